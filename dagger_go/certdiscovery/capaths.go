@@ -0,0 +1,56 @@
+package certdiscovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CAPathEntry is one entry of CA_CERTIFICATES_PATH and the files or
+// directories it resolved to.
+type CAPathEntry struct {
+	Pattern string
+	Matches []string
+	Err     error // invalid glob pattern
+}
+
+// SplitPathList splits a path list such as CA_CERTIFICATES_PATH. On Windows
+// only ";" separates entries, so drive letters (C:\certs) survive; elsewhere
+// both ":" and ";" do. Empty entries are dropped.
+func SplitPathList(value, goos string) []string {
+	separators := ":;"
+	if goos == "windows" {
+		separators = ";"
+	}
+	var entries []string
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ExpandCAPaths resolves each entry of a CA path list: glob patterns (*, ?,
+// [...]) to the files they match, plain paths to themselves when they
+// exist. Entries that match nothing are returned with no matches so they
+// can be reported.
+func ExpandCAPaths(value, goos string) []CAPathEntry {
+	var entries []CAPathEntry
+	for _, pattern := range SplitPathList(value, goos) {
+		entry := CAPathEntry{Pattern: pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+			entry.Err = err
+			for _, m := range matches {
+				if info, err := os.Stat(m); err == nil && !info.IsDir() {
+					entry.Matches = append(entry.Matches, m)
+				}
+			}
+		} else if _, err := os.Stat(pattern); err == nil {
+			entry.Matches = []string{pattern}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
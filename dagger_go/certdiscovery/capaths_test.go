@@ -0,0 +1,57 @@
+package certdiscovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSplitPathList tests CA_CERTIFICATES_PATH splitting on Unix and Windows
+func TestSplitPathList(t *testing.T) {
+	cases := []struct {
+		value, goos string
+		want        []string
+	}{
+		{"/etc/corp/root.pem:/opt/certs", "linux", []string{"/etc/corp/root.pem", "/opt/certs"}},
+		{"/etc/corp/root.pem;/opt/certs: /tmp/x.crt ", "darwin", []string{"/etc/corp/root.pem", "/opt/certs", "/tmp/x.crt"}},
+		{`C:\certs\root.pem;D:\corp\*.pem`, "windows", []string{`C:\certs\root.pem`, `D:\corp\*.pem`}},
+		{`C:/certs/root.pem;;\\fileserver\share\ca.crt;`, "windows", []string{"C:/certs/root.pem", `\\fileserver\share\ca.crt`}},
+		{"", "linux", nil},
+	}
+	for _, c := range cases {
+		if got := SplitPathList(c.value, c.goos); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Fatalf("SplitPathList(%q, %s) = %q, want %q", c.value, c.goos, got, c.want)
+		}
+	}
+	fmt.Println("✅ CA path lists split per OS")
+}
+
+// TestExpandCAPaths tests glob expansion and per-entry match reporting
+func TestExpandCAPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"root.pem", "issuing.pem", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644)
+	}
+	os.Mkdir(filepath.Join(dir, "sub.pem"), 0o755) // directories never match a glob
+
+	value := strings.Join([]string{filepath.Join(dir, "*.pem"), dir, filepath.Join(dir, "missing.crt"), filepath.Join(dir, "[.pem")}, ";")
+	entries := ExpandCAPaths(value, "linux")
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+	if len(entries[0].Matches) != 2 || !strings.HasSuffix(entries[0].Matches[0], "issuing.pem") {
+		t.Fatalf("glob should match the two .pem files: %v", entries[0].Matches)
+	}
+	if len(entries[1].Matches) != 1 || entries[1].Matches[0] != dir {
+		t.Fatalf("a directory entry resolves to itself: %v", entries[1].Matches)
+	}
+	if len(entries[2].Matches) != 0 || entries[2].Err != nil {
+		t.Fatalf("a missing file matches nothing: %+v", entries[2])
+	}
+	if entries[3].Err == nil {
+		t.Fatalf("an invalid pattern should report an error: %+v", entries[3])
+	}
+	fmt.Println("✅ CA path globs expanded")
+}
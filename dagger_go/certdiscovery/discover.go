@@ -0,0 +1,542 @@
+// Package certdiscovery finds the corporate CA certificates of a machine:
+// user-provided files in credentials/certs, the system trust stores, Docker
+// and Rancher Desktop certs.d directories, the host stores Docker inherits,
+// CA_CERTIFICATES_PATH and the CI runners' certificate locations.
+//
+// Discover only looks and prints nothing. The corporate pipeline renders
+// its Result as console output; other tools can log or serialize it.
+package certdiscovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultCertsDir is the directory of user-provided .pem files.
+const DefaultCertsDir = "credentials/certs"
+
+// Source is where a certificate path was discovered.
+type Source string
+
+// Discovery sources, in the order they are checked.
+const (
+	SourceUser          Source = "user"           // DefaultCertsDir
+	SourceSystem        Source = "system"         // system trust stores
+	SourceDocker        Source = "docker"         // Docker/Rancher Desktop certs.d directories
+	SourceDockerHost    Source = "docker-host"    // host stores Docker and Rancher inherit
+	SourceEnv           Source = "env"            // CA_CERTIFICATES_PATH
+	SourceJenkins       Source = "jenkins"        // JENKINS_HOME
+	SourceGitHubActions Source = "github-actions" // RUNNER_TEMP
+)
+
+// NoteKind is the kind of a discovery log line.
+type NoteKind int
+
+// Note kinds.
+const (
+	NoteInfo    NoteKind = iota // nothing to act on, e.g. an optional location is missing
+	NoteDetail                  // what is being checked, e.g. a directory scan
+	NoteFound                   // a certificate path was added
+	NoteWarning                 // something expected is missing; ID names it
+	NoteError                   // a location could not be read
+)
+
+// Note is one line of the discovery log.
+type Note struct {
+	Kind    NoteKind
+	ID      string // warning ID of a NoteWarning, e.g. ca-path
+	Message string
+	Always  bool // worth showing outside a debug log (CA_CERTIFICATES_PATH results)
+}
+
+// SourceLog is the log of checking one source.
+type SourceLog struct {
+	Source Source
+	Title  string
+	Notes  []Note
+}
+
+// Stats counts the locations checked.
+type Stats struct {
+	Attempts  int `json:"attempts"`
+	Successes int `json:"successes"`
+	NotFound  int `json:"not_found"`
+	Errors    int `json:"errors"`
+}
+
+// Entry is one discovered certificate file or directory.
+type Entry struct {
+	Path         string        `json:"path"`
+	Source       Source        `json:"source"`
+	Dir          bool          `json:"dir,omitempty"`
+	Format       Format        `json:"format,omitempty"` // files only
+	Certificates []Certificate `json:"certificates,omitempty"`
+	Excluded     string        `json:"excluded,omitempty"` // why the path is not used
+	Err          error         `json:"-"`                  // the error behind Excluded
+}
+
+// Result is everything Discover found.
+type Result struct {
+	Entries []Entry     `json:"entries"`
+	Sources []SourceLog `json:"-"`
+	Stats   Stats       `json:"stats"`
+}
+
+// Paths returns the paths of the entries that are not excluded.
+func (r Result) Paths() []string {
+	var paths []string
+	for _, e := range r.Entries {
+		if e.Excluded == "" {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// Options configures Discover. The zero value discovers like the corporate
+// pipeline, except that Home must be set for home-based locations.
+type Options struct {
+	CertsDir    string              // user-provided certificates (default: DefaultCertsDir)
+	Home        string              // user's home directory; "" skips home-based locations (see HomeDir)
+	Getenv      func(string) string // environment (default: os.Getenv)
+	GOOS        string              // path list syntax of CA_CERTIFICATES_PATH (default: runtime.GOOS)
+	MaxFileSize int64               // bytes read per certificate file (default: DefaultMaxFileSize)
+	// Root is prepended to the built-in absolute locations (system stores,
+	// Docker directories), so tests can point discovery at a fake tree.
+	Root string
+}
+
+// HomeDir returns the current user's home directory, or "" when none is
+// resolvable. Some CI containers run without HOME; joining paths onto an
+// empty home would produce root-anchored or cwd-relative candidates, so
+// home-derived locations are skipped entirely in that case.
+func HomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// discoverer accumulates a Result.
+type discoverer struct {
+	opts   Options
+	result Result
+	seen   map[string]bool
+}
+
+// Discover checks every source, validates the paths found and reads their
+// certificates. Paths are deduplicated across sources; the first source
+// that found a path is recorded.
+func Discover(opts Options) (Result, error) {
+	if opts.MaxFileSize < 0 {
+		return Result{}, fmt.Errorf("MaxFileSize must not be negative")
+	}
+	if opts.CertsDir == "" {
+		opts.CertsDir = DefaultCertsDir
+	}
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	if opts.GOOS == "" {
+		opts.GOOS = runtime.GOOS
+	}
+	if opts.MaxFileSize == 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	d := &discoverer{opts: opts, seen: map[string]bool{}}
+	d.userCerts()
+	d.systemStores()
+	d.dockerDirs()
+	d.dockerHost()
+	d.envPaths()
+	d.jenkins()
+	d.githubActions()
+
+	for i := range d.result.Entries {
+		e := &d.result.Entries[i]
+		if err := Validate(e.Path, opts.MaxFileSize); err != nil {
+			e.Excluded, e.Err = err.Error(), err
+			continue
+		}
+		if !e.Dir {
+			e.Format, _, _ = Sniff(e.Path, opts.MaxFileSize)
+		}
+		e.Certificates = ReadCertificates(e.Path, opts.MaxFileSize)
+	}
+	return d.result, nil
+}
+
+// source starts the log of a source.
+func (d *discoverer) source(source Source, title string) {
+	d.result.Sources = append(d.result.Sources, SourceLog{Source: source, Title: title})
+}
+
+// note adds a line to the current source's log.
+func (d *discoverer) note(kind NoteKind, id, format string, args ...any) {
+	log := &d.result.Sources[len(d.result.Sources)-1]
+	log.Notes = append(log.Notes, Note{Kind: kind, ID: id, Message: fmt.Sprintf(format, args...)})
+}
+
+// showAlways marks the current source's last note as worth showing
+// outside a debug log.
+func (d *discoverer) showAlways() {
+	log := &d.result.Sources[len(d.result.Sources)-1]
+	log.Notes[len(log.Notes)-1].Always = true
+}
+
+// add records path for the current source unless it was found already.
+func (d *discoverer) add(path string) bool {
+	if d.seen[path] {
+		return false
+	}
+	d.seen[path] = true
+	info, err := os.Stat(path)
+	d.result.Entries = append(d.result.Entries, Entry{
+		Path:   path,
+		Source: d.result.Sources[len(d.result.Sources)-1].Source,
+		Dir:    err == nil && info.IsDir(),
+	})
+	return true
+}
+
+// found adds path as found by the current source, counting and logging it.
+func (d *discoverer) found(path string) bool {
+	if !d.add(path) {
+		return false
+	}
+	d.result.Stats.Successes++
+	d.note(NoteFound, "", "%s", path)
+	return true
+}
+
+// rooted prepends Options.Root to built-in locations.
+func (d *discoverer) rooted(paths []string) []string {
+	if d.opts.Root == "" {
+		return paths
+	}
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = filepath.Join(d.opts.Root, p)
+	}
+	return out
+}
+
+// userCerts collects the .pem files of CertsDir.
+func (d *discoverer) userCerts() {
+	dir := d.opts.CertsDir
+	d.source(SourceUser, "User-provided certificates ("+dir+"/)")
+	d.result.Stats.Attempts++
+	if _, err := os.Stat(dir); err != nil {
+		d.note(NoteInfo, "", "Directory not found (this is optional)")
+		d.result.Stats.NotFound++
+		return
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		d.note(NoteError, "", "Error reading directory: %v", err)
+		d.result.Stats.Errors++
+		return
+	}
+	found := 0
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".pem") && d.found(filepath.Join(dir, file.Name())) {
+			found++
+		}
+	}
+	if found == 0 {
+		d.note(NoteWarning, "ca-dir-empty", "Directory exists but no .pem files found")
+		d.result.Stats.NotFound++
+	}
+}
+
+// systemStores checks the system trust stores and Docker/Rancher Desktop
+// certificate locations directly.
+func (d *discoverer) systemStores() {
+	d.source(SourceSystem, "System certificate stores (50+ locations)")
+	if d.opts.Home == "" {
+		d.note(NoteInfo, "", "No home directory resolvable (HOME unset) — skipping home-based locations")
+	}
+	username := d.opts.Getenv("USERNAME")
+	paths := d.rooted([]string{
+		// Linux/Debian
+		"/etc/ssl/certs/ca-bundle.crt",
+		"/etc/ssl/certs/ca-certificates.crt",
+		// Linux/RHEL
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		// macOS
+		"/etc/ssl/cert.pem",
+		"/usr/local/etc/openssl/cert.pem",
+		// Windows via WSL
+		"/mnt/c/ProgramData/Microsoft/Windows/Certificates/ca-certificates.pem",
+		// Windows native paths
+		`C:\ProgramData\Microsoft\Windows\Certificates\ca-certificates.pem`,
+		`C:\Users\` + username + `\AppData\Local\Corporate_Certificates\ca-bundle.pem`,
+		// Docker Desktop on Windows
+		`C:\Users\` + username + `\.docker\certs.d\docker.io\ca.pem`,
+		`C:\Users\` + username + `\.docker\certs.d\ghcr.io\ca.pem`,
+		`C:\Users\` + username + `\.docker\certs.d`,
+		// Rancher Desktop on Windows
+		`C:\Users\` + username + `\.rancher\certs.d`,
+		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
+		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\config\certs`,
+		// Linux Docker / Rancher Desktop socket
+		"/etc/docker/certs.d",
+		"/var/lib/docker/certs.d",
+		"/etc/rancher/k3s/certs.d",
+	})
+	// macOS Docker Desktop / Rancher Desktop (home-based, skipped when no home is resolvable)
+	paths = append(paths, userCertPaths(d.opts.Home)...)
+
+	found := 0
+	for _, path := range paths {
+		d.result.Stats.Attempts++
+		if _, err := os.Stat(path); err != nil {
+			d.result.Stats.NotFound++
+			continue
+		}
+		if d.found(path) {
+			found++
+		}
+	}
+	if found == 0 {
+		d.note(NoteWarning, "no-system-certs", "No system certificates found (checked all standard locations)")
+	}
+}
+
+// dockerDirs scans the Docker and Rancher Desktop certs.d directories
+// (registry-specific certificates) recursively.
+func (d *discoverer) dockerDirs() {
+	d.source(SourceDocker, "Docker/Rancher Desktop directories (recursive scan)")
+	username := d.opts.Getenv("USERNAME")
+	dirs := d.rooted([]string{
+		// Docker Desktop
+		"/etc/docker/certs.d",
+		"/var/lib/docker/certs.d",
+		`C:\Users\` + username + `\.docker\certs.d`,
+		// Rancher Desktop
+		`C:\Users\` + username + `\.rancher\certs.d`,
+		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
+		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\config\certs`,
+		"/etc/rancher/k3s/certs.d",
+	})
+	// Home- and XDG-based Docker (incl. rootless) and Rancher Desktop directories
+	dirs = append(dirs, userCertDirs(d.opts.Home, d.opts.Getenv("XDG_CONFIG_HOME"), d.opts.Getenv("XDG_DATA_HOME"))...)
+
+	found := 0
+	for _, dir := range dirs {
+		d.result.Stats.Attempts++
+		if n := d.scanDir(dir); n > 0 {
+			d.result.Stats.Successes++
+			found += n
+		} else if _, err := os.Stat(dir); err != nil {
+			d.result.Stats.NotFound++
+		}
+	}
+	if found == 0 {
+		d.note(NoteInfo, "", "No Docker/Rancher certificates found (directories may not exist or be empty)")
+	}
+}
+
+// scanDir adds the .pem and .crt files under dir and returns how many were
+// new.
+func (d *discoverer) scanDir(dir string) int {
+	if _, err := os.Stat(dir); err != nil {
+		d.note(NoteInfo, "", "Directory not found: %s", dir)
+		return 0
+	}
+	d.note(NoteDetail, "", "Scanning: %s", dir)
+	found := 0
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			d.note(NoteWarning, "ca-walk", "Error walking path %s: %v", path, err)
+			d.result.Stats.Errors++
+			return nil
+		}
+		if !info.IsDir() && isCertFile(info.Name()) && d.add(path) {
+			d.note(NoteFound, "", "%s", path)
+			found++
+		}
+		return nil
+	})
+	if found > 0 {
+		d.note(NoteDetail, "", "Found %d certificate(s) in this directory", found)
+	}
+	return found
+}
+
+// dockerHost adds the host certificate stores Docker Desktop and Rancher
+// Desktop inherit and make available to containers.
+func (d *discoverer) dockerHost() {
+	d.source(SourceDockerHost, "Docker host system certificates")
+	d.result.Stats.Attempts++
+	username := d.opts.Getenv("USERNAME")
+	paths := d.rooted([]string{
+		// Windows: Docker Desktop and Rancher Desktop use the Windows certificate store
+		`C:\ProgramData\Microsoft\Windows\Certificates\ca-certificates.pem`,
+		`C:\Program Files\Docker\Docker\resources\certs`,
+		`C:\Program Files\Rancher Desktop\resources\certs`,
+		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
+		// macOS: the system's /etc/ssl/cert.pem
+		"/etc/ssl/cert.pem",
+		"/usr/local/etc/openssl/cert.pem",
+		// Linux: the host's /etc/ssl/certs and system store
+		"/etc/ssl/certs",
+		"/etc/ssl/certs/ca-bundle.crt",
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/rancher/k3s/certs", // Rancher k3s certs
+	})
+	found := 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil && d.found(path) {
+			found++
+		}
+	}
+	if found == 0 {
+		d.note(NoteInfo, "", "No host certificates found (platform may not use standard locations)")
+		d.result.Stats.NotFound++
+	}
+}
+
+// envPaths adds the files and directories of CA_CERTIFICATES_PATH.
+func (d *discoverer) envPaths() {
+	d.source(SourceEnv, "CA_CERTIFICATES_PATH environment variable")
+	d.result.Stats.Attempts++
+	value := d.opts.Getenv("CA_CERTIFICATES_PATH")
+	if value == "" {
+		d.note(NoteInfo, "", "Environment variable not set")
+		d.result.Stats.NotFound++
+		return
+	}
+	d.note(NoteDetail, "", "Checking paths: %s", value)
+	found := 0
+	for _, entry := range ExpandCAPaths(value, d.opts.GOOS) {
+		switch {
+		case entry.Err != nil:
+			d.note(NoteWarning, "ca-path", "CA_CERTIFICATES_PATH: %s: %v", entry.Pattern, entry.Err)
+		case len(entry.Matches) == 0:
+			d.note(NoteWarning, "ca-path", "CA_CERTIFICATES_PATH: %s matched no files", entry.Pattern)
+		default:
+			d.note(NoteDetail, "", "CA_CERTIFICATES_PATH: %s → %d path(s)", entry.Pattern, len(entry.Matches))
+		}
+		d.showAlways()
+		if len(entry.Matches) == 0 {
+			d.result.Stats.NotFound++
+		}
+		for _, path := range entry.Matches {
+			if d.found(path) {
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		d.note(NoteWarning, "ca-path", "Environment variable set but no valid certificates found")
+	}
+}
+
+// jenkins adds the certificate locations of a Jenkins installation.
+func (d *discoverer) jenkins() {
+	d.source(SourceJenkins, "Jenkins CI/CD environment")
+	d.result.Stats.Attempts++
+	jenkinsHome := d.opts.Getenv("JENKINS_HOME")
+	if jenkinsHome == "" {
+		d.note(NoteInfo, "", "Not running in Jenkins (JENKINS_HOME not set)")
+		d.result.Stats.NotFound++
+		return
+	}
+	d.note(NoteDetail, "", "Jenkins detected: %s", jenkinsHome)
+	found := 0
+	for _, path := range []string{
+		filepath.Join(jenkinsHome, "war/WEB-INF/ca-bundle.crt"),
+		filepath.Join(jenkinsHome, "certs"),
+		filepath.Join(jenkinsHome, "ca-certificates"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			d.result.Stats.NotFound++
+			continue
+		}
+		if d.found(path) {
+			found++
+		}
+	}
+	if found == 0 {
+		d.note(NoteWarning, "ci-certs-missing", "Jenkins detected but no certificates found in standard locations")
+	}
+}
+
+// githubActions adds the custom certificates of a GitHub Actions runner.
+func (d *discoverer) githubActions() {
+	d.source(SourceGitHubActions, "GitHub Actions runner environment")
+	d.result.Stats.Attempts++
+	runnerTemp := d.opts.Getenv("RUNNER_TEMP")
+	if runnerTemp == "" {
+		d.note(NoteInfo, "", "Not running in GitHub Actions (RUNNER_TEMP not set)")
+		d.result.Stats.NotFound++
+		return
+	}
+	d.note(NoteDetail, "", "GitHub Actions detected: %s", runnerTemp)
+	path := filepath.Join(runnerTemp, "ca-certificates")
+	if _, err := os.Stat(path); err != nil {
+		d.note(NoteWarning, "ci-certs-missing", "GitHub Actions detected but no custom certificates found")
+		d.result.Stats.NotFound++
+		return
+	}
+	d.found(path)
+}
+
+// xdgBaseDir resolves an XDG base directory. Per the XDG spec a relative
+// value is invalid and ignored; when unset it falls back to home/fallback.
+func xdgBaseDir(value, home, fallback string) string {
+	if value != "" && filepath.IsAbs(value) {
+		return value
+	}
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, fallback)
+}
+
+// userCertPaths returns home-based Docker Desktop / Rancher Desktop
+// certificate locations that are checked directly (files and directories).
+func userCertPaths(home string) []string {
+	if home == "" {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, ".docker/certs.d/docker.io/ca.pem"),
+		filepath.Join(home, ".docker/certs.d/ghcr.io/ca.pem"),
+		filepath.Join(home, ".docker/certs.d"),
+		filepath.Join(home, ".rancher/certs.d"),
+		// macOS Docker Desktop Group Containers (sandboxed storage)
+		filepath.Join(home, "Library/Group Containers/group.com.docker/certs"),
+		filepath.Join(home, "Library/Group Containers/group.com.docker/settings/ca-certificates"),
+	}
+}
+
+// userCertDirs returns the per-user certs.d directories scanned
+// recursively: ~/.docker and ~/.rancher, plus XDG-relocated configurations
+// (rootless Docker reads $XDG_CONFIG_HOME/docker/certs.d, Rancher Desktop on
+// Linux keeps its state under $XDG_CONFIG_HOME and $XDG_DATA_HOME).
+func userCertDirs(home, xdgConfigHome, xdgDataHome string) []string {
+	var dirs []string
+	if home != "" {
+		dirs = append(dirs,
+			filepath.Join(home, ".docker/certs.d"),
+			filepath.Join(home, ".rancher/certs.d"),
+		)
+	}
+	if configHome := xdgBaseDir(xdgConfigHome, home, ".config"); configHome != "" {
+		dirs = append(dirs,
+			filepath.Join(configHome, "docker/certs.d"),
+			filepath.Join(configHome, "rancher-desktop/certs.d"),
+		)
+	}
+	if dataHome := xdgBaseDir(xdgDataHome, home, ".local/share"); dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "rancher-desktop/certs"))
+	}
+	return dirs
+}
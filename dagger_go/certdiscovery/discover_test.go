@@ -0,0 +1,201 @@
+package certdiscovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCertCandidatesHomeUnset tests that no home-derived paths are produced without HOME
+func TestCertCandidatesHomeUnset(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("HOME is not consulted on Windows")
+	}
+	t.Setenv("HOME", "")
+
+	home := HomeDir()
+	if home != "" {
+		t.Fatalf("HomeDir() = %q, want empty when HOME is unset", home)
+	}
+	if paths := userCertPaths(home); len(paths) != 0 {
+		t.Fatalf("expected no home-based paths, got %v", paths)
+	}
+	if dirs := userCertDirs(home, "", ""); len(dirs) != 0 {
+		t.Fatalf("expected no home-based dirs, got %v", dirs)
+	}
+	fmt.Println("✅ Home-derived candidates skipped when HOME is unset")
+}
+
+// TestCertCandidatesHomeSet tests the default ~/.docker, ~/.rancher and XDG fallbacks
+func TestCertCandidatesHomeSet(t *testing.T) {
+	home := filepath.FromSlash("/home/dev")
+
+	paths := userCertPaths(home)
+	for _, p := range paths {
+		if !strings.HasPrefix(p, home) {
+			t.Fatalf("path %q is not under home %q", p, home)
+		}
+	}
+	if !containsPath(paths, filepath.Join(home, ".docker/certs.d/ghcr.io/ca.pem")) {
+		t.Fatalf("missing GHCR Docker Desktop cert in %v", paths)
+	}
+
+	dirs := userCertDirs(home, "", "")
+	expected := []string{
+		filepath.Join(home, ".docker/certs.d"),
+		filepath.Join(home, ".rancher/certs.d"),
+		filepath.Join(home, ".config/docker/certs.d"),
+		filepath.Join(home, ".config/rancher-desktop/certs.d"),
+		filepath.Join(home, ".local/share/rancher-desktop/certs"),
+	}
+	for _, want := range expected {
+		if !containsPath(dirs, want) {
+			t.Fatalf("expected %q in %v", want, dirs)
+		}
+	}
+	fmt.Println("✅ Home-based candidates generated with XDG defaults")
+}
+
+// TestCertCandidatesXDGOverrides tests that XDG variables relocate the Docker/Rancher dirs
+func TestCertCandidatesXDGOverrides(t *testing.T) {
+	home := filepath.FromSlash("/home/dev")
+	xdgConfig := filepath.FromSlash("/srv/config")
+	xdgData := filepath.FromSlash("/srv/data")
+
+	dirs := userCertDirs(home, xdgConfig, xdgData)
+	for _, want := range []string{
+		filepath.Join(xdgConfig, "docker/certs.d"),
+		filepath.Join(xdgConfig, "rancher-desktop/certs.d"),
+		filepath.Join(xdgData, "rancher-desktop/certs"),
+	} {
+		if !containsPath(dirs, want) {
+			t.Fatalf("expected %q in %v", want, dirs)
+		}
+	}
+	if containsPath(dirs, filepath.Join(home, ".config/docker/certs.d")) {
+		t.Fatal("default XDG location should not be used when XDG_CONFIG_HOME is set")
+	}
+
+	// XDG overrides still apply without a home directory
+	noHome := userCertDirs("", xdgConfig, "")
+	if len(noHome) != 2 || !containsPath(noHome, filepath.Join(xdgConfig, "docker/certs.d")) {
+		t.Fatalf("expected only XDG config dirs without HOME, got %v", noHome)
+	}
+
+	// Relative XDG values are invalid per the spec and ignored
+	relative := userCertDirs("", "relative/config", "relative/data")
+	if len(relative) != 0 {
+		t.Fatalf("relative XDG values should be ignored, got %v", relative)
+	}
+	fmt.Println("✅ XDG overrides relocate Docker/Rancher certificate dirs")
+}
+
+// TestDiscoverFakeTree tests discovery over a fake filesystem tree: sources, deduplication, validation and notes
+func TestDiscoverFakeTree(t *testing.T) {
+	root := t.TempDir()
+	expiry := time.Now().Add(24 * time.Hour)
+	write := func(path string, data []byte) string {
+		t.Helper()
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return full
+	}
+	corp := write("work/credentials/certs/corp.pem", testCertPEM(t, "Corp Root CA", expiry))
+	bad := write("work/credentials/certs/bad.pem", []byte{0x00, 0x01})
+	write("work/credentials/certs/readme.txt", []byte("not a certificate"))
+	bundle := write("etc/ssl/certs/ca-certificates.crt", testCertPEM(t, "Public Root", expiry))
+	registry := write("etc/docker/certs.d/registry.corp:5000/ca.crt", testCertPEM(t, "Registry CA", expiry))
+	extra := write("extra/proxy.pem", testCertPEM(t, "Proxy CA", expiry))
+
+	env := map[string]string{
+		"CA_CERTIFICATES_PATH": filepath.Join(root, "extra", "*.pem") + ";" + filepath.Join(root, "missing.pem"),
+		"RUNNER_TEMP":          filepath.Join(root, "runner-temp"),
+	}
+	result, err := Discover(Options{
+		CertsDir: filepath.Join(root, "work/credentials/certs"),
+		Getenv:   func(key string) string { return env[key] },
+		GOOS:     "windows", // ";" separates CA_CERTIFICATES_PATH entries
+		Root:     root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]Source{}
+	for _, e := range result.Entries {
+		if _, dup := sources[e.Path]; dup {
+			t.Fatalf("%s discovered twice", e.Path)
+		}
+		sources[e.Path] = e.Source
+	}
+	want := map[string]Source{
+		corp:     SourceUser,
+		bad:      SourceUser,
+		bundle:   SourceSystem,
+		registry: SourceDocker,
+		extra:    SourceEnv,
+	}
+	for path, source := range want {
+		if sources[path] != source {
+			t.Fatalf("%s: source %q, want %q (entries %+v)", path, sources[path], source, result.Entries)
+		}
+	}
+
+	paths := result.Paths()
+	if containsPath(paths, bad) || !containsPath(paths, corp) {
+		t.Fatalf("invalid files should be excluded from Paths, got %v", paths)
+	}
+	for _, e := range result.Entries {
+		switch e.Path {
+		case bad:
+			if !strings.Contains(e.Excluded, "neither PEM nor DER") {
+				t.Fatalf("bad.pem should be excluded, got %+v", e)
+			}
+		case corp:
+			if e.Format != FormatPEM || len(e.Certificates) != 1 || e.Certificates[0].Subject != "CN=Corp Root CA" {
+				t.Fatalf("corp.pem read wrong: %+v", e)
+			}
+		}
+	}
+
+	var notes []string
+	for _, log := range result.Sources {
+		for _, n := range log.Notes {
+			if n.Kind == NoteWarning {
+				notes = append(notes, fmt.Sprintf("%s always=%v", n.ID, n.Always))
+			}
+		}
+	}
+	joined := strings.Join(notes, ", ")
+	for _, want := range []string{"ca-path always=true", "ci-certs-missing always=false"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected warning %q, got %s", want, joined)
+		}
+	}
+	if result.Stats.Successes == 0 || result.Stats.NotFound == 0 {
+		t.Fatalf("stats not counted: %+v", result.Stats)
+	}
+	if _, err := Discover(Options{MaxFileSize: -1}); err == nil {
+		t.Fatal("a negative MaxFileSize should be rejected")
+	}
+	fmt.Println("✅ Discovery over a fake tree")
+}
+
+// containsPath reports whether paths contains p
+func containsPath(paths []string, p string) bool {
+	for _, candidate := range paths {
+		if candidate == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package certdiscovery
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultMaxFileSize caps how much of a single certificate file is read;
+// real CA bundles are a few hundred KiB at most.
+const DefaultMaxFileSize = 5 << 20
+
+// ErrTooLarge is returned for a certificate file over the size limit.
+var ErrTooLarge = errors.New("larger than the size limit")
+
+// Format is the content type detected by sniffing a certificate file.
+type Format string
+
+// Certificate file formats.
+const (
+	FormatUnknown Format = "unknown"
+	FormatPEM     Format = "PEM"
+	FormatDER     Format = "DER"
+)
+
+// Certificate is one certificate found in a discovered file.
+type Certificate struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	SHA256   string    `json:"sha256"`         // fingerprint of the DER encoding
+	File     string    `json:"file,omitempty"` // file within a directory entry
+}
+
+// Expired reports whether the certificate has expired at now.
+func (c Certificate) Expired(now time.Time) bool {
+	return now.After(c.NotAfter)
+}
+
+// Sniff classifies a certificate file by content without loading it whole:
+// files over maxBytes are rejected outright, DER is recognised from the
+// leading ASN.1 SEQUENCE header, and PEM blocks are counted by streaming the
+// file line by line. Binary content that is not DER is classified as
+// unknown as soon as a NUL byte is seen.
+func Sniff(path string, maxBytes int64) (Format, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FormatUnknown, 0, fmt.Errorf("cannot read certificate file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return FormatUnknown, 0, fmt.Errorf("cannot stat certificate file: %w", err)
+	}
+	if info.Size() > maxBytes {
+		return FormatUnknown, 0, fmt.Errorf("file is %s, %w of %s", formatBytes(info.Size()), ErrTooLarge, formatBytes(maxBytes))
+	}
+
+	reader := bufio.NewReader(io.LimitReader(f, maxBytes))
+	head, _ := reader.Peek(512)
+	// DER certificates start with SEQUENCE (0x30) and a long-form length (0x81-0x84)
+	if len(head) >= 2 && head[0] == 0x30 && head[1] >= 0x81 && head[1] <= 0x84 {
+		return FormatDER, 1, nil
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return FormatUnknown, 0, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	blocks := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if bytes.HasPrefix(line, []byte("-----BEGIN ")) && bytes.Contains(line, []byte("CERTIFICATE-----")) {
+			blocks++
+		}
+	}
+	if err := scanner.Err(); err != nil && blocks == 0 {
+		// Overlong "lines" mean binary or minified content — not a PEM bundle
+		return FormatUnknown, 0, nil
+	}
+	if blocks > 0 {
+		return FormatPEM, blocks, nil
+	}
+	return FormatUnknown, 0, nil
+}
+
+// Validate checks that a certificate path is readable and usable. A
+// directory must contain .pem or .crt files; a file is content-sniffed with
+// reads capped at maxBytes, and anything that is neither PEM nor DER is
+// rejected so it is never mounted into a container.
+func Validate(path string, maxBytes int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("certificate not accessible: %w", err)
+	}
+
+	if info.IsDir() {
+		hasValidCerts := false
+		filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && isCertFile(info.Name()) {
+				hasValidCerts = true
+			}
+			return nil
+		})
+		if !hasValidCerts {
+			return fmt.Errorf("directory contains no .pem or .crt files")
+		}
+		return nil
+	}
+
+	format, _, err := Sniff(path, maxBytes)
+	if err != nil {
+		return err
+	}
+	if format == FormatUnknown {
+		return fmt.Errorf("unrecognized content (neither PEM nor DER) — skipped")
+	}
+	return nil
+}
+
+// ReadCertificates parses the certificates of a file, or of the .pem and
+// .crt files under a directory. Files over maxBytes and content that does
+// not parse are left out.
+func ReadCertificates(path string, maxBytes int64) []Certificate {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return readCertificateFile(path, "", maxBytes)
+	}
+	var certs []Certificate
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && isCertFile(info.Name()) {
+			rel, _ := filepath.Rel(path, file)
+			certs = append(certs, readCertificateFile(file, rel, maxBytes)...)
+		}
+		return nil
+	})
+	return certs
+}
+
+// readCertificateFile parses a PEM bundle or a DER certificate.
+func readCertificateFile(path, name string, maxBytes int64) []Certificate {
+	format, _, err := Sniff(path, maxBytes)
+	if err != nil || format == FormatUnknown {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var ders [][]byte
+	if format == FormatDER {
+		ders = [][]byte{data}
+	}
+	for format == FormatPEM {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+	var certs []Certificate
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(cert.Raw)
+		certs = append(certs, Certificate{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter,
+			SHA256:   hex.EncodeToString(sum[:]),
+			File:     name,
+		})
+	}
+	return certs
+}
+
+// isCertFile reports whether a file name has a certificate extension.
+func isCertFile(name string) bool {
+	return strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, ".crt")
+}
+
+// formatBytes renders a byte count using binary units, e.g. "5.0 MiB". The
+// package does not import the pipeline, so tools using it do not pull in
+// the Dagger SDK.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
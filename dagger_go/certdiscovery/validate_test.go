@@ -0,0 +1,146 @@
+package certdiscovery
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertPEM returns a self-signed CA certificate in PEM form.
+func testCertPEM(t *testing.T, cn string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// TestSniffCertificateFormats tests PEM/DER/unknown classification
+func TestSniffCertificateFormats(t *testing.T) {
+	dir := t.TempDir()
+	pemBundle := "# corporate bundle\n-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n" +
+		"-----BEGIN CERTIFICATE-----\nMIIC\n-----END CERTIFICATE-----\n"
+	files := map[string][]byte{
+		"bundle.pem": []byte(pemBundle),
+		"root.der":   append([]byte{0x30, 0x82, 0x03, 0x21}, bytes.Repeat([]byte{0x01}, 64)...),
+		"blob.bin":   append([]byte("ELF"), bytes.Repeat([]byte{0x00, 0xff}, 512)...),
+		"notes.txt":  []byte("just some text\nno certificates here\n"),
+		"long.pem":   bytes.Repeat([]byte("A"), 2<<20),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		blocks int
+	}{
+		{"bundle.pem", FormatPEM, 2},
+		{"root.der", FormatDER, 1},
+		{"blob.bin", FormatUnknown, 0},
+		{"notes.txt", FormatUnknown, 0},
+		{"long.pem", FormatUnknown, 0},
+	}
+	for _, tc := range tests {
+		format, blocks, err := Sniff(filepath.Join(dir, tc.name), DefaultMaxFileSize)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if format != tc.format || blocks != tc.blocks {
+			t.Fatalf("%s: got (%s, %d), want (%s, %d)", tc.name, format, blocks, tc.format, tc.blocks)
+		}
+	}
+	fmt.Println("✅ Certificate files classified by content")
+}
+
+// TestValidateSizeLimit tests that oversized files are rejected without being read
+func TestValidateSizeLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "huge.pem")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\n"), 1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Validate(path, 1024); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected oversized file to be rejected with ErrTooLarge, got: %v", err)
+	}
+	if err := Validate(path, DefaultMaxFileSize); err != nil {
+		t.Fatalf("file within limit should validate, got: %v", err)
+	}
+	fmt.Println("✅ Oversized certificate files rejected")
+}
+
+// TestValidateUnknownContent tests that non-certificate files are rejected
+func TestValidateUnknownContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "random.crt")
+	if err := os.WriteFile(path, []byte{0x00, 0x01, 0x02, 0x03}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Validate(path, DefaultMaxFileSize)
+	if err == nil || !strings.Contains(err.Error(), "neither PEM nor DER") {
+		t.Fatalf("expected unrecognized content error, got: %v", err)
+	}
+	fmt.Println("✅ Unknown certificate content rejected")
+}
+
+// TestReadCertificates tests reading subjects, expiry and fingerprints from files and directories
+func TestReadCertificates(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(365 * 24 * time.Hour).Truncate(time.Second)
+	bundle := append(testCertPEM(t, "Corp Root CA", expiry), testCertPEM(t, "Corp Issuing CA", expiry)...)
+	if err := os.WriteFile(filepath.Join(dir, "bundle.pem"), bundle, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(testCertPEM(t, "Proxy CA", time.Now().Add(-time.Hour)))
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "proxy.crt"), block.Bytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	certs := ReadCertificates(filepath.Join(dir, "bundle.pem"), DefaultMaxFileSize)
+	if len(certs) != 2 || certs[0].Subject != "CN=Corp Root CA" || !certs[0].NotAfter.Equal(expiry) || len(certs[0].SHA256) != 64 {
+		t.Fatalf("got %+v", certs)
+	}
+	if certs[0].SHA256 == certs[1].SHA256 {
+		t.Fatal("different certificates should have different fingerprints")
+	}
+
+	all := ReadCertificates(dir, DefaultMaxFileSize)
+	if len(all) != 3 {
+		t.Fatalf("a directory should yield the certificates of its files, got %+v", all)
+	}
+	der := all[2]
+	if der.File != filepath.Join("sub", "proxy.crt") || der.Subject != "CN=Proxy CA" || !der.Expired(time.Now()) {
+		t.Fatalf("DER certificate read wrong: %+v", der)
+	}
+	fmt.Println("✅ Certificates read with subject, expiry and fingerprint")
+}
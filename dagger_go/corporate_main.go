@@ -4,53 +4,34 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"dagger.io/dagger"
+
+	"cert-parser-dagger-go/certdiscovery"
+	"cert-parser-dagger-go/pipeline"
 )
 
 // Constants for corporate pipeline
 const (
 	corporateSeparatorLine = "─────────────────────────────────────────────────────────────────────────────────"
-	baseImageCorporate     = "python:3.14-slim"
-	appWorkdirCorporate    = "/app"
 	dockerUnixPrefixCorp   = "unix://"
 )
 
-// CorporatePipeline represents the cert-parser CI/CD pipeline with corporate
-// MITM proxy and custom CA certificate support. All project-specific values
-// (name, image name) are discovered at runtime from pyproject.toml — nothing
-// is hardcoded. Git host, container registry, and test stages are individually
-// configurable via environment variables.
-type CorporatePipeline struct {
-	RepoName            string
-	ProjectName         string // Discovered from pyproject.toml
-	ImageName           string
-	GitRepo             string
-	GitBranch           string
-	GitUser             string
-	GitHost             string // Git server hostname (e.g. "github.com", "gitlab.com")
-	Registry            string // Container registry (e.g. "ghcr.io", "registry.gitlab.com")
-	GitAuthUser         string // HTTP auth username for git clone (e.g. "x-access-token", "oauth2")
-	PipCache            *dagger.CacheVolume // pip package cache
-	HasDocker           bool                // Docker available on host for testcontainers
-	RunUnitTests        bool                // Run pytest unit tests (default: true)
-	RunIntegrationTests bool                // Run pytest integration tests (default: true)
-	RunAcceptanceTests  bool                // Run pytest acceptance tests (default: true)
-	RunLint             bool                // Run ruff lint (default: true)
-	RunTypeCheck        bool                // Run mypy type check (default: true)
-	CACertPaths         []string            // Paths to CA certificates
-	ProxyURL            string              // HTTP proxy URL
-	DebugMode           bool                // Enable certificate discovery diagnostics
-}
+// warnings collects the warnings raised before the pipeline starts; the
+// pipeline adds its own and lists them all at the end of the run.
+var warnings = pipeline.NewWarnings()
 
 // parseEnvBool parses boolean environment variables with a default fallback
 func parseEnvBool(key string, defaultValue bool) bool {
@@ -62,115 +43,422 @@ func parseEnvBool(key string, defaultValue bool) bool {
 	return value == "true" || value == "1" || value == "yes"
 }
 
+// applyConfigFile loads PIPELINE_CONFIG (default pipeline.yaml when
+// present) and exports its options for the variables left unset, before
+// anything reads them.
+func applyConfigFile() *pipeline.ConfigFile {
+	configFile, err := pipeline.ResolveConfigFile(os.LookupEnv)
+	if err == nil && configFile != nil {
+		err = configFile.Apply(os.LookupEnv, os.Setenv)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	return configFile
+}
+
+// applyProfile resolves PIPELINE_PROFILE, exports its defaults for the
+// variables left unset so the usual parsing picks them up, and prints the
+// resolved settings.
+func applyProfile() *pipeline.ProfileRecord {
+	profile, err := pipeline.ResolveProfile(os.Getenv(pipeline.ProfileEnv), os.LookupEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	for _, s := range profile.Settings {
+		if s.Source == pipeline.SourceProfile {
+			os.Setenv(s.Name, s.Value)
+		}
+	}
+	pipeline.PrintProfile(os.Stdout, profile)
+	return profile
+}
+
+// setFlags are the command-line flags given, by name, for
+// pipeline.ResolveOptionSources; option flags and their aliases are keyed
+// by the option they set.
+func setFlags(optionFlags *pipeline.OptionFlags) map[string]string {
+	flags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	maps.Copy(flags, optionFlags.Values())
+	return flags
+}
+
 // main runs the cert-parser CI/CD pipeline with corporate MITM proxy and
-// custom CA certificate support. Mirrors main.go but adds CA/proxy handling.
+// custom CA certificate support. Like main.go it only translates environment
+// variables into a pipeline.Config; on top of that it discovers and validates
+// CA certificates and passes them, with the proxy, to the library.
 //
-// Required: CR_PAT (registry/git token), USERNAME, REPO_NAME.
+// Required: USERNAME, REPO_NAME, plus CR_PAT (registry/git token) when publishing.
+// On a GitHub Actions runner both default to the workflow's repository, and
+// without CR_PAT the workflow's GITHUB_TOKEN publishes to ghcr.io and clones
+// that repository (see main.go).
 //
 // Repository & registry configuration:
 //
 //	GIT_HOST=github.com|gitlab.com|...       (default: github.com)
-//	REGISTRY=ghcr.io|registry.gitlab.com|... (default: ghcr.io)
+//	REGISTRY_HOST=docker.io                  registry to publish to, e.g. harbor.corp.local or an ECR host (default: ghcr.io; REGISTRY also works)
+//	REGISTRY_NAMESPACE=team                  namespace of the image, e.g. a Harbor project; / for none, as on ECR (default: USERNAME)
+//	REGISTRY_USERNAME=AWS                    registry login (default: USERNAME)
+//	REGISTRY_PASSWORD=<token>                registry password, e.g. an ECR login password (default: CR_PAT)
+//	REGISTRY_PASSWORD_FILE=<path>            read the registry password from a file instead; re-read after a 401 during publish
 //	GIT_AUTH_USERNAME=x-access-token|oauth2|... (default: x-access-token)
-//	GIT_BRANCH=main                          (default: main)
+//	GIT_BRANCH=main                          (default: main) also refs/heads/<branch>, origin/<branch> or a commit SHA
+//	GIT_TAG=v1.2.0                           build a tag instead; the image tag starts with it instead of v<version>
+//	GIT_COMMIT=<sha>                         build a 40-hex commit SHA instead (set only one of the three)
+//	LOCAL_SOURCE=<path>                      build this host directory instead of cloning, e.g. ".." (or --source-dir);
+//	                                         tagged with its `git rev-parse HEAD` or "local"; CR_PAT only when publishing
+//	BEHIND_WARN_THRESHOLD=50                 warn when the branch is more commits behind the default branch
+//	BEHIND_STRICT=true|false                 fail a publishing run over that threshold (default: false)
+//	COMMIT_CHECK=warn|fail|off               check that the built commit is on GIT_BRANCH after the clone and before
+//	                                         publishing (default: warn)
+//	CLOCK_SKEW_THRESHOLD=2m                  warn when the local clock is further off the Git host's Date header
+//	CLOCK_SKEW_STRICT=true|false             fail a publishing run over that threshold (default: false)
 //	IMAGE_NAME=<name>                        (default: auto-discovered from pyproject.toml)
+//	NAME_CHECK=warn|fail|off                 compare the pyproject.toml name with REPO_NAME and IMAGE_NAME (default: warn)
+//	IMAGE_LABELS=com.acme.team=pki,...       extra image labels next to the OCI source, revision, created, version and title
+//	RELEASE_VERSION=1.4.0                    set the pyproject.toml version on the default branch, push it with tag v1.4.0, build the tag
+//	RELEASE_DRY_RUN=true|false               check the release and print what it would push, then stop (default: false)
+//	RELEASE_AUTHOR="Name <email>"            author of the release commit and tag (default: Release Pipeline)
+//	CR_PAT_COMMAND=<cmd>                     prints a fresh registry token after a 401 during publish
+//	CR_PAT_FILE=<path>                       re-read for a fresh registry token after a 401 during publish
+//	GIT_DEPENDENCY_TOKEN=<token>             token pip uses for private git+https dependencies (default: CR_PAT)
+//	GIT_DEPENDENCY_HOSTS=<[user@]host …>     hosts it is offered to (default: GIT_AUTH_USERNAME@GIT_HOST)
+//	DISCOVER_PROJECTS_GLOB=services/*        run once per matching directory with a pyproject.toml
+//	DISCOVER_CHANGED_ONLY=true|false         only projects changed since CHANGED_BASE (default: false)
+//	CHANGED_BASE=<ref>                       ref compared with HEAD in the host checkout (default: origin/main)
+//
+// Command-line flags (--help lists them with their defaults):
+//
+//	--<option>[=value]                       every option except secrets, named like config-schema: --repo-name=x,
+//	                                         --debug-certs, --run-lint=false; aliases --branch and --skip-unit,
+//	                                         --skip-integration, --skip-acceptance, --skip-lint, --skip-typecheck,
+//	                                         --skip-build, --skip-publish; precedence: flag > environment >
+//	                                         PIPELINE_CONFIG > PIPELINE_PROFILE > default
+//
+// Config file & profiles (defaults only — variables set explicitly always win):
+//
+//	PIPELINE_CONFIG=<path>                   YAML mapping of options by name (default: pipeline.yaml when present),
+//	                                         e.g. run-unit-tests: false; unknown keys fail; CR_PAT stays in the environment
+//	PIPELINE_PROFILE=pr|main|nightly         pr: no publish, CHANGED_ONLY_TESTS, LINT_CHANGED_ONLY; main: today's defaults (default);
+//	                                         nightly: main plus slow tests, benchmarks, audit, scan and NO_CACHE
 //
 // Optional:
 //
 //	HTTP_PROXY / HTTPS_PROXY   MITM proxy URL
+//	PROXY_AUTODETECT=ask       Proxy from the macOS/Windows settings when HTTP_PROXY is unset:
+//	                           ask (prompt on a terminal, warn in CI), adopt or off
 //	DEBUG_CERTS=true           Enable certificate discovery diagnostics
-//	CA_CERTIFICATES_PATH=...   Colon-separated paths to CA certs
+//	CA_CERTIFICATES_PATH=...   CA files, directories or globs (/opt/corp/certs/*.pem), separated by
+//	                           ":" or ";" — only ";" on Windows, so C:\certs\root.pem works
+//	CERT_MAX_FILE_SIZE=5MiB    Largest certificate file read during validation
+//	OUTPUT=compact             One line per stage, and the end of a failed stage's output (default: full)
+//	STATUS_FILE=<path>         status.json rewritten after every stage transition
+//	EVENTS_FILE=<path>         JSON-lines event stream appended to next to the console output
+//	HTML_REPORT=true           Also render the report as a self-contained <ARTIFACTS_DIR>/report.html
+//	STAGE_ARTIFACTS_LIMIT=100MiB  Cap on the files a container stage leaves in /app/.pipeline-artifacts, exported
+//	                           to <ARTIFACTS_DIR>/<stage> after it passes or fails ("off" disables)
+//	STATUS_LISTEN=:8088        Serve /status, /report and /healthz while running (binds 127.0.0.1 by default)
+//	STATUS_ALLOW_REMOTE=true   Allow a non-loopback STATUS_LISTEN address; the endpoints have no authentication
+//	DURATION_BUDGET=20m        Warn (or exit 10 with DURATION_BUDGET_HARD=true) when a successful run exceeds it
+//	DURATION_HISTORY_FILE=...  Per-branch duration history (default: user cache dir)
+//	BASELINE_FREEZE=<path>     requirements-resolved.txt to diff the new freeze against
+//	FREEZE_HISTORY_DIR=<dir>   Last freeze, image size and built commit per branch (default: user cache dir)
+//	FORCE_RUN=true             Run even when the last successful run on the branch built the same commit
+//	VERIFY_UNCHANGED=true      Skip an unchanged commit only while its published images are still in the registry
+//	IMAGE_SIZE_BUDGET=300MB    Warn (or fail with IMAGE_SIZE_HARD_LIMIT=true) when the image is larger
+//	IMAGE_SIZE_MAX_GROWTH=20   Same when the image grew more (percent) since the branch's previous build
+//	WARNINGS_BUDGET=50         Fail on more Python warnings in the tests, or on categories new since the branch's last accepted run
+//	PLATFORMS=linux/amd64,linux/arm64  Build the image for each platform and publish manifest lists
+//	LARGE_IMAGE_THRESHOLD=1GiB Retry the publish of a larger image with backoff and print progress ("off" disables)
+//	LARGE_IMAGE_PUBLISH_ATTEMPTS=4   Publish attempts for a large image
+//	LARGE_IMAGE_PUBLISH_TIMEOUT=45m  Time limit per publish attempt of a large image
+//	MIN_FREE_SPACE=5GiB        Fail before any stage when the engine, temp or artifacts disk has less free space ("off" disables)
+//	DAGGER_ENGINE_STATE_DIR=<dir>    Engine state directory checked for free space (default: /var/lib/docker, local engine on Linux)
+//	DEPLOY_WEBHOOK=<url>       POSTed the published image metadata
+//	DEPLOY_VERIFY_URL=<url>    Polled after the webhook until it reports the new image tag or commit
+//	DEPLOY_VERIFY_FIELD=<path> JSON field compared, e.g. build.commit (default: whole body)
+//	DEPLOY_VERIFY_MATCH=<text> Expected value (default: the image tag or short commit SHA)
+//	DEPLOY_VERIFY_TIMEOUT=5m   How long to poll before failing with exit code 9
+//	FLAKY_THRESHOLD=0.2        Failure rate from which intermittently failing tests are flagged
+//	FLAKY_WINDOW=20            Runs kept in the flaky-test history
+//	FLAKY_HISTORY_FILE=<path>  Flaky-test history (default: <ARTIFACTS_DIR>/history/test-history.json)
+//	FLAKY_HISTORY_URL=<url>    Remote flaky-test history (GET/PUT) instead of the file
+//	LINT_HISTORY_FILE=<path>   ruff/mypy counts per run (default: <ARTIFACTS_DIR>/history/lint-history.json)
+//	LINT_RATCHET=true|false    Fail lint/type check when findings grew since the previous run (default: false)
+//	WARNINGS_AS_ERRORS=true    Fail an otherwise passing run on any warning (all are listed in a final section)
+//	WARNINGS_ALLOW=<id,...>    Warning IDs that stay non-fatal, e.g. tool-unpinned,branch-behind
 //
-// Test configuration environment variables (all default true):
+// Test configuration environment variables (default true unless stated):
 //
 //	RUN_UNIT_TESTS=true|false
 //	RUN_INTEGRATION_TESTS=true|false   — requires Docker on host
 //	RUN_ACCEPTANCE_TESTS=true|false    — requires Docker on host
+//	ACCEPTANCE_AGAINST_IMAGE=true|false — acceptance tests call the built image (default false)
+//	ACCEPTANCE_IMAGE_PORT=<port>       port the image serves on (default: its first exposed port)
+//	SERVICE_READY_URL=tcp|/path|<url>  probe the image passes before the tests (default: tcp, the port accepting connections)
+//	SERVICE_READY_INTERVAL=<duration>  between probes (default: 500ms)
+//	SERVICE_READY_TIMEOUT=<duration>   before the image's logs are printed and the run fails (default: 60s)
 //	RUN_LINT=true|false
 //	RUN_TYPE_CHECK=true|false
+//	RUN_BUILD=true|false
+//	RUN_PUBLISH=true|false             — implies RUN_BUILD
+//	PUBLISH_TEST_IMAGE=true|false      — also publish <image>-tests:<tag> with the test suite (default: false)
+//	PUBLISH_PR_IMAGE=true|false        — in a pull request build, publish only the moving <image>:pr-<number> tag (default: false)
+//	ALLOW_FORK_PR_IMAGE=true|false     also publish it for pull requests from forks, whose code is untrusted (default: false)
+//	COMMIT_STATUS=true|false           post a GitHub commit status for the run and each stage; skipped stages are pending with their reason (default: false)
+//	PR_COMMENT=true|false              in a pull request build, comment the stage table on it (default: false)
+//	PR_IMAGE_CLEANUP=true|false        after a publish outside a pull request, delete the GHCR images of closed pull requests (default: false)
+//	REQUIRE_DOCKER_TESTS=true|false    fail instead of skipping Docker tests (default: true on main or when publishing)
+//	PUBLISH_REQUIRES=unit,lint         stages that must run and pass in this run before publishing (default: unit)
+//	PUBLISH_POLICY=on|off              off publishes without that check, with a loud warning (default: on)
+//	ALLOW_EMPTY_TEST_STAGE=true|false  pass a test stage that collects no tests (default: false)
+//	RUN_SLOW_TESTS=true|false          pytest -m slow in its own stage, not in unit tests (default: false)
+//	RUN_BENCHMARKS=true|false          pytest -m benchmark in its own stage (default: false)
+//	PYTHON_VERSIONS=3.12,3.13,3.14     also run the unit tests, lint and type check on these Python versions, concurrently;
+//	                                   the image is still built from BASE_IMAGE (optional)
+//	DEP_RESOLUTION_MATRIX=highest,lowest also run the unit tests against the lowest allowed dependency versions (default: highest)
+//	CHANGED_ONLY_TESTS=true|false      only tests affected by changes since CHANGED_BASE (default: false)
+//	LINT_CHANGED_ONLY=true|false       ruff/mypy only on Python files changed since DIFF_BASE (default: false)
+//	DIFF_BASE=<ref>                    branch, tag or commit compared with (default: main)
+//	LINT_CHANGED_MAX=30                more changed Python files check the full repository
+//	RUN_DEPENDENCY_AUDIT=true|false    pip-audit over the installed dependencies (default: false)
+//	RUN_VULN_SCAN=true|false           fail on fixable HIGH/CRITICAL image vulnerabilities, trivy (default: false)
+//	NO_CACHE=true|false                re-run every step instead of reusing cached results (default: false)
+//	STAGE_MEMORY_LIMIT=<size>          address space per test/lint process, e.g. 3GB (optional); fails with a
+//	                                   MemoryError instead of the OOM killer — Dagger has no per-stage limits
+//	TEST_AS_USER=<uid>[:<gid>]         run the container test and lint commands as this user, e.g. 1000:1000 (default: root)
+//	TEST_UMASK=<octal>                 umask of those commands, e.g. 027 (optional)
+//	STAGE_ENV_<STAGE>=<K=V,…>          extra environment of one stage, e.g. STAGE_ENV_UNIT_TESTS="TZ=UTC,FEATURE_X=1";
+//	                                   K=@secret:CR_PAT passes that variable's value as a Dagger secret (optional)
+//	SOURCE_DATE_EPOCH=<seconds>        fixed build time for the image tag, created label and build arg, e.g.
+//	                                   $(git log -1 --format=%ct), for reproducible builds (default: the clock)
+//	EXPORT_TEST_VENV=true|false        run host tests in a copy of the build container's environment (default: false)
+//	VENV_DIR=<path>                    where that copy is created, outside the checkout (default: <ARTIFACTS_DIR>/test-venv)
+//	LOCAL_FRAMEWORK_DIRS=<dir,...>     local packages installed before the project, in order (default: python_framework
+//	                                   when present); +<dir,...> adds to the default, none installs none
+//	DATABASE_URL_OVERRIDE=<url>        shared PostgreSQL for integration/acceptance tests instead of testcontainers
+//	HOST_TEST_WRAPPER="<cmd> <args>"   run the host pytest through this command, e.g. "corp-netns-exec --profile build --"
+//	DB_SEED_COMMAND="<cmd> <args>"     load fixtures into the test database before the integration/acceptance tests,
+//	                                   e.g. "python -m cert_parser.tools.seed --fixtures tests/fixtures/seed" (optional)
+//	BASE_IMAGE=<image>                 Python image the build container starts from (default: python:3.14-slim)
+//	APT_PACKAGES="a b,c"               apt packages of the build container, replacing git build-essential libpq-dev;
+//	                                   ca-certificates is still added for the mounted CA certificates
+//	EXTRA_APT_PACKAGES="a b,c"         extra apt packages for the build container
+//	SKIP_DEFAULT_APT=true|false        leave out git build-essential libpq-dev (default: false)
+//	BASE_IMAGE_MIRROR=<prefix>         pull the base image through a Docker Hub mirror, e.g. registry.corp.local/dockerhub
+//	REGISTRY_MIRRORS=a,b               images or registry hosts on mirrors the engine pulls from, fetched before the clone
+//	PIP_INDEX_URL=<url>                package index pip installs from, e.g. https://pypi.corp.local/simple
+//	HASH_CHECKED_INSTALL=true|false    install the dependencies from HASH_LOCK_FILE with --require-hashes (default: false)
+//	HASH_LOCK_FILE=requirements.lock   the lockfile with hashes, relative to the project
+//	OFFLINE_MODE=true|false            air-gapped run: requires BASE_IMAGE_MIRROR and PIP_INDEX_URL, rejects internet hosts (default: false)
+//	OFFLINE_ALLOW_HOSTS=a,b            internal domains an offline run may contact, e.g. corp.local (optional)
+//	DOCKERHUB_PULL_USERNAME=<user>     authenticate Docker Hub pulls against the anonymous rate limit (set both)
+//	DOCKERHUB_PULL_TOKEN=<token>       Docker Hub access token for DOCKERHUB_PULL_USERNAME
+//	ALLOW_ROOT_IMAGE=true|false        pass the hardening check with a root image (default: false)
+//	RUN_DOCKLE=true|false              dockle CIS checks, failing at DOCKLE_FAIL_LEVEL (default: false, FATAL)
+//	ENTRYPOINT_CHECK=warn|fail|off     image entrypoint vs [project.scripts] (default: warn)
+//	IMAGE_WORKDIR=<path>               WORKDIR the image must have (optional)
+//	RUN_PACKAGE_PUBLISH=true|false     sdist + wheel via twine (default: false; upload on main only)
+//	EXPORT_WHEELS=true|false           export the wheel to ARTIFACTS_DIR/dist (default: true on main)
+//
+// Package index: TWINE_REPOSITORY_URL (default TestPyPI, PyPI with
+// PACKAGE_PUBLISH_PROD=true), TWINE_USERNAME, TWINE_PASSWORD.
+// ARTIFACTS_DIR (default pipeline-artifacts) receives dist/ and pipeline-report.json.
+//
+// DAGGER_RUNNER_HOST (docker-container://, tcp://, unix://) selects an
+// existing engine, e.g. a shared build server. STRICT_ENGINE_VERSION=true
+// fails the run when the engine is not on the SDK's minor line.
+// TIMING_DETAIL=true times the key Dagger operations (connect, git tree,
+// first exec, docker build, publish) with a sync point at each, and
+// records them under dagger_timings in the report.
+//
+// Tool images (curl for DEBUG_CERTS diagnostics, scanners) come from the pinned
+// pipeline.ToolImages table; TOOL_IMAGE_<NAME> points one at an internal
+// mirror. --update-tool-pins prints the table with current digests.
+//
+// `warmup` (no USERNAME/REPO_NAME needed) pre-pulls the base and tool images
+// and fills the apt cache, plus the pip cache from WARMUP_REQUIREMENTS, through
+// the corporate proxy and CAs — run it once when provisioning a runner.
+//
+// `cleanup` lists the pipeline's cache volumes with size and last use and,
+// with CLEANUP_APPLY=true, empties those unused for CACHE_MAX_AGE days
+// (default 30); CLEANUP_ENGINE_PRUNE=true also prunes dangling build cache.
+//
+// `--watch` (or MODE=watch; no USERNAME/REPO_NAME needed) runs the unit
+// tests and ruff on LOCAL_SOURCE through the corporate proxy and CAs, and
+// re-runs them for the changed files whenever src/ or tests/ change.
+//
+// `config-schema` prints every option this binary reads as JSON, from the
+// pipeline.Options registry; a variable one or two edits from an option
+// warns with the option it probably meant. Where each option came from
+// (default, profile, env, flag) is recorded under option_sources in
+// pipeline-report.json.
+//
+// `engine-config` prints an engine.toml sending Docker Hub pulls through
+// BASE_IMAGE_MIRROR and trusting the discovered CA certificates for the
+// mirrors, and the docker run command starting a Dagger engine with it and
+// the certificates installed. The default engine only trusts public CAs, so
+// a mirror with the corporate CA fails the run until DAGGER_RUNNER_HOST
+// points at such an engine.
+//
+// `compare [--json] old.json new.json` prints what differs between two
+// pipeline-report.json files: configuration, environment fingerprint,
+// dependency versions and stage outcomes — attach it to support tickets.
+//
+// `--paranoid` (or PARANOID=true) snapshots the checkout's git status before
+// the run and fails if anything outside ARTIFACTS_DIR changed.
+//
+// From a terminal, risky operations (e.g. :latest from a non-main branch)
+// ask for confirmation first; NON_INTERACTIVE=true or CI=true skips prompts.
+//
+// Exit codes match main.go (see pipeline.ExitCode): 2 configuration,
+// 3 clone/auth, 4 tests, 5 lint/type, 6 build, 7 publish, 8 engine,
+// 9 deployment verification, 10 duration budget, 11 Docker unavailable
+// for required tests, 130 cancelled.
 func main() {
-	ctx := context.Background()
-
-	// Require CR_PAT and USERNAME
-	for _, v := range []string{"CR_PAT", "USERNAME"} {
-		if _, ok := os.LookupEnv(v); !ok {
-			fmt.Fprintf(os.Stderr, "ERROR: %s environment variable must be set\n", v)
-			os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	updateToolPins := flag.Bool("update-tool-pins", false, "print the tool image table with current digests and exit")
+	watchFlag := flag.Bool("watch", false, "re-run unit tests and ruff on LOCAL_SOURCE whenever it changes")
+	compareJSON := flag.Bool("json", false, "compare: print the differences as JSON")
+	paranoid := flag.Bool("paranoid", false, "fail if the run changed the git working tree outside ARTIFACTS_DIR")
+	optionFlags := pipeline.RegisterOptionFlags(flag.CommandLine, pipeline.BinaryCorporate)
+	flag.Parse()
+	if flag.Arg(0) == "config-schema" {
+		schema, err := pipeline.ConfigSchema(pipeline.BinaryCorporate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
+		}
+		os.Stdout.Write(schema)
+		return
+	}
+	if flag.Arg(0) == "compare" {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "ERROR: usage: compare [--json] <older pipeline-report.json> <newer pipeline-report.json>")
+			os.Exit(pipeline.ExitConfig)
+		}
+		if _, err := pipeline.CompareReportFiles(os.Stdout, flag.Arg(1), flag.Arg(2), *compareJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.CategoryOf(err).ExitCode())
+		}
+		return
+	}
+	if err := optionFlags.Apply(os.LookupEnv, os.Setenv); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	configFile := applyConfigFile()
+	warmup := flag.Arg(0) == "warmup"
+	cleanup := flag.Arg(0) == "cleanup"
+	engineConfig := flag.Arg(0) == "engine-config"
+	watch := *watchFlag || os.Getenv("MODE") == "watch"
+	outputMode, err := pipeline.ParseOutputMode(os.Getenv("OUTPUT"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: OUTPUT: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	// OUTPUT=compact: one line per stage; the rest of stdout is kept as
+	// detail for the excerpt of a failure
+	var compact *pipeline.CompactOutput
+	restoreStdout := func() {}
+	if outputMode == pipeline.OutputCompact && !warmup && !cleanup && !watch && !*updateToolPins && !engineConfig {
+		compact = pipeline.NewCompactOutput(os.Stdout, pipeline.IsTerminal(os.Stdout))
+		if restoreStdout, err = compact.CaptureStdout(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: OUTPUT=compact: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
 		}
 	}
 
-	if repoName := os.Getenv("REPO_NAME"); repoName == "" {
-		fmt.Fprintf(os.Stderr, "ERROR: REPO_NAME environment variable must be set (e.g. 'cert-parser')\n")
-		os.Exit(1)
+	pipeline.PrintOptions(os.Stdout, os.LookupEnv, os.Environ(), configFile, optionFlags)
+	if configFile != nil {
+		pipeline.PrintConfigFile(os.Stdout, configFile)
+	}
+	pipeline.PrintOptionFlags(os.Stdout, optionFlags)
+	for _, s := range pipeline.SuggestOptions(os.Environ()) {
+		warnings.Fprintf(os.Stdout, "unknown-option", "⚠️  Unknown option %s — did you mean %s?\n", s.Env, s.Suggestion)
 	}
+	profile := applyProfile()
+	optionSources := pipeline.ResolveOptionSources(pipeline.BinaryCorporate, os.LookupEnv, os.Environ(), profile, configFile, setFlags(optionFlags))
 
-	debugMode := os.Getenv("DEBUG_CERTS") == "true"
-	proxyURL := os.Getenv("HTTP_PROXY")
-	if proxyURL == "" {
-		proxyURL = os.Getenv("HTTPS_PROXY")
+	env, err := pipeline.ConfigFromEnv(pipeline.BinaryCorporate, os.LookupEnv, os.Environ(), os.Stdout, warnings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.CategoryOf(err).ExitCode())
 	}
+	cfg := env.Config
+	cfg.Profile = profile
+	cfg.OptionSources = optionSources
+	cfg.Paranoid = cfg.Paranoid || *paranoid
 
-	username := os.Getenv("USERNAME")
-	repoName := os.Getenv("REPO_NAME")
-	gitBranch := envOrDefaultCorp("GIT_BRANCH", "main")
-	imageName := os.Getenv("IMAGE_NAME") // empty is fine — auto-discovered later
-	gitHost := envOrDefaultCorp("GIT_HOST", "github.com")
-	registry := envOrDefaultCorp("REGISTRY", "ghcr.io")
-	gitAuthUser := envOrDefaultCorp("GIT_AUTH_USERNAME", "x-access-token")
+	// Require USERNAME and REPO_NAME, and CR_PAT only when publishing without a workflow token
+	if !warmup && !cleanup && !watch && !engineConfig {
+		if err := env.CheckRequired(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
+		}
+	}
 
-	runUnitTests := parseEnvBool("RUN_UNIT_TESTS", true)
-	runIntegrationTests := parseEnvBool("RUN_INTEGRATION_TESTS", true)
-	runAcceptanceTests := parseEnvBool("RUN_ACCEPTANCE_TESTS", true)
-	runLint := parseEnvBool("RUN_LINT", true)
-	runTypeCheck := parseEnvBool("RUN_TYPE_CHECK", true)
+	debugMode := os.Getenv("DEBUG_CERTS") == "true"
+	proxyAutodetect := strings.ToLower(envOrDefaultCorp("PROXY_AUTODETECT", pipeline.ProxyAutodetectAsk))
+	if !pipeline.ValidProxyAutodetect(proxyAutodetect) {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid PROXY_AUTODETECT %q (use ask, adopt or off)\n", proxyAutodetect)
+		os.Exit(pipeline.ExitConfig)
+	}
 
-	if !runUnitTests && !runIntegrationTests && !runAcceptanceTests {
-		fmt.Println("⚠️  All test stages disabled — skipping tests, proceeding to lint/build/push")
+	// Prompt before risky operations only when a person is at the terminal
+	if pipeline.IsInteractive(os.Stdin, os.Getenv) {
+		cfg.Confirm = (&pipeline.Prompter{In: os.Stdin, Out: os.Stdout}).Confirm
+	}
+	if cfg.ProxyURL == "" && proxyAutodetect != pipeline.ProxyAutodetectOff {
+		cfg.ProxyURL = systemProxyCorp(pipeline.DetectSystemProxy(runtime.GOOS, pipeline.RunCommand), proxyAutodetect, cfg.Confirm)
 	}
 
 	fmt.Println("🏢 CORPORATE MODE: MITM Proxy & Custom CA Support")
 	if debugMode {
 		fmt.Println("   🔍 Debug mode: ENABLED — certificate discovery diagnostics active")
 	}
-	if proxyURL != "" {
-		fmt.Printf("   🌐 Proxy: %s\n", proxyURL)
+	if cfg.ProxyURL != "" {
+		fmt.Printf("   🌐 Proxy: %s\n", cfg.ProxyURL)
 	}
 	fmt.Printf("🚀 Starting Python CI/CD Pipeline (Go SDK v0.19.7 - Corporate Mode)...\n")
-	fmt.Printf("   Git Host    : %s\n", gitHost)
-	fmt.Printf("   Registry    : %s\n", registry)
-	fmt.Printf("   User        : %s\n", username)
-	fmt.Printf("   Repository  : %s (branch: %s)\n", repoName, gitBranch)
-	fmt.Println("🧪 Test Configuration:")
-	fmt.Printf("   Unit tests:        %v (RUN_UNIT_TESTS)\n", runUnitTests)
-	fmt.Printf("   Integration tests: %v (RUN_INTEGRATION_TESTS)\n", runIntegrationTests)
-	fmt.Printf("   Acceptance tests:  %v (RUN_ACCEPTANCE_TESTS)\n", runAcceptanceTests)
-	fmt.Printf("   Lint (ruff):       %v (RUN_LINT)\n", runLint)
-	fmt.Printf("   Type check (mypy): %v (RUN_TYPE_CHECK)\n", runTypeCheck)
+	fmt.Printf("   Git Host    : %s\n", cfg.GitHost)
+	fmt.Printf("   Registry    : %s\n", cfg.Registry)
+	fmt.Printf("   User        : %s\n", cfg.GitUser)
+	if cfg.LocalSource != "" {
+		fmt.Printf("   Source      : %s (LOCAL_SOURCE, not cloned)\n", cfg.LocalSource)
+	} else if cfg.GitTag != "" {
+		fmt.Printf("   Repository  : %s (tag: %s)\n", cfg.RepoName, cfg.GitTag)
+	} else {
+		fmt.Printf("   Repository  : %s (branch: %s)\n", cfg.RepoName, cfg.GitBranch)
+	}
+	fmt.Printf("   Credentials : %s — %s\n", env.Credentials.Source, env.Credentials.Reason)
+	pipeline.PrintTestConfiguration(os.Stdout, cfg)
 
-	// Initialize Dagger client
-	client, err := dagger.Connect(ctx, dagger.WithLogOutput(os.Stderr))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Failed to create Dagger client: %v\n", err)
-		os.Exit(1)
+	certMaxFileSize := int64(certdiscovery.DefaultMaxFileSize)
+	if v := os.Getenv("CERT_MAX_FILE_SIZE"); v != "" {
+		size, err := pipeline.ParseByteSize(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: CERT_MAX_FILE_SIZE: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
+		}
+		certMaxFileSize = size
 	}
-	defer client.Close()
 
 	// Collect CA certificates from credentials/certs/ and system stores
-	caCertPaths := collectCACertificates()
-	if len(caCertPaths) > 0 {
-		fmt.Printf("   📜 Found %d CA certificate path(s)\n", len(caCertPaths))
-		validCerts := 0
-		for _, cert := range caCertPaths {
-			fmt.Printf("      - %s", filepath.Base(cert))
-			if err := validateCertificatePath(cert); err != nil {
-				fmt.Printf(" ❌ INVALID: %v\n", err)
-				continue
-			}
-			fmt.Println(" ✅")
-			validCerts++
+	discovered := collectCACertificates(certMaxFileSize)
+	cfg.CACertPaths = discovered.Paths()
+	if len(discovered.Entries) > 0 {
+		fmt.Printf("   📜 Found %d CA certificate path(s)\n", len(discovered.Entries))
+		for _, entry := range discovered.Entries {
+			printCertEntry(entry, debugMode)
 		}
-		if validCerts == 0 {
-			fmt.Println("\n   ⚠️  WARNING: No valid certificates found after validation")
+		if len(cfg.CACertPaths) == 0 {
+			warnings.Fprintf(os.Stdout, "no-valid-certs", "\n   ⚠️  WARNING: No valid certificates found after validation\n")
 		}
 	} else {
 		fmt.Println("   ℹ️  No CA certificates discovered automatically")
@@ -178,499 +466,335 @@ func main() {
 		fmt.Println("      Or set CA_CERTIFICATES_PATH environment variable")
 	}
 
-	pipeline := &CorporatePipeline{
-		RepoName:            repoName,
-		ImageName:           imageName,
-		GitBranch:           gitBranch,
-		GitUser:             username,
-		GitHost:             gitHost,
-		Registry:            registry,
-		GitAuthUser:         gitAuthUser,
-		RunUnitTests:        runUnitTests,
-		RunIntegrationTests: runIntegrationTests,
-		RunAcceptanceTests:  runAcceptanceTests,
-		RunLint:             runLint,
-		RunTypeCheck:        runTypeCheck,
-		CACertPaths:         caCertPaths,
-		ProxyURL:            proxyURL,
-		DebugMode:           debugMode,
+	// One HTTP client for every host-side call, trusting the corporate CAs and proxy
+	cfg.HTTPClient, err = pipeline.NewHTTPClient(pipeline.HTTPClientConfig{
+		ProxyURL:    cfg.ProxyURL,
+		CACertPaths: cfg.CACertPaths,
+		Debug:       debugMode,
+		Log:         os.Stdout,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
 	}
 
-	if debugMode {
-		if err := pipeline.runDiagnostics(ctx, client); err != nil {
-			fmt.Printf("⚠️  Diagnostic mode had warnings (continuing anyway): %v\n", err)
+	if *updateToolPins {
+		if err := pipeline.UpdateToolPins(ctx, os.Stdout, cfg.HTTPClient); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
 		}
+		return
 	}
 
-	if err := pipeline.runCorporate(ctx, client); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed: %v\n", err)
-		os.Exit(1)
+	if engineConfig {
+		pipeline.WriteEngineConfig(os.Stdout, pipeline.EngineSetup{
+			BaseImageMirror: cfg.BaseImageMirror,
+			RegistryMirrors: cfg.RegistryMirrors,
+			CACertPaths:     cfg.CACertPaths,
+		})
+		return
 	}
 
-	fmt.Println("\n🎉 Corporate pipeline completed successfully!")
-}
-
-// collectCACertificates auto-discovers certificates from multiple sources
-func collectCACertificates() []string {
-	var certPaths []string
-	discoveredCerts := make(map[string]bool) // Track unique certificates
-
-	// Certificate discovery statistics
-	stats := struct {
-		attempts  int
-		successes int
-		notFound  int
-		errors    int
-	}{}
-
-	debugMode := os.Getenv("DEBUG_CERTS") == "true"
-
-	if debugMode {
-		fmt.Println("\n📜 Certificate Discovery - Detailed Log")
-		fmt.Println(corporateSeparatorLine)
+	// Initialize Dagger client — local engine, or DAGGER_RUNNER_HOST when set
+	if cfg.RunnerHost.IsSet() {
+		fmt.Printf("🔌 Dagger engine: %s\n", cfg.RunnerHost.Raw)
+		if cfg.RunnerHost.Remote() {
+			fmt.Println("   ℹ️  Remote engine — host-run tests and Docker socket checks still run on this machine;")
+			fmt.Println("      local directories and files are uploaded to the engine, so large trees take longer")
+		}
 	}
-
-	// 1. First: Try to collect from credentials/certs/ (user-provided)
-	certsDir := "credentials/certs"
-	if debugMode {
-		fmt.Println("\n🔍 Source: User-provided certificates (credentials/certs/)")
+	connectStart := time.Now()
+	var daggerLog io.Writer = os.Stderr
+	if compact != nil {
+		daggerLog = compact
 	}
-	stats.attempts++
-	if _, err := os.Stat(certsDir); err == nil {
-		files, err := os.ReadDir(certsDir)
-		if err == nil {
-			foundInDir := 0
-			for _, file := range files {
-				if !file.IsDir() && strings.HasSuffix(file.Name(), ".pem") {
-					fullPath := filepath.Join(certsDir, file.Name())
-					if _, exists := discoveredCerts[fullPath]; !exists {
-						certPaths = append(certPaths, fullPath)
-						discoveredCerts[fullPath] = true
-						stats.successes++
-						foundInDir++
-						if debugMode {
-							fmt.Printf("   ✅ Found: %s\n", fullPath)
-						}
-					}
-				}
-			}
-			if debugMode && foundInDir == 0 {
-				fmt.Println("   ⚠️  Directory exists but no .pem files found")
-				stats.notFound++
-			}
-		} else {
-			if debugMode {
-				fmt.Printf("   ❌ Error reading directory: %v\n", err)
-			}
-			stats.errors++
-		}
-	} else {
-		if debugMode {
-			fmt.Println("   ℹ️  Directory not found (this is optional)")
-		}
-		stats.notFound++
+	client, err := pipeline.Connect(ctx, cfg.RunnerHost, daggerLog)
+	if err != nil {
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to create Dagger client (%s): %v\n", category, err)
+		os.Exit(category.ExitCode())
 	}
+	defer client.Close()
+	cfg.ConnectTime = time.Since(connectStart)
 
-	// 2. Auto-discover from system certificate stores
-	username := os.Getenv("USERNAME")
-	if debugMode {
-		fmt.Println("\n🔍 Source: System certificate stores (50+ locations)")
-	}
-	systemCertPaths := []string{
-		// Linux/Debian
-		"/etc/ssl/certs/ca-bundle.crt",
-		"/etc/ssl/certs/ca-certificates.crt",
-		// Linux/RHEL
-		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
-		// macOS
-		"/etc/ssl/cert.pem",
-		"/usr/local/etc/openssl/cert.pem",
-		// macOS Docker Desktop / Rancher Desktop
-		filepath.Join(os.Getenv("HOME"), ".docker/certs.d/docker.io/ca.pem"),
-		filepath.Join(os.Getenv("HOME"), ".docker/certs.d/ghcr.io/ca.pem"),
-		filepath.Join(os.Getenv("HOME"), ".docker/certs.d"),
-		filepath.Join(os.Getenv("HOME"), ".rancher/certs.d"),
-		// macOS Docker Desktop Group Containers (sandboxed storage)
-		filepath.Join(os.Getenv("HOME"), "Library/Group Containers/group.com.docker/certs"),
-		filepath.Join(os.Getenv("HOME"), "Library/Group Containers/group.com.docker/settings/ca-certificates"),
-		// Windows via WSL
-		"/mnt/c/ProgramData/Microsoft/Windows/Certificates/ca-certificates.pem",
-		// Windows native paths
-		`C:\ProgramData\Microsoft\Windows\Certificates\ca-certificates.pem`,
-		`C:\Users\` + username + `\AppData\Local\Corporate_Certificates\ca-bundle.pem`,
-		// Docker Desktop on Windows
-		`C:\Users\` + username + `\.docker\certs.d\docker.io\ca.pem`,
-		`C:\Users\` + username + `\.docker\certs.d\ghcr.io\ca.pem`,
-		`C:\Users\` + username + `\.docker\certs.d`,
-		// Rancher Desktop on Windows
-		`C:\Users\` + username + `\.rancher\certs.d`,
-		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
-		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\config\certs`,
-		// Linux Docker / Rancher Desktop socket
-		"/etc/docker/certs.d",
-		"/var/lib/docker/certs.d",
-		"/etc/rancher/k3s/certs.d",
+	daggerVersions, err := pipeline.VerifyEngineVersion(ctx, client, os.Stdout, parseEnvBool("STRICT_ENGINE_VERSION", false))
+	if err != nil {
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(category.ExitCode())
 	}
 
-	systemFound := 0
-	for _, systemPath := range systemCertPaths {
-		stats.attempts++
-		if _, err := os.Stat(systemPath); err == nil {
-			if _, exists := discoveredCerts[systemPath]; !exists {
-				certPaths = append(certPaths, systemPath)
-				discoveredCerts[systemPath] = true
-				stats.successes++
-				systemFound++
-				if debugMode {
-					fmt.Printf("   ✅ Found: %s\n", systemPath)
-				}
-			}
-		} else {
-			stats.notFound++
+	if cleanup {
+		maxAge, err := pipeline.ParseCacheMaxAge(os.Getenv("CACHE_MAX_AGE"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
+		}
+		if _, err := pipeline.Cleanup(ctx, client, pipeline.CleanupConfig{
+			MaxAge:       maxAge,
+			Apply:        parseEnvBool("CLEANUP_APPLY", false),
+			EnginePrune:  parseEnvBool("CLEANUP_ENGINE_PRUNE", false),
+			DiskPaths:    pipeline.DiskPaths(cfg.EngineStateDir, cfg.ArtifactsDir),
+			MinFreeSpace: cfg.MinFreeSpace,
+		}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Cleanup failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-	}
-	if debugMode && systemFound == 0 {
-		fmt.Println("   ⚠️  No system certificates found (checked all standard locations)")
+		return
 	}
 
-	// 2b. Recursively scan Docker and Rancher Desktop certificate directories (registry-specific)
-	if debugMode {
-		fmt.Println("\n🔍 Source: Docker/Rancher Desktop directories (recursive scan)")
-	}
-	rancherCertDirs := []string{
-		// Docker Desktop
-		filepath.Join(os.Getenv("HOME"), ".docker/certs.d"),
-		"/etc/docker/certs.d",
-		"/var/lib/docker/certs.d",
-		`C:\Users\` + username + `\.docker\certs.d`,
-		// Rancher Desktop
-		filepath.Join(os.Getenv("HOME"), ".rancher/certs.d"),
-		`C:\Users\` + username + `\.rancher\certs.d`,
-		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
-		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\config\certs`,
-		"/etc/rancher/k3s/certs.d",
-	}
-	dockerFound := 0
-	for _, certDir := range rancherCertDirs {
-		stats.attempts++
-		beforeCount := len(certPaths)
-		scanDockerCerts(certDir, discoveredCerts, &certPaths, &stats, debugMode)
-		afterCount := len(certPaths)
-		if afterCount > beforeCount {
-			stats.successes++
-			dockerFound += (afterCount - beforeCount)
-		} else if !fileExists(certDir) {
-			stats.notFound++
+	if warmup {
+		if _, err := pipeline.Warmup(ctx, client, pipeline.WarmupConfig{
+			Requirements:     os.Getenv("WARMUP_REQUIREMENTS"),
+			CACertPaths:      cfg.CACertPaths,
+			ProxyURL:         cfg.ProxyURL,
+			ToolImages:       cfg.ToolImages,
+			BaseImage:        cfg.BaseImage,
+			AptPackages:      cfg.AptPackages,
+			ExtraAptPackages: cfg.ExtraAptPackages,
+			SkipDefaultApt:   cfg.SkipDefaultApt,
+			BaseImageMirror:  cfg.BaseImageMirror,
+			DockerHubUser:    cfg.DockerHubUser,
+			DockerHubToken:   cfg.DockerHubToken,
+		}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Warmup failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-	}
-	if debugMode && dockerFound == 0 {
-		fmt.Println("   ℹ️  No Docker/Rancher certificates found (directories may not exist or be empty)")
+		return
 	}
 
-	// 2c. Extract host system certificates that Docker uses
-	// Docker inherits these from the host and makes them available to containers
-	if debugMode {
-		fmt.Println("\n🔍 Source: Docker host system certificates")
-	}
-	stats.attempts++
-	hostCerts := extractDockerHostCertificates(debugMode, &stats)
-	hostFound := 0
-	for _, hostCert := range hostCerts {
-		if !discoveredCerts[hostCert] {
-			certPaths = append(certPaths, hostCert)
-			discoveredCerts[hostCert] = true
-			stats.successes++
-			hostFound++
-			if debugMode {
-				fmt.Printf("   ✅ Found: %s\n", hostCert)
-			}
+	// Docker on the host is only needed for the testcontainers stages
+	if (cfg.RunIntegrationTests || cfg.RunAcceptanceTests) && !watch {
+		fmt.Println("🔍 Checking Docker availability for testcontainers...")
+		if sock := getDockerSocketPathCorp(); sock != "" {
+			cfg.HasDocker = true
+			cfg.DockerDetection = "socket " + sock
+			fmt.Printf("   ✅ Docker socket detected: %s\n", sock)
+		} else {
+			cfg.DockerDetection = fmt.Sprintf("no Docker socket found on %s, DOCKER_HOST=%q", runtime.GOOS, os.Getenv("DOCKER_HOST"))
+			warnings.Fprintf(os.Stdout, "docker-missing", "   ⚠️  Docker socket NOT available (OS: %s)\n", runtime.GOOS)
 		}
 	}
-	if debugMode && hostFound == 0 {
-		fmt.Println("   ℹ️  No host certificates found (platform may not use standard locations)")
-		stats.notFound++
-	}
 
-	// 3. Try to capture from current environment (environment variable)
-	if debugMode {
-		fmt.Println("\n🔍 Source: CA_CERTIFICATES_PATH environment variable")
+	if compact != nil {
+		cfg.Output = compact
 	}
-	stats.attempts++
-	if envCerts := os.Getenv("CA_CERTIFICATES_PATH"); envCerts != "" {
-		if debugMode {
-			fmt.Printf("   🔍 Checking paths: %s\n", envCerts)
-		}
-		paths := strings.Split(envCerts, ":")
-		envFound := 0
-		for _, path := range paths {
-			path = strings.TrimSpace(path)
-			if path != "" && !discoveredCerts[path] {
-				if _, err := os.Stat(path); err == nil {
-					certPaths = append(certPaths, path)
-					discoveredCerts[path] = true
-					stats.successes++
-					envFound++
-					if debugMode {
-						fmt.Printf("   ✅ Found: %s\n", path)
-					}
-				} else {
-					if debugMode {
-						fmt.Printf("   ❌ Not found: %s\n", path)
-					}
-					stats.notFound++
-				}
-			}
-		}
-		if debugMode && envFound == 0 {
-			fmt.Println("   ⚠️  Environment variable set but no valid certificates found")
-		}
-	} else {
-		if debugMode {
-			fmt.Println("   ℹ️  Environment variable not set")
+
+	// Watch: the unit tests and ruff of the same configuration, re-run on every change
+	if watch {
+		if err := pipeline.Watch(ctx, client, cfg, pipeline.WatchOptions{}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Watch failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-		stats.notFound++
+		return
 	}
 
-	// 4. Detect Jenkins CI/CD environment certificates
-	if debugMode {
-		fmt.Println("\n🔍 Source: Jenkins CI/CD environment")
-	}
-	stats.attempts++
-	if jenkinsHome := os.Getenv("JENKINS_HOME"); jenkinsHome != "" {
-		if debugMode {
-			fmt.Printf("   🏢 Jenkins detected: %s\n", jenkinsHome)
-		}
-		jenkinsCertPaths := []string{
-			filepath.Join(jenkinsHome, "war/WEB-INF/ca-bundle.crt"),
-			filepath.Join(jenkinsHome, "certs"),
-			filepath.Join(jenkinsHome, "ca-certificates"),
+	// Monorepo: one run per discovered project, plus a combined summary
+	if env.Projects.Glob != "" {
+		ci := pipeline.DetectCI(os.Getenv)
+		if ci != nil {
+			fmt.Printf("🧩 CI system detected: %s — stage output grouped into collapsible sections\n", ci.Name)
 		}
-		jenkinsFound := 0
-		for _, path := range jenkinsCertPaths {
-			if _, err := os.Stat(path); err == nil {
-				if !discoveredCerts[path] {
-					certPaths = append(certPaths, path)
-					discoveredCerts[path] = true
-					stats.successes++
-					jenkinsFound++
-					if debugMode {
-						fmt.Printf("   ✅ Found: %s\n", path)
-					}
-				}
-			} else {
-				stats.notFound++
+		projects := env.Projects
+		projects.Dagger = &daggerVersions
+		projects.Attach = func(p *pipeline.Pipeline) {
+			if ci != nil {
+				ci.Attach(p.Tracker(), os.Stdout)
+			}
+			if compact != nil {
+				compact.Attach(p.Tracker(), pipeline.PlannedStages(cfg))
 			}
 		}
-		if debugMode && jenkinsFound == 0 {
-			fmt.Println("   ⚠️  Jenkins detected but no certificates found in standard locations")
+		_, err := pipeline.RunProjects(ctx, client, cfg, projects)
+		if compact != nil {
+			compact.Close(err)
+			restoreStdout()
 		}
-	} else {
-		if debugMode {
-			fmt.Println("   ℹ️  Not running in Jenkins (JENKINS_HOME not set)")
+		if err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-		stats.notFound++
+		fmt.Println("\n🎉 Corporate pipeline completed successfully for all projects!")
+		return
 	}
 
-	// 5. Detect GitHub Actions runner environment
-	if debugMode {
-		fmt.Println("\n🔍 Source: GitHub Actions runner environment")
+	cp, err := pipeline.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.CategoryOf(err).ExitCode())
 	}
-	stats.attempts++
-	if runnerTemp := os.Getenv("RUNNER_TEMP"); runnerTemp != "" {
-		if debugMode {
-			fmt.Printf("   🐙 GitHub Actions detected: %s\n", runnerTemp)
-		}
-		customCertsPath := filepath.Join(runnerTemp, "ca-certificates")
-		if _, err := os.Stat(customCertsPath); err == nil {
-			if !discoveredCerts[customCertsPath] {
-				certPaths = append(certPaths, customCertsPath)
-				discoveredCerts[customCertsPath] = true
-				stats.successes++
-				if debugMode {
-					fmt.Printf("   ✅ Found: %s\n", customCertsPath)
-				}
-			}
-		} else {
-			if debugMode {
-				fmt.Println("   ⚠️  GitHub Actions detected but no custom certificates found")
-			}
-			stats.notFound++
-		}
-	} else {
-		if debugMode {
-			fmt.Println("   ℹ️  Not running in GitHub Actions (RUNNER_TEMP not set)")
-		}
-		stats.notFound++
+
+	if ci := pipeline.DetectCI(os.Getenv); ci != nil {
+		fmt.Printf("🧩 CI system detected: %s — stage output grouped into collapsible sections\n", ci.Name)
+		ci.Attach(cp.Tracker(), os.Stdout)
 	}
 
-	// Summary statistics
 	if debugMode {
-		fmt.Println("\n📊 Certificate Discovery Summary")
-		fmt.Println(corporateSeparatorLine)
-		fmt.Printf("   🔍 Total sources checked: %d\n", stats.attempts)
-		fmt.Printf("   ✅ Certificates found: %d\n", stats.successes)
-		fmt.Printf("   ℹ️  Not found: %d\n", stats.notFound)
-		if stats.errors > 0 {
-			fmt.Printf("   ❌ Errors: %d\n", stats.errors)
+		if err := runDiagnostics(ctx, client, cp, cfg.Registry); err != nil {
+			warnings.Fprintf(os.Stdout, "diagnostics", "⚠️  Diagnostic mode had warnings (continuing anyway): %v\n", err)
 		}
-		fmt.Printf("   📜 Unique certificates collected: %d\n", len(certPaths))
-		fmt.Println(corporateSeparatorLine)
 	}
 
-	return certPaths
-}
-
-// fileExists checks if a file exists
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
+	if compact != nil {
+		compact.Attach(cp.Tracker(), pipeline.PlannedStages(cfg))
+	}
 
-// scanDockerCerts recursively scans Docker certificate directories for .pem and .crt files
-func scanDockerCerts(dockerDir string, discovered map[string]bool, paths *[]string, stats *struct {
-	attempts  int
-	successes int
-	notFound  int
-	errors    int
-}, debugMode bool) {
-	if !fileExists(dockerDir) {
-		if debugMode {
-			fmt.Printf("   ℹ️  Directory not found: %s\n", dockerDir)
-		}
-		return
+	report, err := cp.Run(ctx, client)
+	if compact != nil {
+		compact.Close(err)
+		restoreStdout()
 	}
-	if debugMode {
-		fmt.Printf("   🔍 Scanning: %s\n", dockerDir)
+	report.Dagger = &daggerVersions
+	if path, werr := pipeline.WriteReport(cfg.ArtifactsDir, report); werr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not write pipeline report: %v\n", werr)
+	} else {
+		fmt.Printf("📝 Pipeline report: %s\n", path)
 	}
-	filesFound := 0
-	filepath.Walk(dockerDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			if debugMode {
-				fmt.Printf("   ⚠️  Error walking path %s: %v\n", path, err)
-			}
-			stats.errors++
-			return nil
-		}
-		if info.IsDir() {
-			return nil
-		}
-		if strings.HasSuffix(info.Name(), ".pem") || strings.HasSuffix(info.Name(), ".crt") {
-			if !discovered[path] {
-				*paths = append(*paths, path)
-				discovered[path] = true
-				filesFound++
-				if debugMode {
-					fmt.Printf("      ✅ %s\n", path)
-				}
-			}
+	if cfg.HTMLReport {
+		if path, werr := pipeline.WriteHTMLReport(cfg.ArtifactsDir, report); werr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write HTML report: %v\n", werr)
+		} else {
+			fmt.Printf("📝 HTML report: %s\n", path)
 		}
-		return nil
-	})
-	if debugMode && filesFound > 0 {
-		fmt.Printf("   📊 Found %d certificate(s) in this directory\n", filesFound)
-	}
-}
-
-// extractDockerHostCertificates extracts certificates from the Docker/Rancher daemon's CA store
-// This captures the host system certificates that Docker/Rancher inherited and makes available
-func extractDockerHostCertificates(debugMode bool, stats *struct {
-	attempts  int
-	successes int
-	notFound  int
-	errors    int
-}) []string {
-	var hostCerts []string
-	username := os.Getenv("USERNAME")
-
-	// On Windows: Docker Desktop and Rancher Desktop use Windows Certificate Store
-	windowsCertPaths := []string{
-		`C:\ProgramData\Microsoft\Windows\Certificates\ca-certificates.pem`,
-		`C:\Program Files\Docker\Docker\resources\certs`,
-		`C:\Program Files\Rancher Desktop\resources\certs`,
-		`C:\Users\` + username + `\AppData\Local\Rancher Desktop\certs`,
 	}
-	for _, path := range windowsCertPaths {
-		if fileExists(path) {
-			hostCerts = append(hostCerts, path)
-		}
+	if err != nil {
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed (%s): %v\n", category, err)
+		os.Exit(category.ExitCode())
 	}
 
-	// On macOS: Docker Desktop and Rancher Desktop use system's /etc/ssl/cert.pem
-	macCertPaths := []string{
-		"/etc/ssl/cert.pem",
-		"/usr/local/etc/openssl/cert.pem",
+	budgetRef := cfg.GitBranch
+	if cfg.GitTag != "" {
+		budgetRef = cfg.GitTag
 	}
-	for _, path := range macCertPaths {
-		if fileExists(path) {
-			hostCerts = append(hostCerts, path)
-		}
+	if err := pipeline.CheckDurationBudget(os.Stdout, report.Status, budgetRef, env.DurationBudget, env.DurationHistoryFile); err != nil && env.DurationBudgetHard {
+		fmt.Fprintf(os.Stderr, "ERROR: %v (DURATION_BUDGET_HARD=true)\n", err)
+		os.Exit(pipeline.ExitBudget)
 	}
 
-	// On Linux: Docker daemon and Rancher Desktop use host's /etc/ssl/certs and system store
-	linuxCertPaths := []string{
-		"/etc/ssl/certs",
-		"/etc/ssl/certs/ca-bundle.crt",
-		"/etc/ssl/certs/ca-certificates.crt",
-		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
-		"/etc/rancher/k3s/certs", // Rancher k3s certs
-	}
-	for _, path := range linuxCertPaths {
-		if fileExists(path) {
-			hostCerts = append(hostCerts, path)
-		}
+	fmt.Println("\n🎉 Corporate pipeline completed successfully!")
+	if len(report.PublishedImages) == 0 {
+		fmt.Println("   ℹ️  No image was published (RUN_BUILD/RUN_PUBLISH disabled)")
 	}
-
-	return hostCerts
 }
 
-// validateCertificatePath checks if a certificate file is readable and valid
-func validateCertificatePath(certPath string) error {
-	info, err := os.Stat(certPath)
+// collectCACertificates discovers the CA certificates (certdiscovery) and
+// prints what was found: the detailed per-source log with DEBUG_CERTS=true,
+// otherwise only the CA_CERTIFICATES_PATH results. Warnings are recorded
+// when they are printed.
+func collectCACertificates(maxFileSize int64) certdiscovery.Result {
+	debugMode := os.Getenv("DEBUG_CERTS") == "true"
+	result, err := certdiscovery.Discover(certdiscovery.Options{
+		Home:        certdiscovery.HomeDir(),
+		MaxFileSize: maxFileSize,
+	})
 	if err != nil {
-		return fmt.Errorf("certificate not accessible: %w", err)
+		fmt.Fprintf(os.Stderr, "ERROR: certificate discovery: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
 	}
 
-	// If it's a directory, check if it contains any .pem or .crt files
-	if info.IsDir() {
-		hasValidCerts := false
-		filepath.Walk(certPath, func(path string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				if strings.HasSuffix(info.Name(), ".pem") || strings.HasSuffix(info.Name(), ".crt") {
-					hasValidCerts = true
-				}
+	if debugMode {
+		fmt.Println("\n📜 Certificate Discovery - Detailed Log")
+		fmt.Println(corporateSeparatorLine)
+	}
+	for _, source := range result.Sources {
+		if debugMode {
+			fmt.Printf("\n🔍 Source: %s\n", source.Title)
+		}
+		for _, note := range source.Notes {
+			if debugMode || note.Always {
+				printCertNote(note)
 			}
-			return nil
-		})
-		if !hasValidCerts {
-			return fmt.Errorf("directory contains no .pem or .crt files")
 		}
-		return nil
 	}
 
-	// For individual files, verify readability
-	data, err := os.ReadFile(certPath)
-	if err != nil {
-		return fmt.Errorf("cannot read certificate file: %w", err)
+	if debugMode {
+		stats := result.Stats
+		fmt.Println("\n📊 Certificate Discovery Summary")
+		fmt.Println(corporateSeparatorLine)
+		fmt.Printf("   🔍 Total sources checked: %d\n", stats.Attempts)
+		fmt.Printf("   ✅ Certificates found: %d\n", stats.Successes)
+		fmt.Printf("   ℹ️  Not found: %d\n", stats.NotFound)
+		if stats.Errors > 0 {
+			fmt.Printf("   ❌ Errors: %d\n", stats.Errors)
+		}
+		fmt.Printf("   📜 Unique certificates collected: %d\n", len(result.Entries))
+		fmt.Println(corporateSeparatorLine)
 	}
+	return result
+}
 
-	// Basic PEM format check (most common format)
-	if !strings.Contains(string(data), "-----BEGIN CERTIFICATE-----") {
-		// Could be DER format or bundle - still valid, just warn
-		fmt.Printf("   ⚠️  Warning: Certificate may not be in PEM format: %s\n", certPath)
+// printCertNote prints one line of the discovery log.
+func printCertNote(note certdiscovery.Note) {
+	switch note.Kind {
+	case certdiscovery.NoteFound:
+		fmt.Printf("   ✅ Found: %s\n", note.Message)
+	case certdiscovery.NoteDetail:
+		fmt.Printf("   🔍 %s\n", note.Message)
+	case certdiscovery.NoteWarning:
+		warnings.Fprintf(os.Stdout, note.ID, "   ⚠️  %s\n", note.Message)
+	case certdiscovery.NoteError:
+		fmt.Printf("   ❌ %s\n", note.Message)
+	default:
+		fmt.Printf("   ℹ️  %s\n", note.Message)
 	}
+}
 
-	return nil
+// printCertEntry prints a discovered path and whether it is used. With
+// DEBUG_CERTS=true the certificates it holds are listed as well.
+func printCertEntry(entry certdiscovery.Entry, debugMode bool) {
+	fmt.Printf("      - %s", filepath.Base(entry.Path))
+	if entry.Excluded != "" {
+		reason := entry.Excluded
+		if errors.Is(entry.Err, certdiscovery.ErrTooLarge) {
+			reason += " (CERT_MAX_FILE_SIZE)"
+		}
+		fmt.Printf(" ❌ INVALID: %s\n", reason)
+		warnings.Add("ca-cert-invalid", fmt.Sprintf("%s skipped: %s", entry.Path, reason))
+		return
+	}
+	fmt.Println(" ✅")
+	if !debugMode {
+		return
+	}
+	const shown = 5
+	now := time.Now()
+	for i, cert := range entry.Certificates {
+		if i == shown {
+			fmt.Printf("         … and %d more certificate(s)\n", len(entry.Certificates)-shown)
+			break
+		}
+		expired := ""
+		if cert.Expired(now) {
+			expired = " ⚠️  EXPIRED"
+		}
+		fmt.Printf("         %s (expires %s, sha256 %s…)%s\n", cert.Subject, cert.NotAfter.Format("2006-01-02"), cert.SHA256[:16], expired)
+	}
 }
 
 // runDiagnostics creates a diagnostic container to identify certificate issues
-func (cp *CorporatePipeline) runDiagnostics(ctx context.Context, client *dagger.Client) error {
+// with Docker Hub and the registry the image is published to
+func runDiagnostics(ctx context.Context, client *dagger.Client, cp *pipeline.Pipeline, registry string) error {
 	fmt.Println("\n🔍 DIAGNOSTIC MODE: Analyzing certificate chain...")
 	fmt.Println("   This will attempt to connect to critical endpoints and capture certificates")
 
-	const diagnosticImage = "curlimages/curl:latest"
-
-	diagnostic := client.Container().
-		From(diagnosticImage).
+	curl, err := cp.ToolContainer(ctx, client, "curl")
+	if err != nil {
+		return err
+	}
+	registryHost, _, _ := strings.Cut(strings.TrimRight(registry, "/"), "/")
+	registryName, registryPort, ok := strings.Cut(registryHost, ":")
+	if !ok {
+		registryPort = "443"
+	}
+	diagnostic := curl.
+		WithEnvVariable("REGISTRY_HOST", registryHost).
+		WithEnvVariable("REGISTRY_NAME", registryName).
+		WithEnvVariable("REGISTRY_PORT", registryPort).
 		WithExec([]string{"sh", "-c", `
 set -e
 
@@ -690,8 +814,8 @@ echo "=== Testing container registry connectivity ==="
 curl -v https://registry-1.docker.io/v2/ 2>&1 | head -30 || true
 echo ""
 
-echo "=== Testing GitHub Container Registry connectivity ==="
-curl -v https://ghcr.io/v2/ 2>&1 | head -30 || true
+echo "=== Testing publish registry connectivity ($REGISTRY_HOST) ==="
+curl -v "https://$REGISTRY_HOST/v2/" 2>&1 | head -30 || true
 echo ""
 
 echo "=== Certificate Verification (registry-1.docker.io) ==="
@@ -699,9 +823,9 @@ echo | openssl s_client -servername registry-1.docker.io \
   -connect registry-1.docker.io:443 2>&1 | grep -E "subject=|issuer=|Verify return code" || true
 echo ""
 
-echo "=== Certificate Verification (ghcr.io) ==="
-echo | openssl s_client -servername ghcr.io \
-  -connect ghcr.io:443 2>&1 | grep -E "subject=|issuer=|Verify return code" || true
+echo "=== Certificate Verification ($REGISTRY_HOST) ==="
+echo | openssl s_client -servername "$REGISTRY_NAME" \
+  -connect "$REGISTRY_NAME:$REGISTRY_PORT" 2>&1 | grep -E "subject=|issuer=|Verify return code" || true
 `})
 
 	output, err := diagnostic.Stdout(ctx)
@@ -716,438 +840,30 @@ echo | openssl s_client -servername ghcr.io \
 	return nil
 }
 
-// collectFromDirectory adds .pem files from a directory
-func collectFromDirectory(dir string, discovered map[string]bool, paths *[]string) {
-	if !fileExists(dir) {
-		return
-	}
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return
-	}
-	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".pem") {
-			fullPath := filepath.Join(dir, f.Name())
-			if !discovered[fullPath] {
-				*paths = append(*paths, fullPath)
-				discovered[fullPath] = true
-			}
-		}
-	}
-}
-
-// runCorporate executes the complete CI/CD pipeline with corporate CA support
-// runCorporate executes the full Python CI/CD pipeline with corporate CA support.
-// Clone → Discover → Build env (with CA certs + proxy) → Unit Tests → Integration Tests
-// → Acceptance Tests → Lint → Type-check → Docker Build → Publish.
-func (cp *CorporatePipeline) runCorporate(ctx context.Context, client *dagger.Client) error {
-	// ── Clone repository ────────────────────────────────────────
-	crPAT := client.SetSecret("github-pat", os.Getenv("CR_PAT"))
-	gitURL := fmt.Sprintf("https://%s/%s/%s.git", cp.GitHost, cp.GitUser, cp.RepoName)
-	cp.GitRepo = gitURL
-	fmt.Printf("\n📥 Cloning repository: %s (branch: %s)\n", gitURL, cp.GitBranch)
-
-	repo := client.Git(gitURL, dagger.GitOpts{
-		KeepGitDir:       true,
-		HTTPAuthToken:    crPAT,
-		HTTPAuthUsername: cp.GitAuthUser,
-	})
-	source := repo.Branch(cp.GitBranch).Tree()
-
-	commitSHA, err := repo.Branch(cp.GitBranch).Commit(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get commit SHA: %w", err)
-	}
-	fmt.Printf("   Commit: %s\n", commitSHA[:minCorp(12, len(commitSHA))])
-
-	// ── Discover project name from pyproject.toml ────────────────
-	fmt.Println("🔍 Discovering project name from pyproject.toml...")
-	pyprojectContent, err := source.File("pyproject.toml").Contents(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to read pyproject.toml: %w", err)
-	}
-	projectName := extractProjectNameCorp(pyprojectContent)
-	if projectName == "" {
-		projectName = cp.RepoName
-		fmt.Printf("   ⚠️  Could not parse name from pyproject.toml, using repo name: %s\n", projectName)
-	} else {
-		fmt.Printf("   Project name: %s\n", projectName)
-	}
-	cp.ProjectName = projectName
-	if cp.ImageName == "" {
-		cp.ImageName = dockerSafeNameCorp(projectName)
-	}
-
-	// ── Check Docker availability for testcontainers ─────────────
-	if cp.RunIntegrationTests || cp.RunAcceptanceTests {
-		fmt.Println("🔍 Checking Docker availability for testcontainers...")
-		if sock := getDockerSocketPathCorp(); sock != "" {
-			cp.HasDocker = true
-			fmt.Printf("   ✅ Docker socket detected: %s\n", sock)
-		} else {
-			cp.HasDocker = false
-			fmt.Printf("   ⚠️  Docker socket NOT available (OS: %s)\n", runtime.GOOS)
-			fmt.Println("   Integration/acceptance tests will be SKIPPED")
-		}
-	}
-
-	// ── Set up Python build environment with corporate CA + proxy ─
-	fmt.Println("🔨 Setting up Python build environment with corporate CA support...")
-	cp.PipCache = client.CacheVolume("pip-cache-" + dockerSafeNameCorp(cp.RepoName))
-	builder := cp.setupBuildEnv(client, source)
-
-	stageNum := 0
-
-	// ── Stage: Unit Tests (inside Dagger container) ──────────────
-	if cp.RunUnitTests {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: UNIT TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Dagger container (isolated, CA certs + proxy configured)")
-		fmt.Println("🧪 Running: pytest -m \"not integration and not acceptance\"")
-		fmt.Println(corporateSeparatorLine)
-
-		testContainer := builder.WithExec([]string{
-			"pytest", "-v", "--tb=short",
-			"-m", "not integration and not acceptance",
-		})
-		testOutput, err := testContainer.Stdout(ctx)
-		if err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: UNIT TESTS\n", stageNum)
-			return fmt.Errorf("unit tests failed: %w", err)
-		}
-		fmt.Println(testOutput)
-		fmt.Printf("✅ STAGE %d COMPLETE: All unit tests passed\n", stageNum)
-		builder = testContainer
-	}
-
-	// ── Stage: Integration Tests (on host — testcontainers needs Docker) ──
-	if cp.RunIntegrationTests && cp.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: INTEGRATION TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Host machine (testcontainers requires native Docker)")
-		fmt.Println("🧪 Running: pytest -v --tb=short -m integration")
-		fmt.Println(corporateSeparatorLine)
-		if err := cp.runTestsOnHostCorp(ctx, "integration"); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: INTEGRATION TESTS\n", stageNum)
-			return fmt.Errorf("integration tests failed: %w", err)
-		}
-		fmt.Printf("✅ STAGE %d COMPLETE: All integration tests passed\n", stageNum)
-	} else if cp.RunIntegrationTests && !cp.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: INTEGRATION TESTS — SKIPPED\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("   ⏭️  Docker not available — testcontainers cannot start PostgreSQL")
-	}
-
-	// ── Stage: Acceptance Tests (on host — testcontainers needs Docker) ──
-	if cp.RunAcceptanceTests && cp.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: ACCEPTANCE TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Host machine (testcontainers requires native Docker)")
-		fmt.Println("📦 Fixtures: Real ICAO .bin/.der fixtures used for end-to-end verification")
-		fmt.Println("🧪 Running: pytest -v --tb=short -m acceptance")
-		fmt.Println(corporateSeparatorLine)
-		if err := cp.runTestsOnHostCorp(ctx, "acceptance"); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: ACCEPTANCE TESTS\n", stageNum)
-			return fmt.Errorf("acceptance tests failed: %w", err)
-		}
-		fmt.Printf("✅ STAGE %d COMPLETE: All acceptance tests passed\n", stageNum)
-	} else if cp.RunAcceptanceTests && !cp.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: ACCEPTANCE TESTS — SKIPPED\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("   ⏭️  Docker not available — testcontainers cannot start PostgreSQL")
-	}
-
-	// ── Stage: Lint ──────────────────────────────────────────────
-	if cp.RunLint {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: LINT (ruff)\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("🔍 Running ruff check src/ tests/...")
-		lintContainer := builder.WithExec([]string{"ruff", "check", "src/", "tests/"})
-		if _, err := lintContainer.Stdout(ctx); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: LINT\n", stageNum)
-			return fmt.Errorf("ruff lint failed: %w", err)
-		}
-		fmt.Printf("✅ STAGE %d COMPLETE: Lint passed\n", stageNum)
-		builder = lintContainer
-	}
-
-	// ── Stage: Type Check ────────────────────────────────────────
-	if cp.RunTypeCheck {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: TYPE CHECK (mypy)\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("🔍 Running mypy src/ --strict...")
-		typeContainer := builder.WithExec([]string{"mypy", "src/", "--strict"})
-		if _, err := typeContainer.Stdout(ctx); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: TYPE CHECK\n", stageNum)
-			return fmt.Errorf("mypy type check failed: %w", err)
-		}
-		fmt.Printf("✅ STAGE %d COMPLETE: Type check passed\n", stageNum)
-	}
-
-	// ── Stage: Docker Build ──────────────────────────────────────
-	stageNum++
-	fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-	fmt.Printf("PIPELINE STAGE %d: BUILD DOCKER IMAGE\n", stageNum)
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println("🐳 Building Docker image from Dockerfile...")
-
-	image := source.DockerBuild()
-	shortSHA := commitSHA
-	if len(commitSHA) > 7 {
-		shortSHA = commitSHA[:7]
-	}
-	timestamp := time.Now().Format("20060102-1504")
-	imageTag := fmt.Sprintf("v0.1.0-%s-%s", shortSHA, timestamp)
-	imageNameClean := dockerSafeNameCorp(cp.ImageName)
-	userLower := strings.ToLower(cp.GitUser)
-	versionedImage := fmt.Sprintf("%s/%s/%s:%s", cp.Registry, userLower, imageNameClean, imageTag)
-	latestImage := fmt.Sprintf("%s/%s/%s:latest", cp.Registry, userLower, imageNameClean)
-	fmt.Printf("   Image: %s\n", versionedImage)
-	fmt.Printf("✅ STAGE %d COMPLETE: Docker image built\n", stageNum)
-
-	// ── Stage: Publish to Registry ───────────────────────────────
-	stageNum++
-	fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-	fmt.Printf("PIPELINE STAGE %d: PUBLISH TO REGISTRY\n", stageNum)
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Printf("📤 Publishing to: %s\n", versionedImage)
-
-	password := client.SetSecret("password", os.Getenv("CR_PAT"))
-	pubAddr, err := image.
-		WithRegistryAuth(cp.Registry, cp.GitUser, password).
-		Publish(ctx, versionedImage)
-	if err != nil {
-		return fmt.Errorf("failed to publish versioned image: %w", err)
-	}
-	latestAddr, err := image.
-		WithRegistryAuth(cp.Registry, cp.GitUser, password).
-		Publish(ctx, latestImage)
-	if err != nil {
-		return fmt.Errorf("failed to publish latest image: %w", err)
-	}
-	fmt.Printf("✅ STAGE %d COMPLETE: Images published\n", stageNum)
-	fmt.Printf("   📦 Versioned: %s\n", pubAddr)
-	fmt.Printf("   📦 Latest:    %s\n", latestAddr)
-
-	if deployWebhook := os.Getenv("DEPLOY_WEBHOOK"); deployWebhook != "" {
-		fmt.Println("🚀 Triggering deployment webhook...")
-		if err := cp.triggerWebhook(deployWebhook, imageTag, pubAddr, commitSHA, timestamp); err != nil {
-			fmt.Printf("⚠️  Warning: Deployment trigger failed: %v\n", err)
-		} else {
-			fmt.Println("✅ Deployment triggered successfully")
-		}
-	}
-
-	return nil
-}
-
-// setupBuildEnv creates a Dagger container with Python build dependencies,
-// corporate CA certificates installed, and proxy environment configured.
-// Installs: git, build-essential, libpq-dev → upgrades pip → installs
-// python_framework (local railway-rop) → installs cert-parser[dev,server].
-func (cp *CorporatePipeline) setupBuildEnv(client *dagger.Client, source *dagger.Directory) *dagger.Container {
-	container := client.Container().
-		From(baseImageCorporate).
-		WithExec([]string{"apt-get", "update"}).
-		WithExec([]string{"apt-get", "install", "-y", "--no-install-recommends",
-			"git", "build-essential", "libpq-dev", "ca-certificates"}).
-		WithExec([]string{"rm", "-rf", "/var/lib/apt/lists/*"})
-
-	// Mount corporate CA certificates and update the trust store
-	if len(cp.CACertPaths) > 0 {
-		fmt.Println("   📜 Mounting corporate CA certificates into container...")
-		for _, certPath := range cp.CACertPaths {
-			info, err := os.Stat(certPath)
-			if err != nil {
-				fmt.Printf("   ⚠️  Could not access %s: %v\n", certPath, err)
-				continue
-			}
-			filename := filepath.Base(certPath)
-			if info.IsDir() {
-				container = container.WithMountedDirectory("/usr/local/share/ca-certificates/"+filename, client.Host().Directory(certPath))
-			} else {
-				container = container.WithMountedFile("/usr/local/share/ca-certificates/"+filename, client.Host().File(certPath))
-			}
-			fmt.Printf("      ✓ Mounted %s\n", filename)
-		}
-		fmt.Println("   🔄 Updating CA certificate store (update-ca-certificates)...")
-		container = container.WithExec([]string{"update-ca-certificates"})
-	}
-
-	// Configure proxy if present
-	if cp.ProxyURL != "" {
-		fmt.Println("   🌐 Configuring proxy settings in container...")
-		fmt.Printf("      ✓ HTTP_PROXY=%s\n", cp.ProxyURL)
-		container = container.
-			WithEnvVariable("HTTP_PROXY", cp.ProxyURL).
-			WithEnvVariable("HTTPS_PROXY", cp.ProxyURL).
-			WithEnvVariable("http_proxy", cp.ProxyURL).
-			WithEnvVariable("https_proxy", cp.ProxyURL).
-			WithEnvVariable("NO_PROXY", "localhost,127.0.0.1,.local").
-			WithEnvVariable("no_proxy", "localhost,127.0.0.1,.local")
-	}
-
-	// Also set REQUESTS_CA_BUNDLE to point to the updated system bundle
-	// so that Python's requests/httpx library trusts corporate MITM certs
-	container = container.
-		WithEnvVariable("REQUESTS_CA_BUNDLE", "/etc/ssl/certs/ca-certificates.crt").
-		WithEnvVariable("SSL_CERT_FILE", "/etc/ssl/certs/ca-certificates.crt").
-		WithEnvVariable("CURL_CA_BUNDLE", "/etc/ssl/certs/ca-certificates.crt")
-
-	// Mount source and install dependencies
-	container = container.
-		WithMountedCache("/root/.cache/pip", cp.PipCache).
-		WithMountedDirectory(appWorkdirCorporate, source).
-		WithWorkdir(appWorkdirCorporate).
-		WithExec([]string{"pip", "install", "--upgrade", "pip", "setuptools", "wheel"}).
-		// Install local framework (railway-rop) before the project
-		WithExec([]string{"pip", "install", "-e", "./python_framework"}).
-		// Install main project with dev + server extras
-		WithExec([]string{"pip", "install", "-e", ".[dev,server]"})
-
-	return container
-}
-
-// getRepositorySource clones and returns (directory, commitSHA).
-// (Used internally when we need a bare source without the builder setup.)
-func (cp *CorporatePipeline) getRepositorySource(ctx context.Context, client *dagger.Client) (*dagger.Directory, string) {
-	gitURL := fmt.Sprintf("https://%s/%s/%s.git", cp.GitHost, cp.GitUser, cp.RepoName)
-	crPAT := client.SetSecret("github-pat", os.Getenv("CR_PAT"))
-	repo := client.Git(gitURL, dagger.GitOpts{
-		KeepGitDir:       true,
-		HTTPAuthToken:    crPAT,
-		HTTPAuthUsername: cp.GitAuthUser,
-	})
-	commitSHA, _ := repo.Branch(cp.GitBranch).Commit(ctx)
-	return repo.Branch(cp.GitBranch).Tree(), commitSHA
-}
-
-// runTestsOnHostCorp executes pytest with a marker on the HOST machine.
-// Integration and acceptance tests use testcontainers, which requires native
-// Docker socket access — Docker-in-Docker inside Dagger breaks volume mounts.
-// The host's corporate proxy env vars are inherited automatically by the child process.
-func (cp *CorporatePipeline) runTestsOnHostCorp(ctx context.Context, marker string) error {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	projectRoot := cwd + "/.."
-
-	fmt.Println("⚙️  Configuration:")
-	fmt.Printf("   • Project root: %s\n", projectRoot)
-	fmt.Printf("   • Marker:       %s\n", marker)
-	if cp.ProxyURL != "" {
-		fmt.Printf("   • Proxy:        %s (inherited from host env)\n", cp.ProxyURL)
-	}
-	fmt.Println("")
-
-	pytestBin := projectRoot + "/.venv/bin/pytest"
-	if _, err := os.Stat(pytestBin); err != nil {
-		pytestBin = "pytest"
-		fmt.Println("   ⚠️  .venv not found, using system pytest")
-	} else {
-		fmt.Printf("   • Using: %s\n", pytestBin)
-	}
-
-	cmd := exec.CommandContext(ctx, pytestBin, "-v", "--tb=short", "-m", marker)
-	cmd.Dir = projectRoot
-	cmd.Env = os.Environ() // inherit proxy settings and all host env vars
-
-	var outputBuffer strings.Builder
-	multiWriter := io.MultiWriter(os.Stdout, &outputBuffer)
-	cmd.Stdout = multiWriter
-	cmd.Stderr = os.Stderr
-
-	start := time.Now()
-	err = cmd.Run()
-	duration := time.Since(start)
-
-	fmt.Println(corporateSeparatorLine)
-	cp.displayHostTestSummary(marker, outputBuffer.String(), duration, err)
-
-	if err != nil {
-		return fmt.Errorf("%s tests failed: %w", marker, err)
-	}
-	return nil
-}
-
-// displayHostTestSummary parses pytest output and shows a concise result.
-func (cp *CorporatePipeline) displayHostTestSummary(marker string, output string, duration time.Duration, testErr error) {
-	passedPattern := regexp.MustCompile(`(\d+) passed`)
-	failedPattern := regexp.MustCompile(`(\d+) failed`)
-	errorPattern := regexp.MustCompile(`(\d+) error`)
-
-	lines := strings.Split(output, "\n")
-	var summaryLine string
-	for _, line := range lines {
-		if strings.Contains(line, "====") &&
-			(strings.Contains(line, "passed") || strings.Contains(line, "failed")) {
-			summaryLine = line
-		}
-	}
-
-	label := strings.ToUpper(marker[:1]) + marker[1:]
-	fmt.Printf("\n📊 %s Test Summary\n", label)
-	fmt.Println(corporateSeparatorLine)
-
-	if summaryLine != "" {
-		passed := "0"
-		failed := "0"
-		errors := "0"
-		if m := passedPattern.FindStringSubmatch(summaryLine); m != nil {
-			passed = m[1]
-		}
-		if m := failedPattern.FindStringSubmatch(summaryLine); m != nil {
-			failed = m[1]
-		}
-		if m := errorPattern.FindStringSubmatch(summaryLine); m != nil {
-			errors = m[1]
-		}
-		fmt.Printf("   Passed: %s | Failed: %s | Errors: %s | Duration: %v\n",
-			passed, failed, errors, duration.Round(time.Millisecond))
-	} else {
-		fmt.Printf("   Duration: %v\n", duration.Round(time.Millisecond))
-	}
-
-	if testErr != nil {
-		fmt.Printf("   ❌ FAILED: %s tests failed after %v\n", marker, duration.Round(time.Millisecond))
-	} else {
-		fmt.Printf("   ✅ SUCCESS: %s tests passed in %v\n", marker, duration.Round(time.Millisecond))
-	}
-	fmt.Println("")
-}
-
 // ── Self-contained helpers (corporate binary is compiled standalone) ──────────
 
-// extractProjectNameCorp parses the `name = "..."` field from pyproject.toml content.
-func extractProjectNameCorp(content string) string {
-	re := regexp.MustCompile(`(?m)^name\s*=\s*"([^"]+)"`)
-	matches := re.FindStringSubmatch(content)
-	if len(matches) >= 2 {
-		return matches[1]
-	}
+// systemProxyCorp decides on the proxy found in the OS settings when none
+// is exported. It is adopted with mode adopt, or with ask when the operator
+// confirms; otherwise it is only reported. A PAC file is always reported
+// but never evaluated, so it needs HTTP_PROXY set by hand.
+func systemProxyCorp(proxy pipeline.SystemProxy, mode string, confirm pipeline.ConfirmFunc) string {
+	if proxy.PACURL != "" {
+		fmt.Printf("   ℹ️  System proxy auto-config (PAC) found via %s: %s — not evaluated; set HTTP_PROXY to the proxy it selects\n", proxy.Source, proxy.PACURL)
+	}
+	if proxy.URL == "" {
+		return ""
+	}
+	switch {
+	case mode == pipeline.ProxyAutodetectAdopt:
+		fmt.Printf("   🌐 Using system proxy %s from %s (HTTP_PROXY unset, PROXY_AUTODETECT=adopt)\n", proxy.URL, proxy.Source)
+		return proxy.URL
+	case confirm != nil && confirm(fmt.Sprintf("HTTP_PROXY is unset but %s configures proxy %s. Use it?", proxy.Source, proxy.URL)):
+		return proxy.URL
+	}
+	warnings.Fprintf(os.Stdout, "proxy-unset", "⚠️  HTTP_PROXY is unset but %s configures proxy %s — running without a proxy (set HTTP_PROXY, or PROXY_AUTODETECT=adopt)\n", proxy.Source, proxy.URL)
 	return ""
 }
 
-// dockerSafeNameCorp converts a project name to a Docker-safe image name.
-func dockerSafeNameCorp(name string) string {
-	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
-}
-
 // envOrDefaultCorp returns the value of an environment variable, or a default.
 func envOrDefaultCorp(key, defaultValue string) string {
 	if v := os.Getenv(key); v != "" {
@@ -1167,10 +883,12 @@ func getDockerSocketPathCorp() string {
 			`//./pipe/docker_engine`,
 		}
 	case "darwin":
-		candidates = []string{
-			"/var/run/docker.sock",
-			os.Getenv("HOME") + "/.docker/run/docker.sock",
-			os.Getenv("HOME") + "/.colima/docker.sock",
+		candidates = []string{"/var/run/docker.sock"}
+		if home := certdiscovery.HomeDir(); home != "" {
+			candidates = append(candidates,
+				home+"/.docker/run/docker.sock",
+				home+"/.colima/docker.sock",
+			)
 		}
 	default: // linux
 		candidates = []string{
@@ -1190,23 +908,3 @@ func getDockerSocketPathCorp() string {
 	}
 	return ""
 }
-
-// minCorp returns the minimum of two integers.
-func minCorp(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// triggerWebhook triggers deployment webhook with build metadata
-func (cp *CorporatePipeline) triggerWebhook(webhookURL, imageTag, imageAddress, commitSHA, timestamp string) error {
-	// This would integrate with your deployment system
-	// Example: using webhook to trigger ArgoCD, Flux, or custom deployment service
-	fmt.Printf("   Webhook: %s\n", webhookURL)
-	fmt.Printf("   Image Tag: %s\n", imageTag)
-	fmt.Printf("   Image: %s\n", imageAddress)
-	fmt.Printf("   Commit: %s\n", commitSHA)
-	fmt.Printf("   Timestamp: %s\n", timestamp)
-	return nil
-}
@@ -0,0 +1,110 @@
+//go:build corporate
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cert-parser-dagger-go/certdiscovery"
+	"cert-parser-dagger-go/pipeline"
+)
+
+// writeTestCA writes a self-signed CA certificate in PEM form to path.
+func writeTestCA(t *testing.T, path string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Corporate Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCollectCACertificatesWithoutHome tests that discovery without a home
+// directory still scans the XDG-relocated Docker and Rancher Desktop directories
+func TestCollectCACertificatesWithoutHome(t *testing.T) {
+	configHome, dataHome := t.TempDir(), t.TempDir()
+	dockerCA := filepath.Join(configHome, "docker/certs.d/registry.corp.local/ca.crt")
+	rancherCA := filepath.Join(dataHome, "rancher-desktop/certs/corp-ca.pem")
+	writeTestCA(t, dockerCA)
+	writeTestCA(t, rancherCA)
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("CA_CERTIFICATES_PATH", "")
+	t.Setenv("DEBUG_CERTS", "false")
+
+	if home := certdiscovery.HomeDir(); home != "" {
+		t.Fatalf("expected no home directory, got %q", home)
+	}
+	result := collectCACertificates(certdiscovery.DefaultMaxFileSize)
+	found := map[string]bool{}
+	for _, entry := range result.Entries {
+		if entry.Excluded == "" && entry.Source == certdiscovery.SourceDocker {
+			found[entry.Path] = true
+		}
+	}
+	if !found[dockerCA] || !found[rancherCA] {
+		t.Fatalf("expected the XDG certificates %s and %s, got %v", dockerCA, rancherCA, result.Paths())
+	}
+	fmt.Println("✅ XDG certificate directories scanned without a home directory")
+}
+
+// TestSystemProxyCorp tests adopting, confirming and reporting the OS proxy
+func TestSystemProxyCorp(t *testing.T) {
+	detected := pipeline.SystemProxy{Source: "scutil --proxy", URL: "http://proxy.corp.local:8080"}
+	yes := func(string) bool { return true }
+	no := func(string) bool { return false }
+
+	if got := systemProxyCorp(detected, pipeline.ProxyAutodetectAdopt, nil); got != detected.URL {
+		t.Fatalf("adopt: expected %s, got %q", detected.URL, got)
+	}
+	if got := systemProxyCorp(detected, pipeline.ProxyAutodetectAsk, yes); got != detected.URL {
+		t.Fatalf("ask, confirmed: expected %s, got %q", detected.URL, got)
+	}
+	for name, confirm := range map[string]pipeline.ConfirmFunc{"declined": no, "no terminal": nil} {
+		if got := systemProxyCorp(detected, pipeline.ProxyAutodetectAsk, confirm); got != "" {
+			t.Fatalf("ask, %s: expected no proxy, got %q", name, got)
+		}
+	}
+	warned := false
+	for _, w := range warnings.List() {
+		warned = warned || w.ID == "proxy-unset"
+	}
+	if !warned {
+		t.Fatal("expected a proxy-unset warning for the proxy that was not used")
+	}
+
+	pac := pipeline.SystemProxy{Source: "scutil --proxy", PACURL: "http://wpad.corp.local/proxy.pac"}
+	if got := systemProxyCorp(pac, pipeline.ProxyAutodetectAdopt, yes); got != "" {
+		t.Fatalf("expected a PAC file never to be adopted, got %q", got)
+	}
+	fmt.Println("✅ System proxy adopted, confirmed or reported")
+}
@@ -0,0 +1,10 @@
+module dagger/cert-parser-pipeline
+
+go 1.24
+
+require (
+	cert-parser-dagger-go v0.0.0
+	dagger.io/dagger v0.19.7
+)
+
+replace cert-parser-dagger-go => ../
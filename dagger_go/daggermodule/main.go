@@ -0,0 +1,254 @@
+// Dagger module exposing the cert-parser pipeline stages to `dagger call`
+// and to other modules. Every function delegates to the container-level
+// stage implementations in cert-parser-dagger-go/pipeline, the same code the
+// standalone binaries run, so both invocation styles behave identically.
+//
+//	dagger call --source=.. test
+//	dagger call --source=.. lint
+//	dagger call --source=.. type-check
+//	dagger call --source=.. build export --path=image.tar
+//	dagger call --source=.. publish --address=ghcr.io/org/cert-parser:dev --registry-user=org --token=env:CR_PAT
+//
+// The generated SDK (dagger.gen.go, internal/) is not committed; run
+// `dagger develop` after cloning to create it.
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+
+	"dagger/cert-parser-pipeline/internal/dagger"
+
+	sdk "dagger.io/dagger"
+
+	"cert-parser-dagger-go/pipeline"
+)
+
+// CertParserPipeline runs individual pipeline stages against a source tree.
+type CertParserPipeline struct {
+	// +private
+	Source *dagger.Directory
+	// +private
+	RepoName string
+	// +private
+	ExtraAptPackages []string
+	// +private
+	SkipDefaultApt bool
+	// +private
+	ProxyURL string
+	// +private
+	GitRepo string
+	// +private
+	Revision string
+	// +private
+	SourceDateEpoch int
+	// +private
+	ImageLabels []string
+}
+
+func New(
+	// Project checkout containing pyproject.toml, the Dockerfile and python_framework/
+	source *dagger.Directory,
+	// Repository name, used in logs and image names
+	// +optional
+	// +default="cert-parser"
+	repoName string,
+	// Extra apt packages for the build container (EXTRA_APT_PACKAGES)
+	// +optional
+	extraAptPackages []string,
+	// Leave out the default apt packages (SKIP_DEFAULT_APT)
+	// +optional
+	skipDefaultApt bool,
+	// Proxy exported to the build container
+	// +optional
+	proxyURL string,
+	// Clone URL of the source, for the image's source label
+	// +optional
+	// +default="https://github.com/Javier-Godon/cert-parser.git"
+	gitRepo string,
+	// Commit the source was checked out at, for the image's revision label
+	// +optional
+	revision string,
+	// Unix time of the build, for reproducible images (SOURCE_DATE_EPOCH)
+	// +optional
+	sourceDateEpoch int,
+	// Extra key=value image labels (IMAGE_LABELS)
+	// +optional
+	imageLabels []string,
+) *CertParserPipeline {
+	return &CertParserPipeline{
+		Source:           source,
+		RepoName:         repoName,
+		ExtraAptPackages: extraAptPackages,
+		SkipDefaultApt:   skipDefaultApt,
+		ProxyURL:         proxyURL,
+		GitRepo:          gitRepo,
+		Revision:         revision,
+		SourceDateEpoch:  sourceDateEpoch,
+		ImageLabels:      imageLabels,
+	}
+}
+
+// Test runs the unit tests like the pipeline's unit stage (no external
+// services, JUnit report, failing when none are collected) and returns
+// pytest's output.
+func (m *CertParserPipeline) Test(ctx context.Context) (string, error) {
+	return m.inBuildEnv(ctx, func(p *pipeline.Pipeline, builder *sdk.Container) (string, error) {
+		return pipeline.TestOutput(ctx, p.UnitTests(builder))
+	})
+}
+
+// Lint runs ruff over src/ and tests/.
+func (m *CertParserPipeline) Lint(ctx context.Context) (string, error) {
+	return m.inBuildEnv(ctx, func(p *pipeline.Pipeline, builder *sdk.Container) (string, error) {
+		return pipeline.StageOutput(ctx, p.Lint(builder, nil))
+	})
+}
+
+// TypeCheck runs mypy in strict mode over src/.
+func (m *CertParserPipeline) TypeCheck(ctx context.Context) (string, error) {
+	return m.inBuildEnv(ctx, func(p *pipeline.Pipeline, builder *sdk.Container) (string, error) {
+		return pipeline.StageOutput(ctx, p.TypeCheck(builder, nil))
+	})
+}
+
+// Build builds the application image from the Dockerfile, with the OCI
+// labels and SOURCE_DATE_EPOCH of the pipeline's Docker Build stage.
+func (m *CertParserPipeline) Build(ctx context.Context) (*dagger.Container, error) {
+	cfg, err := m.config("dagger-module") // only used for registry auth, which Build never does
+	if err != nil {
+		return nil, err
+	}
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, source, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	image, err := m.image(ctx, p, source)
+	if err != nil {
+		return nil, err
+	}
+	id, err := image.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dag.LoadContainerFromID(dagger.ContainerID(id)), nil
+}
+
+// Publish builds the image and pushes it to address, returning the
+// published reference with its digest.
+func (m *CertParserPipeline) Publish(
+	ctx context.Context,
+	// Full image reference, e.g. ghcr.io/org/cert-parser:v1.2.3
+	address string,
+	// Registry user
+	registryUser string,
+	// Registry token (e.g. env:CR_PAT)
+	token *dagger.Secret,
+	// Registry host the credentials are for
+	// +optional
+	// +default="ghcr.io"
+	registry string,
+) (string, error) {
+	password, err := token.Plaintext(ctx)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := m.config(registryUser)
+	if err != nil {
+		return "", err
+	}
+	cfg.Registry, cfg.RegistryToken = registry, password
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		return "", err
+	}
+	client, source, err := m.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	image, err := m.image(ctx, p, source)
+	if err != nil {
+		return "", err
+	}
+	return p.PublishImage(ctx, client, image, address)
+}
+
+// image builds the application image like the pipeline's Docker Build stage.
+func (m *CertParserPipeline) image(ctx context.Context, p *pipeline.Pipeline, source *sdk.Directory) (*sdk.Container, error) {
+	labels, err := p.ImageLabels(ctx, source, m.Revision)
+	if err != nil {
+		return nil, err
+	}
+	return p.BuildImage(source, "", labels), nil
+}
+
+// config is the pipeline configuration of the module's options, with
+// gitUser for registry authentication.
+func (m *CertParserPipeline) config(gitUser string) (pipeline.Config, error) {
+	var labels map[string]string
+	for _, entry := range m.ImageLabels {
+		parsed, err := pipeline.ParseImageLabels(entry)
+		if err != nil {
+			return pipeline.Config{}, fmt.Errorf("image label: %w", err)
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		maps.Copy(labels, parsed)
+	}
+	return pipeline.Config{
+		RepoName:         m.RepoName,
+		GitUser:          gitUser,
+		GitRepo:          m.GitRepo,
+		ExtraAptPackages: m.ExtraAptPackages,
+		SkipDefaultApt:   m.SkipDefaultApt,
+		ProxyURL:         m.ProxyURL,
+		SourceDateEpoch:  int64(m.SourceDateEpoch),
+		ImageLabels:      labels,
+		Output:           os.Stderr,
+	}, nil
+}
+
+// inBuildEnv runs stage in the pipeline's build container and returns its output.
+func (m *CertParserPipeline) inBuildEnv(ctx context.Context, stage func(*pipeline.Pipeline, *sdk.Container) (string, error)) (string, error) {
+	cfg, err := m.config("dagger-module") // only used for registry auth, which these stages never do
+	if err != nil {
+		return "", err
+	}
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		return "", err
+	}
+	client, source, err := m.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	return stage(p, p.BuildEnv(client, source))
+}
+
+// connect opens a library client on the module's session and loads the
+// source directory into it by ID; the generated module types and the
+// library's dagger.io/dagger types are distinct Go types.
+func (m *CertParserPipeline) connect(ctx context.Context) (*sdk.Client, *sdk.Directory, error) {
+	id, err := m.Source.ID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sdk.Connect(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to the Dagger session: %w", err)
+	}
+	return client, client.LoadDirectoryFromID(sdk.DirectoryID(id)), nil
+}
@@ -1,511 +1,648 @@
+//go:build !corporate
+
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
-	"os/exec"
-	"regexp"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
-	"dagger.io/dagger"
+	"cert-parser-dagger-go/pipeline"
 )
 
 // Constants
-const (
-	baseImage                 = "python:3.14-slim"
-	appWorkdir                = "/app"
-	containerDockerSocketPath = "/var/run/docker.sock"
-	dockerUnixPrefix          = "unix://"
-	separatorLine             = "─────────────────────────────────────────────────────────────────────────────────"
-)
+const dockerUnixPrefix = "unix://"
 
-// Pipeline represents a universal Python CI/CD pipeline.
-// All project-specific values (name, image name) are discovered at runtime
-// from pyproject.toml inside the cloned repository — nothing is hardcoded.
-// Git host, container registry, and test stages are individually configurable
-// via environment variables, enabling use with GitHub, GitLab, Gitea, etc.
-type Pipeline struct {
-	RepoName            string // Repository name (e.g. "cert-parser")
-	ProjectName         string // Python project name from pyproject.toml
-	ImageName           string // Docker image name (default: RepoName, Docker-safe)
-	GitRepo             string // Full clone URL
-	GitBranch           string // Branch to build
-	GitUser             string // Username on the Git host
-	GitHost             string // Git server hostname (e.g. "github.com", "gitlab.com")
-	Registry            string // Container registry (e.g. "ghcr.io", "registry.gitlab.com")
-	GitAuthUser         string // HTTP auth username for git clone (e.g. "x-access-token", "oauth2")
-	PipCache            *dagger.CacheVolume
-	RunUnitTests        bool // Whether to run unit tests (default: true)
-	RunIntegrationTests bool // Whether to run integration tests (default: true)
-	RunAcceptanceTests  bool // Whether to run acceptance tests (default: true)
-	RunLint             bool // Whether to run ruff lint (default: true)
-	RunTypeCheck        bool // Whether to run mypy type check (default: true)
-	HasDocker           bool // Docker available on host for testcontainers
-}
+// warnings collects the warnings raised before the pipeline starts; the
+// pipeline adds its own and lists them all at the end of the run.
+var warnings = pipeline.NewWarnings()
 
-// main runs the CI/CD pipeline.
+// main runs the CI/CD pipeline. It only translates environment variables
+// into a pipeline.Config and pipeline errors into exit codes; other Go
+// programs can embed the same run with pipeline.New and (*Pipeline).Run.
 // Project name is auto-discovered from pyproject.toml unless overridden.
-// Required: CR_PAT (registry/git token), USERNAME.
+// Required: USERNAME, plus CR_PAT (registry/git token) when publishing.
+// In GitHub Actions (GITHUB_ACTIONS=true) USERNAME and REPO_NAME default to
+// the workflow's repository, and without CR_PAT the workflow's GITHUB_TOKEN
+// publishes to ghcr.io and clones that repository; the job then needs
+// `permissions: {contents: read, packages: write}`. CR_PAT is only needed to
+// clone a different private repository; another registry takes
+// REGISTRY_PASSWORD (or CR_PAT).
 //
 // Repository & registry configuration:
 //
 //	GIT_HOST=github.com|gitlab.com|...  (default: github.com)
-//	REGISTRY=ghcr.io|registry.gitlab.com|...  (default: ghcr.io)
+//	REGISTRY_HOST=<host>                (default: ghcr.io) registry to publish to, e.g. docker.io, harbor.corp.local or
+//	                                    <account>.dkr.ecr.<region>.amazonaws.com; REGISTRY is the older name
+//	REGISTRY_NAMESPACE=<ns>             (default: USERNAME) namespace of the image, e.g. a Docker Hub user or a Harbor
+//	                                    project; / publishes at the registry root (ECR)
+//	REGISTRY_USERNAME=<user>            (default: USERNAME) registry login; AWS for ECR
+//	REGISTRY_PASSWORD=<token>           (default: CR_PAT) registry password, e.g. a Docker Hub access token or the
+//	                                    output of aws ecr get-login-password
+//	REGISTRY_PASSWORD_FILE=<path>       (optional) read the registry password from a file instead; re-read after a
+//	                                    401 during publish
 //	GIT_AUTH_USERNAME=x-access-token|oauth2|...  (default: x-access-token)
 //	REPO_NAME=<name>                    (auto-detected from parent dir if unset)
-//	GIT_BRANCH=<branch>                 (default: main)
+//	GIT_BRANCH=<branch>                 (default: main) also refs/heads/<branch>, origin/<branch> or a
+//	                                    40-hex commit SHA for detached builds; tags are rejected
+//	GIT_TAG=<tag>                       (optional) build a tag instead, e.g. v1.2.0 or refs/tags/v1.2.0; the
+//	                                    image is tagged <tag>-<sha>-<timestamp> instead of v<version>-<sha>-<timestamp>
+//	GIT_COMMIT=<sha>                    (optional) build a 40-hex commit SHA instead; set only one of GIT_BRANCH,
+//	                                    GIT_TAG and GIT_COMMIT
+//	LOCAL_SOURCE=<path>                 (optional; or --source-dir) build this host directory, e.g. "..", instead
+//	                                    of cloning: .venv, .git, __pycache__ and dagger_go are left out, the image is
+//	                                    tagged with its `git rev-parse HEAD` or "local"; CR_PAT only when publishing
+//	BEHIND_WARN_THRESHOLD=<n>           (default: 50) warn when the branch is more commits behind the
+//	                                    default branch (GitHub compare API); negative disables
+//	BEHIND_STRICT=true|false            (default: false) fail a publishing run over that threshold
+//	COMMIT_CHECK=warn|fail|off          (default: warn) check with the GitHub compare API that the built commit
+//	                                    is on GIT_BRANCH, after the clone and again before publishing
+//	CLOCK_SKEW_THRESHOLD=<duration>     (default: 2m) warn when the local clock is further off the Git
+//	                                    host's Date header (x509 and token errors); negative disables
+//	CLOCK_SKEW_STRICT=true|false        (default: false) fail a publishing run over that threshold
 //	IMAGE_NAME=<name>                   (default: Docker-safe project name)
+//	NAME_CHECK=warn|fail|off            (default: warn) compare the pyproject.toml name with REPO_NAME and
+//	                                    IMAGE_NAME; fail stops the run before the tests on a mismatch
+//	IMAGE_LABELS=<k=v,…>                (optional) extra image labels, e.g. org.opencontainers.image.vendor=ACME;
+//	                                    they replace the OCI source, revision, created, version and title labels
+//	RELEASE_VERSION=<x.y.z>             (optional) on the default branch only: set the pyproject.toml version,
+//	                                    commit it, push it with the tag vX.Y.Z (CR_PAT) and build the tag;
+//	                                    an existing tag fails the run
+//	RELEASE_DRY_RUN=true|false          (default: false) check the release and print what it would push, then stop
+//	RELEASE_AUTHOR="Name <email>"       (default: Release Pipeline <release-pipeline@users.noreply.github.com>)
+//	CR_PAT_COMMAND=<cmd>                (optional) prints a fresh registry token after a 401 during publish
+//	CR_PAT_FILE=<path>                  (optional) re-read for a fresh registry token after a 401 during publish
+//	GIT_DEPENDENCY_TOKEN=<token>        (default: CR_PAT) offered to pip for private git+https dependencies
+//	GIT_DEPENDENCY_HOSTS=<[user@]host…> (default: GIT_AUTH_USERNAME@GIT_HOST) hosts that token is offered to
+//
+// Monorepos:
+//
+//	DISCOVER_PROJECTS_GLOB=<glob>     (optional) run once per matching directory with a pyproject.toml,
+//	                                  e.g. services/*; IMAGE_NAME is ignored, each project names its image
+//	DISCOVER_CHANGED_ONLY=true|false  (default: false) only projects changed since CHANGED_BASE
+//	CHANGED_BASE=<ref>                (default: origin/main) compared with HEAD in the host checkout
+//
+// Command-line flags (--help lists them with their defaults):
+//
+//	--<option>[=value]                every option except secrets, named like config-schema: --repo-name=x,
+//	                                  --image-name=x, --run-lint=false; aliases --branch, --skip-unit,
+//	                                  --skip-integration, --skip-acceptance, --skip-lint, --skip-typecheck,
+//	                                  --skip-build and --skip-publish; precedence: flag > environment >
+//	                                  PIPELINE_CONFIG > PIPELINE_PROFILE > default; a flag that replaces a
+//	                                  variable's value is reported at startup
+//
+// Config file & profiles (defaults only — variables set explicitly always win):
+//
+//	PIPELINE_CONFIG=<path>            (default: pipeline.yaml when present) YAML mapping of options by name,
+//	                                  e.g. run-unit-tests: false; lists may be sequences; unknown keys fail
+//	                                  the run; secrets such as CR_PAT stay in the environment
+//	PIPELINE_PROFILE=pr|main|nightly  (default: main) pr: RUN_PUBLISH=false, CHANGED_ONLY_TESTS=true,
+//	                                  LINT_CHANGED_ONLY=true; main: today's defaults; nightly: main plus RUN_SLOW_TESTS,
+//	                                  RUN_BENCHMARKS, RUN_DEPENDENCY_AUDIT, RUN_VULN_SCAN and NO_CACHE
 //
 // Test configuration environment variables:
 //
 //	RUN_UNIT_TESTS=true|false         (default: true)
 //	RUN_INTEGRATION_TESTS=true|false  (default: true)  — requires Docker
 //	RUN_ACCEPTANCE_TESTS=true|false   (default: true)   — requires Docker
+//	ACCEPTANCE_AGAINST_IMAGE=true|false (default: false) acceptance tests call the built image, not the editable install
+//	ACCEPTANCE_IMAGE_PORT=<port>      (default: first port the image exposes)
+//	SERVICE_READY_URL=tcp|/healthz|<url> (default: tcp) probe the started image passes before the acceptance tests
+//	SERVICE_READY_INTERVAL=<duration> (default: 500ms) between probes
+//	SERVICE_READY_TIMEOUT=<duration>  (default: 60s) then the image's last output is printed and the run fails
 //	RUN_LINT=true|false               (default: true)
 //	RUN_TYPE_CHECK=true|false         (default: true)
+//	RUN_BUILD=true|false              (default: true)
+//	RUN_PUBLISH=true|false            (default: true)   — implies RUN_BUILD
+//	PUBLISH_TEST_IMAGE=true|false     (default: false)  also publish <image>-tests:<tag> with the test suite
+//	PUBLISH_PR_IMAGE=true|false       (default: false)  in a pull request build, publish only the moving <image>:pr-<number>
+//	                                  tag instead of RUN_PUBLISH; the job summary shows the pull command
+//	ALLOW_FORK_PR_IMAGE=true|false    (default: false)  also publish it for pull requests from forks (untrusted code)
+//	COMMIT_STATUS=true|false          (default: false)  post a GitHub commit status for the run and each stage with
+//	                                  GIT_TOKEN; skipped stages are pending with their reason
+//	PR_COMMENT=true|false             (default: false)  in a pull request build, comment the stage table on it
+//	PR_IMAGE_CLEANUP=true|false       (default: false)  after a publish outside a pull request, delete the GHCR images
+//	                                  of closed pull requests (the token needs delete:packages)
+//	REQUIRE_DOCKER_TESTS=true|false   (default: true on main or when publishing) fail instead of
+//	                                  skipping integration/acceptance tests when Docker is missing
+//	PUBLISH_REQUIRES=unit,lint        (default: unit) stages that must run and pass in this run before
+//	                                  publishing: unit slow benchmarks integration acceptance lint
+//	                                  typecheck audit hardening vuln-scan
+//	PUBLISH_POLICY=on|off             (default: on) off publishes without that check (loud warning)
+//	ALLOW_EMPTY_TEST_STAGE=true|false (default: false) pass an enabled test stage whose marker
+//	                                  selects no tests (pytest exit 5) instead of failing
+//	RUN_SLOW_TESTS=true|false         (default: false) pytest -m slow in its own stage, not in unit tests
+//	RUN_BENCHMARKS=true|false         (default: false) pytest -m benchmark in its own stage
+//	DEP_RESOLUTION_MATRIX=highest,lowest (default: highest) also run the unit tests against the lowest versions
+//	                                  the dependency lower bounds allow (uv --resolution lowest-direct)
+//	PYTHON_VERSIONS=3.12,3.13,3.14    (optional) also run the unit tests, lint and type check on these Python
+//	                                  versions, concurrently, each from BASE_IMAGE with the version swapped in;
+//	                                  the image is still built from BASE_IMAGE
+//	CHANGED_ONLY_TESTS=true|false     (default: false) only tests affected by changes since CHANGED_BASE;
+//	                                  changes that cannot be mapped to test files run everything
+//	LINT_CHANGED_ONLY=true|false      (default: false) ruff and mypy only on Python files changed since DIFF_BASE
+//	DIFF_BASE=<ref>                   (default: main) branch, tag or commit of the cloned repository
+//	LINT_CHANGED_MAX=<n>              (default: 30) more changed Python files check the full repository
+//	DATABASE_URL_OVERRIDE=<url>       (optional) external PostgreSQL for integration/acceptance tests;
+//	                                  no Docker/testcontainers needed, only the host appears in logs
+//	HOST_TEST_WRAPPER="<cmd> <args>"  (optional) run the host pytest through this command, shell-word split,
+//	                                  e.g. "corp-netns-exec --profile build --"; the final command is printed
+//	DB_SEED_COMMAND="<cmd> <args>"    (optional) load fixtures into the test database once it exists, before the
+//	                                  integration/acceptance tests, e.g. "python -m cert_parser.tools.seed --fixtures
+//	                                  tests/fixtures/seed"; a failure fails the stage, its duration is reported apart
+//
+// Build container:
+//
+//	BASE_IMAGE=<image>                (default: python:3.14-slim) Python image the build container starts from
+//	APT_PACKAGES="a b,c"              (default: git build-essential libpq-dev) apt packages of the build container,
+//	                                  replacing the default; EXTRA_APT_PACKAGES is still added after them
+//	EXTRA_APT_PACKAGES="a b,c"        (optional) extra apt packages, space or comma separated
+//	SKIP_DEFAULT_APT=true|false       (default: false) leave out git build-essential libpq-dev
+//	BASE_IMAGE_MIRROR=<prefix>        (optional) pull the base image through a Docker Hub mirror,
+//	                                  e.g. registry.corp.local/dockerhub
+//	REGISTRY_MIRRORS=a,b              (optional) images or registry hosts on mirrors the engine pulls from,
+//	                                  e.g. registry.corp.local/python/python:3.14-slim; each, and the
+//	                                  base image through BASE_IMAGE_MIRROR, is fetched before the clone
+//	PIP_INDEX_URL=<url>               (optional) package index pip installs from, e.g. https://pypi.corp.local/simple
+//	HASH_CHECKED_INSTALL=true|false   (default: false) install the dependencies from HASH_LOCK_FILE with
+//	                                  --require-hashes --no-deps, then the local packages and the project
+//	                                  editable without their dependencies, then pip check
+//	HASH_LOCK_FILE=<path>             (default: requirements.lock) the lockfile, relative to the project
+//	OFFLINE_MODE=true|false           (default: false) air-gapped run: requires BASE_IMAGE_MIRROR and PIP_INDEX_URL,
+//	                                  rejects github.com/pypi.org/docker.io hosts and skips the audit and trivy scan
+//	OFFLINE_ALLOW_HOSTS=a,b           (optional) internal domains an offline run may contact, e.g. corp.local
+//	DOCKERHUB_PULL_USERNAME=<user>    (optional) authenticate Docker Hub pulls, avoiding the anonymous
+//	DOCKERHUB_PULL_TOKEN=<token>      rate limit (429 toomanyrequests); set both
+//	NO_CACHE=true|false               (default: false) re-run every step instead of reusing cached results
+//	STAGE_MEMORY_LIMIT=<size>         (optional) address space per test/lint process, e.g. 3GB; a MemoryError
+//	                                  instead of the OOM killer. Dagger has no per-stage memory or CPU limits
+//	TEST_AS_USER=<uid>[:<gid>]        (default: root) run the container test, lint and type-check commands as this
+//	                                  user, e.g. 1000:1000 like the production image, to catch permission bugs
+//	TEST_UMASK=<octal>                (optional) umask of those commands, e.g. 027
+//	STAGE_ENV_<STAGE>=<K=V,…>         (optional) extra environment of one stage only, e.g.
+//	                                  STAGE_ENV_UNIT_TESTS="TZ=UTC,FEATURE_X=1" or STAGE_ENV_TYPECHECK="MYPYPATH=stubs";
+//	                                  K=@secret:CR_PAT passes that variable's value as a Dagger secret
+//	SOURCE_DATE_EPOCH=<seconds>       (optional) fixed build time for the image tag, the created label and the
+//	                                  Dockerfile build arg, e.g. $(git log -1 --format=%ct); default: the clock
+//	EXPORT_TEST_VENV=true|false       (default: false) run host tests in a copy of the build container's environment
+//	VENV_DIR=<path>                   (default: <ARTIFACTS_DIR>/test-venv) where that copy is created; not inside the checkout
+//	RUN_DEPENDENCY_AUDIT=true|false   (default: false) pip-audit over the installed dependencies
+//	LOCAL_FRAMEWORK_DIRS=<dir,...>    (default: python_framework when present) local packages installed, in order,
+//	                                  before the project; +<dir,...> adds to the default, none installs none
+//
+// Image hardening (runs after every Docker build):
+//
+//	ALLOW_ROOT_IMAGE=true|false       (default: false) pass even when the image runs as root
+//	RUN_DOCKLE=true|false             (default: false) CIS-style checks with dockle
+//	DOCKLE_FAIL_LEVEL=FATAL|WARN|INFO (default: FATAL) lowest dockle level that fails the run
+//	ENTRYPOINT_CHECK=warn|fail|off    (default: warn) image ENTRYPOINT/CMD must run a [project.scripts] entry
+//	IMAGE_WORKDIR=<path>              (optional) WORKDIR the image must have, e.g. /app
+//	RUN_VULN_SCAN=true|false          (default: false) fail on fixable HIGH/CRITICAL vulnerabilities (trivy)
+//	GITHUB_STEP_SUMMARY               (set by GitHub Actions) results appended to the job summary
+//
+// Python package publishing (sdist + wheel via twine):
+//
+//	RUN_PACKAGE_PUBLISH=true|false    (default: false)
+//	TWINE_REPOSITORY_URL=<url>        (default: TestPyPI, or PyPI with PACKAGE_PUBLISH_PROD=true)
+//	TWINE_USERNAME / TWINE_PASSWORD   index credentials (username default: __token__)
+//	                                  Uploads happen on main only; other branches build + check.
+//	EXPORT_WHEELS=true|false          (default: true on main) build the wheel and export dist/, no upload
+//	ARTIFACTS_DIR=<path>              (default: pipeline-artifacts) dist/ files and pipeline-report.json
+//	STAGE_ARTIFACTS_LIMIT=<size>|off  (default: 100MiB) files a container stage writes to /app/.pipeline-artifacts
+//	                                  are exported to ARTIFACTS_DIR/<stage>, passing or failing, up to this size
+//	PARANOID=true|false               (default: false, or --paranoid) fail if the run changed the checkout's
+//	                                  git working tree outside ARTIFACTS_DIR
+//
+// Status reporting:
+//
+//	OUTPUT=full|compact               (default: full) compact prints one line per stage ("[2/4] unit-tests …
+//	                                  1m23s ✅"), updated in place on a terminal, and the end of a failed
+//	                                  stage's output — for pre-push hooks
+//	STATUS_FILE=<path>                (optional) status.json rewritten after every stage transition
+//	EVENTS_FILE=<path>                (optional) JSON-lines event stream (stages, warnings, test counts,
+//	                                  published images, heartbeats) appended to next to the console output
+//	HTML_REPORT=true|false            (default: false) also render the report as <ARTIFACTS_DIR>/report.html:
+//	                                  stages, test counts, failures, images, warnings and environment,
+//	                                  one file with no external assets to mail or attach to a CI run
+//	STATUS_LISTEN=<[host]:port>       (optional) serve /status, /report and /healthz while running, e.g. :8088
+//	                                  (binds 127.0.0.1 unless a host is given)
+//	STATUS_ALLOW_REMOTE=true|false    (default: false) allow a non-loopback STATUS_LISTEN; no authentication
+//	DURATION_BUDGET=<duration>        (optional) warn when the run takes longer, e.g. 20m
+//	DURATION_BUDGET_HARD=true|false   (default: false) fail a successful run over budget (exit 10) instead of warn
+//	DURATION_HISTORY_FILE=<path>      (default: user cache dir) per-branch duration history
+//	BASELINE_FREEZE=<path>            (optional) requirements-resolved.txt to diff the new freeze against
+//	FREEZE_HISTORY_DIR=<dir>          (default: user cache dir) last freeze, image size and built commit per branch,
+//	                                  the default baseline
+//	FORCE_RUN=true|false              (default: false) run even when the commit is the one the last successful run
+//	                                  on the branch built; otherwise such a run stops early and exits 0
+//	VERIFY_UNCHANGED=true|false       (default: false) skip an unchanged commit only while its published images are
+//	                                  still in the registry
+//	IMAGE_SIZE_BUDGET=<size>          (optional) warn when the image is larger, e.g. 300MB
+//	IMAGE_SIZE_MAX_GROWTH=<percent>   (default: 20) warn when the image grew more since the branch's previous build
+//	IMAGE_SIZE_HARD_LIMIT=true|false  (default: false) fail the build stage instead of warning
+//	WARNINGS_BUDGET=<n>               (optional) fail when the unit, integration and acceptance tests emit more Python
+//	                                  warnings in total, or categories the branch's last accepted run did not have;
+//	                                  warnings are always listed in the report and the job summary
+//	PLATFORMS=a,b                     (optional) build the image for each platform, e.g. linux/amd64,linux/arm64;
+//	                                  with more than one, the versioned tag and latest are manifest lists
+//	LARGE_IMAGE_THRESHOLD=<size>|off  (default: 1GiB) retry the publish of a larger image with backoff and print progress
+//	LARGE_IMAGE_PUBLISH_ATTEMPTS=<n>  (default: 4) publish attempts for a large image
+//	LARGE_IMAGE_PUBLISH_TIMEOUT=<dur> (default: 45m) time limit per publish attempt of a large image
+//	MIN_FREE_SPACE=<size>|off         (default: 5GiB) fail before any stage when the engine, temp or artifacts disk has less free space
+//	DAGGER_ENGINE_STATE_DIR=<dir>     (default: /var/lib/docker for a local engine on Linux) engine state directory checked for free space
+//	FLAKY_THRESHOLD=<0-1>             (default: 0.2) flag tests failing intermittently at this rate or more
+//	FLAKY_WINDOW=<n>                  (default: 20) runs kept in the flaky-test history
+//	FLAKY_HISTORY_FILE=<path>         (default: <ARTIFACTS_DIR>/history/test-history.json) per-test outcomes
+//	FLAKY_HISTORY_URL=<url>           (optional) remote history read with GET, written with PUT
+//	LINT_HISTORY_FILE=<path>          (default: <ARTIFACTS_DIR>/history/lint-history.json) ruff/mypy counts per run
+//	LINT_RATCHET=true|false           (default: false) fail lint/type check when findings grew since the previous run
+//	WARNINGS_AS_ERRORS=true|false     (default: false) fail an otherwise passing run on any warning; all warnings
+//	                                  are listed, deduplicated, in a final "Warnings (n)" section and the report
+//	WARNINGS_ALLOW=<id,...>           (optional) warning IDs that stay non-fatal, e.g. tool-unpinned,branch-behind
+//
+// Interactive runs:
+//
+//	NON_INTERACTIVE=true|false        (default: false) never prompt; prompts are also skipped
+//	                                  without a TTY on stdin or when CI=true. Prompts default
+//	                                  to "no" after 30s. Asked before publishing :latest from
+//	                                  a branch other than main.
+//
+// Auxiliary tool images (pinned in pipeline.ToolImages):
+//
+//	TOOL_IMAGE_<NAME>=<image>         (optional) e.g. TOOL_IMAGE_TRIVY=mirror.corp/aquasec/trivy:0.58.1
+//	--update-tool-pins                print the table with current registry digests and exit
+//
+// Warm-up (`go run main.go warmup`, no USERNAME/CR_PAT needed): pulls the base
+// and tool images, fills the apt cache volume and, with WARMUP_REQUIREMENTS,
+// the pip cache, so the first real run on a fresh runner is not a cold one.
+//
+//	WARMUP_REQUIREMENTS=<path>        (optional) requirements file pre-installed into the pip cache
+//
+// Cleanup (`go run main.go cleanup`, no USERNAME/CR_PAT needed): lists the
+// pipeline's cache volumes (apt-cache, pip-cache, trivy-cache and their
+// CACHE_KEY variants) with size and last use. Dry run unless CLEANUP_APPLY=true.
+//
+//	CACHE_MAX_AGE=<days>              (default: 30) empty volumes unused for longer
+//	CLEANUP_APPLY=true|false          (default: false) empty the stale volumes instead of listing them
+//	CLEANUP_ENGINE_PRUNE=true|false   (default: false) also prune the engine's dangling build cache
+//
+// Watch (`go run main.go --watch` or MODE=watch, no USERNAME/CR_PAT needed):
+// runs the unit tests and ruff on LOCAL_SOURCE, then re-runs them for the
+// changed files whenever src/, tests/ or pyproject.toml change. The build
+// environment is built once and reused; nothing is built or published.
+// Ctrl-C stops it.
+//
+//	LOCAL_SOURCE=<path>               (required for watch) project directory to watch
+//
+// Config schema (`go run main.go config-schema`, no engine or credentials
+// needed): prints every option above as JSON — variable, flag, type,
+// default, description, modes and profile defaults — from the registry in
+// pipeline.Options the binary reads. At startup the options set in the
+// environment are listed, and a variable one or two edits from an option
+// (RUN_UNIT_TEST) warns with the option it probably meant. Where each option
+// came from — default, profile, environment or flag — is shown next to the
+// RUN_* settings and recorded under option_sources in pipeline-report.json.
+//
+// Engine config (`go run main.go engine-config`, no engine or credentials
+// needed): prints an engine.toml that sends Docker Hub pulls through
+// BASE_IMAGE_MIRROR and the docker run command starting a Dagger engine with
+// it. The engine pulls the base images itself, so a mirror with an internal
+// CA needs such an engine (DAGGER_RUNNER_HOST); the corporate binary also
+// installs its CA certificates into it.
+//
+// Compare (`go run main.go compare [--json] old.json new.json`, no engine or
+// credentials needed): prints what differs between two pipeline-report.json
+// files — configuration (secrets redacted), environment fingerprint,
+// resolved dependency versions and stage outcomes/durations.
+//
+// Exit codes:
+//
+//	0 success, 1 uncategorized, 2 configuration/validation error,
+//	3 clone/auth failure, 4 test failure, 5 lint/type failure,
+//	6 build failure, 7 publish failure, 8 Dagger engine unreachable/incompatible,
+//	9 deployment verification failure (corporate binary),
+//	10 over DURATION_BUDGET with DURATION_BUDGET_HARD,
+//	11 Docker unavailable for required integration/acceptance tests, 130 cancelled
+//
+// Engine:
+//
+//	DAGGER_RUNNER_HOST=docker-container://<name>|tcp://<host>:<port>|unix://<path>
+//	                                  (optional) use an existing engine instead of provisioning one
+//	STRICT_ENGINE_VERSION=true|false  (default: false) fail instead of warn on engine/SDK mismatch
+//	TIMING_DETAIL=true|false          (default: false) time connect, git tree, pyproject.toml, the first exec, docker build
+//	                                  and publish, forcing evaluation at each; printed and in the report (slower)
 func main() {
-	ctx := context.Background()
-
-	// Check required environment variables
-	for _, v := range []string{"CR_PAT", "USERNAME"} {
-		if _, ok := os.LookupEnv(v); !ok {
-			fmt.Fprintf(os.Stderr, "ERROR: %s environment variable must be set\n", v)
-			os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	updateToolPins := flag.Bool("update-tool-pins", false, "print the tool image table with current digests and exit")
+	watchFlag := flag.Bool("watch", false, "re-run unit tests and ruff on LOCAL_SOURCE whenever it changes")
+	compareJSON := flag.Bool("json", false, "compare: print the differences as JSON")
+	paranoid := flag.Bool("paranoid", false, "fail if the run changed the git working tree outside ARTIFACTS_DIR")
+	optionFlags := pipeline.RegisterOptionFlags(flag.CommandLine, pipeline.BinaryStandard)
+	flag.Parse()
+	if flag.Arg(0) == "config-schema" {
+		schema, err := pipeline.ConfigSchema(pipeline.BinaryStandard)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
 		}
+		os.Stdout.Write(schema)
+		return
 	}
-
-	username := os.Getenv("USERNAME")
-	repoName := envOrDefault("REPO_NAME", "")
-	gitBranch := envOrDefault("GIT_BRANCH", "main")
-	imageName := envOrDefault("IMAGE_NAME", "")
-	gitHost := envOrDefault("GIT_HOST", "github.com")
-	registry := envOrDefault("REGISTRY", "ghcr.io")
-	gitAuthUser := envOrDefault("GIT_AUTH_USERNAME", "x-access-token")
-
-	// Parse configurable pipeline stages
-	runUnitTests := parseEnvBool("RUN_UNIT_TESTS", true)
-	runIntegrationTests := parseEnvBool("RUN_INTEGRATION_TESTS", true)
-	runAcceptanceTests := parseEnvBool("RUN_ACCEPTANCE_TESTS", true)
-	runLint := parseEnvBool("RUN_LINT", true)
-	runTypeCheck := parseEnvBool("RUN_TYPE_CHECK", true)
-
-	fmt.Println("🚀 Starting Python CI/CD Pipeline (Go SDK v0.19.7)...")
-	fmt.Printf("   Git Host:  %s\n", gitHost)
-	fmt.Printf("   Registry:  %s\n", registry)
-	fmt.Printf("   User:      %s\n", username)
-	fmt.Printf("   Branch:    %s\n", gitBranch)
-	fmt.Println("🧪 Test Configuration:")
-	fmt.Printf("   Unit tests:        %v (RUN_UNIT_TESTS)\n", runUnitTests)
-	fmt.Printf("   Integration tests: %v (RUN_INTEGRATION_TESTS)\n", runIntegrationTests)
-	fmt.Printf("   Acceptance tests:  %v (RUN_ACCEPTANCE_TESTS)\n", runAcceptanceTests)
-	fmt.Printf("   Lint (ruff):       %v (RUN_LINT)\n", runLint)
-	fmt.Printf("   Type check (mypy): %v (RUN_TYPE_CHECK)\n", runTypeCheck)
-
-	if !runUnitTests && !runIntegrationTests && !runAcceptanceTests {
-		fmt.Println("⚠️  All test stages disabled — skipping tests, proceeding to lint/build/push")
-	}
-
-	// Initialize Dagger client
-	client, err := dagger.Connect(ctx, dagger.WithLogOutput(os.Stderr))
+	if flag.Arg(0) == "engine-config" {
+		registryMirrors, err := pipeline.ParseRegistryMirrors(os.Getenv("REGISTRY_MIRRORS"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: REGISTRY_MIRRORS: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
+		}
+		pipeline.WriteEngineConfig(os.Stdout, pipeline.EngineSetup{BaseImageMirror: os.Getenv("BASE_IMAGE_MIRROR"), RegistryMirrors: registryMirrors})
+		return
+	}
+	if flag.Arg(0) == "compare" {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "ERROR: usage: compare [--json] <older pipeline-report.json> <newer pipeline-report.json>")
+			os.Exit(pipeline.ExitConfig)
+		}
+		if _, err := pipeline.CompareReportFiles(os.Stdout, flag.Arg(1), flag.Arg(2), *compareJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.CategoryOf(err).ExitCode())
+		}
+		return
+	}
+	if err := optionFlags.Apply(os.LookupEnv, os.Setenv); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	configFile := applyConfigFile()
+	warmup := flag.Arg(0) == "warmup"
+	cleanup := flag.Arg(0) == "cleanup"
+	watch := *watchFlag || os.Getenv("MODE") == "watch"
+	outputMode, err := pipeline.ParseOutputMode(os.Getenv("OUTPUT"))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Failed to create Dagger client: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "ERROR: OUTPUT: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
 	}
-	defer client.Close()
-
-	pipeline := &Pipeline{
-		RepoName:            repoName,
-		ImageName:           imageName,
-		GitBranch:           gitBranch,
-		GitUser:             username,
-		GitHost:             gitHost,
-		Registry:            registry,
-		GitAuthUser:         gitAuthUser,
-		RunUnitTests:        runUnitTests,
-		RunIntegrationTests: runIntegrationTests,
-		RunAcceptanceTests:  runAcceptanceTests,
-		RunLint:             runLint,
-		RunTypeCheck:        runTypeCheck,
+	// OUTPUT=compact: one line per stage; the rest of stdout is kept as
+	// detail for the excerpt of a failure
+	var compact *pipeline.CompactOutput
+	restoreStdout := func() {}
+	if outputMode == pipeline.OutputCompact && !warmup && !cleanup && !watch && !*updateToolPins {
+		compact = pipeline.NewCompactOutput(os.Stdout, pipeline.IsTerminal(os.Stdout))
+		if restoreStdout, err = compact.CaptureStdout(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: OUTPUT=compact: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
+		}
 	}
-
-	if err := pipeline.run(ctx, client); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed: %v\n", err)
-		os.Exit(1)
+	if *updateToolPins {
+		httpClient, err := pipeline.NewHTTPClient(pipeline.HTTPClientConfig{})
+		if err == nil {
+			err = pipeline.UpdateToolPins(ctx, os.Stdout, httpClient)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitFailure)
+		}
+		return
 	}
 
-	fmt.Println("\n🎉 Pipeline completed successfully!")
-}
-
-// run executes the full pipeline:
-// Clone → Discover → Install → Unit Tests → Integration Tests → Acceptance Tests
-// → Lint → Type-check → Docker Build → Publish
-func (p *Pipeline) run(ctx context.Context, client *dagger.Client) error {
-	// ── Clone repository from GitHub ─────────────────────────────
-	crPAT := client.SetSecret("github-pat", os.Getenv("CR_PAT"))
-
-	if p.RepoName == "" {
-		return fmt.Errorf("REPO_NAME environment variable is required (e.g. 'cert-parser')")
+	pipeline.PrintOptions(os.Stdout, os.LookupEnv, os.Environ(), configFile, optionFlags)
+	if configFile != nil {
+		pipeline.PrintConfigFile(os.Stdout, configFile)
 	}
-
-	gitURL := fmt.Sprintf("https://%s/%s/%s.git", p.GitHost, p.GitUser, p.RepoName)
-	p.GitRepo = gitURL
-	fmt.Printf("\n📥 Cloning repository: %s (branch: %s)\n", gitURL, p.GitBranch)
-
-	repo := client.Git(gitURL, dagger.GitOpts{
-		KeepGitDir:       true,
-		HTTPAuthToken:    crPAT,
-		HTTPAuthUsername: p.GitAuthUser,
-	})
-
-	source := repo.Branch(p.GitBranch).Tree()
-
-	commitSHA, err := repo.Branch(p.GitBranch).Commit(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get commit SHA: %w", err)
+	pipeline.PrintOptionFlags(os.Stdout, optionFlags)
+	for _, s := range pipeline.SuggestOptions(os.Environ()) {
+		warnings.Fprintf(os.Stdout, "unknown-option", "⚠️  Unknown option %s — did you mean %s?\n", s.Env, s.Suggestion)
 	}
-	fmt.Printf("   Commit: %s\n", commitSHA[:min(12, len(commitSHA))])
+	profile := applyProfile()
+	optionSources := pipeline.ResolveOptionSources(pipeline.BinaryStandard, os.LookupEnv, os.Environ(), profile, configFile, setFlags(optionFlags))
 
-	// ── Discover project name from pyproject.toml ────────────────
-	fmt.Println("🔍 Discovering project name from pyproject.toml...")
-
-	pyprojectContent, err := source.File("pyproject.toml").Contents(ctx)
+	env, err := pipeline.ConfigFromEnv(pipeline.BinaryStandard, os.LookupEnv, os.Environ(), os.Stdout, warnings)
 	if err != nil {
-		return fmt.Errorf("failed to read pyproject.toml: %w", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.CategoryOf(err).ExitCode())
+	}
+	cfg := env.Config
+	cfg.Profile = profile
+	cfg.OptionSources = optionSources
+	cfg.Paranoid = cfg.Paranoid || *paranoid
+
+	// Check required environment variables — registry credentials only matter when publishing
+	if !warmup && !cleanup && !watch {
+		if err := env.CheckRequired(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
+		}
 	}
 
-	projectName := extractProjectName(pyprojectContent)
-	if projectName == "" {
-		projectName = p.RepoName
-		fmt.Printf("   ⚠️  Could not parse name from pyproject.toml, using repo name: %s\n", projectName)
+	fmt.Println("🚀 Starting Python CI/CD Pipeline (Go SDK v0.19.7)...")
+	fmt.Printf("   Git Host:  %s\n", cfg.GitHost)
+	fmt.Printf("   Registry:  %s\n", cfg.Registry)
+	fmt.Printf("   User:      %s\n", cfg.GitUser)
+	if cfg.GitTag != "" {
+		fmt.Printf("   Tag:       %s\n", cfg.GitTag)
 	} else {
-		fmt.Printf("   Project name: %s\n", projectName)
+		fmt.Printf("   Branch:    %s\n", cfg.GitBranch)
+	}
+	fmt.Printf("🔑 Credentials: %s — %s\n", env.Credentials.Source, env.Credentials.Reason)
+	pipeline.PrintTestConfiguration(os.Stdout, cfg)
+
+	// Initialize Dagger client — local engine, or DAGGER_RUNNER_HOST when set
+	if cfg.RunnerHost.IsSet() {
+		fmt.Printf("🔌 Dagger engine: %s\n", cfg.RunnerHost.Raw)
+		if cfg.RunnerHost.Remote() {
+			fmt.Println("   ℹ️  Remote engine — host-run tests and Docker socket checks still run on this machine;")
+			fmt.Println("      local directories and files are uploaded to the engine, so large trees take longer")
+		}
+	}
+	connectStart := time.Now()
+	var daggerLog io.Writer = os.Stderr
+	if compact != nil {
+		daggerLog = compact
+	}
+	client, err := pipeline.Connect(ctx, cfg.RunnerHost, daggerLog)
+	if err != nil {
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to create Dagger client (%s): %v\n", category, err)
+		os.Exit(category.ExitCode())
 	}
-	p.ProjectName = projectName
+	defer client.Close()
+	cfg.ConnectTime = time.Since(connectStart)
 
-	if p.ImageName == "" {
-		p.ImageName = dockerSafeName(projectName)
+	daggerVersions, err := pipeline.VerifyEngineVersion(ctx, client, os.Stdout, parseEnvBool("STRICT_ENGINE_VERSION", false))
+	if err != nil {
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(category.ExitCode())
 	}
 
-	// ── Check Docker availability for testcontainers ─────────────
-	needsDocker := p.RunIntegrationTests || p.RunAcceptanceTests
-	if needsDocker {
-		fmt.Println("🔍 Checking Docker availability for testcontainers...")
-		hostDockerPath := getDockerSocketPath()
-		if hostDockerPath != "" {
-			p.HasDocker = true
-			fmt.Printf("   ✅ Docker socket detected: %s\n", hostDockerPath)
-		} else {
-			p.HasDocker = false
-			fmt.Printf("   ⚠️  Docker socket NOT available (OS: %s)\n", runtime.GOOS)
-			if p.RunIntegrationTests {
-				fmt.Println("   Integration tests will be SKIPPED (require Docker)")
-			}
-			if p.RunAcceptanceTests {
-				fmt.Println("   Acceptance tests will be SKIPPED (require Docker)")
-			}
-		}
+	// One HTTP client for every host-side call (proxy from HTTP(S)_PROXY)
+	if cfg.HTTPClient, err = pipeline.NewHTTPClient(pipeline.HTTPClientConfig{}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
 	}
 
-	// ── Set up build environment (Dagger container) ──────────────
-	fmt.Println("🔨 Setting up Python build environment...")
-
-	p.PipCache = client.CacheVolume("pip-cache-" + dockerSafeName(p.RepoName))
-
-	builder := client.Container().
-		From(baseImage).
-		WithExec([]string{"apt-get", "update"}).
-		WithExec([]string{"apt-get", "install", "-y", "--no-install-recommends",
-			"git", "build-essential", "libpq-dev"}).
-		WithExec([]string{"rm", "-rf", "/var/lib/apt/lists/*"}).
-		WithMountedCache("/root/.cache/pip", p.PipCache).
-		WithMountedDirectory(appWorkdir, source).
-		WithWorkdir(appWorkdir).
-		WithExec([]string{"pip", "install", "--upgrade", "pip", "setuptools", "wheel"})
-
-	// Install the local framework dependency first, then the project with dev+server extras
-	builder = builder.
-		WithExec([]string{"pip", "install", "-e", "./python_framework"}).
-		WithExec([]string{"pip", "install", "-e", ".[dev,server]"})
-
-	stageNum := 0
-
-	// ── Stage: Unit Tests (inside Dagger container) ──────────────
-	if p.RunUnitTests {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: UNIT TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Dagger container (isolated, no Docker needed)")
-		fmt.Println("🧪 Running: pytest -m \"not integration and not acceptance\"")
-		fmt.Println(separatorLine)
-
-		testContainer := builder.WithExec([]string{
-			"pytest", "-v", "--tb=short",
-			"-m", "not integration and not acceptance",
-		})
-
-		testOutput, err := testContainer.Stdout(ctx)
+	if cleanup {
+		maxAge, err := pipeline.ParseCacheMaxAge(os.Getenv("CACHE_MAX_AGE"))
 		if err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: UNIT TESTS\n", stageNum)
-			return fmt.Errorf("unit tests failed: %w", err)
-		}
-		fmt.Println(testOutput)
-		fmt.Printf("✅ STAGE %d COMPLETE: All unit tests passed\n", stageNum)
-
-		builder = testContainer
-	}
-
-	// ── Stage: Integration Tests (on host — testcontainers needs Docker) ──
-	if p.RunIntegrationTests && p.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: INTEGRATION TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Host machine (testcontainers requires native Docker)")
-		fmt.Println("🐘 PostgreSQL: Testcontainers will start a real PostgreSQL instance")
-		fmt.Println("🧪 Running: pytest -v --tb=short -m integration")
-		fmt.Println(separatorLine)
-
-		if err := p.runTestsOnHost(ctx, "integration"); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: INTEGRATION TESTS\n", stageNum)
-			return fmt.Errorf("integration tests failed: %w", err)
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(pipeline.ExitConfig)
 		}
-		fmt.Printf("✅ STAGE %d COMPLETE: All integration tests passed\n", stageNum)
-	} else if p.RunIntegrationTests && !p.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: INTEGRATION TESTS — SKIPPED\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("   ⏭️  Docker not available — testcontainers cannot start PostgreSQL")
-	}
-
-	// ── Stage: Acceptance Tests (on host — testcontainers needs Docker) ──
-	if p.RunAcceptanceTests && p.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: ACCEPTANCE TESTS\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("📍 Location: Host machine (testcontainers requires native Docker)")
-		fmt.Println("🐘 PostgreSQL: Testcontainers will start a real PostgreSQL instance")
-		fmt.Println("📦 Fixtures: Real ICAO .bin/.der fixtures used for end-to-end verification")
-		fmt.Println("🧪 Running: pytest -v --tb=short -m acceptance")
-		fmt.Println(separatorLine)
-
-		if err := p.runTestsOnHost(ctx, "acceptance"); err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: ACCEPTANCE TESTS\n", stageNum)
-			return fmt.Errorf("acceptance tests failed: %w", err)
+		if _, err := pipeline.Cleanup(ctx, client, pipeline.CleanupConfig{
+			MaxAge:       maxAge,
+			Apply:        parseEnvBool("CLEANUP_APPLY", false),
+			EnginePrune:  parseEnvBool("CLEANUP_ENGINE_PRUNE", false),
+			DiskPaths:    pipeline.DiskPaths(cfg.EngineStateDir, cfg.ArtifactsDir),
+			MinFreeSpace: cfg.MinFreeSpace,
+		}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Cleanup failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-		fmt.Printf("✅ STAGE %d COMPLETE: All acceptance tests passed\n", stageNum)
-	} else if p.RunAcceptanceTests && !p.HasDocker {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: ACCEPTANCE TESTS — SKIPPED\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("   ⏭️  Docker not available — testcontainers cannot start PostgreSQL")
-	}
-
-	// ── Stage: Lint ──────────────────────────────────────────────
-	if p.RunLint {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: LINT (ruff)\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("🔍 Running ruff check src/ tests/...")
-
-		lintContainer := builder.WithExec([]string{
-			"ruff", "check", "src/", "tests/",
-		})
-
-		_, err = lintContainer.Stdout(ctx)
-		if err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: LINT\n", stageNum)
-			return fmt.Errorf("ruff lint failed: %w", err)
-		}
-		fmt.Printf("✅ STAGE %d COMPLETE: Lint passed\n", stageNum)
-		builder = lintContainer
+		return
 	}
 
-	// ── Stage: Type Check ────────────────────────────────────────
-	if p.RunTypeCheck {
-		stageNum++
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("PIPELINE STAGE %d: TYPE CHECK (mypy)\n", stageNum)
-		fmt.Println(strings.Repeat("=", 80))
-		fmt.Println("🔍 Running mypy src/ --strict...")
-
-		typeContainer := builder.WithExec([]string{
-			"mypy", "src/", "--strict",
-		})
-
-		_, err = typeContainer.Stdout(ctx)
-		if err != nil {
-			fmt.Printf("\n❌ PIPELINE FAILED AT STAGE %d: TYPE CHECK\n", stageNum)
-			return fmt.Errorf("mypy type check failed: %w", err)
+	if warmup {
+		if _, err := pipeline.Warmup(ctx, client, pipeline.WarmupConfig{
+			Requirements:     os.Getenv("WARMUP_REQUIREMENTS"),
+			ToolImages:       cfg.ToolImages,
+			BaseImage:        cfg.BaseImage,
+			AptPackages:      cfg.AptPackages,
+			ExtraAptPackages: cfg.ExtraAptPackages,
+			SkipDefaultApt:   cfg.SkipDefaultApt,
+			BaseImageMirror:  cfg.BaseImageMirror,
+			DockerHubUser:    cfg.DockerHubUser,
+			DockerHubToken:   cfg.DockerHubToken,
+		}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Warmup failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
 		}
-		fmt.Printf("✅ STAGE %d COMPLETE: Type check passed\n", stageNum)
+		return
 	}
 
-	// ── Stage: Docker Build ──────────────────────────────────────
-	stageNum++
-	fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-	fmt.Printf("PIPELINE STAGE %d: BUILD DOCKER IMAGE\n", stageNum)
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println("🐳 Building Docker image from Dockerfile...")
-
-	image := source.DockerBuild()
-
-	shortSHA := commitSHA
-	if len(commitSHA) > 7 {
-		shortSHA = commitSHA[:7]
+	// Prompt before risky operations only when a person is at the terminal
+	if pipeline.IsInteractive(os.Stdin, os.Getenv) {
+		cfg.Confirm = (&pipeline.Prompter{In: os.Stdin, Out: os.Stdout}).Confirm
 	}
-	timestamp := time.Now().Format("20060102-1504")
-	imageTag := fmt.Sprintf("v0.1.0-%s-%s", shortSHA, timestamp)
-
-	imageNameClean := dockerSafeName(p.ImageName)
-	userLower := strings.ToLower(p.GitUser)
-	versionedImage := fmt.Sprintf("%s/%s/%s:%s", p.Registry, userLower, imageNameClean, imageTag)
-	latestImage := fmt.Sprintf("%s/%s/%s:latest", p.Registry, userLower, imageNameClean)
-
-	fmt.Printf("   Image: %s\n", versionedImage)
-	fmt.Printf("✅ STAGE %d COMPLETE: Docker image built\n", stageNum)
 
-	// ── Stage: Publish to Registry ───────────────────────────────
-	stageNum++
-	fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-	fmt.Printf("PIPELINE STAGE %d: PUBLISH TO REGISTRY\n", stageNum)
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Printf("📤 Publishing to: %s\n", versionedImage)
-
-	password := client.SetSecret("password", os.Getenv("CR_PAT"))
-
-	publishedAddress, err := image.
-		WithRegistryAuth(p.Registry, p.GitUser, password).
-		Publish(ctx, versionedImage)
-	if err != nil {
-		return fmt.Errorf("failed to publish versioned image: %w", err)
+	// Docker on the host is only needed for the testcontainers stages
+	if (cfg.RunIntegrationTests || cfg.RunAcceptanceTests) && !watch {
+		fmt.Println("🔍 Checking Docker availability for testcontainers...")
+		if sock := getDockerSocketPath(); sock != "" {
+			cfg.HasDocker = true
+			cfg.DockerDetection = "socket " + sock
+			fmt.Printf("   ✅ Docker socket detected: %s\n", sock)
+		} else {
+			cfg.DockerDetection = fmt.Sprintf("no Docker socket found on %s, DOCKER_HOST=%q", runtime.GOOS, os.Getenv("DOCKER_HOST"))
+			warnings.Fprintf(os.Stdout, "docker-missing", "   ⚠️  Docker socket NOT available (OS: %s)\n", runtime.GOOS)
+		}
 	}
 
-	latestAddress, err := image.
-		WithRegistryAuth(p.Registry, p.GitUser, password).
-		Publish(ctx, latestImage)
-	if err != nil {
-		return fmt.Errorf("failed to publish latest image: %w", err)
+	if compact != nil {
+		cfg.Output = compact
 	}
 
-	fmt.Printf("✅ STAGE %d COMPLETE: Images published\n", stageNum)
-	fmt.Printf("   📦 Versioned: %s\n", publishedAddress)
-	fmt.Printf("   📦 Latest:    %s\n", latestAddress)
-
-	return nil
-}
+	// Watch: the unit tests and ruff of the same configuration, re-run on every change
+	if watch {
+		if err := pipeline.Watch(ctx, client, cfg, pipeline.WatchOptions{}); err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Watch failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
+		}
+		return
+	}
 
-// ── Host-based test execution ────────────────────────────────────
+	// Monorepo: one run per discovered project, plus a combined summary
+	if env.Projects.Glob != "" {
+		ci := pipeline.DetectCI(os.Getenv)
+		if ci != nil {
+			fmt.Printf("🧩 CI system detected: %s — stage output grouped into collapsible sections\n", ci.Name)
+		}
+		projects := env.Projects
+		projects.Dagger = &daggerVersions
+		projects.Attach = func(p *pipeline.Pipeline) {
+			if ci != nil {
+				ci.Attach(p.Tracker(), os.Stdout)
+			}
+			if compact != nil {
+				compact.Attach(p.Tracker(), pipeline.PlannedStages(cfg))
+			}
+		}
+		_, err := pipeline.RunProjects(ctx, client, cfg, projects)
+		if compact != nil {
+			compact.Close(err)
+			restoreStdout()
+		}
+		if err != nil {
+			category := pipeline.CategoryOf(err)
+			fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed (%s): %v\n", category, err)
+			os.Exit(category.ExitCode())
+		}
+		fmt.Println("\n🎉 Pipeline completed successfully for all projects!")
+		return
+	}
 
-// runTestsOnHost executes pytest with a specific marker on the HOST machine
-// (not inside the Dagger container). This is necessary for integration and
-// acceptance tests that use testcontainers, because testcontainers requires
-// native Docker socket access — Docker-in-Docker path mismatches inside Dagger
-// containers prevent testcontainers from binding volumes correctly.
-//
-// Requires: the project's virtualenv to be installed on the host machine.
-// The function auto-discovers the project root (parent of dagger_go/) and
-// runs pytest from there using the local .venv.
-func (p *Pipeline) runTestsOnHost(ctx context.Context, marker string) error {
-	cwd, err := os.Getwd()
+	p, err := pipeline.New(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.CategoryOf(err).ExitCode())
 	}
 
-	// When running from dagger_go/, the project root is ../
-	projectRoot := cwd + "/.."
+	if ci := pipeline.DetectCI(os.Getenv); ci != nil {
+		fmt.Printf("🧩 CI system detected: %s — stage output grouped into collapsible sections\n", ci.Name)
+		ci.Attach(p.Tracker(), os.Stdout)
+	}
 
-	fmt.Println("⚙️  Configuration:")
-	fmt.Printf("   • Project root: %s\n", projectRoot)
-	fmt.Printf("   • Marker: %s\n", marker)
-	fmt.Printf("   • Command: pytest -v --tb=short -m %s\n", marker)
-	fmt.Println("")
+	if compact != nil {
+		compact.Attach(p.Tracker(), pipeline.PlannedStages(cfg))
+	}
 
-	// Determine pytest executable — prefer .venv/bin/pytest, fall back to PATH
-	pytestBin := projectRoot + "/.venv/bin/pytest"
-	if _, err := os.Stat(pytestBin); err != nil {
-		// Try system pytest
-		pytestBin = "pytest"
-		fmt.Println("   ⚠️  .venv not found, using system pytest")
+	report, err := p.Run(ctx, client)
+	if compact != nil {
+		compact.Close(err)
+		restoreStdout()
+	}
+	report.Dagger = &daggerVersions
+	if path, werr := pipeline.WriteReport(cfg.ArtifactsDir, report); werr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not write pipeline report: %v\n", werr)
 	} else {
-		fmt.Printf("   • Using: %s\n", pytestBin)
+		fmt.Printf("📝 Pipeline report: %s\n", path)
+	}
+	if cfg.HTMLReport {
+		if path, werr := pipeline.WriteHTMLReport(cfg.ArtifactsDir, report); werr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write HTML report: %v\n", werr)
+		} else {
+			fmt.Printf("📝 HTML report: %s\n", path)
+		}
 	}
-
-	cmd := exec.CommandContext(ctx, pytestBin, "-v", "--tb=short", "-m", marker)
-	cmd.Dir = projectRoot
-
-	// Capture output while streaming to stdout
-	var outputBuffer strings.Builder
-	multiWriter := io.MultiWriter(os.Stdout, &outputBuffer)
-	cmd.Stdout = multiWriter
-	cmd.Stderr = os.Stderr
-
-	start := time.Now()
-	err = cmd.Run()
-	duration := time.Since(start)
-
-	fmt.Println(separatorLine)
-	displayHostTestSummary(marker, outputBuffer.String(), duration, err)
-
 	if err != nil {
-		return fmt.Errorf("%s tests failed: %w", marker, err)
+		category := pipeline.CategoryOf(err)
+		fmt.Fprintf(os.Stderr, "ERROR: Pipeline failed (%s): %v\n", category, err)
+		os.Exit(category.ExitCode())
 	}
-	return nil
-}
 
-// displayHostTestSummary parses pytest output and shows a summary
-func displayHostTestSummary(marker string, output string, duration time.Duration, testErr error) {
-	// Parse pytest summary line: "X passed, Y failed, Z errors in Ns"
-	summaryPattern := regexp.MustCompile(`(\d+) passed`)
-	failedPattern := regexp.MustCompile(`(\d+) failed`)
-	errorPattern := regexp.MustCompile(`(\d+) error`)
-
-	lines := strings.Split(output, "\n")
-	var summaryLine string
-	for _, line := range lines {
-		if strings.Contains(line, "passed") || strings.Contains(line, "failed") {
-			if strings.Contains(line, "====") {
-				summaryLine = line
-			}
-		}
+	budgetRef := cfg.GitBranch
+	if cfg.GitTag != "" {
+		budgetRef = cfg.GitTag
 	}
-
-	fmt.Printf("\n📊 %s Test Summary\n", strings.ToUpper(marker[:1])+marker[1:])
-	fmt.Println(separatorLine)
-
-	if summaryLine != "" {
-		passed := "0"
-		failed := "0"
-		errors := "0"
-		if m := summaryPattern.FindStringSubmatch(summaryLine); m != nil {
-			passed = m[1]
-		}
-		if m := failedPattern.FindStringSubmatch(summaryLine); m != nil {
-			failed = m[1]
-		}
-		if m := errorPattern.FindStringSubmatch(summaryLine); m != nil {
-			errors = m[1]
-		}
-		fmt.Printf("   Passed: %s | Failed: %s | Errors: %s | Duration: %v\n",
-			passed, failed, errors, duration.Round(time.Millisecond))
-	} else {
-		fmt.Printf("   Duration: %v\n", duration.Round(time.Millisecond))
+	if err := pipeline.CheckDurationBudget(os.Stdout, report.Status, budgetRef, env.DurationBudget, env.DurationHistoryFile); err != nil && env.DurationBudgetHard {
+		fmt.Fprintf(os.Stderr, "ERROR: %v (DURATION_BUDGET_HARD=true)\n", err)
+		os.Exit(pipeline.ExitBudget)
 	}
 
-	if testErr != nil {
-		fmt.Printf("   ❌ FAILED: %s tests failed after %v\n", marker, duration.Round(time.Millisecond))
-	} else {
-		fmt.Printf("   ✅ SUCCESS: %s tests passed in %v\n", marker, duration.Round(time.Millisecond))
+	fmt.Println("\n🎉 Pipeline completed successfully!")
+	if len(report.PublishedImages) == 0 {
+		fmt.Println("   ℹ️  No image was published (RUN_BUILD/RUN_PUBLISH disabled)")
 	}
-	fmt.Println("")
 }
 
 // ── Docker socket detection ─────────────────────────────────────
@@ -549,27 +686,47 @@ func getDockerSocketPath() string {
 
 // ── Helpers ──────────────────────────────────────────────────────
 
-// extractProjectName parses the `name = "..."` field from pyproject.toml content.
-func extractProjectName(content string) string {
-	re := regexp.MustCompile(`(?m)^name\s*=\s*"([^"]+)"`)
-	matches := re.FindStringSubmatch(content)
-	if len(matches) >= 2 {
-		return matches[1]
+// applyConfigFile loads PIPELINE_CONFIG (default pipeline.yaml when
+// present) and exports its options for the variables left unset, before
+// anything reads them.
+func applyConfigFile() *pipeline.ConfigFile {
+	configFile, err := pipeline.ResolveConfigFile(os.LookupEnv)
+	if err == nil && configFile != nil {
+		err = configFile.Apply(os.LookupEnv, os.Setenv)
 	}
-	return ""
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	return configFile
 }
 
-// dockerSafeName converts a project name to a Docker-safe image name.
-func dockerSafeName(name string) string {
-	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+// applyProfile resolves PIPELINE_PROFILE, exports its defaults for the
+// variables left unset so the usual parsing picks them up, and prints the
+// resolved settings.
+func applyProfile() *pipeline.ProfileRecord {
+	profile, err := pipeline.ResolveProfile(os.Getenv(pipeline.ProfileEnv), os.LookupEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(pipeline.ExitConfig)
+	}
+	for _, s := range profile.Settings {
+		if s.Source == pipeline.SourceProfile {
+			os.Setenv(s.Name, s.Value)
+		}
+	}
+	pipeline.PrintProfile(os.Stdout, profile)
+	return profile
 }
 
-// envOrDefault returns the value of an environment variable, or a default.
-func envOrDefault(key, defaultValue string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return defaultValue
+// setFlags are the command-line flags given, by name, for
+// pipeline.ResolveOptionSources; option flags and their aliases are keyed
+// by the option they set.
+func setFlags(optionFlags *pipeline.OptionFlags) map[string]string {
+	flags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	maps.Copy(flags, optionFlags.Values())
+	return flags
 }
 
 // parseEnvBool parses boolean environment variables with default value.
@@ -582,11 +739,3 @@ func parseEnvBool(envVar string, defaultValue bool) bool {
 	lowerValue := strings.ToLower(value)
 	return lowerValue == "true" || lowerValue == "1" || lowerValue == "yes"
 }
-
-// min returns the minimum of two integers.
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
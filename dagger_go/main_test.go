@@ -1,15 +1,21 @@
+//go:build !corporate
+
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"runtime"
 	"testing"
+
+	"cert-parser-dagger-go/pipeline"
 )
 
 // TestRepositoryConfiguration tests repository configuration
 func TestRepositoryConfiguration(t *testing.T) {
-	pipeline := &Pipeline{
+	cfg := &pipeline.Config{
 		RepoName:            "cert-parser",
 		GitRepo:             "https://github.com/test/cert-parser.git",
 		GitBranch:           "main",
@@ -24,49 +30,52 @@ func TestRepositoryConfiguration(t *testing.T) {
 		RunTypeCheck:        true,
 	}
 
-	if pipeline.RepoName == "" {
+	if cfg.RepoName == "" {
 		t.Fatal("RepoName is empty")
 	}
-	if pipeline.GitRepo == "" {
+	if cfg.GitRepo == "" {
 		t.Fatal("GitRepo is empty")
 	}
-	if pipeline.GitBranch == "" {
+	if cfg.GitBranch == "" {
 		t.Fatal("GitBranch is empty")
 	}
-	if pipeline.GitHost == "" {
+	if cfg.GitHost == "" {
 		t.Fatal("GitHost is empty")
 	}
-	if pipeline.Registry == "" {
+	if cfg.Registry == "" {
 		t.Fatal("Registry is empty")
 	}
 
-	fmt.Printf("✅ Repository config valid: %s @ %s → %s\n", pipeline.RepoName, pipeline.GitHost, pipeline.Registry)
+	fmt.Printf("✅ Repository config valid: %s @ %s → %s\n", cfg.RepoName, cfg.GitHost, cfg.Registry)
 }
 
-// TestPipelineDefaultFlags tests that Pipeline flags default to zero values
+// TestPipelineDefaultFlags tests that Config flags default to zero values
 func TestPipelineDefaultFlags(t *testing.T) {
-	pipeline := &Pipeline{}
+	cfg := &pipeline.Config{}
 
 	// Go zero values — bool defaults to false
-	if pipeline.RunUnitTests {
+	if cfg.RunUnitTests {
 		t.Fatal("RunUnitTests should default to false (zero value)")
 	}
-	if pipeline.RunIntegrationTests {
+	if cfg.RunIntegrationTests {
 		t.Fatal("RunIntegrationTests should default to false (zero value)")
 	}
-	if pipeline.RunAcceptanceTests {
+	if cfg.RunAcceptanceTests {
 		t.Fatal("RunAcceptanceTests should default to false (zero value)")
 	}
-	if pipeline.HasDocker {
+	if cfg.HasDocker {
 		t.Fatal("HasDocker should default to false (zero value)")
 	}
+	if cfg.RunBuild || cfg.RunPublish {
+		t.Fatal("RunBuild/RunPublish should default to false (zero value)")
+	}
 
 	fmt.Println("✅ Pipeline flags correctly default to zero values")
 }
 
 // TestPipelineConfigurableFlags tests explicit flag configuration
 func TestPipelineConfigurableFlags(t *testing.T) {
-	pipeline := &Pipeline{
+	cfg := &pipeline.Config{
 		RepoName:            "cert-parser",
 		RunUnitTests:        true,
 		RunIntegrationTests: false,
@@ -76,19 +85,19 @@ func TestPipelineConfigurableFlags(t *testing.T) {
 		HasDocker:           true,
 	}
 
-	if !pipeline.RunUnitTests {
+	if !cfg.RunUnitTests {
 		t.Fatal("RunUnitTests should be true")
 	}
-	if pipeline.RunIntegrationTests {
+	if cfg.RunIntegrationTests {
 		t.Fatal("RunIntegrationTests should be false")
 	}
-	if !pipeline.RunAcceptanceTests {
+	if !cfg.RunAcceptanceTests {
 		t.Fatal("RunAcceptanceTests should be true")
 	}
-	if !pipeline.HasDocker {
+	if !cfg.HasDocker {
 		t.Fatal("HasDocker should be true")
 	}
-	if pipeline.RunTypeCheck {
+	if cfg.RunTypeCheck {
 		t.Fatal("RunTypeCheck should be false")
 	}
 
@@ -110,21 +119,21 @@ func TestEnvironmentVariables(t *testing.T) {
 
 // TestImageNaming tests Docker image naming logic with configurable registry
 func TestImageNaming(t *testing.T) {
-	pipeline := &Pipeline{
+	cfg := &pipeline.Config{
 		ImageName: "cert-parser",
 		GitUser:   "javier-godon",
 		Registry:  "ghcr.io",
 	}
 
-	imageName := fmt.Sprintf("%s/%s/%s:v0.1.0", pipeline.Registry, pipeline.GitUser, pipeline.ImageName)
+	imageName := fmt.Sprintf("%s/%s/%s:v0.1.0", cfg.Registry, cfg.GitUser, cfg.ImageName)
 
 	if imageName == "" {
 		t.Fatal("Image name is empty")
 	}
-	if !contains(imageName, pipeline.Registry) {
+	if !contains(imageName, cfg.Registry) {
 		t.Fatal("Image name should contain registry")
 	}
-	if !contains(imageName, pipeline.ImageName) {
+	if !contains(imageName, cfg.ImageName) {
 		t.Fatal("Image name should contain image name")
 	}
 
@@ -133,13 +142,13 @@ func TestImageNaming(t *testing.T) {
 
 // TestImageNamingCustomRegistry tests Docker image naming with a custom registry
 func TestImageNamingCustomRegistry(t *testing.T) {
-	pipeline := &Pipeline{
+	cfg := &pipeline.Config{
 		ImageName: "cert-parser",
 		GitUser:   "mygroup",
 		Registry:  "registry.gitlab.com",
 	}
 
-	imageName := fmt.Sprintf("%s/%s/%s:v0.1.0", pipeline.Registry, pipeline.GitUser, pipeline.ImageName)
+	imageName := fmt.Sprintf("%s/%s/%s:v0.1.0", cfg.Registry, cfg.GitUser, cfg.ImageName)
 
 	if !contains(imageName, "registry.gitlab.com") {
 		t.Fatalf("Image name should contain custom registry, got: %s", imageName)
@@ -161,12 +170,12 @@ func TestGitRepositoryURL(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		pipeline := &Pipeline{
+		cfg := &pipeline.Config{
 			GitHost:  tc.gitHost,
 			GitUser:  tc.username,
 			RepoName: tc.repoName,
 		}
-		gitRepo := fmt.Sprintf("https://%s/%s/%s.git", pipeline.GitHost, pipeline.GitUser, pipeline.RepoName)
+		gitRepo := fmt.Sprintf("https://%s/%s/%s.git", cfg.GitHost, cfg.GitUser, cfg.RepoName)
 
 		if !contains(gitRepo, tc.gitHost) {
 			t.Fatalf("Git repository URL should contain %s, got: %s", tc.gitHost, gitRepo)
@@ -186,7 +195,7 @@ name = "cert-parser"
 version = "0.1.0"
 description = "ICAO Master List certificate parser"
 `
-	name := extractProjectName(content)
+	name := pipeline.ExtractProjectName(content)
 	if name != "cert-parser" {
 		t.Fatalf("Expected 'cert-parser', got '%s'", name)
 	}
@@ -198,7 +207,7 @@ func TestExtractProjectNameMissing(t *testing.T) {
 	content := `[project]
 version = "0.1.0"
 `
-	name := extractProjectName(content)
+	name := pipeline.ExtractProjectName(content)
 	if name != "" {
 		t.Fatalf("Expected empty string, got '%s'", name)
 	}
@@ -216,9 +225,9 @@ func TestDockerSafeName(t *testing.T) {
 		{"My_Project", "my-project"},
 	}
 	for _, tc := range tests {
-		result := dockerSafeName(tc.input)
+		result := pipeline.DockerSafeName(tc.input)
 		if result != tc.expected {
-			t.Fatalf("dockerSafeName(%q) = %q, want %q", tc.input, result, tc.expected)
+			t.Fatalf("pipeline.DockerSafeName(%q) = %q, want %q", tc.input, result, tc.expected)
 		}
 	}
 	fmt.Println("✅ Docker-safe naming works correctly")
@@ -287,6 +296,33 @@ func TestGetDockerSocketPath(t *testing.T) {
 	}
 }
 
+// optionFlagErrorArgs are command lines whose option flags are rejected,
+// by name for TestOptionFlagErrorsExitConfig.
+var optionFlagErrorArgs = map[string][]string{
+	"bad value":      {"--skip-lint=maybe"},
+	"unknown option": {"--no-such-option"},
+	"apply fails":    {"--branch=main\x00"}, // setenv rejects the NUL byte
+}
+
+// TestOptionFlagErrorsExitConfig tests that rejected option flags exit with the config error code
+func TestOptionFlagErrorsExitConfig(t *testing.T) {
+	if name := os.Getenv("TEST_OPTION_FLAG_ARGS"); name != "" {
+		os.Args = append([]string{os.Args[0]}, optionFlagErrorArgs[name]...)
+		main()
+		os.Exit(0)
+	}
+	for name := range optionFlagErrorArgs {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestOptionFlagErrorsExitConfig$")
+		cmd.Env = append(os.Environ(), "TEST_OPTION_FLAG_ARGS="+name)
+		out, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != pipeline.ExitConfig {
+			t.Fatalf("%s: expected exit code %d, got %v\n%s", name, pipeline.ExitConfig, err, out)
+		}
+	}
+	fmt.Println("✅ Rejected option flags exit with the config error code")
+}
+
 // Helper function to check if string contains substring
 func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
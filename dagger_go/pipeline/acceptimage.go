@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// Environment variables handed to acceptance tests that run against the
+// built image. The conftest fixtures call ACCEPTANCE_BASE_URL instead of
+// starting the application in-process when ACCEPTANCE_TARGET is "image".
+const (
+	AcceptanceTargetEnv  = "ACCEPTANCE_TARGET"
+	AcceptanceBaseURLEnv = "ACCEPTANCE_BASE_URL"
+	AcceptanceImageEnv   = "ACCEPTANCE_IMAGE"
+)
+
+// Acceptance modes recorded in the report.
+const (
+	AcceptanceEditable      = "editable install"
+	AcceptanceDockerRun     = "built image (docker run)"
+	AcceptanceDaggerService = "built image (Dagger service)"
+)
+
+// AcceptanceImageLogFile receives the image's output when acceptance tests
+// against it fail.
+const AcceptanceImageLogFile = "acceptance-image.log"
+
+// AcceptanceStartTimeout bounds how long the image may take to become ready
+// (default of ServiceReadyTimeout).
+const AcceptanceStartTimeout = 60 * time.Second
+
+var dockerLoadPattern = regexp.MustCompile(`(?m)^Loaded image(?: ID)?: (\S+)`)
+
+// ParseDockerLoad returns the image reference or ID `docker load` printed.
+func ParseDockerLoad(output string) string {
+	if m := dockerLoadPattern.FindAllStringSubmatch(output, -1); len(m) > 0 {
+		return m[len(m)-1][1]
+	}
+	return ""
+}
+
+// ParseDockerPort turns `docker port` output ("0.0.0.0:49153", "[::]:49153")
+// into a host address reachable from this machine.
+func ParseDockerPort(output string) string {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		if host == "0.0.0.0" || host == "::" || host == "" {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, port)
+	}
+	return ""
+}
+
+// acceptanceTarget is the running image the acceptance tests call.
+type acceptanceTarget struct {
+	mode      string
+	baseURL   string
+	image     string          // local image ID (docker run)
+	container string          // container ID (docker run)
+	tunnel    *dagger.Service // host tunnel (Dagger service)
+}
+
+// acceptanceEnv is the extra environment for host-run tests of marker.
+func (p *Pipeline) acceptanceEnv(marker string) []string {
+	if marker != acceptanceMarker || p.acceptance == nil {
+		return nil
+	}
+	return []string{
+		AcceptanceTargetEnv + "=image",
+		AcceptanceBaseURLEnv + "=" + p.acceptance.baseURL,
+		AcceptanceImageEnv + "=" + p.acceptance.image,
+	}
+}
+
+// imagePort is the port the acceptance tests reach the image on:
+// AcceptanceImagePort, or the first port the image exposes.
+func (p *Pipeline) imagePort(ctx context.Context, image *dagger.Container) (int, error) {
+	if p.cfg.AcceptanceImagePort > 0 {
+		return p.cfg.AcceptanceImagePort, nil
+	}
+	ports, err := image.ExposedPorts(ctx)
+	if err != nil {
+		return 0, Errorf(CategoryBuild, "inspect exposed ports: %w", err)
+	}
+	if len(ports) == 0 {
+		return 0, Errorf(CategoryConfig, "the image exposes no port; set ACCEPTANCE_IMAGE_PORT")
+	}
+	return ports[0].Port(ctx)
+}
+
+// startAcceptanceImage builds the image and starts it for the acceptance
+// tests: with `docker run` when Docker is available on the host, where the
+// tests run, and otherwise as a Dagger service tunnelled to the host. With
+// an external database, its URL is passed to the image as TEST_DATABASE_URL.
+func (p *Pipeline) startAcceptanceImage(ctx context.Context, client *dagger.Client, source *dagger.Directory) error {
+	p.println("🐳 Building the image for acceptance tests...")
+	image := p.BuildImage(source, "", p.labels)
+	if _, err := image.Sync(ctx); err != nil {
+		return Errorf(CategoryBuild, "docker build failed: %w", err)
+	}
+	port, err := p.imagePort(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	if !p.cfg.HasDocker {
+		if p.cfg.DatabaseURL != "" {
+			image = image.WithEnvVariable(TestDatabaseURLEnv, p.cfg.DatabaseURL)
+		}
+		tunnel, err := client.Host().Tunnel(image.AsService(), dagger.HostTunnelOpts{
+			Ports: []dagger.PortForward{{Backend: port}},
+		}).Start(ctx)
+		if err != nil {
+			return Errorf(CategoryBuild, "start image as a service: %w", err)
+		}
+		endpoint, err := tunnel.Endpoint(ctx, dagger.ServiceEndpointOpts{Scheme: "http"})
+		if err != nil {
+			tunnel.Stop(ctx)
+			return Errorf(CategoryBuild, "image service endpoint: %w", err)
+		}
+		p.acceptance = &acceptanceTarget{mode: AcceptanceDaggerService, baseURL: endpoint, tunnel: tunnel}
+		if err := p.waitUntilReady(ctx, endpoint); err != nil {
+			return err
+		}
+		p.printf("   ✅ Image running as a Dagger service: %s\n", endpoint)
+		return nil
+	}
+
+	if err := os.MkdirAll(p.cfg.ArtifactsDir, 0o755); err != nil {
+		return Errorf(CategoryBuild, "prepare image export: %w", err)
+	}
+	tarball, err := filepath.Abs(filepath.Join(p.cfg.ArtifactsDir, "acceptance-image.tar"))
+	if err != nil {
+		return Errorf(CategoryBuild, "prepare image export: %w", err)
+	}
+	defer os.Remove(tarball)
+	if _, err := image.Export(ctx, tarball, dagger.ContainerExportOpts{MediaTypes: dagger.ImageMediaTypesDocker}); err != nil {
+		return Errorf(CategoryBuild, "export image: %w", err)
+	}
+	out, err := exec.CommandContext(ctx, "docker", "load", "-i", tarball).CombinedOutput()
+	imageID := ParseDockerLoad(string(out))
+	if err != nil || imageID == "" {
+		return Errorf(CategoryBuild, "docker load: %v %s", err, strings.TrimSpace(string(out)))
+	}
+
+	args := []string{"run", "-d", "-p", fmt.Sprintf("127.0.0.1::%d", port)}
+	if p.cfg.DatabaseURL != "" {
+		args = append(args, "-e", TestDatabaseURLEnv) // value from this process, never on the command line
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(args, imageID)...)
+	if p.cfg.DatabaseURL != "" {
+		cmd.Env = append(os.Environ(), TestDatabaseURLEnv+"="+p.cfg.DatabaseURL)
+	}
+	out, err = cmd.Output()
+	if err != nil {
+		return Errorf(CategoryBuild, "docker run: %w", err)
+	}
+	target := &acceptanceTarget{mode: AcceptanceDockerRun, image: imageID, container: strings.TrimSpace(string(out))}
+	p.acceptance = target
+
+	out, err = exec.CommandContext(ctx, "docker", "port", target.container, strconv.Itoa(port)).Output()
+	address := ParseDockerPort(string(out))
+	if err != nil || address == "" {
+		return Errorf(CategoryBuild, "docker port %d of the acceptance container: %v", port, err)
+	}
+	target.baseURL = "http://" + address
+	if err := p.waitUntilReady(ctx, target.baseURL); err != nil {
+		return err
+	}
+	p.printf("   ✅ Image running (docker run %s): %s\n", shortID(target.container), target.baseURL)
+	return nil
+}
+
+// stopAcceptanceImage stops the image started for the acceptance tests.
+// When they failed, the image's output is saved as an artifact first.
+func (p *Pipeline) stopAcceptanceImage(ctx context.Context, failed bool) {
+	target := p.acceptance
+	if target == nil {
+		return
+	}
+	// Clean up even when the run was cancelled
+	ctx = context.WithoutCancel(ctx)
+	if target.tunnel != nil {
+		if failed {
+			p.println("   ℹ️  Image logs are not available from a Dagger service; they are in the Dagger trace")
+		}
+		target.tunnel.Stop(ctx)
+		return
+	}
+	if target.container == "" {
+		return
+	}
+	if failed {
+		logs, _ := exec.CommandContext(ctx, "docker", "logs", "--timestamps", target.container).CombinedOutput()
+		path := filepath.Join(p.cfg.ArtifactsDir, AcceptanceImageLogFile)
+		if err := WriteFileAtomic(path, logs); err != nil {
+			p.warnf("image-logs", "   ⚠️  Could not save the image logs: %v\n", err)
+		} else {
+			p.report.Artifacts = append(p.report.Artifacts, Artifact{Name: AcceptanceImageLogFile, Path: path, Size: int64(len(logs)), SHA256: sha256Hex(string(logs))})
+			p.printf("   📄 Image logs: %s\n", path)
+		}
+	}
+	exec.CommandContext(ctx, "docker", "rm", "-f", target.container).Run()
+}
+
+// shortID shortens a container or image ID for display.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	return id[:min(12, len(id))]
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestParseDockerLoad tests reading the loaded image from `docker load` output
+func TestParseDockerLoad(t *testing.T) {
+	cases := map[string]string{
+		"Loaded image ID: sha256:0123abcd\n":                "sha256:0123abcd",
+		"Loaded image: cert-parser:latest\n":                "cert-parser:latest",
+		"Loaded image: a:1\nLoaded image ID: sha256:beef\n": "sha256:beef",
+		"open /tmp/x.tar: no such file or directory":        "",
+	}
+	for output, want := range cases {
+		if got := ParseDockerLoad(output); got != want {
+			t.Fatalf("ParseDockerLoad(%q) = %q, want %q", output, got, want)
+		}
+	}
+	fmt.Println("✅ docker load output parsed")
+}
+
+// TestParseDockerPort tests turning `docker port` output into a reachable address
+func TestParseDockerPort(t *testing.T) {
+	cases := map[string]string{
+		"127.0.0.1:49153\n":           "127.0.0.1:49153",
+		"0.0.0.0:49153\n[::]:49153\n": "127.0.0.1:49153",
+		"[::]:49154\n":                "127.0.0.1:49154",
+		"":                            "",
+	}
+	for output, want := range cases {
+		if got := ParseDockerPort(output); got != want {
+			t.Fatalf("ParseDockerPort(%q) = %q, want %q", output, got, want)
+		}
+	}
+	fmt.Println("✅ docker port output parsed")
+}
+
+// TestAcceptanceEnv tests that only acceptance tests get the running image's address
+func TestAcceptanceEnv(t *testing.T) {
+	p := &Pipeline{}
+	if env := p.acceptanceEnv(acceptanceMarker); env != nil {
+		t.Fatalf("no image running should add nothing, got %v", env)
+	}
+	p.acceptance = &acceptanceTarget{mode: AcceptanceDockerRun, baseURL: "http://127.0.0.1:49153", image: "sha256:beef"}
+	if env := p.acceptanceEnv(integrationMarker); env != nil {
+		t.Fatalf("integration tests should not get the image, got %v", env)
+	}
+	env := p.acceptanceEnv(acceptanceMarker)
+	want := []string{"ACCEPTANCE_TARGET=image", "ACCEPTANCE_BASE_URL=http://127.0.0.1:49153", "ACCEPTANCE_IMAGE=sha256:beef"}
+	if fmt.Sprint(env) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", env, want)
+	}
+	fmt.Println("✅ Acceptance tests get the image address")
+}
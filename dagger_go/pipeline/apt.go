@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultAptPackages are installed in the build container unless
+// AptPackages replaces them or SkipDefaultApt is set: git for VCS
+// dependencies, a compiler toolchain for native wheels and the libpq headers
+// for psycopg.
+var DefaultAptPackages = []string{"git", "build-essential", "libpq-dev"}
+
+var (
+	// Debian package name, optionally with :arch and =version.
+	aptPackagePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+(:[a-z0-9-]+)?(=[A-Za-z0-9.+~:-]+)?$`)
+	aptUnlocatedPattern  = regexp.MustCompile(`(?m)^E: (?:Unable to locate package |Package '?)([^\s']+)`)
+	aptPackageSeparators = regexp.MustCompile(`[\s,]+`)
+)
+
+// ParseAptPackages splits a space- and/or comma-separated package list,
+// such as EXTRA_APT_PACKAGES="libxml2-dev, swig".
+func ParseAptPackages(list string) []string {
+	var packages []string
+	for _, name := range aptPackageSeparators.Split(list, -1) {
+		if name != "" {
+			packages = append(packages, name)
+		}
+	}
+	return packages
+}
+
+// validateAptPackages rejects names apt would never accept, before any
+// container is built.
+func validateAptPackages(field string, packages []string) error {
+	for _, name := range packages {
+		if !aptPackagePattern.MatchString(name) {
+			return fmt.Errorf("invalid apt package name %q in %s (lower-case letters, digits and + - . only)", name, field)
+		}
+	}
+	return nil
+}
+
+// validateBuildContainer checks the base image and the apt package lists of
+// the build container.
+func validateBuildContainer(cfg Config) error {
+	if cfg.BaseImage != "" {
+		if err := ValidateImageRef(cfg.BaseImage); err != nil {
+			return fmt.Errorf("BaseImage: %w", err)
+		}
+	}
+	if cfg.AptPackages != nil && cfg.SkipDefaultApt {
+		return errors.New("set AptPackages or SkipDefaultApt, not both (AptPackages already replaces the default packages)")
+	}
+	if err := validateAptPackages("AptPackages", cfg.AptPackages); err != nil {
+		return err
+	}
+	return validateAptPackages("ExtraAptPackages", cfg.ExtraAptPackages)
+}
+
+// aptPackages is the package list for the build container. ca-certificates
+// is added whenever CA certificates are mounted, whatever the lists say:
+// update-ca-certificates comes with it.
+func (p *Pipeline) aptPackages() []string {
+	var packages []string
+	switch {
+	case p.cfg.AptPackages != nil:
+		packages = append(packages, p.cfg.AptPackages...)
+	case !p.cfg.SkipDefaultApt:
+		packages = append(packages, DefaultAptPackages...)
+	}
+	packages = append(packages, p.cfg.ExtraAptPackages...)
+	if len(p.cfg.CACertPaths) > 0 && !slices.Contains(packages, "ca-certificates") {
+		packages = append(packages, "ca-certificates")
+	}
+	return packages
+}
+
+// unlocatedAptPackages returns the packages apt-get reported as unknown.
+func unlocatedAptPackages(stderr string) []string {
+	var names []string
+	for _, m := range aptUnlocatedPattern.FindAllStringSubmatch(stderr, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// aptInstallError turns a failed apt-get install into an error naming the
+// packages apt could not find. It returns nil for any other failure.
+func aptInstallError(err error) error {
+	var execErr *dagger.ExecError
+	if !errors.As(err, &execErr) || len(execErr.Cmd) < 2 || execErr.Cmd[0] != "apt-get" || execErr.Cmd[1] != "install" {
+		return nil
+	}
+	if names := unlocatedAptPackages(execErr.Stderr); len(names) > 0 {
+		return Errorf(CategoryBuild, "apt-get could not install package(s) >>> %s <<< (check APT_PACKAGES and EXTRA_APT_PACKAGES): %w", strings.Join(names, ", "), err)
+	}
+	return Errorf(CategoryBuild, "apt-get install failed:\n%s", strings.TrimSpace(execErr.Stderr))
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseAptPackages tests space/comma separated package lists
+func TestParseAptPackages(t *testing.T) {
+	got := ParseAptPackages(" libxml2-dev, swig\tlibffi-dev,,")
+	if strings.Join(got, " ") != "libxml2-dev swig libffi-dev" {
+		t.Fatalf("unexpected packages %q", got)
+	}
+	if ParseAptPackages("") != nil {
+		t.Fatal("empty list should parse to nil")
+	}
+	fmt.Println("✅ Extra apt package list parsed")
+}
+
+// TestAptPackages tests the default list, AptPackages, extras, SkipDefaultApt and name validation
+func TestAptPackages(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", ExtraAptPackages: []string{"swig", "libxml2-dev=2.9.14+dfsg-1.3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p.aptPackages(), " "); got != "git build-essential libpq-dev swig libxml2-dev=2.9.14+dfsg-1.3" {
+		t.Fatalf("unexpected package list %q", got)
+	}
+
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", SkipDefaultApt: true, CACertPaths: []string{"/certs/ca.pem"}})
+	if got := strings.Join(p.aptPackages(), " "); got != "ca-certificates" {
+		t.Fatalf("CA support must survive SkipDefaultApt, got %q", got)
+	}
+
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", AptPackages: []string{"git", "libxml2-dev"}, ExtraAptPackages: []string{"swig"}, CACertPaths: []string{"/certs/ca.pem"}})
+	if got := strings.Join(p.aptPackages(), " "); got != "git libxml2-dev swig ca-certificates" {
+		t.Fatalf("AptPackages should replace the default and keep CA support, got %q", got)
+	}
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", AptPackages: []string{"ca-certificates", "git"}, CACertPaths: []string{"/certs/ca.pem"}})
+	if got := strings.Join(p.aptPackages(), " "); got != "ca-certificates git" {
+		t.Fatalf("ca-certificates should not be added twice, got %q", got)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", AptPackages: []string{"git"}, SkipDefaultApt: true}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("AptPackages with SkipDefaultApt should be a config error, got %v", err)
+	}
+
+	_, err = New(Config{RepoName: "cert-parser", GitUser: "org", ExtraAptPackages: []string{"swig", "libXML2; rm -rf /"}})
+	if CategoryOf(err) != CategoryConfig || !strings.Contains(err.Error(), "libXML2; rm -rf /") {
+		t.Fatalf("expected config error naming the package, got %v", err)
+	}
+	fmt.Println("✅ Build container apt packages assembled and validated")
+}
+
+// TestUnlocatedAptPackages tests picking the offending names out of apt's errors
+func TestUnlocatedAptPackages(t *testing.T) {
+	stderr := "Reading package lists...\nE: Unable to locate package libxml-dev\nE: Package 'swig4' has no installation candidate\n"
+	if got := strings.Join(unlocatedAptPackages(stderr), ","); got != "libxml-dev,swig4" {
+		t.Fatalf("unexpected packages %q", got)
+	}
+	fmt.Println("✅ Unknown apt packages identified from apt output")
+}
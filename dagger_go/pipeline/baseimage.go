@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// dockerHubAuthAddress is the registry address Docker Hub credentials are
+// registered for.
+const dockerHubAuthAddress = "docker.io"
+
+// imageRefPattern matches an image reference: an optional registry host,
+// a lower-case repository path, an optional tag and an optional digest.
+var imageRefPattern = regexp.MustCompile(`^([A-Za-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*(:[A-Za-z0-9_][A-Za-z0-9._-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+// dockerHubRateLimitPattern matches Docker Hub's pull rate-limit response as
+// it surfaces in Dagger errors.
+var dockerHubRateLimitPattern = regexp.MustCompile(`(?i)toomanyrequests|429 Too Many Requests|pull rate limit`)
+
+// IsDockerHubRef reports whether an image reference is pulled from Docker
+// Hub: no registry host ("python:3.14-slim", "aquasec/trivy") or docker.io.
+func IsDockerHubRef(ref string) bool {
+	first, _, found := strings.Cut(ref, "/")
+	if !found || !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return true
+	}
+	switch first {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return true
+	}
+	return false
+}
+
+// MirrorImage rewrites a Docker Hub reference to the mirror prefix, e.g.
+// python:3.14-slim → registry.corp.local/dockerhub/python:3.14-slim. Other
+// registries' references, and every reference when mirror is empty, are
+// returned unchanged.
+func MirrorImage(ref, mirror string) string {
+	if mirror == "" || !IsDockerHubRef(ref) {
+		return ref
+	}
+	for _, prefix := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		ref = strings.TrimPrefix(ref, prefix)
+	}
+	ref = strings.TrimPrefix(ref, "library/")
+	return strings.TrimSuffix(mirror, "/") + "/" + ref
+}
+
+// IsRateLimited reports whether err is Docker Hub refusing a pull because of
+// its rate limit.
+func IsRateLimited(err error) bool {
+	return err != nil && dockerHubRateLimitPattern.MatchString(err.Error())
+}
+
+// ValidateImageRef rejects what is not an image reference, such as
+// python:3.13-slim or registry.corp.local/python@sha256:<digest>.
+func ValidateImageRef(ref string) error {
+	if !imageRefPattern.MatchString(ref) {
+		return fmt.Errorf("%q is not an image reference such as python:3.13-slim or registry.corp.local/python/python:3.13-slim", ref)
+	}
+	return nil
+}
+
+// baseImageRef is cfg.BaseImage, or BaseImage when it is not set.
+func baseImageRef(cfg Config) string {
+	if cfg.BaseImage != "" {
+		return cfg.BaseImage
+	}
+	return BaseImage
+}
+
+// baseImage is the base image, through BaseImageMirror when set.
+func (p *Pipeline) baseImage() string {
+	return MirrorImage(baseImageRef(p.cfg), p.cfg.BaseImageMirror)
+}
+
+// from pulls ref, authenticated with the Docker Hub pull credentials when it
+// comes from Docker Hub. Dagger keeps registry credentials for the whole
+// session, so they also cover the Dockerfile's own FROM.
+func (p *Pipeline) from(client *dagger.Client, ref string) *dagger.Container {
+	container := client.Container()
+	if p.cfg.DockerHubToken != "" && IsDockerHubRef(ref) {
+		secret := client.SetSecret("dockerhub-pull-token", p.cfg.DockerHubToken)
+		container = container.WithRegistryAuth(dockerHubAuthAddress, p.cfg.DockerHubUser, secret)
+	}
+	return container.From(ref)
+}
+
+// explainRateLimit prints what to do when a pull failed on the Docker Hub
+// rate limit, which otherwise shows as a 429 deep in the Dagger output.
+func (p *Pipeline) explainRateLimit(err error) {
+	if !IsRateLimited(err) {
+		return
+	}
+	p.println("\n⚠️  Docker Hub refused a pull: rate limit reached (429 toomanyrequests)")
+	if p.cfg.DockerHubToken == "" {
+		p.println("   Anonymous pulls are limited per IP address, and a shared egress IP reaches the limit quickly.")
+		p.println("   Fix: set DOCKERHUB_PULL_USERNAME and DOCKERHUB_PULL_TOKEN (a Docker Hub access token) to pull authenticated")
+	} else {
+		p.printf("   Pulls are already authenticated as %s, and that account's limit is reached too.\n", p.cfg.DockerHubUser)
+	}
+	if p.cfg.BaseImageMirror == "" {
+		p.println("   Or pull the base image through an internal mirror: BASE_IMAGE_MIRROR=registry.corp.local/dockerhub")
+	} else {
+		p.println("   BASE_IMAGE_MIRROR covers the base image only; tool images and the Dockerfile's FROM still come from Docker Hub")
+	}
+}
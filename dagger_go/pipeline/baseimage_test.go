@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestMirrorImage tests rewriting Docker Hub references and leaving other registries alone
+func TestMirrorImage(t *testing.T) {
+	mirror := "registry.corp.local/dockerhub/"
+	cases := map[string]string{
+		"python:3.14-slim":                "registry.corp.local/dockerhub/python:3.14-slim",
+		"docker.io/library/python:3.14":   "registry.corp.local/dockerhub/python:3.14",
+		"aquasec/trivy:0.58.1":            "registry.corp.local/dockerhub/aquasec/trivy:0.58.1",
+		"ghcr.io/org/app:1":               "ghcr.io/org/app:1",
+		"localhost:5000/python:3.14-slim": "localhost:5000/python:3.14-slim",
+		"registry.corp.local/python:3.14": "registry.corp.local/python:3.14",
+	}
+	for ref, want := range cases {
+		if got := MirrorImage(ref, mirror); got != want {
+			t.Fatalf("MirrorImage(%q) = %q, want %q", ref, got, want)
+		}
+	}
+	if got := MirrorImage("python:3.14-slim", ""); got != "python:3.14-slim" {
+		t.Fatalf("no mirror should keep the reference, got %q", got)
+	}
+	fmt.Println("✅ Docker Hub references rewritten to the mirror")
+}
+
+// TestValidateImageRef tests BASE_IMAGE reference checking
+func TestValidateImageRef(t *testing.T) {
+	for _, ref := range []string{
+		"python:3.13-slim",
+		"docker.io/library/python:3.12-slim-bookworm",
+		"registry.corp.local:5000/python/python:3.14-slim",
+		"python@sha256:" + strings.Repeat("a", 64),
+		"ghcr.io/org/python-base:3.14_2",
+	} {
+		if err := ValidateImageRef(ref); err != nil {
+			t.Fatalf("ValidateImageRef(%q): %v", ref, err)
+		}
+	}
+	for _, ref := range []string{"", "Python:3.13", "python:3.13 slim", "python:", "python@sha256:abc", "https://registry/python"} {
+		if err := ValidateImageRef(ref); err == nil {
+			t.Fatalf("ValidateImageRef(%q) should fail", ref)
+		}
+	}
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", BaseImage: "python:3.13-slim", BaseImageMirror: "registry.corp.local/dockerhub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.baseImage(); got != "registry.corp.local/dockerhub/python:3.13-slim" {
+		t.Fatalf("unexpected base image %q", got)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", BaseImage: "python 3.13"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	fmt.Println("✅ BASE_IMAGE checked and pulled through the mirror")
+}
+
+// TestRateLimitGuidance tests recognizing the Docker Hub 429 and naming the remedies
+func TestRateLimitGuidance(t *testing.T) {
+	err := errors.New(`failed to resolve source metadata for docker.io/library/python:3.14-slim: unexpected status from HEAD request: 429 Too Many Requests - Server message: toomanyrequests: You have reached your pull rate limit.`)
+	if !IsRateLimited(err) || IsRateLimited(errors.New("exit code: 1")) || IsRateLimited(nil) {
+		t.Fatal("rate limit detection mismatch")
+	}
+
+	var out bytes.Buffer
+	p, nerr := New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out})
+	if nerr != nil {
+		t.Fatal(nerr)
+	}
+	p.explainRateLimit(err)
+	for _, want := range []string{"rate limit", "DOCKERHUB_PULL_USERNAME", "DOCKERHUB_PULL_TOKEN", "BASE_IMAGE_MIRROR"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("guidance should mention %s:\n%s", want, out.String())
+		}
+	}
+	if _, nerr := New(Config{RepoName: "cert-parser", GitUser: "org", DockerHubToken: "t"}); CategoryOf(nerr) != CategoryConfig {
+		t.Fatalf("a token without a username should be a config error, got %v", nerr)
+	}
+	fmt.Println("✅ Docker Hub rate limit explained")
+}
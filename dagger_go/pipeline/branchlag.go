@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBehindWarnThreshold is how many commits the built branch may be
+// behind the default branch before the run warns.
+const DefaultBehindWarnThreshold = 50
+
+// branchLagTimeout bounds the two GitHub API calls, so an unreachable API
+// delays the run by no more than this.
+const branchLagTimeout = 15 * time.Second
+
+// maxGitHubResponse bounds the GitHub API responses read; compare responses
+// list commits and files, but only the counts are used.
+const maxGitHubResponse = 16 << 20
+
+// BranchLag is how far the built commit is from the repository's default
+// branch, recorded in the run report.
+type BranchLag struct {
+	DefaultBranch string `json:"default_branch"`
+	Commit        string `json:"commit"`
+	BehindBy      int    `json:"behind_by"`
+	AheadBy       int    `json:"ahead_by"`
+	Threshold     int    `json:"threshold"`
+}
+
+// GitHubAPIBase is the REST API root for a Git host: api.github.com for
+// github.com, /api/v3 on the host itself for GitHub Enterprise Server.
+func GitHubAPIBase(gitHost string) string {
+	if gitHost == "github.com" || gitHost == "www.github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + gitHost + "/api/v3"
+}
+
+// CompareWithDefaultBranch looks up the default branch of owner/repo and
+// compares commit with it through the GitHub compare API. token may be
+// empty for public repositories.
+func CompareWithDefaultBranch(ctx context.Context, client *http.Client, apiBase, owner, repo, token, commit string) (*BranchLag, error) {
+	repoURL := fmt.Sprintf("%s/repos/%s/%s", strings.TrimSuffix(apiBase, "/"), url.PathEscape(owner), url.PathEscape(repo))
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := getGitHubJSON(ctx, client, repoURL, token, &info); err != nil {
+		return nil, err
+	}
+	if info.DefaultBranch == "" {
+		return nil, fmt.Errorf("GET %s: no default_branch in the response", repoURL)
+	}
+	var compare struct {
+		AheadBy  int `json:"ahead_by"`
+		BehindBy int `json:"behind_by"`
+	}
+	compareURL := fmt.Sprintf("%s/compare/%s...%s", repoURL, url.PathEscape(info.DefaultBranch), url.PathEscape(commit))
+	if err := getGitHubJSON(ctx, client, compareURL, token, &compare); err != nil {
+		return nil, err
+	}
+	return &BranchLag{DefaultBranch: info.DefaultBranch, Commit: commit, BehindBy: compare.BehindBy, AheadBy: compare.AheadBy}, nil
+}
+
+func getGitHubJSON(ctx context.Context, client *http.Client, url, token string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxGitHubResponse))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkBranchLag warns when the built commit is more than
+// BehindWarnThreshold commits behind the default branch, and with
+// BehindStrict fails a publishing run. Tags are skipped: an older release
+// is expected to be behind. The check is advisory: API errors and hosts
+// without the GitHub API only print a note.
+func (p *Pipeline) checkBranchLag(ctx context.Context, commit string) error {
+	cfg := p.cfg
+	if cfg.BehindWarnThreshold < 0 || cfg.GitBranch == mainBranch || cfg.GitTag != "" {
+		return nil
+	}
+	if strings.Contains(cfg.GitHost, "gitlab") {
+		p.println("   ℹ️  Branch freshness not checked (needs the GitHub compare API)")
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, branchLagTimeout)
+	defer cancel()
+	lag, err := CompareWithDefaultBranch(ctx, cfg.HTTPClient, GitHubAPIBase(cfg.GitHost), cfg.GitUser, cfg.RepoName, cfg.GitToken, commit)
+	if err != nil {
+		p.warnf("branch-compare", "   ⚠️  Could not compare with the default branch: %v\n", err)
+		return nil
+	}
+	lag.Threshold = cfg.BehindWarnThreshold
+	p.report.BranchLag = lag
+	if lag.BehindBy <= lag.Threshold {
+		if lag.BehindBy > 0 {
+			p.printf("   Branch is %d commit(s) behind %s\n", lag.BehindBy, lag.DefaultBranch)
+		}
+		return nil
+	}
+	p.warnf("branch-behind", "\n⚠️  This branch is %d commits behind %s (BEHIND_WARN_THRESHOLD=%d) — the image will lack the fixes merged there since\n",
+		lag.BehindBy, lag.DefaultBranch, lag.Threshold)
+	if cfg.BehindStrict && cfg.RunPublish {
+		return Errorf(CategoryPublish, "branch %s is %d commits behind %s (threshold %d, BEHIND_STRICT=true): merge or rebase before publishing",
+			cfg.GitBranch, lag.BehindBy, lag.DefaultBranch, lag.Threshold)
+	}
+	return nil
+}
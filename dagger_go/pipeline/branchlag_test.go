@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// githubCompareServer serves the repository and compare endpoints of a
+// GitHub Enterprise API for org/cert-parser with the given behind_by count.
+func githubCompareServer(t *testing.T, behindBy int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/org/cert-parser", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pat" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"default_branch": "develop"}`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/cert-parser/compare/develop...abc123", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status": "diverged", "ahead_by": 2, "behind_by": %d}`, behindBy)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestCompareWithDefaultBranch tests reading behind_by against the repository's default branch
+func TestCompareWithDefaultBranch(t *testing.T) {
+	if GitHubAPIBase("github.com") != "https://api.github.com" || GitHubAPIBase("git.corp.example") != "https://git.corp.example/api/v3" {
+		t.Fatal("unexpected API base")
+	}
+	server := githubCompareServer(t, 73)
+	lag, err := CompareWithDefaultBranch(context.Background(), server.Client(), server.URL+"/api/v3", "org", "cert-parser", "pat", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.DefaultBranch != "develop" || lag.BehindBy != 73 || lag.AheadBy != 2 {
+		t.Fatalf("got %+v", lag)
+	}
+	if _, err := CompareWithDefaultBranch(context.Background(), server.Client(), server.URL+"/api/v3", "org", "cert-parser", "", "abc123"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected the API status in the error, got %v", err)
+	}
+	fmt.Println("✅ Branch compared with the default branch")
+}
+
+// TestCheckBranchLag tests the warning, strict mode for publishing runs and the report entry
+func TestCheckBranchLag(t *testing.T) {
+	server := githubCompareServer(t, 73)
+	host := strings.TrimPrefix(server.URL, "https://")
+	cases := []struct {
+		name      string
+		threshold int
+		strict    bool
+		wantErr   bool
+		wantWarn  bool
+	}{
+		{"under threshold", 100, false, false, false},
+		{"over threshold warns", 0, false, false, true},
+		{"strict fails publishing", 0, true, true, true},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		p, err := New(Config{
+			RepoName: "cert-parser", GitUser: "org", GitHost: host, GitBranch: "feature/x", GitToken: "pat",
+			BehindWarnThreshold: c.threshold, BehindStrict: c.strict,
+			RunBuild: true, RunPublish: true, RegistryToken: "pat",
+			HTTPClient: server.Client(), Output: &out,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.checkBranchLag(context.Background(), "abc123")
+		if (err != nil) != c.wantErr || (err != nil && CategoryOf(err) != CategoryPublish) {
+			t.Fatalf("%s: err = %v", c.name, err)
+		}
+		if warned := strings.Contains(out.String(), "73 commits behind develop"); warned != c.wantWarn {
+			t.Fatalf("%s: warning = %v in %q", c.name, warned, out.String())
+		}
+		if p.report.BranchLag == nil || p.report.BranchLag.BehindBy != 73 {
+			t.Fatalf("%s: report = %+v", c.name, p.report.BranchLag)
+		}
+	}
+	fmt.Println("✅ Branch lag warned about and recorded")
+}
@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultHistoryRuns is how many runs per branch the duration history keeps.
+const DefaultHistoryRuns = 20
+
+// RunDuration is one successful run recorded in the duration history.
+type RunDuration struct {
+	Branch       string             `json:"branch"`
+	FinishedAt   time.Time          `json:"finished_at"`
+	TotalSeconds float64            `json:"total_seconds"`
+	Stages       map[string]float64 `json:"stages"`
+}
+
+// DurationHistory is the rolling per-branch record of run durations used as
+// the baseline when a run goes over DURATION_BUDGET.
+type DurationHistory struct {
+	Runs []RunDuration `json:"runs"`
+}
+
+// StageDelta compares a stage's duration in this run with its rolling average.
+type StageDelta struct {
+	Name       string
+	Seconds    float64
+	Average    float64 // 0 when the stage has no history yet
+	HasHistory bool
+}
+
+// DefaultHistoryFile returns the per-user cache location of the duration
+// history, or "" when no cache directory is available.
+func DefaultHistoryFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil || dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "cert-parser-dagger", "duration-history.json")
+}
+
+// LoadDurationHistory reads the history file. A missing file is an empty history.
+func LoadDurationHistory(path string) (*DurationHistory, error) {
+	h := &DurationHistory{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("invalid duration history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Save atomically writes the history to path.
+func (h *DurationHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+// Record appends a run and drops the oldest runs of the same branch beyond keep.
+func (h *DurationHistory) Record(run RunDuration, keep int) {
+	h.Runs = append(h.Runs, run)
+	drop := -keep
+	for _, r := range h.Runs {
+		if r.Branch == run.Branch {
+			drop++
+		}
+	}
+	kept := make([]RunDuration, 0, len(h.Runs))
+	for _, r := range h.Runs {
+		if r.Branch == run.Branch && drop > 0 {
+			drop--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	h.Runs = kept
+}
+
+// StageAverages returns the mean duration of each stage over the branch's runs.
+func (h *DurationHistory) StageAverages(branch string) map[string]float64 {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for _, run := range h.Runs {
+		if run.Branch != branch {
+			continue
+		}
+		for name, seconds := range run.Stages {
+			sums[name] += seconds
+			counts[name]++
+		}
+	}
+	averages := make(map[string]float64, len(sums))
+	for name, sum := range sums {
+		averages[name] = sum / float64(counts[name])
+	}
+	return averages
+}
+
+// NewRunDuration converts a finished run's status into a history entry.
+// Skipped stages are left out since their zero duration would skew averages.
+func NewRunDuration(status Status, branch string) RunDuration {
+	run := RunDuration{
+		Branch:       branch,
+		FinishedAt:   status.UpdatedAt,
+		TotalSeconds: status.ElapsedSeconds,
+		Stages:       map[string]float64{},
+	}
+	for _, stage := range status.Stages {
+		if stage.Status != StageSkipped {
+			run.Stages[stage.Name] = stage.DurationSeconds
+		}
+	}
+	return run
+}
+
+// SlowestStages returns the n slowest executed stages of a run with their
+// rolling averages.
+func SlowestStages(status Status, averages map[string]float64, n int) []StageDelta {
+	var deltas []StageDelta
+	for _, stage := range status.Stages {
+		if stage.Status == StageSkipped {
+			continue
+		}
+		avg, ok := averages[stage.Name]
+		deltas = append(deltas, StageDelta{Name: stage.Name, Seconds: stage.DurationSeconds, Average: avg, HasHistory: ok})
+	}
+	sort.SliceStable(deltas, func(i, j int) bool { return deltas[i].Seconds > deltas[j].Seconds })
+	if len(deltas) > n {
+		deltas = deltas[:n]
+	}
+	return deltas
+}
+
+// CheckDurationBudget compares a successful run against the budget, prints a
+// warning listing the slowest stages versus their rolling averages when it is
+// exceeded, and records the run in the history file. It returns a
+// CategoryBudget error only when the budget was exceeded. A zero budget only
+// records history; an empty historyFile disables the history. Failed runs are
+// not checked: they exit with their failure's code and would skew the
+// averages.
+func CheckDurationBudget(w io.Writer, status Status, branch string, budget time.Duration, historyFile string) error {
+	history := &DurationHistory{}
+	if historyFile != "" {
+		loaded, err := LoadDurationHistory(historyFile)
+		if err != nil {
+			fmt.Fprintf(w, "⚠️  Could not read duration history (starting fresh): %v\n", err)
+		} else {
+			history = loaded
+		}
+	}
+	averages := history.StageAverages(branch)
+
+	var exceeded error
+	total := time.Duration(status.ElapsedSeconds * float64(time.Second))
+	if budget > 0 && total > budget {
+		exceeded = Errorf(CategoryBudget, "pipeline took %s, over the %s duration budget", total.Round(time.Second), budget)
+		fmt.Fprintf(w, "\n⚠️  DURATION BUDGET EXCEEDED: %v\n", exceeded)
+		fmt.Fprintf(w, "   Slowest stages (vs rolling average for branch %s):\n", branch)
+		for _, d := range SlowestStages(status, averages, 3) {
+			seconds := time.Duration(d.Seconds * float64(time.Second)).Round(time.Second)
+			if !d.HasHistory {
+				fmt.Fprintf(w, "   • %-20s %8s  (no history yet)\n", d.Name, seconds)
+				continue
+			}
+			delta := time.Duration((d.Seconds - d.Average) * float64(time.Second)).Round(time.Second)
+			sign := "+"
+			if delta < 0 {
+				sign = ""
+			}
+			fmt.Fprintf(w, "   • %-20s %8s  (avg %s, %s%s)\n", d.Name, seconds,
+				time.Duration(d.Average*float64(time.Second)).Round(time.Second), sign, delta)
+		}
+	}
+
+	if historyFile != "" {
+		history.Record(NewRunDuration(status, branch), DefaultHistoryRuns)
+		if err := history.Save(historyFile); err != nil {
+			fmt.Fprintf(w, "⚠️  Could not write duration history %s: %v\n", historyFile, err)
+		}
+	}
+	return exceeded
+}
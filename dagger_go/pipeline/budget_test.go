@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func statusWithStages(total float64, stages map[string]float64) Status {
+	s := Status{State: RunSucceeded, ElapsedSeconds: total, UpdatedAt: time.Now()}
+	for _, name := range []string{"unit-tests", "integration-tests", "lint", "build", "publish"} {
+		if seconds, ok := stages[name]; ok {
+			s.Stages = append(s.Stages, StageRecord{Name: name, Status: StagePassed, DurationSeconds: seconds})
+		}
+	}
+	return s
+}
+
+// TestDurationHistoryRecord tests per-branch trimming and averaging
+func TestDurationHistoryRecord(t *testing.T) {
+	h := &DurationHistory{}
+	for i := 1; i <= 5; i++ {
+		h.Record(RunDuration{Branch: "main", Stages: map[string]float64{"build": float64(i * 10)}}, 3)
+		h.Record(RunDuration{Branch: "dev", Stages: map[string]float64{"build": 1}}, 3)
+	}
+	if len(h.Runs) != 6 {
+		t.Fatalf("expected 3 runs per branch (6 total), got %d", len(h.Runs))
+	}
+	// main keeps runs 3, 4, 5 → average 40
+	if avg := h.StageAverages("main")["build"]; avg != 40 {
+		t.Fatalf("main build average = %v, want 40", avg)
+	}
+	if avg := h.StageAverages("dev")["build"]; avg != 1 {
+		t.Fatalf("dev build average = %v, want 1", avg)
+	}
+	if h.Runs[len(h.Runs)-1].Branch != "dev" {
+		t.Fatal("runs should stay in chronological order")
+	}
+	fmt.Println("✅ Duration history keeps the last N runs per branch")
+}
+
+// TestCheckDurationBudget tests the over-budget warning and history persistence
+func TestCheckDurationBudget(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history.json")
+	var out bytes.Buffer
+
+	// First run within budget seeds the history
+	first := statusWithStages(600, map[string]float64{"unit-tests": 60, "integration-tests": 300, "build": 200, "lint": 20})
+	if err := CheckDurationBudget(&out, first, "main", 15*time.Minute, historyFile); err != nil {
+		t.Fatalf("run within budget should pass, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("no output expected within budget, got %q", out.String())
+	}
+
+	// Second run goes over budget; integration tests doubled
+	second := statusWithStages(1200, map[string]float64{"unit-tests": 60, "integration-tests": 600, "build": 250, "lint": 20, "publish": 200})
+	err := CheckDurationBudget(&out, second, "main", 15*time.Minute, historyFile)
+	if CategoryOf(err) != CategoryBudget {
+		t.Fatalf("expected a duration budget error, got %v", err)
+	}
+	log := out.String()
+	for _, want := range []string{"DURATION BUDGET EXCEEDED", "integration-tests", "+5m0s", "build", "publish", "no history yet"} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("expected %q in output:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "lint") {
+		t.Fatalf("only the three slowest stages should be listed:\n%s", log)
+	}
+
+	h, err := LoadDurationHistory(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Runs) != 2 {
+		t.Fatalf("expected 2 recorded runs, got %d", len(h.Runs))
+	}
+	fmt.Println("✅ Duration budget overrun reported against rolling averages")
+}
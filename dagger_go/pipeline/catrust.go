@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// caCertsDir is where CACertPaths are mounted in the build container; its
+// *.crt files are what update-ca-certificates adds to the trust store.
+const caCertsDir = "/usr/local/share/ca-certificates"
+
+var caUpdateCounts = regexp.MustCompile(`(\d+) added, (\d+) removed`)
+
+// CARejection is a mounted certificate file update-ca-certificates did not
+// trust, with the reason.
+type CARejection struct {
+	File   string // relative to the mount directory
+	Reason string
+}
+
+// CAUpdate is the parsed result of update-ca-certificates.
+type CAUpdate struct {
+	Added    int
+	Removed  int
+	Counted  bool     // the "N added, M removed" line was found
+	Warnings []string // warning lines, verbatim
+}
+
+// ParseCAUpdate reads the added/removed counts and the warnings from
+// update-ca-certificates output (stdout and stderr together).
+func ParseCAUpdate(output string) CAUpdate {
+	var u CAUpdate
+	if m := caUpdateCounts.FindStringSubmatch(output); m != nil {
+		u.Added, _ = strconv.Atoi(m[1])
+		u.Removed, _ = strconv.Atoi(m[2])
+		u.Counted = true
+	}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		if strings.HasPrefix(line, "W:") || strings.Contains(lower, "warning") {
+			// The generated bundle itself is always skipped by the rehash
+			if strings.Contains(line, "ca-certificates.crt") {
+				continue
+			}
+			u.Warnings = append(u.Warnings, line)
+		}
+	}
+	return u
+}
+
+// RejectedCAFiles lists the mounted files (relative to caCertsDir) that
+// will not be trusted: files without the .crt extension are never read by
+// update-ca-certificates, and .crt files a warning names — by full name or
+// by the .pem link the rehash creates for them — were skipped.
+func RejectedCAFiles(files []string, warnings []string) []CARejection {
+	var rejected []CARejection
+	for _, file := range files {
+		if path.Ext(file) != ".crt" {
+			rejected = append(rejected, CARejection{File: file, Reason: "not a .crt file — update-ca-certificates only reads *.crt"})
+			continue
+		}
+		base := path.Base(file)
+		link := strings.TrimSuffix(base, ".crt") + ".pem"
+		for _, w := range warnings {
+			if containsWord(w, base) || containsWord(w, link) {
+				rejected = append(rejected, CARejection{File: file, Reason: w})
+				break
+			}
+		}
+	}
+	return rejected
+}
+
+// containsWord reports whether name appears in s delimited by something
+// other than a file-name character.
+func containsWord(s, name string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if (start == 0 || !isNameChar(s[start-1])) && (end == len(s) || !isNameChar(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// mountedCAFiles lists the files CACertPaths put under caCertsDir, relative
+// to it, the way systemEnv mounts them.
+func mountedCAFiles(paths []string) []string {
+	var files []string
+	for _, certPath := range paths {
+		info, err := os.Stat(certPath)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(certPath)
+		if !info.IsDir() {
+			files = append(files, name)
+			continue
+		}
+		filepath.WalkDir(certPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if rel, err := filepath.Rel(certPath, p); err == nil {
+				files = append(files, path.Join(name, filepath.ToSlash(rel)))
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// verifyCATrust checks the update-ca-certificates run of the build
+// environment before anything depends on it. It lists every mounted file
+// that was rejected and why, and fails when the command failed or none of
+// the corporate certificates ended up trusted, so a later pip TLS error is
+// not the first sign of a bad certificate.
+func (p *Pipeline) verifyCATrust(ctx context.Context) error {
+	if p.caUpdate == nil {
+		return nil
+	}
+	stdout, err := p.caUpdate.Stdout(ctx)
+	if err != nil {
+		if aptErr := aptInstallError(err); aptErr != nil {
+			return aptErr
+		}
+		return Errorf(CategoryBuild, "system environment setup failed: %w", err)
+	}
+	stderr, _ := p.caUpdate.Stderr(ctx)
+	code, err := p.caUpdate.ExitCode(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "update-ca-certificates: %w", err)
+	}
+	output := strings.TrimSpace(stdout + "\n" + stderr)
+	if code != 0 {
+		p.println(output)
+		return Errorf(CategoryConfig, "update-ca-certificates failed with exit code %d", code)
+	}
+
+	update := ParseCAUpdate(output)
+	files := mountedCAFiles(p.cfg.CACertPaths)
+	rejected := RejectedCAFiles(files, update.Warnings)
+	trusted := len(files) - len(rejected)
+	if update.Counted {
+		p.printf("   📜 update-ca-certificates: %d added, %d removed\n", update.Added, update.Removed)
+	}
+	if len(rejected) > 0 {
+		p.warnf("ca-cert-rejected", "   ⚠️  %d of %d mounted certificate file(s) rejected:\n", len(rejected), len(files))
+		for _, r := range rejected {
+			p.printf("      ✗ %s/%s: %s\n", caCertsDir, r.File, r.Reason)
+		}
+	}
+	if (update.Counted && update.Added == 0) || trusted <= 0 {
+		if len(update.Warnings) > 0 {
+			p.println("   update-ca-certificates warnings:")
+			for _, w := range update.Warnings {
+				p.printf("      %s\n", w)
+			}
+		}
+		return Errorf(CategoryConfig, "none of the %d corporate CA certificate file(s) was added to the trust store (check CA_CERTIFICATES_PATH)", len(files))
+	}
+	return nil
+}
+
+// caMountPath is the container path of a CACertPaths entry.
+func caMountPath(certPath string) string {
+	return fmt.Sprintf("%s/%s", caCertsDir, filepath.Base(certPath))
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestParseCAUpdate tests the added count and warnings of update-ca-certificates
+func TestParseCAUpdate(t *testing.T) {
+	output := `Updating certificates in /etc/ssl/certs...
+rehash: warning: skipping ca-certificates.crt,it does not contain exactly one certificate or CRL
+rehash: warning: skipping broken.pem,it does not contain exactly one certificate or CRL
+1 added, 0 removed; done.
+Running hooks in /etc/ca-certificates/update.d...
+done.`
+	u := ParseCAUpdate(output)
+	if !u.Counted || u.Added != 1 || u.Removed != 0 {
+		t.Fatalf("unexpected counts: %+v", u)
+	}
+	if len(u.Warnings) != 1 || !strings.Contains(u.Warnings[0], "broken.pem") {
+		t.Fatalf("expected only the broken.pem warning, got %v", u.Warnings)
+	}
+	fmt.Println("✅ update-ca-certificates output parsed")
+}
+
+// TestRejectedCAFiles tests which mounted files are reported as not trusted
+func TestRejectedCAFiles(t *testing.T) {
+	files := []string{"corp-root.crt", "broken.crt", "proxy.pem", "certs/issuing.crt"}
+	warnings := []string{"rehash: warning: skipping broken.pem,it does not contain exactly one certificate or CRL"}
+
+	rejected := RejectedCAFiles(files, warnings)
+	if len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected files, got %+v", rejected)
+	}
+	if rejected[0].File != "broken.crt" || !strings.Contains(rejected[0].Reason, "exactly one certificate") {
+		t.Fatalf("broken.crt should carry the rehash warning: %+v", rejected[0])
+	}
+	if rejected[1].File != "proxy.pem" || !strings.Contains(rejected[1].Reason, "not a .crt file") {
+		t.Fatalf("proxy.pem should be rejected for its extension: %+v", rejected[1])
+	}
+	fmt.Println("✅ Rejected CA files listed with reasons")
+}
+
+// TestMountedCAFiles tests the container-relative names of mounted files and directories
+func TestMountedCAFiles(t *testing.T) {
+	dir := t.TempDir()
+	certs := filepath.Join(dir, "certs")
+	if err := os.MkdirAll(filepath.Join(certs, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{filepath.Join(dir, "root.crt"), filepath.Join(certs, "a.crt"), filepath.Join(certs, "sub", "b.pem")} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := mountedCAFiles([]string{filepath.Join(dir, "root.crt"), certs, filepath.Join(dir, "missing.crt")})
+	sort.Strings(files)
+	want := []string{"certs/a.crt", "certs/sub/b.pem", "root.crt"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Fatalf("mountedCAFiles = %v, want %v", files, want)
+	}
+	fmt.Println("✅ Mounted CA files resolved")
+}
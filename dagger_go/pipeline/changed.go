@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// testFilePatterns find the test files in the project source.
+var testFilePatterns = []string{"**/test_*.py", "**/*_test.py"}
+
+// isTestFile reports whether a file name follows pytest's default test
+// file patterns.
+func isTestFile(file string) bool {
+	base := path.Base(file)
+	return strings.HasSuffix(base, ".py") && (strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py"))
+}
+
+// isDocFile reports whether a change cannot affect any test.
+func isDocFile(file string) bool {
+	switch path.Ext(file) {
+	case ".md", ".rst":
+		return true
+	}
+	return strings.HasPrefix(file, "docs/")
+}
+
+// ChangedTests maps changed files (relative to the project) to the test
+// files to run: a changed test file runs itself, and a changed module
+// foo.py runs every test_foo.py and foo_test.py. Documentation changes and
+// deleted test files select nothing. A non-empty reason means a change
+// cannot be narrowed down — conftest.py, a package __init__.py, a module
+// without a matching test file, pyproject.toml or any other file — and the
+// whole suite runs.
+func ChangedTests(changed, testFiles []string) (selected []string, reason string) {
+	exists := map[string]bool{}
+	byName := map[string][]string{}
+	for _, f := range testFiles {
+		exists[f] = true
+		byName[path.Base(f)] = append(byName[path.Base(f)], f)
+	}
+	seen := map[string]bool{}
+	add := func(files ...string) {
+		for _, f := range files {
+			if !seen[f] {
+				seen[f] = true
+				selected = append(selected, f)
+			}
+		}
+	}
+
+	for _, file := range changed {
+		base := path.Base(file)
+		switch {
+		case isDocFile(file):
+		case isTestFile(file):
+			if exists[file] { // deleted test files have nothing to run
+				add(file)
+			}
+		case path.Ext(file) != ".py":
+			return nil, file + " changed"
+		case base == "conftest.py" || base == "__init__.py":
+			return nil, file + " affects every test in its package"
+		default:
+			stem := strings.TrimSuffix(base, ".py")
+			tests := append(append([]string(nil), byName["test_"+stem+".py"]...), byName[stem+"_test.py"]...)
+			if len(tests) == 0 {
+				return nil, "no test file matches " + file
+			}
+			add(tests...)
+		}
+	}
+	sort.Strings(selected)
+	return selected, ""
+}
+
+// selectChangedTests limits the test stages to the tests affected by the
+// changes since ChangedBase in the host checkout. Whenever the changes
+// cannot be mapped to test files, the full suite runs.
+func (p *Pipeline) selectChangedTests(ctx context.Context, source *dagger.Directory) {
+	base := p.cfg.ChangedBase
+	if base == "" {
+		base = DefaultChangedBase
+	}
+	p.printf("🎯 Selecting tests affected by changes since %s (CHANGED_ONLY_TESTS=true)\n", base)
+	changed, err := changedFiles(ctx, p.cfg.ProjectRoot, base)
+	if err != nil {
+		p.warnf("changed-files", "   ⚠️  Could not detect changed files (%v) — running all tests\n", err)
+		return
+	}
+
+	files := changed
+	if dir := strings.Trim(p.cfg.ProjectDir, "/"); dir != "" {
+		files = nil
+		for _, file := range changed {
+			rel, ok := strings.CutPrefix(file, dir+"/")
+			if !ok {
+				p.printf("   🔁 %s is outside %s — running all tests\n", file, dir)
+				return
+			}
+			files = append(files, rel)
+		}
+	}
+
+	var testFiles []string
+	for _, pattern := range testFilePatterns {
+		matches, err := source.Glob(ctx, pattern)
+		if err != nil {
+			p.warnf("changed-files", "   ⚠️  Could not list test files (%v) — running all tests\n", err)
+			return
+		}
+		testFiles = append(testFiles, matches...)
+	}
+
+	selected, reason := ChangedTests(files, testFiles)
+	if reason != "" {
+		p.printf("   🔁 %s — running all tests\n", reason)
+		return
+	}
+	p.changedOnly, p.changedTests = true, selected
+	if len(selected) == 0 {
+		p.printf("   ⏭️  No test-relevant changes in %d changed file(s) — test stages will be skipped\n", len(changed))
+		return
+	}
+	p.printf("   • %d test file(s) selected from %d changed file(s):\n", len(selected), len(changed))
+	for _, f := range selected {
+		p.printf("     %s\n", f)
+	}
+}
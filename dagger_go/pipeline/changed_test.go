@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestChangedTests tests mapping changed files to the tests to run
+func TestChangedTests(t *testing.T) {
+	testFiles := []string{
+		"tests/unit/test_parser.py",
+		"tests/unit/test_models.py",
+		"tests/integration/test_parser.py",
+		"tests/acceptance/loader_test.py",
+	}
+
+	selected, reason := ChangedTests([]string{
+		"src/cert_parser/parser.py",
+		"tests/acceptance/loader_test.py",
+		"tests/unit/test_removed.py",
+		"README.md",
+		"docs/usage.txt",
+	}, testFiles)
+	want := []string{"tests/acceptance/loader_test.py", "tests/integration/test_parser.py", "tests/unit/test_parser.py"}
+	if reason != "" || !reflect.DeepEqual(selected, want) {
+		t.Fatalf("ChangedTests = %v %q, want %v", selected, reason, want)
+	}
+
+	if selected, reason := ChangedTests([]string{"README.md"}, testFiles); reason != "" || len(selected) != 0 {
+		t.Fatalf("documentation changes should select nothing, got %v %q", selected, reason)
+	}
+
+	for _, file := range []string{"pyproject.toml", "tests/conftest.py", "src/cert_parser/__init__.py", "src/cert_parser/asn1.py"} {
+		if _, reason := ChangedTests([]string{"src/cert_parser/parser.py", file}, testFiles); reason == "" {
+			t.Fatalf("%s should run the whole suite", file)
+		}
+	}
+	fmt.Println("✅ Changed files mapped to test files")
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func fakeEnv(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+// TestDetectCI tests CI system detection from standard environment variables
+func TestDetectCI(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{"GITHUB_ACTIONS": "true"}, "GitHub Actions"},
+		{map[string]string{"GITLAB_CI": "true"}, "GitLab CI"},
+		{map[string]string{"JENKINS_URL": "https://jenkins.example.com/"}, "Jenkins"},
+		{map[string]string{"JENKINS_HOME": "/var/jenkins_home"}, "Jenkins"},
+		{map[string]string{"CI": "true"}, ""},
+		{map[string]string{}, ""},
+	}
+	for _, tc := range tests {
+		got := ""
+		if flavor := DetectCI(fakeEnv(tc.env)); flavor != nil {
+			got = flavor.Name
+		}
+		if got != tc.expected {
+			t.Fatalf("DetectCI(%v) = %q, want %q", tc.env, got, tc.expected)
+		}
+	}
+	fmt.Println("✅ CI systems detected from environment")
+}
+
+// TestGitLabSections tests that stages are wrapped in GitLab section markers
+func TestGitLabSections(t *testing.T) {
+	var out bytes.Buffer
+	tracker := NewTracker("")
+	DetectCI(fakeEnv(map[string]string{"GITLAB_CI": "true"})).Attach(tracker, &out)
+
+	tracker.StartStage("unit-tests")
+	tracker.PassStage()
+	tracker.SkipStage("integration-tests", "Docker not available")
+	tracker.StartStage("lint")
+	tracker.Finish(errors.New("ruff lint failed"))
+
+	log := out.String()
+	for _, want := range []string{
+		"section_start:", ":unit-tests[collapsed=false]\r\x1b[0Kunit-tests\n",
+		"section_end:", ":unit-tests\r",
+		":lint[collapsed=false]", ":lint\r",
+	} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("expected %q in output:\n%q", want, log)
+		}
+	}
+	if strings.Contains(log, "integration-tests") {
+		t.Fatalf("skipped stage should not open a section:\n%q", log)
+	}
+	if strings.Count(log, "section_start:") != strings.Count(log, "section_end:") {
+		t.Fatalf("unbalanced sections:\n%q", log)
+	}
+	fmt.Println("✅ GitLab collapsible sections emitted per stage")
+}
+
+// TestGitHubAndJenkinsSections tests group and marker output for the other flavors
+func TestGitHubAndJenkinsSections(t *testing.T) {
+	tests := []struct {
+		env   map[string]string
+		start string
+		end   string
+	}{
+		{map[string]string{"GITHUB_ACTIONS": "true"}, "::group::build\n", "::endgroup::\n"},
+		{map[string]string{"JENKINS_HOME": "/var/jenkins_home"}, "[pipeline-stage] BEGIN build\n", "[pipeline-stage] END build\n"},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		tracker := NewTracker("")
+		DetectCI(fakeEnv(tc.env)).Attach(tracker, &out)
+		tracker.StartStage("build")
+		tracker.PassStage()
+
+		if got, want := out.String(), tc.start+tc.end; got != want {
+			t.Fatalf("output = %q, want %q", got, want)
+		}
+	}
+	fmt.Println("✅ GitHub groups and Jenkins stage markers emitted")
+}
@@ -0,0 +1,249 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// DefaultCacheMaxAge is how long a cache volume may go unused before
+// cleanup empties it.
+const DefaultCacheMaxAge = 30 * 24 * time.Hour
+
+// ParseCacheMaxAge parses CACHE_MAX_AGE, a number of days; empty is
+// DefaultCacheMaxAge.
+func ParseCacheMaxAge(value string) (time.Duration, error) {
+	if value == "" {
+		return DefaultCacheMaxAge, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return 0, Errorf(CategoryConfig, "invalid CACHE_MAX_AGE %q (use a number of days, at least 1)", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// cacheVolumeNames are the cache volumes the pipeline creates; a CacheKey
+// adds a "-<key>" suffix. Cleanup never touches any other volume.
+var cacheVolumeNames = []string{AptCacheVolume, PipCacheVolume, trivyCacheVolume, uvCacheVolume}
+
+// CleanupConfig selects what Cleanup removes.
+type CleanupConfig struct {
+	MaxAge      time.Duration // volumes unused for longer are emptied (default: DefaultCacheMaxAge)
+	Apply       bool          // empty the stale volumes; false only lists them (dry run)
+	EnginePrune bool          // also prune the engine's releasable (dangling) build cache
+	Output      io.Writer     // Progress output (default: os.Stdout)
+	// Filesystems whose free space is shown first, e.g. DiskPaths; low free
+	// space in a dry run suggests applying
+	DiskPaths    []DiskPath
+	MinFreeSpace int64 // default: DefaultMinFreeSpace; negative never reports low space
+}
+
+// CacheVolumeUsage is one of the pipeline's cache volumes as seen in the
+// engine's cache.
+type CacheVolumeUsage struct {
+	Name     string    `json:"name"`
+	Bytes    int64     `json:"bytes"`
+	LastUsed time.Time `json:"last_used"`
+	Stale    bool      `json:"stale"`
+	Emptied  bool      `json:"emptied,omitempty"`
+}
+
+// CleanupReport lists the pipeline's cache volumes and what was removed.
+type CleanupReport struct {
+	Volumes     []CacheVolumeUsage `json:"volumes"`
+	Reclaimable int64              `json:"reclaimable"` // stale bytes; with Apply, the bytes emptied
+	Pruned      bool               `json:"engine_pruned,omitempty"`
+	FreeSpace   []FreeSpace        `json:"free_space,omitempty"`
+}
+
+// PipelineCacheVolume returns the volume name when a cache entry
+// description refers to one of the pipeline's cache volumes ("pip-cache" or
+// "pip-cache-<key>"), and "" otherwise.
+func PipelineCacheVolume(description string) string {
+	fields := strings.FieldsFunc(description, func(r rune) bool {
+		return strings.ContainsRune(" \t\"':/=,()[]{}", r)
+	})
+	for _, field := range fields {
+		for _, name := range cacheVolumeNames {
+			if field == name || strings.HasPrefix(field, name+"-") && len(field) > len(name)+1 {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// CacheEntry is the part of an engine cache entry cleanup reads.
+type CacheEntry struct {
+	Description string
+	Bytes       int64
+	LastUsed    time.Time
+}
+
+// GroupCacheVolumes sums the entries of each pipeline cache volume, keeps
+// the latest use and marks volumes unused since before cutoff as stale; a
+// volume without a known last use is never stale. Entries of other volumes
+// are ignored. The result is sorted by name.
+func GroupCacheVolumes(entries []CacheEntry, cutoff time.Time) []CacheVolumeUsage {
+	byName := map[string]*CacheVolumeUsage{}
+	for _, e := range entries {
+		name := PipelineCacheVolume(e.Description)
+		if name == "" {
+			continue
+		}
+		v := byName[name]
+		if v == nil {
+			v = &CacheVolumeUsage{Name: name}
+			byName[name] = v
+		}
+		v.Bytes += e.Bytes
+		if e.LastUsed.After(v.LastUsed) {
+			v.LastUsed = e.LastUsed
+		}
+	}
+	volumes := make([]CacheVolumeUsage, 0, len(byName))
+	for _, v := range byName {
+		v.Stale = !v.LastUsed.IsZero() && v.LastUsed.Before(cutoff)
+		volumes = append(volumes, *v)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes
+}
+
+// Cleanup lists the pipeline's cache volumes with their size and last use,
+// and with Apply empties those unused for longer than MaxAge. Volumes are
+// emptied rather than deleted because the engine API has no per-volume
+// removal; the emptied entries are then released by the engine's own
+// garbage collection or by EnginePrune. Volumes whose names do not match
+// the pipeline's are never touched.
+func Cleanup(ctx context.Context, client *dagger.Client, cfg CleanupConfig) (*CleanupReport, error) {
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultCacheMaxAge
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	out := cfg.Output
+	mode := "dry run — set CLEANUP_APPLY=true to remove"
+	if cfg.Apply {
+		mode = "applying"
+	}
+	fmt.Fprintf(out, "🧹 Cache cleanup: volumes unused for more than %s (%s)\n", formatAge(cfg.MaxAge), mode)
+
+	if cfg.MinFreeSpace == 0 {
+		cfg.MinFreeSpace = DefaultMinFreeSpace
+	}
+	spaces, _ := CheckFreeSpace(cfg.DiskPaths, cfg.MinFreeSpace)
+	lowSpace := false
+	for _, space := range spaces {
+		if space.Error != "" {
+			fmt.Fprintf(out, "   💾 %-10s %s: free space unknown (%s)\n", space.Purpose, space.Path, space.Error)
+			continue
+		}
+		note := ""
+		if space.Low {
+			note, lowSpace = "  ⚠️  low", true
+		}
+		fmt.Fprintf(out, "   💾 %-10s %10s free of %s  %s%s\n", space.Purpose, FormatBytes(space.Free), FormatBytes(space.Total), space.Path, note)
+	}
+
+	cache := client.Engine().LocalCache()
+	items, err := cache.EntrySet().Entries(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryEngine, "list engine cache: %w", err)
+	}
+	var entries []CacheEntry
+	for _, item := range items {
+		description, err := item.Description(ctx)
+		if err != nil || PipelineCacheVolume(description) == "" {
+			continue
+		}
+		e := CacheEntry{Description: description}
+		if n, err := item.DiskSpaceBytes(ctx); err == nil {
+			e.Bytes = int64(n)
+		}
+		if ns, err := item.MostRecentUseTimeUnixNano(ctx); err == nil {
+			e.LastUsed = time.Unix(0, int64(ns))
+		}
+		entries = append(entries, e)
+	}
+
+	now := time.Now()
+	report := &CleanupReport{Volumes: GroupCacheVolumes(entries, now.Add(-cfg.MaxAge)), FreeSpace: spaces}
+	if len(report.Volumes) == 0 {
+		fmt.Fprintln(out, "   ℹ️  No pipeline cache volumes found in the engine cache")
+	}
+	for i := range report.Volumes {
+		v := &report.Volumes[i]
+		lastUsed, status := "unknown", "kept"
+		if !v.LastUsed.IsZero() {
+			lastUsed = formatAge(now.Sub(v.LastUsed)) + " ago"
+		}
+		if v.Stale {
+			status = "stale"
+		}
+		fmt.Fprintf(out, "   %-24s %10s  last used %-10s %s\n", v.Name, FormatBytes(v.Bytes), lastUsed, status)
+		if !v.Stale {
+			continue
+		}
+		if !cfg.Apply {
+			report.Reclaimable += v.Bytes
+			continue
+		}
+		// The unique variable keeps the engine from reusing an earlier cleanup's result
+		_, err := client.Container().From(BaseImage).
+			WithEnvVariable(cacheBusterEnv, now.Format(time.RFC3339Nano)).
+			WithMountedCache("/cache", client.CacheVolume(v.Name), dagger.ContainerWithMountedCacheOpts{
+				Sharing: dagger.CacheSharingModeLocked,
+			}).
+			WithExec([]string{"find", "/cache", "-mindepth", "1", "-delete"}).
+			Sync(ctx)
+		if err != nil {
+			fmt.Fprintf(out, "      ❌ Could not empty %s: %v\n", v.Name, err)
+			continue
+		}
+		v.Emptied = true
+		report.Reclaimable += v.Bytes
+		fmt.Fprintf(out, "      🗑️  Emptied %s\n", v.Name)
+	}
+	if report.Reclaimable > 0 {
+		verb := "Would reclaim"
+		if cfg.Apply {
+			verb = "Reclaimed"
+		}
+		fmt.Fprintf(out, "   %s about %s from stale volumes\n", verb, FormatBytes(report.Reclaimable))
+	}
+	if lowSpace && !cfg.Apply {
+		fmt.Fprintf(out, "   ⚠️  Free space is under %s — rerun with CLEANUP_APPLY=true, and CLEANUP_ENGINE_PRUNE=true to release the engine cache\n", FormatBytes(2*cfg.MinFreeSpace))
+	}
+
+	if cfg.EnginePrune {
+		if !cfg.Apply {
+			fmt.Fprintln(out, "   ⏭️  Engine prune skipped in a dry run")
+			return report, nil
+		}
+		fmt.Fprintln(out, "   🧹 Pruning the engine's releasable build cache...")
+		if err := cache.Prune(ctx); err != nil {
+			return report, Errorf(CategoryEngine, "prune engine cache: %w", err)
+		}
+		report.Pruned = true
+		fmt.Fprintln(out, "   ✅ Engine cache pruned")
+	}
+	return report, nil
+}
+
+// formatAge renders a duration in days, or hours below a day.
+func formatAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
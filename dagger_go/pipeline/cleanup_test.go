@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPipelineCacheVolume tests that only the pipeline's volume names are matched
+func TestPipelineCacheVolume(t *testing.T) {
+	cases := map[string]string{
+		`cached mount /root/.cache/pip with id "pip-cache"`: "pip-cache",
+		"cache volume apt-cache-billing":                    "apt-cache-billing",
+		"cache volume trivy-cache":                          "trivy-cache",
+		"cache volume pip-cache-":                           "",
+		"cache volume my-pip-cache":                         "",
+		"cache volume node-modules":                         "",
+		"pulled python:3.14-slim":                           "",
+	}
+	for description, want := range cases {
+		if got := PipelineCacheVolume(description); got != want {
+			t.Fatalf("PipelineCacheVolume(%q) = %q, want %q", description, got, want)
+		}
+	}
+	fmt.Println("✅ Only the pipeline's cache volumes are matched")
+}
+
+// TestGroupCacheVolumes tests per-volume totals and the stale cutoff
+func TestGroupCacheVolumes(t *testing.T) {
+	now := time.Now()
+	entries := []CacheEntry{
+		{Description: "cache volume pip-cache", Bytes: 100, LastUsed: now.Add(-40 * 24 * time.Hour)},
+		{Description: "cache volume pip-cache", Bytes: 50, LastUsed: now.Add(-2 * time.Hour)},
+		{Description: "cache volume apt-cache-billing", Bytes: 70, LastUsed: now.Add(-31 * 24 * time.Hour)},
+		{Description: "cache volume trivy-cache", Bytes: 10},
+		{Description: "cache volume someone-else", Bytes: 999, LastUsed: now.Add(-365 * 24 * time.Hour)},
+	}
+	volumes := GroupCacheVolumes(entries, now.Add(-DefaultCacheMaxAge))
+	if len(volumes) != 3 {
+		t.Fatalf("expected 3 pipeline volumes, got %+v", volumes)
+	}
+	if v := volumes[0]; v.Name != "apt-cache-billing" || !v.Stale {
+		t.Fatalf("apt-cache-billing should be stale: %+v", v)
+	}
+	if v := volumes[1]; v.Name != "pip-cache" || v.Bytes != 150 || v.Stale {
+		t.Fatalf("pip-cache was used recently and totals 150 bytes: %+v", v)
+	}
+	if v := volumes[2]; v.Name != "trivy-cache" || v.Stale {
+		t.Fatalf("a volume without a known last use is never stale: %+v", v)
+	}
+	fmt.Println("✅ Cache volumes grouped and aged")
+}
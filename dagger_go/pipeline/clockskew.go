@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultClockSkewThreshold is how far the local clock may be from the Git
+// host's before the run warns.
+const DefaultClockSkewThreshold = 2 * time.Minute
+
+// clockSkewTimeout bounds the request for the Date header, so an
+// unreachable host delays the run by no more than this.
+const clockSkewTimeout = 10 * time.Second
+
+// ClockSkew is how far the local clock is from a server's, recorded in the
+// run report.
+type ClockSkew struct {
+	Server           string    `json:"server"`
+	Local            time.Time `json:"local"`
+	Remote           time.Time `json:"remote"`
+	SkewSeconds      float64   `json:"skew_seconds"` // positive when the local clock is ahead
+	ThresholdSeconds float64   `json:"threshold_seconds"`
+}
+
+// Skew is the local clock minus the server's.
+func (s *ClockSkew) Skew() time.Duration {
+	return time.Duration(s.SkewSeconds * float64(time.Second))
+}
+
+// MeasureClockSkew sends a HEAD request to url and compares the local
+// clock, at the midpoint of the round trip, with the Date header of the
+// response, whatever its status. now is time.Now outside tests.
+func MeasureClockSkew(ctx context.Context, client *http.Client, url string, now func() time.Time) (*ClockSkew, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	sent := now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	received := now()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return nil, fmt.Errorf("HEAD %s: no usable Date header (%q)", url, resp.Header.Get("Date"))
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	// Date has a resolution of one second; a skew inside it is no skew
+	skew := local.Sub(remote)
+	if skew > -time.Second && skew < time.Second {
+		skew = 0
+	}
+	return &ClockSkew{Server: url, Local: local.UTC(), Remote: remote.UTC(), SkewSeconds: skew.Round(time.Second).Seconds()}, nil
+}
+
+// isCertificateTimeError reports whether err is a TLS certificate that is
+// expired or not yet valid at the local time, the error a wrong clock
+// gives before any Date header can be read.
+func isCertificateTimeError(err error) bool {
+	var invalid x509.CertificateInvalidError
+	return errors.As(err, &invalid) && invalid.Reason == x509.Expired
+}
+
+// describeSkew is d as "N minutes ahead of" or "behind".
+func describeSkew(d time.Duration) string {
+	direction := "ahead of"
+	if d < 0 {
+		direction, d = "behind", -d
+	}
+	return fmt.Sprintf("%s %s", d.Round(time.Second), direction)
+}
+
+// checkClockSkew compares the local clock with the Date header of the Git
+// host before anything validates a certificate or a token. Over
+// ClockSkewThreshold it warns, and with ClockSkewStrict fails a publishing
+// run. The check is advisory otherwise: request errors only print a note.
+func (p *Pipeline) checkClockSkew(ctx context.Context) error {
+	cfg := p.cfg
+	if cfg.ClockSkewThreshold < 0 || cfg.HTTPClient == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, clockSkewTimeout)
+	defer cancel()
+	server := "https://" + cfg.GitHost
+	skew, err := MeasureClockSkew(ctx, cfg.HTTPClient, server, time.Now)
+	if err != nil {
+		if isCertificateTimeError(err) {
+			p.warnf("clock-skew", "\n⚠️  The TLS certificate of %s is not valid at the local time (%s) — the system clock is probably wrong: %v\n",
+				cfg.GitHost, time.Now().UTC().Format(time.RFC3339), err)
+			return nil
+		}
+		p.warnf("clock-check", "   ⚠️  Could not check the clock against %s: %v\n", cfg.GitHost, err)
+		return nil
+	}
+	skew.ThresholdSeconds = cfg.ClockSkewThreshold.Seconds()
+	p.report.ClockSkew = skew
+	d := skew.Skew()
+	if d.Abs() <= cfg.ClockSkewThreshold {
+		return nil
+	}
+	p.warnf("clock-skew", "\n⚠️  The local clock is %s %s (CLOCK_SKEW_THRESHOLD=%s)\n"+
+		"   Expect x509 \"certificate is not yet valid\" or \"certificate has expired\" errors from pip and git,\n"+
+		"   and GitHub rejecting tokens as issued in the future or expired. Sync the clock and rerun.\n",
+		describeSkew(d), cfg.GitHost, cfg.ClockSkewThreshold)
+	if cfg.ClockSkewStrict && cfg.RunPublish {
+		return Errorf(CategoryConfig, "the local clock is %s %s (threshold %s, CLOCK_SKEW_STRICT=true): sync the clock before publishing",
+			describeSkew(d), cfg.GitHost, cfg.ClockSkewThreshold)
+	}
+	return nil
+}
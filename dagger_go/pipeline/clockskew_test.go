@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dateServer answers every request with a Date header offset from the local clock.
+func dateServer(t *testing.T, offset time.Duration) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	}))
+	// The client rejecting the certificate on purpose is not worth a log line
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestMeasureClockSkew tests comparing the local clock with a Date header
+func TestMeasureClockSkew(t *testing.T) {
+	server := dateServer(t, -25*time.Minute)
+	skew, err := MeasureClockSkew(context.Background(), server.Client(), server.URL, time.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := skew.Skew(); d < 24*time.Minute || d > 26*time.Minute {
+		t.Fatalf("the local clock should be about 25m ahead, got %s", d)
+	}
+	if describeSkew(25*time.Minute) != "25m0s ahead of" || describeSkew(-90*time.Second) != "1m30s behind" {
+		t.Fatalf("unexpected description %q", describeSkew(-90*time.Second))
+	}
+
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	transport.TLSClientConfig.Time = func() time.Time { return time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC) }
+	_, err = MeasureClockSkew(context.Background(), &http.Client{Transport: transport}, server.URL, time.Now)
+	if !isCertificateTimeError(err) {
+		t.Fatalf("a certificate expired at the local time should be recognised, got %v", err)
+	}
+	fmt.Println("✅ Clock skew measured against the Date header")
+}
+
+// TestCheckClockSkew tests the warning, strict mode for publishing runs and the report entry
+func TestCheckClockSkew(t *testing.T) {
+	cases := []struct {
+		name     string
+		offset   time.Duration
+		strict   bool
+		wantErr  bool
+		wantWarn bool
+	}{
+		{"in sync", 0, true, false, false},
+		{"behind warns", 20 * time.Minute, false, false, true},
+		{"strict fails publishing", 20 * time.Minute, true, true, true},
+	}
+	for _, c := range cases {
+		server := dateServer(t, c.offset)
+		var out bytes.Buffer
+		p, err := New(Config{
+			RepoName: "cert-parser", GitUser: "org", GitHost: strings.TrimPrefix(server.URL, "https://"),
+			ClockSkewStrict: c.strict, RunBuild: true, RunPublish: true, RegistryToken: "pat",
+			HTTPClient: server.Client(), Output: &out,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.checkClockSkew(context.Background())
+		if (err != nil) != c.wantErr || (err != nil && CategoryOf(err) != CategoryConfig) {
+			t.Fatalf("%s: err = %v", c.name, err)
+		}
+		if warned := strings.Contains(out.String(), "behind "+p.cfg.GitHost+" (CLOCK_SKEW_THRESHOLD=2m0s)"); warned != c.wantWarn {
+			t.Fatalf("%s: warning = %v in %q", c.name, warned, out.String())
+		}
+		if p.report.ClockSkew == nil || p.report.ClockSkew.ThresholdSeconds != 120 {
+			t.Fatalf("%s: report = %+v", c.name, p.report.ClockSkew)
+		}
+	}
+	fmt.Println("✅ Clock skew warned about and recorded")
+}
@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output modes of the binaries (OUTPUT).
+const (
+	OutputFull    = "full"
+	OutputCompact = "compact"
+)
+
+// compactExcerptLines is how many lines of a failed stage's output the
+// compact mode shows.
+const compactExcerptLines = 30
+
+// ParseOutputMode parses OUTPUT; empty is OutputFull.
+func ParseOutputMode(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", OutputFull:
+		return OutputFull, nil
+	case OutputCompact:
+		return OutputCompact, nil
+	}
+	return "", fmt.Errorf("unknown output mode %q (use %s or %s)", value, OutputFull, OutputCompact)
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// CompactOutput renders a run as one line per stage:
+//
+//	[2/4] unit-tests … 1m23s ✅
+//
+// On a terminal the line of the running stage updates in place with its
+// elapsed time; otherwise each line is printed once the stage ends. It is
+// also the io.Writer the detailed output goes to: the detail is not shown,
+// except the last compactExcerptLines lines of a failed stage.
+type CompactOutput struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	planned []string
+	seen    int
+	// The running stage, its line prefix and start
+	stage   string
+	prefix  string
+	started time.Time
+	ticker  *time.Ticker
+	// Detail since the running stage started, or since the last one ended
+	tail    []string
+	partial string
+	failed  bool
+	now     func() time.Time
+}
+
+// NewCompactOutput renders to out, updating lines in place when tty is
+// set (IsTerminal).
+func NewCompactOutput(out io.Writer, tty bool) *CompactOutput {
+	return &CompactOutput{out: out, tty: tty, now: time.Now}
+}
+
+// Write keeps the detail for failure excerpts.
+func (c *CompactOutput) Write(data []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lines := strings.Split(c.partial+string(data), "\n")
+	c.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		// Progress bars redraw with \r; only the last state counts
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		c.tail = append(c.tail, line)
+		if len(c.tail) > compactExcerptLines {
+			c.tail = c.tail[len(c.tail)-compactExcerptLines:]
+		}
+	}
+	return len(data), nil
+}
+
+// Attach renders the stages t records. planned are the stages of the run
+// (PlannedStages), which give the "[2/4]" positions.
+func (c *CompactOutput) Attach(t *Tracker, planned []string) {
+	c.mu.Lock()
+	c.planned = planned
+	c.mu.Unlock()
+	t.OnTransition(c.transition)
+}
+
+// transition prints the line of a stage event.
+func (c *CompactOutput) transition(rec StageRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch rec.Status {
+	case StageRunning:
+		c.stage, c.prefix, c.started = rec.Name, c.position(rec.Name)+" "+rec.Name+" …", c.now()
+		c.tail, c.partial = nil, ""
+		if c.tty {
+			fmt.Fprintf(c.out, "\r\x1b[K%s", c.prefix)
+			c.ticker = time.NewTicker(time.Second)
+			go c.tick(c.ticker)
+		}
+	case StagePassed:
+		c.endLocked(rec, "✅")
+	case StageFailed:
+		c.endLocked(rec, "❌")
+		c.excerptLocked(rec.Message)
+	case StageSkipped:
+		line := c.position(rec.Name) + " " + rec.Name + " ⏭️  skipped"
+		if rec.Message != "" {
+			line += ": " + rec.Message
+		}
+		fmt.Fprintln(c.out, line)
+	}
+}
+
+// position is the "[2/4]" of a stage; a stage that was not planned counts
+// on from the last one.
+func (c *CompactOutput) position(name string) string {
+	c.seen++
+	if i := slices.Index(c.planned, name); i >= 0 {
+		c.seen = i + 1
+	}
+	return fmt.Sprintf("[%d/%d]", c.seen, max(len(c.planned), c.seen))
+}
+
+// tick redraws the running stage's line with its elapsed time.
+func (c *CompactOutput) tick(ticker *time.Ticker) {
+	for range ticker.C {
+		c.mu.Lock()
+		if c.ticker != ticker {
+			c.mu.Unlock()
+			return
+		}
+		fmt.Fprintf(c.out, "\r\x1b[K%s %s", c.prefix, compactDuration(c.now().Sub(c.started)))
+		c.mu.Unlock()
+	}
+}
+
+// endLocked stops the running stage's line with its duration and mark.
+func (c *CompactOutput) endLocked(rec StageRecord, mark string) {
+	c.stopTickerLocked()
+	prefix := c.prefix
+	if c.stage != rec.Name {
+		prefix = c.position(rec.Name) + " " + rec.Name + " …"
+	}
+	if c.tty {
+		fmt.Fprint(c.out, "\r\x1b[K")
+	}
+	fmt.Fprintf(c.out, "%s %s %s\n", prefix, compactDuration(time.Duration(rec.DurationSeconds*float64(time.Second))), mark)
+	c.stage = ""
+}
+
+// excerptLocked prints the error of a failure and the end of its detail.
+func (c *CompactOutput) excerptLocked(msg string) {
+	c.failed = true
+	if msg != "" {
+		fmt.Fprintf(c.out, "   %s\n", firstLine(msg))
+	}
+	for _, line := range c.tail {
+		fmt.Fprintf(c.out, "   │ %s\n", line)
+	}
+	c.tail = nil
+}
+
+func (c *CompactOutput) stopTickerLocked() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+		c.ticker = nil
+	}
+}
+
+// Close ends the rendering after the run with its result. A failure that
+// no stage line showed, such as a clone error, gets the excerpt of the
+// detail before it.
+func (c *CompactOutput) Close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopTickerLocked()
+	if c.tty && c.stage != "" {
+		fmt.Fprintln(c.out)
+	}
+	if err != nil && !c.failed {
+		fmt.Fprintln(c.out, "❌ failed")
+		c.excerptLocked(err.Error())
+	}
+}
+
+// CaptureStdout sends what is written to os.Stdout from now on into c, so
+// the startup output of the binaries becomes detail too; c keeps writing
+// to the stdout it was created with. The returned function restores
+// os.Stdout and waits for the detail already written.
+func (c *CompactOutput) CaptureStdout() (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		io.Copy(c, r)
+		close(done)
+	}()
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+		r.Close()
+	}, nil
+}
+
+// compactDuration is d as 1m23s, in whole seconds.
+func compactDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompactOutput tests the one-line-per-stage rendering and the excerpt of a failed stage
+func TestCompactOutput(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker("")
+	tracker.now = func() time.Time { return clock }
+
+	var out bytes.Buffer
+	c := NewCompactOutput(&out, false)
+	c.Attach(tracker, []string{StageUnitTests, StageIntegrationTests, StageLint, StageBuild})
+
+	fmt.Fprintln(c, "📥 Cloning repository")
+	tracker.StartStage(StageUnitTests)
+	fmt.Fprintln(c, "collected 120 items")
+	clock = clock.Add(83 * time.Second)
+	tracker.PassStage()
+	tracker.SkipStage(StageIntegrationTests, "Docker not available")
+	tracker.StartStage(StageLint)
+	for i := range 40 {
+		fmt.Fprintf(c, "src/x.py:%d:1: F401 unused import\n", i)
+	}
+	fmt.Fprint(c, "progress 10%\rprogress 100%\n")
+	clock = clock.Add(4 * time.Second)
+	tracker.FailStage(errors.New("ruff found 41 problems\nsee above"))
+	c.Close(errors.New("stage lint: ruff found 41 problems"))
+
+	s := out.String()
+	for _, want := range []string{
+		"[1/4] unit-tests … 1m23s ✅\n",
+		"[2/4] integration-tests ⏭️  skipped: Docker not available\n",
+		"[3/4] lint … 4s ❌\n   ruff found 41 problems\n",
+		"   │ progress 100%\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("output should contain %q:\n%s", want, s)
+		}
+	}
+	for _, unwanted := range []string{"Cloning", "collected 120", "src/x.py:10:1", "progress 10%", "❌ failed"} {
+		if strings.Contains(s, unwanted) {
+			t.Fatalf("output should not contain %q:\n%s", unwanted, s)
+		}
+	}
+	if n := strings.Count(s, "   │ "); n != compactExcerptLines {
+		t.Fatalf("excerpt should have %d lines, got %d", compactExcerptLines, n)
+	}
+
+	// A failure outside any stage shows the detail before it
+	out.Reset()
+	c = NewCompactOutput(&out, true)
+	c.Attach(NewTracker(""), nil)
+	fmt.Fprintln(c, "fatal: repository not found")
+	c.Close(errors.New("failed to get commit SHA"))
+	if s := out.String(); !strings.Contains(s, "❌ failed\n   failed to get commit SHA\n   │ fatal: repository not found\n") {
+		t.Fatalf("unexpected output:\n%s", s)
+	}
+	fmt.Println("✅ Compact output renders one line per stage")
+}
+
+// TestCompactOutputTerminal tests the in-place line of the running stage on a terminal
+func TestCompactOutputTerminal(t *testing.T) {
+	tracker := NewTracker("")
+	var out bytes.Buffer
+	c := NewCompactOutput(&out, true)
+	c.Attach(tracker, []string{StageUnitTests})
+	tracker.StartStage(StageUnitTests)
+	tracker.PassStage()
+	c.Close(nil)
+	if s := out.String(); !strings.HasPrefix(s, "\r\x1b[K[1/1] unit-tests …\r\x1b[K[1/1] unit-tests … 0s ✅\n") {
+		t.Fatalf("unexpected output %q", s)
+	}
+
+	for in, want := range map[string]string{"": OutputFull, "full": OutputFull, " Compact ": OutputCompact} {
+		if got, err := ParseOutputMode(in); err != nil || got != want {
+			t.Fatalf("ParseOutputMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseOutputMode("quiet"); err == nil {
+		t.Fatal("unknown modes should be rejected")
+	}
+	fmt.Println("✅ Compact output updates the running stage in place on a terminal")
+}
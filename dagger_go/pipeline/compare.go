@@ -0,0 +1,296 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets in the recorded configuration.
+const redactedValue = "***"
+
+// secretConfigFields are recorded only as set or unset.
+var secretConfigFields = map[string]bool{
+	"GitToken":                true,
+	"GitDependencyToken":      true,
+	"RegistryToken":           true,
+	"DockerHubToken":          true,
+	"PackagePublish.Password": true,
+}
+
+// measuredConfigFields are measurements passed in through the
+// configuration rather than settings; they would make every two runs
+// differ, so they are not recorded.
+var measuredConfigFields = map[string]bool{
+	"ConnectTime": true,
+}
+
+// separateConfigFields have their own section in the report.
+var separateConfigFields = map[string]bool{
+	"OptionSources": true,
+}
+
+// opaqueURLFields are URLs whose path or query may itself be the secret
+// (webhook tokens, signed store URLs); only their scheme and host are kept.
+var opaqueURLFields = map[string]bool{
+	"DeployWebhook":   true,
+	"FlakyHistoryURL": true,
+}
+
+// EffectiveConfig flattens the configuration into field name → value for
+// the run report, with tokens and passwords redacted and credentials
+// stripped from URLs. Functions, writers, clients, the profile and the
+// option sources (recorded separately) are left out; nested structs use
+// "Outer.Field" keys.
+func EffectiveConfig(cfg Config) map[string]string {
+	values := map[string]string{}
+	flattenConfig(reflect.ValueOf(cfg), "", values)
+	return values
+}
+
+func flattenConfig(v reflect.Value, prefix string, values map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + field.Name
+		if measuredConfigFields[name] || separateConfigFields[name] {
+			continue
+		}
+		switch value.Kind() {
+		case reflect.Func, reflect.Interface, reflect.Pointer, reflect.Chan:
+			continue
+		case reflect.Struct:
+			flattenConfig(value, name+".", values)
+			continue
+		}
+		s := configValueString(value)
+		switch {
+		case secretConfigFields[name] && s != "":
+			s = redactedValue
+		case strings.Contains(s, "://"):
+			s = redactConfigURL(s, opaqueURLFields[name])
+		}
+		values[name] = s
+	}
+}
+
+// configValueString renders a configuration value; lists and maps are sorted
+// so equal settings compare equal.
+func configValueString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			items = append(items, fmt.Sprintf("%v=%v", key.Interface(), v.MapIndex(key).Interface()))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// redactConfigURL drops the password and query of a URL, or with opaque
+// everything after the host.
+func redactConfigURL(raw string, opaque bool) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	redacted := u.Scheme + "://"
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			redacted += u.User.Username() + ":" + redactedValue + "@"
+		} else {
+			redacted += u.User.Username() + "@"
+		}
+	}
+	redacted += u.Host
+	if opaque {
+		if u.Path != "" || u.RawQuery != "" {
+			redacted += "/" + redactedValue
+		}
+		return redacted
+	}
+	redacted += u.Path
+	if u.RawQuery != "" {
+		redacted += "?" + redactedValue
+	}
+	return redacted
+}
+
+// ReportDifference is one value that differs between two run reports. Old
+// or New is empty when the value is absent from that report.
+type ReportDifference struct {
+	Section string `json:"section"` // config, environment, dependencies or stages
+	Key     string `json:"key"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// ReadReport loads a pipeline-report.json.
+func ReadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// CompareReports lists what differs between an older and a newer run:
+// configuration, environment fingerprint, resolved dependency versions and
+// stage outcomes, plus stage durations that changed noticeably (at least
+// five seconds and a quarter). Differences are ordered by section, then key.
+func CompareReports(older, newer *Report) []ReportDifference {
+	var diffs []ReportDifference
+	diffs = append(diffs, diffMaps("config", older.Config, newer.Config)...)
+	diffs = append(diffs, diffMaps("environment", environmentFields(older.Environment), environmentFields(newer.Environment))...)
+
+	var oldDeps, newDeps map[string]string
+	if older.Dependencies != nil {
+		oldDeps = older.Dependencies.Versions
+	}
+	if newer.Dependencies != nil {
+		newDeps = newer.Dependencies.Versions
+	}
+	diffs = append(diffs, diffMaps("dependencies", oldDeps, newDeps)...)
+
+	oldStages, newStages := map[string]string{}, map[string]string{}
+	oldTimes, newTimes := map[string]float64{}, map[string]float64{}
+	for _, s := range older.Status.Stages {
+		oldStages[s.Name], oldTimes[s.Name] = string(s.Status), s.DurationSeconds
+	}
+	for _, s := range newer.Status.Stages {
+		newStages[s.Name], newTimes[s.Name] = string(s.Status), s.DurationSeconds
+	}
+	diffs = append(diffs, diffMaps("stages", oldStages, newStages)...)
+	var slower []ReportDifference
+	for name, before := range oldTimes {
+		after, ok := newTimes[name]
+		if !ok || oldStages[name] != newStages[name] {
+			continue
+		}
+		if delta := math.Abs(after - before); delta >= 5 && delta >= 0.25*math.Max(before, after) {
+			slower = append(slower, ReportDifference{
+				Section: "stages",
+				Key:     name + " duration",
+				Old:     fmt.Sprintf("%.1fs", before),
+				New:     fmt.Sprintf("%.1fs", after),
+			})
+		}
+	}
+	sort.Slice(slower, func(i, j int) bool { return slower[i].Key < slower[j].Key })
+	return append(diffs, slower...)
+}
+
+// environmentFields flattens a fingerprint by its JSON field names.
+func environmentFields(env *Environment) map[string]string {
+	if env == nil {
+		return nil
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+	values := map[string]string{}
+	for key, value := range fields {
+		values[key] = fmt.Sprint(value)
+	}
+	return values
+}
+
+// diffMaps lists the keys whose values differ, sorted by key.
+func diffMaps(section string, older, newer map[string]string) []ReportDifference {
+	keys := map[string]bool{}
+	for k := range older {
+		keys[k] = true
+	}
+	for k := range newer {
+		keys[k] = true
+	}
+	var diffs []ReportDifference
+	for k := range keys {
+		if older[k] != newer[k] {
+			diffs = append(diffs, ReportDifference{Section: section, Key: k, Old: older[k], New: newer[k]})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
+
+// PrintComparison prints the differences grouped by section.
+func PrintComparison(w io.Writer, olderName, newerName string, diffs []ReportDifference) {
+	fmt.Fprintf(w, "🔀 Comparing %s → %s\n", olderName, newerName)
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "   ✅ No differences in configuration, environment, dependencies or stages")
+		return
+	}
+	orAbsent := func(s string) string {
+		if s == "" {
+			return "(unset)"
+		}
+		return s
+	}
+	section := ""
+	for _, d := range diffs {
+		if d.Section != section {
+			section = d.Section
+			fmt.Fprintf(w, "\n   %s:\n", section)
+		}
+		fmt.Fprintf(w, "      %-32s %s → %s\n", d.Key, orAbsent(d.Old), orAbsent(d.New))
+	}
+	fmt.Fprintf(w, "\n   %d difference(s)\n", len(diffs))
+}
+
+// CompareReportFiles reads two pipeline-report.json files and prints their
+// differences, as text or, with asJSON, as a JSON array.
+func CompareReportFiles(w io.Writer, olderPath, newerPath string, asJSON bool) ([]ReportDifference, error) {
+	older, err := ReadReport(olderPath)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "read report: %w", err)
+	}
+	newer, err := ReadReport(newerPath)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "read report: %w", err)
+	}
+	diffs := CompareReports(older, newer)
+	if !asJSON {
+		PrintComparison(w, olderPath, newerPath, diffs)
+		return diffs, nil
+	}
+	if diffs == nil {
+		diffs = []ReportDifference{}
+	}
+	data, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(w, string(data))
+	return diffs, nil
+}
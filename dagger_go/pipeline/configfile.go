@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the pipeline config file in the binaries.
+const ConfigFileEnv = "PIPELINE_CONFIG"
+
+// DefaultConfigFile is read from the working directory when
+// PIPELINE_CONFIG is unset; it may be absent.
+const DefaultConfigFile = "pipeline.yaml"
+
+// ConfigSetting is one option set in a config file.
+type ConfigSetting struct {
+	Env    string // the option's variable, e.g. RUN_UNIT_TESTS
+	Value  string
+	Line   int
+	Source string // SourceFile once applied, SourceEnv when the environment or a flag overrides it
+}
+
+// ConfigFile is a pipeline.yaml: the binaries' options as a flat mapping,
+// keyed by option name (run-unit-tests) or variable (RUN_UNIT_TESTS).
+// Lists may be YAML sequences. The environment overrides the file.
+type ConfigFile struct {
+	Path     string
+	Settings []ConfigSetting // in file order
+}
+
+// LoadConfig reads and validates a config file. Unknown keys, nested
+// mappings, secrets and keys set twice are CategoryConfig errors; every
+// unknown key is listed, with the option it is likely a typo of.
+func LoadConfig(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "%s: %v", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, Errorf(CategoryConfig, "%s: %v", path, err)
+	}
+	file := &ConfigFile{Path: path}
+	if len(doc.Content) == 0 {
+		return file, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, Errorf(CategoryConfig, "%s: expected a mapping of options, e.g. run-unit-tests: true", path)
+	}
+	var problems []string
+	seen := map[string]int{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+		env := strings.ToUpper(strings.ReplaceAll(key.Value, "-", "_"))
+		o, ok := LookupOption(env)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("line %d: unknown option %s%s", key.Line, key.Value, configSuggestion(env)))
+			continue
+		case o.Type == "secret":
+			problems = append(problems, fmt.Sprintf("line %d: %s is a secret; set %s in the environment", key.Line, key.Value, env))
+			continue
+		case env == ConfigFileEnv:
+			problems = append(problems, fmt.Sprintf("line %d: %s can only be set in the environment", key.Line, key.Value))
+			continue
+		case seen[env] != 0:
+			problems = append(problems, fmt.Sprintf("line %d: %s is already set on line %d", key.Line, key.Value, seen[env]))
+			continue
+		}
+		seen[env] = key.Line
+		value, err := configValue(node)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %s: %v", key.Line, key.Value, err))
+			continue
+		}
+		file.Settings = append(file.Settings, ConfigSetting{Env: env, Value: value, Line: key.Line})
+	}
+	if len(problems) > 0 {
+		return nil, Errorf(CategoryConfig, "%s:\n   %s", path, strings.Join(problems, "\n   "))
+	}
+	return file, nil
+}
+
+// configSuggestion is the " (did you mean ...?)" hint for an unknown key.
+func configSuggestion(env string) string {
+	if s := SuggestOptions([]string{env + "="}); len(s) > 0 {
+		return fmt.Sprintf(" (did you mean %s?)", Option{Env: s[0].Suggestion}.Name())
+	}
+	return ""
+}
+
+// configValue is the text of a scalar as written, so 027 stays 027, or
+// a sequence of scalars joined with commas; null is empty.
+func configValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", errors.New("list items must be plain values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", errors.New("expected a value or a list, not a mapping")
+}
+
+// ResolveConfigFile loads PIPELINE_CONFIG, or DefaultConfigFile when it is
+// unset and exists; nil without either.
+func ResolveConfigFile(lookupEnv func(string) (string, bool)) (*ConfigFile, error) {
+	path, ok := lookupEnv(ConfigFileEnv)
+	if !ok || path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err != nil {
+			return nil, nil
+		}
+		path = DefaultConfigFile
+	}
+	return LoadConfig(path)
+}
+
+// Apply exports the file's settings with setenv for the variables the
+// environment leaves unset, so the binaries parse them like any other
+// variable; the others are marked as overridden.
+func (f *ConfigFile) Apply(lookupEnv func(string) (string, bool), setenv func(string, string) error) error {
+	for i, s := range f.Settings {
+		if value, ok := lookupEnv(s.Env); ok && value != "" {
+			f.Settings[i].Source = SourceEnv
+			continue
+		}
+		if err := setenv(s.Env, s.Value); err != nil {
+			return err
+		}
+		f.Settings[i].Source = SourceFile
+	}
+	return nil
+}
+
+// applied reports whether the file set env, i.e. the environment did not
+// override it.
+func (f *ConfigFile) applied(env string) bool {
+	if f == nil {
+		return false
+	}
+	for _, s := range f.Settings {
+		if s.Env == env {
+			return s.Source == SourceFile
+		}
+	}
+	return false
+}
+
+// PrintConfigFile prints the options of an applied config file, in
+// variable order.
+func PrintConfigFile(out io.Writer, f *ConfigFile) {
+	fmt.Fprintf(out, "📄 Config file: %s (%d options, the environment overrides them)\n", f.Path, len(f.Settings))
+	settings := append([]ConfigSetting(nil), f.Settings...)
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Env < settings[j].Env })
+	for _, s := range settings {
+		o, _ := LookupOption(s.Env)
+		if s.Source == SourceEnv {
+			fmt.Fprintf(out, "   %-28s %s (overridden by the environment or a flag)\n", s.Env, optionDisplayValue(o, s.Value))
+		} else {
+			fmt.Fprintf(out, "   %-28s %s\n", s.Env, optionDisplayValue(o, s.Value))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes content to a pipeline.yaml in a temporary directory.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultConfigFile)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfig tests reading options from a pipeline.yaml
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, `# committed pipeline settings
+username: org
+REPO_NAME: cert-parser
+run-unit-tests: true
+run-publish: no
+python-versions-unused: ~
+`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "line 6: unknown option python-versions-unused") {
+		t.Fatalf("expected the unknown key with its line, got %v", err)
+	}
+
+	path = writeConfigFile(t, `username: org
+REPO_NAME: cert-parser
+run-unit-tests: true
+run-publish: no
+test-umask: 027
+git-dependency-hosts: [github.com, git@gitlab.corp]
+image-name: ~
+stage-env-unit-tests: TZ=UTC
+`)
+	file, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ConfigSetting{
+		{Env: "USERNAME", Value: "org", Line: 1},
+		{Env: "REPO_NAME", Value: "cert-parser", Line: 2},
+		{Env: "RUN_UNIT_TESTS", Value: "true", Line: 3},
+		{Env: "RUN_PUBLISH", Value: "no", Line: 4},
+		{Env: "TEST_UMASK", Value: "027", Line: 5},
+		{Env: "GIT_DEPENDENCY_HOSTS", Value: "github.com,git@gitlab.corp", Line: 6},
+		{Env: "IMAGE_NAME", Value: "", Line: 7},
+		{Env: "STAGE_ENV_UNIT_TESTS", Value: "TZ=UTC", Line: 8},
+	}
+	if len(file.Settings) != len(want) {
+		t.Fatalf("unexpected settings %+v", file.Settings)
+	}
+	for i := range want {
+		if file.Settings[i] != want[i] {
+			t.Fatalf("setting %d: got %+v, want %+v", i, file.Settings[i], want[i])
+		}
+	}
+	if empty, err := LoadConfig(writeConfigFile(t, "# nothing yet\n")); err != nil || len(empty.Settings) != 0 {
+		t.Fatalf("an empty file should load: %+v, %v", empty, err)
+	}
+	fmt.Println("✅ Config file options loaded by name or variable")
+}
+
+// TestLoadConfigErrors tests that every problem of a file is reported at once
+func TestLoadConfigErrors(t *testing.T) {
+	_, err := LoadConfig(writeConfigFile(t, `run-unit-test: true
+cr-pat: ghp_secret
+RUN_LINT: false
+run-lint: true
+tool-images:
+  trivy: mirror/trivy:1
+pipeline-config: other.yaml
+`))
+	if CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	for _, want := range []string{
+		"line 1: unknown option run-unit-test (did you mean run-unit-tests?)",
+		"line 2: cr-pat is a secret; set CR_PAT in the environment",
+		"line 4: run-lint is already set on line 3",
+		"line 5: unknown option tool-images",
+		"line 7: pipeline-config can only be set in the environment",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error should contain %q:\n%v", want, err)
+		}
+	}
+	for content, want := range map[string]string{
+		"- run-unit-tests\n":               "expected a mapping of options",
+		"image-name: {name: x}\n":          "not a mapping",
+		"git-dependency-hosts: [[a]]\n":    "list items must be plain values",
+		"run-unit-tests: true\n  bad: 1\n": "yaml",
+	} {
+		if _, err := LoadConfig(writeConfigFile(t, content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected an error with %q, got %v", content, want, err)
+		}
+	}
+	fmt.Println("✅ Config file problems listed together")
+}
+
+// TestApplyConfigFile tests that set variables override the file
+func TestApplyConfigFile(t *testing.T) {
+	env := map[string]string{"RUN_PUBLISH": "true", "IMAGE_NAME": ""}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	file := &ConfigFile{Path: "pipeline.yaml", Settings: []ConfigSetting{
+		{Env: "RUN_PUBLISH", Value: "false"},
+		{Env: "IMAGE_NAME", Value: "cert-parser"},
+		{Env: "FLAKY_HISTORY_URL", Value: "https://user:pw@history.corp/x"},
+	}}
+	if err := file.Apply(lookup, func(k, v string) error { env[k] = v; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if env["RUN_PUBLISH"] != "true" || env["IMAGE_NAME"] != "cert-parser" || !file.applied("IMAGE_NAME") || file.applied("RUN_PUBLISH") {
+		t.Fatalf("unexpected environment %v, settings %+v", env, file.Settings)
+	}
+
+	var out bytes.Buffer
+	PrintConfigFile(&out, file)
+	s := out.String()
+	for _, want := range []string{"📄 Config file: pipeline.yaml (3 options", "IMAGE_NAME", "RUN_PUBLISH                  false (overridden by the environment or a flag)"} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("output should contain %q:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "pw@") {
+		t.Fatalf("credentials in the output:\n%s", s)
+	}
+	out.Reset()
+	PrintOptions(&out, lookup, nil, file, nil)
+	if strings.Contains(out.String(), "IMAGE_NAME") || !strings.Contains(out.String(), "RUN_PUBLISH") {
+		t.Fatalf("the environment listing should leave out the file's options:\n%s", out.String())
+	}
+	fmt.Println("✅ Config file applied under the environment")
+}
+
+// TestResolveConfigFile tests the default file and PIPELINE_CONFIG
+func TestResolveConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	unset := func(string) (string, bool) { return "", false }
+	if file, err := ResolveConfigFile(unset); file != nil || err != nil {
+		t.Fatalf("no default file should be no config: %+v, %v", file, err)
+	}
+	if err := os.WriteFile(DefaultConfigFile, []byte("run-lint: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if file, err := ResolveConfigFile(unset); err != nil || file.Path != DefaultConfigFile || len(file.Settings) != 1 {
+		t.Fatalf("the default file should be read: %+v, %v", file, err)
+	}
+	missing := func(string) (string, bool) { return "ci/missing.yaml", true }
+	if _, err := ResolveConfigFile(missing); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a missing PIPELINE_CONFIG should be a config error, got %v", err)
+	}
+	fmt.Println("✅ pipeline.yaml read by default, PIPELINE_CONFIG required to exist")
+}
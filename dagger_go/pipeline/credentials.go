@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Credential sources reported by ResolveCredentials.
+const (
+	CredentialPAT           = "CR_PAT"
+	CredentialWorkflowToken = "GITHUB_TOKEN"
+	CredentialNone          = "none"
+)
+
+// githubPackagesRegistry is the only registry the workflow token can push to.
+const githubPackagesRegistry = "ghcr.io"
+
+// WorkflowPermissions is the job-level block a workflow needs for the
+// pipeline to clone its own repository and publish to GHCR with GITHUB_TOKEN.
+const WorkflowPermissions = "permissions:\n  contents: read\n  packages: write"
+
+// registryPermissionErrorPattern matches a registry refusing a valid token
+// the right to push, as opposed to rejecting the token itself.
+var registryPermissionErrorPattern = regexp.MustCompile(`(?i)\b403\b|denied|permission_denied|forbidden|write_package`)
+
+// Credentials are the tokens the pipeline uses and why they were chosen.
+type Credentials struct {
+	Source        string // CredentialPAT, CredentialWorkflowToken or CredentialNone
+	Reason        string
+	GitToken      string // empty clones anonymously
+	RegistryToken string
+}
+
+// WorkflowToken reports whether the tokens are the GitHub Actions GITHUB_TOKEN.
+func (c Credentials) WorkflowToken() bool {
+	return c.Source == CredentialWorkflowToken
+}
+
+// WorkflowRepository returns the owner and name of the repository a GitHub
+// Actions workflow runs in, or empty strings outside GitHub Actions.
+func WorkflowRepository(getenv func(string) string) (owner, name string) {
+	if getenv("GITHUB_ACTIONS") != "true" {
+		return "", ""
+	}
+	owner, name, _ = strings.Cut(getenv("GITHUB_REPOSITORY"), "/")
+	if o := getenv("GITHUB_REPOSITORY_OWNER"); o != "" {
+		owner = o
+	}
+	return owner, name
+}
+
+// ResolveCredentials picks the registry and clone tokens. An explicit CR_PAT
+// always wins. Inside GitHub Actions without CR_PAT, the workflow's
+// GITHUB_TOKEN is used for GHCR (given `packages: write`) and for cloning
+// the workflow's own repository; any other repository is cloned
+// anonymously, so only a different private repository still needs CR_PAT.
+// GHCR does not accept the workflow's OIDC identity token, so GITHUB_TOKEN
+// is the ambient credential used.
+func ResolveCredentials(getenv func(string) string, gitHost, gitUser, repoName, registry string) Credentials {
+	if pat := getenv("CR_PAT"); pat != "" {
+		return Credentials{Source: CredentialPAT, Reason: "CR_PAT is set", GitToken: pat, RegistryToken: pat}
+	}
+	token := getenv("GITHUB_TOKEN")
+	if getenv("GITHUB_ACTIONS") != "true" || token == "" {
+		return Credentials{Source: CredentialNone, Reason: "CR_PAT is not set and not running in GitHub Actions with GITHUB_TOKEN"}
+	}
+
+	c := Credentials{Source: CredentialWorkflowToken}
+	var uses []string
+	if registryHost(registry) == githubPackagesRegistry {
+		c.RegistryToken = token
+		uses = append(uses, "publishing to "+githubPackagesRegistry)
+	} else {
+		uses = append(uses, fmt.Sprintf("not for %s (it only authenticates to %s)", registry, githubPackagesRegistry))
+	}
+	owner, name := WorkflowRepository(getenv)
+	serverHost := "github.com"
+	if u, err := url.Parse(getenv("GITHUB_SERVER_URL")); err == nil && u.Host != "" {
+		serverHost = u.Host
+	}
+	if strings.EqualFold(gitHost, serverHost) && strings.EqualFold(gitUser, owner) && strings.EqualFold(repoName, name) {
+		c.GitToken = token
+		uses = append(uses, "cloning "+owner+"/"+name+" (the workflow's repository)")
+	} else {
+		uses = append(uses, fmt.Sprintf("cloning %s/%s anonymously (not the workflow's repository %s/%s; set CR_PAT if it is private)", gitUser, repoName, owner, name))
+	}
+	c.Reason = "running in GitHub Actions without CR_PAT — " + strings.Join(uses, ", ")
+	return c
+}
+
+// RegistryPasswordFromEnv reads the password of a registry other than GHCR
+// from REGISTRY_PASSWORD, or from the file REGISTRY_PASSWORD_FILE names
+// (e.g. a mounted secret). It is empty when neither is set.
+func RegistryPasswordFromEnv(getenv func(string) string) (string, error) {
+	password, path := getenv("REGISTRY_PASSWORD"), getenv("REGISTRY_PASSWORD_FILE")
+	switch {
+	case password != "" && path != "":
+		return "", fmt.Errorf("set REGISTRY_PASSWORD or REGISTRY_PASSWORD_FILE, not both")
+	case path == "":
+		return password, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read REGISTRY_PASSWORD_FILE: %w", err)
+	}
+	if password = strings.TrimSpace(string(data)); password == "" {
+		return "", fmt.Errorf("REGISTRY_PASSWORD_FILE %s is empty", path)
+	}
+	return password, nil
+}
+
+// WithRegistryPassword publishes with password instead of the token the
+// credentials picked; the clone token is unchanged. An empty password
+// changes nothing.
+func (c Credentials) WithRegistryPassword(password string) Credentials {
+	if password != "" {
+		c.RegistryToken = password
+		c.Reason += "; publishing with REGISTRY_PASSWORD"
+	}
+	return c
+}
+
+// publishPermissionHint explains how to fix a push the registry refused to
+// the workflow token, or returns "" when the token is not GITHUB_TOKEN or the
+// error is not about permissions.
+func (p *Pipeline) publishPermissionHint(err error) string {
+	if !p.cfg.WorkflowToken || !registryPermissionErrorPattern.MatchString(err.Error()) {
+		return ""
+	}
+	return "\nThe workflow's GITHUB_TOKEN may not push packages. Add to the job (or workflow):\n\n" +
+		indentLines(WorkflowPermissions, "  ") +
+		"\n\nFor an existing package, also give this repository write access in the package's\n" +
+		"\"Manage Actions access\" settings, or set CR_PAT to a token with write:packages."
+}
+
+// cloneCredentialHint explains a clone failure under the workflow token, or
+// returns "" when the token is not GITHUB_TOKEN.
+func (p *Pipeline) cloneCredentialHint() string {
+	switch {
+	case !p.cfg.WorkflowToken:
+		return ""
+	case p.cfg.GitToken == "":
+		return "\nThe repository is not the workflow's own, so it was cloned without credentials;\n" +
+			"set CR_PAT to a token that can read it if it is private."
+	default:
+		return "\nThe workflow's GITHUB_TOKEN may not read the repository. Add to the job (or workflow):\n\n" +
+			indentLines(WorkflowPermissions, "  ")
+	}
+}
+
+// indentLines prefixes every line of s with indent.
+func indentLines(s, indent string) string {
+	return indent + strings.ReplaceAll(s, "\n", "\n"+indent)
+}
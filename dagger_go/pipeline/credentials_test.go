@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResolveCredentials tests the choice between CR_PAT and the workflow token
+func TestResolveCredentials(t *testing.T) {
+	actions := map[string]string{
+		"GITHUB_ACTIONS":          "true",
+		"GITHUB_TOKEN":            "ghs_workflow",
+		"GITHUB_REPOSITORY":       "Javier-Godon/cert-parser",
+		"GITHUB_REPOSITORY_OWNER": "Javier-Godon",
+		"GITHUB_SERVER_URL":       "https://github.com",
+	}
+	env := func(m map[string]string, extra ...string) func(string) string {
+		merged := map[string]string{}
+		for k, v := range m {
+			merged[k] = v
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			merged[extra[i]] = extra[i+1]
+		}
+		return func(k string) string { return merged[k] }
+	}
+
+	c := ResolveCredentials(env(actions), "github.com", "javier-godon", "cert-parser", "ghcr.io")
+	if !c.WorkflowToken() || c.GitToken != "ghs_workflow" || c.RegistryToken != "ghs_workflow" {
+		t.Fatalf("own repository in Actions should use GITHUB_TOKEN for both: %+v", c)
+	}
+
+	c = ResolveCredentials(env(actions), "github.com", "other-org", "private-lib", "ghcr.io")
+	if c.GitToken != "" || c.RegistryToken != "ghs_workflow" || !strings.Contains(c.Reason, "set CR_PAT if it is private") {
+		t.Fatalf("another repository should clone anonymously: %+v", c)
+	}
+
+	c = ResolveCredentials(env(actions), "github.com", "Javier-Godon", "cert-parser", "registry.gitlab.com")
+	if c.RegistryToken != "" || c.GitToken == "" {
+		t.Fatalf("GITHUB_TOKEN only authenticates to ghcr.io: %+v", c)
+	}
+
+	c = ResolveCredentials(env(actions, "CR_PAT", "ghp_explicit"), "github.com", "Javier-Godon", "cert-parser", "ghcr.io")
+	if c.Source != CredentialPAT || c.GitToken != "ghp_explicit" || c.RegistryToken != "ghp_explicit" {
+		t.Fatalf("an explicit CR_PAT must win: %+v", c)
+	}
+
+	c = ResolveCredentials(env(nil, "GITHUB_TOKEN", "ghs_stray"), "github.com", "Javier-Godon", "cert-parser", "ghcr.io")
+	if c.Source != CredentialNone || c.RegistryToken != "" {
+		t.Fatalf("outside Actions GITHUB_TOKEN is not used: %+v", c)
+	}
+
+	if owner, name := WorkflowRepository(env(actions)); owner != "Javier-Godon" || name != "cert-parser" {
+		t.Fatalf("WorkflowRepository = %s/%s", owner, name)
+	}
+	fmt.Println("✅ Credential source follows CR_PAT and the workflow's repository")
+}
+
+// TestWorkflowPermissionHints tests that permission failures name the permissions block
+func TestWorkflowPermissionHints(t *testing.T) {
+	p := &Pipeline{cfg: Config{WorkflowToken: true, GitToken: "ghs_workflow"}}
+	denied := errors.New("failed to push: 403 Forbidden: permission_denied: write_package")
+	hint := p.publishPermissionHint(denied)
+	if !strings.Contains(hint, "permissions:\n    contents: read\n    packages: write") {
+		t.Fatalf("publish hint does not name the permissions block:\n%s", hint)
+	}
+	if p.publishPermissionHint(errors.New("401 Unauthorized")) != "" {
+		t.Fatal("a rejected token is not a permission problem")
+	}
+	if !strings.Contains(p.cloneCredentialHint(), "contents: read") {
+		t.Fatalf("clone hint = %q", p.cloneCredentialHint())
+	}
+	p.cfg.GitToken = ""
+	if !strings.Contains(p.cloneCredentialHint(), "CR_PAT") {
+		t.Fatalf("anonymous clone hint = %q", p.cloneCredentialHint())
+	}
+	pat := &Pipeline{cfg: Config{GitToken: "ghp_explicit"}}
+	if pat.publishPermissionHint(denied) != "" || pat.cloneCredentialHint() != "" {
+		t.Fatal("no workflow hints with CR_PAT")
+	}
+	fmt.Println("✅ Workflow permission failures say what to grant")
+}
+
+// TestRegistryPasswordFromEnv tests the password of registries other than GHCR
+func TestRegistryPasswordFromEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ecr-password")
+	if err := os.WriteFile(file, []byte("eyJwYXlsb2FkIjoi\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"REGISTRY_PASSWORD_FILE": file}
+	getenv := func(k string) string { return env[k] }
+	password, err := RegistryPasswordFromEnv(getenv)
+	if err != nil || password != "eyJwYXlsb2FkIjoi" {
+		t.Fatalf("REGISTRY_PASSWORD_FILE: %q %v", password, err)
+	}
+	c := ResolveCredentials(func(k string) string { return map[string]string{"CR_PAT": "ghp_git"}[k] }, "github.com", "org", "cert-parser", "docker.io").
+		WithRegistryPassword(password)
+	if c.GitToken != "ghp_git" || c.RegistryToken != "eyJwYXlsb2FkIjoi" {
+		t.Fatalf("REGISTRY_PASSWORD should only replace the registry token: %+v", c)
+	}
+
+	// Rotated on disk: a 401 during publish picks up the new one
+	if err := os.WriteFile(file, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := RegistryTokenFromEnv(getenv)(context.Background()); err != nil || token != "rotated" {
+		t.Fatalf("renewal should re-read REGISTRY_PASSWORD_FILE: %q %v", token, err)
+	}
+
+	env["REGISTRY_PASSWORD"] = "dckr_pat"
+	if _, err := RegistryPasswordFromEnv(getenv); err == nil {
+		t.Fatal("REGISTRY_PASSWORD and REGISTRY_PASSWORD_FILE together should fail")
+	}
+	delete(env, "REGISTRY_PASSWORD_FILE")
+	if password, err := RegistryPasswordFromEnv(getenv); err != nil || password != "dckr_pat" {
+		t.Fatalf("REGISTRY_PASSWORD: %q %v", password, err)
+	}
+	fmt.Println("✅ Registry password read from REGISTRY_PASSWORD or its file")
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Environment variables handed to host-run tests when an external database
+// replaces testcontainers. The conftest fixtures use the DSN as is and skip
+// starting a container when ExternalDatabaseEnv is "1".
+const (
+	ExternalDatabaseEnv = "EXTERNAL_TEST_DATABASE"
+	TestDatabaseURLEnv  = "TEST_DATABASE_URL"
+)
+
+// RedactDatabaseURL returns the scheme, host and database name of a
+// PostgreSQL URL with the credentials and query (which may carry a password)
+// removed, for logs and the run report.
+func RedactDatabaseURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(unparseable database URL)"
+	}
+	redacted := u.Scheme + "://"
+	if u.User != nil {
+		redacted += "***@"
+	}
+	return redacted + u.Host + "/" + strings.TrimPrefix(u.Path, "/")
+}
+
+// validateDatabaseURL accepts postgres:// and postgresql:// URLs with a host.
+// Errors never echo the URL, which usually carries a password.
+func validateDatabaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+		return fmt.Errorf("DatabaseURL must be a postgres:// or postgresql:// URL with a host")
+	}
+	return nil
+}
+
+// databaseEnv is the extra environment for host-run tests against the
+// external database, or nil when testcontainers should be used.
+func (p *Pipeline) databaseEnv() []string {
+	if p.cfg.DatabaseURL == "" {
+		return nil
+	}
+	return []string{ExternalDatabaseEnv + "=1", TestDatabaseURLEnv + "=" + p.cfg.DatabaseURL}
+}
+
+// printDatabaseBanner states where the host-run test stages get PostgreSQL.
+func (p *Pipeline) printDatabaseBanner() {
+	if p.cfg.DatabaseURL != "" {
+		p.println("📍 Location: Host machine (external database — no Docker needed)")
+		p.printf("🐘 PostgreSQL: EXTERNAL database %s (DATABASE_URL_OVERRIDE)\n", RedactDatabaseURL(p.cfg.DatabaseURL))
+		return
+	}
+	p.println("📍 Location: Host machine (testcontainers requires native Docker)")
+	p.println("🐘 PostgreSQL: Testcontainers will start a real PostgreSQL instance")
+}
@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Environment variables that hand DBSeedCommand to the conftest fixtures
+// when the database only exists inside the pytest session (testcontainers).
+// The fixture runs the command once after creating the schema and writes
+// its outcome, as a DBSeed, to the file named by DBSeedResultEnv.
+const (
+	DBSeedCommandEnv = "TEST_DB_SEED_COMMAND"
+	DBSeedResultEnv  = "TEST_DB_SEED_RESULT"
+)
+
+// Where the database seed ran.
+const (
+	DBSeedHost    = "host"         // by the pipeline, against DATABASE_URL_OVERRIDE
+	DBSeedSession = "test-session" // by the conftest fixture, against its testcontainer
+)
+
+// dbSeedOutputLines is how much of a failed seed's output its error quotes.
+const dbSeedOutputLines = 20
+
+// DBSeed records one run of DB_SEED_COMMAND. Its duration is kept out of
+// the test stage's duration.
+type DBSeed struct {
+	Stage           string  `json:"stage"`
+	Mode            string  `json:"mode"` // DBSeedHost or DBSeedSession
+	DurationSeconds float64 `json:"duration_seconds"`
+	ExitCode        int     `json:"exit_code"`
+	Output          string  `json:"output,omitempty"` // the last lines, on failure
+}
+
+// err is the stage error of a failed seed, with its output.
+func (s DBSeed) err() error {
+	if s.ExitCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("DB_SEED_COMMAND failed with exit code %d:\n%s", s.ExitCode, s.Output)
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// seedCommand puts the bin directory of the host test environment first
+// on PATH, and runs a bare command from it when it is there, so `python -m
+// ...` in the seed command is the tests' interpreter.
+func (p *Pipeline) seedCommand(root string, args, env []string) ([]string, []string) {
+	bin := filepath.Join(root, ".venv", "bin")
+	if p.testVenv != "" {
+		bin = filepath.Join(p.testVenv, "bin")
+	} else if _, err := os.Stat(bin); err != nil {
+		return args, env
+	}
+	path := bin
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "PATH="); ok {
+			path += string(os.PathListSeparator) + v
+		}
+	}
+	args = slices.Clone(args)
+	if local := filepath.Join(bin, args[0]); !strings.ContainsRune(args[0], filepath.Separator) {
+		if _, err := os.Stat(local); err == nil {
+			args[0] = local
+		}
+	}
+	return args, append(env, "PATH="+path)
+}
+
+// seedExternalDatabase runs DBSeedCommand on the host against the external
+// database, once per run, before the first database test stage. It goes
+// through HostTestWrapper like pytest, so it reaches the same network, and
+// gets the test environment with DATABASE_DSN set to the database too.
+func (p *Pipeline) seedExternalDatabase(ctx context.Context, stage, root string, env []string) error {
+	cfg := p.cfg
+	if len(cfg.DBSeedCommand) == 0 || cfg.DatabaseURL == "" || p.dbSeeded {
+		return nil
+	}
+	p.dbSeeded = true
+
+	p.printf("🌱 Seeding the database (DB_SEED_COMMAND): %s\n", shellJoin(cfg.DBSeedCommand))
+	seedArgs, env := p.seedCommand(root, cfg.DBSeedCommand, env)
+	args := append(slices.Clone(cfg.HostTestWrapper), seedArgs...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = root
+	cmd.Env = append(env, "DATABASE_DSN="+cfg.DatabaseURL)
+	p.stopThroughWrapper(cmd)
+	var output strings.Builder
+	cmd.Stdout = io.MultiWriter(p.out, &output)
+	cmd.Stderr = cmd.Stdout
+
+	start := time.Now()
+	err := cmd.Run()
+	seed := DBSeed{Stage: stage, Mode: DBSeedHost, DurationSeconds: time.Since(start).Seconds()}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		seed.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		seed.ExitCode = -1
+		output.WriteString(err.Error())
+	}
+	return p.recordDBSeed(seed, output.String())
+}
+
+// sessionSeedEnv hands DBSeedCommand to the conftest fixtures of a stage
+// whose database is a testcontainer, with the file its outcome goes to.
+// Any outcome left by an earlier run is removed first.
+func (p *Pipeline) sessionSeedEnv(marker string) ([]string, string) {
+	if len(p.cfg.DBSeedCommand) == 0 || p.cfg.DatabaseURL != "" {
+		return nil, ""
+	}
+	dir, err := filepath.Abs(p.cfg.ArtifactsDir)
+	if err != nil {
+		return nil, ""
+	}
+	result := filepath.Join(dir, "db-seed-"+marker+".json")
+	_ = os.Remove(result)
+	return []string{DBSeedCommandEnv + "=" + shellJoin(p.cfg.DBSeedCommand), DBSeedResultEnv + "=" + result}, result
+}
+
+// collectSessionSeed reads the outcome the conftest fixture wrote to
+// result, and returns how long the seed took, to leave out of the stage's
+// test duration, and its error when it failed. No file means pytest never
+// started the database.
+func (p *Pipeline) collectSessionSeed(stage, result string) (time.Duration, error) {
+	if result == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(result)
+	if err != nil {
+		return 0, nil
+	}
+	var seed DBSeed
+	if err := json.Unmarshal(data, &seed); err != nil {
+		p.warnf("db-seed", "   ⚠️  Could not read the database seed outcome %s: %v\n", result, err)
+		return 0, nil
+	}
+	seed.Stage, seed.Mode = stage, DBSeedSession
+	output := seed.Output
+	seed.Output = ""
+	return time.Duration(seed.DurationSeconds * float64(time.Second)), p.recordDBSeed(seed, output)
+}
+
+// recordDBSeed adds seed to the report, prints its duration and returns
+// its error, keeping the end of output on failure.
+func (p *Pipeline) recordDBSeed(seed DBSeed, output string) error {
+	duration := time.Duration(seed.DurationSeconds * float64(time.Second)).Round(time.Millisecond)
+	if seed.ExitCode != 0 {
+		seed.Output = tailLines(output, dbSeedOutputLines)
+		p.printf("   ❌ Database seed failed after %v (exit code %d)\n", duration, seed.ExitCode)
+	} else {
+		p.printf("   🌱 Database seeded in %v (%s, not counted in the test duration)\n", duration, seed.Mode)
+	}
+	p.report.DBSeeds = append(p.report.DBSeeds, seed)
+	return seed.err()
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestSeedExternalDatabase tests that the seed runs once on the host against the external database
+func TestSeedExternalDatabase(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fixtures")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, ".venv", "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	seed := `#!/bin/sh
+echo "$TEST_DATABASE_URL $DATABASE_DSN $*" >> "$SEED_LOG"
+echo "loading fixtures"
+[ "$SEED_EXIT" = 0 ] || echo "relation \"dsc\" does not exist" >&2
+exit "$SEED_EXIT"
+`
+	os.WriteFile(filepath.Join(bin, "seed-db"), []byte(seed), 0o755)
+	seedLog := filepath.Join(root, "seed.log")
+	t.Setenv("SEED_LOG", seedLog)
+	t.Setenv("SEED_EXIT", "0")
+
+	dbURL := "postgresql://ci:pw@db.corp.local:5432/cert_test"
+	newPipeline := func() (*Pipeline, *bytes.Buffer) {
+		var out bytes.Buffer
+		return &Pipeline{
+			cfg:    Config{ProjectRoot: root, DatabaseURL: dbURL, DBSeedCommand: []string{"seed-db", "--fixtures", "tests/fixtures/seed"}},
+			out:    &out,
+			report: &Report{},
+		}, &out
+	}
+	p, out := newPipeline()
+	env := append(os.Environ(), p.databaseEnv()...)
+	for range 2 {
+		if err := p.seedExternalDatabase(context.Background(), StageIntegrationTests, root, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log, _ := os.ReadFile(seedLog)
+	if string(log) != dbURL+" "+dbURL+" --fixtures tests/fixtures/seed\n" {
+		t.Fatalf("the seed should run once with the database in its environment: %q", log)
+	}
+	if len(p.report.DBSeeds) != 1 || p.report.DBSeeds[0].Mode != DBSeedHost || p.report.DBSeeds[0].Stage != StageIntegrationTests {
+		t.Fatalf("unexpected record %+v", p.report.DBSeeds)
+	}
+	if !strings.Contains(out.String(), "Database seeded in") {
+		t.Fatalf("the seed duration should be printed: %s", out.String())
+	}
+
+	t.Setenv("SEED_EXIT", "3")
+	p, _ = newPipeline()
+	env = append(os.Environ(), p.databaseEnv()...)
+	err := p.seedExternalDatabase(context.Background(), StageAcceptanceTests, root, env)
+	if err == nil || !strings.Contains(err.Error(), "exit code 3") || !strings.Contains(err.Error(), `relation "dsc" does not exist`) {
+		t.Fatalf("the failure should carry the seed's output, got %v", err)
+	}
+	if p.report.DBSeeds[0].ExitCode != 3 || !strings.Contains(p.report.DBSeeds[0].Output, "loading fixtures") {
+		t.Fatalf("unexpected record %+v", p.report.DBSeeds)
+	}
+
+	p, _ = newPipeline()
+	p.cfg.DatabaseURL = ""
+	if err := p.seedExternalDatabase(context.Background(), StageIntegrationTests, root, env); err != nil || p.report.DBSeeds != nil {
+		t.Fatalf("testcontainers databases are seeded inside pytest: %v", err)
+	}
+	fmt.Println("✅ External database seeded once before the database tests")
+}
+
+// TestSessionSeed tests that the conftest fixture's seed outcome is read back after a host-run stage
+func TestSessionSeed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fixtures")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, ".venv", "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Stands in for the conftest fixture, which writes the outcome
+	pytest := `#!/bin/sh
+case "$*" in
+*--collect-only*) echo "tests/test_a.py::test_ok"; echo "1 test collected"; exit 0 ;;
+esac
+echo "$TEST_DB_SEED_COMMAND" > "$SEED_LOG"
+printf '{"duration_seconds": 0.25, "exit_code": %s, "output": "seeding\\nboom"}' "$SEED_EXIT" > "$TEST_DB_SEED_RESULT"
+if [ "$SEED_EXIT" = 0 ]; then
+  echo "tests/test_a.py::test_ok PASSED"
+  echo "========================= 1 passed in 0.40s =========================="
+  exit 0
+fi
+echo "tests/test_a.py::test_ok ERROR"
+echo "========================= 1 error in 0.40s =========================="
+exit 1
+`
+	os.WriteFile(filepath.Join(bin, "pytest"), []byte(pytest), 0o755)
+	seedLog := filepath.Join(root, "seed.log")
+	t.Setenv("SEED_LOG", seedLog)
+	t.Setenv("SEED_EXIT", "0")
+
+	newPipeline := func() (*Pipeline, *bytes.Buffer) {
+		var out bytes.Buffer
+		return &Pipeline{
+			cfg:     Config{ProjectRoot: root, ArtifactsDir: t.TempDir(), DBSeedCommand: []string{"python", "-m", "cert_parser.tools.seed", "--fixtures", "tests/fixtures/seed dir"}},
+			out:     &out,
+			report:  &Report{},
+			tracker: NewTracker(""),
+		}, &out
+	}
+	p, out := newPipeline()
+	p.tracker.StartStage(StageIntegrationTests)
+	if ran, err := p.runTestsOnHost(context.Background(), "integration"); !ran || err != nil {
+		t.Fatalf("ran=%v err=%v", ran, err)
+	}
+	log, _ := os.ReadFile(seedLog)
+	if string(log) != "python -m cert_parser.tools.seed --fixtures 'tests/fixtures/seed dir'\n" {
+		t.Fatalf("the command should be handed to the fixture shell-quoted: %q", log)
+	}
+	seeds := p.report.DBSeeds
+	if len(seeds) != 1 || seeds[0].Mode != DBSeedSession || seeds[0].Stage != StageIntegrationTests || seeds[0].DurationSeconds != 0.25 || seeds[0].Output != "" {
+		t.Fatalf("unexpected record %+v", seeds)
+	}
+	if !strings.Contains(out.String(), "Database seeded in 250ms") {
+		t.Fatalf("the seed duration should be printed: %s", out.String())
+	}
+
+	t.Setenv("SEED_EXIT", "2")
+	p, _ = newPipeline()
+	_, err := p.runTestsOnHost(context.Background(), "integration")
+	if err == nil || !strings.Contains(err.Error(), "DB_SEED_COMMAND failed with exit code 2:\nseeding\nboom") {
+		t.Fatalf("the seed failure should fail the stage with its output, got %v", err)
+	}
+	fmt.Println("✅ Testcontainers database seeded by the fixture and reported apart from the tests")
+}
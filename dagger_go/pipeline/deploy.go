@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deployment verification defaults.
+const (
+	DefaultDeployVerifyTimeout  = 5 * time.Minute
+	DefaultDeployVerifyInterval = 10 * time.Second
+)
+
+// maxVerifyBody caps how much of a verification response is read.
+const maxVerifyBody = 1 << 20
+
+// jsonField returns the value at a dot-separated path (e.g. "build.commit")
+// of a JSON document, formatted as text.
+func jsonField(body []byte, path string) (string, bool) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[key]; !ok {
+			return "", false
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case nil:
+		return "", true
+	default:
+		data, _ := json.Marshal(val)
+		return string(data), true
+	}
+}
+
+// deploymentMatches reports whether a verification response shows the new
+// release: the JSON field (or, without a field, the whole body) contains
+// one of the expected values. observed is what was compared, for logs.
+func deploymentMatches(body []byte, field string, want []string) (observed string, ok bool) {
+	observed = strings.TrimSpace(string(body))
+	if field != "" {
+		value, found := jsonField(body, field)
+		if !found {
+			return fmt.Sprintf("(no %q field)", field), false
+		}
+		observed = value
+	}
+	for _, w := range want {
+		if w != "" && strings.Contains(observed, w) {
+			return observed, true
+		}
+	}
+	return observed, false
+}
+
+// verifyDeployment polls DeployVerifyURL until the response matches one of
+// want or DeployVerifyTimeout elapses. Errors, non-2xx responses and stale
+// versions are retried; only the timeout fails.
+func (p *Pipeline) verifyDeployment(ctx context.Context, want []string) error {
+	cfg := p.cfg
+	ctx, cancel := context.WithTimeout(ctx, cfg.DeployVerifyTimeout)
+	defer cancel()
+
+	last := ""
+	for attempt := 1; ; attempt++ {
+		observed, ok, err := p.pollDeployment(ctx, want)
+		switch {
+		case ok:
+			p.printf("   ✅ Attempt %d: %s reports %s\n", attempt, cfg.DeployVerifyURL, oneLine(observed))
+			return nil
+		case err != nil:
+			observed = err.Error()
+		}
+		if observed != last {
+			p.printf("   ⏳ Attempt %d: %s\n", attempt, oneLine(observed))
+			last = observed
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%s did not report %s within %v (last: %s)", cfg.DeployVerifyURL, strings.Join(want, " or "), cfg.DeployVerifyTimeout, oneLine(last))
+			}
+			return ctx.Err()
+		case <-time.After(cfg.DeployVerifyInterval):
+		}
+	}
+}
+
+// pollDeployment makes one verification request.
+func (p *Pipeline) pollDeployment(ctx context.Context, want []string) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.DeployVerifyURL, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVerifyBody))
+	if err != nil {
+		return "", false, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", false, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	observed, ok := deploymentMatches(body, p.cfg.DeployVerifyField, want)
+	return observed, ok, nil
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDeploymentMatches tests matching a verification response
+func TestDeploymentMatches(t *testing.T) {
+	body := []byte(`{"status":"ok","build":{"commit":"4f2c9e1a77","number":42}}`)
+	if observed, ok := deploymentMatches(body, "build.commit", []string{"v0.1.0-4f2c9e1-20261017-0930", "4f2c9e1"}); !ok || observed != "4f2c9e1a77" {
+		t.Fatalf("field match failed: %q %v", observed, ok)
+	}
+	if _, ok := deploymentMatches(body, "build.number", []string{"42"}); !ok {
+		t.Fatal("numeric field should be compared as text")
+	}
+	if observed, ok := deploymentMatches(body, "build.tag", []string{"4f2c9e1"}); ok || !strings.Contains(observed, "no") {
+		t.Fatalf("missing field must not match: %q", observed)
+	}
+	if _, ok := deploymentMatches([]byte("cert-parser 4f2c9e1\n"), "", []string{"4f2c9e1"}); !ok {
+		t.Fatal("substring match on the body failed")
+	}
+	if _, ok := deploymentMatches([]byte("cert-parser 0000000"), "", []string{"", "4f2c9e1"}); ok {
+		t.Fatal("stale version matched")
+	}
+	fmt.Println("✅ Deployment responses matched by field or substring")
+}
+
+// TestVerifyDeployment tests polling until the new version is reported
+func TestVerifyDeployment(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			fmt.Fprint(w, `{"version":"v0.1.0-0000000-20261016-1200"}`)
+		default:
+			fmt.Fprint(w, `{"version":"v0.1.0-4f2c9e1-20261017-0930"}`)
+		}
+	}))
+	defer server.Close()
+
+	p, err := New(Config{
+		RepoName:             "cert-parser",
+		GitUser:              "org",
+		DeployWebhook:        server.URL,
+		DeployVerifyURL:      server.URL,
+		DeployVerifyField:    "version",
+		DeployVerifyInterval: 5 * time.Millisecond,
+		DeployVerifyTimeout:  5 * time.Second,
+		HTTPClient:           server.Client(),
+		Output:               io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.verifyDeployment(context.Background(), []string{"4f2c9e1"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 polls, got %d", calls.Load())
+	}
+
+	p.cfg.DeployVerifyTimeout = 30 * time.Millisecond
+	err = p.verifyDeployment(context.Background(), []string{"deadbee"})
+	if err == nil || !strings.Contains(err.Error(), "did not report deadbee") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", DeployVerifyURL: server.URL}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("DeployVerifyURL without DeployWebhook should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Deployment verified by polling")
+}
@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultMinFreeSpace is the free space a run needs on each checked
+// filesystem; below twice that it warns.
+const DefaultMinFreeSpace = 5 << 30
+
+// dockerDataRoot is where a local engine running in Docker keeps its state,
+// in a Docker volume.
+const dockerDataRoot = "/var/lib/docker"
+
+// DiskPath is a directory whose filesystem needs free space.
+type DiskPath struct {
+	Purpose string // engine, temp or artifacts
+	Path    string
+}
+
+// FreeSpace is the free space found on the filesystem of a DiskPath.
+type FreeSpace struct {
+	Purpose string `json:"purpose"`
+	Path    string `json:"path"`
+	Free    int64  `json:"free"`
+	Total   int64  `json:"total"`
+	Low     bool   `json:"low,omitempty"` // under twice MinFreeSpace
+	Error   string `json:"error,omitempty"`
+}
+
+// DirUsage is the size of a local directory the pipeline keeps between
+// runs, and how much it grew during the run.
+type DirUsage struct {
+	Purpose string `json:"purpose"`
+	Path    string `json:"path"`
+	Bytes   int64  `json:"bytes"`
+	Growth  int64  `json:"growth"`
+}
+
+// DiskReport is the disk usage of a run, recorded in the run report.
+type DiskReport struct {
+	MinFreeSpace int64       `json:"min_free_space"`
+	FreeSpace    []FreeSpace `json:"free_space"`
+	Artifacts    int64       `json:"artifacts_bytes"` // ArtifactsDir at the end of the run
+	Directories  []DirUsage  `json:"directories,omitempty"`
+}
+
+// ParseMinFreeSpace parses MIN_FREE_SPACE: a size such as 10GiB, or "off"
+// (returned as -1) to skip the check.
+func ParseMinFreeSpace(value string) (int64, error) {
+	return parseSizeOrOff(value)
+}
+
+// parseSizeOrOff parses a size, or "off" as -1.
+func parseSizeOrOff(value string) (int64, error) {
+	if strings.EqualFold(strings.TrimSpace(value), "off") {
+		return -1, nil
+	}
+	return ParseByteSize(value)
+}
+
+// LocalEngineStateDir is the directory holding the Dagger engine's state
+// for the free-space check: explicit when set, else Docker's data root for
+// a local engine on Linux, and "" when it cannot be determined (a remote
+// engine, or Docker Desktop's virtual machine).
+func LocalEngineStateDir(explicit string, remote bool) string {
+	if explicit != "" {
+		return explicit
+	}
+	if remote || runtime.GOOS != "linux" {
+		return ""
+	}
+	if _, err := os.Stat(dockerDataRoot); err != nil {
+		return ""
+	}
+	return dockerDataRoot
+}
+
+// DiskPaths lists the directories checked for free space: the engine state
+// directory when known, the OS temp directory and the artifacts directory.
+func DiskPaths(engineStateDir, artifactsDir string) []DiskPath {
+	var paths []DiskPath
+	if engineStateDir != "" {
+		paths = append(paths, DiskPath{Purpose: "engine", Path: engineStateDir})
+	}
+	return append(paths,
+		DiskPath{Purpose: "temp", Path: os.TempDir()},
+		DiskPath{Purpose: "artifacts", Path: artifactsDir},
+	)
+}
+
+// CheckFreeSpace measures the free space of each path's filesystem. A path
+// that does not exist yet is measured at its nearest existing parent. It
+// returns the paths under minFree; Low marks those under twice minFree.
+func CheckFreeSpace(paths []DiskPath, minFree int64) (spaces []FreeSpace, short []FreeSpace) {
+	for _, p := range paths {
+		space := FreeSpace{Purpose: p.Purpose, Path: p.Path}
+		free, total, err := diskFree(existingParent(p.Path))
+		if err != nil {
+			space.Error = err.Error()
+		} else {
+			space.Free, space.Total = free, total
+			space.Low = free < 2*minFree
+			if free < minFree {
+				short = append(short, space)
+			}
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, short
+}
+
+// existingParent is path, or its nearest ancestor that exists.
+func existingParent(path string) string {
+	path, _ = filepath.Abs(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// DirSize sums the sizes of the regular files under path; a missing path
+// is 0.
+func DirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// historyDirs are the local directories kept between runs whose growth is
+// reported: the flaky-test and lint histories, the freeze history and the
+// exported test environment.
+func (p *Pipeline) historyDirs() []DiskPath {
+	cfg := p.cfg
+	var dirs []DiskPath
+	seen := map[string]bool{}
+	add := func(purpose, path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			dirs = append(dirs, DiskPath{Purpose: purpose, Path: path})
+		}
+	}
+	add("history", filepath.Dir(cfg.FlakyHistoryFile))
+	add("history", filepath.Dir(cfg.LintHistoryFile))
+	add("freeze-history", cfg.FreezeHistoryDir)
+	if cfg.ExportTestVenv {
+		add("test-venv", cfg.VenvDir)
+	}
+	return dirs
+}
+
+// checkFreeSpace fails the run before any work when a checked filesystem
+// has less than MinFreeSpace free, warns under twice that, and notes the
+// size of the history directories so their growth can be reported.
+func (p *Pipeline) checkFreeSpace() error {
+	cfg := p.cfg
+	if cfg.MinFreeSpace < 0 {
+		return nil
+	}
+	p.printf("💾 Checking free disk space (at least %s needed)...\n", FormatBytes(cfg.MinFreeSpace))
+	spaces, short := CheckFreeSpace(DiskPaths(cfg.EngineStateDir, cfg.ArtifactsDir), cfg.MinFreeSpace)
+	p.report.Disk = &DiskReport{MinFreeSpace: cfg.MinFreeSpace, FreeSpace: spaces}
+	for _, space := range spaces {
+		switch {
+		case space.Error != "":
+			p.warnf("disk-space-unknown", "   ⚠️  Could not check free space for %s (%s): %s\n", space.Purpose, space.Path, space.Error)
+		case space.Free < cfg.MinFreeSpace:
+			p.printf("   ❌ %s free of %s for %s (%s)\n", FormatBytes(space.Free), FormatBytes(space.Total), space.Purpose, space.Path)
+		case space.Low:
+			p.warnf("disk-space-low", "   ⚠️  Only %s free for %s (%s); the run needs %s — consider the cleanup mode\n", FormatBytes(space.Free), space.Purpose, space.Path, FormatBytes(cfg.MinFreeSpace))
+		default:
+			p.printf("   ✓ %s free for %s (%s)\n", FormatBytes(space.Free), space.Purpose, space.Path)
+		}
+	}
+	for _, dir := range p.historyDirs() {
+		p.report.Disk.Directories = append(p.report.Disk.Directories, DirUsage{Purpose: dir.Purpose, Path: dir.Path, Bytes: DirSize(dir.Path)})
+	}
+	if len(short) > 0 {
+		var where []string
+		for _, space := range short {
+			where = append(where, fmt.Sprintf("%s %s has %s", space.Purpose, space.Path, FormatBytes(space.Free)))
+		}
+		return Errorf(CategoryConfig, "not enough free disk space (MinFreeSpace %s): %s; free some space, run the cleanup mode (CLEANUP_APPLY=true), or lower MIN_FREE_SPACE",
+			FormatBytes(cfg.MinFreeSpace), strings.Join(where, ", "))
+	}
+	return nil
+}
+
+// recordDiskUsage reports the size of the artifacts directory and the
+// growth of the history directories since checkFreeSpace.
+func (p *Pipeline) recordDiskUsage() {
+	disk := p.report.Disk
+	if disk == nil {
+		return
+	}
+	disk.Artifacts = DirSize(p.cfg.ArtifactsDir)
+	line := fmt.Sprintf("💾 Artifacts: %s in %s", FormatBytes(disk.Artifacts), p.cfg.ArtifactsDir)
+	for i := range disk.Directories {
+		dir := &disk.Directories[i]
+		size := DirSize(dir.Path)
+		dir.Growth, dir.Bytes = size-dir.Bytes, size
+		line += fmt.Sprintf("; %s %s (%s)", dir.Purpose, FormatBytes(dir.Bytes), formatGrowth(dir.Growth))
+	}
+	p.println(line)
+}
+
+// formatGrowth renders a size change with its sign, e.g. "+1.2 MiB".
+func formatGrowth(n int64) string {
+	if n < 0 {
+		return "-" + FormatBytes(-n)
+	}
+	return "+" + FormatBytes(n)
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package pipeline
+
+import "errors"
+
+// diskFree is not implemented on this platform; the free-space check
+// records the error and goes on.
+func diskFree(path string) (free, total int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckFreeSpace tests the free-space check against the minimum
+func TestCheckFreeSpace(t *testing.T) {
+	if n, err := ParseMinFreeSpace("off"); err != nil || n != -1 {
+		t.Fatalf("ParseMinFreeSpace(off) = %d, %v", n, err)
+	}
+	if n, err := ParseMinFreeSpace("5GiB"); err != nil || n != DefaultMinFreeSpace {
+		t.Fatalf("ParseMinFreeSpace(5GiB) = %d, %v", n, err)
+	}
+	if _, err := ParseMinFreeSpace("lots"); err == nil {
+		t.Fatal("an invalid size should be rejected")
+	}
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "not", "created", "yet")
+	if got := existingParent(missing); got != dir {
+		t.Fatalf("existingParent(%s) = %s, want %s", missing, got, dir)
+	}
+	paths := []DiskPath{{Purpose: "artifacts", Path: missing}}
+	spaces, short := CheckFreeSpace(paths, 1)
+	if len(spaces) != 1 || spaces[0].Error != "" {
+		t.Fatalf("CheckFreeSpace = %+v", spaces)
+	}
+	if spaces[0].Total == 0 || len(short) != 0 || spaces[0].Low {
+		t.Fatalf("1 byte should be available: %+v, short %+v", spaces[0], short)
+	}
+	if _, short := CheckFreeSpace(paths, 1<<60); len(short) != 1 || !short[0].Low {
+		t.Fatalf("an impossible minimum should be short: %+v", short)
+	}
+	if _, short := CheckFreeSpace(paths, -1); len(short) != 0 {
+		t.Fatalf("a disabled minimum should never be short: %+v", short)
+	}
+
+	got := DiskPaths("", dir)
+	if len(got) != 2 || got[0].Purpose != "temp" || got[1].Path != dir {
+		t.Fatalf("DiskPaths without an engine dir = %+v", got)
+	}
+	if got := DiskPaths("/var/lib/docker", dir); len(got) != 3 || got[0].Purpose != "engine" {
+		t.Fatalf("DiskPaths with an engine dir = %+v", got)
+	}
+	fmt.Println("✅ Free space checked")
+}
+
+// TestDirSize tests measuring artifact and history directories
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "history"), 0o755)
+	os.WriteFile(filepath.Join(dir, "report.json"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(dir, "history", "runs.json"), make([]byte, 50), 0o644)
+	if got := DirSize(dir); got != 150 {
+		t.Fatalf("DirSize = %d, want 150", got)
+	}
+	if got := DirSize(filepath.Join(dir, "missing")); got != 0 {
+		t.Fatalf("DirSize of a missing dir = %d, want 0", got)
+	}
+	if got := formatGrowth(2048); got != "+2.0 KiB" {
+		t.Fatalf("formatGrowth(2048) = %q", got)
+	}
+	if got := formatGrowth(-2048); got != "-2.0 KiB" {
+		t.Fatalf("formatGrowth(-2048) = %q", got)
+	}
+	fmt.Println("✅ Directory sizes measured")
+}
@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+	"dagger.io/dagger/engineconn"
+)
+
+// EnginePreflightTimeout bounds the reachability check of DAGGER_RUNNER_HOST.
+const EnginePreflightTimeout = 5 * time.Second
+
+// RunnerHost is a parsed DAGGER_RUNNER_HOST value. The zero value means the
+// default locally provisioned engine.
+type RunnerHost struct {
+	Raw    string
+	Scheme string // docker-container, tcp or unix
+	Target string // container name, host:port, or socket path
+}
+
+// ParseRunnerHost validates a DAGGER_RUNNER_HOST value of the form
+// docker-container://<name>, tcp://<host>:<port> or unix://<path>.
+// An empty value returns the zero RunnerHost.
+func ParseRunnerHost(raw string) (RunnerHost, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return RunnerHost{}, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RunnerHost{}, fmt.Errorf("invalid DAGGER_RUNNER_HOST %q: %w", raw, err)
+	}
+	r := RunnerHost{Raw: raw, Scheme: u.Scheme}
+	switch u.Scheme {
+	case "docker-container":
+		r.Target = u.Host
+	case "tcp":
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			return RunnerHost{}, fmt.Errorf("invalid DAGGER_RUNNER_HOST %q: tcp form needs host:port", raw)
+		}
+		r.Target = u.Host
+	case "unix":
+		r.Target = u.Path
+	default:
+		return RunnerHost{}, fmt.Errorf("unsupported DAGGER_RUNNER_HOST scheme in %q (use docker-container://, tcp:// or unix://)", raw)
+	}
+	if r.Target == "" {
+		return RunnerHost{}, fmt.Errorf("invalid DAGGER_RUNNER_HOST %q: missing %s target", raw, u.Scheme)
+	}
+	return r, nil
+}
+
+// IsSet reports whether an explicit runner host was configured.
+func (r RunnerHost) IsSet() bool {
+	return r.Raw != ""
+}
+
+// Remote reports whether the engine runs on another machine. Only tcp://
+// endpoints to a non-loopback host are remote; docker-container:// and
+// unix:// engines share this machine.
+func (r RunnerHost) Remote() bool {
+	if r.Scheme != "tcp" {
+		return false
+	}
+	host, _, _ := net.SplitHostPort(r.Target)
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// Preflight checks that the configured engine endpoint accepts connections,
+// so an unreachable engine fails fast instead of inside the session handshake.
+func (r RunnerHost) Preflight(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	switch r.Scheme {
+	case "tcp", "unix":
+		conn, err := d.DialContext(ctx, r.Scheme, r.Target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case "docker-container":
+		if _, err := exec.LookPath("docker"); err != nil {
+			return nil // cannot check without the docker CLI; Connect will report it
+		}
+		out, err := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{.State.Running}}", r.Target).Output()
+		if err != nil {
+			return fmt.Errorf("container %s not found: %w", r.Target, err)
+		}
+		if strings.TrimSpace(string(out)) != "true" {
+			return fmt.Errorf("container %s is not running", r.Target)
+		}
+	}
+	return nil
+}
+
+// IsEngineVersionError reports whether a connection error comes from an
+// engine/CLI version mismatch rather than an unreachable engine.
+func IsEngineVersionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"incompatible", "version mismatch", "unsupported version", "minimum version", "requires version"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Connect opens a Dagger session against runner (or the default local engine
+// when unset). Failures are tagged CategoryEngine and say whether the engine
+// was unreachable or version-incompatible.
+func Connect(ctx context.Context, runner RunnerHost, logOutput io.Writer) (*dagger.Client, error) {
+	opts := []dagger.ClientOpt{dagger.WithLogOutput(logOutput)}
+	target := "local engine"
+	if runner.IsSet() {
+		target = runner.Raw
+		if err := runner.Preflight(ctx, EnginePreflightTimeout); err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, ctx.Err()
+			}
+			return nil, Errorf(CategoryEngine, "dagger engine unreachable at %s: %w", target, err)
+		}
+		opts = append(opts, dagger.WithRunnerHost(runner.Raw))
+	}
+
+	client, err := dagger.Connect(ctx, opts...)
+	switch {
+	case err == nil:
+		return client, nil
+	case errors.Is(err, context.Canceled):
+		return nil, err
+	case IsEngineVersionError(err):
+		return nil, Errorf(CategoryEngine, "dagger engine at %s is version-incompatible with this SDK: %w", target, err)
+	default:
+		return nil, Errorf(CategoryEngine, "dagger engine unreachable at %s: %w", target, err)
+	}
+}
+
+// SDKVersion is the Dagger version this binary was compiled against.
+const SDKVersion = engineconn.CLIVersion
+
+// DaggerVersions records the SDK and engine versions of a run for the report.
+type DaggerVersions struct {
+	SDK        string `json:"sdk"`
+	Engine     string `json:"engine,omitempty"`
+	Compatible bool   `json:"compatible"`
+}
+
+// CompareEngineVersion reports whether engine is compatible with sdk and, if
+// not, why. Versions are compatible when major and minor match; patch
+// releases within a minor line interoperate.
+func CompareEngineVersion(engine, sdk string) (bool, string) {
+	eMajor, eMinor, eOK := majorMinor(engine)
+	sMajor, sMinor, sOK := majorMinor(sdk)
+	switch {
+	case !eOK || !sOK:
+		return false, fmt.Sprintf("cannot compare engine version %q with SDK version %q", engine, sdk)
+	case eMajor != sMajor || eMinor != sMinor:
+		direction := "older"
+		if eMajor > sMajor || (eMajor == sMajor && eMinor > sMinor) {
+			direction = "newer"
+		}
+		return false, fmt.Sprintf("engine v%d.%d is %s than the v%d.%d.x line this binary was built with",
+			eMajor, eMinor, direction, sMajor, sMinor)
+	}
+	return true, ""
+}
+
+// VerifyEngineVersion queries the connected engine's version and compares it
+// with SDKVersion, printing a prominent warning with the upgrade command on a
+// mismatch. With strict set, a mismatch is returned as a CategoryEngine error.
+func VerifyEngineVersion(ctx context.Context, client *dagger.Client, w io.Writer, strict bool) (DaggerVersions, error) {
+	versions := DaggerVersions{SDK: SDKVersion}
+	engine, err := client.Version(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return versions, err
+		}
+		fmt.Fprintf(w, "⚠️  Could not query Dagger engine version: %v\n", err)
+		return versions, nil
+	}
+	versions.Engine = engine
+
+	ok, reason := CompareEngineVersion(engine, SDKVersion)
+	versions.Compatible = ok
+	if ok {
+		fmt.Fprintf(w, "   Dagger engine %s (SDK v%s)\n", engine, SDKVersion)
+		return versions, nil
+	}
+
+	fmt.Fprintf(w, "\n%s\n", strings.Repeat("!", 80))
+	fmt.Fprintln(w, "⚠️  DAGGER ENGINE VERSION MISMATCH")
+	fmt.Fprintf(w, "   Engine: %s\n", engine)
+	fmt.Fprintf(w, "   SDK:    v%s\n", SDKVersion)
+	fmt.Fprintf(w, "   %s\n", reason)
+	fmt.Fprintln(w, "   Upgrade the Dagger CLI/engine to match:")
+	fmt.Fprintf(w, "     curl -fsSL https://dl.dagger.io/dagger/install.sh | DAGGER_VERSION=%s BIN_DIR=/usr/local/bin sh\n", SDKVersion)
+	fmt.Fprintln(w, "   then remove the old engine container so it is re-provisioned:")
+	fmt.Fprintln(w, "     docker rm -f $(docker ps -aq --filter name=dagger-engine)")
+	fmt.Fprintln(w, strings.Repeat("!", 80))
+	if strict {
+		return versions, Errorf(CategoryEngine, "dagger engine %s is incompatible with SDK v%s (STRICT_ENGINE_VERSION=true): %s",
+			engine, SDKVersion, reason)
+	}
+	return versions, nil
+}
+
+// majorMinor extracts major and minor from versions such as "v0.19.7",
+// "0.19.7" or "v0.19.7-20250101-abcdef".
+func majorMinor(v string) (int, int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
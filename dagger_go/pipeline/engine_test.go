@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestParseRunnerHost tests the accepted DAGGER_RUNNER_HOST forms
+func TestParseRunnerHost(t *testing.T) {
+	tests := []struct {
+		raw    string
+		scheme string
+		target string
+		remote bool
+	}{
+		{"", "", "", false},
+		{"docker-container://dagger-engine", "docker-container", "dagger-engine", false},
+		{"tcp://buildbox.internal:1234", "tcp", "buildbox.internal:1234", true},
+		{"tcp://10.0.0.5:1234", "tcp", "10.0.0.5:1234", true},
+		{"tcp://127.0.0.1:1234", "tcp", "127.0.0.1:1234", false},
+		{"tcp://localhost:1234", "tcp", "localhost:1234", false},
+		{"unix:///run/dagger/engine.sock", "unix", "/run/dagger/engine.sock", false},
+	}
+	for _, tc := range tests {
+		r, err := ParseRunnerHost(tc.raw)
+		if err != nil {
+			t.Fatalf("ParseRunnerHost(%q) returned error: %v", tc.raw, err)
+		}
+		if r.Scheme != tc.scheme || r.Target != tc.target || r.Remote() != tc.remote {
+			t.Fatalf("ParseRunnerHost(%q) = %+v (remote=%v), want %s %s remote=%v",
+				tc.raw, r, r.Remote(), tc.scheme, tc.target, tc.remote)
+		}
+	}
+
+	for _, bad := range []string{"ssh://buildbox", "tcp://buildbox", "docker-container://", "unix://"} {
+		if _, err := ParseRunnerHost(bad); err == nil {
+			t.Fatalf("ParseRunnerHost(%q) should fail", bad)
+		}
+	}
+	fmt.Println("✅ DAGGER_RUNNER_HOST forms parsed and validated")
+}
+
+// TestRunnerHostPreflight tests the reachability check for tcp endpoints
+func TestRunnerHostPreflight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	r, _ := ParseRunnerHost("tcp://" + addr)
+	if err := r.Preflight(context.Background(), EnginePreflightTimeout); err != nil {
+		t.Fatalf("listening endpoint should be reachable: %v", err)
+	}
+
+	ln.Close()
+	if err := r.Preflight(context.Background(), EnginePreflightTimeout); err == nil {
+		t.Fatal("closed endpoint should be unreachable")
+	}
+	fmt.Println("✅ Unreachable engine endpoints detected before connecting")
+}
+
+// TestIsEngineVersionError tests unreachable vs incompatible classification
+func TestIsEngineVersionError(t *testing.T) {
+	if !IsEngineVersionError(errors.New("engine v0.9.0 is incompatible with client v0.19.7")) {
+		t.Fatal("expected version error")
+	}
+	if IsEngineVersionError(errors.New("dial tcp 10.0.0.5:1234: connect: connection refused")) {
+		t.Fatal("connection refused is not a version error")
+	}
+	fmt.Println("✅ Engine version errors told apart from connectivity errors")
+}
+
+// TestCompareEngineVersion tests the SDK/engine compatibility range
+func TestCompareEngineVersion(t *testing.T) {
+	tests := []struct {
+		engine     string
+		sdk        string
+		compatible bool
+		reason     string
+	}{
+		{"v0.19.7", "0.19.7", true, ""},
+		{"v0.19.2", "0.19.7", true, ""},
+		{"v0.16.3", "0.19.7", false, "older"},
+		{"v0.20.0", "0.19.7", false, "newer"},
+		{"devel", "0.19.7", false, "cannot compare"},
+	}
+	for _, tc := range tests {
+		ok, reason := CompareEngineVersion(tc.engine, tc.sdk)
+		if ok != tc.compatible || !strings.Contains(reason, tc.reason) {
+			t.Fatalf("CompareEngineVersion(%q, %q) = (%v, %q), want (%v, ~%q)", tc.engine, tc.sdk, ok, reason, tc.compatible, tc.reason)
+		}
+	}
+	if SDKVersion == "" {
+		t.Fatal("SDKVersion should be set from the Dagger SDK")
+	}
+	fmt.Println("✅ Engine versions compared against the SDK minor line")
+}
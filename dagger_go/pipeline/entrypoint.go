@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EntrypointCheck modes: what a built image whose entrypoint does not match
+// the project's declared scripts does to the run.
+const (
+	EntrypointCheckWarn = "warn"
+	EntrypointCheckFail = "fail"
+	EntrypointCheckOff  = "off"
+)
+
+// ValidEntrypointCheck reports whether mode can be used as ENTRYPOINT_CHECK.
+func ValidEntrypointCheck(mode string) bool {
+	switch mode {
+	case EntrypointCheckWarn, EntrypointCheckFail, EntrypointCheckOff:
+		return true
+	}
+	return false
+}
+
+var (
+	tomlTableHeader = regexp.MustCompile(`^\[\s*([^\[\]]+?)\s*\]$`)
+	tomlKeyValue    = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_.-]+)\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// ProjectScripts parses the console and GUI scripts declared in the
+// [project.scripts] and [project.gui-scripts] tables of pyproject.toml into
+// script name → "module:function" entry point.
+func ProjectScripts(pyproject string) map[string]string {
+	scripts := map[string]string{}
+	inScripts := false
+	for _, line := range strings.Split(pyproject, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := tomlTableHeader.FindStringSubmatch(line); m != nil && !strings.HasPrefix(line, "[[") {
+			table := strings.ReplaceAll(m[1], " ", "")
+			inScripts = table == "project.scripts" || table == "project.gui-scripts"
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inScripts = false
+			continue
+		}
+		if !inScripts {
+			continue
+		}
+		if m := tomlKeyValue.FindStringSubmatch(line); m != nil {
+			scripts[unquoteTOML(m[1])] = unquoteTOML(m[2])
+		}
+	}
+	return scripts
+}
+
+// unquoteTOML strips the quotes of a basic or literal TOML string.
+func unquoteTOML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// EntrypointMatchesScripts reports whether an image command line (the
+// entrypoint followed by the default arguments) runs one of the declared
+// scripts: by script name (or a path ending in it), or through the module
+// or top-level package of its entry point, e.g. `python -m cert_parser`.
+// Shell-form commands are split into words.
+func EntrypointMatchesScripts(argv []string, scripts map[string]string) bool {
+	var words []string
+	for _, arg := range argv {
+		words = append(words, strings.Fields(arg)...)
+	}
+	for _, word := range words {
+		word = strings.Trim(word, `"'`)
+		base := path.Base(word)
+		for name, target := range scripts {
+			module, _, _ := strings.Cut(target, ":")
+			module = strings.TrimSpace(module)
+			pkg, _, _ := strings.Cut(module, ".")
+			switch {
+			case base == name:
+				return true
+			case module != "" && (word == module || strings.HasPrefix(word, module+":")):
+				return true
+			case pkg != "" && (word == pkg || strings.HasPrefix(word, pkg+".") || strings.HasPrefix(word, pkg+":")):
+				return true
+			case pkg != "" && strings.Contains(word, "/"+pkg+"/"):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entrypointProblems checks the image command line and working directory
+// against the declared scripts and the expected WORKDIR. Each problem
+// quotes both values so the fix is obvious.
+func entrypointProblems(entrypoint, cmd []string, workdir, wantWorkdir string, scripts map[string]string) []string {
+	var problems []string
+	argv := append(append([]string(nil), entrypoint...), cmd...)
+	if len(scripts) > 0 && len(argv) > 0 && !EntrypointMatchesScripts(argv, scripts) {
+		var declared []string
+		for name, target := range scripts {
+			declared = append(declared, fmt.Sprintf("%s = %q", name, target))
+		}
+		sort.Strings(declared)
+		problems = append(problems, fmt.Sprintf("image runs %q, which references none of the [project.scripts] entries (%s)",
+			strings.Join(argv, " "), strings.Join(declared, ", ")))
+	}
+	if wantWorkdir != "" && path.Clean(workdir) != path.Clean(wantWorkdir) {
+		problems = append(problems, fmt.Sprintf("image WORKDIR is %q, expected %q (IMAGE_WORKDIR)", workdir, wantWorkdir))
+	}
+	return problems
+}
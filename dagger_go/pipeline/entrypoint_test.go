@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const scriptsPyproject = `[project]
+name = "cert-parser"
+dependencies = ["cryptography>=43"]
+
+[project.scripts]
+cert-parser = "cert_parser.main:main"
+"cert-export" = 'cert_parser.export:run'  # literal string
+# cert-debug = "cert_parser.debug:main"
+
+[project.gui-scripts]
+cert-viewer = "cert_parser.gui:start"
+
+[tool.hatch.envs.default.scripts]
+test = "pytest"
+`
+
+// TestProjectScripts tests parsing [project.scripts] from pyproject.toml
+func TestProjectScripts(t *testing.T) {
+	scripts := ProjectScripts(scriptsPyproject)
+	want := map[string]string{
+		"cert-parser": "cert_parser.main:main",
+		"cert-export": "cert_parser.export:run",
+		"cert-viewer": "cert_parser.gui:start",
+	}
+	if len(scripts) != len(want) {
+		t.Fatalf("got %v, want %v", scripts, want)
+	}
+	for name, target := range want {
+		if scripts[name] != target {
+			t.Fatalf("%s = %q, want %q", name, scripts[name], target)
+		}
+	}
+	if len(ProjectScripts("[project]\nname = \"x\"\n")) != 0 {
+		t.Fatal("a project without scripts has none")
+	}
+	fmt.Println("✅ [project.scripts] parsed")
+}
+
+// TestEntrypointMatchesScripts tests the accepted ways an image can run a declared script
+func TestEntrypointMatchesScripts(t *testing.T) {
+	scripts := map[string]string{"cert-parser": "cert_parser.main:main"}
+	matching := [][]string{
+		{"cert-parser"},
+		{"/app/.venv/bin/cert-parser", "--serve"},
+		{"python", "-m", "cert_parser"},
+		{"python", "-m", "cert_parser.main"},
+		{"/bin/sh", "-c", "exec cert-parser --port 8080"},
+		{"uvicorn", "cert_parser.api:app"},
+		{"python", "/app/src/cert_parser/main.py"},
+	}
+	for _, argv := range matching {
+		if !EntrypointMatchesScripts(argv, scripts) {
+			t.Fatalf("%q should match %v", argv, scripts)
+		}
+	}
+	drifted := [][]string{
+		{"cert-parse"},
+		{"python", "-m", "certparser"},
+		{"/bin/sh", "-c", "old-cert-tool"},
+	}
+	for _, argv := range drifted {
+		if EntrypointMatchesScripts(argv, scripts) {
+			t.Fatalf("%q should not match %v", argv, scripts)
+		}
+	}
+	fmt.Println("✅ Image entrypoints are matched against declared scripts")
+}
+
+// TestEntrypointProblems tests that problems quote both the image and the expected values
+func TestEntrypointProblems(t *testing.T) {
+	scripts := map[string]string{"cert-parser": "cert_parser.main:main"}
+	problems := entrypointProblems([]string{"cert-parse"}, []string{"--serve"}, "/srv", "/app", scripts)
+	if len(problems) != 2 {
+		t.Fatalf("expected entrypoint and workdir problems, got %v", problems)
+	}
+	if !strings.Contains(problems[0], `"cert-parse --serve"`) || !strings.Contains(problems[0], `cert-parser = "cert_parser.main:main"`) {
+		t.Fatalf("entrypoint problem should quote both values: %s", problems[0])
+	}
+	if !strings.Contains(problems[1], `"/srv"`) || !strings.Contains(problems[1], `"/app"`) {
+		t.Fatalf("workdir problem should quote both values: %s", problems[1])
+	}
+	if got := entrypointProblems([]string{"anything"}, nil, "/app/", "/app", nil); len(got) != 0 {
+		t.Fatalf("no scripts and a matching workdir should pass, got %v", got)
+	}
+	fmt.Println("✅ Entrypoint problems show both values")
+}
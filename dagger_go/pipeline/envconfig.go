@@ -0,0 +1,396 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvConfig is what a binary reads from its environment: the Config of the
+// run, and the settings of the binary around the pipeline.
+type EnvConfig struct {
+	Config      Config
+	Credentials Credentials // where GitToken and RegistryToken came from
+
+	DurationBudget      time.Duration   // warn when a successful run takes longer (optional)
+	DurationBudgetHard  bool            // fail instead of warning
+	DurationHistoryFile string          // per-branch duration history (default: DefaultHistoryFile)
+	Projects            ProjectsOptions // Glob set by DISCOVER_PROJECTS_GLOB runs a monorepo; Dagger and Attach are the binary's
+}
+
+// explicitOptions are the options with a Config field that ConfigFromEnv
+// reads itself rather than through optionParsers — credentials, the git
+// ref and values that depend on other options — or that the binaries
+// resolve: the profile, and the corporate CA certificates.
+var explicitOptions = map[string]bool{
+	"USERNAME":                true,
+	"REPO_NAME":               true,
+	"CR_PAT":                  true,
+	"GITHUB_TOKEN":            true,
+	"REGISTRY_PASSWORD":       true,
+	"REGISTRY_PASSWORD_FILE":  true,
+	"GIT_BRANCH":              true,
+	"GIT_TAG":                 true,
+	"GIT_COMMIT":              true,
+	"GIT_DEPENDENCY_HOSTS":    true,
+	"PIPELINE_PROFILE":        true,
+	"TWINE_REPOSITORY_URL":    true,
+	"DAGGER_ENGINE_STATE_DIR": true,
+	"CA_CERTIFICATES_PATH":    true,
+}
+
+// optionParser parses the set value of an option into the value of its
+// Config field; errors name the variable.
+type optionParser func(env, value string) (any, error)
+
+// optionParsers parse the options whose value is more than a bool, a
+// string or a comma-separated list: sizes, durations, bounded numbers,
+// shell words and the lists and enums with their own syntax.
+var optionParsers = map[string]optionParser{
+	"BEHIND_WARN_THRESHOLD":        intOption(math.MinInt, math.MaxInt, "number of commits, negative disables"),
+	"COMMIT_CHECK":                 parsed(ParseCommitCheck),
+	"CLOCK_SKEW_THRESHOLD":         parseClockSkewThreshold,
+	"IMAGE_LABELS":                 parsed(ParseImageLabels),
+	"NAME_CHECK":                   parsed(ParseNameCheck),
+	"ACCEPTANCE_IMAGE_PORT":        intOption(1, 65535, "TCP port, 1-65535"),
+	"SERVICE_READY_URL":            parseServiceReadyURL,
+	"SERVICE_READY_INTERVAL":       durationOption("use a Go duration such as 1s"),
+	"SERVICE_READY_TIMEOUT":        durationOption("use a Go duration such as 90s"),
+	"PUBLISH_REQUIRES":             func(_, v string) (any, error) { return ParsePublishRequires(v), nil },
+	"PUBLISH_POLICY":               parsePublishPolicy,
+	"PYTHON_VERSIONS":              parsed(ParsePythonVersions),
+	"DEP_RESOLUTION_MATRIX":        parsed(ParseDepResolutions),
+	"LINT_CHANGED_MAX":             intOption(1, math.MaxInt, "number of files, at least 1"),
+	"HOST_TEST_WRAPPER":            parsed(ParseShellWords),
+	"DB_SEED_COMMAND":              parsed(ParseShellWords),
+	"APT_PACKAGES":                 func(_, v string) (any, error) { return ParseAptPackages(v), nil },
+	"EXTRA_APT_PACKAGES":           func(_, v string) (any, error) { return ParseAptPackages(v), nil },
+	"REGISTRY_MIRRORS":             parsed(ParseRegistryMirrors),
+	"STAGE_MEMORY_LIMIT":           parsed(ParseByteSize),
+	"SOURCE_DATE_EPOCH":            func(_, v string) (any, error) { return ParseSourceDateEpoch(v) },
+	"LOCAL_FRAMEWORK_DIRS":         parsed(ParseLocalFrameworkDirs),
+	"STAGE_ARTIFACTS_LIMIT":        parsed(ParseStageArtifactsLimit),
+	"DEPLOY_VERIFY_TIMEOUT":        durationOption("use a Go duration such as 5m"),
+	"IMAGE_SIZE_BUDGET":            parsed(ParseByteSize),
+	"IMAGE_SIZE_MAX_GROWTH":        parseImageSizeMaxGrowth,
+	"WARNINGS_BUDGET":              intOption(1, math.MaxInt, "number of warnings, at least 1"),
+	"PLATFORMS":                    parsed(ParsePlatforms),
+	"LARGE_IMAGE_THRESHOLD":        parsed(ParseLargeImageThreshold),
+	"LARGE_IMAGE_PUBLISH_ATTEMPTS": intOption(1, math.MaxInt, "number of attempts, at least 1"),
+	"LARGE_IMAGE_PUBLISH_TIMEOUT":  durationOption("use a Go duration such as 45m"),
+	"MIN_FREE_SPACE":               parsed(ParseMinFreeSpace),
+	"FLAKY_THRESHOLD":              parseFlakyThreshold,
+	"FLAKY_WINDOW":                 intOption(2, math.MaxInt, "number of runs, at least 2"),
+}
+
+// ConfigFromEnv reads the Config of a run from the environment. Every
+// option of Options with a Config field that binary reads is set by its
+// type — bools, strings and comma-separated lists — or by optionParsers;
+// the options that depend on each other follow: the pull request image,
+// credentials, the git ref and the defaults that follow the branch.
+// Warnings and notes go to out. The binaries add what they resolve or
+// detect themselves: the profile, Docker, the HTTP client and, in the
+// corporate binary, the CA certificates and the system proxy.
+func ConfigFromEnv(binary string, lookupEnv func(string) (string, bool), environ []string, out io.Writer, warnings *Warnings) (*EnvConfig, error) {
+	getenv := func(key string) string {
+		v, _ := lookupEnv(key)
+		return v
+	}
+	cfg := Config{Warnings: warnings}
+	for _, o := range Options {
+		if o.Field == "" || explicitOptions[o.Env] || o.prefix() != "" || !o.forBinary(binary) {
+			continue
+		}
+		if err := setOption(&cfg, o, getenv(o.Env)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.RunPublish && !cfg.RunBuild {
+		warnings.Fprintf(out, "publish-disabled", "⚠️  RUN_BUILD=false — publish disabled as well (nothing to publish)\n")
+		cfg.RunPublish = false
+	}
+	pullRequest, err := DetectPullRequest(getenv)
+	if err != nil {
+		warnings.Fprintf(out, "pull-request", "⚠️  Could not read the pull request of this build: %v\n", err)
+	}
+	cfg.PullRequest = pullRequest
+	if cfg.PRComment && pullRequest == nil {
+		warnings.Fprintf(out, "pr-comment", "⚠️  PR_COMMENT=true outside a pull request build — ignored\n")
+		cfg.PRComment = false
+	}
+	switch {
+	case cfg.PublishPRImage && pullRequest == nil:
+		warnings.Fprintf(out, "pr-image", "⚠️  PUBLISH_PR_IMAGE=true outside a pull request build — ignored\n")
+		cfg.PublishPRImage = false
+	case cfg.PublishPRImage && !cfg.RunBuild:
+		warnings.Fprintf(out, "pr-image", "⚠️  RUN_BUILD=false — no pull request image to publish\n")
+		cfg.PublishPRImage = false
+	case cfg.PublishPRImage:
+		fmt.Fprintf(out, "🐳 Pull request #%d: publishing %s only (PUBLISH_PR_IMAGE)\n", pullRequest.Number, PRImageTag(pullRequest.Number))
+		cfg.RunPublish = false
+	}
+	cfg.PublishTestImage = cfg.PublishTestImage && cfg.RunPublish
+
+	// In GitHub Actions the workflow's repository and GITHUB_TOKEN stand in
+	// for USERNAME/REPO_NAME and CR_PAT
+	workflowOwner, workflowRepo := WorkflowRepository(getenv)
+	cfg.GitUser, cfg.RepoName = getenv("USERNAME"), getenv("REPO_NAME")
+	if cfg.GitUser == "" {
+		cfg.GitUser = workflowOwner
+	}
+	if cfg.RepoName == "" {
+		cfg.RepoName = workflowRepo
+	}
+	if cfg.GitBranch, cfg.GitTag, err = SelectGitRef(getenv("GIT_BRANCH"), getenv("GIT_TAG"), getenv("GIT_COMMIT")); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if cfg.GitHost == "" {
+		cfg.GitHost = "github.com"
+	}
+	if cfg.Registry == "" {
+		cfg.Registry = "ghcr.io"
+	}
+	if cfg.GitAuthUser == "" {
+		cfg.GitAuthUser = "x-access-token"
+	}
+	if cfg.GitDependencyHosts, err = ParseGitCredentialHosts(getenv("GIT_DEPENDENCY_HOSTS"), cfg.GitAuthUser); err != nil {
+		return nil, Errorf(CategoryConfig, "GIT_DEPENDENCY_HOSTS: %w", err)
+	}
+	credentials := ResolveCredentials(getenv, cfg.GitHost, cfg.GitUser, cfg.RepoName, cfg.Registry)
+	registryPassword, err := RegistryPasswordFromEnv(getenv)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	credentials = credentials.WithRegistryPassword(registryPassword)
+	cfg.GitToken = credentials.GitToken
+	cfg.RegistryToken = credentials.RegistryToken
+	cfg.WorkflowToken = credentials.WorkflowToken()
+	cfg.RenewRegistryToken = RegistryTokenFromEnv(getenv)
+
+	if getenv("REQUIRE_DOCKER_TESTS") == "" {
+		cfg.RequireDockerTests = cfg.GitBranch == mainBranch || cfg.RunPublish
+	}
+	if getenv("EXPORT_WHEELS") == "" {
+		cfg.ExportWheels = cfg.GitBranch == PackageUploadBranch
+	}
+	if cfg.ArtifactsDir == "" {
+		cfg.ArtifactsDir = DefaultArtifactsDir
+	}
+	if cfg.FreezeHistoryDir == "" && DefaultHistoryFile() != "" {
+		cfg.FreezeHistoryDir = filepath.Dir(DefaultHistoryFile())
+	}
+	cfg.PackagePublish.IndexURL = PackageIndexURL(getenv("TWINE_REPOSITORY_URL"), envBool(getenv("PACKAGE_PUBLISH_PROD"), false))
+	if cfg.PackagePublish.Username == "" {
+		cfg.PackagePublish.Username = "__token__"
+	}
+	cfg.PackagePublish.Release = cfg.RunPackagePublish
+	cfg.PackagePublish.Upload = cfg.RunPackagePublish && cfg.GitBranch == PackageUploadBranch
+	cfg.PackagePublish.ExportDir = filepath.Join(cfg.ArtifactsDir, "dist")
+	if cfg.PackagePublish.Upload && cfg.PackagePublish.Password == "" {
+		return nil, Errorf(CategoryConfig, "TWINE_PASSWORD must be set when RUN_PACKAGE_PUBLISH=true on the main branch")
+	}
+
+	if cfg.StageEnv, err = StageEnvOverrides(environ, lookupEnv); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	cfg.ToolImages = ToolImageOverrides(environ)
+	if cfg.RunnerHost, err = ParseRunnerHost(getenv("DAGGER_RUNNER_HOST")); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	cfg.EngineStateDir = LocalEngineStateDir(getenv("DAGGER_ENGINE_STATE_DIR"), cfg.RunnerHost.Remote())
+
+	if !cfg.RunUnitTests && !cfg.RunIntegrationTests && !cfg.RunAcceptanceTests {
+		warnings.Fprintf(out, "tests-disabled", "⚠️  All test stages disabled — skipping tests, proceeding to lint/build/push\n")
+	}
+
+	env := &EnvConfig{
+		Config:              cfg,
+		Credentials:         credentials,
+		DurationBudgetHard:  envBool(getenv("DURATION_BUDGET_HARD"), false),
+		DurationHistoryFile: getenv("DURATION_HISTORY_FILE"),
+		Projects: ProjectsOptions{
+			Glob:        getenv("DISCOVER_PROJECTS_GLOB"),
+			ChangedOnly: envBool(getenv("DISCOVER_CHANGED_ONLY"), false),
+			ChangedBase: cfg.ChangedBase,
+		},
+	}
+	if v := getenv("DURATION_BUDGET"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, Errorf(CategoryConfig, "invalid DURATION_BUDGET %q (use a Go duration such as 20m or 1h30m)", v)
+		}
+		env.DurationBudget = d
+	}
+	if env.DurationHistoryFile == "" {
+		env.DurationHistoryFile = DefaultHistoryFile()
+	}
+	return env, nil
+}
+
+// CheckRequired reports the first variable a run needs that is not set:
+// USERNAME and REPO_NAME, and a registry token when publishing. Warmup,
+// cleanup and watch need none of them.
+func (e *EnvConfig) CheckRequired() error {
+	cfg := e.Config
+	switch {
+	case cfg.GitUser == "":
+		return Errorf(CategoryConfig, "USERNAME environment variable must be set")
+	case (cfg.RunPublish || cfg.PublishPRImage) && cfg.RegistryToken == "" && e.Credentials.WorkflowToken():
+		return Errorf(CategoryConfig, "CR_PAT (or REGISTRY_PASSWORD) environment variable must be set\n       (%s)", e.Credentials.Reason)
+	case (cfg.RunPublish || cfg.PublishPRImage) && cfg.RegistryToken == "":
+		return Errorf(CategoryConfig, "CR_PAT (or REGISTRY_PASSWORD) environment variable must be set")
+	case cfg.RepoName == "":
+		return Errorf(CategoryConfig, "REPO_NAME environment variable must be set (e.g. 'cert-parser')")
+	}
+	return nil
+}
+
+// PrintTestConfiguration prints the stages of cfg and where each setting
+// came from.
+func PrintTestConfiguration(out io.Writer, cfg Config) {
+	fmt.Fprintln(out, "🧪 Test Configuration:")
+	fmt.Fprintf(out, "   Unit tests:        %v (RUN_UNIT_TESTS, %s)\n", cfg.RunUnitTests, cfg.OptionSources.Of("RUN_UNIT_TESTS"))
+	fmt.Fprintf(out, "   Integration tests: %v (RUN_INTEGRATION_TESTS, %s)\n", cfg.RunIntegrationTests, cfg.OptionSources.Of("RUN_INTEGRATION_TESTS"))
+	fmt.Fprintf(out, "   Acceptance tests:  %v (RUN_ACCEPTANCE_TESTS, %s)\n", cfg.RunAcceptanceTests, cfg.OptionSources.Of("RUN_ACCEPTANCE_TESTS"))
+	if cfg.RunAcceptanceTests && cfg.AcceptanceImage {
+		fmt.Fprintln(out, "     → against the built image (ACCEPTANCE_AGAINST_IMAGE)")
+	}
+	fmt.Fprintf(out, "   Lint (ruff):       %v (RUN_LINT, %s)\n", cfg.RunLint, cfg.OptionSources.Of("RUN_LINT"))
+	fmt.Fprintf(out, "   Type check (mypy): %v (RUN_TYPE_CHECK, %s)\n", cfg.RunTypeCheck, cfg.OptionSources.Of("RUN_TYPE_CHECK"))
+	fmt.Fprintf(out, "   Docker build:      %v (RUN_BUILD, %s)\n", cfg.RunBuild, cfg.OptionSources.Of("RUN_BUILD"))
+	fmt.Fprintf(out, "   Publish:           %v (RUN_PUBLISH, %s)\n", cfg.RunPublish, cfg.OptionSources.Of("RUN_PUBLISH"))
+	fmt.Fprintf(out, "   Package publish:   %v (RUN_PACKAGE_PUBLISH, %s)\n", cfg.RunPackagePublish, cfg.OptionSources.Of("RUN_PACKAGE_PUBLISH"))
+	fmt.Fprintf(out, "   Export wheels:     %v (EXPORT_WHEELS, %s)\n", cfg.ExportWheels, cfg.OptionSources.Of("EXPORT_WHEELS"))
+}
+
+// setOption sets the Config field of o from value. An unset option keeps
+// the zero value, or for a bool its registered default; of several
+// variables setting one string field, the first in Options wins.
+func setOption(cfg *Config, o Option, value string) error {
+	field := reflect.ValueOf(cfg).Elem()
+	for _, name := range strings.Split(o.Field, ".") {
+		field = field.FieldByName(name)
+	}
+	if parse, ok := optionParsers[o.Env]; ok {
+		if value == "" {
+			return nil
+		}
+		v, err := parse(o.Env, value)
+		if err != nil {
+			return Errorf(CategoryConfig, "%w", err)
+		}
+		field.Set(reflect.ValueOf(v).Convert(field.Type()))
+		return nil
+	}
+	switch {
+	case field.Kind() == reflect.Bool:
+		field.SetBool(envBool(value, o.Default == "true"))
+	case field.Kind() == reflect.String:
+		if field.String() == "" {
+			field.SetString(value)
+		}
+	case field.Type() == reflect.TypeOf([]string(nil)):
+		if value != "" {
+			field.Set(reflect.ValueOf(ParseCommaList(value)))
+		}
+	default:
+		return fmt.Errorf("option %s: no parser for Config.%s (%s)", o.Env, o.Field, field.Type())
+	}
+	return nil
+}
+
+// envBool parses a boolean variable: true, 1 or yes in any case; unset is
+// defaultValue.
+func envBool(value string, defaultValue bool) bool {
+	if value == "" {
+		return defaultValue
+	}
+	value = strings.ToLower(value)
+	return value == "true" || value == "1" || value == "yes"
+}
+
+// parsed adapts a Parse function whose errors do not name the variable.
+func parsed[T any](parse func(string) (T, error)) optionParser {
+	return func(env, value string) (any, error) {
+		v, err := parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+		return v, nil
+	}
+}
+
+// intOption parses a whole number between min and max; hint says what the
+// number is.
+func intOption(min, max int, hint string) optionParser {
+	return func(env, value string) (any, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("invalid %s %q (%s)", env, value, hint)
+		}
+		return n, nil
+	}
+}
+
+// durationOption parses a positive Go duration; hint gives an example.
+func durationOption(hint string) optionParser {
+	return func(env, value string) (any, error) {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q (%s)", env, value, hint)
+		}
+		return d, nil
+	}
+}
+
+// parseClockSkewThreshold parses CLOCK_SKEW_THRESHOLD, where a negative
+// duration turns the check off.
+func parseClockSkewThreshold(env, value string) (any, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d == 0 {
+		return nil, fmt.Errorf("invalid %s %q (use a Go duration such as 2m, negative disables)", env, value)
+	}
+	return d, nil
+}
+
+// parseServiceReadyURL checks SERVICE_READY_URL and keeps it as given.
+func parseServiceReadyURL(env, value string) (any, error) {
+	if err := ValidateServiceReadyURL(value); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", env, err)
+	}
+	return value, nil
+}
+
+// parsePublishPolicy parses PUBLISH_POLICY into Config.PublishPolicyOff.
+func parsePublishPolicy(env, value string) (any, error) {
+	value = strings.ToLower(value)
+	if value != "on" && value != "off" {
+		return nil, fmt.Errorf("invalid %s %q (use on or off)", env, value)
+	}
+	return value == "off", nil
+}
+
+// parseImageSizeMaxGrowth parses IMAGE_SIZE_MAX_GROWTH, a percentage with
+// or without the % sign.
+func parseImageSizeMaxGrowth(env, value string) (any, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid %s %q (use a percentage such as 20)", env, value)
+	}
+	return n, nil
+}
+
+// parseFlakyThreshold parses FLAKY_THRESHOLD, a failure rate in (0, 1].
+func parseFlakyThreshold(env, value string) (any, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 || f > 1 {
+		return nil, fmt.Errorf("invalid %s %q (failure rate between 0 and 1, e.g. 0.2)", env, value)
+	}
+	return f, nil
+}
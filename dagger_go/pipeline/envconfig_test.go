@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapEnv returns a lookupEnv and environ reading env.
+func mapEnv(env map[string]string) (func(string) (string, bool), []string) {
+	var environ []string
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	return func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}, environ
+}
+
+// configFromMap runs ConfigFromEnv for binary over env.
+func configFromMap(t *testing.T, binary string, env map[string]string) *EnvConfig {
+	t.Helper()
+	lookupEnv, environ := mapEnv(env)
+	e, err := ConfigFromEnv(binary, lookupEnv, environ, io.Discard, NewWarnings())
+	if err != nil {
+		t.Fatalf("ConfigFromEnv: %v", err)
+	}
+	return e
+}
+
+// TestConfigFromEnvDefaults tests the Config of an empty environment
+func TestConfigFromEnvDefaults(t *testing.T) {
+	cfg := configFromMap(t, BinaryStandard, map[string]string{"HOME": t.TempDir()}).Config
+	if !cfg.RunUnitTests || !cfg.RunLint || !cfg.RunBuild || !cfg.RunPublish || cfg.RunSlowTests || cfg.NoCache {
+		t.Fatalf("unexpected stage defaults: %+v", cfg)
+	}
+	if cfg.GitHost != "github.com" || cfg.Registry != "ghcr.io" || cfg.GitAuthUser != "x-access-token" || cfg.ArtifactsDir != DefaultArtifactsDir {
+		t.Fatalf("unexpected repository defaults: host %q registry %q auth %q artifacts %q", cfg.GitHost, cfg.Registry, cfg.GitAuthUser, cfg.ArtifactsDir)
+	}
+	if cfg.GitBranch != "main" || !cfg.RequireDockerTests || !cfg.ExportWheels || cfg.PublishTestImage {
+		t.Fatalf("main requires the Docker tests and exports wheels: %+v", cfg)
+	}
+	if cfg.PackagePublish.Username != "__token__" || cfg.PackagePublish.ExportDir != DefaultArtifactsDir+"/dist" || cfg.Warnings == nil {
+		t.Fatalf("unexpected package publish defaults: %+v", cfg.PackagePublish)
+	}
+	if cfg.FlakyWindow != 0 || cfg.StageMemoryLimit != 0 || cfg.PythonVersions != nil || cfg.ProxyURL != "" {
+		t.Fatal("unset options must keep their zero value for New to default")
+	}
+	fmt.Println("✅ Empty environment gives the documented defaults")
+}
+
+// TestConfigFromEnv tests reading options by type, by their parsers and by binary
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"HOME":                   t.TempDir(),
+		"USERNAME":               "Javier-Godon",
+		"REPO_NAME":              "cert-parser",
+		"CR_PAT":                 "ghp_token",
+		"GIT_BRANCH":             "main",
+		"REGISTRY_HOST":          "harbor.corp.local",
+		"REGISTRY":               "docker.io",
+		"RUN_ACCEPTANCE_TESTS":   "no",
+		"RUN_SLOW_TESTS":         "YES",
+		"PUBLISH_TEST_IMAGE":     "1",
+		"OFFLINE_ALLOW_HOSTS":    "pypi.corp.local, files.corp.local",
+		"STAGE_MEMORY_LIMIT":     "2GiB",
+		"FLAKY_WINDOW":           "10",
+		"FLAKY_THRESHOLD":        "0.5",
+		"CLOCK_SKEW_THRESHOLD":   "-1s",
+		"BEHIND_WARN_THRESHOLD":  "-1",
+		"IMAGE_SIZE_MAX_GROWTH":  "15%",
+		"PUBLISH_POLICY":         "OFF",
+		"HOST_TEST_WRAPPER":      "xvfb-run -a",
+		"PYTHON_VERSIONS":        "3.12,3.13",
+		"IMAGE_LABELS":           "team=platform",
+		"SERVICE_READY_TIMEOUT":  "90s",
+		"TWINE_USERNAME":         "ci",
+		"STAGE_ENV_UNIT":         "PYTHONHASHSEED=0",
+		"TOOL_IMAGE_TRIVY":       "aquasec/trivy:0.60.0",
+		"HTTP_PROXY":             "http://proxy.corp.local:8080",
+		"HTTPS_PROXY":            "http://other.corp.local:8080",
+		"DEPLOY_VERIFY_TIMEOUT":  "2m",
+		"DURATION_BUDGET":        "20m",
+		"DISCOVER_PROJECTS_GLOB": "services/*",
+	}
+	e := configFromMap(t, BinaryStandard, env)
+	cfg := e.Config
+	if cfg.GitUser != "Javier-Godon" || cfg.RepoName != "cert-parser" || cfg.RegistryToken != "ghp_token" || e.Credentials.Source != CredentialPAT {
+		t.Fatalf("unexpected repository and credentials: %+v", e.Credentials)
+	}
+	if cfg.Registry != "harbor.corp.local" {
+		t.Fatalf("REGISTRY_HOST must win over REGISTRY, got %q", cfg.Registry)
+	}
+	if cfg.RunAcceptanceTests || !cfg.RunSlowTests || !cfg.PublishTestImage || !cfg.ExportWheels {
+		t.Fatalf("unexpected bools: %+v", cfg)
+	}
+	if strings.Join(cfg.OfflineAllowHosts, " ") != "pypi.corp.local files.corp.local" || strings.Join(cfg.HostTestWrapper, " ") != "xvfb-run -a" {
+		t.Fatalf("unexpected lists: %q %q", cfg.OfflineAllowHosts, cfg.HostTestWrapper)
+	}
+	if cfg.StageMemoryLimit != 2<<30 || cfg.FlakyWindow != 10 || cfg.FlakyThreshold != 0.5 || cfg.ClockSkewThreshold != -time.Second ||
+		cfg.BehindWarnThreshold != -1 || cfg.ImageSizeMaxGrowth != 15 || !cfg.PublishPolicyOff || cfg.ServiceReadyTimeout != 90*time.Second {
+		t.Fatalf("unexpected parsed values: %+v", cfg)
+	}
+	if len(cfg.PythonVersions) != 2 || cfg.ImageLabels["team"] != "platform" || cfg.PackagePublish.Username != "ci" {
+		t.Fatalf("unexpected parsed values: %q %v %q", cfg.PythonVersions, cfg.ImageLabels, cfg.PackagePublish.Username)
+	}
+	if len(cfg.StageEnv) != 1 || cfg.ToolImages["trivy"] != "aquasec/trivy:0.60.0" {
+		t.Fatalf("unexpected families: %v %v", cfg.StageEnv, cfg.ToolImages)
+	}
+	if e.DurationBudget != 20*time.Minute || e.DurationHistoryFile == "" || e.Projects.Glob != "services/*" {
+		t.Fatalf("unexpected binary settings: %+v", e)
+	}
+	if cfg.ProxyURL != "" || cfg.DeployVerifyTimeout != 0 {
+		t.Fatal("the standard binary must not read the corporate options")
+	}
+
+	cfg = configFromMap(t, BinaryCorporate, env).Config
+	if cfg.ProxyURL != "http://proxy.corp.local:8080" || cfg.DeployVerifyTimeout != 2*time.Minute {
+		t.Fatalf("the corporate binary reads HTTP_PROXY first and DEPLOY_VERIFY_TIMEOUT: %q %s", cfg.ProxyURL, cfg.DeployVerifyTimeout)
+	}
+	fmt.Println("✅ Options read by type, parser and binary")
+}
+
+// TestConfigFromEnvDependent tests the options that depend on each other
+func TestConfigFromEnvDependent(t *testing.T) {
+	cfg := configFromMap(t, BinaryStandard, map[string]string{"RUN_BUILD": "false", "PUBLISH_TEST_IMAGE": "true", "GIT_BRANCH": "feature"}).Config
+	if cfg.RunPublish || cfg.PublishTestImage || cfg.RequireDockerTests {
+		t.Fatalf("RUN_BUILD=false disables publishing and the Docker test requirement off main: %+v", cfg)
+	}
+	cfg = configFromMap(t, BinaryStandard, map[string]string{"RUN_PUBLISH": "false", "REQUIRE_DOCKER_TESTS": "true", "EXPORT_WHEELS": "true", "GIT_BRANCH": "feature"}).Config
+	if !cfg.RequireDockerTests || !cfg.ExportWheels {
+		t.Fatalf("explicit REQUIRE_DOCKER_TESTS and EXPORT_WHEELS must win: %+v", cfg)
+	}
+	cfg = configFromMap(t, BinaryStandard, map[string]string{"PR_COMMENT": "true", "PUBLISH_PR_IMAGE": "true"}).Config
+	if cfg.PRComment || cfg.PublishPRImage {
+		t.Fatal("PR_COMMENT and PUBLISH_PR_IMAGE are ignored outside a pull request build")
+	}
+	fmt.Println("✅ Dependent options resolved")
+}
+
+// TestConfigFromEnvErrors tests that invalid values are configuration errors naming the variable
+func TestConfigFromEnvErrors(t *testing.T) {
+	for env, value := range map[string]string{
+		"FLAKY_WINDOW":          "1",
+		"FLAKY_THRESHOLD":       "2",
+		"STAGE_MEMORY_LIMIT":    "lots",
+		"ACCEPTANCE_IMAGE_PORT": "70000",
+		"PUBLISH_POLICY":        "maybe",
+		"NAME_CHECK":            "strict",
+		"SOURCE_DATE_EPOCH":     "yesterday",
+		"DURATION_BUDGET":       "-5m",
+		"GIT_DEPENDENCY_HOSTS":  "https://",
+		"DAGGER_RUNNER_HOST":    "ftp://engine",
+	} {
+		lookupEnv, environ := mapEnv(map[string]string{env: value})
+		_, err := ConfigFromEnv(BinaryStandard, lookupEnv, environ, io.Discard, NewWarnings())
+		if err == nil || CategoryOf(err) != CategoryConfig {
+			t.Fatalf("%s=%s: expected a configuration error, got %v", env, value, err)
+		}
+		if env != "DAGGER_RUNNER_HOST" && !strings.Contains(err.Error(), env) {
+			t.Fatalf("%s=%s: error does not name the variable: %v", env, value, err)
+		}
+	}
+	lookupEnv, environ := mapEnv(map[string]string{"RUN_PACKAGE_PUBLISH": "true", "GIT_BRANCH": "main"})
+	if _, err := ConfigFromEnv(BinaryStandard, lookupEnv, environ, io.Discard, NewWarnings()); err == nil || !strings.Contains(err.Error(), "TWINE_PASSWORD") {
+		t.Fatalf("expected TWINE_PASSWORD to be required, got %v", err)
+	}
+	fmt.Println("✅ Invalid options rejected as configuration errors")
+}
+
+// TestCheckRequired tests the variables a run cannot do without
+func TestCheckRequired(t *testing.T) {
+	check := func(env map[string]string) error {
+		return configFromMap(t, BinaryStandard, env).CheckRequired()
+	}
+	if err := check(map[string]string{"REPO_NAME": "cert-parser"}); err == nil || !strings.Contains(err.Error(), "USERNAME") {
+		t.Fatalf("expected USERNAME to be required, got %v", err)
+	}
+	if err := check(map[string]string{"USERNAME": "Javier-Godon", "REPO_NAME": "cert-parser"}); err == nil || !strings.Contains(err.Error(), "CR_PAT") {
+		t.Fatalf("expected CR_PAT to be required when publishing, got %v", err)
+	}
+	if err := check(map[string]string{"USERNAME": "Javier-Godon", "RUN_PUBLISH": "false"}); err == nil || !strings.Contains(err.Error(), "REPO_NAME") {
+		t.Fatalf("expected REPO_NAME to be required, got %v", err)
+	}
+	if err := check(map[string]string{"USERNAME": "Javier-Godon", "REPO_NAME": "cert-parser", "RUN_PUBLISH": "false"}); err != nil {
+		t.Fatalf("expected no error without publishing, got %v", err)
+	}
+	fmt.Println("✅ Required variables checked")
+}
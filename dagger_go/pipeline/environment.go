@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// Environment fingerprints where and with what a run happened, so two runs
+// that disagree can be compared field by field.
+type Environment struct {
+	Binary         string `json:"binary"`
+	GoVersion      string `json:"go_version"`
+	DaggerSDK      string `json:"dagger_sdk"`
+	DaggerEngine   string `json:"dagger_engine,omitempty"`
+	OS             string `json:"os"`
+	Arch           string `json:"arch"`
+	DockerEndpoint string `json:"docker_endpoint,omitempty"`
+	DockerServer   string `json:"docker_server,omitempty"`
+	BaseImage      string `json:"base_image"`                // digest-pinned reference when it could be resolved
+	DependencyHash string `json:"dependency_hash,omitempty"` // sha256 of pyproject.toml
+	FreezeHash     string `json:"freeze_hash,omitempty"`     // sha256 of the resolved pip freeze
+	Proxy          bool   `json:"proxy"`
+	CorporateCAs   int    `json:"corporate_cas"`
+}
+
+// BinaryVersion identifies the running binary: its module version, or the
+// VCS revision it was built from ("-dirty" with uncommitted changes).
+func BinaryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" && (version == "" || version == "(devel)") {
+		version = revision[:min(12, len(revision))]
+		if modified {
+			version += "-dirty"
+		}
+	}
+	if version == "" {
+		return "(devel)"
+	}
+	return version
+}
+
+// sha256Hex is the hex SHA-256 of content.
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// dockerServerVersion asks the host Docker CLI for the server version; any
+// problem leaves it empty.
+func dockerServerVersion(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", "version", "--format", "{{.Server.Version}}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// fingerprint collects the run environment into the report and prints it.
+// The dependency and freeze hashes are filled in once the source is cloned
+// and the build environment installed.
+func (p *Pipeline) fingerprint(ctx context.Context, client *dagger.Client) {
+	cfg := p.cfg
+	env := &Environment{
+		Binary:       BinaryVersion(),
+		GoVersion:    runtime.Version(),
+		DaggerSDK:    SDKVersion,
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		BaseImage:    p.baseImage(),
+		Proxy:        cfg.ProxyURL != "",
+		CorporateCAs: len(cfg.CACertPaths),
+	}
+	if engine, err := client.Version(ctx); err == nil {
+		env.DaggerEngine = engine
+	}
+	if ref, err := p.from(client, p.baseImage()).ImageRef(ctx); err == nil {
+		env.BaseImage = ref
+	}
+	if cfg.HasDocker {
+		env.DockerEndpoint = strings.TrimPrefix(cfg.DockerDetection, "socket ")
+		env.DockerServer = dockerServerVersion(ctx)
+	}
+	p.report.Environment = env
+	PrintEnvironment(p.out, env)
+}
+
+// PrintEnvironment prints the fingerprint block shown at the start of a run.
+func PrintEnvironment(w io.Writer, env *Environment) {
+	orNone := func(s, none string) string {
+		if s == "" {
+			return none
+		}
+		return s
+	}
+	yesNo := map[bool]string{true: "yes", false: "no"}
+	fmt.Fprintln(w, "\n🧬 Environment fingerprint:")
+	fmt.Fprintf(w, "   Binary:        %s (%s)\n", env.Binary, env.GoVersion)
+	fmt.Fprintf(w, "   Dagger:        SDK v%s, engine %s\n", env.DaggerSDK, orNone(env.DaggerEngine, "unknown"))
+	fmt.Fprintf(w, "   Host:          %s/%s\n", env.OS, env.Arch)
+	fmt.Fprintf(w, "   Docker:        %s", orNone(env.DockerEndpoint, "not used"))
+	if env.DockerServer != "" {
+		fmt.Fprintf(w, " (server %s)", env.DockerServer)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "   Base image:    %s\n", env.BaseImage)
+	fmt.Fprintf(w, "   Proxy:         %s\n", yesNo[env.Proxy])
+	fmt.Fprintf(w, "   Corporate CAs: %d\n", env.CorporateCAs)
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestEnvironmentFingerprint tests the fingerprint block and its report fields
+func TestEnvironmentFingerprint(t *testing.T) {
+	env := &Environment{
+		Binary:         BinaryVersion(),
+		GoVersion:      "go1.24.0",
+		DaggerSDK:      SDKVersion,
+		DaggerEngine:   "v0.19.7",
+		OS:             "linux",
+		Arch:           "amd64",
+		DockerEndpoint: "/var/run/docker.sock",
+		DockerServer:   "27.3.1",
+		BaseImage:      BaseImage + "@sha256:abc",
+		Proxy:          true,
+		CorporateCAs:   2,
+	}
+	if env.Binary == "" {
+		t.Fatal("BinaryVersion() is empty")
+	}
+
+	var out bytes.Buffer
+	PrintEnvironment(&out, env)
+	for _, want := range []string{"engine v0.19.7", "linux/amd64", "/var/run/docker.sock (server 27.3.1)", "Proxy:         yes", "Corporate CAs: 2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("fingerprint missing %q:\n%s", want, out.String())
+		}
+	}
+
+	data, err := json.Marshal(&Report{Environment: env})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"environment":{"binary":`) || !strings.Contains(string(data), `"corporate_cas":2`) {
+		t.Fatalf("unexpected report JSON: %s", data)
+	}
+	fmt.Println("✅ Environment fingerprint printed and recorded")
+}
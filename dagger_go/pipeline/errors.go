@@ -0,0 +1,150 @@
+// Package pipeline holds the pieces shared by the standard (main.go) and
+// corporate (corporate_main.go) pipeline binaries.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Category classifies a pipeline failure so wrapper scripts and CI systems
+// can tell an infrastructure problem from a failing test.
+type Category int
+
+const (
+	CategoryUnknown   Category = iota // Uncategorized failure (exit 1)
+	CategoryConfig                    // Configuration / validation error (exit 2)
+	CategoryClone                     // Clone or authentication failure (exit 3)
+	CategoryTest                      // Test failure (exit 4)
+	CategoryLint                      // Lint or type-check failure (exit 5)
+	CategoryBuild                     // Docker build failure (exit 6)
+	CategoryPublish                   // Registry publish failure (exit 7)
+	CategoryEngine                    // Dagger engine unreachable or incompatible (exit 8)
+	CategoryDeploy                    // Deployment verification failed after publishing (exit 9)
+	CategoryBudget                    // Successful run over DURATION_BUDGET with DURATION_BUDGET_HARD (exit 10)
+	CategoryDocker                    // Docker-dependent tests required but Docker unavailable (exit 11)
+	CategoryCancelled                 // Run cancelled by signal (exit 130)
+)
+
+// Process exit codes returned by both pipeline binaries.
+const (
+	ExitSuccess   = 0
+	ExitFailure   = 1
+	ExitConfig    = 2
+	ExitClone     = 3
+	ExitTest      = 4
+	ExitLint      = 5
+	ExitBuild     = 6
+	ExitPublish   = 7
+	ExitEngine    = 8
+	ExitDeploy    = 9
+	ExitBudget    = 10
+	ExitDocker    = 11
+	ExitCancelled = 130
+)
+
+// String returns a short human-readable label for the category.
+func (c Category) String() string {
+	switch c {
+	case CategoryConfig:
+		return "configuration"
+	case CategoryClone:
+		return "clone"
+	case CategoryTest:
+		return "test"
+	case CategoryLint:
+		return "lint"
+	case CategoryBuild:
+		return "build"
+	case CategoryPublish:
+		return "publish"
+	case CategoryEngine:
+		return "engine"
+	case CategoryDeploy:
+		return "deploy"
+	case CategoryBudget:
+		return "duration budget"
+	case CategoryDocker:
+		return "docker unavailable"
+	case CategoryCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// ExitCode returns the process exit code for the category.
+func (c Category) ExitCode() int {
+	switch c {
+	case CategoryConfig:
+		return ExitConfig
+	case CategoryClone:
+		return ExitClone
+	case CategoryTest:
+		return ExitTest
+	case CategoryLint:
+		return ExitLint
+	case CategoryBuild:
+		return ExitBuild
+	case CategoryPublish:
+		return ExitPublish
+	case CategoryEngine:
+		return ExitEngine
+	case CategoryDeploy:
+		return ExitDeploy
+	case CategoryBudget:
+		return ExitBudget
+	case CategoryDocker:
+		return ExitDocker
+	case CategoryCancelled:
+		return ExitCancelled
+	default:
+		return ExitFailure
+	}
+}
+
+// PipelineError is an error tagged with a failure category. It survives
+// further wrapping with fmt.Errorf("...: %w", err), so the category assigned
+// by the failing stage is what the main function sees.
+type PipelineError struct {
+	Category Category
+	Err      error
+}
+
+func (e *PipelineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// Errorf formats an error like fmt.Errorf and tags it with a category.
+func Errorf(category Category, format string, args ...any) error {
+	return &PipelineError{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// CategoryOf returns the category of the outermost PipelineError in err's
+// chain. Context cancellation is always reported as CategoryCancelled.
+func CategoryOf(err error) Category {
+	if err == nil {
+		return CategoryUnknown
+	}
+	if errors.Is(err, context.Canceled) {
+		return CategoryCancelled
+	}
+	var pe *PipelineError
+	if errors.As(err, &pe) {
+		return pe.Category
+	}
+	return CategoryUnknown
+}
+
+// ExitCode maps an error returned by a pipeline run to a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	return CategoryOf(err).ExitCode()
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestExitCodeMapping tests the exit code for representative stage failures
+func TestExitCodeMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"success", nil, ExitSuccess},
+		{"missing REPO_NAME", Errorf(CategoryConfig, "REPO_NAME environment variable is required"), ExitConfig},
+		{"clone auth", Errorf(CategoryClone, "failed to get commit SHA: %w", errors.New("401")), ExitClone},
+		{"unit tests", Errorf(CategoryTest, "unit tests failed: %w", errors.New("exit 1")), ExitTest},
+		{"ruff", Errorf(CategoryLint, "ruff lint failed"), ExitLint},
+		{"mypy", Errorf(CategoryLint, "mypy type check failed"), ExitLint},
+		{"docker build", Errorf(CategoryBuild, "docker build failed"), ExitBuild},
+		{"publish", Errorf(CategoryPublish, "failed to publish versioned image"), ExitPublish},
+		{"engine", Errorf(CategoryEngine, "dagger engine unreachable"), ExitEngine},
+		{"deploy", Errorf(CategoryDeploy, "deployment not verified"), ExitDeploy},
+		{"duration budget", Errorf(CategoryBudget, "pipeline took 25m0s, over the 20m0s duration budget"), ExitBudget},
+		{"no docker", Errorf(CategoryDocker, "Docker-dependent tests required but Docker is unavailable"), ExitDocker},
+		{"cancelled", fmt.Errorf("unit tests failed: %w", context.Canceled), ExitCancelled},
+		{"uncategorized", errors.New("boom"), ExitFailure},
+	}
+
+	for _, tc := range tests {
+		if got := ExitCode(tc.err); got != tc.expected {
+			t.Fatalf("%s: ExitCode() = %d, want %d", tc.name, got, tc.expected)
+		}
+	}
+	fmt.Println("✅ Exit codes map correctly for all failure categories")
+}
+
+// TestExitCodeSurvivesWrapping tests that wrapping a stage error keeps its category
+func TestExitCodeSurvivesWrapping(t *testing.T) {
+	stageErr := Errorf(CategoryTest, "integration tests failed: %w", errors.New("exit status 1"))
+	wrapped := fmt.Errorf("pipeline: %w", fmt.Errorf("stage 2: %w", stageErr))
+
+	if got := CategoryOf(wrapped); got != CategoryTest {
+		t.Fatalf("CategoryOf(wrapped) = %v, want %v", got, CategoryTest)
+	}
+	if got := ExitCode(wrapped); got != ExitTest {
+		t.Fatalf("ExitCode(wrapped) = %d, want %d", got, ExitTest)
+	}
+	if wrapped.Error() != "pipeline: stage 2: integration tests failed: exit status 1" {
+		t.Fatalf("unexpected message: %s", wrapped.Error())
+	}
+	fmt.Println("✅ Category preserved through wrapping")
+}
+
+// TestCancellationWinsOverCategory tests that a cancelled stage exits 130
+func TestCancellationWinsOverCategory(t *testing.T) {
+	err := Errorf(CategoryPublish, "failed to publish versioned image: %w", context.Canceled)
+	if got := ExitCode(err); got != ExitCancelled {
+		t.Fatalf("ExitCode() = %d, want %d", got, ExitCancelled)
+	}
+	fmt.Println("✅ Cancellation maps to exit 130")
+}
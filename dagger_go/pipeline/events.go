@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EventsSchemaVersion is the "schema" of every event in EVENTS_FILE. It
+// changes when a field is renamed or removed, or changes meaning; new event
+// types and fields do not change it.
+const EventsSchemaVersion = 1
+
+// EventsHeartbeatInterval is how often a running pipeline writes a
+// heartbeat event, so a reader can tell a long stage from a hung run.
+const EventsHeartbeatInterval = 30 * time.Second
+
+// EventType is the kind of an event.
+type EventType string
+
+// Event types. Each stage has exactly one stage_started event and one
+// stage_completed, stage_failed or stage_skipped event; a skipped stage's
+// started event comes just before its skipped event.
+const (
+	EventRunStarted     EventType = "run_started"
+	EventStageStarted   EventType = "stage_started"
+	EventStageCompleted EventType = "stage_completed"
+	EventStageFailed    EventType = "stage_failed"
+	EventStageSkipped   EventType = "stage_skipped"
+	EventWarning        EventType = "warning"
+	EventTestSummary    EventType = "test_summary"
+	EventPublished      EventType = "published"
+	EventHeartbeat      EventType = "heartbeat"
+	EventRunFinished    EventType = "run_finished"
+)
+
+// Event is one line of EVENTS_FILE, a JSON object per line. Fields that do
+// not apply to the type are left out.
+type Event struct {
+	Schema int       `json:"schema"` // EventsSchemaVersion
+	Time   time.Time `json:"time"`
+	Type   EventType `json:"type"`
+	// The project directory of every event of a run in one, e.g. a monorepo project
+	Project string `json:"project,omitempty"`
+	// run_started: what is built
+	Repo   string `json:"repo,omitempty"`
+	GitRef string `json:"git_ref,omitempty"`
+	// Stage events; heartbeat: the running stage
+	Stage           string  `json:"stage,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"` // stage_completed, stage_failed
+	// stage_failed and stage_skipped: why; warning: its text; run_finished: the error
+	Message   string     `json:"message,omitempty"`
+	WarningID string     `json:"warning_id,omitempty"` // warning: the ID WARNINGS_ALLOW takes
+	Tests     *TestCount `json:"tests,omitempty"`      // test_summary: the outcome counts of Stage
+	Image     string     `json:"image,omitempty"`      // published: the reference with its digest
+	// heartbeat and run_finished: time since run_started
+	ElapsedSeconds float64    `json:"elapsed_seconds,omitempty"`
+	Outcome        RunOutcome `json:"outcome,omitempty"`   // run_finished
+	ExitCode       *int       `json:"exit_code,omitempty"` // run_finished: what the binary exits with
+}
+
+// EventLog writes events as JSON lines, next to the human output. A nil
+// EventLog writes nothing. Safe for concurrent use.
+type EventLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	now     func() time.Time
+	project string // stamped on every event
+}
+
+// NewEventLog writes events to w.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w, now: time.Now}
+}
+
+// OpenEventLog appends events to the file at path, so the projects of a
+// monorepo run share one stream.
+func OpenEventLog(path string) (*EventLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	log := NewEventLog(f)
+	log.closer = f
+	return log, nil
+}
+
+// Emit writes event with the schema version and, unless set, the time.
+// Events are a side channel: a failed write does not fail the run.
+func (l *EventLog) Emit(event Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event.Schema, event.Project = EventsSchemaVersion, l.project
+	if event.Time.IsZero() {
+		event.Time = l.now().UTC()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	l.w.Write(append(data, '\n'))
+}
+
+// Attach writes the stage events of the stages t records.
+func (l *EventLog) Attach(t *Tracker) {
+	if l == nil {
+		return
+	}
+	t.OnTransition(func(rec StageRecord) {
+		event := Event{Stage: rec.Name, Message: rec.Message}
+		switch rec.Status {
+		case StageRunning:
+			event.Type, event.Time, event.Message = EventStageStarted, rec.StartedAt.UTC(), ""
+		case StagePassed:
+			event.Type, event.DurationSeconds = EventStageCompleted, rec.DurationSeconds
+		case StageFailed:
+			event.Type, event.DurationSeconds = EventStageFailed, rec.DurationSeconds
+		case StageSkipped:
+			l.Emit(Event{Type: EventStageStarted, Time: rec.StartedAt.UTC(), Stage: rec.Name})
+			event.Type = EventStageSkipped
+		default:
+			return
+		}
+		l.Emit(event)
+	})
+}
+
+// Close closes the events file.
+func (l *EventLog) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// startEvents opens EventsFile, writes the run_started event and the stage
+// events of the run, and starts the heartbeat. The returned function stops
+// the heartbeat and writes run_finished with the run's error. Without
+// EventsFile, or when it cannot be opened, the run has no events.
+func (p *Pipeline) startEvents() func(error) {
+	cfg := p.cfg
+	if cfg.EventsFile == "" {
+		return func(error) {}
+	}
+	events, err := OpenEventLog(cfg.EventsFile)
+	if err != nil {
+		p.warnf("events-file", "⚠️  Could not open EVENTS_FILE, no events are written: %v\n", err)
+		return func(error) {}
+	}
+	events.project = cfg.ProjectDir
+	p.events = events
+	started := time.Now()
+	events.Emit(Event{Type: EventRunStarted, Repo: cfg.GitRepo, GitRef: gitRefName(cfg)})
+	events.Attach(p.tracker)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(EventsHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				events.Emit(Event{Type: EventHeartbeat, Stage: p.tracker.Snapshot().CurrentStage, ElapsedSeconds: time.Since(started).Seconds()})
+			}
+		}
+	}()
+
+	return func(err error) {
+		close(stop)
+		<-done
+		finished := Event{Type: EventRunFinished, ElapsedSeconds: time.Since(started).Seconds(), Outcome: p.report.Outcome}
+		exitCode := 0
+		if err != nil {
+			exitCode, finished.Message = CategoryOf(err).ExitCode(), err.Error()
+		}
+		finished.ExitCode = &exitCode
+		events.Emit(finished)
+		p.events = nil
+		if err := events.Close(); err != nil {
+			p.warnf("events-file", "⚠️  Could not close EVENTS_FILE: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readEvents parses a JSON-lines event stream.
+func readEvents(t *testing.T, data []byte) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		if event.Schema != EventsSchemaVersion || event.Time.IsZero() {
+			t.Fatalf("event without schema or time: %q", line)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// TestStageEvents tests that every stage gets exactly one started and one completed, failed or skipped event
+func TestStageEvents(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewTracker("")
+	NewEventLog(&buf).Attach(tracker)
+
+	tracker.StartStage(StageUnitTests)
+	tracker.StartStage(StageLint) // closes unit-tests as passed
+	tracker.SkipStage(StageIntegrationTests, "no Docker")
+	tracker.StartStage(StageTypeCheck)
+	tracker.FailStage(errors.New("mypy: 3 errors"))
+	tracker.StartStage(StageBuild)
+	tracker.Finish(Errorf(CategoryBuild, "docker build failed"))
+
+	started := map[string]int{}
+	ended := map[string][]EventType{}
+	for _, event := range readEvents(t, buf.Bytes()) {
+		if event.Type == EventStageStarted {
+			if len(ended[event.Stage]) > 0 {
+				t.Fatalf("%s started after it ended", event.Stage)
+			}
+			started[event.Stage]++
+		} else {
+			ended[event.Stage] = append(ended[event.Stage], event.Type)
+		}
+	}
+	want := map[string]EventType{
+		StageUnitTests:        EventStageCompleted,
+		StageLint:             EventStageCompleted,
+		StageIntegrationTests: EventStageSkipped,
+		StageTypeCheck:        EventStageFailed,
+		StageBuild:            EventStageFailed,
+	}
+	if len(started) != len(want) {
+		t.Fatalf("unexpected stages %v", started)
+	}
+	for stage, outcome := range want {
+		if started[stage] != 1 || len(ended[stage]) != 1 || ended[stage][0] != outcome {
+			t.Fatalf("%s: %d started, ended with %v; want 1 started and %s", stage, started[stage], ended[stage], outcome)
+		}
+	}
+	fmt.Println("✅ One started and one terminal event per stage")
+}
+
+// TestPipelineEvents tests the run, warning, test summary and published events of EVENTS_FILE
+func TestPipelineEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "run.jsonl")
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitBranch: "main", ProjectDir: "services/parser", EventsFile: path, Output: &strings.Builder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finish := p.startEvents()
+	p.tracker.StartStage(StageUnitTests)
+	p.warnf("junit", "   ⚠️  No test outcomes recorded for %s: %v\n", "unit", "missing")
+	junit := filepath.Join(t.TempDir(), "unit.xml")
+	os.WriteFile(junit, []byte(`<testsuite><testcase classname="t" name="a"/><testcase classname="t" name="b"><failure/></testcase></testsuite>`), 0o644)
+	p.collectJUnit("unit", junit)
+	p.events.Emit(Event{Type: EventPublished, Image: "ghcr.io/org/cert-parser:v1@sha256:beef"})
+	p.tracker.Finish(nil)
+	finish(Errorf(CategoryTest, "1 test failed"))
+	p.events.Emit(Event{Type: EventWarning}) // after the run: dropped
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := readEvents(t, data)
+	var types []string
+	for _, event := range events {
+		types = append(types, string(event.Type))
+		if event.Project != "services/parser" {
+			t.Fatalf("event without the project: %+v", event)
+		}
+	}
+	if strings.Join(types, " ") != "run_started stage_started warning test_summary published stage_completed run_finished" {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if e := events[0]; e.Repo != "https://github.com/org/cert-parser.git" || e.GitRef != "main" {
+		t.Fatalf("unexpected run_started %+v", e)
+	}
+	if e := events[2]; e.WarningID != "junit" || e.Message != "No test outcomes recorded for unit: missing" {
+		t.Fatalf("unexpected warning %+v", e)
+	}
+	if e := events[3]; e.Stage != "unit" || e.Tests == nil || e.Tests.Passed != 1 || e.Tests.Failed != 1 {
+		t.Fatalf("unexpected test summary %+v", e)
+	}
+	if e := events[6]; e.ExitCode == nil || *e.ExitCode != ExitTest || e.Message != "1 test failed" {
+		t.Fatalf("unexpected run_finished %+v", e)
+	}
+	fmt.Println("✅ Run events written to EVENTS_FILE")
+}
@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// flagAliases are shorthand flags for common options: --skip-lint is
+// --run-lint=false.
+var flagAliases = []struct {
+	Name   string
+	Env    string
+	Negate bool // a boolean flag that sets the option to the opposite value
+}{
+	{Name: "branch", Env: "GIT_BRANCH"},
+	{Name: "source-dir", Env: "LOCAL_SOURCE"},
+	{Name: "skip-unit", Env: "RUN_UNIT_TESTS", Negate: true},
+	{Name: "skip-integration", Env: "RUN_INTEGRATION_TESTS", Negate: true},
+	{Name: "skip-acceptance", Env: "RUN_ACCEPTANCE_TESTS", Negate: true},
+	{Name: "skip-lint", Env: "RUN_LINT", Negate: true},
+	{Name: "skip-typecheck", Env: "RUN_TYPE_CHECK", Negate: true},
+	{Name: "skip-build", Env: "RUN_BUILD", Negate: true},
+	{Name: "skip-publish", Env: "RUN_PUBLISH", Negate: true},
+}
+
+// FlagSetting is one option given on the command line.
+type FlagSetting struct {
+	Flag      string // as given, e.g. --skip-lint
+	Env       string
+	Value     string
+	Overrides string // the environment's value it replaced, if any
+}
+
+// OptionFlags are the command-line flags of the options: --<name> for
+// every option of a binary, plus the aliases. Secrets and variable
+// families such as TOOL_IMAGE_<NAME> have no flag.
+type OptionFlags struct {
+	given []FlagSetting // in command-line order, one per option
+}
+
+// optionFlag is the flag.Value of one option or alias.
+type optionFlag struct {
+	flags  *OptionFlags
+	name   string
+	env    string
+	def    string
+	isBool bool
+	negate bool
+	value  string
+}
+
+func (f *optionFlag) String() string {
+	switch {
+	case f == nil:
+		return ""
+	case f.value == "":
+		return f.def
+	}
+	return f.value
+}
+
+func (f *optionFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *optionFlag) Set(value string) error {
+	if f.negate {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		value = strconv.FormatBool(!b)
+	}
+	f.value = value
+	return f.flags.set("--"+f.name, f.env, value)
+}
+
+// set records an option given on the command line; two flags giving one
+// option different values are an error.
+func (fl *OptionFlags) set(name, env, value string) error {
+	for i, s := range fl.given {
+		if s.Env != env {
+			continue
+		}
+		if s.Value != value {
+			return fmt.Errorf("conflicts with %s, which sets %s=%s", s.Flag, env, s.Value)
+		}
+		fl.given[i].Flag = name
+		return nil
+	}
+	fl.given = append(fl.given, FlagSetting{Flag: name, Env: env, Value: value})
+	return nil
+}
+
+// RegisterOptionFlags defines the option flags of binary on fs, after the
+// binary's own flags, whose names take precedence, and sets its usage to
+// list them with their defaults.
+func RegisterOptionFlags(fs *flag.FlagSet, binary string) *OptionFlags {
+	fl := &OptionFlags{}
+	byEnv := map[string]Option{}
+	for _, o := range Options {
+		if !o.forBinary(binary) || o.prefix() != "" || o.Type == "secret" || o.Flag != "" || fs.Lookup(o.Name()) != nil {
+			continue
+		}
+		byEnv[o.Env] = o
+		fs.Var(&optionFlag{flags: fl, name: o.Name(), env: o.Env, def: o.Default, isBool: o.Type == "bool"}, o.Name(),
+			fmt.Sprintf("%s (%s)", o.Description, o.Env))
+	}
+	for _, a := range flagAliases {
+		if _, ok := byEnv[a.Env]; !ok || fs.Lookup(a.Name) != nil {
+			continue
+		}
+		usage := fmt.Sprintf("same as --%s", byEnv[a.Env].Name())
+		if a.Negate {
+			usage = fmt.Sprintf("same as --%s=false", byEnv[a.Env].Name())
+		}
+		fs.Var(&optionFlag{flags: fl, name: a.Name, env: a.Env, isBool: a.Negate, negate: a.Negate}, a.Name, usage)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] [mode]\n\n", fs.Name())
+		fmt.Fprintln(fs.Output(), "Every option is also an environment variable (see config-schema). A flag overrides")
+		fmt.Fprintln(fs.Output(), "the environment, which overrides PIPELINE_CONFIG, which overrides PIPELINE_PROFILE.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	return fl
+}
+
+// Given are the options given on the command line, in order.
+func (fl *OptionFlags) Given() []FlagSetting {
+	return fl.given
+}
+
+// Values are the options given on the command line by option name, as
+// ResolveOptionSources takes them.
+func (fl *OptionFlags) Values() map[string]string {
+	values := map[string]string{}
+	for _, s := range fl.given {
+		values[Option{Env: s.Env}.Name()] = s.Value
+	}
+	return values
+}
+
+// Apply exports the options given on the command line with setenv,
+// replacing the environment's values, so the binaries parse them like any
+// other variable; each replaced value is recorded for PrintOptionFlags.
+func (fl *OptionFlags) Apply(lookupEnv func(string) (string, bool), setenv func(string, string) error) error {
+	for i, s := range fl.given {
+		if value, ok := lookupEnv(s.Env); ok && value != "" && value != s.Value {
+			fl.given[i].Overrides = value
+		}
+		if err := setenv(s.Env, s.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applied reports whether a flag set env.
+func (fl *OptionFlags) applied(env string) bool {
+	if fl == nil {
+		return false
+	}
+	for _, s := range fl.given {
+		if s.Env == env {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintOptionFlags prints the options given on the command line, and
+// which environment values they won over.
+func PrintOptionFlags(out io.Writer, fl *OptionFlags) {
+	if len(fl.given) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "🏳️  Options set by flags (%d, over the environment and the config file):\n", len(fl.given))
+	for _, s := range fl.given {
+		o, _ := LookupOption(s.Env)
+		line := fmt.Sprintf("   %-28s %s", s.Env, optionDisplayValue(o, s.Value))
+		if s.Overrides != "" {
+			line += fmt.Sprintf(" (%s wins over the environment's %s)", s.Flag, optionDisplayValue(o, s.Overrides))
+		} else {
+			line += fmt.Sprintf(" (%s)", s.Flag)
+		}
+		fmt.Fprintln(out, line)
+	}
+}
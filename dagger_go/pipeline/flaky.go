@@ -0,0 +1,242 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flaky-test history defaults.
+const (
+	TestHistoryFile       = "test-history.json"
+	DefaultFlakyWindow    = 20
+	DefaultFlakyThreshold = 0.2
+	testHistoryVersion    = 1
+)
+
+// TestHistory is the flaky-test store: the per-test outcomes of the last
+// runs, oldest first. On disk (or at the remote endpoint) it is a single
+// JSON document:
+//
+//	{
+//	  "version": 1,
+//	  "runs": [
+//	    {
+//	      "finished_at": "2026-10-17T09:30:00Z",
+//	      "branch": "main",
+//	      "commit": "4f2c9e1",
+//	      "outcomes": {"integration:tests.integration.test_db::test_insert": "failed", ...}
+//	    }
+//	  ]
+//	}
+//
+// Test keys are "<stage>:<junit classname>::<name>"; outcomes are passed,
+// failed, error or skipped. Appending trims the store to the window size.
+type TestHistory struct {
+	Version int       `json:"version"`
+	Runs    []TestRun `json:"runs"`
+}
+
+// TestRun is one pipeline run in the history.
+type TestRun struct {
+	FinishedAt time.Time         `json:"finished_at"`
+	Branch     string            `json:"branch"`
+	Commit     string            `json:"commit,omitempty"`
+	Outcomes   map[string]string `json:"outcomes"`
+}
+
+// FlakyTest is a test that both passed and failed within the window.
+type FlakyTest struct {
+	ID       string  `json:"id"`
+	Runs     int     `json:"runs"`
+	Failures int     `json:"failures"`
+	Rate     float64 `json:"failure_rate"`
+}
+
+// Append adds a run and keeps only the newest window runs.
+func (h *TestHistory) Append(run TestRun, window int) {
+	h.Version = testHistoryVersion
+	h.Runs = append(h.Runs, run)
+	if window > 0 && len(h.Runs) > window {
+		h.Runs = append([]TestRun(nil), h.Runs[len(h.Runs)-window:]...)
+	}
+}
+
+// Flaky lists tests that failed in some runs and passed in others, highest
+// failure rate first. Skipped runs of a test are not counted.
+func (h *TestHistory) Flaky() []FlakyTest {
+	type counts struct{ runs, failures int }
+	stats := map[string]*counts{}
+	for _, run := range h.Runs {
+		for id, outcome := range run.Outcomes {
+			if outcome == OutcomeSkipped {
+				continue
+			}
+			c := stats[id]
+			if c == nil {
+				c = &counts{}
+				stats[id] = c
+			}
+			c.runs++
+			if outcome == OutcomeFailed || outcome == OutcomeError {
+				c.failures++
+			}
+		}
+	}
+	var flaky []FlakyTest
+	for id, c := range stats {
+		if c.failures > 0 && c.failures < c.runs {
+			flaky = append(flaky, FlakyTest{ID: id, Runs: c.runs, Failures: c.failures, Rate: float64(c.failures) / float64(c.runs)})
+		}
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Rate != flaky[j].Rate {
+			return flaky[i].Rate > flaky[j].Rate
+		}
+		return flaky[i].ID < flaky[j].ID
+	})
+	return flaky
+}
+
+// LoadTestHistory reads the store from path; a missing file is an empty history.
+func LoadTestHistory(path string) (*TestHistory, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &TestHistory{Version: testHistoryVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeTestHistory(data)
+}
+
+// SaveTestHistory atomically writes the store to path.
+func SaveTestHistory(path string, h *TestHistory) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+func decodeTestHistory(data []byte) (*TestHistory, error) {
+	var h TestHistory
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid test history: %w", err)
+	}
+	if h.Version > testHistoryVersion {
+		return nil, fmt.Errorf("test history version %d is newer than supported (%d)", h.Version, testHistoryVersion)
+	}
+	return &h, nil
+}
+
+// loadRemoteTestHistory GETs the store from url; 404 is an empty history.
+func loadRemoteTestHistory(ctx context.Context, client *http.Client, url string) (*TestHistory, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return decodeTestHistory(data)
+	case http.StatusNotFound:
+		return &TestHistory{Version: testHistoryVersion}, nil
+	}
+	return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+// saveRemoteTestHistory PUTs the store to url.
+func saveRemoteTestHistory(ctx context.Context, client *http.Client, url string, h *TestHistory) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// recordTestHistory appends this run's test outcomes to the history and
+// reports the flaky candidates. Failures here never fail the run.
+func (p *Pipeline) recordTestHistory(ctx context.Context) {
+	if len(p.outcomes) == 0 {
+		return
+	}
+	cfg := p.cfg
+	var history *TestHistory
+	var err error
+	if cfg.FlakyHistoryURL != "" {
+		history, err = loadRemoteTestHistory(ctx, cfg.HTTPClient, cfg.FlakyHistoryURL)
+	} else {
+		history, err = LoadTestHistory(cfg.FlakyHistoryFile)
+	}
+	if err != nil {
+		p.warnf("test-history", "⚠️  Could not load test history: %v\n", err)
+		return
+	}
+
+	history.Append(TestRun{FinishedAt: time.Now().UTC(), Branch: gitRefName(cfg), Commit: p.commit, Outcomes: p.outcomes}, cfg.FlakyWindow)
+	if cfg.FlakyHistoryURL != "" {
+		err = saveRemoteTestHistory(ctx, cfg.HTTPClient, cfg.FlakyHistoryURL, history)
+	} else {
+		err = SaveTestHistory(cfg.FlakyHistoryFile, history)
+	}
+	if err != nil {
+		p.warnf("test-history", "⚠️  Could not save test history: %v\n", err)
+	}
+
+	flaky := history.Flaky()
+	p.report.FlakyTests = flaky
+	if len(flaky) == 0 {
+		return
+	}
+	var above []FlakyTest
+	for _, f := range flaky {
+		if f.Rate >= cfg.FlakyThreshold {
+			above = append(above, f)
+		}
+	}
+	if len(above) > 0 {
+		p.printf("\n%s\n", strings.Repeat("!", 80))
+		p.warnf("flaky-tests", "⚠️  FLAKY TESTS: %d test(s) at or above FLAKY_THRESHOLD=%.0f%% over the last %d run(s)\n", len(above), cfg.FlakyThreshold*100, len(history.Runs))
+		for _, f := range above {
+			p.printf("   %5.1f%%  %d/%d  %s\n", f.Rate*100, f.Failures, f.Runs, f.ID)
+		}
+		p.println(strings.Repeat("!", 80))
+	}
+	if below := len(flaky) - len(above); below > 0 {
+		p.printf("ℹ️  %d more test(s) failed intermittently below the flaky threshold (see flaky_tests in the report)\n", below)
+	}
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseJUnit tests pytest JUnit XML parsing
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="4">
+  <testcase classname="tests.integration.test_db.TestRepo" name="test_insert" time="0.1"/>
+  <testcase classname="tests.integration.test_db.TestRepo" name="test_update"><failure message="assert 1 == 2">...</failure></testcase>
+  <testcase classname="tests.integration.test_db" name="test_conn"><error message="fixture failed"/></testcase>
+  <testcase classname="tests.integration.test_db" name="test_slow"><skipped message="slow"/></testcase>
+</testsuite></testsuites>`
+	outcomes, err := ParseJUnit([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TestOutcome{
+		{"tests.integration.test_db.TestRepo::test_insert", OutcomePassed},
+		{"tests.integration.test_db.TestRepo::test_update", OutcomeFailed},
+		{"tests.integration.test_db::test_conn", OutcomeError},
+		{"tests.integration.test_db::test_slow", OutcomeSkipped},
+	}
+	if fmt.Sprint(outcomes) != fmt.Sprint(want) {
+		t.Fatalf("got %v", outcomes)
+	}
+	if _, err := ParseJUnit([]byte("<testsuite><testcase")); err == nil {
+		t.Fatal("expected truncated XML to be rejected")
+	}
+	fmt.Println("✅ JUnit XML outcomes parsed")
+}
+
+// TestTestHistoryAppendTrim tests that the store keeps only the newest runs
+func TestTestHistoryAppendTrim(t *testing.T) {
+	h := &TestHistory{}
+	for i := 0; i < 5; i++ {
+		h.Append(TestRun{Commit: fmt.Sprint(i), Outcomes: map[string]string{}}, 3)
+	}
+	if len(h.Runs) != 3 || h.Runs[0].Commit != "2" || h.Runs[2].Commit != "4" || h.Version != testHistoryVersion {
+		t.Fatalf("unexpected history after trim: %+v", h)
+	}
+
+	path := filepath.Join(t.TempDir(), "history", TestHistoryFile)
+	loaded, err := LoadTestHistory(path)
+	if err != nil || len(loaded.Runs) != 0 {
+		t.Fatalf("missing store should load empty: %v %v", loaded, err)
+	}
+	if err := SaveTestHistory(path, h); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadTestHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Append(TestRun{Commit: "5", Outcomes: map[string]string{}}, 3)
+	if len(loaded.Runs) != 3 || loaded.Runs[0].Commit != "3" {
+		t.Fatalf("append after reload did not trim: %+v", loaded.Runs)
+	}
+	fmt.Println("✅ Test history appended and trimmed to the window")
+}
+
+// TestTestHistoryFlaky tests intermittent failure detection and rates
+func TestTestHistoryFlaky(t *testing.T) {
+	h := &TestHistory{}
+	for _, outcomes := range []map[string]string{
+		{"integration:a": OutcomePassed, "integration:b": OutcomeFailed, "unit:c": OutcomePassed},
+		{"integration:a": OutcomeFailed, "integration:b": OutcomeFailed, "unit:c": OutcomePassed},
+		{"integration:a": OutcomePassed, "integration:b": OutcomeFailed, "unit:c": OutcomeError},
+		{"integration:a": OutcomeSkipped, "integration:b": OutcomeFailed, "unit:c": OutcomePassed},
+	} {
+		h.Append(TestRun{FinishedAt: time.Now(), Outcomes: outcomes}, 10)
+	}
+	flaky := h.Flaky()
+	want := []FlakyTest{
+		{ID: "integration:a", Runs: 3, Failures: 1, Rate: 1.0 / 3},
+		{ID: "unit:c", Runs: 4, Failures: 1, Rate: 0.25},
+	}
+	if fmt.Sprint(flaky) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v (always-failing tests are not flaky)", flaky, want)
+	}
+	fmt.Println("✅ Intermittently failing tests reported with failure rates")
+}
+
+// TestRemoteTestHistory tests the GET/PUT remote store
+func TestRemoteTestHistory(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(stored)
+		case http.MethodPut:
+			stored, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	h, err := loadRemoteTestHistory(ctx, server.Client(), server.URL)
+	if err != nil || len(h.Runs) != 0 {
+		t.Fatalf("404 should load an empty history: %v %v", h, err)
+	}
+	h.Append(TestRun{Commit: "abc", Outcomes: map[string]string{"unit:x": OutcomePassed}}, 5)
+	if err := saveRemoteTestHistory(ctx, server.Client(), server.URL, h); err != nil {
+		t.Fatal(err)
+	}
+	h, err = loadRemoteTestHistory(ctx, server.Client(), server.URL)
+	if err != nil || len(h.Runs) != 1 || h.Runs[0].Outcomes["unit:x"] != OutcomePassed {
+		t.Fatalf("round trip failed: %+v %v", h, err)
+	}
+	fmt.Println("✅ Remote test history round-trips")
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultLocalFrameworkDirs are the local packages installed before the
+// project when they exist.
+var DefaultLocalFrameworkDirs = []string{"python_framework"}
+
+// packageMarkers are the files that make a directory pip-installable.
+var packageMarkers = []string{"pyproject.toml", "setup.py"}
+
+// ParseLocalFrameworkDirs parses LOCAL_FRAMEWORK_DIRS: comma-separated
+// directories of the project, installed in the order given. The list
+// replaces DefaultLocalFrameworkDirs; with a leading "+" it extends them,
+// and "none" installs no local package. Unset returns nil, the default.
+func ParseLocalFrameworkDirs(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(value, "none") {
+		return []string{}, nil
+	}
+	var dirs []string
+	if rest, ok := strings.CutPrefix(value, "+"); ok {
+		dirs = append(dirs, DefaultLocalFrameworkDirs...)
+		value = rest
+	}
+	for _, dir := range ParseCommaList(value) {
+		clean := path.Clean(filepath.ToSlash(dir))
+		if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("%q is not a directory inside the project", dir)
+		}
+		dirs = append(dirs, clean)
+	}
+	return dirs, nil
+}
+
+// CheckLocalFramework looks at a local package directory through exists,
+// which reports whether a path relative to the project exists. A missing
+// directory is not installed (false, nil); one without pyproject.toml or
+// setup.py is an error naming them, instead of pip's own.
+func CheckLocalFramework(exists func(string) (bool, error), dir string) (bool, error) {
+	ok, err := exists(dir)
+	if err != nil || !ok {
+		return false, err
+	}
+	for _, marker := range packageMarkers {
+		if ok, err := exists(path.Join(dir, marker)); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, fmt.Errorf("%s/ is not an installable package: %s/%s or %s/%s is missing", dir, dir, packageMarkers[0], dir, packageMarkers[1])
+}
+
+// resolveLocalFrameworks selects the local packages to install before the
+// project. exists looks at the project checkout. A default directory that
+// is missing is skipped with a note; one named in LocalFrameworkDirs must
+// exist.
+func (p *Pipeline) resolveLocalFrameworks(exists func(string) (bool, error)) error {
+	dirs, explicit := DefaultLocalFrameworkDirs, p.cfg.LocalFrameworkDirs != nil
+	if explicit {
+		dirs = p.cfg.LocalFrameworkDirs
+	}
+	p.localPackageDirs = nil
+	for _, dir := range dirs {
+		ok, err := CheckLocalFramework(exists, dir)
+		switch {
+		case err != nil:
+			return Errorf(CategoryConfig, "local framework %v", err)
+		case ok:
+			p.localPackageDirs = append(p.localPackageDirs, dir)
+		case explicit && !slices.Contains(DefaultLocalFrameworkDirs, dir):
+			return Errorf(CategoryConfig, "LOCAL_FRAMEWORK_DIRS names %s/, which does not exist in the project", dir)
+		default:
+			p.printf("   ℹ️  No %s/ in the project — not installing it\n", dir)
+		}
+	}
+	if len(p.localPackageDirs) > 0 {
+		p.printf("   📦 Local packages installed first: %s\n", strings.Join(p.localPackageDirs, ", "))
+	}
+	return nil
+}
+
+// sourceExists is the exists function of resolveLocalFrameworks for a
+// Dagger directory.
+func sourceExists(ctx context.Context, source *dagger.Directory) func(string) (bool, error) {
+	return func(name string) (bool, error) { return source.Exists(ctx, name) }
+}
+
+// hostExists is the exists function of resolveLocalFrameworks for a host
+// directory.
+func hostExists(root string) func(string) (bool, error) {
+	return func(name string) (bool, error) {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+}
+
+// frameworkInstallArgs are the pip -e arguments installing the local
+// packages from the host checkout at root.
+func (p *Pipeline) frameworkInstallArgs(root string) []string {
+	var args []string
+	for _, dir := range p.localPackageDirs {
+		args = append(args, "-e", filepath.Join(root, filepath.FromSlash(dir)))
+	}
+	return args
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseLocalFrameworkDirs tests the override, extend and none forms of LOCAL_FRAMEWORK_DIRS
+func TestParseLocalFrameworkDirs(t *testing.T) {
+	cases := map[string][]string{
+		"":                  nil,
+		"none":              {},
+		"libs/core, shared": {"libs/core", "shared"},
+		"+libs/core":        {"python_framework", "libs/core"},
+		"./shared/":         {"shared"},
+	}
+	for value, want := range cases {
+		got, err := ParseLocalFrameworkDirs(value)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseLocalFrameworkDirs(%q) = %#v, %v; want %#v", value, got, err, want)
+		}
+	}
+	for _, bad := range []string{"/opt/framework", "../sibling", "."} {
+		if _, err := ParseLocalFrameworkDirs(bad); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+	fmt.Println("✅ LOCAL_FRAMEWORK_DIRS parsed")
+}
+
+// TestResolveLocalFrameworks tests that missing default packages are skipped and invalid ones fail
+func TestResolveLocalFrameworks(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"python_framework", "libs/core", "docs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(root, "python_framework", "pyproject.toml"), []byte("[project]\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "libs", "core", "setup.py"), []byte("setup()\n"), 0o644)
+
+	var out bytes.Buffer
+	p := &Pipeline{out: &out, report: &Report{}}
+	if err := p.resolveLocalFrameworks(hostExists(root)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.localPackageDirs, []string{"python_framework"}) {
+		t.Fatalf("default should install python_framework: %v", p.localPackageDirs)
+	}
+	if args := strings.Join(p.frameworkInstallArgs(root), " "); args != "-e "+filepath.Join(root, "python_framework") {
+		t.Fatalf("unexpected host install args: %s", args)
+	}
+
+	p.cfg.LocalFrameworkDirs = []string{"libs/core", "python_framework"}
+	if err := p.resolveLocalFrameworks(hostExists(root)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.localPackageDirs, []string{"libs/core", "python_framework"}) {
+		t.Fatalf("explicit packages should install in the order given: %v", p.localPackageDirs)
+	}
+
+	p.cfg.LocalFrameworkDirs = []string{"docs"}
+	err := p.resolveLocalFrameworks(hostExists(root))
+	if CategoryOf(err) != CategoryConfig || !strings.Contains(err.Error(), "docs/pyproject.toml or docs/setup.py is missing") {
+		t.Fatalf("a directory without pyproject.toml or setup.py should fail naming them: %v", err)
+	}
+	p.cfg.LocalFrameworkDirs = []string{"libs/missing"}
+	if err := p.resolveLocalFrameworks(hostExists(root)); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("an explicit directory that does not exist should fail: %v", err)
+	}
+
+	out.Reset()
+	p.cfg.LocalFrameworkDirs = nil
+	if err := p.resolveLocalFrameworks(hostExists(t.TempDir())); err != nil || len(p.localPackageDirs) != 0 {
+		t.Fatalf("a missing default package should be skipped: %v %v", p.localPackageDirs, err)
+	}
+	if !strings.Contains(out.String(), "No python_framework/ in the project") {
+		t.Fatalf("skipping should print an info line: %s", out.String())
+	}
+	fmt.Println("✅ Local packages detected, ordered and verified before install")
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// FreezeFile is the name of the resolved-dependency list in the artifacts directory.
+const FreezeFile = "requirements-resolved.txt"
+
+// PackageChange is a dependency whose resolved version differs from the
+// baseline. Old is empty for added packages, New for removed ones.
+type PackageChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// DependencySnapshot describes the pip freeze captured by a run.
+type DependencySnapshot struct {
+	File     string            `json:"file"`
+	SHA256   string            `json:"sha256"`
+	Packages int               `json:"packages"`
+	Versions map[string]string `json:"versions,omitempty"` // package → resolved version
+	Baseline string            `json:"baseline,omitempty"`
+	Changes  []PackageChange   `json:"changes,omitempty"`
+}
+
+var packageNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizePackageName applies PEP 503 normalization so "Foo_Bar" and
+// "foo-bar" compare equal.
+func normalizePackageName(name string) string {
+	return packageNameSeparators.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+}
+
+// ParseFreeze maps each package in `pip freeze` output to its version.
+// Direct references ("name @ url") keep the URL as the version; editable
+// installs ("-e …") are keyed by the whole line.
+func ParseFreeze(content string) map[string]string {
+	packages := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "-e "):
+			packages[line] = "editable"
+		case strings.Contains(line, "=="):
+			name, version, _ := strings.Cut(line, "==")
+			packages[normalizePackageName(name)] = strings.TrimSpace(version)
+		case strings.Contains(line, " @ "):
+			name, ref, _ := strings.Cut(line, " @ ")
+			packages[normalizePackageName(name)] = strings.TrimSpace(ref)
+		default:
+			packages[normalizePackageName(line)] = ""
+		}
+	}
+	return packages
+}
+
+// DiffFreeze lists packages added, removed or changed between two freezes,
+// sorted by name.
+func DiffFreeze(baseline, current map[string]string) []PackageChange {
+	var changes []PackageChange
+	for name, version := range current {
+		if old, ok := baseline[name]; !ok || old != version {
+			changes = append(changes, PackageChange{Name: name, Old: old, New: version})
+		}
+	}
+	for name, old := range baseline {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, PackageChange{Name: name, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// PrintFreezeDiff prints one line per changed dependency.
+func PrintFreezeDiff(w io.Writer, baseline string, changes []PackageChange) {
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "   ✅ Resolved dependencies unchanged since %s\n", baseline)
+		return
+	}
+	fmt.Fprintf(w, "   📋 %d dependency change(s) since %s:\n", len(changes), baseline)
+	for _, c := range changes {
+		switch {
+		case c.Old == "":
+			fmt.Fprintf(w, "      + %s %s\n", c.Name, c.New)
+		case c.New == "":
+			fmt.Fprintf(w, "      - %s %s\n", c.Name, c.Old)
+		default:
+			fmt.Fprintf(w, "      ~ %s %s → %s\n", c.Name, c.Old, c.New)
+		}
+	}
+}
+
+// freezeHistoryFile is where the last freeze of a branch is kept between runs.
+func freezeHistoryFile(dir, branch string) string {
+	return filepath.Join(dir, "requirements-"+strings.NewReplacer("/", "_", "\\", "_").Replace(branch)+".txt")
+}
+
+// captureFreeze runs pip freeze in the build container, exports it to the
+// artifacts directory and compares it with the baseline: BaselineFreeze
+// when set, otherwise the branch's previous freeze in FreezeHistoryDir.
+func (p *Pipeline) captureFreeze(ctx context.Context, builder *dagger.Container) error {
+	// First exec that forces the build environment, so apt/pip failures surface here
+	freeze, err := builder.WithExec([]string{"pip", "freeze", "--all"}).Stdout(ctx)
+	if err != nil {
+		if aptErr := aptInstallError(err); aptErr != nil {
+			return aptErr
+		}
+		return Errorf(CategoryBuild, "dependency install failed: %w", err)
+	}
+
+	path := filepath.Join(p.cfg.ArtifactsDir, FreezeFile)
+	if err := os.MkdirAll(p.cfg.ArtifactsDir, 0o755); err != nil {
+		return Errorf(CategoryBuild, "export %s: %w", FreezeFile, err)
+	}
+	if err := WriteFileAtomic(path, []byte(freeze)); err != nil {
+		return Errorf(CategoryBuild, "export %s: %w", FreezeFile, err)
+	}
+	sum := sha256.Sum256([]byte(freeze))
+	current := ParseFreeze(freeze)
+	snapshot := &DependencySnapshot{File: path, SHA256: hex.EncodeToString(sum[:]), Packages: len(current), Versions: current}
+	p.report.Dependencies = snapshot
+	if p.report.Environment != nil {
+		p.report.Environment.FreezeHash = snapshot.SHA256
+	}
+	p.report.Artifacts = append(p.report.Artifacts, Artifact{Name: FreezeFile, Path: path, Size: int64(len(freeze)), SHA256: snapshot.SHA256})
+	p.printf("   📄 Resolved dependencies: %s (%d packages, sha256:%s)\n", path, len(current), snapshot.SHA256[:12])
+
+	baseline := p.cfg.BaselineFreeze
+	history := ""
+	if p.cfg.FreezeHistoryDir != "" {
+		history = freezeHistoryFile(p.cfg.FreezeHistoryDir, gitRefName(p.cfg))
+		if baseline == "" {
+			baseline = history
+		}
+	}
+	if baseline != "" {
+		data, err := os.ReadFile(baseline)
+		switch {
+		case err == nil:
+			snapshot.Baseline = baseline
+			snapshot.Changes = DiffFreeze(ParseFreeze(string(data)), current)
+			PrintFreezeDiff(p.out, baseline, snapshot.Changes)
+		case errors.Is(err, os.ErrNotExist) && baseline == history:
+			p.println("   ℹ️  No previous freeze for this branch — nothing to compare yet")
+		default:
+			p.warnf("baseline-freeze", "   ⚠️  Could not read baseline freeze: %v\n", err)
+		}
+	}
+	if history != "" {
+		err := os.MkdirAll(filepath.Dir(history), 0o755)
+		if err == nil {
+			err = WriteFileAtomic(history, []byte(freeze))
+		}
+		if err != nil {
+			p.warnf("freeze-history", "   ⚠️  Could not record freeze history: %v\n", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseFreeze tests pip freeze parsing and package name normalization
+func TestParseFreeze(t *testing.T) {
+	packages := ParseFreeze(`# generated
+Requests==2.32.3
+typing_extensions==4.12.2
+cert-parser @ file:///app
+
+-e git+https://github.com/org/lib.git@abc#egg=lib
+`)
+	if packages["requests"] != "2.32.3" || packages["typing-extensions"] != "4.12.2" {
+		t.Fatalf("unexpected versions: %v", packages)
+	}
+	if packages["cert-parser"] != "file:///app" {
+		t.Fatalf("direct reference not kept: %q", packages["cert-parser"])
+	}
+	if len(packages) != 4 {
+		t.Fatalf("expected 4 packages, got %d: %v", len(packages), packages)
+	}
+	fmt.Println("✅ pip freeze output parsed")
+}
+
+// TestDiffFreeze tests added, removed and changed package detection
+func TestDiffFreeze(t *testing.T) {
+	baseline := ParseFreeze("requests==2.32.2\nurllib3==2.2.3\nidna==3.10\n")
+	current := ParseFreeze("requests==2.32.3\nidna==3.10\ncertifi==2024.8.30\n")
+
+	changes := DiffFreeze(baseline, current)
+	want := []PackageChange{
+		{Name: "certifi", New: "2024.8.30"},
+		{Name: "requests", Old: "2.32.2", New: "2.32.3"},
+		{Name: "urllib3", Old: "2.2.3"},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", changes, want)
+	}
+
+	var out bytes.Buffer
+	PrintFreezeDiff(&out, "baseline.txt", changes)
+	for _, line := range []string{"+ certifi 2024.8.30", "~ requests 2.32.2 → 2.32.3", "- urllib3 2.2.3"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("diff output missing %q:\n%s", line, out.String())
+		}
+	}
+	if len(DiffFreeze(current, current)) != 0 {
+		t.Fatal("identical freezes should have no changes")
+	}
+	fmt.Println("✅ Dependency version changes detected")
+}
+
+// TestFreezeHistoryFile tests that branch names map to flat file names
+func TestFreezeHistoryFile(t *testing.T) {
+	if got := freezeHistoryFile("/cache", "feature/x"); got != "/cache/requirements-feature_x.txt" {
+		t.Fatalf("unexpected history file %q", got)
+	}
+	fmt.Println("✅ Freeze history stored per branch")
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// gitDependencyTokenEnv holds the token for VCS dependencies inside the
+// build container. It is a Dagger secret variable: never written to a
+// layer, scrubbed from logs, and removed again once pip install is done.
+const gitDependencyTokenEnv = "PIP_GIT_TOKEN"
+
+// GitCredentialHost is a Git host whose HTTPS URLs pip may clone with the
+// dependency token, and the username sent with it.
+type GitCredentialHost struct {
+	Host string // e.g. github.com or gitlab.example.com:8443
+	User string // e.g. x-access-token (GitHub) or oauth2 (GitLab)
+}
+
+// gitCredentialUserPattern is a username the credential helper may send:
+// the helper is a shell function, so anything else is rejected rather
+// than quoted into it.
+var gitCredentialUserPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// validGitCredentialUser checks a username for the credential helper.
+func validGitCredentialUser(user string) error {
+	if !gitCredentialUserPattern.MatchString(user) {
+		return fmt.Errorf("invalid git username %q (use letters, digits, '.', '_' and '-')", user)
+	}
+	return nil
+}
+
+// ParseGitCredentialHosts parses GIT_DEPENDENCY_HOSTS: a space or comma
+// separated list of "host" or "user@host" entries. Entries without a user
+// get defaultUser.
+func ParseGitCredentialHosts(value, defaultUser string) ([]GitCredentialHost, error) {
+	var hosts []GitCredentialHost
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+		user, host, found := strings.Cut(entry, "@")
+		if !found {
+			user, host = defaultUser, entry
+		}
+		if host == "" || user == "" || strings.ContainsAny(host, "/:@") && !isHostPort(host) {
+			return nil, fmt.Errorf("invalid git dependency host %q (use host or user@host, e.g. oauth2@gitlab.example.com)", entry)
+		}
+		if err := validGitCredentialUser(user); err != nil {
+			return nil, fmt.Errorf("git dependency host %q: %w", entry, err)
+		}
+		hosts = append(hosts, GitCredentialHost{Host: strings.ToLower(host), User: user})
+	}
+	return hosts, nil
+}
+
+// isHostPort reports whether s is host:port with a numeric port.
+func isHostPort(s string) bool {
+	host, port, found := strings.Cut(s, ":")
+	if !found || host == "" || strings.ContainsAny(host, "/@") {
+		return false
+	}
+	_, err := strconv.Atoi(port)
+	return err == nil
+}
+
+// GitCredentialEnv is the git configuration, as GIT_CONFIG_* environment
+// variables, that answers credential requests for the given hosts with the
+// token from gitDependencyTokenEnv. Only the variable name appears in the
+// configuration; other hosts are unaffected. The username is shell-quoted
+// into the helper as well as validated where it is configured.
+func GitCredentialEnv(hosts []GitCredentialHost) [][2]string {
+	env := [][2]string{{"GIT_CONFIG_COUNT", strconv.Itoa(len(hosts))}}
+	for i, h := range hosts {
+		helper := fmt.Sprintf(`!f() { test "$1" = get && echo username=%s && echo "password=$%s"; }; f`, shellQuote(h.User), gitDependencyTokenEnv)
+		env = append(env,
+			[2]string{fmt.Sprintf("GIT_CONFIG_KEY_%d", i), fmt.Sprintf("credential.https://%s.helper", h.Host)},
+			[2]string{fmt.Sprintf("GIT_CONFIG_VALUE_%d", i), helper},
+		)
+	}
+	return env
+}
+
+// withGitCredentials lets the pip installs that follow clone private
+// `git+https://` dependencies from the configured hosts. The token is a
+// secret variable; the git configuration only names it.
+func (p *Pipeline) withGitCredentials(client *dagger.Client, container *dagger.Container) *dagger.Container {
+	token := p.cfg.GitDependencyToken
+	if token == "" || len(p.cfg.GitDependencyHosts) == 0 {
+		return container
+	}
+	hosts := make([]string, len(p.cfg.GitDependencyHosts))
+	for i, h := range p.cfg.GitDependencyHosts {
+		hosts[i] = h.User + "@" + h.Host
+	}
+	p.printf("   🔑 Git dependencies: token available to pip for %s\n", strings.Join(hosts, ", "))
+	for _, kv := range GitCredentialEnv(p.cfg.GitDependencyHosts) {
+		container = container.WithEnvVariable(kv[0], kv[1])
+	}
+	return container.
+		WithEnvVariable("GIT_TERMINAL_PROMPT", "0").
+		WithSecretVariable(gitDependencyTokenEnv, client.SetSecret("git-dependency-token", token))
+}
+
+// withoutGitCredentials removes what withGitCredentials added, so the
+// stages that run on the build environment never see the token.
+func (p *Pipeline) withoutGitCredentials(container *dagger.Container) *dagger.Container {
+	if p.cfg.GitDependencyToken == "" || len(p.cfg.GitDependencyHosts) == 0 {
+		return container
+	}
+	for _, kv := range GitCredentialEnv(p.cfg.GitDependencyHosts) {
+		container = container.WithoutEnvVariable(kv[0])
+	}
+	return container.
+		WithoutEnvVariable("GIT_TERMINAL_PROMPT").
+		WithoutSecretVariable(gitDependencyTokenEnv)
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseGitCredentialHosts tests GIT_DEPENDENCY_HOSTS parsing and validation
+func TestParseGitCredentialHosts(t *testing.T) {
+	hosts, err := ParseGitCredentialHosts("github.com, oauth2@GitLab.Example.com git.corp:8443", "x-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GitCredentialHost{
+		{Host: "github.com", User: "x-access-token"},
+		{Host: "gitlab.example.com", User: "oauth2"},
+		{Host: "git.corp:8443", User: "x-access-token"},
+	}
+	if len(hosts) != len(want) {
+		t.Fatalf("got %+v, want %+v", hosts, want)
+	}
+	for i := range want {
+		if hosts[i] != want[i] {
+			t.Fatalf("host %d = %+v, want %+v", i, hosts[i], want[i])
+		}
+	}
+	for _, bad := range []string{"https://github.com", "github.com/ourorg", "@github.com", "a@b@c", "git.corp:port", "o;id@github.com", "$(id)@github.com"} {
+		if _, err := ParseGitCredentialHosts(bad, "x-access-token"); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+	if _, err := ParseGitCredentialHosts("github.com", "x`id`"); err == nil {
+		t.Fatal("a default user with shell syntax should be rejected")
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitDependencyHosts: []GitCredentialHost{{Host: "github.com", User: "a'b"}}}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error for a user set in Config, got %v", err)
+	}
+	if hosts, err := ParseGitCredentialHosts("", "x-access-token"); err != nil || hosts != nil {
+		t.Fatalf("empty value should give no hosts, got %+v, %v", hosts, err)
+	}
+	fmt.Println("✅ Git dependency hosts parsed")
+}
+
+// TestGitCredentialEnv tests that the git configuration names the token variable but never holds a token
+func TestGitCredentialEnv(t *testing.T) {
+	env := GitCredentialEnv([]GitCredentialHost{
+		{Host: "github.com", User: "x-access-token"},
+		{Host: "gitlab.example.com", User: "oauth2"},
+	})
+	values := map[string]string{}
+	for _, kv := range env {
+		values[kv[0]] = kv[1]
+	}
+	if values["GIT_CONFIG_COUNT"] != "2" {
+		t.Fatalf("GIT_CONFIG_COUNT = %q", values["GIT_CONFIG_COUNT"])
+	}
+	if values["GIT_CONFIG_KEY_1"] != "credential.https://gitlab.example.com.helper" {
+		t.Fatalf("GIT_CONFIG_KEY_1 = %q", values["GIT_CONFIG_KEY_1"])
+	}
+	helper := values["GIT_CONFIG_VALUE_0"]
+	if !strings.HasPrefix(helper, "!") || !strings.Contains(helper, "username=x-access-token") || !strings.Contains(helper, "$"+gitDependencyTokenEnv) {
+		t.Fatalf("helper should read the token from %s: %q", gitDependencyTokenEnv, helper)
+	}
+	if helper := GitCredentialEnv([]GitCredentialHost{{Host: "github.com", User: "a b"}})[2][1]; !strings.Contains(helper, "username='a b' &&") {
+		t.Fatalf("the username should be shell-quoted: %q", helper)
+	}
+	fmt.Println("✅ Git credential helper reads the token from a secret variable")
+}
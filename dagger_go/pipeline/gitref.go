@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// commitSHAPattern matches a full commit SHA, which CI systems hand over
+// instead of a branch for detached builds.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// remoteBranchPrefixes are stripped from GIT_BRANCH: the full ref GitHub
+// Actions has in GITHUB_REF and the remote-tracking names Jenkins puts in
+// its GIT_BRANCH.
+var remoteBranchPrefixes = []string{"refs/heads/", "refs/remotes/origin/", "remotes/origin/", "origin/"}
+
+// IsCommitSHA reports whether a normalized GIT_BRANCH names a commit
+// rather than a branch.
+func IsCommitSHA(branch string) bool {
+	return commitSHAPattern.MatchString(branch)
+}
+
+// NormalizeGitBranch turns the branch a CI system hands over into the name
+// the clone expects:
+//
+//	refs/heads/feature/x       → feature/x (GITHUB_REF)
+//	origin/feature/x           → feature/x (Jenkins GIT_BRANCH)
+//	refs/remotes/origin/main   → main
+//	<40 hex digits>            → the SHA in lower case, checked out as a commit
+//
+// A branch literally named origin/... therefore cannot be built. Tags, pull
+// request refs and names git would reject are errors. Empty stays empty.
+func NormalizeGitBranch(value string) (string, error) {
+	branch := strings.TrimSpace(value)
+	if branch == "" {
+		return "", nil
+	}
+	if IsCommitSHA(branch) {
+		return strings.ToLower(branch), nil
+	}
+	switch {
+	case strings.HasPrefix(branch, "refs/tags/"):
+		return "", fmt.Errorf("%q is a tag, not a branch — set GIT_TAG instead", value)
+	case strings.HasPrefix(branch, "refs/pull/"), strings.HasPrefix(branch, "refs/merge-requests/"):
+		return "", fmt.Errorf("%q is a pull request ref — set the pull request's source branch (e.g. github.head_ref) or its commit SHA instead", value)
+	}
+	for _, prefix := range remoteBranchPrefixes {
+		if rest, ok := strings.CutPrefix(branch, prefix); ok {
+			branch = rest
+			break
+		}
+	}
+	if strings.HasPrefix(branch, "refs/") {
+		return "", fmt.Errorf("%q is not a branch ref (refs/heads/...)", value)
+	}
+	if err := checkBranchName(branch); err != nil {
+		return "", fmt.Errorf("%q is not a valid branch name: %v", value, err)
+	}
+	return branch, nil
+}
+
+// NormalizeGitTag turns GIT_TAG into the tag name the clone expects:
+// refs/tags/v1.2.0 becomes v1.2.0. Other refs and names git would reject
+// are errors. Empty stays empty.
+func NormalizeGitTag(value string) (string, error) {
+	tag := strings.TrimSpace(value)
+	if tag == "" {
+		return "", nil
+	}
+	tag = strings.TrimPrefix(tag, "refs/tags/")
+	if strings.HasPrefix(tag, "refs/") {
+		return "", fmt.Errorf("%q is not a tag ref (refs/tags/...)", value)
+	}
+	if err := checkBranchName(tag); err != nil {
+		return "", fmt.Errorf("%q is not a valid tag name: %v", value, err)
+	}
+	return tag, nil
+}
+
+// SelectGitRef picks what a run builds from GIT_BRANCH, GIT_TAG and
+// GIT_COMMIT, of which at most one may be set, and returns it as the
+// GitBranch and GitTag of the Config. A commit becomes GitBranch, which
+// checks out a SHA as a commit; with none set, the branch is main.
+func SelectGitRef(branch, tag, commit string) (gitBranch, gitTag string, err error) {
+	var set []string
+	for _, ref := range [][2]string{{"GIT_BRANCH", branch}, {"GIT_TAG", tag}, {"GIT_COMMIT", commit}} {
+		if strings.TrimSpace(ref[1]) != "" {
+			set = append(set, ref[0]+"="+strings.TrimSpace(ref[1]))
+		}
+	}
+	if len(set) > 1 {
+		return "", "", fmt.Errorf("set only one of GIT_BRANCH, GIT_TAG and GIT_COMMIT, got %s", strings.Join(set, ", "))
+	}
+	switch {
+	case strings.TrimSpace(commit) != "":
+		if !IsCommitSHA(strings.TrimSpace(commit)) {
+			return "", "", fmt.Errorf("GIT_COMMIT %q is not a full 40-character commit SHA", commit)
+		}
+		return strings.ToLower(strings.TrimSpace(commit)), "", nil
+	case strings.TrimSpace(tag) != "":
+		gitTag, err = NormalizeGitTag(tag)
+		if err != nil {
+			return "", "", fmt.Errorf("GIT_TAG %v", err)
+		}
+		return "", gitTag, nil
+	}
+	gitBranch, err = NormalizeGitBranch(branch)
+	if err != nil {
+		return "", "", fmt.Errorf("GIT_BRANCH %v", err)
+	}
+	if gitBranch == "" {
+		gitBranch = mainBranch
+	}
+	return gitBranch, "", nil
+}
+
+// gitRefName is the tag or branch cfg builds. It keys what is kept between
+// runs, such as the last build and the histories.
+func gitRefName(cfg Config) string {
+	if cfg.GitTag != "" {
+		return cfg.GitTag
+	}
+	return cfg.GitBranch
+}
+
+// checkBranchName applies the rules of git check-ref-format to a branch name.
+func checkBranchName(name string) error {
+	switch {
+	case name == "" || name == "@":
+		return fmt.Errorf("empty")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//"):
+		return fmt.Errorf("empty path component")
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("starts with -")
+	case strings.Contains(name, ".."), strings.Contains(name, "@{"):
+		return fmt.Errorf("contains .. or @{")
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock"):
+		return fmt.Errorf("ends with . or .lock")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return fmt.Errorf("component %q starts with .", part)
+		}
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("contains %q", r)
+		}
+	}
+	return nil
+}
+
+// BranchTag is branch as a Docker tag: characters outside [A-Za-z0-9_.-],
+// such as the slashes of feature/x, become "-", a leading "." or "-"
+// becomes "_", and it is cut to the 128 characters a tag may have.
+func BranchTag(branch string) string {
+	tag := []byte(branch)
+	for i, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			tag[i] = '-'
+		}
+	}
+	if len(tag) > 0 && (tag[0] == '.' || tag[0] == '-') {
+		tag[0] = '_'
+	}
+	return string(tag[:min(len(tag), 128)])
+}
+
+// checkout is the ref of the repository the run builds: the tag when
+// GitTag is set, the commit when GitBranch is a SHA, the branch otherwise.
+func (p *Pipeline) checkout(repo *dagger.GitRepository) *dagger.GitRef {
+	if p.cfg.GitTag != "" {
+		return repo.Tag(p.cfg.GitTag)
+	}
+	if IsCommitSHA(p.cfg.GitBranch) {
+		return repo.Commit(p.cfg.GitBranch)
+	}
+	return repo.Branch(p.cfg.GitBranch)
+}
+
+// checkoutKind is "tag", "commit" or "branch", for the log.
+func (p *Pipeline) checkoutKind() string {
+	if p.cfg.GitTag != "" {
+		return "tag"
+	}
+	if IsCommitSHA(p.cfg.GitBranch) {
+		return "commit"
+	}
+	return "branch"
+}
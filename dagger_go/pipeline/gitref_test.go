@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeGitBranch tests the branch forms GitHub Actions and Jenkins hand over
+func TestNormalizeGitBranch(t *testing.T) {
+	sha := "0123456789abcdef0123456789ABCDEF01234567"
+	cases := map[string]string{
+		"":                               "",
+		"main":                           "main",
+		"  feature/x\n":                  "feature/x",
+		"refs/heads/feature/x":           "feature/x",   // GitHub Actions GITHUB_REF
+		"refs/heads/main":                "main",        // GitHub Actions push to main
+		"origin/feature/x":               "feature/x",   // Jenkins GIT_BRANCH
+		"remotes/origin/release/1.2":     "release/1.2", // git branch -a
+		"refs/remotes/origin/main":       "main",        // Jenkins with full refspecs
+		"dependabot/pip/requests-2.32.3": "dependabot/pip/requests-2.32.3",
+		"PR-42":                          "PR-42",              // Jenkins multibranch BRANCH_NAME
+		sha:                              strings.ToLower(sha), // detached builds
+	}
+	for in, want := range cases {
+		got, err := NormalizeGitBranch(in)
+		if err != nil || got != want {
+			t.Fatalf("NormalizeGitBranch(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if !IsCommitSHA(strings.ToLower(sha)) || IsCommitSHA("main") || IsCommitSHA(sha[:12]) {
+		t.Fatal("only a full SHA is a commit")
+	}
+	for in, want := range map[string]string{
+		"refs/tags/v1.2.0":           "is a tag",
+		"refs/pull/42/merge":         "pull request ref",
+		"refs/merge-requests/7/head": "pull request ref",
+		"refs/notes/commits":         "not a branch ref",
+		"feature x":                  "not a valid branch name",
+		"feature..x":                 "not a valid branch name",
+		"feature/x.lock":             "not a valid branch name",
+		"origin/":                    "not a valid branch name",
+		"-rf":                        "not a valid branch name",
+	} {
+		if _, err := NormalizeGitBranch(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("NormalizeGitBranch(%q) = %v, want an error containing %q", in, err, want)
+		}
+	}
+	fmt.Println("✅ GIT_BRANCH normalized from refs, remote names and SHAs")
+}
+
+// TestNewNormalizesGitBranch tests that the pipeline builds the normalized branch and rejects tags
+func TestNewNormalizesGitBranch(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitBranch: "refs/heads/feature/x"})
+	if err != nil || p.cfg.GitBranch != "feature/x" || p.checkoutKind() != "branch" {
+		t.Fatalf("got %v, %v", err, p)
+	}
+	_, err = New(Config{RepoName: "cert-parser", GitUser: "org", GitBranch: "refs/tags/v1"})
+	if err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a tag should be a configuration error, got %v", err)
+	}
+	fmt.Println("✅ Pipeline builds the normalized GIT_BRANCH")
+}
+
+// TestSelectGitRef tests choosing between GIT_BRANCH, GIT_TAG and GIT_COMMIT
+func TestSelectGitRef(t *testing.T) {
+	sha := "0123456789abcdef0123456789ABCDEF01234567"
+	for _, c := range []struct{ branch, tag, commit, wantBranch, wantTag string }{
+		{"", "", "", "main", ""},
+		{"origin/feature/x", "", "", "feature/x", ""},
+		{"", "refs/tags/v1.2.0", "", "", "v1.2.0"},
+		{"", "", sha, strings.ToLower(sha), ""},
+	} {
+		branch, tag, err := SelectGitRef(c.branch, c.tag, c.commit)
+		if err != nil || branch != c.wantBranch || tag != c.wantTag {
+			t.Fatalf("SelectGitRef(%q, %q, %q) = %q, %q, %v", c.branch, c.tag, c.commit, branch, tag, err)
+		}
+	}
+	for _, c := range [][3]string{
+		{"main", "v1.2.0", ""},
+		{"", "v1.2.0", sha},
+		{"main", "", sha},
+	} {
+		if _, _, err := SelectGitRef(c[0], c[1], c[2]); err == nil || !strings.Contains(err.Error(), "set only one of") {
+			t.Fatalf("SelectGitRef(%q) should reject more than one ref, got %v", c, err)
+		}
+	}
+	if _, _, err := SelectGitRef("", "", sha[:12]); err == nil {
+		t.Fatal("a short SHA should be rejected")
+	}
+	if _, _, err := SelectGitRef("", "refs/heads/main", ""); err == nil {
+		t.Fatal("a branch ref should not be a tag")
+	}
+
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitTag: "refs/tags/v1.2.0"})
+	if err != nil || p.cfg.GitTag != "v1.2.0" || p.cfg.GitBranch != "" || p.checkoutKind() != "tag" || gitRefName(p.cfg) != "v1.2.0" {
+		t.Fatalf("got %v, %+v", err, p)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitTag: "v1.2.0", GitBranch: "main"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a tag and a branch should be a configuration error, got %v", err)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitTag: "v1.2.0", ReleaseVersion: "1.3.0", GitToken: "t"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a release from a tag should be a configuration error, got %v", err)
+	}
+	fmt.Println("✅ One of GIT_BRANCH, GIT_TAG and GIT_COMMIT selects the ref")
+}
+
+// TestBranchTag tests turning branch names into valid Docker tags
+func TestBranchTag(t *testing.T) {
+	for in, want := range map[string]string{
+		"main":                   "main",
+		"feature/x":              "feature-x",
+		"release/1.2":            "release-1.2",
+		".hidden":                "_hidden",
+		"user@fix#12":            "user-fix-12",
+		strings.Repeat("a", 200): strings.Repeat("a", 128),
+	} {
+		if got := BranchTag(in); got != want {
+			t.Fatalf("BranchTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+	fmt.Println("✅ Branch names made valid Docker tags")
+}
@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// Dockle finding levels, from most to least severe.
+const (
+	DockleFatal = "FATAL"
+	DockleWarn  = "WARN"
+	DockleInfo  = "INFO"
+)
+
+// HardeningReport is the result of the post-build image check.
+type HardeningReport struct {
+	User         string        `json:"user"`
+	Root         bool          `json:"root"`
+	ExposedPorts []string      `json:"exposed_ports,omitempty"`
+	Entrypoint   []string      `json:"entrypoint,omitempty"`
+	Cmd          []string      `json:"cmd,omitempty"`
+	Workdir      string        `json:"workdir,omitempty"`
+	Dockle       *DockleResult `json:"dockle,omitempty"`
+	Passed       bool          `json:"passed"`
+	Problems     []string      `json:"problems,omitempty"`
+	Warnings     []string      `json:"warnings,omitempty"` // EntrypointCheck findings in warn mode
+}
+
+// DockleResult summarizes a dockle (CIS Docker benchmark) scan.
+type DockleResult struct {
+	FailLevel string          `json:"fail_level"`
+	Fatal     int             `json:"fatal"`
+	Warn      int             `json:"warn"`
+	Info      int             `json:"info"`
+	Pass      int             `json:"pass"`
+	Findings  []DockleFinding `json:"findings,omitempty"`
+}
+
+// DockleFinding is one failed dockle checkpoint.
+type DockleFinding struct {
+	Code   string   `json:"code"`
+	Title  string   `json:"title"`
+	Level  string   `json:"level"`
+	Alerts []string `json:"alerts,omitempty"`
+}
+
+// IsRootUser reports whether an image USER value runs as root. An empty
+// user means the image never switched away from root.
+func IsRootUser(user string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(user), ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+// dockleLevelRank orders levels so a threshold can be compared; unknown
+// levels (SKIP, PASS) rank 0 and never fail a run.
+func dockleLevelRank(level string) int {
+	switch strings.ToUpper(level) {
+	case DockleFatal:
+		return 3
+	case DockleWarn:
+		return 2
+	case DockleInfo:
+		return 1
+	}
+	return 0
+}
+
+// ValidDockleLevel reports whether level can be used as DOCKLE_FAIL_LEVEL.
+func ValidDockleLevel(level string) bool {
+	return dockleLevelRank(level) > 0
+}
+
+// ParseDockleOutput parses `dockle --format json` output.
+func ParseDockleOutput(data []byte) (DockleResult, error) {
+	var raw struct {
+		Summary struct {
+			Fatal int `json:"fatal"`
+			Warn  int `json:"warn"`
+			Info  int `json:"info"`
+			Pass  int `json:"pass"`
+		} `json:"summary"`
+		Details []DockleFinding `json:"details"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return DockleResult{}, fmt.Errorf("invalid dockle output: %w", err)
+	}
+	return DockleResult{
+		Fatal:    raw.Summary.Fatal,
+		Warn:     raw.Summary.Warn,
+		Info:     raw.Summary.Info,
+		Pass:     raw.Summary.Pass,
+		Findings: raw.Details,
+	}, nil
+}
+
+// Failing returns the findings at or above the result's fail level.
+func (d DockleResult) Failing() []DockleFinding {
+	threshold := dockleLevelRank(d.FailLevel)
+	var failing []DockleFinding
+	for _, f := range d.Findings {
+		if rank := dockleLevelRank(f.Level); rank > 0 && rank >= threshold {
+			failing = append(failing, f)
+		}
+	}
+	return failing
+}
+
+// checkImageHardening inspects the built image config and optionally runs
+// dockle against it. The returned report is filled in even when err is set.
+func (p *Pipeline) checkImageHardening(ctx context.Context, client *dagger.Client, image *dagger.Container) (*HardeningReport, error) {
+	h := &HardeningReport{}
+	var err error
+	if h.User, err = image.User(ctx); err != nil {
+		return h, Errorf(CategoryBuild, "inspect image user: %w", err)
+	}
+	h.Root = IsRootUser(h.User)
+	if h.Entrypoint, err = image.Entrypoint(ctx); err != nil {
+		return h, Errorf(CategoryBuild, "inspect image entrypoint: %w", err)
+	}
+	if h.Cmd, err = image.DefaultArgs(ctx); err != nil {
+		return h, Errorf(CategoryBuild, "inspect image command: %w", err)
+	}
+	if h.Workdir, err = image.Workdir(ctx); err != nil {
+		return h, Errorf(CategoryBuild, "inspect image workdir: %w", err)
+	}
+	ports, err := image.ExposedPorts(ctx)
+	if err != nil {
+		return h, Errorf(CategoryBuild, "inspect exposed ports: %w", err)
+	}
+	for _, port := range ports {
+		number, err := port.Port(ctx)
+		if err != nil {
+			return h, Errorf(CategoryBuild, "inspect exposed ports: %w", err)
+		}
+		protocol, err := port.Protocol(ctx)
+		if err != nil {
+			return h, Errorf(CategoryBuild, "inspect exposed ports: %w", err)
+		}
+		h.ExposedPorts = append(h.ExposedPorts, fmt.Sprintf("%d/%s", number, strings.ToLower(string(protocol))))
+	}
+
+	user := h.User
+	if user == "" {
+		user = "(unset — root)"
+	}
+	p.printf("   User:        %s\n", user)
+	p.printf("   Entrypoint:  %s\n", strings.Join(h.Entrypoint, " "))
+	p.printf("   Command:     %s\n", strings.Join(h.Cmd, " "))
+	p.printf("   Workdir:     %s\n", h.Workdir)
+	p.printf("   Ports:       %s\n", strings.Join(h.ExposedPorts, ", "))
+
+	if h.Root {
+		if p.cfg.AllowRootImage {
+			p.warnf("root-image", "   ⚠️  Image runs as root (allowed by ALLOW_ROOT_IMAGE=true)\n")
+		} else {
+			h.Problems = append(h.Problems, "image runs as root — PodSecurity admission will reject it (set USER in the Dockerfile, or ALLOW_ROOT_IMAGE=true)")
+		}
+	}
+	if len(h.Entrypoint) == 0 && len(h.Cmd) == 0 {
+		h.Problems = append(h.Problems, "image has neither ENTRYPOINT nor CMD")
+	}
+	if p.cfg.EntrypointCheck != EntrypointCheckOff {
+		if len(p.scripts) == 0 {
+			p.println("   ℹ️  No [project.scripts] in pyproject.toml — entrypoint not checked against scripts")
+		}
+		for _, problem := range entrypointProblems(h.Entrypoint, h.Cmd, h.Workdir, p.cfg.ImageWorkdir, p.scripts) {
+			if p.cfg.EntrypointCheck == EntrypointCheckFail {
+				h.Problems = append(h.Problems, problem)
+				continue
+			}
+			h.Warnings = append(h.Warnings, problem)
+			p.warnf("entrypoint", "   ⚠️  %s (ENTRYPOINT_CHECK=warn)\n", problem)
+		}
+	}
+
+	if p.cfg.RunDockle {
+		p.printf("🔍 Running dockle (fail level: %s)...\n", p.cfg.DockleFailLevel)
+		dockle, err := p.ToolContainer(ctx, client, "dockle")
+		if err != nil {
+			return h, Errorf(CategoryBuild, "dockle: %w", err)
+		}
+		output, err := dockle.
+			WithMountedFile("/image.tar", image.AsTarball()).
+			WithExec([]string{"dockle", "--input", "/image.tar", "--format", "json", "--exit-code", "0"}).
+			Stdout(ctx)
+		if err != nil {
+			return h, Errorf(CategoryBuild, "dockle: %w", err)
+		}
+		result, err := ParseDockleOutput([]byte(output))
+		if err != nil {
+			return h, Errorf(CategoryBuild, "dockle: %w", err)
+		}
+		result.FailLevel = p.cfg.DockleFailLevel
+		h.Dockle = &result
+		p.printf("   dockle: %d fatal, %d warn, %d info, %d pass\n", result.Fatal, result.Warn, result.Info, result.Pass)
+		for _, f := range result.Failing() {
+			p.printf("   ✗ %s %s — %s\n", f.Level, f.Code, f.Title)
+			h.Problems = append(h.Problems, fmt.Sprintf("dockle %s %s: %s", f.Level, f.Code, f.Title))
+		}
+	}
+
+	h.Passed = len(h.Problems) == 0
+	if !h.Passed {
+		return h, Errorf(CategoryBuild, "image hardening check failed: %s", strings.Join(h.Problems, "; "))
+	}
+	return h, nil
+}
+
+// HardeningMarkdown renders the check for a CI job summary.
+func HardeningMarkdown(h *HardeningReport) string {
+	var b strings.Builder
+	status := "✅ passed"
+	if !h.Passed {
+		status = "❌ failed"
+	}
+	user := h.User
+	if user == "" {
+		user = "(unset — root)"
+	}
+	fmt.Fprintf(&b, "### Image hardening: %s\n\n", status)
+	fmt.Fprintf(&b, "| Check | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| User | `%s` |\n", user)
+	fmt.Fprintf(&b, "| Entrypoint | `%s` |\n", strings.Join(h.Entrypoint, " "))
+	fmt.Fprintf(&b, "| Command | `%s` |\n", strings.Join(h.Cmd, " "))
+	fmt.Fprintf(&b, "| Workdir | `%s` |\n", h.Workdir)
+	fmt.Fprintf(&b, "| Exposed ports | %s |\n", strings.Join(h.ExposedPorts, ", "))
+	if h.Dockle != nil {
+		fmt.Fprintf(&b, "| dockle (fail ≥ %s) | %d fatal, %d warn, %d info |\n", h.Dockle.FailLevel, h.Dockle.Fatal, h.Dockle.Warn, h.Dockle.Info)
+	}
+	if len(h.Problems) > 0 {
+		b.WriteString("\n")
+		for _, problem := range h.Problems {
+			fmt.Fprintf(&b, "- %s\n", problem)
+		}
+	}
+	for _, warning := range h.Warnings {
+		fmt.Fprintf(&b, "- ⚠️ %s\n", warning)
+	}
+	return b.String()
+}
+
+// AppendStepSummary appends markdown to a CI job summary file such as
+// $GITHUB_STEP_SUMMARY.
+func AppendStepSummary(path, markdown string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(markdown + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIsRootUser tests root detection for image USER values
+func TestIsRootUser(t *testing.T) {
+	for _, user := range []string{"", "root", "0", "0:0", "root:root", " root "} {
+		if !IsRootUser(user) {
+			t.Fatalf("IsRootUser(%q) = false, want true", user)
+		}
+	}
+	for _, user := range []string{"certparser", "1000", "1000:1000", "nobody:0"} {
+		if IsRootUser(user) {
+			t.Fatalf("IsRootUser(%q) = true, want false", user)
+		}
+	}
+	fmt.Println("✅ Root and unset image users detected")
+}
+
+// TestParseDockleOutput tests dockle JSON parsing and the failure threshold
+func TestParseDockleOutput(t *testing.T) {
+	output := `{
+  "image": "/image.tar",
+  "summary": {"fatal": 1, "warn": 1, "info": 2, "skip": 0, "pass": 12},
+  "details": [
+    {"code": "CIS-DI-0010", "title": "Do not store credential in environment variables/files", "level": "FATAL", "alerts": ["Suspicious ENV key found : AWS_SECRET"]},
+    {"code": "CIS-DI-0001", "title": "Create a user for the container", "level": "WARN", "alerts": ["Last user should not be root"]},
+    {"code": "DKL-LI-0003", "title": "Only put necessary files", "level": "INFO"},
+    {"code": "CIS-DI-0005", "title": "Enable Content trust for Docker", "level": "INFO"}
+  ]
+}`
+	result, err := ParseDockleOutput([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fatal != 1 || result.Warn != 1 || result.Info != 2 || result.Pass != 12 || len(result.Findings) != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	for level, want := range map[string]int{DockleFatal: 1, DockleWarn: 2, DockleInfo: 4} {
+		result.FailLevel = level
+		if got := len(result.Failing()); got != want {
+			t.Fatalf("fail level %s: %d failing findings, want %d", level, got, want)
+		}
+	}
+	if _, err := ParseDockleOutput([]byte("not json")); err == nil {
+		t.Fatal("expected invalid output to be rejected")
+	}
+	fmt.Println("✅ dockle findings parsed and filtered by fail level")
+}
+
+// TestNewValidatesDockleFailLevel tests DockleFailLevel defaults and validation
+func TestNewValidatesDockleFailLevel(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", DockleFailLevel: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Config().DockleFailLevel != DockleWarn {
+		t.Fatalf("level not normalized: %q", p.Config().DockleFailLevel)
+	}
+	if p, _ := New(Config{RepoName: "cert-parser", GitUser: "org"}); p.Config().DockleFailLevel != DockleFatal {
+		t.Fatal("default fail level should be FATAL")
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", DockleFailLevel: "SKIP"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected config error, got %v", err)
+	}
+	fmt.Println("✅ dockle fail level validated")
+}
+
+// TestHardeningStepSummary tests the job summary markdown and file append
+func TestHardeningStepSummary(t *testing.T) {
+	h := &HardeningReport{
+		User:         "",
+		Root:         true,
+		ExposedPorts: []string{"8000/tcp"},
+		Entrypoint:   []string{"python", "-m", "uvicorn"},
+		Dockle:       &DockleResult{FailLevel: DockleFatal, Warn: 1},
+		Problems:     []string{"image runs as root"},
+	}
+	md := HardeningMarkdown(h)
+	for _, want := range []string{"❌ failed", "(unset — root)", "8000/tcp", "python -m uvicorn", "- image runs as root"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("summary missing %q:\n%s", want, md)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "summary.md")
+	for i := 0; i < 2; i++ {
+		if err := AppendStepSummary(path, md); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "### Image hardening") != 2 {
+		t.Fatal("summary should be appended, not overwritten")
+	}
+	fmt.Println("✅ Hardening results rendered into the job summary")
+}
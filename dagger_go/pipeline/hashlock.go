@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultHashLockFile is the lockfile HashCheckedInstall installs the
+// dependencies from, relative to the project.
+const DefaultHashLockFile = "requirements.lock"
+
+// hashLockHint tells how to generate a lockfile --require-hashes accepts.
+const hashLockHint = `Generate it from pyproject.toml with hashes and commit it:
+      pip-compile --generate-hashes --extra dev --extra server -o requirements.lock pyproject.toml
+   or
+      uv pip compile --generate-hashes --extra dev --extra server -o requirements.lock pyproject.toml
+   Leave the local packages out: they are installed editable after it, without hashes.`
+
+// requirementName matches the package name at the start of a requirement.
+var requirementName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+// HashLock is a lockfile read for HashCheckedInstall, with the line of each
+// package to point at when pip rejects one.
+type HashLock struct {
+	Path     string
+	Packages int
+	Lines    map[string]int // normalized package name → line
+}
+
+// HashLockProblem is a lockfile line pip would reject in --require-hashes
+// mode.
+type HashLockProblem struct {
+	Line        int
+	Requirement string
+	Reason      string
+}
+
+// HashInstallFailure is a package pip rejected while installing the
+// lockfile.
+type HashInstallFailure struct {
+	Package string `json:"package"`
+	Line    int    `json:"line,omitempty"` // in the lockfile; 0 when it is not there
+	Reason  string `json:"reason"`
+}
+
+// HashCheckedInstall records the hash-checked install of the dependencies.
+type HashCheckedInstall struct {
+	Lockfile string               `json:"lockfile"`
+	Packages int                  `json:"packages"`
+	Failures []HashInstallFailure `json:"failures,omitempty"`
+}
+
+// ParseHashLock reads a requirements file for a hash-checked install. Every
+// requirement must be pinned with == and carry a --hash; editable and
+// unnamed (path or URL) requirements cannot be hash-checked. Options such
+// as --index-url are left to pip.
+func ParseHashLock(path string, content []byte) (*HashLock, []HashLockProblem) {
+	lock := &HashLock{Path: path, Lines: map[string]int{}}
+	var problems []HashLockProblem
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		start, line := i+1, strings.TrimSpace(lines[i])
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			line = strings.TrimSpace(strings.TrimSuffix(line, `\`)) + " " + strings.TrimSpace(lines[i])
+		}
+		if idx := strings.Index(line, "#"); idx == 0 || idx > 0 && (line[idx-1] == ' ' || line[idx-1] == '\t') {
+			line = strings.TrimSpace(line[:idx])
+		}
+		requirement, _, _ := strings.Cut(line, " --hash")
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "-e ") || strings.HasPrefix(line, "--editable"):
+			problems = append(problems, HashLockProblem{start, line, "editable installs cannot be hash-checked"})
+			continue
+		case strings.HasPrefix(line, "-"):
+			continue
+		}
+		name := requirementName.FindString(line)
+		switch {
+		case name == "" || strings.Contains(requirement, "://") || strings.Contains(requirement, " @ "):
+			problems = append(problems, HashLockProblem{start, requirement, "a path or URL, not a pinned package"})
+		case !strings.Contains(requirement, "=="):
+			problems = append(problems, HashLockProblem{start, requirement, "not pinned with =="})
+		case !strings.Contains(line, "--hash="):
+			problems = append(problems, HashLockProblem{start, requirement, "has no --hash"})
+		}
+		if name != "" {
+			lock.Lines[normalizePackageName(name)] = start
+			lock.Packages++
+		}
+	}
+	return lock, problems
+}
+
+// ParseHashInstallFailures finds the packages pip rejected in the output of
+// pip install --require-hashes: hash mismatches, missing hashes and
+// requirements not pinned with ==, each with its line in lock.
+func ParseHashInstallFailures(output string, lock *HashLock) []HashInstallFailure {
+	var failures []HashInstallFailure
+	reason := ""
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, "DO NOT MATCH THE HASHES"):
+			reason = "hash mismatch"
+		case strings.Contains(line, "Hashes are required in --require-hashes mode"):
+			reason = "missing hash"
+		case strings.Contains(line, "must have their versions pinned with =="):
+			reason = "not pinned with =="
+		case reason == "" || trimmed == "":
+		case !strings.HasPrefix(line, " "):
+			reason = ""
+		case strings.HasPrefix(trimmed, "Expected ") && len(failures) > 0:
+			failures[len(failures)-1].Reason += ": expected " + strings.TrimPrefix(trimmed, "Expected ")
+		case strings.HasPrefix(trimmed, "Got ") && len(failures) > 0:
+			failures[len(failures)-1].Reason += ", got " + strings.TrimSpace(strings.TrimPrefix(trimmed, "Got "))
+		default:
+			name := requirementName.FindString(trimmed)
+			if name == "" {
+				continue
+			}
+			failure := HashInstallFailure{Package: name, Reason: reason}
+			if lock != nil {
+				failure.Line = lock.Lines[normalizePackageName(name)]
+			}
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// readHashLock reads and checks the lockfile of HashCheckedInstall before
+// the build environment is set up. A missing lockfile, or lines pip would
+// reject, stop the run with what to fix.
+func (p *Pipeline) readHashLock(ctx context.Context, source *dagger.Directory) error {
+	cfg := p.cfg
+	if !cfg.HashCheckedInstall {
+		return nil
+	}
+	exists, err := source.Exists(ctx, cfg.HashLockFile)
+	if err != nil {
+		return Errorf(CategoryClone, "checking for %s: %w", cfg.HashLockFile, err)
+	}
+	if !exists {
+		p.printf("\n❌ HASH_CHECKED_INSTALL=true, but the project has no %s\n   %s\n", cfg.HashLockFile, hashLockHint)
+		return Errorf(CategoryConfig, "HASH_CHECKED_INSTALL needs %s in the project", cfg.HashLockFile)
+	}
+	content, err := source.File(cfg.HashLockFile).Contents(ctx)
+	if err != nil {
+		return Errorf(CategoryClone, "reading %s: %w", cfg.HashLockFile, err)
+	}
+	lock, problems := ParseHashLock(cfg.HashLockFile, []byte(content))
+	if len(problems) > 0 {
+		p.printf("\n❌ %s cannot be installed with --require-hashes:\n", cfg.HashLockFile)
+		for _, problem := range problems {
+			p.printf("   %s:%d: %s — %s\n", cfg.HashLockFile, problem.Line, problem.Requirement, problem.Reason)
+		}
+		p.printf("   %s\n", hashLockHint)
+		return Errorf(CategoryConfig, "%s: %d line(s) cannot be hash-checked, first %s:%d: %s", cfg.HashLockFile, len(problems), cfg.HashLockFile, problems[0].Line, problems[0].Reason)
+	}
+	p.hashLock = lock
+	p.report.HashCheckedInstall = &HashCheckedInstall{Lockfile: lock.Path, Packages: lock.Packages}
+	p.printf("   🔒 Hash-checked install: %d package(s) from %s (HASH_CHECKED_INSTALL)\n", lock.Packages, lock.Path)
+	return nil
+}
+
+// verifyHashedInstall checks the lockfile install of the build environment
+// before anything depends on it, and lists every package pip rejected with
+// its lockfile line.
+func (p *Pipeline) verifyHashedInstall(ctx context.Context) error {
+	if p.hashInstall == nil {
+		return nil
+	}
+	stdout, err := p.hashInstall.Stdout(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "pip install --require-hashes -r %s: %w", p.hashLock.Path, err)
+	}
+	stderr, _ := p.hashInstall.Stderr(ctx)
+	code, err := p.hashInstall.ExitCode(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "pip install --require-hashes -r %s: %w", p.hashLock.Path, err)
+	}
+	if code == 0 {
+		p.printf("   🔒 %d package(s) installed from %s with verified hashes\n", p.hashLock.Packages, p.hashLock.Path)
+		return nil
+	}
+
+	output := strings.TrimSpace(stdout + "\n" + stderr)
+	failures := ParseHashInstallFailures(output, p.hashLock)
+	p.report.HashCheckedInstall.Failures = failures
+	if len(failures) == 0 {
+		p.println(output)
+		return Errorf(CategoryBuild, "pip install --require-hashes -r %s failed with exit code %d", p.hashLock.Path, code)
+	}
+	p.printf("\n❌ %d package(s) failed the hash check of %s:\n", len(failures), p.hashLock.Path)
+	for _, f := range failures {
+		where := fmt.Sprintf("%s:%d", p.hashLock.Path, f.Line)
+		if f.Line == 0 {
+			where = "not in " + p.hashLock.Path
+		}
+		p.printf("   %s: %s — %s\n", where, f.Package, f.Reason)
+	}
+	p.println("   Regenerate the lockfile if the versions were updated; otherwise check the package index — the files may have been tampered with")
+	return Errorf(CategoryBuild, "%s failed the hash check (%s)", failures[0].Package, failures[0].Reason)
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseHashLock tests the lockfile lines --require-hashes would reject
+func TestParseHashLock(t *testing.T) {
+	content := "# generated by pip-compile --generate-hashes\n" +
+		"--index-url https://pypi.corp.local/simple\n" +
+		"\n" +
+		"Cryptography==42.0.5 \\\n" +
+		"    --hash=sha256:aaaa \\\n" +
+		"    --hash=sha256:bbbb\n" +
+		"    # via cert-parser\n" +
+		"idna==3.6 --hash=sha256:cccc ; python_version >= \"3.8\"\n" +
+		"requests>=2.31 --hash=sha256:dddd\n" +
+		"urllib3==2.2.1\n" +
+		"-e ./railway_framework\n" +
+		"asn1crypto @ https://example.com/asn1crypto-1.5.1.tar.gz --hash=sha256:eeee\n"
+	lock, problems := ParseHashLock("requirements.lock", []byte(content))
+	if lock.Lines["cryptography"] != 4 || lock.Lines["idna"] != 8 || lock.Packages != 5 {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+	var got []string
+	for _, problem := range problems {
+		got = append(got, fmt.Sprintf("%d: %s — %s", problem.Line, problem.Requirement, problem.Reason))
+	}
+	want := []string{
+		"9: requests>=2.31 — not pinned with ==",
+		"10: urllib3==2.2.1 — has no --hash",
+		"11: -e ./railway_framework — editable installs cannot be hash-checked",
+		"12: asn1crypto @ https://example.com/asn1crypto-1.5.1.tar.gz — a path or URL, not a pinned package",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected problems:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, problems := ParseHashLock("requirements.lock", []byte("idna==3.6 \\\r\n    --hash=sha256:cccc\r\n")); len(problems) != 0 {
+		t.Fatalf("a clean lockfile should have no problems, got %+v", problems)
+	}
+	fmt.Println("✅ Lockfile lines checked for pins and hashes")
+}
+
+// TestParseHashInstallFailures tests pointing pip's hash errors at lockfile lines
+func TestParseHashInstallFailures(t *testing.T) {
+	lock, _ := ParseHashLock("requirements.lock", []byte("cryptography==42.0.5 --hash=sha256:aaaa\nidna==3.6 --hash=sha256:cccc\n"))
+	output := `Collecting cryptography==42.0.5
+ERROR: THESE PACKAGES DO NOT MATCH THE HASHES FROM THE REQUIREMENTS FILE. If you have updated the package versions, please update the hashes. Otherwise, examine the package contents carefully; someone may have tampered with them.
+    cryptography==42.0.5 from https://files.pythonhosted.org/packages/cryptography-42.0.5-cp39-abi3-manylinux_2_28_x86_64.whl:
+        Expected sha256 aaaa
+             Got        ffff
+
+ERROR: Hashes are required in --require-hashes mode, but they are missing from some requirements.
+    python_dateutil==2.9.0 --hash=sha256:9999
+`
+	failures := ParseHashInstallFailures(output, lock)
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %+v", failures)
+	}
+	if f := failures[0]; f.Package != "cryptography" || f.Line != 1 || f.Reason != "hash mismatch: expected sha256 aaaa, got ffff" {
+		t.Fatalf("unexpected mismatch %+v", f)
+	}
+	if f := failures[1]; f.Package != "python_dateutil" || f.Line != 0 || f.Reason != "missing hash" {
+		t.Fatalf("unexpected missing hash %+v", f)
+	}
+	if failures := ParseHashInstallFailures("ERROR: No matching distribution found for idna==3.6", lock); len(failures) != 0 {
+		t.Fatalf("other pip errors are not hash failures, got %+v", failures)
+	}
+	fmt.Println("✅ pip hash errors pointed at lockfile lines")
+}
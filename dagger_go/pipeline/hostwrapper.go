@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// wrapperStopGrace is how long a wrapped host test run gets to exit after
+// SIGTERM on cancellation before it is killed.
+const wrapperStopGrace = 10 * time.Second
+
+// ParseShellWords splits a command line the way a POSIX shell splits words:
+// whitespace separates words, single quotes keep everything literal, and
+// double quotes and backslashes escape as usual. There is no expansion.
+func ParseShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", s)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`\n", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated double quote in %q", s)
+			}
+			inWord = true
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// hostTestCommand is the command prefix of the host-run test stages:
+// HostTestWrapper, when set, followed by the pytest to run.
+func (p *Pipeline) hostTestCommand(root string) []string {
+	return append(slices.Clone(p.cfg.HostTestWrapper), p.hostPytest(root)...)
+}
+
+// stopThroughWrapper makes cancelling cmd send SIGTERM, which a wrapper can
+// forward to pytest, instead of killing the wrapper alone and leaving pytest
+// running; it is killed after wrapperStopGrace.
+func (p *Pipeline) stopThroughWrapper(cmd *exec.Cmd) {
+	if len(p.cfg.HostTestWrapper) == 0 {
+		return
+	}
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = wrapperStopGrace
+}
+
+// wrapperExitHint explains the exit codes a shell-style wrapper uses when it
+// could not start pytest at all.
+func (p *Pipeline) wrapperExitHint(exitCode int) string {
+	if len(p.cfg.HostTestWrapper) == 0 {
+		return ""
+	}
+	switch exitCode {
+	case 126, 127:
+		return fmt.Sprintf(" — HOST_TEST_WRAPPER %s could not start pytest (command not found or not executable)", p.cfg.HostTestWrapper[0])
+	}
+	return ""
+}
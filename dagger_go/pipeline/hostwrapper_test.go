@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestParseShellWords tests the shell-word splitting of HOST_TEST_WRAPPER
+func TestParseShellWords(t *testing.T) {
+	cases := map[string][]string{
+		"":                                       nil,
+		"corp-netns-exec --profile build --":     {"corp-netns-exec", "--profile", "build", "--"},
+		`  sudo  -E   `:                          {"sudo", "-E"},
+		`wrap --label 'build job' --`:            {"wrap", "--label", "build job", "--"},
+		`wrap "--note=a \"quoted\" \$HOME" x\ y`: {"wrap", `--note=a "quoted" $HOME`, "x y"},
+		`wrap ''`:                                {"wrap", ""},
+	}
+	for in, want := range cases {
+		got, err := ParseShellWords(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseShellWords(%q) = %#v, %v; want %#v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{`wrap 'open`, `wrap "open`, `wrap \`} {
+		if _, err := ParseShellWords(bad); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+	fmt.Println("✅ HOST_TEST_WRAPPER split like shell words")
+}
+
+// TestHostTestWrapper tests that the host pytest runs through the wrapper with its exit code and summary intact
+func TestHostTestWrapper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fixtures")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, ".venv", "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pytest := `#!/bin/sh
+case "$*" in
+*--collect-only*) echo "tests/test_a.py::test_ok"; echo "2 tests collected"; exit 0 ;;
+esac
+echo "tests/test_a.py::test_ok PASSED"
+echo "tests/test_a.py::test_bad FAILED"
+echo "=========================== short test summary info ============================"
+echo "FAILED tests/test_a.py::test_bad - assert 1 == 2"
+echo "========================= 1 failed, 1 passed in 0.10s =========================="
+exit 1
+`
+	wrapper := `#!/bin/sh
+echo "[netns] profile $2" >> "$WRAP_LOG"
+shift 3
+"$@"
+`
+	os.WriteFile(filepath.Join(bin, "pytest"), []byte(pytest), 0o755)
+	wrapperPath := filepath.Join(root, "netns-exec")
+	os.WriteFile(wrapperPath, []byte(wrapper), 0o755)
+	wrapLog := filepath.Join(root, "wrap.log")
+	t.Setenv("WRAP_LOG", wrapLog)
+
+	var out bytes.Buffer
+	p := &Pipeline{
+		cfg:     Config{ProjectRoot: root, ArtifactsDir: t.TempDir(), HostTestWrapper: []string{wrapperPath, "--profile", "build", "--"}},
+		out:     &out,
+		report:  &Report{},
+		tracker: NewTracker(""),
+	}
+	ran, err := p.runTestsOnHost(context.Background(), "integration")
+	var runErr *TestRunError
+	if !ran || !errors.As(err, &runErr) || runErr.ExitCode != 1 {
+		t.Fatalf("the wrapped pytest's exit code should come through: ran=%v err=%v", ran, err)
+	}
+	if len(p.report.TestFailures) != 1 || p.report.TestFailures[0].ID != "tests/test_a.py::test_bad" {
+		t.Fatalf("the summary should be parsed from the wrapped output: %+v", p.report.TestFailures)
+	}
+	if !strings.HasPrefix(runErr.Command, wrapperPath+" --profile build -- "+filepath.Join(bin, "pytest")) {
+		t.Fatalf("the recorded command should include the wrapper: %s", runErr.Command)
+	}
+	log, _ := os.ReadFile(wrapLog)
+	if strings.Count(string(log), "[netns] profile build") != 2 {
+		t.Fatalf("the collection pre-pass and the run should both go through the wrapper: %q", log)
+	}
+	if !strings.Contains(out.String(), "$ "+wrapperPath+" --profile build -- ") {
+		t.Fatalf("the final argv should be printed: %s", out.String())
+	}
+	fmt.Println("✅ Host tests run through HOST_TEST_WRAPPER with exit code and summary intact")
+}
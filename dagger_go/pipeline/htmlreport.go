@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HTMLReportFile is the name of the HTML rendering of the run report
+// inside the artifacts directory (HTML_REPORT).
+const HTMLReportFile = "report.html"
+
+// htmlExcerptLines is how many lines of a failure's details the HTML report
+// shows; the full details stay in pipeline-report.json and the failures file.
+const htmlExcerptLines = 40
+
+//go:embed htmlreport.tmpl
+var htmlReportTemplate string
+
+// htmlReport is the template of report.html. It has no external assets, so
+// the file can be mailed or attached to a CI run and opened anywhere.
+var htmlReport = template.Must(template.New(HTMLReportFile).Funcs(template.FuncMap{
+	"duration": func(seconds float64) string {
+		return compactDuration(time.Duration(seconds * float64(time.Second)))
+	},
+	"excerpt": func(details string) string {
+		lines := strings.Split(strings.TrimRight(details, "\n"), "\n")
+		if len(lines) > htmlExcerptLines {
+			lines = append([]string{"…"}, lines[len(lines)-htmlExcerptLines:]...)
+		}
+		return strings.Join(lines, "\n")
+	},
+	"trim": strings.TrimSpace,
+	"timestamp": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+}).Parse(htmlReportTemplate))
+
+// htmlReportImage is an image the run published, with its pull command.
+type htmlReportImage struct {
+	Kind string
+	Ref  string
+	Pull string
+}
+
+// htmlReportData is what the template renders.
+type htmlReportData struct {
+	*Report
+	Title  string
+	Repo   string
+	Branch string
+	Images []htmlReportImage
+}
+
+// RenderHTMLReport renders r as a self-contained HTML page: the stages with
+// durations and outcomes, test counts, failure excerpts, the published
+// images with pull commands, the warnings and the environment fingerprint.
+func RenderHTMLReport(r *Report) ([]byte, error) {
+	data := htmlReportData{Report: r, Repo: r.Config["GitRepo"], Branch: r.Config["GitBranch"]}
+	if tag := r.Config["GitTag"]; tag != "" {
+		data.Branch = tag
+	}
+	data.Title = "Pipeline report"
+	if name := r.Config["RepoName"]; name != "" {
+		data.Title += ": " + name
+	}
+	for _, ref := range r.PublishedImages {
+		data.Images = append(data.Images, htmlReportImage{Kind: "image", Ref: ref, Pull: "docker pull " + ref})
+	}
+	if r.PRImage != nil {
+		data.Images = append(data.Images, htmlReportImage{Kind: "pull request", Ref: r.PRImage.Image, Pull: r.PRImage.Pull})
+	}
+	if r.TestImage != "" {
+		data.Images = append(data.Images, htmlReportImage{Kind: "test runner", Ref: r.TestImage, Pull: "docker pull " + r.TestImage})
+	}
+	var out bytes.Buffer
+	if err := htmlReport.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// WriteHTMLReport atomically writes the HTML rendering of r to
+// dir/report.html and returns the path written.
+func WriteHTMLReport(dir string, r *Report) (string, error) {
+	data, err := RenderHTMLReport(r)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, HTMLReportFile)
+	return path, WriteFileAtomic(path, data)
+}
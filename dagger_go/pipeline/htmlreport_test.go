@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRenderHTMLReport tests the sections of report.html and its escaping
+func TestRenderHTMLReport(t *testing.T) {
+	r := &Report{
+		Status: Status{
+			State:          RunFailed,
+			StartedAt:      time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC),
+			ElapsedSeconds: 95,
+			Error:          "integration tests failed",
+			Stages: []StageRecord{
+				{Name: "unit-tests", Status: StagePassed, DurationSeconds: 12},
+				{Name: "integration-tests", Status: StageFailed, DurationSeconds: 80, Message: "1 failed"},
+				{Name: "publish", Status: StageSkipped, Message: "tests failed"},
+			},
+		},
+		Config: map[string]string{"RepoName": "cert-parser", "GitRepo": "github.com/org/cert-parser", "GitBranch": "main"},
+		Tests:  []TestCount{{Stage: "integration-tests", Passed: 41, Failed: 1, Skipped: 2}},
+		TestFailures: []TestFailure{{
+			Stage: "integration-tests", ID: "tests/test_db.py::test_insert", Kind: "FAILED",
+			Reason: "AssertionError", Details: "assert '<script>alert(1)</script>' == ''",
+		}},
+		PublishedImages: []string{"ghcr.io/org/cert-parser:v1-abc1234"},
+		Warnings:        []Warning{{ID: "clock-skew", Message: "clock is 5m ahead\n", Count: 2}},
+		Environment:     &Environment{Binary: "v1.2.0", GoVersion: "go1.24.0", DaggerSDK: "0.19.7", OS: "linux", Arch: "amd64", BaseImage: BaseImage},
+	}
+	data, err := RenderHTMLReport(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"<title>Pipeline report: cert-parser</title>",
+		`<span class="badge failed">failed</span>`,
+		"integration-tests", "1m35s", "started 2026-03-01 09:30:00 UTC",
+		`<td class="num">41</td>`,
+		"tests/test_db.py::test_insert",
+		"&lt;script&gt;",
+		"docker pull ghcr.io/org/cert-parser:v1-abc1234",
+		"clock-skew", "go1.24.0 (linux/amd64)",
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("missing %q in:\n%s", want, html)
+		}
+	}
+	for _, external := range []string{"<script", "<link", "src=", "http://", "https://"} {
+		if strings.Contains(html, external) {
+			t.Fatalf("report references %q; it must be self-contained", external)
+		}
+	}
+	fmt.Println("✅ HTML report rendered without external assets")
+}
+
+// TestWriteHTMLReport tests that report.html is written to the artifacts directory
+func TestWriteHTMLReport(t *testing.T) {
+	dir := t.TempDir()
+	path, err := WriteHTMLReport(dir, &Report{Status: Status{State: RunSucceeded}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, HTMLReportFile) {
+		t.Fatalf("unexpected path %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "No stage ran.") {
+		t.Fatalf("unexpected report:\n%s", data)
+	}
+	fmt.Println("✅ HTML report written")
+}
+
+// TestCountOutcomes tests the per-stage test counts taken from JUnit
+func TestCountOutcomes(t *testing.T) {
+	count := CountOutcomes("unit-tests", []TestOutcome{
+		{ID: "a", Outcome: OutcomePassed}, {ID: "b", Outcome: OutcomePassed},
+		{ID: "c", Outcome: OutcomeFailed}, {ID: "d", Outcome: OutcomeError}, {ID: "e", Outcome: OutcomeSkipped},
+	})
+	if count != (TestCount{Stage: "unit-tests", Passed: 2, Failed: 1, Errors: 1, Skipped: 1}) {
+		t.Fatalf("unexpected count %+v", count)
+	}
+	fmt.Println("✅ Test outcomes counted")
+}
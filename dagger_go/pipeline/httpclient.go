@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds a whole request made with the pipeline HTTP client.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// HTTPClientConfig configures the HTTP client used for every call the
+// pipeline makes from the host (registry API, GitHub API, webhooks).
+type HTTPClientConfig struct {
+	ProxyURL    string        // explicit proxy; empty falls back to HTTP(S)_PROXY/NO_PROXY
+	CACertPaths []string      // extra CA files or directories (PEM or DER) trusted on top of the system pool
+	Timeout     time.Duration // whole-request timeout; 0 means DefaultHTTPTimeout
+	Debug       bool          // log each request and response status to Log
+	Log         io.Writer     // debug log destination; nil means os.Stderr
+}
+
+// NewHTTPClient builds an http.Client that goes through the configured proxy
+// and trusts the system roots plus the corporate CAs, so host-side calls work
+// behind a TLS-intercepting proxy.
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.ProxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	logw := cfg.Log
+	if logw == nil {
+		logw = os.Stderr
+	}
+	for _, path := range cfg.CACertPaths {
+		added, err := addCertsFromPath(roots, path)
+		if cfg.Debug {
+			if err != nil {
+				fmt.Fprintf(logw, "   [http] skipping CA %s: %v\n", path, err)
+			} else {
+				fmt.Fprintf(logw, "   [http] trusted %d certificate(s) from %s\n", added, path)
+			}
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 20 * time.Second
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	if cfg.Debug {
+		client.Transport = &loggingTransport{next: transport, w: logw}
+	}
+	return client, nil
+}
+
+// addCertsFromPath adds the certificates in a PEM/DER file, or in every file
+// of a directory, to pool and returns how many were added.
+func addCertsFromPath(pool *x509.CertPool, path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return addCertsFromFile(pool, path)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		// Files that are not certificates (READMEs, keys) are skipped silently
+		if n, err := addCertsFromFile(pool, filepath.Join(path, entry.Name())); err == nil {
+			total += n
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("no certificates found in directory")
+	}
+	return total, nil
+}
+
+func addCertsFromFile(pool *x509.CertPool, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			pool.AddCert(cert)
+			added++
+		}
+	}
+	if added > 0 {
+		return added, nil
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return 0, fmt.Errorf("neither PEM nor DER certificate")
+	}
+	pool.AddCert(cert)
+	return 1, nil
+}
+
+// loggingTransport logs method, URL (without query) and status of each
+// request. Headers are never logged, so tokens stay out of the output.
+type loggingTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(t.w, "   [http] %s %s → error after %v: %v\n", req.Method, target, elapsed, err)
+		return nil, err
+	}
+	fmt.Fprintf(t.w, "   [http] %s %s → %s (%v)\n", req.Method, target, strings.TrimSpace(resp.Status), elapsed)
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHTTPClientCustomRoots tests that the corporate CA is honored for TLS
+func TestHTTPClientCustomRoots(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// Without the test server's CA the handshake must fail
+	plain, err := NewHTTPClient(HTTPClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Get(server.URL); err == nil {
+		t.Fatal("expected certificate verification failure without the custom CA")
+	}
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "corporate-ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	derDir := filepath.Join(dir, "certs.d")
+	if err := os.Mkdir(derDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(derDir, "corporate-ca.der"), server.Certificate().Raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{caFile, derDir} {
+		client, err := NewHTTPClient(HTTPClientConfig{CACertPaths: []string{path}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request with CA from %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	fmt.Println("✅ Custom CA roots (PEM file and DER directory) trusted by the HTTP client")
+}
+
+// TestHTTPClientProxyAndDebugLog tests proxy selection and debug request logging
+func TestHTTPClientProxyAndDebugLog(t *testing.T) {
+	if _, err := NewHTTPClient(HTTPClientConfig{ProxyURL: "not a url"}); err == nil {
+		t.Fatal("expected invalid proxy URL to be rejected")
+	}
+
+	var log bytes.Buffer
+	client, err := NewHTTPClient(HTTPClientConfig{ProxyURL: "http://proxy.corp.example:3128", Debug: true, Log: &log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lt, ok := client.Transport.(*loggingTransport)
+	if !ok {
+		t.Fatalf("debug client should log requests, got %T", client.Transport)
+	}
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: "ghcr.io", Path: "/v2/"}}
+	proxyURL, err := lt.next.(*http.Transport).Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.Host != "proxy.corp.example:3128" {
+		t.Fatalf("expected configured proxy, got %v (%v)", proxyURL, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	direct, err := NewHTTPClient(HTTPClientConfig{Debug: true, Log: &log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := direct.Get(server.URL + "/v2/?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !strings.Contains(log.String(), "GET "+server.URL+"/v2/ → 200 OK") {
+		t.Fatalf("expected request to be logged, got %q", log.String())
+	}
+	if strings.Contains(log.String(), "secret") {
+		t.Fatal("query strings must not be logged")
+	}
+	fmt.Println("✅ Proxy honored and requests logged in debug mode")
+}
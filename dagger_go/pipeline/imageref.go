@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// registryPattern matches a registry host, optionally with a port and a
+	// path prefix, e.g. registry.corp.local:5000/team.
+	registryPattern = regexp.MustCompile(`^[A-Za-z0-9.-]+(:[0-9]+)?(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+	// repositoryComponentPattern matches one component of a repository path.
+	repositoryComponentPattern = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*$`)
+	// imageTagPattern matches an image tag.
+	imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+)
+
+// ImageOwner is the repository path form of a GitHub user or organization:
+// GHCR and Docker Hub only accept lower-case paths, so Javier-Godon
+// publishes under javier-godon.
+func ImageOwner(user string) string {
+	return strings.ToLower(user)
+}
+
+// buildImageRef composes host/namespace/name:tag. It is the one place image
+// references are built: trailing slashes are dropped, the host and
+// namespace are lower-cased (ImageOwner), name is made Docker-safe
+// (DockerSafeName), and a part that is still invalid after that is an error
+// naming it, before anything is pushed. An empty namespace publishes at the
+// root of the registry, as ECR repositories are.
+func buildImageRef(host, namespace, name, tag string) (string, error) {
+	host = strings.ToLower(strings.TrimRight(host, "/"))
+	namespace, name = strings.Trim(ImageOwner(namespace), "/"), DockerSafeName(name)
+	switch {
+	case !registryPattern.MatchString(host):
+		return "", fmt.Errorf("registry %q is not a registry host such as ghcr.io or registry.corp.local:5000", host)
+	case namespace != "" && !validNamespace(namespace):
+		return "", fmt.Errorf("image namespace %q is invalid: lower-case letters and digits, separated by . _ or -, with / between levels", namespace)
+	case !repositoryComponentPattern.MatchString(name):
+		return "", fmt.Errorf("image name %q is invalid: lower-case letters and digits, separated by . _ or -", name)
+	case !imageTagPattern.MatchString(tag):
+		return "", fmt.Errorf("image tag %q is invalid: up to 128 letters, digits, _ . and -, not starting with . or -", tag)
+	}
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s:%s", host, name, tag), nil
+	}
+	return fmt.Sprintf("%s/%s/%s:%s", host, namespace, name, tag), nil
+}
+
+// validNamespace reports whether every level of a namespace such as
+// team/backend is a valid repository path component.
+func validNamespace(namespace string) bool {
+	for _, component := range strings.Split(namespace, "/") {
+		if !repositoryComponentPattern.MatchString(component) {
+			return false
+		}
+	}
+	return true
+}
+
+// registryHost is the host (and port) of a registry that may carry a path
+// prefix, e.g. registry.corp.local:5000 for registry.corp.local:5000/team:
+// the address registry credentials are for.
+func registryHost(registry string) string {
+	host, _, _ := strings.Cut(strings.ToLower(registry), "/")
+	return host
+}
+
+// checkImageRef validates the image reference of the run once the image name
+// is known, and says which namespace form to use when it differs from
+// RegistryNamespace.
+func (p *Pipeline) checkImageRef(imageName string) error {
+	ref, err := buildImageRef(p.cfg.Registry, p.cfg.RegistryNamespace, imageName, "latest")
+	if err != nil {
+		return Errorf(CategoryConfig, "image reference: %w", err)
+	}
+	namespace := strings.Trim(p.cfg.RegistryNamespace, "/")
+	if owner := ImageOwner(namespace); owner != namespace {
+		p.printf("   ℹ️  Image namespace: %s, not %s — registry paths are lower-case; use %s in deployment manifests\n",
+			owner, namespace, strings.TrimSuffix(ref, ":latest"))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestBuildImageRef tests normalizing and validating the image reference
+func TestBuildImageRef(t *testing.T) {
+	cases := []struct{ registry, owner, name, tag, want string }{
+		{"ghcr.io", "Javier-Godon", "cert_parser", "v0.1.0-abc1234-20250101", "ghcr.io/javier-godon/cert-parser:v0.1.0-abc1234-20250101"},
+		{"registry.corp.local:5000/team", "ORG", "cert-parser", "latest", "registry.corp.local:5000/team/org/cert-parser:latest"},
+		{"ghcr.io", "org", "Cert.Parser", "pr-12", "ghcr.io/org/cert.parser:pr-12"},
+		{"Harbor.Corp.Local/", "Platform/Backend/", "cert-parser", "latest", "harbor.corp.local/platform/backend/cert-parser:latest"},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com", "/", "cert-parser", "latest", "123456789012.dkr.ecr.eu-west-1.amazonaws.com/cert-parser:latest"},
+		{"docker.io", "", "cert-parser", "latest", "docker.io/cert-parser:latest"},
+	}
+	for _, c := range cases {
+		got, err := buildImageRef(c.registry, c.owner, c.name, c.tag)
+		if err != nil || got != c.want {
+			t.Fatalf("buildImageRef(%q, %q, %q, %q) = %q, %v; want %q", c.registry, c.owner, c.name, c.tag, got, err, c.want)
+		}
+	}
+	invalid := []struct{ registry, owner, name, tag, part string }{
+		{"https://ghcr.io", "org", "app", "latest", "registry"},
+		{"ghcr.io", "org_", "app", "latest", "namespace"},
+		{"ghcr.io", "my org", "app", "latest", "namespace"},
+		{"ghcr.io", "team//backend", "app", "latest", "namespace"},
+		{"ghcr.io", "org", "app@2", "latest", "name"},
+		{"ghcr.io", "org", "-app", "latest", "name"},
+		{"ghcr.io", "org", "app", ".hidden", "tag"},
+		{"ghcr.io", "org", "app", strings.Repeat("v", 129), "tag"},
+	}
+	for _, c := range invalid {
+		if _, err := buildImageRef(c.registry, c.owner, c.name, c.tag); err == nil || !strings.Contains(err.Error(), c.part) {
+			t.Fatalf("buildImageRef(%q, %q, %q, %q) should fail naming the %s, got %v", c.registry, c.owner, c.name, c.tag, c.part, err)
+		}
+	}
+	if registryHost("Registry.Corp.Local:5000/team") != "registry.corp.local:5000" {
+		t.Fatalf("unexpected registry host %q", registryHost("Registry.Corp.Local:5000/team"))
+	}
+	fmt.Println("✅ Image references normalized and validated in one place")
+}
+
+// TestCheckImageRef tests the notice when the image owner differs from the configured user
+func TestCheckImageRef(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "Javier-Godon", Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.checkImageRef("cert-parser"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "use ghcr.io/javier-godon/cert-parser in deployment manifests") {
+		t.Fatalf("expected the owner notice, got %q", out.String())
+	}
+
+	out.Reset()
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out})
+	if err := p.checkImageRef("cert-parser"); err != nil || out.Len() != 0 {
+		t.Fatalf("a lower-case user needs no notice, got %q, %v", out.String(), err)
+	}
+	if err := p.checkImageRef("cert parser"); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	fmt.Println("✅ Lower-cased image owner explained")
+}
+
+// TestRegistryDefaults tests that the namespace and username default to GitUser
+func TestRegistryDefaults(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "Org", Registry: "docker.io/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.Registry != "docker.io" || p.cfg.RegistryNamespace != "Org" || p.cfg.RegistryUser != "Org" {
+		t.Fatalf("unexpected registry config %q %q %q", p.cfg.Registry, p.cfg.RegistryNamespace, p.cfg.RegistryUser)
+	}
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", Registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", RegistryNamespace: "/", RegistryUser: "AWS"})
+	if ref, err := buildImageRef(p.cfg.Registry, p.cfg.RegistryNamespace, "cert-parser", "latest"); err != nil || ref != "123456789012.dkr.ecr.eu-west-1.amazonaws.com/cert-parser:latest" || p.cfg.RegistryUser != "AWS" {
+		t.Fatalf("unexpected ECR reference %q, %v", ref, err)
+	}
+	fmt.Println("✅ Registry namespace and username default to the Git user")
+}
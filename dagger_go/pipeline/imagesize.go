@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"dagger.io/dagger"
+)
+
+// ImageLayersFile is the per-layer size breakdown in the artifacts directory.
+const ImageLayersFile = "image-layers.txt"
+
+// DefaultImageSizeMaxGrowth is how much (percent) the image may grow over
+// the branch's previous build before it is reported.
+const DefaultImageSizeMaxGrowth = 20
+
+// ImageLayer is one layer of the built image with the Dockerfile step that
+// created it. Sizes are compressed, as pushed to a registry.
+type ImageLayer struct {
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// ImageSizeReport is the size of the built image compared with the budget
+// and the branch's previous build.
+type ImageSizeReport struct {
+	Bytes     int64    `json:"bytes"`
+	Layers    int      `json:"layers"`
+	Budget    int64    `json:"budget,omitempty"`
+	Previous  int64    `json:"previous,omitempty"`
+	MaxGrowth int      `json:"max_growth_percent"`
+	HardLimit bool     `json:"hard_limit"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+// ociDescriptor is the part of an OCI descriptor the size check reads.
+type ociDescriptor struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// ImageLayers reads the layers of an OCI image manifest and matches them
+// with the non-empty history entries of its config.
+func ImageLayers(manifest, config []byte) ([]ImageLayer, error) {
+	var m struct {
+		Layers []ociDescriptor `json:"layers"`
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("parse image manifest: %w", err)
+	}
+	var c struct {
+		History []struct {
+			CreatedBy  string `json:"created_by"`
+			EmptyLayer bool   `json:"empty_layer"`
+		} `json:"history"`
+	}
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, fmt.Errorf("parse image config: %w", err)
+	}
+	var steps []string
+	for _, h := range c.History {
+		if !h.EmptyLayer {
+			steps = append(steps, strings.TrimSpace(h.CreatedBy))
+		}
+	}
+	layers := make([]ImageLayer, len(m.Layers))
+	for i, l := range m.Layers {
+		layers[i] = ImageLayer{Digest: l.Digest, Size: l.Size}
+		if len(steps) == len(m.Layers) {
+			layers[i].CreatedBy = steps[i]
+		}
+	}
+	return layers, nil
+}
+
+// CheckImageSize compares an image size with the budget and with the
+// previous build (0 when there is none) and returns the problems found.
+func CheckImageSize(size, budget, previous int64, maxGrowth int) []string {
+	var problems []string
+	if budget > 0 && size > budget {
+		problems = append(problems, fmt.Sprintf("image is %s, over the %s budget (IMAGE_SIZE_BUDGET)", FormatBytes(size), FormatBytes(budget)))
+	}
+	if previous > 0 && size > previous+previous*int64(maxGrowth)/100 {
+		problems = append(problems, fmt.Sprintf("image grew from %s to %s (+%.0f%%, more than %d%% since the previous build)",
+			FormatBytes(previous), FormatBytes(size), float64(size-previous)*100/float64(previous), maxGrowth))
+	}
+	return problems
+}
+
+// FormatImageLayers renders the per-layer breakdown in build order, with
+// each layer's share of the total.
+func FormatImageLayers(layers []ImageLayer) string {
+	var total int64
+	for _, l := range layers {
+		total += l.Size
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSIZE\tSHARE\tCREATED BY")
+	for i, l := range layers {
+		share := 0.0
+		if total > 0 {
+			share = float64(l.Size) * 100 / float64(total)
+		}
+		step := l.CreatedBy
+		if len(step) > 100 {
+			step = step[:97] + "..."
+		}
+		fmt.Fprintf(w, "%d\t%s\t%.1f%%\t%s\n", i+1, FormatBytes(l.Size), share, step)
+	}
+	fmt.Fprintf(w, "\tTOTAL %s\t\t%d layer(s)\n", FormatBytes(total), len(layers))
+	w.Flush()
+	return b.String()
+}
+
+// imageSizeHistoryFile is where the last image size of a branch is kept
+// between runs.
+func imageSizeHistoryFile(dir, branch string) string {
+	return filepath.Join(dir, "image-size-"+strings.NewReplacer("/", "_", "\\", "_").Replace(branch)+".json")
+}
+
+// imageSizeHistory is the stored size of a branch's last accepted image.
+type imageSizeHistory struct {
+	Bytes  int64  `json:"bytes"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// readImageLayers reads the manifest and config of the image's OCI tarball
+// in a throwaway container.
+func readImageLayers(ctx context.Context, base, image *dagger.Container) ([]ImageLayer, error) {
+	reader := base.WithMountedFile("/image.tar", image.AsTarball())
+	blob := func(name string) ([]byte, error) {
+		out, err := reader.WithExec([]string{"tar", "-xOf", "/image.tar", name}).Stdout(ctx)
+		return []byte(out), err
+	}
+	blobPath := func(digest string) string { return "blobs/" + strings.Replace(digest, ":", "/", 1) }
+
+	data, err := blob("index.json")
+	if err != nil {
+		return nil, fmt.Errorf("read image index: %w", err)
+	}
+	var index struct {
+		Manifests []ociDescriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil || len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("image tarball has no manifest")
+	}
+	manifest, err := blob(blobPath(index.Manifests[0].Digest))
+	if err != nil {
+		return nil, fmt.Errorf("read image manifest: %w", err)
+	}
+	var m struct {
+		Config ociDescriptor `json:"config"`
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("parse image manifest: %w", err)
+	}
+	config, err := blob(blobPath(m.Config.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("read image config: %w", err)
+	}
+	return ImageLayers(manifest, config)
+}
+
+// checkImageSize measures the built image, writes the layer breakdown to
+// the artifacts directory and compares the size with ImageSizeBudget and
+// the branch's previous build in FreezeHistoryDir. Problems fail the stage
+// with ImageSizeHardLimit and are warnings otherwise; a size that could
+// not be measured is only a warning.
+func (p *Pipeline) checkImageSize(ctx context.Context, client *dagger.Client, image *dagger.Container) (*ImageSizeReport, error) {
+	cfg := p.cfg
+	layers, err := readImageLayers(ctx, p.from(client, p.baseImage()), image)
+	if err != nil {
+		p.warnf("image-size", "   ⚠️  Could not measure the image size: %v\n", err)
+		return nil, nil
+	}
+	p.imageLayers = layers
+	report := &ImageSizeReport{Layers: len(layers), Budget: cfg.ImageSizeBudget, MaxGrowth: cfg.ImageSizeMaxGrowth, HardLimit: cfg.ImageSizeHardLimit}
+	for _, l := range layers {
+		report.Bytes += l.Size
+	}
+	p.printf("   📦 Image size: %s in %d layer(s)", FormatBytes(report.Bytes), len(layers))
+	if report.Budget > 0 {
+		p.printf(" (budget %s)", FormatBytes(report.Budget))
+	}
+	p.println()
+
+	breakdown := FormatImageLayers(layers)
+	path := filepath.Join(cfg.ArtifactsDir, ImageLayersFile)
+	err = os.MkdirAll(cfg.ArtifactsDir, 0o755)
+	if err == nil {
+		err = WriteFileAtomic(path, []byte(breakdown))
+	}
+	if err != nil {
+		p.warnf("image-size", "   ⚠️  Could not export %s: %v\n", ImageLayersFile, err)
+	} else {
+		sum := sha256.Sum256([]byte(breakdown))
+		p.report.Artifacts = append(p.report.Artifacts, Artifact{Name: ImageLayersFile, Path: path, Size: int64(len(breakdown)), SHA256: hex.EncodeToString(sum[:])})
+		p.printf("   📄 Layer breakdown: %s\n", path)
+	}
+
+	history := ""
+	if cfg.FreezeHistoryDir != "" {
+		history = imageSizeHistoryFile(cfg.FreezeHistoryDir, gitRefName(cfg))
+		var previous imageSizeHistory
+		data, err := os.ReadFile(history)
+		if err == nil {
+			err = json.Unmarshal(data, &previous)
+		}
+		switch {
+		case err == nil:
+			report.Previous = previous.Bytes
+			delta := report.Bytes - previous.Bytes
+			sign := "+"
+			if delta < 0 {
+				sign, delta = "-", -delta
+			}
+			p.printf("   📈 Previous build of %s: %s (%s%s)\n", gitRefName(cfg), FormatBytes(previous.Bytes), sign, FormatBytes(delta))
+		case errors.Is(err, os.ErrNotExist):
+			p.println("   ℹ️  No previous image size for this branch — nothing to compare yet")
+		default:
+			p.warnf("image-size", "   ⚠️  Could not read image size history: %v\n", err)
+		}
+	}
+
+	report.Problems = CheckImageSize(report.Bytes, report.Budget, report.Previous, report.MaxGrowth)
+	for _, problem := range report.Problems {
+		if report.HardLimit {
+			p.printf("   ❌ %s\n", problem)
+		} else {
+			p.warnf("image-size-budget", "   ⚠️  %s\n", problem)
+		}
+	}
+	if len(report.Problems) > 0 && report.HardLimit {
+		// Not recorded, so the next run still compares with the last accepted size
+		return report, Errorf(CategoryBuild, "image size check failed: %s (IMAGE_SIZE_HARD_LIMIT=true)", report.Problems[0])
+	}
+
+	if history != "" {
+		data, _ := json.Marshal(imageSizeHistory{Bytes: report.Bytes, Commit: p.commit})
+		err := os.MkdirAll(filepath.Dir(history), 0o755)
+		if err == nil {
+			err = WriteFileAtomic(history, data)
+		}
+		if err != nil {
+			p.warnf("image-size", "   ⚠️  Could not record image size history: %v\n", err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestImageLayers tests matching manifest layers with the config history
+func TestImageLayers(t *testing.T) {
+	manifest := []byte(`{"layers": [
+		{"digest": "sha256:aaa", "size": 30000000},
+		{"digest": "sha256:bbb", "size": 120000000}
+	]}`)
+	config := []byte(`{"history": [
+		{"created_by": "/bin/sh -c #(nop) ADD file:base in /"},
+		{"created_by": "ENV PYTHONUNBUFFERED=1", "empty_layer": true},
+		{"created_by": "RUN pip install -r requirements.txt"}
+	]}`)
+
+	layers, err := ImageLayers(manifest, config)
+	if err != nil {
+		t.Fatalf("ImageLayers: %v", err)
+	}
+	if len(layers) != 2 || layers[1].Size != 120000000 || layers[1].CreatedBy != "RUN pip install -r requirements.txt" {
+		t.Fatalf("unexpected layers: %+v", layers)
+	}
+
+	// History that does not line up with the layers is left out
+	layers, err = ImageLayers(manifest, []byte(`{"history": [{"created_by": "RUN true"}]}`))
+	if err != nil || layers[0].CreatedBy != "" {
+		t.Fatalf("mismatched history should be dropped: %+v, %v", layers, err)
+	}
+
+	breakdown := FormatImageLayers(layers)
+	if !strings.Contains(breakdown, "80.0%") || !strings.Contains(breakdown, "2 layer(s)") {
+		t.Fatalf("unexpected breakdown:\n%s", breakdown)
+	}
+	fmt.Println("✅ Image layers read from the manifest and config")
+}
+
+// TestCheckImageSize tests the budget and growth checks
+func TestCheckImageSize(t *testing.T) {
+	const mb = 1000 * 1000
+	if problems := CheckImageSize(250*mb, 300*mb, 240*mb, 20); len(problems) != 0 {
+		t.Fatalf("within budget and growth, got %v", problems)
+	}
+	if problems := CheckImageSize(310*mb, 300*mb, 0, 20); len(problems) != 1 || !strings.Contains(problems[0], "budget") {
+		t.Fatalf("over budget not reported: %v", problems)
+	}
+	if problems := CheckImageSize(250*mb, 0, 200*mb, 20); len(problems) != 1 || !strings.Contains(problems[0], "+25%") {
+		t.Fatalf("growth not reported: %v", problems)
+	}
+	if problems := CheckImageSize(400*mb, 300*mb, 200*mb, 20); len(problems) != 2 {
+		t.Fatalf("expected budget and growth problems, got %v", problems)
+	}
+	fmt.Println("✅ Image size checked against budget and previous build")
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultPipIndexURL is the package index pip uses in the build container
+// without PipIndexURL.
+const DefaultPipIndexURL = "https://pypi.org/simple/"
+
+// indexProbeTimeout is pip's socket timeout for the probe, in seconds.
+const indexProbeTimeout = "15"
+
+// indexProbeCmd asks the index for the versions of pip itself: one request
+// through the same proxy and CA settings as the install, without retries.
+var indexProbeCmd = []string{
+	"pip", "index", "versions", "pip",
+	"--retries", "0", "--timeout", indexProbeTimeout, "--disable-pip-version-check",
+}
+
+// IndexProbeError picks the lines of failed probe output that explain it —
+// pip's "Could not fetch URL" and the urllib3 "Caused by" (SSLError,
+// ProxyError, ...) — or falls back to the last non-empty line.
+func IndexProbeError(output string) string {
+	var reasons []string
+	last := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		last = line
+		if strings.Contains(line, "Could not fetch URL") || strings.Contains(line, "Caused by") {
+			reasons = append(reasons, line)
+		}
+	}
+	if len(reasons) == 0 {
+		return last
+	}
+	return strings.Join(reasons, "\n")
+}
+
+// pipIndexURL is the package index pip installs from.
+func (p *Pipeline) pipIndexURL() string {
+	if p.cfg.PipIndexURL != "" {
+		return p.cfg.PipIndexURL
+	}
+	return DefaultPipIndexURL
+}
+
+// probeIndex checks that pip can reach the package index from the system
+// environment before the dependency install, which otherwise spends many
+// minutes retrying a broken proxy or CA setup. It runs only with a proxy,
+// corporate CA certificates or PipIndexURL configured, and never from
+// Dagger's cache.
+func (p *Pipeline) probeIndex(ctx context.Context) error {
+	cfg := p.cfg
+	if p.systemBase == nil || (len(cfg.CACertPaths) == 0 && cfg.ProxyURL == "" && cfg.PipIndexURL == "") {
+		return nil
+	}
+	index := redactConfigURL(p.pipIndexURL(), false)
+	p.printf("   🔌 Checking that pip can reach %s (timeout %ss)...\n", index, indexProbeTimeout)
+	probe := p.systemBase.
+		WithEnvVariable(cacheBusterEnv, p.cacheBuster).
+		WithExec(indexProbeCmd, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+	code, err := probe.ExitCode(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "package index probe: %w", err)
+	}
+	if code == 0 {
+		p.println("      ✓ Package index reachable")
+		return nil
+	}
+	stdout, _ := probe.Stdout(ctx)
+	stderr, _ := probe.Stderr(ctx)
+	proxy, bundle := "none", "none (system default)"
+	if cfg.ProxyURL != "" {
+		proxy = redactConfigURL(cfg.ProxyURL, false)
+	}
+	if len(cfg.CACertPaths) > 0 {
+		bundle = caBundlePath
+	}
+	return Errorf(CategoryConfig, "pip cannot reach the package index %s (proxy %s, CA bundle %s):\n%s",
+		index, proxy, bundle, IndexProbeError(stdout+"\n"+stderr))
+}
@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestIndexProbeError tests picking the proxy/TLS cause out of pip's probe output
+func TestIndexProbeError(t *testing.T) {
+	output := `WARNING: Retrying after connection broken
+Could not fetch URL https://pypi.org/simple/pip/: There was a problem confirming the ssl certificate: HTTPSConnectionPool(host='pypi.org', port=443): Max retries exceeded with url: /simple/pip/ (Caused by SSLError(SSLCertVerificationError(1, '[SSL: CERTIFICATE_VERIFY_FAILED] certificate verify failed: self-signed certificate in certificate chain')))
+ERROR: No matching distribution found for pip
+`
+	got := IndexProbeError(output)
+	if !strings.Contains(got, "CERTIFICATE_VERIFY_FAILED") || strings.Contains(got, "No matching distribution") {
+		t.Fatalf("IndexProbeError = %q", got)
+	}
+	if got := IndexProbeError("ERROR: something else\n\n"); got != "ERROR: something else" {
+		t.Fatalf("fallback to the last line = %q", got)
+	}
+	fmt.Println("✅ Package index probe errors explained")
+}
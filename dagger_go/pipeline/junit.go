@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Test outcomes recorded from JUnit XML.
+const (
+	OutcomePassed  = "passed"
+	OutcomeFailed  = "failed"
+	OutcomeError   = "error"
+	OutcomeSkipped = "skipped"
+)
+
+// TestOutcome is the result of one test case in a JUnit report.
+type TestOutcome struct {
+	ID      string // pytest-style id, e.g. tests.integration.test_db.TestRepo::test_insert
+	Outcome string
+}
+
+type junitCase struct {
+	ClassName string    `xml:"classname,attr"`
+	Name      string    `xml:"name,attr"`
+	Failure   *struct{} `xml:"failure"`
+	Error     *struct{} `xml:"error"`
+	Skipped   *struct{} `xml:"skipped"`
+}
+
+type junitSuite struct {
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+// ParseJUnit reads the test cases of a JUnit XML report as written by
+// `pytest --junitxml`. Both a <testsuites> root and a bare <testsuite> are
+// accepted.
+func ParseJUnit(data []byte) ([]TestOutcome, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit XML: %w", err)
+	}
+	var outcomes []TestOutcome
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			outcome := OutcomePassed
+			switch {
+			case c.Error != nil:
+				outcome = OutcomeError
+			case c.Failure != nil:
+				outcome = OutcomeFailed
+			case c.Skipped != nil:
+				outcome = OutcomeSkipped
+			}
+			id := c.Name
+			if c.ClassName != "" {
+				id = c.ClassName + "::" + c.Name
+			}
+			outcomes = append(outcomes, TestOutcome{ID: id, Outcome: outcome})
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+	return outcomes, nil
+}
+
+// junitPath is where a stage's JUnit report is kept in the artifacts directory.
+func (p *Pipeline) junitPath(stage string) (string, error) {
+	dir, err := filepath.Abs(p.cfg.ArtifactsDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "junit-"+stage+".xml"), nil
+}
+
+// TestCount is how many tests of a stage had each outcome, from its JUnit
+// report.
+type TestCount struct {
+	Stage   string `json:"stage"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Errors  int    `json:"errors"`
+	Skipped int    `json:"skipped"`
+}
+
+// CountOutcomes counts the outcomes of a stage's test cases.
+func CountOutcomes(stage string, outcomes []TestOutcome) TestCount {
+	count := TestCount{Stage: stage}
+	for _, o := range outcomes {
+		switch o.Outcome {
+		case OutcomePassed:
+			count.Passed++
+		case OutcomeFailed:
+			count.Failed++
+		case OutcomeError:
+			count.Errors++
+		case OutcomeSkipped:
+			count.Skipped++
+		}
+	}
+	return count
+}
+
+// collectJUnit records the outcomes of a stage's JUnit report for the
+// flaky-test history, and their counts in the report. A missing or
+// unreadable report only warns.
+func (p *Pipeline) collectJUnit(stage, path string) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var outcomes []TestOutcome
+		if outcomes, err = ParseJUnit(data); err == nil {
+			for _, o := range outcomes {
+				p.outcomes[stage+":"+o.ID] = o.Outcome
+			}
+			count := CountOutcomes(stage, outcomes)
+			p.report.Tests = append(p.report.Tests, count)
+			p.events.Emit(Event{Type: EventTestSummary, Stage: stage, Tests: &count})
+			return
+		}
+	}
+	p.warnf("junit", "   ⚠️  No test outcomes recorded for %s: %v\n", stage, err)
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// OCI annotation labels the built image carries, so an image in the
+// registry leads back to its commit without decoding the tag. The created
+// label is OCICreatedLabel.
+const (
+	OCISourceLabel   = "org.opencontainers.image.source"
+	OCIRevisionLabel = "org.opencontainers.image.revision"
+	OCIVersionLabel  = "org.opencontainers.image.version"
+	OCITitleLabel    = "org.opencontainers.image.title"
+)
+
+// imageLabelKeyPattern is a label key: reverse-DNS style, as the OCI and
+// Docker conventions use, e.g. com.example.team.
+var imageLabelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// ParseImageLabels parses IMAGE_LABELS: comma-separated key=value pairs
+// added to the image's labels, e.g.
+// "org.opencontainers.image.vendor=ACME,com.acme.team=pki".
+func ParseImageLabels(value string) (map[string]string, error) {
+	var labels map[string]string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !imageLabelKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%q is not key=value with a valid label key", entry)
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = strings.TrimSpace(val)
+	}
+	return labels, nil
+}
+
+// SourceURL is the repository's web address for OCISourceLabel: the clone
+// URL without credentials or the .git suffix. An SSH or unparsable URL is
+// returned as it is, minus .git.
+func SourceURL(repo string) string {
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	u, err := url.Parse(repo)
+	if err != nil || u.Host == "" {
+		return repo
+	}
+	u.User = nil
+	return u.String()
+}
+
+// imageLabels is the label set of the image built from the checkout: the
+// OCI source, revision, created, version and title, then Config.ImageLabels,
+// which may replace them. The version is the release being built, else the
+// project's static version; without either it is left out.
+func (p *Pipeline) imageLabels(title, version string, created time.Time) map[string]string {
+	cfg := p.cfg
+	if cfg.ReleaseVersion != "" {
+		version = cfg.ReleaseVersion
+	}
+	labels := map[string]string{
+		OCISourceLabel:  SourceURL(cfg.GitRepo),
+		OCICreatedLabel: created.UTC().Format(time.RFC3339),
+		OCITitleLabel:   title,
+	}
+	if p.commit != "" {
+		labels[OCIRevisionLabel] = p.commit
+	}
+	if version != "" {
+		labels[OCIVersionLabel] = version
+	}
+	for key, value := range cfg.ImageLabels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// ImageLabels is the label set of the image built from source outside Run,
+// as by the Dagger module: the title and static version are read from its
+// pyproject.toml, and revision is the commit it was checked out at (left
+// out when empty).
+func (p *Pipeline) ImageLabels(ctx context.Context, source *dagger.Directory, revision string) (map[string]string, error) {
+	raw, err := source.File("pyproject.toml").Contents(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "failed to read pyproject.toml: %w", err)
+	}
+	_, info, err := ParsePyproject(raw)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	title, version := info.Name, info.Version
+	if title == "" {
+		title = p.cfg.RepoName
+	}
+	if info.DynamicVersion {
+		version = ""
+	}
+	p.commit = revision
+	return p.imageLabels(title, version, p.buildTimestamp().Time), nil
+}
+
+// sortedLabelKeys is the keys of labels in order, so the image config, and
+// with it the image digest, does not depend on map iteration.
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// Large image publish defaults.
+const (
+	DefaultLargeImageThreshold = 1 << 30 // 1 GiB compressed
+	DefaultPublishAttempts     = 4
+	DefaultPublishTimeout      = 45 * time.Minute
+)
+
+// maxPublishBackoff caps the wait between publish attempts.
+const maxPublishBackoff = 5 * time.Minute
+
+// publishProgressInterval is how often the upload progress of a large image
+// is read from the registry.
+var publishProgressInterval = 30 * time.Second
+
+// PublishReport is how a large image was published, recorded in the run
+// report.
+type PublishReport struct {
+	Bytes    int64    `json:"bytes"`
+	Attempts int      `json:"attempts"`
+	Errors   []string `json:"errors,omitempty"` // of the failed attempts
+}
+
+// ParseLargeImageThreshold parses LARGE_IMAGE_THRESHOLD: a size such as
+// 2GiB, or "off" (returned as -1) to publish every image in one attempt.
+func ParseLargeImageThreshold(value string) (int64, error) {
+	return parseSizeOrOff(value)
+}
+
+// PublishBackoff is the wait before publish attempt n+1: 30s, doubling per
+// attempt up to maxPublishBackoff.
+func PublishBackoff(attempt int) time.Duration {
+	wait := 30 * time.Second
+	for i := 1; i < attempt && wait < maxPublishBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxPublishBackoff)
+}
+
+// LayersInRegistry reports which layers of image are already in its
+// registry repository, by asking for each blob with HEAD. Dagger skips the
+// blobs a registry already has, so these are the bytes a retried publish
+// does not upload again.
+func LayersInRegistry(ctx context.Context, client *http.Client, image string, layers []ImageLayer, username, password string) (present int, bytes int64, err error) {
+	if len(layers) == 0 {
+		return 0, 0, nil
+	}
+	host, repo, _ := splitImage(image)
+	base := fmt.Sprintf("https://%s/v2/%s/blobs/", host, repo)
+	auth, err := registryAuth(ctx, client, base+layers[0].Digest, repo, username, password)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, layer := range layers {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, base+layer.Digest, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, 0, err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			present++
+			bytes += layer.Size
+		case http.StatusNotFound:
+		default:
+			return 0, 0, fmt.Errorf("blob %s: registry returned %s", layer.Digest, resp.Status)
+		}
+	}
+	return present, bytes, nil
+}
+
+// largeImage reports whether the built image is over LargeImageThreshold.
+// An image whose size could not be measured is not.
+func (p *Pipeline) largeImage() bool {
+	size := p.report.ImageSize
+	return p.cfg.LargeImageThreshold > 0 && size != nil && size.Bytes >= p.cfg.LargeImageThreshold
+}
+
+// publish publishes image with publishWithReauth. An image over
+// LargeImageThreshold gets PublishAttempts attempts of up to PublishTimeout
+// each, with PublishBackoff in between and the upload progress printed
+// while it runs, because a multi-gigabyte push through a slow proxy fails
+// part-way often enough that one attempt is not worth a whole run.
+// Variants are the other platforms of a manifest list, as in PublishImage.
+func (p *Pipeline) publish(ctx context.Context, client *dagger.Client, image *dagger.Container, address string, variants ...*dagger.Container) (string, error) {
+	if !p.largeImage() {
+		return p.publishWithReauth(ctx, client, image, address, variants...)
+	}
+	cfg := p.cfg
+	record := &PublishReport{Bytes: p.report.ImageSize.Bytes}
+	p.report.Publish = record
+	p.printf("   🐘 Large image (%s, LARGE_IMAGE_THRESHOLD=%s): up to %d attempt(s) of %v each\n",
+		FormatBytes(record.Bytes), FormatBytes(cfg.LargeImageThreshold), cfg.PublishAttempts, cfg.PublishTimeout)
+
+	for attempt := 1; ; attempt++ {
+		record.Attempts = attempt
+		attemptCtx, cancel := context.WithTimeout(ctx, cfg.PublishTimeout)
+		stop := p.reportUploadProgress(attemptCtx, address)
+		published, err := p.publishWithReauth(attemptCtx, client, image, address, variants...)
+		stop()
+		if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("attempt timed out after %v (LARGE_IMAGE_PUBLISH_TIMEOUT): %w", cfg.PublishTimeout, err)
+		}
+		cancel()
+		if err == nil {
+			return published, nil
+		}
+		record.Errors = append(record.Errors, err.Error())
+		if ctx.Err() != nil || attempt >= cfg.PublishAttempts || registryPermissionErrorPattern.MatchString(err.Error()) {
+			return "", fmt.Errorf("%w (attempt %d of %d)", err, attempt, cfg.PublishAttempts)
+		}
+
+		wait := PublishBackoff(attempt)
+		p.printf("   🔁 Publish attempt %d of %d failed: %v\n", attempt, cfg.PublishAttempts, err)
+		if present, bytes, perr := LayersInRegistry(ctx, cfg.HTTPClient, address, p.imageLayers, cfg.RegistryUser, p.registryToken); perr == nil {
+			p.printf("   📦 %d of %d layer(s) (%s of %s) already in the registry — the next attempt uploads only the rest\n",
+				present, len(p.imageLayers), FormatBytes(bytes), FormatBytes(record.Bytes))
+		}
+		p.printf("   ⏳ Retrying in %v\n", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// reportUploadProgress prints every publishProgressInterval how much of the
+// image is in the registry, so a long upload does not look hung. The
+// returned function stops it.
+func (p *Pipeline) reportUploadProgress(ctx context.Context, address string) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		started := time.Now()
+		ticker := time.NewTicker(publishProgressInterval)
+		defer ticker.Stop()
+		unavailable := false
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			elapsed := time.Since(started).Round(time.Second)
+			present, bytes, err := LayersInRegistry(ctx, p.cfg.HTTPClient, address, p.imageLayers, p.cfg.RegistryUser, p.registryToken)
+			switch {
+			case err == nil && len(p.imageLayers) > 0:
+				p.printf("   ⏫ %s of %s in the registry (%d of %d layer(s), %v elapsed)\n",
+					FormatBytes(bytes), FormatBytes(p.report.ImageSize.Bytes), present, len(p.imageLayers), elapsed)
+			case err != nil && !unavailable:
+				unavailable = true
+				p.printf("   ⏫ Still uploading (%v elapsed; progress unavailable: %v)\n", elapsed, err)
+			default:
+				p.printf("   ⏫ Still uploading (%v elapsed)\n", elapsed)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPublishBackoff tests the wait between publish attempts doubling up to its cap
+func TestPublishBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := PublishBackoff(i + 1); got != w {
+			t.Fatalf("PublishBackoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if n, err := ParseLargeImageThreshold("2GiB"); err != nil || n != 2<<30 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if n, err := ParseLargeImageThreshold("OFF"); err != nil || n != -1 {
+		t.Fatalf("off should disable, got %d, %v", n, err)
+	}
+	fmt.Println("✅ Publish backoff and large-image threshold")
+}
+
+// TestLargeImage tests which images get the large-image publish
+func TestLargeImage(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.PublishAttempts != DefaultPublishAttempts || p.cfg.PublishTimeout != DefaultPublishTimeout {
+		t.Fatalf("defaults not applied: %+v", p.cfg)
+	}
+	if p.largeImage() {
+		t.Fatal("an unmeasured image is not large")
+	}
+	p.report.ImageSize = &ImageSizeReport{Bytes: 4800 << 20}
+	if !p.largeImage() {
+		t.Fatal("a 4.8 GiB image is over the default threshold")
+	}
+	p.cfg.LargeImageThreshold = -1
+	if p.largeImage() {
+		t.Fatal("a negative threshold disables the large-image publish")
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", PublishAttempts: -1}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("negative PublishAttempts should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Large images detected by size")
+}
+
+// TestLayersInRegistry tests measuring upload progress from the blobs the registry already has
+func TestLayersInRegistry(t *testing.T) {
+	layers := []ImageLayer{
+		{Digest: "sha256:aaaa", Size: 100},
+		{Digest: "sha256:bbbb", Size: 4000},
+		{Digest: "sha256:cccc", Size: 20},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || !strings.HasPrefix(r.URL.Path, "/v2/org/app/blobs/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "bbbb") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	image := strings.TrimPrefix(server.URL, "https://") + "/org/app:v1"
+	present, bytes, err := LayersInRegistry(context.Background(), server.Client(), image, layers, "ci", "pat")
+	if err != nil || present != 2 || bytes != 120 {
+		t.Fatalf("got %d layer(s), %d bytes, %v", present, bytes, err)
+	}
+	if _, _, err := LayersInRegistry(context.Background(), server.Client(), strings.TrimPrefix(server.URL, "https://")+"/other/app:v1", layers, "ci", "pat"); err == nil {
+		t.Fatal("an unexpected registry status should be an error")
+	}
+	fmt.Println("✅ Upload progress read from the registry")
+}
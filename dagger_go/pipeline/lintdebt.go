@@ -0,0 +1,282 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// Lint history defaults.
+const (
+	LintHistoryFile          = "lint-history.json"
+	DefaultLintHistoryWindow = 100
+	lintHistoryVersion       = 1
+)
+
+// LintFindings is what one ruff or mypy run reported, per rule code (ruff,
+// e.g. F401) or error code (mypy, e.g. arg-type).
+type LintFindings struct {
+	Tool     string         `json:"tool"`
+	Scope    string         `json:"scope"` // "full" or "changed"; only full runs are compared
+	ExitCode int            `json:"exit_code"`
+	Total    int            `json:"total"`
+	Codes    map[string]int `json:"codes,omitempty"`
+	Previous *int           `json:"previous,omitempty"` // baseline total from the lint history
+}
+
+var (
+	// path:line:col: F401 message (concise) or "F401 [*] message" (full)
+	ruffConcisePattern = regexp.MustCompile(`(?m)^\S+:\d+:\d+: ([A-Z]+[0-9]+)\b`)
+	ruffFullPattern    = regexp.MustCompile(`(?m)^([A-Z]+[0-9]+) (?:\[\*\] )?\S.*\n\s*--> `)
+	// path:line: error: message  [code]
+	mypyErrorPattern = regexp.MustCompile(`(?m)^\S+:\d+(?::\d+)?: error: .*?(?:\s\s\[([a-z0-9-]+)\])?$`)
+)
+
+// ParseRuffOutput counts ruff diagnostics per rule code, in the concise or
+// the full output format.
+func ParseRuffOutput(output string) LintFindings {
+	f := LintFindings{Tool: "ruff", Codes: map[string]int{}}
+	matches := ruffConcisePattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		matches = ruffFullPattern.FindAllStringSubmatch(output, -1)
+	}
+	for _, m := range matches {
+		f.Codes[m[1]]++
+		f.Total++
+	}
+	return f
+}
+
+// ParseMypyOutput counts mypy errors per error code; errors without a code
+// count as "misc". Notes are not findings.
+func ParseMypyOutput(output string) LintFindings {
+	f := LintFindings{Tool: "mypy", Codes: map[string]int{}}
+	for _, m := range mypyErrorPattern.FindAllStringSubmatch(output, -1) {
+		code := m[1]
+		if code == "" {
+			code = "misc"
+		}
+		f.Codes[code]++
+		f.Total++
+	}
+	return f
+}
+
+// TopCodes lists the n most frequent codes as "F401×12", most frequent first.
+func (f LintFindings) TopCodes(n int) []string {
+	codes := make([]string, 0, len(f.Codes))
+	for code := range f.Codes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if f.Codes[codes[i]] != f.Codes[codes[j]] {
+			return f.Codes[codes[i]] > f.Codes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	var top []string
+	for _, code := range codes[:min(n, len(codes))] {
+		top = append(top, fmt.Sprintf("%s×%d", code, f.Codes[code]))
+	}
+	return top
+}
+
+// LintHistory is the lint debt store: finding counts of the last runs,
+// oldest first, as one JSON document:
+//
+//	{
+//	  "version": 1,
+//	  "runs": [
+//	    {
+//	      "finished_at": "2026-10-17T09:30:00Z",
+//	      "branch": "main",
+//	      "commit": "4f2c9e1",
+//	      "findings": {"ruff": {"F401": 3, "E501": 9}, "mypy": {"arg-type": 2}}
+//	    }
+//	  ]
+//	}
+//
+// Only full-repository runs are recorded; changed-only counts are not
+// comparable. Appending trims the store to DefaultLintHistoryWindow runs.
+type LintHistory struct {
+	Version int       `json:"version"`
+	Runs    []LintRun `json:"runs"`
+}
+
+// LintRun is one pipeline run in the lint history.
+type LintRun struct {
+	FinishedAt time.Time                 `json:"finished_at"`
+	Branch     string                    `json:"branch"`
+	Commit     string                    `json:"commit,omitempty"`
+	Findings   map[string]map[string]int `json:"findings"`
+}
+
+// Baseline is the total of tool's findings in the newest run on branch, or
+// on fallback when branch has none yet.
+func (h *LintHistory) Baseline(tool, branch, fallback string) (int, bool) {
+	for _, b := range []string{branch, fallback} {
+		for i := len(h.Runs) - 1; i >= 0; i-- {
+			run := h.Runs[i]
+			if codes, ok := run.Findings[tool]; ok && run.Branch == b {
+				total := 0
+				for _, n := range codes {
+					total += n
+				}
+				return total, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// LoadLintHistory reads the store from path; a missing file is an empty history.
+func LoadLintHistory(path string) (*LintHistory, error) {
+	h := &LintHistory{Version: lintHistoryVersion}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("invalid lint history: %w", err)
+	}
+	if h.Version > lintHistoryVersion {
+		return nil, fmt.Errorf("lint history version %d is newer than supported (%d)", h.Version, lintHistoryVersion)
+	}
+	return h, nil
+}
+
+// SaveLintHistory appends run and atomically writes the store to path.
+func SaveLintHistory(path string, h *LintHistory, run LintRun) error {
+	h.Version = lintHistoryVersion
+	h.Runs = append(h.Runs, run)
+	if len(h.Runs) > DefaultLintHistoryWindow {
+		h.Runs = append([]LintRun(nil), h.Runs[len(h.Runs)-DefaultLintHistoryWindow:]...)
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+// runLintTool runs ruff or mypy over the selected scope (runLint) and
+// counts its findings whether it passes or fails. The findings are compared
+// with the lint history and, with LintRatchet, more findings than the
+// baseline fail the stage even when the tool itself passed. The returned
+// container is the one the tool ran in, without the ContainerArtifactsDir
+// it exported.
+func (p *Pipeline) runLintTool(ctx context.Context, builder *dagger.Container, tool string) (*dagger.Container, error) {
+	container := p.runLint(builder, tool)
+	out, err := container.Stdout(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryLint, "%s failed to run: %w", tool, err)
+	}
+	code, err := container.ExitCode(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryLint, "%s failed to run: %w", tool, err)
+	}
+	p.exportStageArtifacts(ctx, p.tracker.Snapshot().CurrentStage, container)
+	if strings.TrimSpace(out) != "" {
+		p.println(strings.TrimRight(out, "\n"))
+	}
+	if exit := ClassifyExitCode(code); exit.Signal != "" {
+		// Killed midway: the output is incomplete and says nothing about the debt
+		return nil, Errorf(CategoryLint, "%s was %s", tool, exit.Reason)
+	}
+
+	findings := ParseRuffOutput(out)
+	if tool == "mypy" {
+		findings = ParseMypyOutput(out)
+	}
+	findings.ExitCode = code
+	findings.Scope = "full"
+	if p.lintScope.Changed {
+		findings.Scope = "changed"
+	}
+	label := map[string]string{"ruff": "ruff findings", "mypy": "mypy errors"}[tool]
+
+	ratchetFailed := false
+	if findings.Scope == "full" {
+		if baseline, ok := p.lintBaseline(tool); ok {
+			findings.Previous = &baseline
+			p.printf("📊 %s: %d → %d%s\n", label, baseline, findings.Total, formatTrend(findings.Total-baseline))
+			ratchetFailed = p.cfg.LintRatchet && findings.Total > baseline
+		} else {
+			p.printf("📊 %s: %d (no previous run to compare with)\n", label, findings.Total)
+		}
+	} else {
+		p.printf("📊 %s: %d in the changed files (not compared with the history)\n", label, findings.Total)
+	}
+	if top := findings.TopCodes(5); len(top) > 0 {
+		p.printf("   Most frequent: %s\n", strings.Join(top, ", "))
+	}
+	p.report.Lint = append(p.report.Lint, findings)
+
+	if ratchetFailed {
+		// Not recorded, so the next run is still held to the old baseline
+		return nil, Errorf(CategoryLint, "%s increased from %d to %d (LINT_RATCHET=true)", label, *findings.Previous, findings.Total)
+	}
+	if findings.Scope == "full" {
+		if p.lintRun.Findings == nil {
+			p.lintRun.Findings = map[string]map[string]int{}
+		}
+		p.lintRun.Findings[tool] = findings.Codes
+	}
+	if code != 0 {
+		return nil, Errorf(CategoryLint, "%s reported %d finding(s) (exit code %d)", tool, findings.Total, code)
+	}
+	return p.asRoot(container).WithoutDirectory(ContainerArtifactsDir), nil
+}
+
+// formatTrend is the " (-5)" suffix of a count change.
+func formatTrend(delta int) string {
+	switch {
+	case delta > 0:
+		return fmt.Sprintf(" (+%d)", delta)
+	case delta < 0:
+		return fmt.Sprintf(" (%d)", delta)
+	}
+	return " (unchanged)"
+}
+
+// lintBaseline is the previous total of tool on this branch, falling back
+// to the main branch. An unreadable history only warns.
+func (p *Pipeline) lintBaseline(tool string) (int, bool) {
+	if p.lintHistory == nil {
+		history, err := LoadLintHistory(p.cfg.LintHistoryFile)
+		if err != nil {
+			p.warnf("lint-history", "   ⚠️  Could not load lint history: %v\n", err)
+			history = &LintHistory{Version: lintHistoryVersion}
+		}
+		p.lintHistory = history
+	}
+	return p.lintHistory.Baseline(tool, gitRefName(p.cfg), mainBranch)
+}
+
+// recordLintHistory appends this run's full-repository finding counts to
+// the lint history.
+func (p *Pipeline) recordLintHistory() {
+	if len(p.lintRun.Findings) == 0 || p.lintHistory == nil {
+		return
+	}
+	run := p.lintRun
+	run.FinishedAt, run.Branch, run.Commit = time.Now().UTC(), gitRefName(p.cfg), p.commit
+	if err := SaveLintHistory(p.cfg.LintHistoryFile, p.lintHistory, run); err != nil {
+		p.warnf("lint-history", "⚠️  Could not save lint history: %v\n", err)
+	}
+}
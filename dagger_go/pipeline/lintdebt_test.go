@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseRuffOutput tests counting ruff diagnostics per rule in both output formats
+func TestParseRuffOutput(t *testing.T) {
+	concise := `src/cert_parser/main.py:1:8: F401 [*] ` + "`os`" + ` imported but unused
+src/cert_parser/main.py:40:89: E501 Line too long (101 > 88)
+tests/test_main.py:3:1: F401 [*] ` + "`sys`" + ` imported but unused
+Found 3 errors.
+[*] 2 fixable with the ` + "`--fix`" + ` option.
+`
+	f := ParseRuffOutput(concise)
+	if f.Total != 3 || f.Codes["F401"] != 2 || f.Codes["E501"] != 1 {
+		t.Fatalf("concise: got %+v", f)
+	}
+
+	full := `F401 [*] ` + "`os`" + ` imported but unused
+ --> src/cert_parser/main.py:1:8
+  |
+1 | import os
+  |        ^^
+  |
+help: Remove unused import: ` + "`os`" + `
+
+E501 Line too long (101 > 88)
+  --> src/cert_parser/main.py:40:89
+
+Found 2 errors.
+`
+	f = ParseRuffOutput(full)
+	if f.Total != 2 || f.Codes["F401"] != 1 || f.Codes["E501"] != 1 {
+		t.Fatalf("full: got %+v", f)
+	}
+	if f := ParseRuffOutput("All checks passed!\n"); f.Total != 0 {
+		t.Fatalf("clean run: got %+v", f)
+	}
+	fmt.Println("✅ ruff findings counted per rule")
+}
+
+// TestParseMypyOutput tests counting mypy errors per error code
+func TestParseMypyOutput(t *testing.T) {
+	output := `src/cert_parser/a.py:10: error: Argument 1 to "f" has incompatible type "str"; expected "int"  [arg-type]
+src/cert_parser/a.py:10: note: See https://mypy.rtfd.io
+src/cert_parser/b.py:3:5: error: Function is missing a return type annotation  [no-untyped-def]
+src/cert_parser/b.py:7: error: Argument 2 to "g" has incompatible type "None"; expected "int"  [arg-type]
+src/cert_parser/c.py:1: error: Some error without a code
+Found 4 errors in 3 files (checked 12 source files)
+`
+	f := ParseMypyOutput(output)
+	if f.Total != 4 || f.Codes["arg-type"] != 2 || f.Codes["no-untyped-def"] != 1 || f.Codes["misc"] != 1 {
+		t.Fatalf("got %+v", f)
+	}
+	if top := strings.Join(f.TopCodes(2), " "); top != "arg-type×2 misc×1" {
+		t.Fatalf("TopCodes = %q", top)
+	}
+	fmt.Println("✅ mypy errors counted per code")
+}
+
+// TestLintHistoryBaseline tests the baseline lookup and that the store round-trips and trims
+func TestLintHistoryBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", LintHistoryFile)
+	h, err := LoadLintHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.Baseline("ruff", "main", "main"); ok {
+		t.Fatal("an empty history has no baseline")
+	}
+	runs := []LintRun{
+		{Branch: "main", Findings: map[string]map[string]int{"ruff": {"F401": 40, "E501": 2}, "mypy": {"arg-type": 9}}},
+		{Branch: "feature/x", Findings: map[string]map[string]int{"ruff": {"F401": 30}}},
+		{Branch: "main", Findings: map[string]map[string]int{"ruff": {"F401": 37}}},
+	}
+	for _, run := range runs {
+		if err := SaveLintHistory(path, h, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h, err = LoadLintHistory(path); err != nil || len(h.Runs) != 3 {
+		t.Fatalf("reloaded %d runs, %v", len(h.Runs), err)
+	}
+	cases := []struct {
+		tool, branch string
+		want         int
+	}{
+		{"ruff", "main", 37},
+		{"ruff", "feature/x", 30},
+		{"ruff", "feature/y", 37}, // falls back to main
+		{"mypy", "main", 9},       // newest run that ran mypy
+	}
+	for _, c := range cases {
+		if got, ok := h.Baseline(c.tool, c.branch, "main"); !ok || got != c.want {
+			t.Fatalf("Baseline(%s, %s) = %d, %v; want %d", c.tool, c.branch, got, ok, c.want)
+		}
+	}
+
+	for i := 0; i < DefaultLintHistoryWindow; i++ {
+		h.Runs = append(h.Runs, LintRun{Branch: "main"})
+	}
+	if err := SaveLintHistory(path, h, LintRun{Branch: "main"}); err != nil || len(h.Runs) != DefaultLintHistoryWindow {
+		t.Fatalf("history should be trimmed to %d runs, got %d (%v)", DefaultLintHistoryWindow, len(h.Runs), err)
+	}
+	fmt.Println("✅ Lint history baseline found")
+}
@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultLintChangedMax is how many changed Python files LintChangedOnly
+// checks one by one before falling back to the full repository.
+const DefaultLintChangedMax = 30
+
+// lintConfigFiles change how ruff or mypy judge every file, so changing one
+// means checking everything.
+var lintConfigFiles = map[string]bool{
+	"pyproject.toml": true,
+	"setup.cfg":      true,
+	"ruff.toml":      true,
+	".ruff.toml":     true,
+	"mypy.ini":       true,
+	".mypy.ini":      true,
+}
+
+// LintScope is what the lint and type-check stages check.
+type LintScope struct {
+	Changed bool     // only the changed files; false checks src/ and tests/ in full
+	Reason  string   // why the full repository is checked
+	Ruff    []string // files passed to ruff: changed files under src/ and tests/
+	Mypy    []string // files passed to mypy: changed files under src/
+}
+
+// ChangedLintScope narrows lint and type checking to the changed Python
+// files (relative to the project). A changed lint configuration, or more
+// than max changed Python files, checks the full repository instead.
+func ChangedLintScope(changed []string, max int) LintScope {
+	var scope LintScope
+	for _, file := range changed {
+		if lintConfigFiles[file] {
+			return LintScope{Reason: file + " changed"}
+		}
+		if path.Ext(file) != ".py" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(file, "src/"):
+			scope.Ruff = append(scope.Ruff, file)
+			scope.Mypy = append(scope.Mypy, file)
+		case strings.HasPrefix(file, "tests/"):
+			scope.Ruff = append(scope.Ruff, file)
+		}
+	}
+	if len(scope.Ruff) > max {
+		return LintScope{Reason: fmt.Sprintf("%d changed Python files (more than LINT_CHANGED_MAX=%d)", len(scope.Ruff), max)}
+	}
+	sort.Strings(scope.Ruff)
+	sort.Strings(scope.Mypy)
+	scope.Changed = true
+	return scope
+}
+
+// selectLintScope compares the cloned source with DiffBase in the same
+// repository and narrows the lint and type-check stages to the changed
+// files. Any problem falls back to the full repository.
+func (p *Pipeline) selectLintScope(ctx context.Context, repo *dagger.GitRepository, source *dagger.Directory) {
+	cfg := p.cfg
+	p.printf("🎯 Selecting changed files for lint and type check (LINT_CHANGED_ONLY=true, DIFF_BASE=%s)\n", cfg.DiffBase)
+	if cfg.GitBranch == cfg.DiffBase {
+		p.printf("   🔁 Building %s itself — checking the full repository\n", cfg.DiffBase)
+		return
+	}
+
+	base := repo.Ref(cfg.DiffBase).Tree()
+	if cfg.ProjectDir != "" {
+		base = base.Directory(cfg.ProjectDir)
+	}
+	changes := source.Changes(base)
+	added, err := changes.AddedPaths(ctx)
+	var modified []string
+	if err == nil {
+		modified, err = changes.ModifiedPaths(ctx)
+	}
+	if err != nil {
+		p.warnf("lint-diff-base", "   ⚠️  Could not compare with %s (%v) — checking the full repository\n", cfg.DiffBase, err)
+		return
+	}
+
+	scope := ChangedLintScope(append(added, modified...), cfg.LintChangedMax)
+	if !scope.Changed {
+		p.printf("   🔁 %s — checking the full repository\n", scope.Reason)
+		return
+	}
+	p.lintScope = scope
+	p.printf("   • %d changed Python file(s) for ruff, %d for mypy\n", len(scope.Ruff), len(scope.Mypy))
+}
+
+// lintTools maps ruff and mypy to their command, their stage container
+// and the directories the full-repository command checks.
+var lintTools = map[string]struct {
+	command func(files []string) []string
+	run     func(p *Pipeline, builder *dagger.Container, files []string) *dagger.Container
+	dirs    []string
+}{
+	"ruff": {LintCommand, (*Pipeline).Lint, []string{"src", "tests"}},
+	"mypy": {TypeCheckCommand, (*Pipeline).TypeCheck, []string{"src"}},
+}
+
+// lintFiles is the selection tool checks: nil for the full repository, or
+// the changed files. ok is false when none of the changed files is checked
+// by the tool.
+func (p *Pipeline) lintFiles(tool string) (files []string, ok bool) {
+	if !p.lintScope.Changed {
+		return nil, true
+	}
+	files = p.lintScope.Ruff
+	if tool == "mypy" {
+		files = p.lintScope.Mypy
+	}
+	return files, len(files) > 0
+}
+
+// lintCommand is the ruff or mypy command for the selected scope, or nil
+// when none of the changed files is checked by the tool.
+func (p *Pipeline) lintCommand(tool string) []string {
+	files, ok := p.lintFiles(tool)
+	if !ok {
+		return nil
+	}
+	return lintTools[tool].command(files)
+}
+
+// runLint runs tool in builder over the selected scope, through Lint or
+// TypeCheck.
+func (p *Pipeline) runLint(builder *dagger.Container, tool string) *dagger.Container {
+	files, _ := p.lintFiles(tool)
+	return lintTools[tool].run(p, builder, files)
+}
+
+// lintMode describes the mode and how many files the tool checks, so a
+// passing stage can be interpreted.
+func (p *Pipeline) lintMode(ctx context.Context, source *dagger.Directory, tool string) string {
+	if p.lintScope.Changed {
+		n := len(p.lintScope.Ruff)
+		if tool == "mypy" {
+			n = len(p.lintScope.Mypy)
+		}
+		return fmt.Sprintf("changed files only: %d file(s) changed since %s", n, p.cfg.DiffBase)
+	}
+	dirs := lintTools[tool].dirs
+	count := 0
+	for _, dir := range dirs {
+		if matches, err := source.Glob(ctx, dir+"/**/*.py"); err == nil {
+			count += len(matches)
+		}
+	}
+	return fmt.Sprintf("full repository: %d file(s) in %s/", count, strings.Join(dirs, "/ and "))
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestChangedLintScope tests narrowing ruff and mypy to changed files
+func TestChangedLintScope(t *testing.T) {
+	scope := ChangedLintScope([]string{"tests/unit/test_parser.py", "src/cert_parser/parser.py", "README.md", "scripts/tool.py"}, 30)
+	if !scope.Changed || scope.Reason != "" {
+		t.Fatalf("expected a changed-files scope, got %+v", scope)
+	}
+	if !reflect.DeepEqual(scope.Ruff, []string{"src/cert_parser/parser.py", "tests/unit/test_parser.py"}) || !reflect.DeepEqual(scope.Mypy, []string{"src/cert_parser/parser.py"}) {
+		t.Fatalf("unexpected files: %+v", scope)
+	}
+
+	if scope := ChangedLintScope([]string{"src/a.py", "pyproject.toml"}, 30); scope.Changed || !strings.Contains(scope.Reason, "pyproject.toml") {
+		t.Fatalf("a config change should check everything, got %+v", scope)
+	}
+	if scope := ChangedLintScope([]string{"src/a.py", "src/b.py", "tests/test_a.py"}, 2); scope.Changed || !strings.Contains(scope.Reason, "LINT_CHANGED_MAX=2") {
+		t.Fatalf("too many files should check everything, got %+v", scope)
+	}
+
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.lintCommand("mypy"), TypeCheckCommand(nil)) {
+		t.Fatalf("full scope should run the usual mypy command, got %v", p.lintCommand("mypy"))
+	}
+	p.lintScope = ChangedLintScope([]string{"tests/test_a.py"}, 30)
+	if cmd := p.lintCommand("ruff"); !reflect.DeepEqual(cmd, []string{"ruff", "check", "tests/test_a.py"}) {
+		t.Fatalf("unexpected ruff command %v", cmd)
+	}
+	if cmd := p.lintCommand("mypy"); cmd != nil {
+		t.Fatalf("no changed src/ files should skip mypy, got %v", cmd)
+	}
+	p.lintScope = ChangedLintScope([]string{"src/a.py"}, 30)
+	if cmd := p.lintCommand("mypy"); !reflect.DeepEqual(cmd, []string{"mypy", "--strict", "--follow-imports=silent", "src/a.py"}) {
+		t.Fatalf("unexpected mypy command %v", cmd)
+	}
+	fmt.Println("✅ Lint scope narrowed to changed files")
+}
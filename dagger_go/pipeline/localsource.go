@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// LocalSourceExcludes are left out when a host directory is the source:
+// the host virtualenv, git metadata, bytecode and this pipeline module.
+var LocalSourceExcludes = []string{".venv", ".git", "**/__pycache__", "dagger_go"}
+
+// LocalCommit stands in for the commit of a local source that is not a git
+// checkout.
+const LocalCommit = "local"
+
+// LocalSourceCommit returns the HEAD commit of the git checkout at dir, or
+// LocalCommit when dir is not one, and whether the checkout has changes
+// that are not committed.
+func LocalSourceCommit(ctx context.Context, dir string) (commit string, dirty bool) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return LocalCommit, false
+	}
+	status := exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=normal")
+	status.Dir = dir
+	changes, err := status.Output()
+	return strings.TrimSpace(string(out)), err == nil && len(strings.TrimSpace(string(changes))) > 0
+}
+
+// localSource is LocalSource as the run's source, with the commit the image
+// is tagged with. Nothing is cloned; uncommitted changes are built as they
+// are, which a publishing run warns about.
+func (p *Pipeline) localSource(ctx context.Context, client *dagger.Client) (*dagger.Directory, string, error) {
+	cfg := p.cfg
+	root, err := filepath.Abs(cfg.LocalSource)
+	if err != nil {
+		return nil, "", Errorf(CategoryConfig, "LocalSource: %w", err)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, "", Errorf(CategoryConfig, "LocalSource %s is not a directory", root)
+	}
+	p.printf("\n📂 Building local source: %s (LOCAL_SOURCE — nothing is cloned)\n", root)
+
+	commit, dirty := LocalSourceCommit(ctx, root)
+	switch {
+	case commit == LocalCommit:
+		p.println("   ℹ️  Not a git checkout — the image is tagged with \"local\"")
+	case dirty:
+		p.printf("   ℹ️  Uncommitted changes are included; %s is the last commit\n", shortCommit(commit))
+		if cfg.RunPublish || cfg.PublishPRImage {
+			p.warnf("local-source", "   ⚠️  Publishing uncommitted changes: the image claims commit %s but was built from the working tree\n", shortCommit(commit))
+		}
+	}
+	return client.Host().Directory(root, dagger.HostDirectoryOpts{Exclude: LocalSourceExcludes}), commit, nil
+}
+
+// validateLocalSource rejects the options that need a clone.
+func validateLocalSource(cfg Config) error {
+	if cfg.LocalSource == "" {
+		return nil
+	}
+	if cfg.ReleaseVersion != "" {
+		return fmt.Errorf("ReleaseVersion pushes a release commit and builds its tag, which needs a clone, not LocalSource")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLocalSourceCommit tests the commit a local source is tagged with
+func TestLocalSourceCommit(t *testing.T) {
+	ctx := context.Background()
+	if commit, dirty := LocalSourceCommit(ctx, t.TempDir()); commit != LocalCommit || dirty {
+		t.Fatalf("a directory outside git should be %q, got %q (dirty %v)", LocalCommit, commit, dirty)
+	}
+
+	root := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Skipf("git unavailable: %v %s", err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(root, "pyproject.toml"), []byte("[project]\nname = \"cert-parser\"\n"), 0o644)
+	git("add", ".")
+	git("-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "-m", "init")
+	head := git("rev-parse", "HEAD")
+
+	if commit, dirty := LocalSourceCommit(ctx, root); commit != head || dirty {
+		t.Fatalf("expected %s, clean; got %s (dirty %v)", head, commit, dirty)
+	}
+	os.WriteFile(filepath.Join(root, "new.py"), []byte("x = 1\n"), 0o644)
+	if commit, dirty := LocalSourceCommit(ctx, root); commit != head || !dirty {
+		t.Fatalf("expected %s, dirty; got %s (dirty %v)", head, commit, dirty)
+	}
+	fmt.Println("✅ Local source tagged with its HEAD, or local outside git")
+}
+
+// TestLocalSourceConfig tests the defaults and checks of a local source build
+func TestLocalSourceConfig(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", LocalSource: "/src/cert-parser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.ProjectRoot != "/src/cert-parser" {
+		t.Fatalf("host-run tests should use the local source, got %q", p.cfg.ProjectRoot)
+	}
+	p, err = New(Config{RepoName: "cert-parser", GitUser: "org", LocalSource: "/src/cert-parser", ProjectRoot: "/checkout"})
+	if err != nil || p.cfg.ProjectRoot != "/checkout" {
+		t.Fatalf("an explicit ProjectRoot should win: %v", err)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", LocalSource: "..", ReleaseVersion: "1.2.0", GitToken: "t"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+
+	flags, err := parseOptionFlags(t, BinaryCorporate, "--source-dir", "..")
+	if err != nil || flags.Values()["local-source"] != ".." {
+		t.Fatalf("--source-dir should set LOCAL_SOURCE: %v, %v", err, flags.Values())
+	}
+	fmt.Println("✅ Local source builds configured")
+}
@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mirrorProbeTimeout bounds the manifest request to each mirror.
+const mirrorProbeTimeout = 10 * time.Second
+
+// engineCADir is where the Dagger engine image installs extra CA
+// certificates from at startup.
+const engineCADir = "/usr/local/share/ca-certificates"
+
+// Mirror states (MirrorStatus.Status).
+const (
+	MirrorOK          = "ok"
+	MirrorInternalCA  = "internal-ca"  // reachable, with a certificate only the CACertPaths trust
+	MirrorUntrusted   = "untrusted"    // certificate not trusted even with CACertPaths
+	MirrorUnreachable = "unreachable"  // no connection, DNS failure or timeout
+	MirrorNotFound    = "not-found"    // reachable, but the registry has no such image
+	MirrorError       = "error"        // any other registry response
+	MirrorEngineNoCA  = "engine-no-ca" // internal CA and a default engine that cannot know it
+)
+
+// MirrorStatus is the outcome of fetching a manifest from one configured
+// mirror, recorded in the report.
+type MirrorStatus struct {
+	Mirror string `json:"mirror"` // the REGISTRY_MIRRORS entry or BASE_IMAGE_MIRROR
+	Probe  string `json:"probe"`  // the image (or /v2/ endpoint) that was fetched
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Failed reports whether the engine will not be able to pull from the
+// mirror.
+func (s MirrorStatus) Failed() bool {
+	return s.Status != MirrorOK && s.Status != MirrorInternalCA
+}
+
+// ParseRegistryMirrors parses REGISTRY_MIRRORS: comma-separated image
+// references on a mirror, e.g. registry.corp.local/python/python:3.14-slim,
+// or bare registry hosts such as registry.corp.local:5000.
+func ParseRegistryMirrors(value string) ([]string, error) {
+	var mirrors []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "://") {
+			return nil, fmt.Errorf("%q: give the registry host or image without a scheme", entry)
+		}
+		if !isRegistryHost(entry) && IsDockerHubRef(entry) {
+			return nil, fmt.Errorf("%q is not on a mirror: start it with the registry host, e.g. registry.corp.local/%s", entry, entry)
+		}
+		mirrors = append(mirrors, entry)
+	}
+	return mirrors, nil
+}
+
+// isRegistryHost reports whether entry is a bare registry host rather than
+// an image reference: registry.corp.local:5000, but not python:3.14-slim.
+func isRegistryHost(entry string) bool {
+	if strings.Contains(entry, "/") {
+		return false
+	}
+	host, port, hasPort := strings.Cut(entry, ":")
+	if hasPort {
+		if _, err := strconv.Atoi(port); err != nil {
+			return false
+		}
+	}
+	return strings.Contains(host, ".") || host == "localhost"
+}
+
+// mirrorHost is the registry host of a mirror entry or prefix.
+func mirrorHost(mirror string) string {
+	host, _, _ := strings.Cut(mirror, "/")
+	return host
+}
+
+// mirrorProbes are the mirrors of cfg with what to fetch from each: the
+// REGISTRY_MIRRORS entries, and the base image through BaseImageMirror.
+func mirrorProbes(cfg Config) []MirrorStatus {
+	var probes []MirrorStatus
+	if cfg.BaseImageMirror != "" {
+		probes = append(probes, MirrorStatus{Mirror: cfg.BaseImageMirror, Probe: MirrorImage(baseImageRef(cfg), cfg.BaseImageMirror)})
+	}
+	for _, mirror := range cfg.RegistryMirrors {
+		probe := mirror
+		if isRegistryHost(mirror) {
+			probe = "https://" + mirror + "/v2/"
+		}
+		probes = append(probes, MirrorStatus{Mirror: mirror, Probe: probe})
+	}
+	return probes
+}
+
+// probeRegistryAPI checks that a registry answers its /v2/ endpoint; an
+// authentication challenge counts as an answer.
+func probeRegistryAPI(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("%s: registry returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// fetchProbe fetches the manifest (or /v2/ endpoint) of one probe.
+func fetchProbe(ctx context.Context, client *http.Client, probe string) error {
+	ctx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+	defer cancel()
+	if strings.HasPrefix(probe, "https://") {
+		return probeRegistryAPI(ctx, client, probe)
+	}
+	_, err := ResolveDigest(ctx, client, probe)
+	return err
+}
+
+// isUnknownAuthority reports whether err is a TLS certificate signed by a
+// CA the client does not trust.
+func isUnknownAuthority(err error) bool {
+	var unknown x509.UnknownAuthorityError
+	var verify *tls.CertificateVerificationError
+	return errors.As(err, &unknown) || errors.As(err, &verify) && errors.As(verify.Err, &unknown)
+}
+
+// ProbeRegistryMirrors fetches a manifest from each mirror with client,
+// which trusts the CACertPaths. A certificate client trusts is checked
+// again with systemClient, which does not: when only the CACertPaths trust
+// it, the mirror has an internal CA the engine has to be given as well.
+func ProbeRegistryMirrors(ctx context.Context, client, systemClient *http.Client, probes []MirrorStatus) []MirrorStatus {
+	results := make([]MirrorStatus, len(probes))
+	for i, probe := range probes {
+		results[i] = probe
+		err := fetchProbe(ctx, client, probe.Probe)
+		switch {
+		case err == nil:
+			results[i].Status = MirrorOK
+			if systemClient != nil && isUnknownAuthority(fetchProbe(ctx, systemClient, probe.Probe)) {
+				results[i].Status = MirrorInternalCA
+			}
+			continue
+		case isUnknownAuthority(err):
+			results[i].Status = MirrorUntrusted
+		case strings.Contains(err.Error(), "404 Not Found"):
+			results[i].Status = MirrorNotFound
+		case isNetworkError(err):
+			results[i].Status = MirrorUnreachable
+		default:
+			results[i].Status = MirrorError
+		}
+		results[i].Detail = err.Error()
+	}
+	return results
+}
+
+// isNetworkError reports whether err is a failure to reach the server at
+// all rather than a response from it.
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// mirrorAdvice is what to do about a mirror in the given state.
+func mirrorAdvice(status MirrorStatus) string {
+	switch status.Status {
+	case MirrorUntrusted:
+		return "its certificate is not signed by a trusted CA: add the corporate CA (CA_CERTIFICATES_PATH on the corporate binary) and give it to the engine (engine-config)"
+	case MirrorEngineNoCA:
+		return "its CA is trusted here but not by the Dagger engine, which pulls the images itself: start an engine that trusts it (the engine-config command) and set DAGGER_RUNNER_HOST"
+	case MirrorUnreachable:
+		return "check the host name, the VPN and HTTPS_PROXY"
+	case MirrorNotFound:
+		return "the mirror does not have this image: check the path, or ask for it to be mirrored"
+	}
+	return "check the mirror and its credentials"
+}
+
+// checkRegistryMirrors fetches a manifest from every configured mirror
+// before anything is pulled, so a mirror the engine cannot use fails with
+// one actionable error instead of a containerd TLS error inside From. A
+// mirror with an internal CA fails too when the engine was provisioned by
+// the SDK (no DAGGER_RUNNER_HOST), since that engine only has public CAs.
+func (p *Pipeline) checkRegistryMirrors(ctx context.Context) error {
+	cfg := p.cfg
+	probes := mirrorProbes(cfg)
+	if len(probes) == 0 || cfg.HTTPClient == nil {
+		return nil
+	}
+	var systemClient *http.Client
+	if len(cfg.CACertPaths) > 0 {
+		if client, err := NewHTTPClient(HTTPClientConfig{ProxyURL: cfg.ProxyURL}); err == nil {
+			systemClient = client
+		}
+	}
+	p.println("\n🪞 Checking registry mirrors...")
+	results := ProbeRegistryMirrors(ctx, cfg.HTTPClient, systemClient, probes)
+	var failed []MirrorStatus
+	for i, result := range results {
+		if result.Status == MirrorInternalCA && !cfg.RunnerHost.IsSet() {
+			results[i].Status = MirrorEngineNoCA
+		}
+		mark := "✅"
+		if results[i].Failed() {
+			mark = "❌"
+			failed = append(failed, results[i])
+		}
+		p.printf("   %s %s (%s): %s\n", mark, result.Mirror, result.Probe, results[i].Status)
+	}
+	p.report.RegistryMirrors = results
+	if len(failed) == 0 {
+		return nil
+	}
+	for _, status := range failed {
+		p.printf("\n⚠️  Registry mirror %s: %s\n", status.Mirror, mirrorAdvice(status))
+		if status.Detail != "" {
+			p.printf("   %s\n", status.Detail)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return Errorf(CategoryEngine, "registry mirror %s is not usable by the Dagger engine (%s)", failed[0].Mirror, failed[0].Status)
+}
+
+// EngineSetup is what WriteEngineConfig sets the engine up for.
+type EngineSetup struct {
+	BaseImageMirror string   // Docker Hub pulls go through it
+	RegistryMirrors []string // hosts whose certificates the engine must trust
+	CACertPaths     []string // CA files or directories installed into the engine
+	Name            string   // engine container name (default: cert-parser-dagger-engine)
+}
+
+// WriteEngineConfig writes an engine.toml for a Dagger engine that pulls
+// Docker Hub images through the mirror and trusts the CAs, and the docker
+// run command that starts the engine with it and the CAs mounted where the
+// engine image installs them from.
+func WriteEngineConfig(w io.Writer, setup EngineSetup) {
+	name := setup.Name
+	if name == "" {
+		name = "cert-parser-dagger-engine"
+	}
+	var caFiles, mounts []string
+	for i, path := range setup.CACertPaths {
+		target := fmt.Sprintf("%s/cert-parser-%d.crt", engineCADir, i)
+		if filepath.Ext(path) == "" {
+			// A directory: the engine installs the .crt files below it
+			target = fmt.Sprintf("%s/cert-parser-%d", engineCADir, i)
+		} else {
+			caFiles = append(caFiles, target)
+		}
+		mounts = append(mounts, fmt.Sprintf("-v %s:%s:ro", shellQuote(path), target))
+	}
+	var hosts []string
+	if setup.BaseImageMirror != "" {
+		hosts = append(hosts, mirrorHost(setup.BaseImageMirror))
+	}
+	for _, mirror := range setup.RegistryMirrors {
+		if host := mirrorHost(mirror); !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	fmt.Fprintln(w, "# engine.toml — save it next to where docker run is started")
+	if setup.BaseImageMirror != "" {
+		fmt.Fprintf(w, "[registry.\"docker.io\"]\n  mirrors = [%q]\n\n", strings.TrimSuffix(setup.BaseImageMirror, "/"))
+	}
+	if len(caFiles) > 0 {
+		quoted := make([]string, len(caFiles))
+		for i, file := range caFiles {
+			quoted[i] = fmt.Sprintf("%q", file)
+		}
+		for _, host := range hosts {
+			fmt.Fprintf(w, "[registry.%q]\n  ca = [%s]\n\n", host, strings.Join(quoted, ", "))
+		}
+	}
+	fmt.Fprintln(w, "# Start the engine and point the pipeline at it")
+	fmt.Fprintf(w, "docker run -d --restart always --name %s --privileged \\\n", name)
+	fmt.Fprintln(w, "  -v \"$PWD/engine.toml:/etc/dagger/engine.toml:ro\" \\")
+	for _, mount := range mounts {
+		fmt.Fprintf(w, "  %s \\\n", mount)
+	}
+	fmt.Fprintf(w, "  registry.dagger.io/engine:v%s\n", SDKVersion)
+	fmt.Fprintf(w, "export DAGGER_RUNNER_HOST=docker-container://%s\n", name)
+}
@@ -0,0 +1,147 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mirrorServer is a registry with a self-signed certificate that has one
+// manifest, python/python:3.14-slim.
+func mirrorServer(t *testing.T) (*httptest.Server, string) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+		case "/v2/python/python/manifests/3.14-slim":
+			w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("ab", 32))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, strings.TrimPrefix(server.URL, "https://")
+}
+
+// TestParseRegistryMirrors tests parsing REGISTRY_MIRRORS
+func TestParseRegistryMirrors(t *testing.T) {
+	mirrors, err := ParseRegistryMirrors(" registry.corp.local/python/python:3.14-slim, ,registry.corp.local:5000,localhost:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mirrors) != 3 || mirrors[1] != "registry.corp.local:5000" {
+		t.Fatalf("unexpected mirrors %q", mirrors)
+	}
+	for _, bad := range []string{"https://registry.corp.local", "python:3.14-slim", "library/python"} {
+		if _, err := ParseRegistryMirrors(bad); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+	fmt.Println("✅ Registry mirrors parsed")
+}
+
+// TestProbeRegistryMirrors tests the per-mirror status of the manifest fetch
+func TestProbeRegistryMirrors(t *testing.T) {
+	server, host := mirrorServer(t)
+	closed, closedHost := mirrorServer(t)
+	closed.Close()
+	probes := mirrorProbes(Config{
+		BaseImageMirror: host + "/dockerhub",
+		RegistryMirrors: []string{host + "/python/python:3.14-slim", host, closedHost},
+	})
+	if probes[0].Probe != host+"/dockerhub/python:3.14-slim" || probes[2].Probe != "https://"+host+"/v2/" {
+		t.Fatalf("unexpected probes %+v", probes)
+	}
+
+	results := ProbeRegistryMirrors(context.Background(), server.Client(), &http.Client{}, probes)
+	want := []string{MirrorNotFound, MirrorInternalCA, MirrorInternalCA, MirrorUnreachable}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Fatalf("%s: status %s, want %s (%s)", result.Probe, result.Status, want[i], result.Detail)
+		}
+	}
+	results = ProbeRegistryMirrors(context.Background(), &http.Client{}, nil, probes[1:2])
+	if results[0].Status != MirrorUntrusted || !results[0].Failed() {
+		t.Fatalf("a certificate the client does not trust should be untrusted: %+v", results[0])
+	}
+	results = ProbeRegistryMirrors(context.Background(), server.Client(), nil, probes[1:2])
+	if results[0].Status != MirrorOK || results[0].Failed() {
+		t.Fatalf("without a system client the mirror should be ok: %+v", results[0])
+	}
+	fmt.Println("✅ Registry mirrors probed")
+}
+
+// TestCheckRegistryMirrors tests that a mirror with an internal CA fails a
+// run on the default engine
+func TestCheckRegistryMirrors(t *testing.T) {
+	server, host := mirrorServer(t)
+	caFile := filepath.Join(t.TempDir(), "corp-ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, runner := range []string{"", "docker-container://corp-engine"} {
+		runnerHost, err := ParseRunnerHost(runner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		p, err := New(Config{
+			RepoName: "cert-parser", GitUser: "org", CACertPaths: []string{caFile},
+			RegistryMirrors: []string{host + "/python/python:3.14-slim"}, RunnerHost: runnerHost, Output: &out,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.checkRegistryMirrors(context.Background())
+		status := p.report.RegistryMirrors[0].Status
+		if runner == "" {
+			if err == nil || CategoryOf(err) != CategoryEngine || status != MirrorEngineNoCA || !strings.Contains(out.String(), "engine-config") {
+				t.Fatalf("the default engine should fail: %v, %s\n%s", err, status, out.String())
+			}
+			continue
+		}
+		if err != nil || status != MirrorInternalCA {
+			t.Fatalf("an engine set up for the CA should pass: %v, %s\n%s", err, status, out.String())
+		}
+	}
+	fmt.Println("✅ Registry mirror preflight fails on the default engine")
+}
+
+// TestWriteEngineConfig tests the engine.toml and docker run command
+func TestWriteEngineConfig(t *testing.T) {
+	var out bytes.Buffer
+	WriteEngineConfig(&out, EngineSetup{
+		BaseImageMirror: "registry.corp.local/dockerhub/",
+		RegistryMirrors: []string{"registry.corp.local/python/python:3.14-slim", "harbor.corp.local"},
+		CACertPaths:     []string{"/etc/corp/root ca.pem", "/etc/corp/certs"},
+	})
+	got := out.String()
+	for _, want := range []string{
+		"[registry.\"docker.io\"]\n  mirrors = [\"registry.corp.local/dockerhub\"]",
+		"[registry.\"registry.corp.local\"]\n  ca = [\"/usr/local/share/ca-certificates/cert-parser-0.crt\"]",
+		"[registry.\"harbor.corp.local\"]",
+		"-v '/etc/corp/root ca.pem':/usr/local/share/ca-certificates/cert-parser-0.crt:ro",
+		"-v /etc/corp/certs:/usr/local/share/ca-certificates/cert-parser-1:ro",
+		"registry.dagger.io/engine:v" + SDKVersion,
+		"export DAGGER_RUNNER_HOST=docker-container://cert-parser-dagger-engine",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "[registry.\"registry.corp.local\"]") != 1 {
+		t.Fatalf("each host should be configured once:\n%s", got)
+	}
+	fmt.Println("✅ Engine config written")
+}
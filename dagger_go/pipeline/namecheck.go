@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NameCheck modes (NAME_CHECK).
+const (
+	NameCheckWarn = "warn"
+	NameCheckFail = "fail"
+	NameCheckOff  = "off"
+)
+
+// nameSeparators are the runs of characters that do not tell two names
+// apart: cert_parser, cert.parser and Cert-Parser are the same project.
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// ParseNameCheck parses NAME_CHECK; empty is NameCheckWarn.
+func ParseNameCheck(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "":
+		return NameCheckWarn, nil
+	case NameCheckWarn, NameCheckFail, NameCheckOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown name check %q (use %s, %s or %s)", value, NameCheckWarn, NameCheckFail, NameCheckOff)
+}
+
+// NameCheckResult compares the project name from pyproject.toml with the
+// repository it was built from and the image it is published as, recorded
+// in the report.
+type NameCheckResult struct {
+	Mode        string `json:"mode"`
+	ProjectName string `json:"project_name"`
+	// RepoName is the repository name, or the last directory of ProjectDir
+	// in a monorepo
+	RepoName  string `json:"repo_name"`
+	ImageName string `json:"image_name,omitempty"` // explicit ImageName only
+	Match     bool   `json:"match"`
+}
+
+// normalizeProjectName is name in Docker-safe form with separators folded,
+// the form the names are compared in.
+func normalizeProjectName(name string) string {
+	return nameSeparators.ReplaceAllString(DockerSafeName(name), "-")
+}
+
+// CompareProjectNames checks that the project name matches the repository
+// name and, when one is given, the explicit image name.
+func CompareProjectNames(projectName, repoName, imageName string) NameCheckResult {
+	project := normalizeProjectName(projectName)
+	match := project == normalizeProjectName(repoName)
+	if imageName != "" {
+		match = match && project == normalizeProjectName(imageName)
+	}
+	return NameCheckResult{ProjectName: projectName, RepoName: repoName, ImageName: imageName, Match: match}
+}
+
+// checkProjectName compares the project name of pyproject.toml with the
+// repository and ImageName, so a REPO_NAME pointing at the wrong repository
+// does not publish another project's image unnoticed. A mismatch warns, or
+// with NameCheckFail stops the run before the tests.
+func (p *Pipeline) checkProjectName(projectName string) error {
+	cfg := p.cfg
+	if cfg.NameCheck == NameCheckOff {
+		return nil
+	}
+	repoName := cfg.RepoName
+	if cfg.ProjectDir != "" {
+		repoName = path.Base(strings.Trim(cfg.ProjectDir, "/"))
+	}
+	result := CompareProjectNames(projectName, repoName, cfg.ImageName)
+	result.Mode = cfg.NameCheck
+	p.report.NameCheck = &result
+	if result.Match {
+		return nil
+	}
+	image := cfg.ImageName
+	if image == "" {
+		image = "(not set)"
+	}
+	p.warnf("name-mismatch", "\n⚠️  The project does not match where it is built from (NAME_CHECK=%s):\n"+
+		"   pyproject.toml name: %s\n   repository:          %s\n   IMAGE_NAME:          %s\n"+
+		"   Check REPO_NAME and IMAGE_NAME; set NAME_CHECK=off if the names differ on purpose\n",
+		cfg.NameCheck, projectName, repoName, image)
+	if cfg.NameCheck == NameCheckFail {
+		return Errorf(CategoryConfig, "project %q does not match repository %q or IMAGE_NAME %q (NAME_CHECK=fail)", projectName, repoName, cfg.ImageName)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestCompareProjectNames tests the normalized comparison of project, repository and image names
+func TestCompareProjectNames(t *testing.T) {
+	cases := []struct {
+		project, repo, image string
+		match                bool
+	}{
+		{"cert-parser", "cert-parser", "", true},
+		{"cert_parser", "Cert-Parser", "", true},
+		{"cert.parser", "cert-parser", "cert--parser", true},
+		{"cert-parser", "cert-parser", "cert-parser-api", false},
+		{"invoice-service", "cert-parser", "", false},
+	}
+	for _, c := range cases {
+		if got := CompareProjectNames(c.project, c.repo, c.image); got.Match != c.match {
+			t.Fatalf("CompareProjectNames(%q, %q, %q) = %+v", c.project, c.repo, c.image, got)
+		}
+	}
+	for in, want := range map[string]string{"": NameCheckWarn, "FAIL": NameCheckFail, "off": NameCheckOff} {
+		if got, err := ParseNameCheck(in); err != nil || got != want {
+			t.Fatalf("ParseNameCheck(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseNameCheck("strict"); err == nil {
+		t.Fatal("unknown modes should be rejected")
+	}
+	fmt.Println("✅ Project names compared with the repository and image names")
+}
+
+// TestCheckProjectName tests the warning, fail mode and the report entry of a mismatch
+func TestCheckProjectName(t *testing.T) {
+	cases := []struct {
+		mode     string
+		dir      string
+		wantErr  bool
+		wantWarn bool
+	}{
+		{NameCheckWarn, "", false, true},
+		{NameCheckFail, "", true, true},
+		{NameCheckOff, "", false, false},
+		{NameCheckFail, "services/invoice_service", false, false},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		p, err := New(Config{RepoName: "cert-parser", GitUser: "org", NameCheck: c.mode, ProjectDir: c.dir, Output: &out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.checkProjectName("invoice-service")
+		if (err != nil) != c.wantErr || (err != nil && CategoryOf(err) != CategoryConfig) {
+			t.Fatalf("%s %s: err = %v", c.mode, c.dir, err)
+		}
+		if warned := strings.Contains(out.String(), "repository:          cert-parser"); warned != c.wantWarn {
+			t.Fatalf("%s %s: warning = %v in %q", c.mode, c.dir, warned, out.String())
+		}
+		if c.mode != NameCheckOff && (p.report.NameCheck == nil || p.report.NameCheck.Mode != c.mode) {
+			t.Fatalf("%s %s: report = %+v", c.mode, c.dir, p.report.NameCheck)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", NameCheck: "loud"}); err == nil {
+		t.Fatal("an unknown NameCheck should be rejected")
+	}
+	fmt.Println("✅ Project name mismatches warned about, failed on and recorded")
+}
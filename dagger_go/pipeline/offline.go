@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// OfflineBlockedHosts are the internet hosts an offline run never contacts.
+// Subdomains are blocked too: api.github.com, files.pythonhosted.org, ...
+var OfflineBlockedHosts = []string{
+	"github.com", "githubusercontent.com", "ghcr.io",
+	"pypi.org", "pythonhosted.org",
+	"docker.io", "docker.com", "gcr.io", "quay.io",
+}
+
+// testcontainersPrefixEnv makes testcontainers pull its images (PostgreSQL,
+// Ryuk) through a registry prefix instead of Docker Hub.
+const testcontainersPrefixEnv = "TESTCONTAINERS_HUB_IMAGE_NAME_PREFIX"
+
+// hostMatches reports whether host is domain or one of its subdomains.
+func hostMatches(host, domain string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// CheckOfflineHost returns why an offline run may not contact host: it is a
+// blocked internet host, or allow is not empty and host is on none of its
+// domains.
+func CheckOfflineHost(host string, allow []string) error {
+	for _, blocked := range OfflineBlockedHosts {
+		if hostMatches(host, blocked) {
+			return fmt.Errorf("%s is an internet host", host)
+		}
+	}
+	if len(allow) > 0 && !slices.ContainsFunc(allow, func(domain string) bool { return hostMatches(host, domain) }) {
+		return fmt.Errorf("%s is not in OfflineAllowHosts", host)
+	}
+	return nil
+}
+
+// urlHost is the host name of a URL, or of a bare host[:port].
+func urlHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	host, _, _ := strings.Cut(raw, "/")
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// imageHost is the registry host of an image reference; Docker Hub
+// references without a host are docker.io.
+func imageHost(ref string) string {
+	if IsDockerHubRef(ref) {
+		return "docker.io"
+	}
+	return urlHost(ref)
+}
+
+// OfflineProblems lists everything that would make a run with cfg (defaults
+// applied) reach the internet: missing mirror and index overrides, and
+// configured hosts that are internet hosts or not allowed. The stages that
+// inherently need the internet are skipped rather than reported, except
+// when PublishRequires asks for them.
+func OfflineProblems(cfg Config) []string {
+	var problems []string
+	if cfg.BaseImageMirror == "" {
+		problems = append(problems, "BaseImageMirror (BASE_IMAGE_MIRROR) is not set: the base image would be pulled from Docker Hub")
+	}
+	if cfg.PipIndexURL == "" {
+		problems = append(problems, "PipIndexURL (PIP_INDEX_URL) is not set: pip would install from pypi.org")
+	}
+
+	check := func(what, value, host string) {
+		if err := CheckOfflineHost(host, cfg.OfflineAllowHosts); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", what, redactConfigURL(value, false), err))
+		}
+	}
+	checkURL := func(what, value string) {
+		if value != "" {
+			check(what, value, urlHost(value))
+		}
+	}
+	checkURL("Git repository", cfg.GitRepo)
+	if cfg.BaseImageMirror != "" {
+		check("base image mirror", "https://"+cfg.BaseImageMirror, urlHost(cfg.BaseImageMirror))
+	}
+	checkURL("package index", cfg.PipIndexURL)
+	if cfg.RunPublish {
+		check("registry", "https://"+cfg.Registry, urlHost(cfg.Registry))
+	}
+	if cfg.RunPackagePublish && cfg.PackagePublish.Upload {
+		checkURL("package upload", cfg.PackagePublish.IndexURL)
+	}
+	checkURL("deployment webhook", cfg.DeployWebhook)
+	checkURL("deployment verification", cfg.DeployVerifyURL)
+	checkURL("flaky-test history", cfg.FlakyHistoryURL)
+	if cfg.RunBuild && cfg.RunDockle {
+		ref := "goodwithtech/dockle"
+		if tool, ok := LookupToolImage("dockle"); ok {
+			ref = tool.Image
+		}
+		if override, ok := cfg.ToolImages["dockle"]; ok {
+			ref = override
+		}
+		if err := CheckOfflineHost(imageHost(ref), cfg.OfflineAllowHosts); err != nil {
+			problems = append(problems, fmt.Sprintf("dockle image %s: %v (set %sDOCKLE)", ref, err, ToolImageEnvPrefix))
+		}
+	}
+
+	if cfg.RunPublish && !cfg.PublishPolicyOff {
+		for _, stage := range cfg.PublishRequires {
+			if stage == StageDependencyAudit || stage == StageVulnScan {
+				problems = append(problems, fmt.Sprintf("PublishRequires includes %s, which offline runs skip (it needs the internet)", stage))
+			}
+		}
+	}
+	return problems
+}
+
+// offlineTransport refuses host-side requests an offline run may not make,
+// so an unexpected call fails at once instead of waiting for a timeout.
+type offlineTransport struct {
+	base  http.RoundTripper
+	allow []string
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := CheckOfflineHost(req.URL.Hostname(), t.allow); err != nil {
+		return nil, fmt.Errorf("offline mode: refusing %s %s: %w", req.Method, req.URL.Redacted(), err)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// offlineClient returns a copy of client whose requests are checked with
+// CheckOfflineHost.
+func offlineClient(client *http.Client, allow []string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	guarded := *client
+	guarded.Transport = &offlineTransport{base: base, allow: allow}
+	return &guarded
+}
+
+// offlineSkipReason is the skip reason of a stage that needs the internet.
+func offlineSkipReason(why string) string {
+	return "offline mode (OFFLINE_MODE=true): " + why
+}
+
+// offlineHostEnv points testcontainers at the base image mirror in an
+// offline run that starts PostgreSQL itself.
+func (p *Pipeline) offlineHostEnv() []string {
+	if !p.cfg.Offline || p.cfg.DatabaseURL != "" || p.cfg.BaseImageMirror == "" {
+		return nil
+	}
+	return []string{testcontainersPrefixEnv + "=" + strings.TrimSuffix(p.cfg.BaseImageMirror, "/") + "/"}
+}
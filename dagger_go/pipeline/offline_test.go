@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestCheckOfflineHost tests blocking internet hosts and their subdomains and the allow list
+func TestCheckOfflineHost(t *testing.T) {
+	for _, host := range []string{"github.com", "api.github.com", "files.pythonhosted.org", "registry-1.docker.io", "GHCR.IO"} {
+		if CheckOfflineHost(host, nil) == nil {
+			t.Fatalf("%s should be blocked", host)
+		}
+	}
+	if err := CheckOfflineHost("git.corp.local", nil); err != nil {
+		t.Fatalf("an internal host without an allow list should pass: %v", err)
+	}
+	allow := []string{"corp.local"}
+	if err := CheckOfflineHost("pypi.corp.local", allow); err != nil {
+		t.Fatalf("a subdomain of an allowed domain should pass: %v", err)
+	}
+	if err := CheckOfflineHost("notcorp.local", allow); err == nil || !strings.Contains(err.Error(), "OfflineAllowHosts") {
+		t.Fatalf("a host outside the allow list should be rejected, got %v", err)
+	}
+	fmt.Println("✅ Offline host checks")
+}
+
+// TestOfflineConfig tests that New lists everything an offline run is missing and guards the HTTP client
+func TestOfflineConfig(t *testing.T) {
+	_, err := New(Config{RepoName: "cert-parser", GitUser: "org", Offline: true, RunPublish: true, RunBuild: true, RegistryToken: "t", PublishRequires: []string{"audit"}})
+	if err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("offline run with defaults should be a config error, got %v", err)
+	}
+	for _, want := range []string{"BASE_IMAGE_MIRROR", "PIP_INDEX_URL", "Git repository https://github.com/", "registry https://ghcr.io", "PublishRequires includes dependency-audit"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error should mention %q:\n%v", want, err)
+		}
+	}
+
+	p, err := New(Config{
+		RepoName: "cert-parser", GitUser: "org", Offline: true,
+		GitHost: "git.corp.local", BaseImageMirror: "registry.corp.local/dockerhub",
+		PipIndexURL: "https://pypi.corp.local/simple", OfflineAllowHosts: []string{"corp.local"},
+	})
+	if err != nil {
+		t.Fatalf("fully mirrored offline run: %v", err)
+	}
+	if _, err := p.Config().HTTPClient.Get("https://api.github.com/repos/org/cert-parser"); err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Fatalf("request to GitHub should be refused, got %v", err)
+	}
+	if env := p.offlineHostEnv(); len(env) != 1 || env[0] != testcontainersPrefixEnv+"=registry.corp.local/dockerhub/" {
+		t.Fatalf("offlineHostEnv = %q", env)
+	}
+	fmt.Println("✅ Offline mode validated up front")
+}
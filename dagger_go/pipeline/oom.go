@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// ExitOOMKilled is the exit code of a process killed with SIGKILL (128+9),
+// which is what the kernel's OOM killer sends.
+const ExitOOMKilled = 137
+
+// ExitClassification tells a normal non-zero exit from a process killed by
+// a signal; it is recorded on the failed stage.
+type ExitClassification struct {
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"` // e.g. SIGKILL when the exit code is 128+n
+	Reason   string `json:"reason"`
+}
+
+// LikelyOOM reports whether the process was SIGKILLed, most often by the
+// OOM killer.
+func (c *ExitClassification) LikelyOOM() bool {
+	return c != nil && c.Signal == "SIGKILL"
+}
+
+var signalNames = map[int]string{
+	1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 6: "SIGABRT", 7: "SIGBUS",
+	8: "SIGFPE", 9: "SIGKILL", 11: "SIGSEGV", 13: "SIGPIPE", 15: "SIGTERM",
+}
+
+var (
+	exitCodePattern   = regexp.MustCompile(`exit (?:status|code):? (\d+)`)
+	killedByOOMMarker = regexp.MustCompile(`(?i)signal: killed|\boom[- ]?kill|BadSignal`)
+)
+
+// ClassifyExitCode describes a non-zero exit code. Codes above 128 are the
+// shell convention for a process terminated by signal code-128.
+func ClassifyExitCode(code int) *ExitClassification {
+	c := &ExitClassification{ExitCode: code, Reason: fmt.Sprintf("exited with code %d", code)}
+	if code > 128 && code < 160 {
+		name, ok := signalNames[code-128]
+		if !ok {
+			name = fmt.Sprintf("signal %d", code-128)
+		}
+		c.Signal = name
+		c.Reason = fmt.Sprintf("killed by %s (exit code %d)", name, code)
+		if code == ExitOOMKilled {
+			c.Reason += " — likely the OOM killer"
+		}
+	}
+	return c
+}
+
+// ClassifyExit finds the exit code behind a stage error: a Dagger exec
+// error, a pytest run, a host process, or an "exit code N" message. It
+// returns nil when err carries none.
+func ClassifyExit(err error) *ExitClassification {
+	if err == nil {
+		return nil
+	}
+	var execErr *dagger.ExecError
+	var runErr *TestRunError
+	var hostErr *exec.ExitError
+	switch {
+	case errors.As(err, &execErr) && execErr.ExitCode > 0:
+		return ClassifyExitCode(execErr.ExitCode)
+	case errors.As(err, &runErr):
+		return ClassifyExitCode(runErr.ExitCode)
+	case errors.As(err, &hostErr) && hostErr.ExitCode() > 0:
+		return ClassifyExitCode(hostErr.ExitCode())
+	}
+	if killedByOOMMarker.MatchString(err.Error()) {
+		return ClassifyExitCode(ExitOOMKilled)
+	}
+	if m := exitCodePattern.FindAllStringSubmatch(err.Error(), -1); m != nil {
+		if code, _ := strconv.Atoi(m[len(m)-1][1]); code > 0 {
+			return ClassifyExitCode(code)
+		}
+	}
+	return nil
+}
+
+// HostAvailableMemory reads MemAvailable from /proc/meminfo, in bytes, or
+// returns 0 where that is not available (macOS, Windows).
+func HostAvailableMemory() int64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	return parseMemAvailable(string(data))
+}
+
+func parseMemAvailable(meminfo string) int64 {
+	for _, line := range strings.Split(meminfo, "\n") {
+		if rest, ok := strings.CutPrefix(line, "MemAvailable:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) > 0 {
+				kb, _ := strconv.ParseInt(fields[0], 10, 64)
+				return kb * 1024
+			}
+		}
+	}
+	return 0
+}
+
+// memoryLimited wraps a container command so its processes get at most
+// StageMemoryLimit of address space. Dagger has no per-exec memory or CPU
+// limits, so this is the closest hint: an oversized run then fails with a
+// MemoryError in the log instead of the whole engine tripping the OOM killer.
+func (p *Pipeline) memoryLimited(cmd []string) []string {
+	if p.cfg.StageMemoryLimit <= 0 {
+		return cmd
+	}
+	kb := p.cfg.StageMemoryLimit / 1024
+	return append([]string{"sh", "-c", fmt.Sprintf(`ulimit -v %d && exec "$@"`, kb), "sh"}, cmd...)
+}
+
+// explainKilled prints what a signal exit of stage most likely means, with
+// the host's available memory at that moment.
+func (p *Pipeline) explainKilled(stage string, exit *ExitClassification) {
+	if exit == nil || exit.Signal == "" {
+		return
+	}
+	if !exit.LikelyOOM() {
+		p.printf("\n⚠️  Stage %s was %s\n", stage, exit.Reason)
+		return
+	}
+	available := "unknown"
+	if n := HostAvailableMemory(); n > 0 {
+		available = FormatBytes(n)
+	}
+	p.printf("\n⚠️  Stage %s was likely killed by the OOM killer (exit code %d) — consider raising the memory of the runner or the Dagger engine, or lowering parallelism\n", stage, exit.ExitCode)
+	p.printf("   Host memory available now: %s (the engine may run in a VM with less)\n", available)
+	if p.cfg.StageMemoryLimit <= 0 {
+		p.println("   STAGE_MEMORY_LIMIT caps each test and lint process, so an oversized run fails with a MemoryError instead")
+	}
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestClassifyExit tests telling an OOM kill from an ordinary failure
+func TestClassifyExit(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   int
+		signal string
+	}{
+		{Errorf(CategoryLint, "mypy was %s", ClassifyExitCode(137).Reason), 137, "SIGKILL"},
+		{errors.New("process \"mypy --strict src/\" did not complete successfully: exit code: 137"), 137, "SIGKILL"},
+		{errors.New("signal: killed"), 137, "SIGKILL"},
+		{&TestRunError{PytestExit{Stage: "unit", ExitCode: 139}}, 139, "SIGSEGV"},
+		{&TestRunError{PytestExit{Stage: "unit", ExitCode: 1}}, 1, ""},
+		{errors.New("exit status 2"), 2, ""},
+	}
+	for _, c := range cases {
+		got := ClassifyExit(c.err)
+		if got == nil || got.ExitCode != c.code || got.Signal != c.signal {
+			t.Fatalf("ClassifyExit(%q) = %+v, want code %d signal %q", c.err, got, c.code, c.signal)
+		}
+	}
+	if !ClassifyExit(cases[0].err).LikelyOOM() || ClassifyExit(cases[3].err).LikelyOOM() {
+		t.Fatal("only SIGKILL is a likely OOM kill")
+	}
+	if !strings.Contains(ClassifyExitCode(137).Reason, "OOM killer") {
+		t.Fatalf("reason should mention the OOM killer: %s", ClassifyExitCode(137).Reason)
+	}
+	if got := ClassifyExit(errors.New("registry denied the push")); got != nil {
+		t.Fatalf("no exit code should give nil, got %+v", got)
+	}
+	fmt.Println("✅ Exit codes classified")
+}
+
+// TestFailedStageRecordsExit tests that the tracker stores the exit classification on the failed stage
+func TestFailedStageRecordsExit(t *testing.T) {
+	tr := NewTracker("")
+	tr.StartStage("typecheck")
+	tr.Finish(Errorf(CategoryLint, "mypy was %s", ClassifyExitCode(137).Reason))
+	stage := tr.Snapshot().Stages[0]
+	if stage.Exit == nil || stage.Exit.Signal != "SIGKILL" || stage.Exit.ExitCode != 137 {
+		t.Fatalf("got %+v", stage.Exit)
+	}
+	fmt.Println("✅ Failed stage records its exit classification")
+}
+
+// TestMemoryLimited tests wrapping container commands with a memory ceiling
+func TestMemoryLimited(t *testing.T) {
+	p, _ := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	cmd := []string{"mypy", "--strict", "src/"}
+	if got := p.memoryLimited(cmd); strings.Join(got, " ") != "mypy --strict src/" {
+		t.Fatalf("no limit should leave the command alone, got %q", got)
+	}
+	p, _ = New(Config{RepoName: "cert-parser", GitUser: "org", StageMemoryLimit: 3 << 30})
+	got := p.memoryLimited(cmd)
+	want := []string{"sh", "-c", `ulimit -v 3145728 && exec "$@"`, "sh", "mypy", "--strict", "src/"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if n := parseMemAvailable("MemTotal:  8000000 kB\nMemAvailable:  1048576 kB\n"); n != 1<<30 {
+		t.Fatalf("MemAvailable = %d", n)
+	}
+	fmt.Println("✅ Container commands get the memory ceiling")
+}
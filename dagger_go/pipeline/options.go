@@ -0,0 +1,498 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Modes of the binaries an option can apply to.
+const (
+	ModeRun     = "run"
+	ModeWarmup  = "warmup"
+	ModeCleanup = "cleanup"
+	ModeWatch   = "watch"
+)
+
+// Binaries an option can apply to; an option without Binaries applies to
+// both.
+const (
+	BinaryStandard  = "standard"
+	BinaryCorporate = "corporate"
+)
+
+// ConfigSchemaVersion is the version of the config-schema document.
+const ConfigSchemaVersion = 1
+
+// Option is one environment variable of the binaries.
+type Option struct {
+	Env         string   `json:"env"`
+	Field       string   `json:"config_field,omitempty"` // Config field it sets, "Outer.Field" for nested ones
+	Flag        string   `json:"flag,omitempty"`
+	Type        string   `json:"type"` // bool, string, int, float, duration, size, list, enum, path, url or secret
+	Default     string   `json:"default,omitempty"`
+	Description string   `json:"description"`
+	Modes       []string `json:"modes"`
+	Binaries    []string `json:"binaries,omitempty"`
+}
+
+// Name is the option's name in documents and flags: the variable in lower
+// case with dashes, run-unit-tests for RUN_UNIT_TESTS.
+func (o Option) Name() string {
+	return strings.ToLower(strings.ReplaceAll(o.Env, "_", "-"))
+}
+
+// prefix is the variable prefix of a family of options such as
+// TOOL_IMAGE_<NAME>, or "" for a single variable.
+func (o Option) prefix() string {
+	prefix, _, found := strings.Cut(o.Env, "<")
+	if !found {
+		return ""
+	}
+	return prefix
+}
+
+var (
+	allModes      = []string{ModeRun, ModeWarmup, ModeCleanup, ModeWatch}
+	runModes      = []string{ModeRun}
+	imageModes    = []string{ModeRun, ModeWarmup, ModeWatch}
+	corporateOnly = []string{BinaryCorporate}
+)
+
+// Options is the registry of the binaries' environment variables, in
+// documentation order. The binaries read only variables listed here;
+// ConfigFromEnv sets the Config field of each option that has one, and a
+// test keeps the table and the mains in step.
+var Options = []Option{
+	// Repository & registry
+	{Env: "USERNAME", Field: "GitUser", Type: "string", Default: "the workflow's repository owner in GitHub Actions", Description: "owner of the repository and the image", Modes: runModes},
+	{Env: "REPO_NAME", Field: "RepoName", Type: "string", Default: "the workflow's repository in GitHub Actions", Description: "repository to clone", Modes: runModes},
+	{Env: "CR_PAT", Field: "RegistryToken", Type: "secret", Description: "registry and git token; required when publishing outside GitHub Actions", Modes: runModes},
+	{Env: "GITHUB_TOKEN", Field: "RegistryToken", Type: "secret", Description: "the workflow's token, used without CR_PAT in GitHub Actions", Modes: runModes},
+	{Env: "GIT_HOST", Field: "GitHost", Type: "string", Default: "github.com", Description: "git server to clone from", Modes: runModes},
+	{Env: "REGISTRY_HOST", Field: "Registry", Type: "string", Default: "ghcr.io", Description: "container registry to publish to, e.g. docker.io, harbor.corp.local or <account>.dkr.ecr.<region>.amazonaws.com", Modes: runModes},
+	{Env: "REGISTRY", Field: "Registry", Type: "string", Default: "ghcr.io", Description: "older name of REGISTRY_HOST", Modes: runModes},
+	{Env: "REGISTRY_NAMESPACE", Field: "RegistryNamespace", Type: "string", Default: "USERNAME", Description: "namespace the image is published under, e.g. a Docker Hub user or Harbor project; / for none (ECR)", Modes: runModes},
+	{Env: "REGISTRY_USERNAME", Field: "RegistryUser", Type: "string", Default: "USERNAME", Description: "registry username; AWS for ECR", Modes: runModes},
+	{Env: "REGISTRY_PASSWORD", Field: "RegistryToken", Type: "secret", Default: "CR_PAT", Description: "registry password or token, e.g. a Docker Hub access token or an ECR login password", Modes: runModes},
+	{Env: "REGISTRY_PASSWORD_FILE", Field: "RegistryToken", Type: "path", Description: "file holding the registry password instead of REGISTRY_PASSWORD; re-read after a 401 during publish", Modes: runModes},
+	{Env: "GIT_AUTH_USERNAME", Field: "GitAuthUser", Type: "string", Default: "x-access-token", Description: "username sent with the git token", Modes: runModes},
+	{Env: "GIT_BRANCH", Field: "GitBranch", Type: "string", Default: "main", Description: "branch to clone and build: a name, refs/heads/<name>, origin/<name> or a commit SHA", Modes: runModes},
+	{Env: "GIT_TAG", Field: "GitTag", Type: "string", Description: "tag to clone and build instead of a branch, e.g. v1.2.0 or refs/tags/v1.2.0; prefixes the image tag", Modes: runModes},
+	{Env: "GIT_COMMIT", Field: "GitBranch", Type: "string", Description: "full commit SHA to clone and build instead of a branch; set only one of GIT_BRANCH, GIT_TAG and GIT_COMMIT", Modes: runModes},
+	{Env: "LOCAL_SOURCE", Field: "LocalSource", Type: "path", Description: "host directory to build instead of cloning, e.g. ..; for watch, the project directory to watch", Modes: []string{ModeRun, ModeWatch}},
+	{Env: "BEHIND_WARN_THRESHOLD", Field: "BehindWarnThreshold", Type: "int", Default: "50", Description: "warn when the branch is more commits behind the default branch; negative disables", Modes: runModes},
+	{Env: "BEHIND_STRICT", Field: "BehindStrict", Type: "bool", Default: "false", Description: "fail a publishing run over that threshold", Modes: runModes},
+	{Env: "COMMIT_CHECK", Field: "CommitCheck", Type: "enum", Default: "warn", Description: "check that the built commit is on GIT_BRANCH after the clone and before publishing: warn, fail or off", Modes: runModes},
+	{Env: "CLOCK_SKEW_THRESHOLD", Field: "ClockSkewThreshold", Type: "duration", Default: "2m", Description: "warn when the local clock is further off the Git host's Date header; negative disables", Modes: runModes},
+	{Env: "CLOCK_SKEW_STRICT", Field: "ClockSkewStrict", Type: "bool", Default: "false", Description: "fail a publishing run over that threshold", Modes: runModes},
+	{Env: "IMAGE_NAME", Field: "ImageName", Type: "string", Default: "Docker-safe project name", Description: "name of the published image", Modes: runModes},
+	{Env: "IMAGE_LABELS", Field: "ImageLabels", Type: "list", Description: "extra key=value image labels; they replace the OCI source, revision, created, version and title labels with the same key", Modes: runModes},
+	{Env: "NAME_CHECK", Field: "NameCheck", Type: "enum", Default: "warn", Description: "compare the pyproject.toml name with REPO_NAME and IMAGE_NAME: warn, fail (stop before the tests) or off", Modes: runModes},
+	{Env: "RELEASE_VERSION", Field: "ReleaseVersion", Type: "string", Description: "x.y.z: set the pyproject.toml version on the default branch, push the commit and tag vX.Y.Z, and build the tag", Modes: runModes},
+	{Env: "RELEASE_DRY_RUN", Field: "ReleaseDryRun", Type: "bool", Default: "false", Description: "check the release and print what it would push, without pushing or building", Modes: runModes},
+	{Env: "RELEASE_AUTHOR", Field: "ReleaseAuthor", Type: "string", Default: "Release Pipeline <release-pipeline@users.noreply.github.com>", Description: "author and committer of the release commit and tag, as \"Name <email>\"", Modes: runModes},
+	{Env: "CR_PAT_COMMAND", Type: "string", Description: "command printing a fresh registry token after a 401 during publish", Modes: runModes},
+	{Env: "CR_PAT_FILE", Type: "path", Description: "file re-read for a fresh registry token after a 401 during publish", Modes: runModes},
+	{Env: "GIT_DEPENDENCY_TOKEN", Field: "GitDependencyToken", Type: "secret", Default: "CR_PAT", Description: "token offered to pip for private git+https dependencies", Modes: []string{ModeRun, ModeWatch}},
+	{Env: "GIT_DEPENDENCY_HOSTS", Field: "GitDependencyHosts", Type: "list", Default: "GIT_AUTH_USERNAME@GIT_HOST", Description: "hosts that token is offered to", Modes: []string{ModeRun, ModeWatch}},
+
+	// Monorepos
+	{Env: "DISCOVER_PROJECTS_GLOB", Type: "string", Description: "run once per matching directory with a pyproject.toml, e.g. services/*", Modes: runModes},
+	{Env: "DISCOVER_CHANGED_ONLY", Type: "bool", Default: "false", Description: "only projects changed since CHANGED_BASE", Modes: runModes},
+	{Env: "CHANGED_BASE", Field: "ChangedBase", Type: "string", Default: "origin/main", Description: "ref compared with HEAD in the host checkout", Modes: runModes},
+
+	// Config file & profiles
+	{Env: ConfigFileEnv, Type: "path", Default: DefaultConfigFile + " when present", Description: "YAML file of options keyed by name (run-unit-tests: false); set variables override it, secrets stay in the environment", Modes: allModes},
+	{Env: ProfileEnv, Field: "Profile", Type: "enum", Default: ProfileMain, Description: "named set of defaults: pr, main or nightly", Modes: runModes},
+
+	// Tests
+	{Env: "RUN_UNIT_TESTS", Field: "RunUnitTests", Type: "bool", Default: "true", Description: "run the unit tests", Modes: runModes},
+	{Env: "RUN_INTEGRATION_TESTS", Field: "RunIntegrationTests", Type: "bool", Default: "true", Description: "run the integration tests; requires Docker", Modes: runModes},
+	{Env: "RUN_ACCEPTANCE_TESTS", Field: "RunAcceptanceTests", Type: "bool", Default: "true", Description: "run the acceptance tests; requires Docker", Modes: runModes},
+	{Env: "ACCEPTANCE_AGAINST_IMAGE", Field: "AcceptanceImage", Type: "bool", Default: "false", Description: "acceptance tests call the built image, not the editable install", Modes: runModes},
+	{Env: "ACCEPTANCE_IMAGE_PORT", Field: "AcceptanceImagePort", Type: "int", Default: "first port the image exposes", Description: "port the image serves on", Modes: runModes},
+	{Env: "SERVICE_READY_URL", Field: "ServiceReadyURL", Type: "string", Default: "tcp", Description: "tcp, a path such as /healthz or a URL the started image must answer before the acceptance tests", Modes: runModes},
+	{Env: "SERVICE_READY_INTERVAL", Field: "ServiceReadyPoll", Type: "duration", Default: "500ms", Description: "pause between readiness probes", Modes: runModes},
+	{Env: "SERVICE_READY_TIMEOUT", Field: "ServiceReadyTimeout", Type: "duration", Default: "60s", Description: "how long the image may take to get ready; its last output is printed when it does not", Modes: runModes},
+	{Env: "RUN_LINT", Field: "RunLint", Type: "bool", Default: "true", Description: "run ruff", Modes: runModes},
+	{Env: "RUN_TYPE_CHECK", Field: "RunTypeCheck", Type: "bool", Default: "true", Description: "run mypy", Modes: runModes},
+	{Env: "RUN_BUILD", Field: "RunBuild", Type: "bool", Default: "true", Description: "build the Docker image", Modes: runModes},
+	{Env: "RUN_PUBLISH", Field: "RunPublish", Type: "bool", Default: "true", Description: "publish the image; implies RUN_BUILD", Modes: runModes},
+	{Env: "PUBLISH_TEST_IMAGE", Field: "PublishTestImage", Type: "bool", Default: "false", Description: "also publish <image>-tests:<tag> with the test suite", Modes: runModes},
+	{Env: "PUBLISH_PR_IMAGE", Field: "PublishPRImage", Type: "bool", Default: "false", Description: "in a pull request build, publish only the moving <image>:pr-<number> tag", Modes: runModes},
+	{Env: "ALLOW_FORK_PR_IMAGE", Field: "AllowForkPRImage", Type: "bool", Default: "false", Description: "also publish it for pull requests from forks", Modes: runModes},
+	{Env: "COMMIT_STATUS", Field: "CommitStatus", Type: "bool", Default: "false", Description: "post a GitHub commit status for the run and each stage; skipped stages are pending with their reason", Modes: runModes},
+	{Env: "PR_COMMENT", Field: "PRComment", Type: "bool", Default: "false", Description: "in a pull request build, comment the stage table, skipped stages with their reason", Modes: runModes},
+	{Env: "PR_IMAGE_CLEANUP", Field: "PRImageCleanup", Type: "bool", Default: "false", Description: "after a publish outside a pull request, delete the GHCR images of closed pull requests", Modes: runModes},
+	{Env: "REQUIRE_DOCKER_TESTS", Field: "RequireDockerTests", Type: "bool", Default: "true on main or when publishing", Description: "fail instead of skipping integration and acceptance tests when Docker is missing", Modes: runModes},
+	{Env: "PUBLISH_REQUIRES", Field: "PublishRequires", Type: "list", Default: "unit", Description: "stages that must run and pass before publishing", Modes: runModes},
+	{Env: "PUBLISH_POLICY", Field: "PublishPolicyOff", Type: "enum", Default: "on", Description: "off publishes without the PUBLISH_REQUIRES check", Modes: runModes},
+	{Env: "ALLOW_EMPTY_TEST_STAGE", Field: "AllowEmptyTestStage", Type: "bool", Default: "false", Description: "pass an enabled test stage whose marker selects no tests", Modes: runModes},
+	{Env: "RUN_SLOW_TESTS", Field: "RunSlowTests", Type: "bool", Default: "false", Description: "pytest -m slow in its own stage", Modes: runModes},
+	{Env: "RUN_BENCHMARKS", Field: "RunBenchmarks", Type: "bool", Default: "false", Description: "pytest -m benchmark in its own stage", Modes: runModes},
+	{Env: "PYTHON_VERSIONS", Field: "PythonVersions", Type: "list", Description: "also run the unit tests, lint and type check on these Python versions, e.g. 3.12,3.13,3.14; the image is built from BASE_IMAGE", Modes: runModes},
+	{Env: "DEP_RESOLUTION_MATRIX", Field: "DepResolutions", Type: "list", Default: ResolutionHighest, Description: "also run the unit tests against the lowest allowed dependency versions (highest,lowest)", Modes: runModes},
+	{Env: "CHANGED_ONLY_TESTS", Field: "ChangedOnlyTests", Type: "bool", Default: "false", Description: "only tests affected by changes since CHANGED_BASE", Modes: runModes},
+	{Env: "LINT_CHANGED_ONLY", Field: "LintChangedOnly", Type: "bool", Default: "false", Description: "ruff and mypy only on Python files changed since DIFF_BASE", Modes: runModes},
+	{Env: "DIFF_BASE", Field: "DiffBase", Type: "string", Default: "main", Description: "branch, tag or commit LINT_CHANGED_ONLY compares with", Modes: runModes},
+	{Env: "LINT_CHANGED_MAX", Field: "LintChangedMax", Type: "int", Default: "30", Description: "more changed Python files check the full repository", Modes: []string{ModeRun, ModeWatch}},
+	{Env: "DATABASE_URL_OVERRIDE", Field: "DatabaseURL", Type: "url", Description: "external PostgreSQL for integration and acceptance tests instead of testcontainers", Modes: runModes},
+	{Env: "HOST_TEST_WRAPPER", Field: "HostTestWrapper", Type: "string", Description: "command the host-run pytest runs through, split like shell words, e.g. corp-netns-exec --profile build --", Modes: runModes},
+	{Env: "DB_SEED_COMMAND", Field: "DBSeedCommand", Type: "string", Description: "command that loads fixtures into the test database before the integration and acceptance tests, split like shell words", Modes: runModes},
+	{Env: "DOCKER_HOST", Type: "string", Description: "Docker daemon the host-run tests use", Modes: runModes},
+
+	// Build container
+	{Env: "BASE_IMAGE", Field: "BaseImage", Type: "string", Default: "python:3.14-slim", Description: "Python image the build container starts from", Modes: imageModes},
+	{Env: "APT_PACKAGES", Field: "AptPackages", Type: "list", Default: "git build-essential libpq-dev", Description: "apt packages of the build container, replacing the default; ca-certificates is added for CA certificates", Modes: imageModes},
+	{Env: "EXTRA_APT_PACKAGES", Field: "ExtraAptPackages", Type: "list", Description: "extra apt packages, space or comma separated", Modes: imageModes},
+	{Env: "SKIP_DEFAULT_APT", Field: "SkipDefaultApt", Type: "bool", Default: "false", Description: "leave out git build-essential libpq-dev", Modes: imageModes},
+	{Env: "BASE_IMAGE_MIRROR", Field: "BaseImageMirror", Type: "string", Description: "pull the base image through a Docker Hub mirror", Modes: imageModes},
+	{Env: "REGISTRY_MIRRORS", Field: "RegistryMirrors", Type: "list", Description: "images or registry hosts on mirrors, fetched before the clone", Modes: imageModes},
+	{Env: "PIP_INDEX_URL", Field: "PipIndexURL", Type: "url", Description: "package index pip installs from", Modes: imageModes},
+	{Env: "HASH_CHECKED_INSTALL", Field: "HashCheckedInstall", Type: "bool", Default: "false", Description: "install the dependencies from HASH_LOCK_FILE with --require-hashes --no-deps, then the project without its dependencies", Modes: runModes},
+	{Env: "HASH_LOCK_FILE", Field: "HashLockFile", Type: "path", Default: DefaultHashLockFile, Description: "requirements file with hashes for HASH_CHECKED_INSTALL, relative to the project", Modes: runModes},
+	{Env: "OFFLINE_MODE", Field: "Offline", Type: "bool", Default: "false", Description: "air-gapped run: requires BASE_IMAGE_MIRROR and PIP_INDEX_URL", Modes: runModes},
+	{Env: "OFFLINE_ALLOW_HOSTS", Field: "OfflineAllowHosts", Type: "list", Description: "internal domains an offline run may contact", Modes: runModes},
+	{Env: "DOCKERHUB_PULL_USERNAME", Field: "DockerHubUser", Type: "string", Description: "authenticate Docker Hub pulls against the anonymous rate limit", Modes: imageModes},
+	{Env: "DOCKERHUB_PULL_TOKEN", Field: "DockerHubToken", Type: "secret", Description: "Docker Hub access token for DOCKERHUB_PULL_USERNAME", Modes: imageModes},
+	{Env: "NO_CACHE", Field: "NoCache", Type: "bool", Default: "false", Description: "re-run every step instead of reusing cached results", Modes: runModes},
+	{Env: "STAGE_MEMORY_LIMIT", Field: "StageMemoryLimit", Type: "size", Description: "address space per test and lint process, e.g. 3GB", Modes: runModes},
+	{Env: "TEST_AS_USER", Field: "TestUser", Type: "string", Default: "root", Description: "run the container test, lint and type-check commands as UID[:GID]", Modes: runModes},
+	{Env: "TEST_UMASK", Field: "TestUmask", Type: "string", Description: "umask of those commands, e.g. 027", Modes: runModes},
+	{Env: StageEnvPrefix + "<STAGE>", Field: "StageEnv", Type: "list", Description: "extra KEY=VALUE environment of one stage, e.g. STAGE_ENV_UNIT_TESTS=TZ=UTC; @secret:NAME passes a variable as a secret", Modes: runModes},
+	{Env: "SOURCE_DATE_EPOCH", Field: "SourceDateEpoch", Type: "int", Default: "the clock", Description: "fixed build time for the image tag, the created label and the build arg", Modes: runModes},
+	{Env: "EXPORT_TEST_VENV", Field: "ExportTestVenv", Type: "bool", Default: "false", Description: "run host tests in a copy of the build container's environment", Modes: runModes},
+	{Env: "VENV_DIR", Field: "VenvDir", Type: "path", Default: "<ARTIFACTS_DIR>/test-venv", Description: "where that copy is created", Modes: runModes},
+	{Env: "LOCAL_FRAMEWORK_DIRS", Field: "LocalFrameworkDirs", Type: "list", Default: "python_framework when present", Description: "local packages installed, in order, before the project; +dir extends the default, none installs none", Modes: []string{ModeRun, ModeWatch}},
+	{Env: "RUN_DEPENDENCY_AUDIT", Field: "RunDependencyAudit", Type: "bool", Default: "false", Description: "pip-audit over the installed dependencies", Modes: runModes},
+
+	// Corporate network
+	{Env: "HTTP_PROXY", Field: "ProxyURL", Type: "url", Description: "MITM proxy URL", Modes: allModes, Binaries: corporateOnly},
+	{Env: "HTTPS_PROXY", Field: "ProxyURL", Type: "url", Default: "HTTP_PROXY", Description: "proxy URL when HTTP_PROXY is unset", Modes: allModes, Binaries: corporateOnly},
+	{Env: "PROXY_AUTODETECT", Type: "enum", Default: ProxyAutodetectAsk, Description: "proxy from the macOS/Windows settings when HTTP_PROXY is unset: ask, adopt or off", Modes: allModes, Binaries: corporateOnly},
+	{Env: "DEBUG_CERTS", Type: "bool", Default: "false", Description: "certificate discovery diagnostics", Modes: allModes, Binaries: corporateOnly},
+	{Env: "CA_CERTIFICATES_PATH", Field: "CACertPaths", Type: "list", Description: "CA files, directories or globs", Modes: allModes, Binaries: corporateOnly},
+	{Env: "CERT_MAX_FILE_SIZE", Type: "size", Default: "5MiB", Description: "largest certificate file read during validation", Modes: allModes, Binaries: corporateOnly},
+
+	// Image hardening
+	{Env: "ALLOW_ROOT_IMAGE", Field: "AllowRootImage", Type: "bool", Default: "false", Description: "pass even when the image runs as root", Modes: runModes},
+	{Env: "RUN_DOCKLE", Field: "RunDockle", Type: "bool", Default: "false", Description: "CIS-style checks with dockle", Modes: runModes},
+	{Env: "DOCKLE_FAIL_LEVEL", Field: "DockleFailLevel", Type: "enum", Default: "FATAL", Description: "lowest dockle level that fails the run: FATAL, WARN or INFO", Modes: runModes},
+	{Env: "ENTRYPOINT_CHECK", Field: "EntrypointCheck", Type: "enum", Default: "warn", Description: "image ENTRYPOINT/CMD must run a [project.scripts] entry: warn, fail or off", Modes: runModes},
+	{Env: "IMAGE_WORKDIR", Field: "ImageWorkdir", Type: "path", Description: "WORKDIR the image must have", Modes: runModes},
+	{Env: "RUN_VULN_SCAN", Field: "RunVulnScan", Type: "bool", Default: "false", Description: "fail on fixable HIGH/CRITICAL vulnerabilities (trivy)", Modes: runModes},
+	{Env: "GITHUB_STEP_SUMMARY", Field: "StepSummary", Type: "path", Description: "job summary file results are appended to; set by GitHub Actions", Modes: runModes},
+
+	// Python package publishing
+	{Env: "RUN_PACKAGE_PUBLISH", Field: "RunPackagePublish", Type: "bool", Default: "false", Description: "build and upload the sdist and wheel with twine; uploads on main only", Modes: runModes},
+	{Env: "TWINE_REPOSITORY_URL", Field: "PackagePublish.IndexURL", Type: "url", Default: "TestPyPI", Description: "package index uploaded to", Modes: runModes},
+	{Env: "PACKAGE_PUBLISH_PROD", Type: "bool", Default: "false", Description: "upload to PyPI instead of TestPyPI", Modes: runModes},
+	{Env: "TWINE_USERNAME", Field: "PackagePublish.Username", Type: "string", Default: "__token__", Description: "package index username", Modes: runModes},
+	{Env: "TWINE_PASSWORD", Field: "PackagePublish.Password", Type: "secret", Description: "package index password or token", Modes: runModes},
+	{Env: "EXPORT_WHEELS", Field: "ExportWheels", Type: "bool", Default: "true on main", Description: "build the wheel and export dist/, no upload", Modes: runModes},
+	{Env: "ARTIFACTS_DIR", Field: "ArtifactsDir", Type: "path", Default: DefaultArtifactsDir, Description: "dist/ files, reports and histories", Modes: runModes},
+	{Env: "STAGE_ARTIFACTS_LIMIT", Field: "StageArtifactsLimit", Type: "size", Default: "100MiB", Description: "what one container stage exports from /app/.pipeline-artifacts to ARTIFACTS_DIR/<stage>; off disables", Modes: runModes},
+	{Env: "PARANOID", Field: "Paranoid", Flag: "--paranoid", Type: "bool", Default: "false", Description: "fail if the run changed the checkout's git working tree outside ARTIFACTS_DIR", Modes: runModes},
+
+	// Deployment
+	{Env: "DEPLOY_WEBHOOK", Field: "DeployWebhook", Type: "url", Description: "POSTed the published image metadata", Modes: runModes, Binaries: corporateOnly},
+	{Env: "DEPLOY_VERIFY_URL", Field: "DeployVerifyURL", Type: "url", Description: "polled after the webhook until it reports the new image tag or commit", Modes: runModes, Binaries: corporateOnly},
+	{Env: "DEPLOY_VERIFY_FIELD", Field: "DeployVerifyField", Type: "string", Default: "whole body", Description: "JSON field compared, e.g. build.commit", Modes: runModes, Binaries: corporateOnly},
+	{Env: "DEPLOY_VERIFY_MATCH", Field: "DeployVerifyMatch", Type: "string", Default: "the image tag or short commit SHA", Description: "expected value", Modes: runModes, Binaries: corporateOnly},
+	{Env: "DEPLOY_VERIFY_TIMEOUT", Field: "DeployVerifyTimeout", Type: "duration", Default: "5m", Description: "how long to poll before failing with exit code 9", Modes: runModes, Binaries: corporateOnly},
+
+	// Status reporting
+	{Env: "OUTPUT", Type: "enum", Default: "full", Description: "compact prints one line per stage and the end of a failed stage's output", Modes: runModes},
+	{Env: "STATUS_FILE", Field: "StatusFile", Type: "path", Description: "status.json rewritten after every stage transition", Modes: runModes},
+	{Env: "EVENTS_FILE", Field: "EventsFile", Type: "path", Description: "JSON-lines event stream (schema EventsSchemaVersion) appended to next to the console output", Modes: runModes},
+	{Env: "HTML_REPORT", Field: "HTMLReport", Type: "bool", Default: "false", Description: "also render the report as a self-contained report.html", Modes: runModes},
+	{Env: "STATUS_LISTEN", Field: "StatusListen", Type: "string", Description: "serve /status, /report and /healthz while running, e.g. :8088", Modes: runModes},
+	{Env: "STATUS_ALLOW_REMOTE", Field: "StatusAllowRemote", Type: "bool", Default: "false", Description: "allow a non-loopback STATUS_LISTEN; no authentication", Modes: runModes},
+	{Env: "DURATION_BUDGET", Type: "duration", Description: "warn when the run takes longer, e.g. 20m", Modes: runModes},
+	{Env: "DURATION_BUDGET_HARD", Type: "bool", Default: "false", Description: "fail a successful run over budget (exit 10) instead of warn", Modes: runModes},
+	{Env: "DURATION_HISTORY_FILE", Type: "path", Default: "user cache dir", Description: "per-branch duration history", Modes: runModes},
+	{Env: "BASELINE_FREEZE", Field: "BaselineFreeze", Type: "path", Description: "requirements-resolved.txt to diff the new freeze against", Modes: runModes},
+	{Env: "FREEZE_HISTORY_DIR", Field: "FreezeHistoryDir", Type: "path", Default: "user cache dir", Description: "last freeze, image size and built commit per branch", Modes: runModes},
+	{Env: "FORCE_RUN", Field: "ForceRun", Type: "bool", Default: "false", Description: "run even when the commit is the one the last successful run on the branch built", Modes: runModes},
+	{Env: "VERIFY_UNCHANGED", Field: "VerifyUnchanged", Type: "bool", Default: "false", Description: "skip an unchanged commit only while its published images are still in the registry", Modes: runModes},
+	{Env: "IMAGE_SIZE_BUDGET", Field: "ImageSizeBudget", Type: "size", Description: "warn when the image is larger, e.g. 300MB", Modes: runModes},
+	{Env: "IMAGE_SIZE_MAX_GROWTH", Field: "ImageSizeMaxGrowth", Type: "int", Default: "20", Description: "warn when the image grew more percent since the branch's previous build", Modes: runModes},
+	{Env: "IMAGE_SIZE_HARD_LIMIT", Field: "ImageSizeHardLimit", Type: "bool", Default: "false", Description: "fail the build stage instead of warning", Modes: runModes},
+	{Env: "WARNINGS_BUDGET", Field: "WarningsBudget", Type: "int", Description: "fail when the unit, integration and acceptance tests emit more Python warnings, or warning categories the branch's last accepted run did not have", Modes: runModes},
+	{Env: "PLATFORMS", Field: "Platforms", Type: "list", Description: "platforms to build the image for, e.g. linux/amd64,linux/arm64; more than one publishes manifest lists", Modes: runModes},
+	{Env: "LARGE_IMAGE_THRESHOLD", Field: "LargeImageThreshold", Type: "size", Default: "1GiB", Description: "retry the publish of a larger image with backoff; off disables", Modes: runModes},
+	{Env: "LARGE_IMAGE_PUBLISH_ATTEMPTS", Field: "PublishAttempts", Type: "int", Default: "4", Description: "publish attempts for a large image", Modes: runModes},
+	{Env: "LARGE_IMAGE_PUBLISH_TIMEOUT", Field: "PublishTimeout", Type: "duration", Default: "45m", Description: "time limit per publish attempt of a large image", Modes: runModes},
+	{Env: "MIN_FREE_SPACE", Field: "MinFreeSpace", Type: "size", Default: "5GiB", Description: "fail before any stage when a disk the run writes to has less free space; off disables", Modes: []string{ModeRun, ModeCleanup}},
+	{Env: "DAGGER_ENGINE_STATE_DIR", Field: "EngineStateDir", Type: "path", Default: "/var/lib/docker for a local engine on Linux", Description: "engine state directory checked for free space", Modes: []string{ModeRun, ModeCleanup}},
+	{Env: "FLAKY_THRESHOLD", Field: "FlakyThreshold", Type: "float", Default: "0.2", Description: "flag tests failing intermittently at this rate or more", Modes: runModes},
+	{Env: "FLAKY_WINDOW", Field: "FlakyWindow", Type: "int", Default: "20", Description: "runs kept in the flaky-test history", Modes: runModes},
+	{Env: "FLAKY_HISTORY_FILE", Field: "FlakyHistoryFile", Type: "path", Default: "<ARTIFACTS_DIR>/history/test-history.json", Description: "per-test outcomes", Modes: runModes},
+	{Env: "FLAKY_HISTORY_URL", Field: "FlakyHistoryURL", Type: "url", Description: "remote history read with GET, written with PUT", Modes: runModes},
+	{Env: "LINT_HISTORY_FILE", Field: "LintHistoryFile", Type: "path", Default: "<ARTIFACTS_DIR>/history/lint-history.json", Description: "ruff and mypy counts per run", Modes: runModes},
+	{Env: "LINT_RATCHET", Field: "LintRatchet", Type: "bool", Default: "false", Description: "fail lint and type check when findings grew since the previous run", Modes: runModes},
+	{Env: "WARNINGS_AS_ERRORS", Field: "WarningsAsErrors", Type: "bool", Default: "false", Description: "fail an otherwise passing run on any warning", Modes: runModes},
+	{Env: "WARNINGS_ALLOW", Field: "WarningsAllow", Type: "list", Description: "warning IDs that stay non-fatal", Modes: runModes},
+
+	// Interactive runs
+	{Env: "NON_INTERACTIVE", Type: "bool", Default: "false", Description: "never prompt; prompts are also skipped without a TTY or when CI=true", Modes: runModes},
+
+	// Engine and tool images
+	{Env: "DAGGER_RUNNER_HOST", Type: "url", Description: "use an existing engine instead of provisioning one", Modes: allModes},
+	{Env: "TIMING_DETAIL", Field: "TimingDetail", Type: "bool", Default: "false", Description: "time the key Dagger operations with a sync point at each, printed and in the report", Modes: runModes},
+	{Env: "STRICT_ENGINE_VERSION", Type: "bool", Default: "false", Description: "fail instead of warn on engine/SDK mismatch", Modes: allModes},
+	{Env: ToolImageEnvPrefix + "<NAME>", Field: "ToolImages", Type: "string", Description: "override one pinned tool image, e.g. TOOL_IMAGE_TRIVY", Modes: imageModes},
+
+	// Modes
+	{Env: "WARMUP_REQUIREMENTS", Type: "path", Description: "requirements file pre-installed into the pip cache", Modes: []string{ModeWarmup}},
+	{Env: "CACHE_MAX_AGE", Type: "int", Default: "30", Description: "empty cache volumes unused for longer, in days", Modes: []string{ModeCleanup}},
+	{Env: "CLEANUP_APPLY", Type: "bool", Default: "false", Description: "empty the stale volumes instead of listing them", Modes: []string{ModeCleanup}},
+	{Env: "CLEANUP_ENGINE_PRUNE", Type: "bool", Default: "false", Description: "also prune the engine's dangling build cache", Modes: []string{ModeCleanup}},
+	{Env: "MODE", Flag: "--watch", Type: "enum", Description: "watch re-runs unit tests and ruff on LOCAL_SOURCE whenever it changes", Modes: []string{ModeWatch}},
+}
+
+// LookupOption returns the registered option of an environment variable.
+func LookupOption(env string) (Option, bool) {
+	for _, o := range Options {
+		if o.Env == env {
+			return o, true
+		}
+		if prefix := o.prefix(); prefix != "" && strings.HasPrefix(env, prefix) && len(env) > len(prefix) {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// forBinary reports whether o applies to binary.
+func (o Option) forBinary(binary string) bool {
+	if len(o.Binaries) == 0 {
+		return true
+	}
+	for _, b := range o.Binaries {
+		if b == binary {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaOption is an Option as config-schema prints it.
+type schemaOption struct {
+	Name string `json:"name"`
+	Option
+	Profiles map[string]string `json:"profiles,omitempty"` // profile → default it sets
+}
+
+// ConfigSchema is the config-schema document for binary: every option it
+// reads, with the defaults the profiles give it.
+func ConfigSchema(binary string) ([]byte, error) {
+	doc := struct {
+		Version  int            `json:"version"`
+		Binary   string         `json:"binary"`
+		Modes    []string       `json:"modes"`
+		Profiles []string       `json:"profiles"`
+		Options  []schemaOption `json:"options"`
+	}{Version: ConfigSchemaVersion, Binary: binary, Modes: allModes}
+	for _, p := range Profiles {
+		doc.Profiles = append(doc.Profiles, p.Name)
+	}
+	for _, o := range Options {
+		if !o.forBinary(binary) {
+			continue
+		}
+		s := schemaOption{Name: o.Name(), Option: o}
+		for _, p := range Profiles {
+			if v, ok := p.Defaults[o.Env]; ok {
+				if s.Profiles == nil {
+					s.Profiles = map[string]string{}
+				}
+				s.Profiles[p.Name] = v
+			}
+		}
+		doc.Options = append(doc.Options, s)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// OptionSuggestion is an unregistered variable that looks like a misspelt
+// option.
+type OptionSuggestion struct {
+	Env        string
+	Suggestion string
+}
+
+// maxSuggestionDistance is how many edits an unknown variable may be from
+// an option to be taken for a typo of it.
+const maxSuggestionDistance = 2
+
+// SuggestOptions returns the variables of environ, in KEY=value form, that
+// are not options but within a couple of edits of one, such as
+// RUN_UNIT_TEST for RUN_UNIT_TESTS. Other unknown variables belong to the
+// CI system or the shell and are left alone.
+func SuggestOptions(environ []string) []OptionSuggestion {
+	var suggestions []OptionSuggestion
+	for _, kv := range environ {
+		env, _, _ := strings.Cut(kv, "=")
+		if len(env) < 6 {
+			continue
+		}
+		if _, ok := LookupOption(env); ok {
+			continue
+		}
+		best, bestDistance := "", maxSuggestionDistance+1
+		for _, o := range Options {
+			if o.prefix() != "" {
+				continue
+			}
+			if d := editDistance(env, o.Env); d < bestDistance {
+				best, bestDistance = o.Env, d
+			}
+		}
+		if best != "" {
+			suggestions = append(suggestions, OptionSuggestion{Env: env, Suggestion: best})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].Env < suggestions[j].Env })
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// optionDisplayValue is the value of o as startup output shows it: secrets
+// redacted, credentials stripped from URLs.
+func optionDisplayValue(o Option, value string) string {
+	switch {
+	case o.Type == "secret":
+		return redactedValue
+	case strings.Contains(value, "://"):
+		return redactConfigURL(value, opaqueURLFields[o.Field])
+	}
+	return value
+}
+
+// PrintOptions prints the options set in the environment, in registry
+// order, before profiles fill in their defaults. lookupEnv is normally
+// os.LookupEnv and environ os.Environ(); the options the config file and
+// the flags set are left to PrintConfigFile and PrintOptionFlags.
+func PrintOptions(out io.Writer, lookupEnv func(string) (string, bool), environ []string, file *ConfigFile, flags *OptionFlags) {
+	var lines []string
+	for _, o := range Options {
+		if prefix := o.prefix(); prefix != "" {
+			for _, kv := range environ {
+				if env, value, _ := strings.Cut(kv, "="); strings.HasPrefix(env, prefix) && len(env) > len(prefix) && !file.applied(env) && !flags.applied(env) {
+					lines = append(lines, fmt.Sprintf("   %-28s %s", env, optionDisplayValue(o, value)))
+				}
+			}
+			continue
+		}
+		if value, ok := lookupEnv(o.Env); ok && value != "" && !file.applied(o.Env) && !flags.applied(o.Env) {
+			lines = append(lines, fmt.Sprintf("   %-28s %s", o.Env, optionDisplayValue(o, value)))
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(out, "⚙️  Options: all defaults (see config-schema)")
+		return
+	}
+	fmt.Fprintf(out, "⚙️  Options set in the environment (%d, the rest at their defaults):\n", len(lines))
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+}
+
+// OptionSource is the effective value of an option and where it came
+// from, recorded in the report.
+type OptionSource struct {
+	Env    string `json:"env"`
+	Value  string `json:"value"`  // secrets redacted
+	Source string `json:"source"` // SourceDefault, SourceProfile, SourceFile, SourceEnv or SourceFlag
+}
+
+// OptionSources are the resolved options of a run, in registry order.
+type OptionSources []OptionSource
+
+// Of is where the option env came from; options not resolved are at their
+// default.
+func (s OptionSources) Of(env string) string {
+	for _, o := range s {
+		if o.Env == env {
+			return o.Source
+		}
+	}
+	return SourceDefault
+}
+
+// ResolveOptionSources records where each option of binary came from.
+// lookupEnv and environ read the environment after the profile was
+// applied, whose settings are attributed to it, as are those of the config
+// file; flags are the command-line flags given, by name without dashes, as
+// collected with flag.Visit and OptionFlags.Values. A flag outranks the
+// environment, which outranks the file, which outranks the profile.
+func ResolveOptionSources(binary string, lookupEnv func(string) (string, bool), environ []string, profile *ProfileRecord, file *ConfigFile, flags map[string]string) OptionSources {
+	fromProfile := map[string]bool{}
+	if profile != nil {
+		for _, s := range profile.Settings {
+			fromProfile[s.Name] = s.Source == SourceProfile
+		}
+	}
+	var sources OptionSources
+	for _, o := range Options {
+		if !o.forBinary(binary) {
+			continue
+		}
+		if prefix := o.prefix(); prefix != "" {
+			for _, kv := range environ {
+				if env, value, _ := strings.Cut(kv, "="); strings.HasPrefix(env, prefix) && len(env) > len(prefix) {
+					source := OptionSource{Env: env, Value: optionDisplayValue(o, value), Source: SourceEnv}
+					if file.applied(env) {
+						source.Source = SourceFile
+					}
+					sources = append(sources, source)
+				}
+			}
+			continue
+		}
+		source := OptionSource{Env: o.Env, Value: o.Default, Source: SourceDefault}
+		if value, ok := lookupEnv(o.Env); ok && value != "" {
+			source.Value, source.Source = optionDisplayValue(o, value), SourceEnv
+			if file.applied(o.Env) {
+				source.Source = SourceFile
+			} else if fromProfile[o.Env] {
+				source.Source = SourceProfile
+			}
+		}
+		if value, ok := flags[strings.TrimPrefix(o.Flag, "--")]; ok && o.Flag != "" {
+			source.Value, source.Source = value, SourceFlag
+		} else if value, ok := flags[o.Name()]; ok && o.Flag == "" {
+			source.Value, source.Source = optionDisplayValue(o, value), SourceFlag
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
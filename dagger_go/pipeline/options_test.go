@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// envReadPattern matches the environment reads of the mains and ConfigFromEnv.
+var envReadPattern = regexp.MustCompile(`(?:Getenv|getenv|LookupEnv|parseEnvBool|envOrDefault|envOrDefaultCorp)\("([A-Z0-9_]+)"`)
+
+// ambientEnv are variables the mains read that are not pipeline options.
+var ambientEnv = map[string]bool{"HOME": true}
+
+// TestOptionsMatchMains tests that every variable the binaries read is registered and every option is read,
+// by name or through its Config field in ConfigFromEnv
+func TestOptionsMatchMains(t *testing.T) {
+	var sources strings.Builder
+	for _, pattern := range []string{"../*.go", "*.go", "../certdiscovery/*.go"} {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			if strings.HasSuffix(file, "_test.go") || filepath.Base(file) == "options.go" {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sources.Write(data)
+			if filepath.Dir(file) == ".." || filepath.Base(file) == "envconfig.go" {
+				for _, m := range envReadPattern.FindAllStringSubmatch(string(data), -1) {
+					if _, ok := LookupOption(m[1]); !ok && !ambientEnv[m[1]] {
+						t.Errorf("%s reads %s, which is not in Options", file, m[1])
+					}
+				}
+			}
+		}
+	}
+	seen := map[string]bool{}
+	configType := reflect.TypeOf(Config{})
+	for _, o := range Options {
+		if seen[o.Env] {
+			t.Errorf("%s is registered twice", o.Env)
+		}
+		seen[o.Env] = true
+		if len(o.Modes) == 0 || o.Type == "" || o.Description == "" {
+			t.Errorf("%s needs modes, a type and a description: %+v", o.Env, o)
+		}
+		env := o.Env
+		if prefix := o.prefix(); prefix != "" {
+			env = prefix
+		}
+		if o.Field != "" && !explicitOptions[o.Env] && o.prefix() == "" {
+			if err := setOption(&Config{}, o, ""); err != nil {
+				t.Errorf("ConfigFromEnv cannot read %s: %v", o.Env, err)
+			}
+		} else if !strings.Contains(sources.String(), `"`+env) {
+			t.Errorf("%s is registered but no binary reads it", o.Env)
+		}
+		if o.Field == "" {
+			continue
+		}
+		t2 := configType
+		for _, part := range strings.Split(o.Field, ".") {
+			field, ok := t2.FieldByName(part)
+			if !ok {
+				t.Fatalf("%s names Config field %s, which does not exist", o.Env, o.Field)
+			}
+			t2 = field.Type
+		}
+	}
+	fmt.Println("✅ Options registry matches the variables the binaries read")
+}
+
+// TestConfigSchema tests the JSON document of the config-schema mode
+func TestConfigSchema(t *testing.T) {
+	data, err := ConfigSchema(BinaryStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version int `json:"version"`
+		Options []struct {
+			Name     string            `json:"name"`
+			Env      string            `json:"env"`
+			Flag     string            `json:"flag"`
+			Default  string            `json:"default"`
+			Modes    []string          `json:"modes"`
+			Profiles map[string]string `json:"profiles"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	byEnv := map[string]int{}
+	for i, o := range doc.Options {
+		byEnv[o.Env] = i
+	}
+	if doc.Version != ConfigSchemaVersion {
+		t.Fatalf("version = %d", doc.Version)
+	}
+	if _, ok := byEnv["DEPLOY_WEBHOOK"]; ok {
+		t.Fatal("corporate-only options must not be in the standard schema")
+	}
+	unit := doc.Options[byEnv["RUN_UNIT_TESTS"]]
+	if unit.Name != "run-unit-tests" || unit.Default != "true" || unit.Modes[0] != ModeRun {
+		t.Fatalf("unexpected RUN_UNIT_TESTS: %+v", unit)
+	}
+	if p := doc.Options[byEnv["RUN_PUBLISH"]].Profiles; p[ProfilePR] != "false" || p[ProfileNightly] != "true" {
+		t.Fatalf("RUN_PUBLISH should carry the profile defaults: %v", p)
+	}
+	if f := doc.Options[byEnv["PARANOID"]].Flag; f != "--paranoid" {
+		t.Fatalf("PARANOID flag = %q", f)
+	}
+
+	corporate, err := ConfigSchema(BinaryCorporate)
+	if err != nil || !bytes.Contains(corporate, []byte(`"DEPLOY_WEBHOOK"`)) {
+		t.Fatalf("corporate schema should list DEPLOY_WEBHOOK: %v", err)
+	}
+	fmt.Println("✅ config-schema lists the options with defaults, flags and profiles")
+}
+
+// TestSuggestOptions tests the did-you-mean suggestions for misspelt options
+func TestSuggestOptions(t *testing.T) {
+	got := SuggestOptions([]string{
+		"RUN_UNIT_TEST=false",
+		"RUN_INTEGRATON_TESTS=false",
+		"RUN_UNIT_TESTS=true",
+		"TOOL_IMAGE_TRIVY=mirror/trivy:1",
+		"GITHUB_ACTOR=octocat",
+		"PATH=/usr/bin",
+		"NO_PROXY=localhost",
+	})
+	want := []OptionSuggestion{
+		{Env: "RUN_INTEGRATON_TESTS", Suggestion: "RUN_INTEGRATION_TESTS"},
+		{Env: "RUN_UNIT_TEST", Suggestion: "RUN_UNIT_TESTS"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	fmt.Println("✅ Misspelt options get a suggestion, other variables are left alone")
+}
+
+// TestPrintOptions tests that the startup listing shows set options with secrets redacted
+func TestPrintOptions(t *testing.T) {
+	env := map[string]string{
+		"RUN_LINT":          "false",
+		"CR_PAT":            "ghp_secret",
+		"FLAKY_HISTORY_URL": "https://user:pw@history.corp/x?token=abc",
+		"UNRELATED":         "x",
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	environ := []string{"TOOL_IMAGE_TRIVY=mirror/trivy:1"}
+
+	var out bytes.Buffer
+	PrintOptions(&out, lookup, environ, nil, nil)
+	s := out.String()
+	for _, want := range []string{"RUN_LINT", "false", "TOOL_IMAGE_TRIVY", "mirror/trivy:1", "(4, the rest"} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("output should contain %q:\n%s", want, s)
+		}
+	}
+	for _, secret := range []string{"ghp_secret", "pw@", "token=abc", "UNRELATED"} {
+		if strings.Contains(s, secret) {
+			t.Fatalf("output must not contain %q:\n%s", secret, s)
+		}
+	}
+
+	out.Reset()
+	PrintOptions(&out, func(string) (string, bool) { return "", false }, nil, nil, nil)
+	if !strings.Contains(out.String(), "all defaults") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	fmt.Println("✅ Startup option listing redacts secrets")
+}
+
+// TestResolveOptionSources tests attributing each option to its default, the profile, the config file, the environment or a flag
+func TestResolveOptionSources(t *testing.T) {
+	profile, err := ResolveProfile(ProfilePR, func(k string) (string, bool) {
+		if k == "RUN_VULN_SCAN" {
+			return "true", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The environment as the binaries see it after applying the config file and the profile
+	env := map[string]string{"RUN_ACCEPTANCE_TESTS": "false", "CR_PAT": "ghp_secret", "PARANOID": "false"}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	file := &ConfigFile{Settings: []ConfigSetting{{Env: "RUN_LINT", Value: "false"}, {Env: "RUN_ACCEPTANCE_TESTS", Value: "true"}, {Env: "TOOL_IMAGE_RUFF", Value: "mirror/ruff:1"}}}
+	if err := file.Apply(lookup, func(k, v string) error { env[k] = v; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range profile.Settings {
+		env[s.Name] = s.Value
+	}
+	environ := []string{"TOOL_IMAGE_TRIVY=mirror/trivy:1", "TOOL_IMAGE_RUFF=mirror/ruff:1"}
+	sources := ResolveOptionSources(BinaryStandard, lookup, environ, profile, file, map[string]string{"paranoid": "true"})
+
+	byEnv := map[string]OptionSource{}
+	for _, s := range sources {
+		byEnv[s.Env] = s
+	}
+	for env, want := range map[string]OptionSource{
+		"RUN_UNIT_TESTS":       {Env: "RUN_UNIT_TESTS", Value: "true", Source: SourceDefault},
+		"RUN_ACCEPTANCE_TESTS": {Env: "RUN_ACCEPTANCE_TESTS", Value: "false", Source: SourceEnv},
+		"RUN_PUBLISH":          {Env: "RUN_PUBLISH", Value: "false", Source: SourceProfile},
+		"RUN_VULN_SCAN":        {Env: "RUN_VULN_SCAN", Value: "true", Source: SourceEnv},
+		"PARANOID":             {Env: "PARANOID", Value: "true", Source: SourceFlag},
+		"CR_PAT":               {Env: "CR_PAT", Value: redactedValue, Source: SourceEnv},
+		"TOOL_IMAGE_TRIVY":     {Env: "TOOL_IMAGE_TRIVY", Value: "mirror/trivy:1", Source: SourceEnv},
+		"TOOL_IMAGE_RUFF":      {Env: "TOOL_IMAGE_RUFF", Value: "mirror/ruff:1", Source: SourceFile},
+		"RUN_LINT":             {Env: "RUN_LINT", Value: "false", Source: SourceFile},
+	} {
+		if byEnv[env] != want {
+			t.Fatalf("%s: got %+v, want %+v", env, byEnv[env], want)
+		}
+	}
+	if _, ok := byEnv["DEPLOY_WEBHOOK"]; ok {
+		t.Fatal("corporate-only options must not be resolved for the standard binary")
+	}
+	if sources.Of("RUN_PUBLISH") != SourceProfile || sources.Of("NOT_AN_OPTION") != SourceDefault {
+		t.Fatal("unexpected Of")
+	}
+	if _, ok := EffectiveConfig(Config{OptionSources: sources})["OptionSources"]; ok {
+		t.Fatal("option sources have their own report section, not a config entry")
+	}
+	fmt.Println("✅ Options attributed to default, profile, config file, environment or flag")
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"dagger.io/dagger"
+)
+
+// Package index upload endpoints used when TWINE_REPOSITORY_URL is not set.
+const (
+	TestPyPIURL = "https://test.pypi.org/legacy/"
+	PyPIURL     = "https://upload.pypi.org/legacy/"
+)
+
+// PackageUploadBranch is the only branch whose packages are uploaded;
+// other branches still build and check the distributions.
+const PackageUploadBranch = "main"
+
+// Artifact is a file exported by the pipeline, recorded in the report.
+type Artifact struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// PackagePublish configures the Python package build and upload stage.
+type PackagePublish struct {
+	IndexURL  string // upload endpoint; see PackageIndexURL
+	Username  string // TWINE_USERNAME
+	Password  string // TWINE_PASSWORD
+	Release   bool   // sdist + wheel + twine check; false builds the wheel only
+	Upload    bool   // upload with twine (implies Release)
+	ExportDir string // host directory receiving the built dist/ files
+}
+
+// PackageIndexURL returns the upload endpoint: the explicit URL when set,
+// otherwise PyPI when prod is true and TestPyPI by default.
+func PackageIndexURL(explicit string, prod bool) string {
+	switch {
+	case explicit != "":
+		return explicit
+	case prod:
+		return PyPIURL
+	default:
+		return TestPyPIURL
+	}
+}
+
+// BuildPythonPackage builds distributions with `python -m build` in the
+// already-provisioned builder container and exports dist/ to opts.ExportDir.
+// A release build adds the sdist and runs `twine check`; with opts.Upload it
+// then uploads with twine using the credentials as Dagger secrets. It returns
+// the exported files with their SHA-256 hashes.
+func BuildPythonPackage(ctx context.Context, client *dagger.Client, builder *dagger.Container, workdir string, opts PackagePublish) ([]Artifact, error) {
+	release := opts.Release || opts.Upload
+	pkg := builder.WithExec([]string{"rm", "-rf", "dist"})
+	if release {
+		pkg = pkg.
+			WithExec([]string{"pip", "install", "--upgrade", "build", "twine"}).
+			WithExec([]string{"python", "-m", "build", "--sdist", "--wheel", "--outdir", "dist", "."}).
+			WithExec([]string{"sh", "-c", "twine check --strict dist/*"})
+	} else {
+		pkg = pkg.
+			WithExec([]string{"pip", "install", "--upgrade", "build"}).
+			WithExec([]string{"python", "-m", "build", "--wheel", "--outdir", "dist", "."})
+	}
+
+	// Wipe so stale distributions from earlier runs are not reported as ours
+	dist := pkg.Directory(workdir + "/dist")
+	if _, err := dist.Export(ctx, opts.ExportDir, dagger.DirectoryExportOpts{Wipe: true}); err != nil {
+		return nil, Errorf(CategoryBuild, "python package build failed: %w", err)
+	}
+	artifacts, err := HashArtifacts(opts.ExportDir)
+	if err != nil {
+		return nil, Errorf(CategoryBuild, "failed to hash built packages: %w", err)
+	}
+
+	if !opts.Upload {
+		return artifacts, nil
+	}
+	uploader := pkg.
+		WithEnvVariable("TWINE_REPOSITORY_URL", opts.IndexURL).
+		WithEnvVariable("TWINE_NON_INTERACTIVE", "1").
+		WithSecretVariable("TWINE_USERNAME", client.SetSecret("twine-username", opts.Username)).
+		WithSecretVariable("TWINE_PASSWORD", client.SetSecret("twine-password", opts.Password)).
+		WithExec([]string{"sh", "-c", "twine upload dist/*"})
+	if _, err := uploader.Sync(ctx); err != nil {
+		return artifacts, Errorf(CategoryPublish, "package upload to %s failed: %w", opts.IndexURL, err)
+	}
+	return artifacts, nil
+}
+
+// HashArtifacts returns every regular file directly inside dir with its size
+// and SHA-256, sorted by name.
+func HashArtifacts(dir string) ([]Artifact, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		sum, size, err := sha256File(path)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, Artifact{Name: entry.Name(), Path: path, Size: size, SHA256: sum})
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
+
+func sha256File(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPackageIndexURL tests the TestPyPI default and explicit/prod overrides
+func TestPackageIndexURL(t *testing.T) {
+	tests := []struct {
+		explicit string
+		prod     bool
+		expected string
+	}{
+		{"", false, TestPyPIURL},
+		{"", true, PyPIURL},
+		{"https://nexus.example.com/repository/pypi-internal/", false, "https://nexus.example.com/repository/pypi-internal/"},
+		{"https://nexus.example.com/repository/pypi-internal/", true, "https://nexus.example.com/repository/pypi-internal/"},
+	}
+	for _, tc := range tests {
+		if got := PackageIndexURL(tc.explicit, tc.prod); got != tc.expected {
+			t.Fatalf("PackageIndexURL(%q, %v) = %q, want %q", tc.explicit, tc.prod, got, tc.expected)
+		}
+	}
+	fmt.Println("✅ Package index defaults to TestPyPI")
+}
+
+// TestHashArtifactsAndReport tests artifact hashing and the report round-trip
+func TestHashArtifactsAndReport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cert_parser-0.1.0.tar.gz"), []byte("sdist"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cert_parser-0.1.0-py3-none-any.whl"), []byte("wheel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts, err := HashArtifacts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %+v", artifacts)
+	}
+	// sha256("wheel")
+	if artifacts[0].Name != "cert_parser-0.1.0-py3-none-any.whl" ||
+		artifacts[0].SHA256 != "ba59926159d2aa256eb8739b8da7e2b574b960e1202c6d624cbe981cef996c91" ||
+		artifacts[0].Size != 5 {
+		t.Fatalf("unexpected wheel artifact: %+v", artifacts[0])
+	}
+
+	path, err := WriteReport(dir, &Report{Status: Status{State: RunSucceeded}, Artifacts: artifacts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if len(r.Artifacts) != 2 || r.Status.State != RunSucceeded {
+		t.Fatalf("report round-trip mismatch: %+v", r)
+	}
+	fmt.Println("✅ Artifacts hashed and recorded in the report")
+}
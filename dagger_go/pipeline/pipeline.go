@@ -0,0 +1,1875 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+const (
+	// BaseImage is the Python image the build environment starts from.
+	BaseImage = "python:3.14-slim"
+	// AppWorkdir is where the cloned source is mounted in the build container.
+	AppWorkdir = "/app"
+	// AptCacheVolume and PipCacheVolume hold downloaded packages across runs
+	// and projects; Warmup pre-populates both.
+	AptCacheVolume = "apt-cache"
+	PipCacheVolume = "pip-cache"
+
+	mainBranch    = "main"
+	separatorLine = "─────────────────────────────────────────────────────────────────────────────────"
+	caBundlePath  = "/etc/ssl/certs/ca-certificates.crt"
+
+	// cacheBusterEnv changes every run with NoCache, so no step of the
+	// build container matches an earlier run's cached result.
+	cacheBusterEnv = "PIPELINE_CACHE_BUSTER"
+)
+
+// Config is everything a pipeline run needs. It is a plain value: the
+// library never reads environment variables, so embedders fill it in
+// directly and the binaries translate env vars into it.
+type Config struct {
+	// Source repository
+	RepoName    string // Repository name, e.g. "cert-parser" (required)
+	GitUser     string // User or group on the Git host (required)
+	GitHost     string // Git server hostname (default: github.com)
+	GitRepo     string // Full clone URL (default: https://<GitHost>/<GitUser>/<RepoName>.git)
+	GitBranch   string // Branch or commit SHA to build (default: main, unless GitTag is set)
+	GitTag      string // Tag to build instead of a branch, e.g. "v1.2.0" (optional)
+	GitAuthUser string // HTTP auth username for git clone (default: x-access-token)
+	GitToken    string // Clone token; empty clones anonymously
+	LocalSource string // Host directory built instead of cloning GitRepo, e.g. ".." (optional)
+
+	// Private `git+https://` dependencies installed by pip in the build container
+	GitDependencyToken string              // Token for the hosts below (default: GitToken); empty disables
+	GitDependencyHosts []GitCredentialHost // Hosts the token is offered to (default: GitAuthUser@GitHost)
+
+	// Branch freshness, from the GitHub compare API after the clone
+	BehindWarnThreshold int  // Warn when the commit is more commits behind the default branch (default: DefaultBehindWarnThreshold; negative disables)
+	BehindStrict        bool // Fail a publishing run over the threshold instead of warning
+
+	// Commit reachability, from the GitHub compare API after the clone and again before publishing
+	CommitCheck string // A commit not on GitBranch: CommitCheckWarn (default), CommitCheckFail or CommitCheckOff
+
+	// Clock skew, from the Date header of the Git host before the clone
+	ClockSkewThreshold time.Duration // Warn when the local clock is further off (default: DefaultClockSkewThreshold; negative disables)
+	ClockSkewStrict    bool          // Fail a publishing run over the threshold instead of warning
+
+	// Registry mirrors, checked with a manifest fetch before anything is pulled
+	RegistryMirrors []string   // Images or hosts on mirrors the engine pulls from, besides BaseImageMirror (optional)
+	RunnerHost      RunnerHost // The engine the binary connected to; the default one only trusts public CAs
+
+	// Release: bump pyproject.toml, commit, tag vX.Y.Z and build the tag
+	ReleaseVersion string // x.y.z to release from the default branch (optional)
+	ReleaseDryRun  bool   // Check and show the release without pushing or building
+	ReleaseAuthor  string // "Name <email>" of the release commit and tag (default: DefaultReleaseAuthor)
+
+	// Image
+	ImageName     string // Docker image name (default: Docker-safe project name)
+	NameCheck     string // Compare the pyproject.toml name with RepoName and ImageName: NameCheckWarn (default), NameCheckFail or NameCheckOff
+	Registry      string // Container registry host, optionally with a path prefix (default: ghcr.io)
+	RegistryToken string // Registry password, required when RunPublish is set
+	WorkflowToken bool   // GitToken/RegistryToken are the GitHub Actions GITHUB_TOKEN; permission errors say what to grant
+	// Labels added to the OCI source, revision, created, version and title
+	// labels of the built image, replacing any with the same key (optional)
+	ImageLabels map[string]string
+	// Namespace the image is published under, e.g. a Docker Hub user or a
+	// Harbor project (default: GitUser); "/" publishes at the registry
+	// root, as ECR repositories are
+	RegistryNamespace string
+	RegistryUser      string // Username for RegistryToken (default: GitUser; AWS for ECR)
+	// Fresh registry token after a 401 during publish; nil fails on the first 401
+	RenewRegistryToken TokenRefreshFunc
+	// Open pull request this run builds, from DetectPullRequest (nil outside one)
+	PullRequest *PullRequest
+	// Report the stages back to GitHub with GitToken: CommitStatus posts a
+	// commit status per stage, PRComment comments the stage table on
+	// PullRequest. Skipped stages carry their reason in both.
+	CommitStatus bool
+	PRComment    bool
+
+	// Stages
+	RunUnitTests        bool
+	RunIntegrationTests bool // requires HasDocker or DatabaseURL
+	RunAcceptanceTests  bool // requires HasDocker or DatabaseURL
+	AcceptanceImage     bool // acceptance tests call the built image (docker run, or a Dagger service without Docker) instead of the editable install
+	AcceptanceImagePort int  // port of the image the tests call (default: its first exposed port)
+	// Readiness gate between starting the image and the acceptance tests:
+	// ServiceReadyURL is a path (/healthz) or URL polled until it answers
+	// 2xx or 3xx, or ServiceReadyTCP (default) for the port accepting
+	// connections; the image's latest output is printed when it never does
+	ServiceReadyURL     string
+	ServiceReadyPoll    time.Duration // Between probes (default: DefaultServiceReadyInterval)
+	ServiceReadyTimeout time.Duration // Before the acceptance stage fails (default: AcceptanceStartTimeout)
+	RunLint             bool
+	RunTypeCheck        bool
+	RunBuild            bool
+	RunPublish          bool   // requires RunBuild
+	PublishTestImage    bool   // also publish <image>-tests (source, dev dependencies, tests) with the versioned tag; requires RunPublish
+	PublishPRImage      bool   // publish only the moving <image>:pr-<number> tag of PullRequest, instead of RunPublish; requires RunBuild
+	AllowForkPRImage    bool   // publish PR images of pull requests from forks too, exposing the registry token to their code
+	PRImageCleanup      bool   // after a publish outside a pull request, delete the GHCR images of closed pull requests
+	AllowRootImage      bool   // pass the hardening check even when the image runs as root
+	RunDockle           bool   // run dockle against the built image
+	DockleFailLevel     string // lowest dockle level that fails the run (default: FATAL)
+	EntrypointCheck     string // warn, fail or off when the image entrypoint runs none of [project.scripts] (default: warn)
+	ImageWorkdir        string // WORKDIR the built image must have, checked unless EntrypointCheck is off (optional)
+	RunPackagePublish   bool   // build sdist+wheel, twine check, upload when PackagePublish.Upload
+	ExportWheels        bool   // build the wheel and export dist/ without uploading
+	PackagePublish      PackagePublish
+	// Dependency resolutions the unit tests run against, see
+	// ParseDepResolutions (default: ResolutionHighest only)
+	DepResolutions      []string
+	AllowEmptyTestStage bool   // pass a test stage whose marker selects no tests, recording it as a test skip
+	RunSlowTests        bool   // pytest -m slow in a stage of its own; the unit stage then leaves them out
+	RunBenchmarks       bool   // pytest -m benchmark in a stage of its own; likewise left out of the unit stage
+	RunDependencyAudit  bool   // pip-audit over the installed dependencies
+	RunVulnScan         bool   // trivy scan of the built image for fixable HIGH/CRITICAL vulnerabilities
+	ChangedOnlyTests    bool   // run only the tests affected by changes since ChangedBase in the host checkout
+	ChangedBase         string // Git ref compared with HEAD (default: DefaultChangedBase)
+	LintChangedOnly     bool   // ruff and mypy check only the Python files changed since DiffBase
+	DiffBase            string // Branch, tag or commit of the cloned repository compared with (default: main)
+	LintChangedMax      int    // More changed Python files than this check the full repository (default: DefaultLintChangedMax)
+
+	// Host
+	HasDocker          bool   // Docker socket available on the host for testcontainers
+	DockerDetection    string // How Docker was looked for, quoted when tests are skipped or required
+	RequireDockerTests bool   // Fail instead of skipping integration/acceptance tests without Docker
+	DatabaseURL        string // External PostgreSQL for integration/acceptance tests instead of testcontainers
+	ProjectRoot        string // Host checkout used by host-run tests (default: "..")
+	ProjectDir         string // Project subdirectory of the repository, e.g. services/api (default: root)
+
+	// HostTestWrapper is a command the host-run test stages run pytest
+	// through, e.g. corp-netns-exec --profile build -- (optional).
+	HostTestWrapper []string
+
+	// DBSeedCommand loads fixtures into the test database once it exists,
+	// before the integration and acceptance tests run, e.g. python -m
+	// cert_parser.tools.seed --fixtures tests/fixtures/seed (optional).
+	DBSeedCommand []string
+
+	// Build container
+	BaseImage          string   // Python image the build container starts from (default: BaseImage)
+	AptPackages        []string // Replaces DefaultAptPackages when set, e.g. git build-essential libpq-dev libxml2-dev
+	ExtraAptPackages   []string // Installed after DefaultAptPackages, e.g. libxml2-dev swig
+	SkipDefaultApt     bool     // Leave out DefaultAptPackages
+	CacheKey           string   // Suffix for the apt/pip cache volumes, e.g. the project in a monorepo (optional)
+	NoCache            bool     // Re-run every build-container step instead of reusing Dagger's cached results
+	SourceDateEpoch    int64    // Unix time used for the image tag, created label and build arg instead of the clock (optional)
+	ExportTestVenv     bool     // Run host tests in a copy of the build environment (Linux host, same arch and Python)
+	BaseImageMirror    string   // Registry prefix the base image is pulled through instead of Docker Hub, e.g. registry.corp.local/dockerhub (optional)
+	PipIndexURL        string   // Package index pip installs from, e.g. https://pypi.corp.local/simple (default: PyPI)
+	HashCheckedInstall bool     // Install the dependencies from HashLockFile with --require-hashes --no-deps, then the project without its dependencies
+	HashLockFile       string   // Requirements file with hashes, relative to the project (default: DefaultHashLockFile)
+	DockerHubUser      string   // Docker Hub account for authenticated pulls, which have a higher rate limit (optional)
+	DockerHubToken     string   // Docker Hub access token of DockerHubUser
+	StageMemoryLimit   int64    // Address space per process of the container test and lint stages, in bytes (optional)
+	TestUser           string   // UID[:GID] the container test and lint commands run as, e.g. 1000:1000 (default: root)
+	TestUmask          string   // Octal umask of the container test and lint commands, e.g. 027 (optional)
+	VenvDir            string   // Where ExportTestVenv creates it, outside the checkout (default: <ArtifactsDir>/test-venv)
+
+	// StageEnv is extra environment per stage name (StageEnvStages), set
+	// in that stage's container only, or its process on the host.
+	StageEnv map[string][]StageEnvVar
+
+	// LocalFrameworkDirs are the project's local packages, installed in
+	// order before it; each needs a pyproject.toml or setup.py. nil selects
+	// DefaultLocalFrameworkDirs that exist, empty installs none.
+	LocalFrameworkDirs []string
+
+	// Offline (air-gapped) runs
+	Offline           bool     // Fail up front when anything would reach the internet; stages that need it are skipped
+	OfflineAllowHosts []string // Internal domains an offline run may contact, e.g. corp.local (default: any host not in OfflineBlockedHosts)
+
+	// Disk space
+	MinFreeSpace   int64  // Free bytes needed on the engine, temp and artifacts filesystems; under twice that warns (default: DefaultMinFreeSpace; negative disables)
+	EngineStateDir string // Directory on the filesystem of the Dagger engine's state, checked for free space (optional; see LocalEngineStateDir)
+
+	// Corporate network
+	CACertPaths   []string          // CA files or directories mounted into the build container
+	ProxyURL      string            // Proxy exported to the build container
+	DeployWebhook string            // URL notified after a successful publish
+	HTTPClient    *http.Client      // Host-side HTTP (default: NewHTTPClient with ProxyURL/CACertPaths)
+	ToolImages    map[string]string // Tool name → image overriding the pinned ToolImages entry
+
+	// Deployment verification (after DeployWebhook)
+	DeployVerifyURL      string        // Polled until it reports the new release, e.g. https://svc/version (optional)
+	DeployVerifyField    string        // Dot-separated JSON field compared, e.g. build.commit (default: whole body)
+	DeployVerifyMatch    string        // Expected value (default: the image tag or short commit SHA)
+	DeployVerifyTimeout  time.Duration // default: DefaultDeployVerifyTimeout
+	DeployVerifyInterval time.Duration // default: DefaultDeployVerifyInterval
+
+	// Output
+	ArtifactsDir string         // Host directory for exported files (default: DefaultArtifactsDir)
+	StatusFile   string         // status.json rewritten after every stage transition (optional)
+	EventsFile   string         // JSON-lines Event stream appended to alongside Output (optional)
+	HTMLReport   bool           // Also write the report as HTMLReportFile, for people rather than tools
+	Output       io.Writer      // Progress output (default: os.Stdout)
+	Confirm      ConfirmFunc    // Asks before risky operations; nil runs them as configured
+	StepSummary  string         // CI job summary file appended to, e.g. $GITHUB_STEP_SUMMARY (optional)
+	Profile      *ProfileRecord // Active PIPELINE_PROFILE, recorded in the report (optional)
+	Paranoid     bool           // Fail the run if it changed the git working tree at ProjectRoot outside ArtifactsDir
+
+	// StageArtifactsLimit caps what one container stage exports from
+	// ContainerArtifactsDir (default: DefaultStageArtifactsLimit; negative
+	// disables the export).
+	StageArtifactsLimit int64
+
+	// Where each option came from (ResolveOptionSources), recorded in the
+	// report (optional)
+	OptionSources OptionSources
+
+	// Dagger operation timing
+	TimingDetail bool          // Force sync points around the key Dagger operations and report how long each took (slower)
+	ConnectTime  time.Duration // How long connecting to the engine took, measured by the caller (optional)
+
+	// Publish policy
+	PublishRequires  []string // Stages (unit, lint, typecheck, ...) that must run and pass in this run before publishing (default: DefaultPublishRequires)
+	PublishPolicyOff bool     // Publish without checking PublishRequires; logged as a loud warning
+
+	// Warnings
+	Warnings         *Warnings // Collects the run's warnings; the binaries pass theirs in with the ones raised before New (default: empty)
+	WarningsAsErrors bool      // Fail an otherwise passing run when any warning not in WarningsAllow was raised
+	WarningsAllow    []string  // Warning IDs that stay non-fatal with WarningsAsErrors, e.g. tool-unpinned
+
+	// Live status server
+	StatusListen      string // HTTP address serving /status, /report and /healthz during the run, e.g. :8088 (optional)
+	StatusAllowRemote bool   // Allow a non-loopback StatusListen address; the endpoints have no authentication
+
+	// Flaky-test history (per-test outcomes from JUnit XML)
+	FlakyHistoryFile string  // Local store (default: <ArtifactsDir>/history/test-history.json)
+	FlakyHistoryURL  string  // Remote store read with GET and written with PUT, instead of the file (optional)
+	FlakyWindow      int     // Runs kept and analyzed (default: DefaultFlakyWindow)
+	FlakyThreshold   float64 // Failure rate (0–1) from which flaky tests are flagged (default: DefaultFlakyThreshold)
+
+	// Lint debt (ruff and mypy finding counts over time)
+	LintHistoryFile string // Local store (default: <ArtifactsDir>/history/lint-history.json)
+	LintRatchet     bool   // Fail lint or type check when its findings exceed the previous run's, even if the tool passed
+
+	// Dependency freeze
+	BaselineFreeze   string // requirements-resolved.txt from an earlier run to diff against (optional)
+	FreezeHistoryDir string // Keeps the last freeze, image size and built commit per branch for the next run (optional)
+
+	// Unchanged commits, against the last successful run in FreezeHistoryDir
+	ForceRun        bool // Run even when the commit is the one the last successful run built
+	VerifyUnchanged bool // Skip only while the images the last run published are still in the registry
+
+	// Image size
+	ImageSizeBudget    int64 // Bytes the built image may take, summed over its compressed layers (optional)
+	ImageSizeMaxGrowth int   // Percent the image may grow over the branch's previous build (default: DefaultImageSizeMaxGrowth)
+	ImageSizeHardLimit bool  // Fail the build stage on a size problem instead of warning
+
+	// WarningsBudget is how many Python warnings the unit, integration and
+	// acceptance tests may emit in total; with it set, warning categories the
+	// branch's last accepted run did not have also fail the run (0: no budget)
+	WarningsBudget int
+
+	// PythonVersions the unit-test, lint and type-check stages also run on,
+	// e.g. 3.12 and 3.13, each in its own container from the base image with
+	// the version swapped in its tag. The image is built from the base
+	// image's version.
+	PythonVersions []string
+
+	// Platforms the image is built for, e.g. linux/amd64 and linux/arm64:
+	// with more than one, the versioned and latest tags are manifest lists
+	// (default: the engine's platform).
+	Platforms []string
+
+	// Large image publish
+	LargeImageThreshold int64         // Image size from which publish retries with backoff and prints progress (default: DefaultLargeImageThreshold; negative disables)
+	PublishAttempts     int           // Publish attempts for a large image (default: DefaultPublishAttempts)
+	PublishTimeout      time.Duration // Time limit per publish attempt of a large image (default: DefaultPublishTimeout)
+}
+
+// Pipeline is a configured run: Clone → Discover → Install → Unit Tests →
+// (Slow Tests → Benchmarks) → Integration Tests → Acceptance Tests → Lint →
+// Type-check → (Dependency Audit) → Package → Docker Build → (Vulnerability
+// Scan) → Publish.
+type Pipeline struct {
+	cfg               Config
+	out               io.Writer
+	tracker           *Tracker
+	report            *Report
+	ran               bool
+	commit            string
+	imageTag          string              // versioned tag of the built image, once computed
+	registryToken     string              // Config.RegistryToken until publish re-authenticates
+	localPackageDirs  []string            // local packages installed before the project, in order
+	outcomes          map[string]string   // "<stage>:<test id>" → outcome, for the flaky-test history
+	cacheBuster       string              // unique per run; busts Dagger's cache when NoCache is set
+	changedOnly       bool                // ChangedOnlyTests narrowed the test stages to changedTests
+	changedTests      []string            // test files affected by the changes, relative to the project
+	lintScope         LintScope           // files the lint and type-check stages check
+	lintHistory       *LintHistory        // loaded by the first full-repository lint stage
+	lintRun           LintRun             // this run's full-repository finding counts, for the lint history
+	testVenv          string              // host copy of the build environment for the host test stages (ExportTestVenv)
+	dbSeeded          bool                // DBSeedCommand ran against the external database
+	caUpdate          *dagger.Container   // the update-ca-certificates exec of the build environment, checked by verifyCATrust
+	hashLock          *HashLock           // the lockfile of HashCheckedInstall, read by readHashLock
+	hashInstall       *dagger.Container   // the lockfile install of the build environment, checked by verifyHashedInstall
+	systemBase        *dagger.Container   // the system environment before any pip install, checked by probeIndex
+	scripts           map[string]string   // [project.scripts] of pyproject.toml, checked against the image entrypoint
+	acceptance        *acceptanceTarget   // the running image acceptance tests call (AcceptanceImage)
+	labels            map[string]string   // labels of the Docker Build stage's image, from imageLabels
+	events            *EventLog           // EventsFile while the run writes it, from startEvents
+	emptyStages       map[string]bool     // stages that passed without running anything, which the publish policy does not count
+	imageLayers       []ImageLayer        // layers of the built image, for the upload progress of a large image
+	platformVariants  []*dagger.Container // the images of Platforms after the first, published with it as a manifest list
+	pythonVersionEnvs []*dagger.Container // the build environment per matrix version of PythonVersions, built on first use
+	pythonWarnings    []PythonWarning     // warnings of the test stages so far, most frequent first
+	warningsCollected bool                // a test stage's output was parsed for warnings
+}
+
+// New validates cfg, applies defaults and returns a pipeline ready to Run.
+// Validation failures are CategoryConfig errors.
+func New(cfg Config) (*Pipeline, error) {
+	if cfg.RepoName == "" {
+		return nil, Errorf(CategoryConfig, "RepoName is required (e.g. 'cert-parser')")
+	}
+	if cfg.GitUser == "" {
+		return nil, Errorf(CategoryConfig, "GitUser is required")
+	}
+	return newPipeline(cfg)
+}
+
+// newPipeline is New without the repository it clones and publishes to,
+// which Watch does not need.
+func newPipeline(cfg Config) (*Pipeline, error) {
+	if cfg.RunPublish && !cfg.RunBuild {
+		return nil, Errorf(CategoryConfig, "RunPublish requires RunBuild (nothing to publish)")
+	}
+	if cfg.PublishTestImage && !cfg.RunPublish {
+		return nil, Errorf(CategoryConfig, "PublishTestImage requires RunPublish")
+	}
+	if cfg.PublishPRImage {
+		switch {
+		case cfg.PullRequest == nil:
+			return nil, Errorf(CategoryConfig, "PublishPRImage requires PullRequest (a pull request build)")
+		case !cfg.RunBuild:
+			return nil, Errorf(CategoryConfig, "PublishPRImage requires RunBuild (nothing to publish)")
+		case cfg.RunPublish:
+			return nil, Errorf(CategoryConfig, "PublishPRImage replaces RunPublish: a pull request build never publishes the versioned and latest tags")
+		case cfg.RegistryToken == "":
+			return nil, Errorf(CategoryConfig, "RegistryToken is required when publishing")
+		case cfg.PullRequest.Fork && !cfg.AllowForkPRImage:
+			return nil, Errorf(CategoryConfig, "refusing to publish an image for pull request #%d from fork %s: its untrusted code would run with the registry token (AllowForkPRImage overrides)",
+				cfg.PullRequest.Number, cfg.PullRequest.HeadRepo)
+		}
+	}
+	if cfg.RunPublish && cfg.RegistryToken == "" {
+		return nil, Errorf(CategoryConfig, "RegistryToken is required when publishing")
+	}
+	if cfg.PackagePublish.Upload && cfg.PackagePublish.Password == "" {
+		return nil, Errorf(CategoryConfig, "PackagePublish.Password is required when uploading packages")
+	}
+
+	if cfg.DockleFailLevel == "" {
+		cfg.DockleFailLevel = DockleFatal
+	}
+	cfg.DockleFailLevel = strings.ToUpper(cfg.DockleFailLevel)
+	if !ValidDockleLevel(cfg.DockleFailLevel) {
+		return nil, Errorf(CategoryConfig, "invalid DockleFailLevel %q (use FATAL, WARN or INFO)", cfg.DockleFailLevel)
+	}
+	if cfg.EntrypointCheck == "" {
+		cfg.EntrypointCheck = EntrypointCheckWarn
+	}
+	cfg.EntrypointCheck = strings.ToLower(cfg.EntrypointCheck)
+	if !ValidEntrypointCheck(cfg.EntrypointCheck) {
+		return nil, Errorf(CategoryConfig, "invalid EntrypointCheck %q (use warn, fail or off)", cfg.EntrypointCheck)
+	}
+	resolutions, err := ParseDepResolutions(strings.Join(cfg.DepResolutions, ","))
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "invalid DepResolutions: %w", err)
+	}
+	cfg.DepResolutions = resolutions
+	if slices.Contains(resolutions, ResolutionLowest) && !cfg.RunUnitTests {
+		return nil, Errorf(CategoryConfig, "DepResolutions %s requires RunUnitTests (the unit tests are what runs against it)", ResolutionLowest)
+	}
+	if err := validateBuildContainer(cfg); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if cfg.BaseImage == "" {
+		cfg.BaseImage = BaseImage
+	}
+	if cfg.DatabaseURL != "" {
+		if err := validateDatabaseURL(cfg.DatabaseURL); err != nil {
+			return nil, Errorf(CategoryConfig, "%w", err)
+		}
+	}
+	if err := validateToolOverrides(cfg.ToolImages); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if err := validateStageEnv(cfg.StageEnv); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+
+	if cfg.PublishRequires == nil {
+		cfg.PublishRequires = DefaultPublishRequires
+	}
+	requires := make([]string, 0, len(cfg.PublishRequires))
+	for _, name := range cfg.PublishRequires {
+		stage, err := PublishStageName(name)
+		if err != nil {
+			return nil, Errorf(CategoryConfig, "%w", err)
+		}
+		if !slices.Contains(requires, stage) {
+			requires = append(requires, stage)
+		}
+	}
+	cfg.PublishRequires = requires
+	if cfg.StatusListen != "" {
+		if _, err := StatusListenAddr(cfg.StatusListen, cfg.StatusAllowRemote); err != nil {
+			return nil, Errorf(CategoryConfig, "%w", err)
+		}
+	}
+	if (cfg.DockerHubUser == "") != (cfg.DockerHubToken == "") {
+		return nil, Errorf(CategoryConfig, "DockerHubUser and DockerHubToken must be set together")
+	}
+	if cfg.SourceDateEpoch < 0 {
+		return nil, Errorf(CategoryConfig, "SourceDateEpoch must not be negative")
+	}
+	if cfg.StageMemoryLimit < 0 {
+		return nil, Errorf(CategoryConfig, "StageMemoryLimit must not be negative")
+	}
+	if cfg.TestUser != "" {
+		if _, _, err := ParseTestUser(cfg.TestUser); err != nil {
+			return nil, Errorf(CategoryConfig, "TestUser: %w", err)
+		}
+	}
+	if cfg.TestUmask != "" && !ValidUmask(cfg.TestUmask) {
+		return nil, Errorf(CategoryConfig, "invalid TestUmask %q (use an octal umask such as 027)", cfg.TestUmask)
+	}
+	if cfg.ImageSizeBudget < 0 {
+		return nil, Errorf(CategoryConfig, "ImageSizeBudget must not be negative")
+	}
+	if cfg.WarningsBudget < 0 {
+		return nil, Errorf(CategoryConfig, "WarningsBudget must not be negative")
+	}
+	if cfg.ImageSizeMaxGrowth < 0 {
+		return nil, Errorf(CategoryConfig, "ImageSizeMaxGrowth must not be negative")
+	}
+	if cfg.MinFreeSpace == 0 {
+		cfg.MinFreeSpace = DefaultMinFreeSpace
+	}
+	if cfg.PublishAttempts < 0 || cfg.PublishTimeout < 0 {
+		return nil, Errorf(CategoryConfig, "PublishAttempts and PublishTimeout must not be negative")
+	}
+
+	if cfg.GitHost == "" {
+		cfg.GitHost = "github.com"
+	}
+	if cfg.Warnings == nil {
+		cfg.Warnings = NewWarnings()
+	}
+	if cfg.NameCheck == "" {
+		cfg.NameCheck = NameCheckWarn
+	}
+	if _, err := ParseNameCheck(cfg.NameCheck); err != nil {
+		return nil, Errorf(CategoryConfig, "%v", err)
+	}
+	for key := range cfg.ImageLabels {
+		if !imageLabelKeyPattern.MatchString(key) {
+			return nil, Errorf(CategoryConfig, "invalid image label key %q", key)
+		}
+	}
+	if cfg.CommitCheck == "" {
+		cfg.CommitCheck = CommitCheckWarn
+	}
+	if _, err := ParseCommitCheck(cfg.CommitCheck); err != nil {
+		return nil, Errorf(CategoryConfig, "%v", err)
+	}
+	if cfg.BehindWarnThreshold == 0 {
+		cfg.BehindWarnThreshold = DefaultBehindWarnThreshold
+	}
+	if cfg.ClockSkewThreshold == 0 {
+		cfg.ClockSkewThreshold = DefaultClockSkewThreshold
+	}
+	branch, err := NormalizeGitBranch(cfg.GitBranch)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "GitBranch %v", err)
+	}
+	cfg.GitBranch = branch
+	tag, err := NormalizeGitTag(cfg.GitTag)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "GitTag %v", err)
+	}
+	cfg.GitTag = tag
+	switch {
+	case cfg.GitTag != "" && cfg.GitBranch != "":
+		return nil, Errorf(CategoryConfig, "set only one of GitBranch (%s) and GitTag (%s)", cfg.GitBranch, cfg.GitTag)
+	case cfg.GitBranch == "" && cfg.GitTag == "":
+		cfg.GitBranch = mainBranch
+	}
+	if cfg.GitAuthUser == "" {
+		cfg.GitAuthUser = "x-access-token"
+	}
+	if cfg.GitDependencyToken == "" {
+		cfg.GitDependencyToken = cfg.GitToken
+	}
+	if len(cfg.GitDependencyHosts) == 0 {
+		cfg.GitDependencyHosts = []GitCredentialHost{{Host: cfg.GitHost, User: cfg.GitAuthUser}}
+	}
+	if cfg.GitRepo == "" {
+		cfg.GitRepo = fmt.Sprintf("https://%s/%s/%s.git", cfg.GitHost, cfg.GitUser, cfg.RepoName)
+	}
+	if cfg.Registry == "" {
+		cfg.Registry = "ghcr.io"
+	}
+	cfg.Registry = strings.TrimRight(cfg.Registry, "/")
+	if cfg.RegistryNamespace == "" {
+		cfg.RegistryNamespace = cfg.GitUser
+	}
+	if cfg.RegistryUser == "" {
+		cfg.RegistryUser = cfg.GitUser
+	}
+	if cfg.ReleaseVersion != "" {
+		version, err := ParseReleaseVersion(cfg.ReleaseVersion)
+		if err != nil {
+			return nil, Errorf(CategoryConfig, "ReleaseVersion %v", err)
+		}
+		cfg.ReleaseVersion = version
+	}
+	if cfg.ReleaseAuthor == "" {
+		cfg.ReleaseAuthor = DefaultReleaseAuthor
+	}
+	if err := validateRelease(cfg); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	for _, h := range cfg.GitDependencyHosts {
+		if err := validGitCredentialUser(h.User); err != nil {
+			return nil, Errorf(CategoryConfig, "GitDependencyHosts %s: %w", h.Host, err)
+		}
+	}
+	if err := validateLocalSource(cfg); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if cfg.ProjectRoot == "" {
+		cfg.ProjectRoot = ".."
+		if cfg.LocalSource != "" {
+			cfg.ProjectRoot = cfg.LocalSource
+		}
+	}
+	if cfg.ArtifactsDir == "" {
+		cfg.ArtifactsDir = DefaultArtifactsDir
+	}
+	if cfg.VenvDir == "" {
+		cfg.VenvDir = filepath.Join(cfg.ArtifactsDir, TestVenvDir)
+	}
+	if Within(cfg.ProjectRoot, cfg.VenvDir) && !Within(cfg.ArtifactsDir, cfg.VenvDir) {
+		return nil, Errorf(CategoryConfig, "invalid VenvDir %q: it is inside the checkout %s; use a directory outside it or under ArtifactsDir", cfg.VenvDir, cfg.ProjectRoot)
+	}
+	if cfg.ImageSizeMaxGrowth == 0 {
+		cfg.ImageSizeMaxGrowth = DefaultImageSizeMaxGrowth
+	}
+	if cfg.HashLockFile == "" {
+		cfg.HashLockFile = DefaultHashLockFile
+	}
+	if cfg.StageArtifactsLimit == 0 {
+		cfg.StageArtifactsLimit = DefaultStageArtifactsLimit
+	}
+	for _, platform := range cfg.Platforms {
+		if !platformPattern.MatchString(platform) {
+			return nil, Errorf(CategoryConfig, "Platforms: %q is not an os/arch platform such as linux/arm64", platform)
+		}
+	}
+	for _, version := range cfg.PythonVersions {
+		if !pythonVersionPattern.MatchString(version) {
+			return nil, Errorf(CategoryConfig, "PythonVersions: %q is not a minor Python version such as 3.13", version)
+		}
+	}
+	matrix, err := matrixVersions(cfg)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "PythonVersions: %w", err)
+	}
+	if len(matrix) > 0 && !cfg.RunUnitTests && !cfg.RunLint && !cfg.RunTypeCheck {
+		return nil, Errorf(CategoryConfig, "PythonVersions runs the unit-test, lint and type-check stages, and all three are disabled")
+	}
+	if cfg.LargeImageThreshold == 0 {
+		cfg.LargeImageThreshold = DefaultLargeImageThreshold
+	}
+	if cfg.PublishAttempts == 0 {
+		cfg.PublishAttempts = DefaultPublishAttempts
+	}
+	if cfg.PublishTimeout == 0 {
+		cfg.PublishTimeout = DefaultPublishTimeout
+	}
+	if cfg.DeployVerifyURL != "" && cfg.DeployWebhook == "" {
+		return nil, Errorf(CategoryConfig, "DeployVerifyURL requires DeployWebhook: verification follows the deployment trigger")
+	}
+	if cfg.DeployVerifyTimeout == 0 {
+		cfg.DeployVerifyTimeout = DefaultDeployVerifyTimeout
+	}
+	if cfg.DeployVerifyInterval == 0 {
+		cfg.DeployVerifyInterval = DefaultDeployVerifyInterval
+	}
+	if cfg.FlakyHistoryFile == "" {
+		cfg.FlakyHistoryFile = filepath.Join(cfg.ArtifactsDir, "history", TestHistoryFile)
+	}
+	if cfg.LintHistoryFile == "" {
+		cfg.LintHistoryFile = filepath.Join(cfg.ArtifactsDir, "history", LintHistoryFile)
+	}
+	if cfg.FlakyWindow == 0 {
+		cfg.FlakyWindow = DefaultFlakyWindow
+	}
+	if cfg.FlakyThreshold == 0 {
+		cfg.FlakyThreshold = DefaultFlakyThreshold
+	}
+	if cfg.FlakyWindow < 2 || cfg.FlakyThreshold < 0 || cfg.FlakyThreshold > 1 {
+		return nil, Errorf(CategoryConfig, "invalid flaky-test settings: FlakyWindow must be at least 2 and FlakyThreshold between 0 and 1")
+	}
+	if cfg.DiffBase == "" {
+		cfg.DiffBase = mainBranch
+	}
+	if cfg.LintChangedMax == 0 {
+		cfg.LintChangedMax = DefaultLintChangedMax
+	}
+	if cfg.LintChangedMax < 0 {
+		return nil, Errorf(CategoryConfig, "invalid LintChangedMax %d: must be positive", cfg.LintChangedMax)
+	}
+	if cfg.AcceptanceImagePort < 0 || cfg.AcceptanceImagePort > 65535 {
+		return nil, Errorf(CategoryConfig, "invalid AcceptanceImagePort %d: must be a TCP port", cfg.AcceptanceImagePort)
+	}
+	if err := ValidateServiceReadyURL(cfg.ServiceReadyURL); err != nil {
+		return nil, Errorf(CategoryConfig, "ServiceReadyURL %v", err)
+	}
+	if cfg.ServiceReadyPoll < 0 || cfg.ServiceReadyTimeout < 0 {
+		return nil, Errorf(CategoryConfig, "ServiceReadyPoll and ServiceReadyTimeout must not be negative")
+	}
+	if cfg.ServiceReadyURL == "" {
+		cfg.ServiceReadyURL = ServiceReadyTCP
+	}
+	if cfg.ServiceReadyPoll == 0 {
+		cfg.ServiceReadyPoll = DefaultServiceReadyInterval
+	}
+	if cfg.ServiceReadyTimeout == 0 {
+		cfg.ServiceReadyTimeout = AcceptanceStartTimeout
+	}
+	if cfg.PackagePublish.ExportDir == "" {
+		cfg.PackagePublish.ExportDir = filepath.Join(cfg.ArtifactsDir, "dist")
+	}
+	if cfg.HTTPClient == nil {
+		client, err := NewHTTPClient(HTTPClientConfig{ProxyURL: cfg.ProxyURL, CACertPaths: cfg.CACertPaths})
+		if err != nil {
+			return nil, Errorf(CategoryConfig, "%w", err)
+		}
+		cfg.HTTPClient = client
+	}
+	if cfg.Offline {
+		if problems := OfflineProblems(cfg); len(problems) > 0 {
+			return nil, Errorf(CategoryConfig, "offline mode cannot run without the internet:\n  - %s", strings.Join(problems, "\n  - "))
+		}
+		cfg.HTTPClient = offlineClient(cfg.HTTPClient, cfg.OfflineAllowHosts)
+	}
+	out := cfg.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	return &Pipeline{
+		cfg:           cfg,
+		out:           out,
+		tracker:       NewTracker(cfg.StatusFile),
+		report:        &Report{Config: EffectiveConfig(cfg), Profile: cfg.Profile, OptionSources: cfg.OptionSources, PythonVersions: matrix},
+		registryToken: cfg.RegistryToken,
+		outcomes:      map[string]string{},
+		cacheBuster:   time.Now().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// Config returns the configuration with defaults applied.
+func (p *Pipeline) Config() Config {
+	return p.cfg
+}
+
+// Tracker returns the stage tracker, e.g. to attach CI log sections before Run.
+func (p *Pipeline) Tracker() *Tracker {
+	return p.tracker
+}
+
+// Run executes the pipeline once. The returned report has the same shape as
+// pipeline-report.json and is non-nil even when err is not; Dagger versions
+// are left for the caller to fill in. Run never exits the process.
+func (p *Pipeline) Run(ctx context.Context, client *dagger.Client) (*Report, error) {
+	if p.ran {
+		return nil, Errorf(CategoryConfig, "pipeline already ran; create a new one with New")
+	}
+	p.ran = true
+
+	if p.cfg.StatusListen != "" {
+		if server, err := p.serveStatus(); err != nil {
+			// The dashboard is a convenience; the run goes on without it
+			p.warnf("status-server", "⚠️  %v\n", err)
+		} else {
+			defer server.Close()
+			defer func() { server.SetReport(p.report) }()
+		}
+	}
+	finishEvents := p.startEvents()
+
+	var worktree WorktreeSnapshot
+	root, err := filepath.Abs(p.cfg.ProjectRoot)
+	if err == nil && p.cfg.Paranoid {
+		if worktree, err = SnapshotWorktree(ctx, root); err != nil {
+			err = Errorf(CategoryConfig, "paranoid mode needs a git checkout at ProjectRoot: %w", err)
+		}
+	}
+	if err == nil {
+		err = p.checkFreeSpace()
+	}
+	if err == nil {
+		err = p.run(ctx, client)
+		if stage := p.tracker.Snapshot().CurrentStage; err != nil && stage != "" {
+			// Name the failing stage in the error the main function prints
+			err = Errorf(CategoryOf(err), "stage %s: %w", stage, err)
+		}
+		if err != nil && CategoryOf(err) != CategoryCancelled {
+			p.explainKilled(p.tracker.Snapshot().CurrentStage, ClassifyExit(err))
+			p.explainRateLimit(err)
+			p.printReproduction(p.tracker.Snapshot().CurrentStage)
+		}
+	}
+	p.recordTestHistory(ctx)
+	p.recordLintHistory()
+	p.recordDiskUsage()
+	if worktree != nil {
+		if werr := p.checkWorktree(ctx, root, worktree); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	err = p.finishWarnings(err)
+	if err == nil {
+		p.recordLastBuild()
+	}
+	p.tracker.Finish(err)
+	p.report.Status = p.tracker.Snapshot()
+	for i := range p.report.Status.Stages {
+		if stage := &p.report.Status.Stages[i]; stage.Status != StageSkipped {
+			stage.Reproduce = p.reproduction(stage.Name)
+		}
+	}
+	p.printPythonVersions()
+	p.printPythonWarnings()
+	p.report.Outcome = Outcome(p.report.Status, p.report.TestSkips)
+	if p.report.Outcome == RunOutcomePassedWithSkips {
+		p.printf("\n⚠️  Passed with skips: %d enabled stage(s) did not run\n", len(p.report.TestSkips))
+	}
+	p.reportToGitHub(ctx)
+	p.writeStagesJUnit()
+	finishEvents(err)
+	return p.report, err
+}
+
+func (p *Pipeline) printf(format string, args ...any) {
+	fmt.Fprintf(p.out, format, args...)
+}
+
+func (p *Pipeline) println(args ...any) {
+	fmt.Fprintln(p.out, args...)
+}
+
+// gitRepo is the configured repository. The token is optional when not
+// publishing — public repositories clone without auth.
+func (p *Pipeline) gitRepo(client *dagger.Client) *dagger.GitRepository {
+	gitOpts := dagger.GitOpts{KeepGitDir: true}
+	if p.cfg.GitToken != "" {
+		gitOpts.HTTPAuthToken = client.SetSecret("github-pat", p.cfg.GitToken)
+		gitOpts.HTTPAuthUsername = p.cfg.GitAuthUser
+	}
+	return client.Git(p.cfg.GitRepo, gitOpts)
+}
+
+func (p *Pipeline) run(ctx context.Context, client *dagger.Client) error {
+	cfg := p.cfg
+	if cfg.TimingDetail && cfg.ConnectTime > 0 {
+		p.recordDaggerTiming("connect", cfg.ConnectTime, nil)
+	}
+	p.fingerprint(ctx, client)
+	if err := p.checkClockSkew(ctx); err != nil {
+		return err
+	}
+	if err := p.checkRegistryMirrors(ctx); err != nil {
+		return err
+	}
+
+	// ── Clone repository, or take the local source ──────────────
+	repo := p.gitRepo(client)
+	var source *dagger.Directory
+	var commitSHA string
+	if cfg.LocalSource != "" {
+		var err error
+		if source, commitSHA, err = p.localSource(ctx, client); err != nil {
+			return err
+		}
+	} else {
+		p.printf("\n📥 Cloning repository: %s (%s: %s)\n", cfg.GitRepo, p.checkoutKind(), gitRefName(cfg))
+		ref := p.checkout(repo)
+		if cfg.ReleaseVersion != "" {
+			released, err := p.release(ctx, client, repo, ref)
+			if err != nil {
+				return err
+			}
+			if released == nil {
+				return nil // dry run
+			}
+			ref = released
+		}
+		source = ref.Tree()
+		err := p.timeDagger("git commit", func() (err error) {
+			commitSHA, err = ref.Commit(ctx)
+			return err
+		})
+		if err != nil {
+			return Errorf(CategoryClone, "failed to get commit SHA: %w%s", err, p.cloneCredentialHint())
+		}
+	}
+
+	if cfg.ProjectDir != "" {
+		p.printf("   Project directory: %s\n", cfg.ProjectDir)
+		source = source.Directory(cfg.ProjectDir)
+	}
+	p.syncPoint(ctx, "git tree", func(ctx context.Context) error { _, err := source.Sync(ctx); return err })
+
+	p.commit = commitSHA
+	p.printf("   Commit: %s\n", commitSHA[:min(12, len(commitSHA))])
+	// A local source is not on the Git host, and its uncommitted changes are
+	// never the same as an earlier build's
+	if cfg.LocalSource == "" {
+		if p.skipUnchanged(ctx, commitSHA) {
+			return nil
+		}
+		if err := p.checkBranchLag(ctx, commitSHA); err != nil {
+			return err
+		}
+		if err := p.checkCommitReachable(ctx, commitSHA, false); err != nil {
+			return err
+		}
+	}
+
+	// ── Discover project name from pyproject.toml ────────────────
+	p.println("🔍 Discovering project name from pyproject.toml...")
+
+	var rawPyproject string
+	err := p.timeDagger("pyproject.toml contents", func() (err error) {
+		rawPyproject, err = source.File("pyproject.toml").Contents(ctx)
+		return err
+	})
+	if err != nil {
+		return Errorf(CategoryConfig, "failed to read pyproject.toml: %w", err)
+	}
+	p.report.Environment.DependencyHash = sha256Hex(rawPyproject)
+	pyprojectContent, pyproject, err := p.readPyproject(rawPyproject)
+	if err != nil {
+		return err
+	}
+
+	if err := p.resolveLocalFrameworks(sourceExists(ctx, source)); err != nil {
+		return err
+	}
+	if err := p.readHashLock(ctx, source); err != nil {
+		return err
+	}
+
+	p.scripts = ProjectScripts(pyprojectContent)
+	projectName := pyproject.Name
+	if projectName == "" {
+		projectName = cfg.RepoName
+		p.warnf("project-name", "   ⚠️  Could not parse name from pyproject.toml, using repo name: %s\n", projectName)
+	} else {
+		p.printf("   Project name: %s\n", projectName)
+		if err := p.checkProjectName(projectName); err != nil {
+			return err
+		}
+	}
+
+	imageName := cfg.ImageName
+	if imageName == "" {
+		imageName = DockerSafeName(projectName)
+	}
+	if err := p.checkImageRef(imageName); err != nil {
+		return err
+	}
+
+	// An external database replaces testcontainers, so Docker is not needed
+	dbTests := cfg.HasDocker || cfg.DatabaseURL != ""
+	if cfg.DatabaseURL != "" && (cfg.RunIntegrationTests || cfg.RunAcceptanceTests) {
+		p.report.ExternalDatabase = RedactDatabaseURL(cfg.DatabaseURL)
+		p.printf("   🐘 External PostgreSQL: %s (DATABASE_URL_OVERRIDE — testcontainers bypassed)\n", p.report.ExternalDatabase)
+	}
+	if (cfg.RunIntegrationTests || cfg.RunAcceptanceTests) && !dbTests {
+		if err := p.skipDockerTests(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.ChangedOnlyTests && (cfg.RunUnitTests || cfg.RunSlowTests || cfg.RunBenchmarks || cfg.RunIntegrationTests || cfg.RunAcceptanceTests) {
+		p.selectChangedTests(ctx, source)
+	}
+
+	// ── Set up build environment (Dagger container) ──────────────
+	if len(cfg.CACertPaths) > 0 || cfg.ProxyURL != "" {
+		p.println("🔨 Setting up Python build environment with corporate CA support...")
+	} else {
+		p.println("🔨 Setting up Python build environment...")
+	}
+	builder := p.buildEnv(client, source)
+	installed := builder // before any stage ran, the base of the test image
+	p.syncPoint(ctx, "build environment (first exec, cache mounts)", func(ctx context.Context) error { _, err := builder.Sync(ctx); return err })
+	if err := p.verifyCATrust(ctx); err != nil {
+		p.println("\n❌ PIPELINE FAILED: corporate CA certificates could not be installed")
+		return err
+	}
+	if err := p.probeIndex(ctx); err != nil {
+		p.println("\n❌ PIPELINE FAILED: the package index is not reachable from the build container")
+		return err
+	}
+	if err := p.verifyHashedInstall(ctx); err != nil {
+		return err
+	}
+
+	// ── Record resolved dependency versions ──────────────────────
+	p.println("📦 Recording resolved dependencies (pip freeze)...")
+	if err := p.captureFreeze(ctx, builder); err != nil {
+		return err
+	}
+	if cfg.ExportTestVenv && dbTests && (cfg.RunIntegrationTests || cfg.RunAcceptanceTests) {
+		p.exportTestVenv(ctx, builder)
+	}
+
+	// ── Stage: Unit Tests (inside Dagger container) ──────────────
+	if cfg.RunUnitTests {
+		p.tracker.StartStage(StageUnitTests)
+		p.stageBanner(StageUnitTests)
+		if len(cfg.CACertPaths) > 0 || cfg.ProxyURL != "" {
+			p.println("📍 Location: Dagger container (isolated, CA certs + proxy configured)")
+		} else {
+			p.println("📍 Location: Dagger container (isolated, no Docker needed)")
+		}
+		p.printf("🧪 Running: pytest -m %q\n", p.unitStageMarker())
+		p.println(separatorLine)
+
+		matrix := p.startPythonMatrix(ctx, client, source, StageUnitTests, p.containerTestCmd("unit", p.unitStageMarker()))
+		unitBuilder := p.withStageEnv(client, StageUnitTests, p.freshFor(StageUnitTests, builder))
+		testContainer, ran, err := p.runContainerTests(ctx, unitBuilder, p.UnitTests(unitBuilder), "unit", p.unitStageMarker())
+		lowest := slices.Contains(cfg.DepResolutions, ResolutionLowest)
+		if err != nil {
+			matrix.stop()
+			if lowest {
+				p.recordResolution(ResolutionHighest, "failed")
+				p.recordResolution(ResolutionLowest, "not run")
+				p.printResolutions()
+			}
+			p.stageFailed(StageUnitTests)
+			return testStageError("unit tests", err)
+		}
+		if lowest {
+			if ran {
+				p.recordResolution(ResolutionHighest, "passed")
+				err = p.runLowestResolution(ctx, client, source)
+			} else {
+				p.recordResolution(ResolutionHighest, "no tests")
+				p.recordResolution(ResolutionLowest, "not run")
+			}
+			p.printResolutions()
+			if err != nil {
+				matrix.stop()
+				p.stageFailed(StageUnitTests)
+				return err
+			}
+		}
+		if err := p.finishPythonMatrix(matrix); err != nil {
+			p.stageFailed(StageUnitTests)
+			return err
+		}
+		if ran {
+			p.stageComplete(StageUnitTests, "All unit tests passed")
+		} else {
+			p.stageComplete(StageUnitTests, "No unit tests (%s)", p.emptyStageNote())
+			p.markEmpty(StageUnitTests)
+		}
+		p.tracker.PassStage()
+
+		builder = p.withoutStageEnv(ctx, StageUnitTests, builder, testContainer)
+	}
+
+	// ── Stages: Slow Tests and Benchmarks (inside Dagger container) ──
+	for _, stage := range []struct {
+		enabled             bool
+		junit, name, marker string
+		label               string
+	}{
+		{cfg.RunSlowTests, "slow", StageSlowTests, slowMarker, "slow tests"},
+		{cfg.RunBenchmarks, "benchmark", StageBenchmarks, benchmarkMarker, "benchmarks"},
+	} {
+		if !stage.enabled {
+			continue
+		}
+		p.tracker.StartStage(stage.name)
+		p.stageBanner(stage.name)
+		p.printf("🧪 Running: pytest -m %q\n", stage.marker)
+		p.println(separatorLine)
+
+		stageBuilder := p.withStageEnv(client, stage.name, p.freshFor(stage.name, builder))
+		testContainer, ran, err := p.runContainerTests(ctx, stageBuilder, p.containerTests(stageBuilder, stage.junit, stage.marker), stage.junit, stage.marker)
+		if err != nil {
+			p.stageFailed(stage.name)
+			return testStageError(stage.label, err)
+		}
+		if ran {
+			p.stageComplete(stage.name, "All %s passed", stage.label)
+		} else {
+			p.stageComplete(stage.name, "No %s (%s)", stage.label, p.emptyStageNote())
+			p.markEmpty(stage.name)
+		}
+		p.tracker.PassStage()
+		builder = p.withoutStageEnv(ctx, stage.name, builder, testContainer)
+	}
+
+	// ── Stage: Integration Tests (on host — testcontainers needs Docker) ──
+	if cfg.RunIntegrationTests && dbTests {
+		p.tracker.StartStage(StageIntegrationTests)
+		p.stageBanner(StageIntegrationTests)
+		p.printDatabaseBanner()
+		p.println("🧪 Running: pytest -v --tb=short -m integration")
+		p.println(separatorLine)
+
+		ran, err := p.runTestsOnHost(ctx, integrationMarker)
+		if err != nil {
+			p.stageFailed(StageIntegrationTests)
+			return testStageError("integration tests", err)
+		}
+		if ran {
+			p.stageComplete(StageIntegrationTests, "All integration tests passed")
+		} else {
+			p.stageComplete(StageIntegrationTests, "No integration tests (%s)", p.emptyStageNote())
+			p.markEmpty(StageIntegrationTests)
+		}
+		p.tracker.PassStage()
+	} else if cfg.RunIntegrationTests {
+		p.stageSkippedBanner(StageIntegrationTests)
+		p.printf("   ⏭️  %s\n", dockerSkipReason(cfg.DockerDetection))
+		p.tracker.SkipStage(StageIntegrationTests, dockerSkipReason(cfg.DockerDetection))
+	}
+
+	// ── Stage: Acceptance Tests (on host — testcontainers needs Docker) ──
+	if cfg.RunAcceptanceTests && dbTests {
+		p.tracker.StartStage(StageAcceptanceTests)
+		p.stageBanner(StageAcceptanceTests)
+		p.printDatabaseBanner()
+		p.report.AcceptanceTarget = AcceptanceEditable
+		if cfg.AcceptanceImage {
+			if err := p.startAcceptanceImage(ctx, client, source); err != nil {
+				// An image that never got ready leaves its logs as an artifact
+				p.stopAcceptanceImage(ctx, true)
+				p.stageFailed(StageAcceptanceTests)
+				return err
+			}
+			p.report.AcceptanceTarget = p.acceptance.mode
+		}
+		p.printf("🎯 Target: %s\n", p.report.AcceptanceTarget)
+		p.println("📦 Fixtures: Real ICAO .bin/.der fixtures used for end-to-end verification")
+		p.println("🧪 Running: pytest -v --tb=short -m acceptance")
+		p.println(separatorLine)
+
+		ran, err := p.runTestsOnHost(ctx, acceptanceMarker)
+		p.stopAcceptanceImage(ctx, err != nil)
+		if err != nil {
+			p.stageFailed(StageAcceptanceTests)
+			return testStageError("acceptance tests", err)
+		}
+		if ran {
+			p.stageComplete(StageAcceptanceTests, "All acceptance tests passed")
+		} else {
+			p.stageComplete(StageAcceptanceTests, "No acceptance tests (%s)", p.emptyStageNote())
+			p.markEmpty(StageAcceptanceTests)
+		}
+		p.tracker.PassStage()
+	} else if cfg.RunAcceptanceTests {
+		p.stageSkippedBanner(StageAcceptanceTests)
+		p.printf("   ⏭️  %s\n", dockerSkipReason(cfg.DockerDetection))
+		p.tracker.SkipStage(StageAcceptanceTests, dockerSkipReason(cfg.DockerDetection))
+	}
+
+	if err := p.checkPythonWarnings(); err != nil {
+		return err
+	}
+
+	if cfg.LintChangedOnly && (cfg.RunLint || cfg.RunTypeCheck) {
+		p.selectLintScope(ctx, repo, source)
+	}
+
+	// ── Stage: Lint ──────────────────────────────────────────────
+	if cfg.RunLint {
+		p.tracker.StartStage(StageLint)
+		p.stageBanner(StageLint)
+		mode := p.lintMode(ctx, source, "ruff")
+		p.printf("📋 Mode: %s\n", mode)
+
+		if cmd := p.lintCommand("ruff"); cmd == nil {
+			p.println("   ⏭️  No changed files under src/ or tests/")
+			p.markEmpty(StageLint)
+		} else {
+			p.printf("🔍 Running %s\n", shellJoin(cmd))
+			matrix := p.startPythonMatrix(ctx, client, source, StageLint, cmd)
+			lintContainer, err := p.runLintTool(ctx, p.withStageEnv(client, StageLint, p.freshFor(StageLint, builder)), "ruff")
+			if err != nil {
+				matrix.stop()
+				p.stageFailed(StageLint)
+				return err
+			}
+			if err := p.finishPythonMatrix(matrix); err != nil {
+				p.stageFailed(StageLint)
+				return err
+			}
+			builder = p.withoutStageEnv(ctx, StageLint, builder, lintContainer)
+		}
+		p.stageComplete(StageLint, "Lint passed (%s)", mode)
+		p.tracker.PassStage()
+	}
+
+	// ── Stage: Type Check ────────────────────────────────────────
+	if cfg.RunTypeCheck {
+		p.tracker.StartStage(StageTypeCheck)
+		p.stageBanner(StageTypeCheck)
+		mode := p.lintMode(ctx, source, "mypy")
+		p.printf("📋 Mode: %s\n", mode)
+
+		if cmd := p.lintCommand("mypy"); cmd == nil {
+			p.println("   ⏭️  No changed files under src/")
+			p.markEmpty(StageTypeCheck)
+		} else {
+			p.printf("🔍 Running %s\n", shellJoin(cmd))
+			matrix := p.startPythonMatrix(ctx, client, source, StageTypeCheck, cmd)
+			if _, err := p.runLintTool(ctx, p.withStageEnv(client, StageTypeCheck, p.freshFor(StageTypeCheck, builder)), "mypy"); err != nil {
+				matrix.stop()
+				p.stageFailed(StageTypeCheck)
+				return err
+			}
+			if err := p.finishPythonMatrix(matrix); err != nil {
+				p.stageFailed(StageTypeCheck)
+				return err
+			}
+		}
+		p.stageComplete(StageTypeCheck, "Type check passed (%s)", mode)
+		p.tracker.PassStage()
+	}
+
+	// ── Stage: Dependency Audit ──────────────────────────────────
+	if cfg.RunDependencyAudit && cfg.Offline {
+		p.skipEnabledStage(StageDependencyAudit, offlineSkipReason("pip-audit queries an online vulnerability service"))
+	} else if cfg.RunDependencyAudit {
+		p.tracker.StartStage(StageDependencyAudit)
+		p.stageBanner(StageDependencyAudit)
+		p.println("🔍 Checking installed dependencies for known vulnerabilities...")
+
+		output, err := DependencyAudit(p.withStageEnv(client, StageDependencyAudit, p.freshFor(StageDependencyAudit, builder))).Stdout(ctx)
+		if err != nil {
+			p.stageFailed(StageDependencyAudit)
+			return Errorf(CategoryLint, "pip-audit found vulnerable dependencies: %w", err)
+		}
+		p.println(output)
+		p.stageComplete(StageDependencyAudit, "No known vulnerabilities in dependencies")
+		p.tracker.PassStage()
+	}
+
+	// ── Stage: Python Package (wheel export / sdist + wheel → package index) ──
+	if cfg.RunPackagePublish || cfg.ExportWheels {
+		stageName := StageExportWheels
+		if cfg.RunPackagePublish {
+			stageName = StagePackagePublish
+		}
+		p.tracker.StartStage(stageName)
+		p.stageBanner(stageName)
+		switch {
+		case cfg.PackagePublish.Upload:
+			p.println("📦 Building sdist + wheel (python -m build) and running twine check...")
+			p.printf("📤 Upload target: %s\n", cfg.PackagePublish.IndexURL)
+		case cfg.RunPackagePublish:
+			p.println("📦 Building sdist + wheel (python -m build) and running twine check...")
+			p.printf("   ℹ️  %s is not branch %s — building and checking only, no upload\n", gitRefName(cfg), PackageUploadBranch)
+		default:
+			p.println("📦 Building wheel in the existing build environment (python -m build --wheel)...")
+		}
+
+		artifacts, err := BuildPythonPackage(ctx, client, p.withStageEnv(client, stageName, builder), AppWorkdir, cfg.PackagePublish)
+		p.report.Artifacts = append(p.report.Artifacts, artifacts...)
+		if err != nil {
+			p.stageFailed(stageName)
+			return err
+		}
+		p.printf("   📁 Exported to %s\n", cfg.PackagePublish.ExportDir)
+		for _, a := range artifacts {
+			p.printf("   📄 %s  sha256:%s\n", a.Name, a.SHA256)
+		}
+		if cfg.PackagePublish.Upload {
+			p.stageComplete(stageName, "Package built and uploaded")
+		} else {
+			p.stageComplete(stageName, "Distributions built and exported")
+		}
+		p.tracker.PassStage()
+	}
+
+	// ── Stage: Docker Build ──────────────────────────────────────
+	shortSHA := commitSHA
+	if len(commitSHA) > 7 {
+		shortSHA = commitSHA[:7]
+	}
+	buildTime := p.buildTimestamp()
+	p.report.BuildTimestamp = &buildTime
+	timestamp := buildTime.TagTimestamp()
+	imageTag := fmt.Sprintf("%s-%s-%s", p.versionTag(pyproject), shortSHA, timestamp)
+	if cfg.GitTag != "" {
+		imageTag = fmt.Sprintf("%s-%s-%s", BranchTag(cfg.GitTag), shortSHA, timestamp)
+	}
+	if cfg.ReleaseVersion != "" {
+		imageTag = ReleaseTag(cfg.ReleaseVersion)
+	}
+	if cfg.RunBuild {
+		p.imageTag = imageTag
+	}
+
+	versionedImage, err := buildImageRef(cfg.Registry, cfg.RegistryNamespace, imageName, imageTag)
+	if err != nil {
+		return Errorf(CategoryConfig, "image reference: %w", err)
+	}
+	latestImage, _ := buildImageRef(cfg.Registry, cfg.RegistryNamespace, imageName, "latest")
+
+	var image *dagger.Container
+	if cfg.RunBuild {
+		p.tracker.StartStage(StageBuild)
+		p.stageBanner(StageBuild)
+		p.println("🐳 Building Docker image from Dockerfile...")
+		if buildTime.Source == TimestampSourceDateEpoch {
+			p.printf("   🕰️  SOURCE_DATE_EPOCH=%d (%s) — tag, created label and build arg are reproducible\n", cfg.SourceDateEpoch, buildTime.Time.Format(time.RFC3339))
+		}
+		version := pyproject.Version
+		if pyproject.DynamicVersion {
+			version = ""
+		}
+		p.labels = p.imageLabels(projectName, version, buildTime.Time)
+		p.report.ImageLabels = p.labels
+		for _, key := range sortedLabelKeys(p.labels) {
+			p.printf("   🏷️  %s=%s\n", key, p.labels[key])
+		}
+
+		image, p.platformVariants = p.buildPlatformImages(source)
+		if err := p.timeDagger("docker build", func() error {
+			for _, container := range append([]*dagger.Container{image}, p.platformVariants...) {
+				if _, err := container.Sync(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			p.stageFailed(StageBuild)
+			return Errorf(CategoryBuild, "docker build failed: %w", err)
+		}
+
+		p.printf("   Image: %s\n", versionedImage)
+		imageSize, err := p.checkImageSize(ctx, client, image)
+		p.report.ImageSize = imageSize
+		if err != nil {
+			p.stageFailed(StageBuild)
+			return err
+		}
+		p.stageComplete(StageBuild, "Docker image built")
+		p.tracker.PassStage()
+
+		// ── Stage: Image Hardening (non-root user, dockle) ───────────
+		p.tracker.StartStage(StageImageHardening)
+		p.stageBanner(StageImageHardening)
+		p.println("🔒 Inspecting image config (user, entrypoint, exposed ports)...")
+
+		hardening, err := p.checkImageHardening(ctx, client, image)
+		p.report.Hardening = hardening
+		if cfg.StepSummary != "" {
+			if werr := AppendStepSummary(cfg.StepSummary, HardeningMarkdown(hardening)); werr != nil {
+				p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", werr)
+			}
+		}
+		if err != nil {
+			for _, problem := range hardening.Problems {
+				p.printf("   ❌ %s\n", problem)
+			}
+			p.stageFailed(StageImageHardening)
+			return err
+		}
+		p.stageComplete(StageImageHardening, "Image hardening check passed")
+		p.tracker.PassStage()
+
+		// ── Stage: Vulnerability Scan (trivy) ────────────────────────
+		if cfg.RunVulnScan && cfg.Offline {
+			p.skipEnabledStage(StageVulnScan, offlineSkipReason("trivy downloads its vulnerability database"))
+		} else if cfg.RunVulnScan {
+			p.tracker.StartStage(StageVulnScan)
+			p.stageBanner(StageVulnScan)
+			p.printf("🔍 Scanning the image for fixable %s vulnerabilities...\n", VulnScanSeverities)
+
+			if err := p.scanVulnerabilities(ctx, client, image); err != nil {
+				p.stageFailed(StageVulnScan)
+				return err
+			}
+			p.stageComplete(StageVulnScan, "No fixable %s vulnerabilities", VulnScanSeverities)
+			p.tracker.PassStage()
+		}
+	} else {
+		p.stageSkippedBanner(StageBuild)
+		p.println("   ⏭️  Docker build disabled (RUN_BUILD=false)")
+		p.tracker.SkipStage(StageBuild, "disabled (RUN_BUILD=false)")
+		if cfg.RunVulnScan {
+			p.println("   ⏭️  Vulnerability scan skipped — no image built")
+			p.tracker.SkipStage(StageVulnScan, "no image built (RUN_BUILD=false)")
+		}
+	}
+
+	// ── Stage: Publish to Registry ───────────────────────────────
+	if cfg.PublishPRImage || cfg.RunPublish {
+		if err := p.checkCommitReachable(ctx, p.commit, true); err != nil {
+			p.printf("\n❌ PIPELINE FAILED BEFORE PUBLISH: %v\n", err)
+			p.tracker.SkipStage(StagePublish, "commit not on the branch")
+			return err
+		}
+	}
+	if cfg.PublishPRImage {
+		prImage, _ := buildImageRef(cfg.Registry, cfg.RegistryNamespace, imageName, PRImageTag(cfg.PullRequest.Number))
+		return p.publishPRImage(ctx, client, image, prImage)
+	}
+	if !cfg.RunPublish {
+		reason := "disabled (RUN_PUBLISH=false)"
+		if !cfg.RunBuild {
+			reason = "no image built (RUN_BUILD=false)"
+		}
+		p.stageSkippedBanner(StagePublish)
+		p.printf("   ⏭️  Publish %s\n", reason)
+		p.tracker.SkipStage(StagePublish, reason)
+		if cfg.DeployWebhook != "" {
+			p.println("⏭️  Skipping deployment webhook — nothing was published")
+		}
+		return nil
+	}
+
+	// ── Publish policy: required stages passed in this run ───────
+	if err := p.checkPublishPolicy(); err != nil {
+		p.printf("\n❌ PIPELINE FAILED BEFORE PUBLISH: %v\n", err)
+		p.tracker.SkipStage(StagePublish, "publish policy not met")
+		return err
+	}
+
+	p.tracker.StartStage(StagePublish)
+	p.stageBanner(StagePublish)
+	p.printf("📤 Publishing to: %s\n", versionedImage)
+
+	var publishedAddress string
+	err = p.timeDagger("publish", func() (err error) {
+		publishedAddress, err = p.publish(ctx, client, image, versionedImage, p.platformVariants...)
+		return err
+	})
+	if err != nil {
+		p.stageFailed(StagePublish)
+		return Errorf(CategoryPublish, "failed to publish versioned image: %w%s", err, p.publishPermissionHint(err))
+	}
+
+	p.report.PublishedImages = []string{publishedAddress}
+	p.events.Emit(Event{Type: EventPublished, Image: publishedAddress})
+
+	// :latest from a feature branch is usually a mistake when run by hand
+	publishLatest := true
+	if cfg.GitBranch != mainBranch && cfg.Confirm != nil {
+		publishLatest = cfg.Confirm(fmt.Sprintf("Publish %s from %s %s (not %s)?", latestImage, p.checkoutKind(), gitRefName(cfg), mainBranch))
+	}
+
+	// The layers are already in the registry: add :latest to the pushed
+	// manifest through the registry API, and only fall back to a second
+	// full Publish when that fails.
+	latestAddress := ""
+	if publishLatest {
+		versionedDigest := imageDigest(publishedAddress)
+		latestDigest, rerr := RetagImage(ctx, cfg.HTTPClient, versionedImage, versionedDigest, "latest", cfg.RegistryUser, p.registryToken)
+		if rerr == nil {
+			latestAddress = latestImage + "@" + latestDigest
+			p.println("   🏷️  Tagged latest via registry API (manifest re-tag, no layer upload)")
+		} else {
+			p.warnf("retag-fallback", "   ⚠️  Re-tag via registry API failed (%v) — publishing latest in full\n", rerr)
+			latestAddress, err = p.publish(ctx, client, image, latestImage, p.platformVariants...)
+			if err != nil {
+				p.stageFailed(StagePublish)
+				return Errorf(CategoryPublish, "failed to publish latest image: %w%s", err, p.publishPermissionHint(err))
+			}
+		}
+		p.report.PublishedImages = append(p.report.PublishedImages, latestAddress)
+		p.events.Emit(Event{Type: EventPublished, Image: latestAddress})
+	}
+	if cfg.PublishTestImage {
+		if err := p.publishTestImage(ctx, client, installed, image, source, versionedImage); err != nil {
+			p.stageFailed(StagePublish)
+			return err
+		}
+	}
+
+	p.stageComplete(StagePublish, "Images published")
+	p.tracker.PassStage()
+	p.printf("   📦 Versioned: %s\n", publishedAddress)
+	if publishLatest {
+		p.printf("   📦 Latest:    %s\n", latestAddress)
+	} else {
+		p.println("   ⏭️  Latest:    not updated (declined)")
+	}
+	if p.report.TestImage != "" {
+		p.printf("   🧪 Tests:     %s\n", p.report.TestImage)
+	}
+	if len(p.platformVariants) > 0 {
+		p.printPlatformDigests(ctx, versionedImage, imageDigest(publishedAddress))
+	}
+	if cfg.PRImageCleanup && cfg.PullRequest == nil {
+		p.cleanupPRImages(ctx, ImageOwner(cfg.RegistryNamespace), DockerSafeName(imageName))
+	}
+
+	if cfg.DeployWebhook != "" {
+		p.println("🚀 Triggering deployment webhook...")
+		webhookErr := p.triggerWebhook(ctx, imageTag, publishedAddress, commitSHA, timestamp)
+		if webhookErr != nil {
+			p.warnf("deploy-webhook", "⚠️  Warning: Deployment trigger failed: %v\n", webhookErr)
+		} else {
+			p.println("✅ Deployment triggered successfully")
+		}
+
+		// ── Stage: Deployment Verification ───────────────────────────
+		if cfg.DeployVerifyURL != "" {
+			p.tracker.StartStage(StageDeployVerify)
+			p.stageBanner(StageDeployVerify)
+			if webhookErr != nil {
+				p.stageFailed(StageDeployVerify)
+				return Errorf(CategoryDeploy, "deployment not triggered, nothing to verify: %w", webhookErr)
+			}
+
+			want := []string{imageTag, shortSHA}
+			if cfg.DeployVerifyMatch != "" {
+				want = []string{cfg.DeployVerifyMatch}
+			}
+			field := "response body"
+			if cfg.DeployVerifyField != "" {
+				field = "field " + cfg.DeployVerifyField
+			}
+			p.printf("🔎 Polling %s every %v (timeout %v)\n", cfg.DeployVerifyURL, cfg.DeployVerifyInterval, cfg.DeployVerifyTimeout)
+			p.printf("   Expecting %s to contain %s\n", field, strings.Join(want, " or "))
+			if err := p.verifyDeployment(ctx, want); err != nil {
+				p.stageFailed(StageDeployVerify)
+				return Errorf(CategoryDeploy, "deployment verification failed: %w", err)
+			}
+			p.stageComplete(StageDeployVerify, "Deployment verified")
+			p.tracker.PassStage()
+		}
+	}
+
+	return nil
+}
+
+// buildEnv creates the Python build container: the system environment,
+// then the local framework and the project with dev+server extras. With a
+// hash lock, the dependencies come from the lockfile only, and the local
+// packages and the project, which cannot be hash-pinned, follow without
+// theirs; pip check then catches a dependency the lockfile lacks.
+func (p *Pipeline) buildEnv(client *dagger.Client, source *dagger.Directory) *dagger.Container {
+	p.systemBase = p.systemEnv(client)
+	container, hashInstall := p.installProject(client, p.systemBase, source)
+	p.hashInstall = hashInstall
+	return container
+}
+
+// installProject installs the project and its local packages into a system
+// environment. With a hash lock it also returns the lockfile install, whose
+// failures verifyHashedInstall reports.
+func (p *Pipeline) installProject(client *dagger.Client, system *dagger.Container, source *dagger.Directory) (*dagger.Container, *dagger.Container) {
+	container := system.
+		WithMountedDirectory(AppWorkdir, source).
+		WithWorkdir(AppWorkdir).
+		WithExec([]string{"pip", "install", "--upgrade", "pip", "setuptools", "wheel"})
+	// Private git+https dependencies clone with the token only while installing
+	container = p.withGitCredentials(client, container)
+	editable := []string{"pip", "install", "-e"}
+	var hashInstall *dagger.Container
+	if p.hashLock != nil {
+		// Failures are reported by verifyHashedInstall with the lockfile lines
+		container = container.WithExec([]string{"pip", "install", "--require-hashes", "--no-deps", "-r", p.hashLock.Path}, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+		hashInstall = container
+		editable = []string{"pip", "install", "--no-deps", "-e"}
+	}
+	// Install the local packages first, in order, then the project with dev+server extras
+	for _, dir := range p.localPackageDirs {
+		container = container.WithExec(append(editable, "./"+dir))
+	}
+	container = container.WithExec(append(editable, ".[dev,server]"))
+	if p.hashLock != nil {
+		container = container.WithExec([]string{"pip", "check"})
+	}
+	return p.withoutGitCredentials(container), hashInstall
+}
+
+// systemEnv is the project-independent part of the build container: proxy,
+// system packages, corporate CA certificates and the apt/pip cache volumes.
+// Warmup builds the same container, so its layers are reused by real runs.
+func (p *Pipeline) systemEnv(client *dagger.Client) *dagger.Container {
+	container, caUpdate := p.systemEnvFrom(client, p.baseImage(), PipCacheVolume)
+	p.caUpdate = caUpdate
+	return container
+}
+
+// systemEnvFrom is systemEnv from another Python image with its own pip
+// cache volume. With CA certificates it also returns the
+// update-ca-certificates exec, whose failures verifyCATrust reports.
+func (p *Pipeline) systemEnvFrom(client *dagger.Client, image, pipCache string) (*dagger.Container, *dagger.Container) {
+	cfg := p.cfg
+	p.printf("   🐍 Base image: %s\n", image)
+	container := p.from(client, image)
+	var caUpdate *dagger.Container
+	if cfg.NoCache {
+		p.println("   ♻️  NO_CACHE=true — cached step results are not reused")
+		container = container.WithEnvVariable(cacheBusterEnv, p.cacheBuster)
+	}
+
+	// Proxy first so apt-get and pip both go through it
+	if cfg.ProxyURL != "" {
+		p.println("   🌐 Configuring proxy settings in container...")
+		p.printf("      ✓ HTTP_PROXY=%s\n", cfg.ProxyURL)
+		container = container.
+			WithEnvVariable("HTTP_PROXY", cfg.ProxyURL).
+			WithEnvVariable("HTTPS_PROXY", cfg.ProxyURL).
+			WithEnvVariable("http_proxy", cfg.ProxyURL).
+			WithEnvVariable("https_proxy", cfg.ProxyURL).
+			WithEnvVariable("NO_PROXY", "localhost,127.0.0.1,.local").
+			WithEnvVariable("no_proxy", "localhost,127.0.0.1,.local")
+	}
+
+	// Keep downloaded .deb files in a cache volume (the base image deletes them by default)
+	if packages := p.aptPackages(); len(packages) > 0 {
+		p.printf("   📦 Apt packages: %s\n", strings.Join(packages, " "))
+		container = container.
+			WithMountedCache("/var/cache/apt/archives", p.cacheVolume(client, AptCacheVolume), dagger.ContainerWithMountedCacheOpts{
+				Sharing: dagger.CacheSharingModeLocked,
+			}).
+			WithExec([]string{"rm", "-f", "/etc/apt/apt.conf.d/docker-clean"}).
+			WithExec([]string{"apt-get", "update"}).
+			WithExec(append([]string{"apt-get", "install", "-y", "--no-install-recommends"}, packages...)).
+			WithExec([]string{"rm", "-rf", "/var/lib/apt/lists/*"})
+	} else {
+		p.println("   ⏭️  No apt packages to install (SKIP_DEFAULT_APT=true)")
+	}
+
+	// Mount corporate CA certificates and update the trust store
+	if len(cfg.CACertPaths) > 0 {
+		p.println("   📜 Mounting corporate CA certificates into container...")
+		for _, certPath := range cfg.CACertPaths {
+			info, err := os.Stat(certPath)
+			if err != nil {
+				p.warnf("ca-cert-access", "   ⚠️  Could not access %s: %v\n", certPath, err)
+				continue
+			}
+			filename := filepath.Base(certPath)
+			if info.IsDir() {
+				container = container.WithMountedDirectory(caMountPath(certPath), client.Host().Directory(certPath))
+			} else {
+				container = container.WithMountedFile(caMountPath(certPath), client.Host().File(certPath))
+			}
+			p.printf("      ✓ Mounted %s\n", filename)
+		}
+		p.println("   🔄 Updating CA certificate store (update-ca-certificates)...")
+		// Failures are reported by verifyCATrust with the rejected files
+		container = container.WithExec([]string{"update-ca-certificates"}, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+		caUpdate = container
+
+		// Point Python's requests/httpx and curl at the updated system bundle
+		container = container.
+			WithEnvVariable("REQUESTS_CA_BUNDLE", caBundlePath).
+			WithEnvVariable("SSL_CERT_FILE", caBundlePath).
+			WithEnvVariable("CURL_CA_BUNDLE", caBundlePath)
+	}
+
+	if cfg.PipIndexURL != "" {
+		p.printf("   📦 Package index: %s\n", redactConfigURL(cfg.PipIndexURL, false))
+		container = container.WithEnvVariable("PIP_INDEX_URL", cfg.PipIndexURL)
+	}
+
+	return container.WithMountedCache("/root/.cache/pip", p.cacheVolume(client, pipCache)), caUpdate
+}
+
+// cacheVolume returns the named cache volume, keyed with CacheKey when set
+// so projects in a monorepo do not share package caches.
+func (p *Pipeline) cacheVolume(client *dagger.Client, name string) *dagger.CacheVolume {
+	if p.cfg.CacheKey != "" {
+		name += "-" + p.cfg.CacheKey
+	}
+	return client.CacheVolume(name)
+}
+
+// runContainerTests runs tests, the container of a test stage's marker in
+// builder (UnitTests or containerTests), after a collection pre-pass,
+// exports its JUnit report and returns the container for the following
+// stages. It returns false when the stage has no tests and
+// AllowEmptyTestStage (or the CHANGED_ONLY_TESTS selection) lets it pass.
+func (p *Pipeline) runContainerTests(ctx context.Context, builder, tests *dagger.Container, stage, marker string) (*dagger.Container, bool, error) {
+	collect := containerCollector(ctx, p.asTestUser(builder), p.changedTests)
+	if run, err := p.precheckCollected(stage, marker, collect); !run || err != nil {
+		return builder, false, err
+	}
+
+	cmd := p.containerTestCmd(stage, marker)
+	testContainer := tests
+	testOutput, err := testContainer.Stdout(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	p.println(testOutput)
+	exitCode, err := testContainer.ExitCode(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	// The lowest-resolution rerun of the unit tests keeps its files apart
+	artifactsStage := p.tracker.Snapshot().CurrentStage
+	if strings.HasSuffix(stage, "-lowest") {
+		artifactsStage += "-lowest"
+	}
+	p.exportStageArtifacts(ctx, artifactsStage, testContainer)
+	stderr, _ := testContainer.Stderr(ctx)
+	if NoTestsRan(testOutput, exitCode) {
+		return builder, false, p.emptyStage(stage, marker, collect)
+	}
+	// Only runs whose tests ran (passed or failed) write a JUnit report
+	if junit, jerr := p.junitPath(stage); jerr == nil && exitCode <= PytestExitTestsFailed {
+		if _, jerr = testContainer.File(containerJUnitPath(stage)).Export(ctx, junit); jerr == nil {
+			p.collectJUnit(stage, junit)
+		} else {
+			p.warnf("junit", "   ⚠️  Could not export JUnit report: %v\n", jerr)
+		}
+	}
+	p.collectPythonWarnings(stage, testOutput+"\n"+stderr)
+	if exitCode != 0 {
+		if stderr != "" {
+			p.println(stderr)
+		}
+		return nil, true, p.pytestFailed(stage, exitCode, cmd)
+	}
+	// Exported already; later stages built on this container start without them
+	return p.asRoot(testContainer).WithoutDirectory(ContainerArtifactsDir), true, nil
+}
+
+// runTestsOnHost executes pytest with a specific marker on the HOST machine
+// (not inside the Dagger container). This is necessary for integration and
+// acceptance tests that use testcontainers, because testcontainers requires
+// native Docker socket access — Docker-in-Docker path mismatches inside Dagger
+// containers prevent testcontainers from binding volumes correctly.
+//
+// pytest runs from ProjectRoot using the environment exported by
+// ExportTestVenv, or its .venv when present; the child process inherits the
+// host environment, including proxy settings. With HostTestWrapper the
+// collection pre-pass and the run both go through the wrapper.
+func (p *Pipeline) runTestsOnHost(ctx context.Context, marker string) (bool, error) {
+	cfg := p.cfg
+	projectRoot, err := filepath.Abs(p.cfg.ProjectRoot)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve project root: %w", err)
+	}
+
+	p.println("⚙️  Configuration:")
+	p.printf("   • Project root: %s\n", projectRoot)
+	p.printf("   • Marker: %s\n", marker)
+	p.printf("   • Command: pytest -v --tb=short -m %s\n", marker)
+	if p.changedOnly {
+		p.printf("   • Test files: %s\n", strings.Join(p.changedTests, " "))
+	}
+	if p.cfg.ProxyURL != "" {
+		p.printf("   • Proxy: %s (inherited from host env)\n", p.cfg.ProxyURL)
+	}
+	p.println("")
+
+	// Determine pytest — the exported build environment, .venv/bin/pytest, or PATH
+	pytest := p.hostPytest(projectRoot)
+	if pytest[0] == "pytest" {
+		p.warnf("venv-missing", "   ⚠️  .venv not found, using system pytest\n")
+	} else {
+		p.printf("   • Using: %s\n", strings.Join(pytest, " "))
+	}
+	if len(cfg.HostTestWrapper) > 0 {
+		pytest = p.hostTestCommand(projectRoot)
+		p.printf("   • Wrapper: %s (HOST_TEST_WRAPPER)\n", shellJoin(cfg.HostTestWrapper))
+	}
+
+	junit, err := p.junitPath(marker)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(junit), 0o755)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare JUnit report: %w", err)
+	}
+
+	// Caches and bytecode go under ArtifactsDir, never into the checkout
+	env := append(append(append(append(os.Environ(), p.hostWriteEnv()...), p.databaseEnv()...), p.offlineHostEnv()...), p.acceptanceEnv(marker)...)
+	stage := p.tracker.Snapshot().CurrentStage
+	env = append(env, p.stageHostEnv(stage)...)
+	seedEnv, seedResult := p.sessionSeedEnv(marker)
+	env = append(env, seedEnv...)
+	collect := hostCollector(ctx, pytest, projectRoot, env, p.changedTests)
+	if run, err := p.precheckCollected(marker, marker, collect); !run || err != nil {
+		return false, err
+	}
+	if err := p.seedExternalDatabase(ctx, stage, projectRoot, env); err != nil {
+		return true, err
+	}
+
+	args := hostPytestArgs(marker, junit, p.changedTests)
+	cmd := exec.CommandContext(ctx, pytest[0], append(pytest[1:], args...)...)
+	cmd.Dir = projectRoot
+	cmd.Env = env
+	p.stopThroughWrapper(cmd)
+	if len(cfg.HostTestWrapper) > 0 {
+		p.printf("   $ %s\n", shellJoin(cmd.Args))
+	}
+
+	// Capture output while streaming it
+	var outputBuffer, stderrBuffer strings.Builder
+	cmd.Stdout = io.MultiWriter(p.out, &outputBuffer)
+	cmd.Stderr = io.MultiWriter(p.out, &stderrBuffer)
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+	seedTime, seedErr := p.collectSessionSeed(stage, seedResult)
+	duration -= seedTime
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return true, fmt.Errorf("failed to run %s: %w", pytest[0], err)
+	}
+	if NoTestsRan(outputBuffer.String(), exitCode) {
+		p.println(separatorLine)
+		return false, p.emptyStage(marker, marker, collect)
+	}
+
+	if exitCode <= PytestExitTestsFailed {
+		p.collectJUnit(marker, junit)
+	}
+	summary := ParsePytestOutput(outputBuffer.String())
+	p.collectPythonWarnings(marker, outputBuffer.String()+"\n"+stderrBuffer.String())
+	p.println(separatorLine)
+	if exitCode != 0 {
+		err = p.pytestFailed(marker, exitCode, append(pytest, args...))
+		if hint := p.wrapperExitHint(exitCode); hint != "" {
+			err = fmt.Errorf("%w%s", err, hint)
+		}
+	}
+	if seedErr != nil {
+		err = seedErr
+	}
+	p.displayHostTestSummary(marker, summary, duration, err)
+	if len(summary.Failures) > 0 {
+		failures := summary.WithStage(marker)
+		p.report.TestFailures = append(p.report.TestFailures, failures...)
+		if path, werr := WriteFailuresFile(p.cfg.ArtifactsDir, marker, failures); werr != nil {
+			p.warnf("failure-details", "   ⚠️  Could not write failure details: %v\n", werr)
+		} else {
+			p.printf("   📄 Full failure details: %s\n\n", path)
+		}
+	}
+
+	return true, err
+}
+
+// displayHostTestSummary prints the pytest counts and, on failure, one line per failing test
+func (p *Pipeline) displayHostTestSummary(marker string, summary PytestSummary, duration time.Duration, testErr error) {
+	label := strings.ToUpper(marker[:1]) + marker[1:]
+	p.printf("\n📊 %s Test Summary\n", label)
+	p.println(separatorLine)
+
+	if summary.HasSummary {
+		p.printf("   Passed: %d | Failed: %d | Errors: %d | Duration: %v\n",
+			summary.Passed, summary.Failed, summary.Errors, duration.Round(time.Millisecond))
+	} else {
+		p.printf("   Duration: %v\n", duration.Round(time.Millisecond))
+	}
+
+	if testErr != nil {
+		p.printf("   ❌ FAILED: %v, after %v\n", testErr, duration.Round(time.Millisecond))
+		PrintFailureList(p.out, summary.Failures, FailureListLimit)
+	} else {
+		p.printf("   ✅ SUCCESS: %s tests passed in %v\n", marker, duration.Round(time.Millisecond))
+	}
+	p.println("")
+}
+
+// triggerWebhook POSTs the published image metadata as JSON to the deployment
+// webhook (ArgoCD, Flux, or a custom deployment service) through the
+// pipeline's HTTP client.
+func (p *Pipeline) triggerWebhook(ctx context.Context, imageTag, imageAddress, commitSHA, timestamp string) error {
+	p.printf("   Webhook: %s\n", p.cfg.DeployWebhook)
+	p.printf("   Image Tag: %s\n", imageTag)
+	p.printf("   Image: %s\n", imageAddress)
+	p.printf("   Commit: %s\n", commitSHA)
+	p.printf("   Timestamp: %s\n", timestamp)
+
+	payload, err := json.Marshal(map[string]string{
+		"repository": p.cfg.RepoName,
+		"branch":     p.cfg.GitBranch,
+		"image":      imageAddress,
+		"image_tag":  imageTag,
+		"commit":     commitSHA,
+		"timestamp":  timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.DeployWebhook, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ExtractProjectName parses the project name from pyproject.toml content
+// (ParsePyproject); it is empty when there is none or the file cannot be
+// decoded.
+func ExtractProjectName(content string) string {
+	_, info, err := ParsePyproject(content)
+	if err != nil {
+		return ""
+	}
+	return info.Name
+}
+
+// ExtractProjectVersion parses the static project version from
+// pyproject.toml content (ParsePyproject) and whether the build backend
+// computes it instead; the version is empty when there is none or the file
+// cannot be decoded.
+func ExtractProjectVersion(content string) (version string, dynamic bool) {
+	_, info, err := ParsePyproject(content)
+	if err != nil {
+		return "", false
+	}
+	return info.Version, info.DynamicVersion
+}
+
+// DockerSafeName converts a project name to a Docker-safe image name.
+func DockerSafeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// TestNewValidatesConfig tests that invalid configurations are rejected as config errors
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing repo", Config{GitUser: "org"}},
+		{"missing user", Config{RepoName: "cert-parser"}},
+		{"publish without build", Config{RepoName: "cert-parser", GitUser: "org", RunPublish: true, RegistryToken: "t"}},
+		{"publish without token", Config{RepoName: "cert-parser", GitUser: "org", RunBuild: true, RunPublish: true}},
+		{"upload without password", Config{RepoName: "cert-parser", GitUser: "org", PackagePublish: PackagePublish{Upload: true}}},
+	}
+	for _, tc := range tests {
+		p, err := New(tc.cfg)
+		if err == nil || p != nil {
+			t.Fatalf("%s: expected New to fail", tc.name)
+		}
+		if CategoryOf(err) != CategoryConfig {
+			t.Fatalf("%s: expected config error, got %s: %v", tc.name, CategoryOf(err), err)
+		}
+	}
+	fmt.Println("✅ Invalid configurations rejected before running")
+}
+
+// TestNewAppliesDefaults tests the defaults filled in for a minimal Config
+func TestNewAppliesDefaults(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "Javier-Godon", Output: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := p.Config()
+	if cfg.GitHost != "github.com" || cfg.Registry != "ghcr.io" || cfg.GitBranch != "main" || cfg.GitAuthUser != "x-access-token" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	if cfg.GitRepo != "https://github.com/Javier-Godon/cert-parser.git" {
+		t.Fatalf("unexpected clone URL %q", cfg.GitRepo)
+	}
+	if cfg.PackagePublish.ExportDir != filepath.Join(DefaultArtifactsDir, "dist") {
+		t.Fatalf("unexpected export dir %q", cfg.PackagePublish.ExportDir)
+	}
+	if cfg.HTTPClient == nil || p.Tracker() == nil {
+		t.Fatal("HTTP client and tracker should be created")
+	}
+
+	custom, err := New(Config{
+		RepoName:      "cert-parser",
+		GitUser:       "mygroup",
+		GitHost:       "gitlab.com",
+		GitRepo:       "https://mirror.internal/cert-parser.git",
+		Registry:      "registry.gitlab.com",
+		RegistryToken: "token",
+		RunBuild:      true,
+		RunPublish:    true,
+		ArtifactsDir:  "out",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = custom.Config()
+	if cfg.GitRepo != "https://mirror.internal/cert-parser.git" || cfg.Registry != "registry.gitlab.com" {
+		t.Fatalf("explicit values overridden: %+v", cfg)
+	}
+	if cfg.PackagePublish.ExportDir != filepath.Join("out", "dist") {
+		t.Fatalf("export dir should follow ArtifactsDir, got %q", cfg.PackagePublish.ExportDir)
+	}
+	fmt.Println("✅ Config defaults applied without overriding explicit values")
+}
+
+// TestNewIgnoresEnvironment tests that the library never picks values up from env vars
+func TestNewIgnoresEnvironment(t *testing.T) {
+	t.Setenv("CR_PAT", "from-env")
+	t.Setenv("GIT_BRANCH", "develop")
+	t.Setenv("REGISTRY", "registry.example.com")
+	t.Setenv("STATUS_FILE", filepath.Join(t.TempDir(), "status.json"))
+
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := p.Config()
+	if cfg.GitToken != "" || cfg.RegistryToken != "" || cfg.GitBranch != "main" || cfg.Registry != "ghcr.io" || cfg.StatusFile != "" {
+		t.Fatalf("configuration leaked from the environment: %+v", cfg)
+	}
+
+	// Publishing still needs an explicit token even when CR_PAT is exported
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", RunBuild: true, RunPublish: true}); err == nil {
+		t.Fatal("expected RegistryToken to be required regardless of CR_PAT")
+	}
+	fmt.Println("✅ Library configuration comes only from Config")
+}
+
+// TestTriggerWebhookPostsMetadata tests the webhook call through the configured HTTP client
+func TestTriggerWebhookPostsMetadata(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid JSON body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	p, err := New(Config{
+		RepoName:      "cert-parser",
+		GitUser:       "org",
+		DeployWebhook: server.URL,
+		HTTPClient:    server.Client(),
+		Output:        io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.triggerWebhook(context.Background(), "v0.1.0-abc1234", "ghcr.io/org/cert-parser:v0.1.0-abc1234", "abc1234", "20260101-1200")
+	if err != nil {
+		t.Fatalf("webhook failed: %v", err)
+	}
+	if got["image"] != "ghcr.io/org/cert-parser:v0.1.0-abc1234" || got["commit"] != "abc1234" || got["branch"] != "main" {
+		t.Fatalf("unexpected payload: %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	p.cfg.DeployWebhook = failing.URL
+	if err := p.triggerWebhook(context.Background(), "t", "i", "c", "ts"); err == nil {
+		t.Fatal("expected non-2xx webhook response to be reported")
+	}
+	fmt.Println("✅ Deployment webhook posts image metadata")
+}
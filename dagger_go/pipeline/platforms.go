@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// platformPattern matches an os/arch[/variant] platform such as linux/arm64
+// or linux/arm/v7.
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// ParsePlatforms parses PLATFORMS: comma-separated platforms, e.g.
+// linux/amd64,linux/arm64. Duplicates are dropped; empty is nil, the
+// engine's own platform.
+func ParsePlatforms(value string) ([]string, error) {
+	var platforms []string
+	for _, platform := range strings.Split(value, ",") {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if platform == "" || slices.Contains(platforms, platform) {
+			continue
+		}
+		if !platformPattern.MatchString(platform) {
+			return nil, fmt.Errorf("%q is not an os/arch platform such as linux/arm64", platform)
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms, nil
+}
+
+// PlatformDigest is the manifest of one platform in a published manifest
+// list.
+type PlatformDigest struct {
+	Platform string `json:"platform"`
+	Digest   string `json:"digest"`
+}
+
+// ManifestPlatforms fetches the manifest list digest of image (the pushed
+// repository:tag) and returns the manifest of each platform in it.
+func ManifestPlatforms(ctx context.Context, client *http.Client, image, digest, username, password string) ([]PlatformDigest, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("no manifest digest for %s", image)
+	}
+	host, repo, _ := splitImage(image)
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, digest)
+	auth, err := registryAuth(ctx, client, url, repo, username, password)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch manifest %s: registry returned %s", digest, resp.Status)
+	}
+	var index struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&index); err != nil {
+		return nil, fmt.Errorf("decode manifest %s: %w", digest, err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("%s is a single-platform manifest, not a manifest list", digest)
+	}
+	var platforms []PlatformDigest
+	for _, m := range index.Manifests {
+		platform := m.Platform.OS + "/" + m.Platform.Architecture
+		if m.Platform.Variant != "" {
+			platform += "/" + m.Platform.Variant
+		}
+		if platform == "unknown/unknown" {
+			continue // attestations BuildKit stores next to the images
+		}
+		platforms = append(platforms, PlatformDigest{Platform: platform, Digest: m.Digest})
+	}
+	return platforms, nil
+}
+
+// buildPlatformImages builds the image for each of Platforms. The first is
+// the image the hardening, size and vulnerability checks inspect; the
+// others are published with it as one manifest list. Without Platforms,
+// or with one, there are no variants and the image is built as before.
+func (p *Pipeline) buildPlatformImages(source *dagger.Directory) (*dagger.Container, []*dagger.Container) {
+	platforms := p.cfg.Platforms
+	if len(platforms) <= 1 {
+		platform := ""
+		if len(platforms) == 1 {
+			platform = platforms[0]
+			p.printf("   🖥️  Platform: %s (PLATFORMS)\n", platform)
+		}
+		return p.BuildImage(source, platform, p.labels), nil
+	}
+	p.printf("   🖥️  Platforms: %s (PLATFORMS) — the checks below inspect %s\n", strings.Join(platforms, ", "), platforms[0])
+	image := p.BuildImage(source, platforms[0], p.labels)
+	var variants []*dagger.Container
+	for _, platform := range platforms[1:] {
+		variants = append(variants, p.BuildImage(source, platform, p.labels))
+	}
+	return image, variants
+}
+
+// printPlatformDigests lists the manifest of each platform in the manifest
+// list published as image with digest. Failing to read it only warns.
+func (p *Pipeline) printPlatformDigests(ctx context.Context, image, digest string) {
+	platforms, err := ManifestPlatforms(ctx, p.cfg.HTTPClient, image, digest, p.cfg.RegistryUser, p.registryToken)
+	if err != nil {
+		p.warnf("platform-digests", "   ⚠️  Could not read the platforms of %s: %v\n", image, err)
+		return
+	}
+	p.report.Platforms = platforms
+	p.printf("   🖥️  Manifest list %s:\n", digest)
+	for _, platform := range platforms {
+		p.printf("      %-14s %s\n", platform.Platform, platform.Digest)
+	}
+}
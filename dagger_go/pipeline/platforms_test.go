@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParsePlatforms tests the PLATFORMS list
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms(" linux/amd64, Linux/ARM64,linux/arm/v7,linux/amd64,")
+	if err != nil || strings.Join(platforms, ",") != "linux/amd64,linux/arm64,linux/arm/v7" {
+		t.Fatalf("got %v, %v", platforms, err)
+	}
+	if platforms, err := ParsePlatforms(""); err != nil || platforms != nil {
+		t.Fatalf("empty should be the engine's platform, got %v, %v", platforms, err)
+	}
+	for _, value := range []string{"amd64", "linux/amd64/v2/x", "linux amd64"} {
+		if _, err := ParsePlatforms(value); err == nil {
+			t.Fatalf("ParsePlatforms(%q) should fail", value)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", Platforms: []string{"arm64"}}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	fmt.Println("✅ PLATFORMS parsed and checked")
+}
+
+// TestManifestPlatforms tests reading the per-platform digests of a published manifest list
+func TestManifestPlatforms(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/v2/org/app/manifests/"+digest:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			fmt.Fprint(w, `{"schemaVersion":2,"manifests":[
+				{"digest":"sha256:aaaa","platform":{"os":"linux","architecture":"amd64"}},
+				{"digest":"sha256:bbbb","platform":{"os":"linux","architecture":"arm64","variant":"v8"}},
+				{"digest":"sha256:cccc","platform":{"os":"unknown","architecture":"unknown"}}]}`)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	platforms, err := ManifestPlatforms(context.Background(), server.Client(), host+"/org/app:v0.1.0-abc", digest, "ci", "pat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PlatformDigest{{"linux/amd64", "sha256:aaaa"}, {"linux/arm64/v8", "sha256:bbbb"}}
+	if fmt.Sprint(platforms) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", platforms, want)
+	}
+	if _, err := ManifestPlatforms(context.Background(), server.Client(), host+"/org/app:v0.1.0-abc", "", "ci", "pat"); err == nil {
+		t.Fatal("expected an error without a digest")
+	}
+
+	// A pull request image is published from the same variants: its tag is the manifest list too
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", HTTPClient: server.Client(), Platforms: []string{"linux/amd64", "linux/arm64"}, Output: &strings.Builder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.printPlatformDigests(context.Background(), host+"/org/app:"+PRImageTag(7), digest)
+	if fmt.Sprint(p.report.Platforms) != fmt.Sprint(want) {
+		t.Fatalf("pull request image platforms = %v, want %v", p.report.Platforms, want)
+	}
+	fmt.Println("✅ Per-platform digests read from the manifest list")
+}
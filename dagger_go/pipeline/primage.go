@@ -0,0 +1,328 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// PRImageTagPrefix starts the moving tag of a pull request's image:
+// ghcr.io/org/cert-parser:pr-123.
+const PRImageTagPrefix = "pr-"
+
+// prCleanupTimeout bounds the GitHub API calls of the PR image cleanup.
+const prCleanupTimeout = 2 * time.Minute
+
+// maxPackagePages bounds how many pages of 100 package versions the PR
+// image cleanup reads.
+const maxPackagePages = 10
+
+// PullRequest is the open pull request a CI run builds.
+type PullRequest struct {
+	Number   int    `json:"number"`
+	HeadRef  string `json:"head_ref,omitempty"`
+	HeadRepo string `json:"head_repo,omitempty"` // owner/name the changes come from
+	BaseRepo string `json:"base_repo,omitempty"` // owner/name the pull request targets
+	Fork     bool   `json:"fork,omitempty"`      // HeadRepo is not BaseRepo: the code is untrusted
+}
+
+// PRImageReport records the image published for a pull request.
+type PRImageReport struct {
+	Number int    `json:"number"`
+	Image  string `json:"image"`
+	Pull   string `json:"pull"` // docker pull command for the moving tag
+}
+
+// PRImageTag is the moving tag of pull request number n.
+func PRImageTag(n int) string {
+	return PRImageTagPrefix + strconv.Itoa(n)
+}
+
+// PRTagNumber returns the pull request number of a PRImageTag.
+func PRTagNumber(tag string) (int, bool) {
+	rest, ok := strings.CutPrefix(tag, PRImageTagPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil && n > 0
+}
+
+// DetectPullRequest returns the pull request a GitHub Actions
+// (pull_request or pull_request_target event, read from GITHUB_EVENT_PATH)
+// or GitLab merge request pipeline builds, or nil for any other run.
+// getenv is normally os.Getenv.
+func DetectPullRequest(getenv func(string) string) (*PullRequest, error) {
+	if iid := getenv("CI_MERGE_REQUEST_IID"); iid != "" {
+		n, err := strconv.Atoi(iid)
+		if err != nil {
+			return nil, fmt.Errorf("CI_MERGE_REQUEST_IID %q is not a number", iid)
+		}
+		head, base := getenv("CI_MERGE_REQUEST_SOURCE_PROJECT_PATH"), getenv("CI_MERGE_REQUEST_PROJECT_PATH")
+		return &PullRequest{
+			Number:   n,
+			HeadRef:  getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"),
+			HeadRepo: head,
+			BaseRepo: base,
+			Fork:     head != "" && base != "" && head != base,
+		}, nil
+	}
+	if getenv("GITHUB_ACTIONS") != "true" {
+		return nil, nil
+	}
+	if event := getenv("GITHUB_EVENT_NAME"); event != "pull_request" && event != "pull_request_target" {
+		return nil, nil
+	}
+	path := getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("GITHUB_EVENT_PATH is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePullRequestEvent(data)
+}
+
+// ParsePullRequestEvent reads the pull request of a GitHub pull_request
+// event payload.
+func ParsePullRequestEvent(data []byte) (*PullRequest, error) {
+	var event struct {
+		PullRequest struct {
+			Number int `json:"number"`
+			Head   struct {
+				Ref  string `json:"ref"`
+				Repo struct {
+					FullName string `json:"full_name"`
+					Fork     bool   `json:"fork"`
+				} `json:"repo"`
+			} `json:"head"`
+			Base struct {
+				Repo struct {
+					FullName string `json:"full_name"`
+				} `json:"repo"`
+			} `json:"base"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("parse pull request event: %w", err)
+	}
+	pr := event.PullRequest
+	if pr.Number == 0 {
+		return nil, fmt.Errorf("parse pull request event: no pull_request.number")
+	}
+	head, base := pr.Head.Repo.FullName, pr.Base.Repo.FullName
+	return &PullRequest{
+		Number:   pr.Number,
+		HeadRef:  pr.Head.Ref,
+		HeadRepo: head,
+		BaseRepo: base,
+		// A deleted fork has no head repository left
+		Fork: pr.Head.Repo.Fork || head == "" || !strings.EqualFold(head, base),
+	}, nil
+}
+
+// PRImageMarkdown renders the pull request image for a CI job summary.
+func PRImageMarkdown(r *PRImageReport) string {
+	return fmt.Sprintf("### 🐳 Image for pull request #%d\n\n```bash\n%s\n```\n", r.Number, r.Pull)
+}
+
+// publishPRImage is the publish stage of a pull request build with
+// PublishPRImage: it pushes the moving pr-<number> tag only, never the
+// versioned and latest tags, and triggers no deployment. With Platforms it
+// is the same manifest list the versioned tag would be.
+func (p *Pipeline) publishPRImage(ctx context.Context, client *dagger.Client, image *dagger.Container, address string) error {
+	cfg := p.cfg
+	if err := p.checkPublishPolicy(); err != nil {
+		p.printf("\n❌ PIPELINE FAILED BEFORE PUBLISH: %v\n", err)
+		p.tracker.SkipStage(StagePublish, "publish policy not met")
+		return err
+	}
+
+	p.tracker.StartStage(StagePublish)
+	p.stageBanner(StagePublish)
+	p.printf("📤 Publishing pull request #%d image to: %s\n", cfg.PullRequest.Number, address)
+	published, err := p.publish(ctx, client, image, address, p.platformVariants...)
+	if err != nil {
+		p.stageFailed(StagePublish)
+		return Errorf(CategoryPublish, "failed to publish pull request image: %w%s", err, p.publishPermissionHint(err))
+	}
+	p.report.PublishedImages = []string{published}
+	p.events.Emit(Event{Type: EventPublished, Image: published})
+	p.report.PRImage = &PRImageReport{Number: cfg.PullRequest.Number, Image: published, Pull: "docker pull " + address}
+	if cfg.StepSummary != "" {
+		if err := AppendStepSummary(cfg.StepSummary, PRImageMarkdown(p.report.PRImage)); err != nil {
+			p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", err)
+		}
+	}
+
+	p.stageComplete(StagePublish, "Pull request image published")
+	p.tracker.PassStage()
+	p.printf("   📦 %s\n", published)
+	p.printf("   🐳 %s\n", p.report.PRImage.Pull)
+	if len(p.platformVariants) > 0 {
+		p.printPlatformDigests(ctx, address, imageDigest(published))
+	}
+	if cfg.DeployWebhook != "" {
+		p.println("⏭️  Skipping deployment webhook — pull request images are previews")
+	}
+	return nil
+}
+
+// PackageVersion is one version of a GitHub Packages container package.
+type PackageVersion struct {
+	ID   int64
+	Tags []string
+}
+
+// ListContainerVersions lists the versions of a GHCR container package of
+// owner, an organization or a user. It returns the versions and their
+// collection URL, which DeleteContainerVersion uses.
+func ListContainerVersions(ctx context.Context, client *http.Client, apiBase, owner, pkg, token string) ([]PackageVersion, string, error) {
+	var lastErr error
+	for _, kind := range []string{"orgs", "users"} {
+		base := fmt.Sprintf("%s/%s/%s/packages/container/%s/versions", strings.TrimSuffix(apiBase, "/"), kind, url.PathEscape(owner), url.PathEscape(pkg))
+		var versions []PackageVersion
+		for page := 1; page <= maxPackagePages; page++ {
+			var batch []struct {
+				ID       int64 `json:"id"`
+				Metadata struct {
+					Container struct {
+						Tags []string `json:"tags"`
+					} `json:"container"`
+				} `json:"metadata"`
+			}
+			if lastErr = getGitHubJSON(ctx, client, fmt.Sprintf("%s?per_page=100&page=%d", base, page), token, &batch); lastErr != nil {
+				break
+			}
+			for _, v := range batch {
+				versions = append(versions, PackageVersion{ID: v.ID, Tags: v.Metadata.Container.Tags})
+			}
+			if len(batch) < 100 {
+				return versions, base, nil
+			}
+		}
+		if lastErr == nil {
+			return versions, base, nil
+		}
+	}
+	return nil, "", lastErr
+}
+
+// DeleteContainerVersion deletes a package version listed by
+// ListContainerVersions; the token needs delete:packages.
+func DeleteContainerVersion(ctx context.Context, client *http.Client, versionsURL string, id int64, token string) error {
+	u := fmt.Sprintf("%s/%d", versionsURL, id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DELETE %s: %s", u, resp.Status)
+	}
+	return nil
+}
+
+// PullRequestClosed reports whether pull request n of owner/repo is closed
+// (merged or not).
+func PullRequestClosed(ctx context.Context, client *http.Client, apiBase, owner, repo, token string, n int) (bool, error) {
+	var pr struct {
+		State string `json:"state"`
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", strings.TrimSuffix(apiBase, "/"), url.PathEscape(owner), url.PathEscape(repo), n)
+	if err := getGitHubJSON(ctx, client, u, token, &pr); err != nil {
+		return false, err
+	}
+	return pr.State == "closed", nil
+}
+
+// cleanupPRImages deletes the images of closed pull requests after a
+// publish outside a pull request: the GHCR package versions whose only tag
+// is a PRImageTag. A version that also carries another tag is kept. With
+// Confirm, the images found are deleted only once it agrees. The cleanup
+// is advisory; API errors only warn.
+func (p *Pipeline) cleanupPRImages(ctx context.Context, owner, pkg string) {
+	cfg := p.cfg
+	p.println("🧹 Deleting the images of closed pull requests (PR_IMAGE_CLEANUP)...")
+	if cfg.Registry != "ghcr.io" {
+		p.printf("   ℹ️  Not supported for %s — only GHCR images can be deleted through the GitHub Packages API\n", cfg.Registry)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, prCleanupTimeout)
+	defer cancel()
+	apiBase := GitHubAPIBase(cfg.GitHost)
+	versions, versionsURL, err := ListContainerVersions(ctx, cfg.HTTPClient, apiBase, owner, pkg, p.registryToken)
+	if err != nil {
+		p.warnf("pr-image-cleanup", "   ⚠️  Could not list the versions of %s: %v\n", pkg, err)
+		return
+	}
+	type closedImage struct {
+		id     int64
+		number int
+	}
+	var found []closedImage
+	for _, v := range versions {
+		var n int
+		var ok bool
+		for _, tag := range v.Tags {
+			if n, ok = PRTagNumber(tag); ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if len(v.Tags) > 1 {
+			p.printf("   ⏭️  %s kept: it is also tagged %s\n", PRImageTag(n), strings.Join(v.Tags, ", "))
+			continue
+		}
+		closed, err := PullRequestClosed(ctx, cfg.HTTPClient, apiBase, cfg.GitUser, cfg.RepoName, cfg.GitToken, n)
+		if err != nil {
+			p.warnf("pr-image-cleanup", "   ⚠️  Could not check pull request #%d: %v\n", n, err)
+			continue
+		}
+		if closed {
+			found = append(found, closedImage{id: v.ID, number: n})
+		}
+	}
+	if len(found) == 0 {
+		p.println("   ✓ No images of closed pull requests")
+		return
+	}
+	if cfg.Confirm != nil {
+		tags := make([]string, len(found))
+		for i, image := range found {
+			tags[i] = PRImageTag(image.number)
+		}
+		if !cfg.Confirm(fmt.Sprintf("Delete %d image(s) of closed pull requests from %s (%s)?", len(found), pkg, strings.Join(tags, ", "))) {
+			p.printf("   ⏭️  Kept %s: deletion not confirmed\n", strings.Join(tags, ", "))
+			return
+		}
+	}
+	for _, image := range found {
+		tag := PRImageTag(image.number)
+		if err := DeleteContainerVersion(ctx, cfg.HTTPClient, versionsURL, image.id, p.registryToken); err != nil {
+			p.warnf("pr-image-cleanup", "   ⚠️  Could not delete %s (needs delete:packages): %v\n", tag, err)
+			continue
+		}
+		p.report.PRImagesDeleted = append(p.report.PRImagesDeleted, tag)
+		p.printf("   🗑️  Deleted %s (pull request #%d is closed)\n", tag, image.number)
+	}
+}
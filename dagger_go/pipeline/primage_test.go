@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestDetectPullRequest tests reading the pull request from GitHub Actions and GitLab CI
+func TestDetectPullRequest(t *testing.T) {
+	if n, ok := PRTagNumber(PRImageTag(123)); !ok || n != 123 {
+		t.Fatalf("PRTagNumber(%s) = %d, %v", PRImageTag(123), n, ok)
+	}
+	for _, tag := range []string{"latest", "pr-", "pr-abc", "v0.1.0-pr-1"} {
+		if _, ok := PRTagNumber(tag); ok {
+			t.Fatalf("%s is not a pull request tag", tag)
+		}
+	}
+
+	event := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(event, []byte(`{"pull_request": {"number": 42,
+		"head": {"ref": "feature/x", "repo": {"full_name": "org/cert-parser", "fork": false}},
+		"base": {"repo": {"full_name": "org/cert-parser"}}}}`), 0o644)
+	env := map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_EVENT_NAME": "pull_request", "GITHUB_EVENT_PATH": event}
+	pr, err := DetectPullRequest(func(k string) string { return env[k] })
+	if err != nil || pr == nil || pr.Number != 42 || pr.HeadRef != "feature/x" || pr.Fork {
+		t.Fatalf("DetectPullRequest = %+v, %v", pr, err)
+	}
+	env["GITHUB_EVENT_NAME"] = "push"
+	if pr, err := DetectPullRequest(func(k string) string { return env[k] }); pr != nil || err != nil {
+		t.Fatalf("a push is not a pull request build: %+v, %v", pr, err)
+	}
+
+	fork, err := ParsePullRequestEvent([]byte(`{"pull_request": {"number": 7,
+		"head": {"ref": "main", "repo": {"full_name": "someone/cert-parser", "fork": true}},
+		"base": {"repo": {"full_name": "org/cert-parser"}}}}`))
+	if err != nil || !fork.Fork || fork.HeadRepo != "someone/cert-parser" {
+		t.Fatalf("fork pull request = %+v, %v", fork, err)
+	}
+
+	gitlab := map[string]string{"CI_MERGE_REQUEST_IID": "9", "CI_MERGE_REQUEST_SOURCE_PROJECT_PATH": "me/cert-parser", "CI_MERGE_REQUEST_PROJECT_PATH": "org/cert-parser"}
+	if pr, err := DetectPullRequest(func(k string) string { return gitlab[k] }); err != nil || pr.Number != 9 || !pr.Fork {
+		t.Fatalf("GitLab merge request = %+v, %v", pr, err)
+	}
+	fmt.Println("✅ Pull request detected")
+}
+
+// TestPublishPRImageConfig tests that PR images refuse forks and never mix with the normal publish
+func TestPublishPRImageConfig(t *testing.T) {
+	base := Config{RepoName: "cert-parser", GitUser: "org", RunBuild: true, RegistryToken: "t", PublishPRImage: true}
+	if _, err := New(base); err == nil || !strings.Contains(err.Error(), "PullRequest") {
+		t.Fatalf("PublishPRImage outside a pull request should be rejected, got %v", err)
+	}
+	base.PullRequest = &PullRequest{Number: 7, HeadRepo: "someone/cert-parser", BaseRepo: "org/cert-parser", Fork: true}
+	if _, err := New(base); err == nil || !strings.Contains(err.Error(), "fork someone/cert-parser") {
+		t.Fatalf("a fork should be refused by default, got %v", err)
+	}
+	base.AllowForkPRImage = true
+	if _, err := New(base); err != nil {
+		t.Fatalf("AllowForkPRImage should allow the fork: %v", err)
+	}
+	base.RunPublish = true
+	if _, err := New(base); err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("PublishPRImage with RunPublish should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Pull request image settings validated")
+}
+
+// TestCleanupPRImagesAPI tests listing package versions, checking pull requests and deleting versions
+func TestCleanupPRImagesAPI(t *testing.T) {
+	deleted := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/org/packages/container/cert-parser/versions", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/users/org/packages/container/cert-parser/versions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "metadata": {"container": {"tags": ["pr-5"]}}},
+			{"id": 2, "metadata": {"container": {"tags": ["latest", "v0.1.0"]}}}]`)
+	})
+	mux.HandleFunc("/users/org/packages/container/cert-parser/versions/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("/repos/org/cert-parser/pulls/5", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"state": "closed", "merged": true}`)
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	ctx, client := context.Background(), server.Client()
+
+	versions, versionsURL, err := ListContainerVersions(ctx, client, server.URL, "org", "cert-parser", "t")
+	if err != nil || len(versions) != 2 || versions[0].Tags[0] != "pr-5" {
+		t.Fatalf("ListContainerVersions = %+v, %v", versions, err)
+	}
+	if !strings.HasSuffix(versionsURL, "/users/org/packages/container/cert-parser/versions") {
+		t.Fatalf("a user package should fall back to /users: %s", versionsURL)
+	}
+	if closed, err := PullRequestClosed(ctx, client, server.URL, "org", "cert-parser", "t", 5); err != nil || !closed {
+		t.Fatalf("PullRequestClosed = %v, %v", closed, err)
+	}
+	if err := DeleteContainerVersion(ctx, client, versionsURL, 1, "t"); err != nil || deleted == "" {
+		t.Fatalf("DeleteContainerVersion: %v (deleted %q)", err, deleted)
+	}
+	if err := DeleteContainerVersion(ctx, client, versionsURL, 2, "t"); err == nil {
+		t.Fatal("a failed delete should return an error")
+	}
+	fmt.Println("✅ Closed pull request images found and deleted")
+}
+
+// TestCleanupPRImagesConfirm tests that nothing is deleted when the cleanup is not confirmed
+func TestCleanupPRImagesConfirm(t *testing.T) {
+	var deletes atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/org/packages/container/cert-parser/versions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "metadata": {"container": {"tags": ["pr-5"]}}}, {"id": 3, "metadata": {"container": {"tags": ["pr-8"]}}}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/cert-parser/pulls/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"state": "closed"}`)
+	})
+	mux.HandleFunc("/api/v3/orgs/org/packages/container/cert-parser/versions/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletes.Add(1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	var question string
+	out := &strings.Builder{}
+	cfg := Config{RepoName: "cert-parser", GitUser: "org", GitHost: strings.TrimPrefix(server.URL, "https://"), HTTPClient: server.Client(), Output: out,
+		Confirm: func(q string) bool { question = q; return false }}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.cleanupPRImages(context.Background(), "org", "cert-parser")
+	if deletes.Load() != 0 || len(p.report.PRImagesDeleted) != 0 {
+		t.Fatalf("expected no deletes without confirmation, got %d", deletes.Load())
+	}
+	if !strings.Contains(question, "Delete 2 image(s)") || !strings.Contains(question, "pr-5, pr-8") {
+		t.Fatalf("unexpected question %q", question)
+	}
+
+	cfg.Confirm = func(string) bool { return true }
+	p, _ = New(cfg)
+	p.cleanupPRImages(context.Background(), "org", "cert-parser")
+	if deletes.Load() != 2 || strings.Join(p.report.PRImagesDeleted, ",") != "pr-5,pr-8" {
+		t.Fatalf("expected both images deleted once confirmed, got %d: %v\n%s", deletes.Load(), p.report.PRImagesDeleted, out)
+	}
+	fmt.Println("✅ Pull request image cleanup asks before deleting")
+}
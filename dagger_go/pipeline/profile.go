@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProfileEnv selects the pipeline profile in the binaries.
+const ProfileEnv = "PIPELINE_PROFILE"
+
+// Profile names.
+const (
+	ProfilePR      = "pr"
+	ProfileMain    = "main"
+	ProfileNightly = "nightly"
+)
+
+// Where a resolved setting came from, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceProfile = "profile"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Profile is a named set of defaults for the binaries' environment
+// variables, so one binary serves pull requests, main builds and the
+// nightly deep run. Profiles only fill in variables that are unset.
+type Profile struct {
+	Name        string
+	Description string
+	Defaults    map[string]string // environment variable → default value
+}
+
+// profileVars are the variables every profile sets, in print order.
+var profileVars = []string{
+	"RUN_PUBLISH",
+	"CHANGED_ONLY_TESTS",
+	"LINT_CHANGED_ONLY",
+	"RUN_SLOW_TESTS",
+	"RUN_BENCHMARKS",
+	"RUN_DEPENDENCY_AUDIT",
+	"RUN_VULN_SCAN",
+	"NO_CACHE",
+}
+
+// Profiles is the table of profiles. main is the behavior without a profile.
+var Profiles = []Profile{
+	{
+		Name:        ProfilePR,
+		Description: "pull requests: tests and lint limited to the change, nothing published",
+		Defaults: map[string]string{
+			"RUN_PUBLISH":          "false",
+			"CHANGED_ONLY_TESTS":   "true",
+			"LINT_CHANGED_ONLY":    "true",
+			"RUN_SLOW_TESTS":       "false",
+			"RUN_BENCHMARKS":       "false",
+			"RUN_DEPENDENCY_AUDIT": "false",
+			"RUN_VULN_SCAN":        "false",
+			"NO_CACHE":             "false",
+		},
+	},
+	{
+		Name:        ProfileMain,
+		Description: "main builds: full test suite, image published",
+		Defaults: map[string]string{
+			"RUN_PUBLISH":          "true",
+			"CHANGED_ONLY_TESTS":   "false",
+			"LINT_CHANGED_ONLY":    "false",
+			"RUN_SLOW_TESTS":       "false",
+			"RUN_BENCHMARKS":       "false",
+			"RUN_DEPENDENCY_AUDIT": "false",
+			"RUN_VULN_SCAN":        "false",
+			"NO_CACHE":             "false",
+		},
+	},
+	{
+		Name:        ProfileNightly,
+		Description: "nightly deep run: main plus slow tests, benchmarks, audits and scans, no cached results",
+		Defaults: map[string]string{
+			"RUN_PUBLISH":          "true",
+			"CHANGED_ONLY_TESTS":   "false",
+			"LINT_CHANGED_ONLY":    "false",
+			"RUN_SLOW_TESTS":       "true",
+			"RUN_BENCHMARKS":       "true",
+			"RUN_DEPENDENCY_AUDIT": "true",
+			"RUN_VULN_SCAN":        "true",
+			"NO_CACHE":             "true",
+		},
+	},
+}
+
+// ProfileSetting is one resolved profile variable.
+type ProfileSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // SourceProfile, or SourceEnv when set explicitly
+}
+
+// ProfileRecord is the active profile with its resolved settings, recorded
+// in the report.
+type ProfileRecord struct {
+	Name     string           `json:"name"`
+	Settings []ProfileSetting `json:"settings"`
+}
+
+// LookupProfile returns the named profile; an empty name is main.
+func LookupProfile(name string) (Profile, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = ProfileMain
+	}
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// ResolveProfile resolves the named profile against the environment: a
+// variable that is set keeps its value, the others take the profile's.
+// Unknown names are CategoryConfig errors.
+func ResolveProfile(name string, lookupEnv func(string) (string, bool)) (*ProfileRecord, error) {
+	profile, ok := LookupProfile(name)
+	if !ok {
+		names := make([]string, len(Profiles))
+		for i, p := range Profiles {
+			names[i] = p.Name
+		}
+		return nil, Errorf(CategoryConfig, "unknown %s %q (use %s)", ProfileEnv, name, strings.Join(names, ", "))
+	}
+	record := &ProfileRecord{Name: profile.Name}
+	for _, v := range profileVars {
+		setting := ProfileSetting{Name: v, Value: profile.Defaults[v], Source: SourceProfile}
+		if value, ok := lookupEnv(v); ok && value != "" {
+			setting.Value, setting.Source = value, SourceEnv
+		}
+		record.Settings = append(record.Settings, setting)
+	}
+	return record, nil
+}
+
+// PrintProfile prints the active profile and its resolved settings.
+func PrintProfile(out io.Writer, r *ProfileRecord) {
+	profile, _ := LookupProfile(r.Name)
+	fmt.Fprintf(out, "🎛️  Profile: %s — %s (%s)\n", r.Name, profile.Description, ProfileEnv)
+	for _, s := range r.Settings {
+		if s.Source == SourceEnv {
+			fmt.Fprintf(out, "   %-22s %-6s (set in the environment)\n", s.Name, s.Value)
+		} else {
+			fmt.Fprintf(out, "   %-22s %s\n", s.Name, s.Value)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestResolveProfile tests that profiles fill in unset variables only
+func TestResolveProfile(t *testing.T) {
+	env := map[string]string{"RUN_PUBLISH": "true", "NO_CACHE": ""}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+
+	record, err := ResolveProfile("PR", lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Name != ProfilePR || len(record.Settings) != len(profileVars) {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	got := map[string]ProfileSetting{}
+	for _, s := range record.Settings {
+		got[s.Name] = s
+	}
+	if s := got["RUN_PUBLISH"]; s.Value != "true" || s.Source != SourceEnv {
+		t.Fatalf("explicit RUN_PUBLISH must win over the profile: %+v", s)
+	}
+	if s := got["CHANGED_ONLY_TESTS"]; s.Value != "true" || s.Source != SourceProfile {
+		t.Fatalf("pr profile should select changed tests: %+v", s)
+	}
+	if s := got["NO_CACHE"]; s.Value != "false" || s.Source != SourceProfile {
+		t.Fatalf("empty variables count as unset: %+v", s)
+	}
+
+	record, err = ResolveProfile("", lookup)
+	if err != nil || record.Name != ProfileMain {
+		t.Fatalf("empty profile should be main: %+v %v", record, err)
+	}
+	if _, err := ResolveProfile("weekly", lookup); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("unknown profile should be a config error, got %v", err)
+	}
+
+	nightly, _ := LookupProfile(ProfileNightly)
+	for _, v := range []string{"RUN_SLOW_TESTS", "RUN_BENCHMARKS", "RUN_DEPENDENCY_AUDIT", "RUN_VULN_SCAN", "NO_CACHE"} {
+		if nightly.Defaults[v] != "true" {
+			t.Fatalf("nightly should enable %s", v)
+		}
+	}
+
+	var out bytes.Buffer
+	PrintProfile(&out, &ProfileRecord{Name: ProfilePR, Settings: []ProfileSetting{{Name: "RUN_PUBLISH", Value: "true", Source: SourceEnv}}})
+	if !strings.Contains(out.String(), "Profile: pr") || !strings.Contains(out.String(), "set in the environment") {
+		t.Fatalf("unexpected profile output:\n%s", out.String())
+	}
+	fmt.Println("✅ Profiles resolved with environment overrides")
+}
@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// ProjectsReportFile is the combined summary of a multi-project run inside
+// the artifacts directory; each project also gets its own pipeline-report.json.
+const ProjectsReportFile = "projects-report.json"
+
+// DefaultChangedBase is the ref changed projects are detected against.
+const DefaultChangedBase = "origin/main"
+
+// ProjectsOptions configures RunProjects.
+type ProjectsOptions struct {
+	Glob        string          // Project directories relative to the repository root, e.g. services/*
+	ChangedOnly bool            // Only run projects with changes since ChangedBase in the host checkout
+	ChangedBase string          // Git ref compared with HEAD (default: DefaultChangedBase)
+	Dagger      *DaggerVersions // Copied into every project report (optional)
+	Attach      func(*Pipeline) // Called with each project's pipeline before it runs, e.g. for CI log sections (optional)
+}
+
+// ProjectResult is one project's outcome in a multi-project run.
+type ProjectResult struct {
+	Dir             string   `json:"dir"`
+	State           RunState `json:"state"`
+	Error           string   `json:"error,omitempty"`
+	Report          string   `json:"report,omitempty"`
+	PublishedImages []string `json:"published_images,omitempty"`
+	DurationSeconds float64  `json:"duration_seconds"`
+}
+
+// ProjectsReport is the combined summary of a multi-project run.
+type ProjectsReport struct {
+	Glob      string          `json:"glob"`
+	Projects  []ProjectResult `json:"projects"`
+	Unchanged []string        `json:"unchanged,omitempty"` // left out by ChangedOnly
+}
+
+// DiscoverProjects returns the directories matching glob that contain a
+// pyproject.toml, sorted.
+func DiscoverProjects(ctx context.Context, source *dagger.Directory, glob string) ([]string, error) {
+	matches, err := source.Glob(ctx, strings.TrimSuffix(glob, "/")+"/pyproject.toml")
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		dirs = append(dirs, path.Dir(m))
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// ChangedProjects keeps the projects containing a changed file. A change
+// outside every project (shared code, the root pyproject.toml, CI files)
+// selects all projects, and shared reports that it did.
+func ChangedProjects(dirs, changed []string) (selected, unchanged []string, shared bool) {
+	hit := map[string]bool{}
+	for _, file := range changed {
+		owner := ""
+		for _, dir := range dirs {
+			if strings.HasPrefix(file, dir+"/") {
+				owner = dir
+				break
+			}
+		}
+		if owner == "" {
+			return dirs, nil, true
+		}
+		hit[owner] = true
+	}
+	for _, dir := range dirs {
+		if hit[dir] {
+			selected = append(selected, dir)
+		} else {
+			unchanged = append(unchanged, dir)
+		}
+	}
+	return selected, unchanged, false
+}
+
+// changedFiles lists the files changed between base and HEAD in the host
+// checkout at repoDir, relative to the repository root.
+func changedFiles(ctx context.Context, repoDir, base string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", base+"...HEAD")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --name-only %s...HEAD: %w", base, err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// projectSlug names a project directory in file names and cache keys.
+func projectSlug(dir string) string {
+	return DockerSafeName(strings.ReplaceAll(strings.Trim(dir, "/"), "/", "-"))
+}
+
+// projectFile inserts the project slug before the extension of a file path
+// or URL, e.g. status.json → status-services-api.json.
+func projectFile(file, slug string) string {
+	if file == "" {
+		return ""
+	}
+	ext := path.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + slug + ext
+}
+
+// projectConfig derives a project's configuration from the shared one:
+// image name from its pyproject.toml, and its own artifacts directory,
+// status and history files and cache volumes.
+func projectConfig(cfg Config, dir string) Config {
+	slug := projectSlug(dir)
+	artifactsDir := cfg.ArtifactsDir
+	if artifactsDir == "" {
+		artifactsDir = DefaultArtifactsDir
+	}
+	if cfg.PackagePublish.ExportDir == filepath.Join(artifactsDir, "dist") {
+		cfg.PackagePublish.ExportDir = "" // defaults into the project's artifacts directory
+	}
+
+	cfg.ProjectDir = dir
+	root := cfg.ProjectRoot
+	if root == "" {
+		root = ".."
+	}
+	cfg.ProjectRoot = filepath.Join(root, filepath.FromSlash(dir))
+	cfg.ImageName = ""
+	cfg.ArtifactsDir = filepath.Join(artifactsDir, slug)
+	cfg.CacheKey = slug
+	if cfg.Warnings != nil {
+		cfg.Warnings = cfg.Warnings.Clone()
+	}
+	cfg.StatusFile = projectFile(cfg.StatusFile, slug)
+	cfg.BaselineFreeze = projectFile(cfg.BaselineFreeze, slug)
+	cfg.FlakyHistoryFile = projectFile(cfg.FlakyHistoryFile, slug)
+	cfg.FlakyHistoryURL = projectFile(cfg.FlakyHistoryURL, slug)
+	if cfg.FreezeHistoryDir != "" {
+		cfg.FreezeHistoryDir = filepath.Join(cfg.FreezeHistoryDir, slug)
+	}
+	return cfg
+}
+
+// RunProjects runs the pipeline once for every project directory matching
+// opts.Glob in the cloned repository, writes each project's report to its
+// own artifacts directory and the combined summary to projects-report.json.
+// A failing project does not stop the others; the returned error is then
+// that of the first failure.
+func RunProjects(ctx context.Context, client *dagger.Client, cfg Config, opts ProjectsOptions) (*ProjectsReport, error) {
+	base, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReleaseVersion != "" {
+		// One tag per repository: the second project would find it taken
+		return nil, Errorf(CategoryConfig, "ReleaseVersion releases a single project; it cannot be combined with project discovery (%s)", opts.Glob)
+	}
+	out := base.out
+	report := &ProjectsReport{Glob: opts.Glob}
+
+	fmt.Fprintf(out, "\n🗂️  Discovering projects matching %s in %s (%s: %s)\n", opts.Glob, base.cfg.GitRepo, base.checkoutKind(), gitRefName(base.cfg))
+	dirs, err := DiscoverProjects(ctx, base.checkout(base.gitRepo(client)).Tree(), opts.Glob)
+	if err != nil {
+		return report, Errorf(CategoryClone, "failed to discover projects: %w", err)
+	}
+	if len(dirs) == 0 {
+		return report, Errorf(CategoryConfig, "no directory matching %q contains a pyproject.toml", opts.Glob)
+	}
+	for _, dir := range dirs {
+		fmt.Fprintf(out, "   • %s\n", dir)
+	}
+
+	if opts.ChangedOnly {
+		changedBase := opts.ChangedBase
+		if changedBase == "" {
+			changedBase = DefaultChangedBase
+		}
+		changed, err := changedFiles(ctx, base.cfg.ProjectRoot, changedBase)
+		if err != nil {
+			fmt.Fprintf(out, "   ⚠️  Could not detect changed files (%v) — running all projects\n", err)
+		} else {
+			selected, unchanged, shared := ChangedProjects(dirs, changed)
+			if shared {
+				fmt.Fprintf(out, "   🔁 Files outside the projects changed since %s — running all projects\n", changedBase)
+			}
+			for _, dir := range unchanged {
+				fmt.Fprintf(out, "   ⏭️  %s unchanged since %s\n", dir, changedBase)
+			}
+			dirs, report.Unchanged = selected, unchanged
+		}
+	}
+
+	var firstErr error
+	for i, dir := range dirs {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(out, "\n%s\n", strings.Repeat("#", 80))
+		fmt.Fprintf(out, "PROJECT %d/%d: %s\n", i+1, len(dirs), dir)
+		fmt.Fprintln(out, strings.Repeat("#", 80))
+
+		result := ProjectResult{Dir: dir}
+		start := time.Now()
+		err := runProject(ctx, client, projectConfig(cfg, dir), opts, &result)
+		result.DurationSeconds = time.Since(start).Seconds()
+		if err != nil {
+			result.State, result.Error = RunFailed, err.Error()
+			if CategoryOf(err) == CategoryCancelled {
+				result.State = RunCancelled
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", dir, err)
+			}
+		} else {
+			result.State = RunSucceeded
+		}
+		report.Projects = append(report.Projects, result)
+	}
+
+	printProjectsSummary(out, report)
+	if data, err := json.MarshalIndent(report, "", "  "); err == nil {
+		path := filepath.Join(base.cfg.ArtifactsDir, ProjectsReportFile)
+		if err := WriteFileAtomic(path, data); err != nil {
+			fmt.Fprintf(out, "⚠️  Could not write projects report: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "📝 Projects report: %s\n", path)
+		}
+	}
+
+	if firstErr != nil {
+		failed := 0
+		for _, r := range report.Projects {
+			if r.State != RunSucceeded {
+				failed++
+			}
+		}
+		return report, Errorf(CategoryOf(firstErr), "%d of %d project(s) failed; first: %w", failed, len(report.Projects), firstErr)
+	}
+	return report, ctx.Err()
+}
+
+// runProject runs one project's pipeline and writes its report.
+func runProject(ctx context.Context, client *dagger.Client, cfg Config, opts ProjectsOptions, result *ProjectResult) error {
+	p, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	if opts.Attach != nil {
+		opts.Attach(p)
+	}
+	report, err := p.Run(ctx, client)
+	report.Dagger = opts.Dagger
+	result.PublishedImages = report.PublishedImages
+	if path, werr := WriteReport(p.cfg.ArtifactsDir, report); werr != nil {
+		fmt.Fprintf(p.out, "⚠️  Could not write pipeline report: %v\n", werr)
+	} else {
+		result.Report = path
+		fmt.Fprintf(p.out, "📝 Pipeline report: %s\n", path)
+	}
+	if p.cfg.HTMLReport {
+		if path, werr := WriteHTMLReport(p.cfg.ArtifactsDir, report); werr != nil {
+			fmt.Fprintf(p.out, "⚠️  Could not write HTML report: %v\n", werr)
+		} else {
+			fmt.Fprintf(p.out, "📝 HTML report: %s\n", path)
+		}
+	}
+	return err
+}
+
+// printProjectsSummary prints one line per project and the unchanged ones.
+func printProjectsSummary(out io.Writer, report *ProjectsReport) {
+	fmt.Fprintf(out, "\n%s\n", strings.Repeat("=", 80))
+	fmt.Fprintf(out, "PROJECTS SUMMARY (%s)\n", report.Glob)
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	for _, r := range report.Projects {
+		icon := "✅"
+		if r.State != RunSucceeded {
+			icon = "❌"
+		}
+		fmt.Fprintf(out, "   %s %-40s %-10s %6.0fs\n", icon, r.Dir, r.State, r.DurationSeconds)
+		if r.Error != "" {
+			fmt.Fprintf(out, "      %s\n", oneLine(r.Error))
+		}
+	}
+	for _, dir := range report.Unchanged {
+		fmt.Fprintf(out, "   ⏭️  %-40s unchanged\n", dir)
+	}
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestChangedProjects tests selecting monorepo projects from changed files
+func TestChangedProjects(t *testing.T) {
+	dirs := []string{"services/api", "services/api-gateway", "services/worker"}
+
+	selected, unchanged, shared := ChangedProjects(dirs, []string{"services/api-gateway/src/main.py", "services/worker/README.md"})
+	if shared || !reflect.DeepEqual(selected, []string{"services/api-gateway", "services/worker"}) || !reflect.DeepEqual(unchanged, []string{"services/api"}) {
+		t.Fatalf("got selected=%v unchanged=%v shared=%v", selected, unchanged, shared)
+	}
+
+	selected, unchanged, shared = ChangedProjects(dirs, []string{"services/api/app.py", "python_framework/core.py"})
+	if !shared || !reflect.DeepEqual(selected, dirs) || unchanged != nil {
+		t.Fatalf("a change outside the projects should select all, got %v %v %v", selected, unchanged, shared)
+	}
+
+	selected, _, _ = ChangedProjects(dirs, nil)
+	if len(selected) != 0 {
+		t.Fatalf("no changes should select nothing, got %v", selected)
+	}
+	fmt.Println("✅ Changed projects selected by directory")
+}
+
+// TestProjectConfig tests the per-project paths, image name and cache key
+func TestProjectConfig(t *testing.T) {
+	cfg := Config{
+		ImageName:        "shared-name",
+		ArtifactsDir:     "out",
+		StatusFile:       "/tmp/status.json",
+		FlakyHistoryURL:  "https://ci.example.com/history/tests.json",
+		FreezeHistoryDir: "/cache/freeze",
+		PackagePublish:   PackagePublish{ExportDir: filepath.Join("out", "dist")},
+	}
+	got := projectConfig(cfg, "services/Auth_API")
+	want := Config{
+		ProjectDir:       "services/Auth_API",
+		ProjectRoot:      filepath.Join("..", "services", "Auth_API"),
+		ArtifactsDir:     filepath.Join("out", "services-auth-api"),
+		CacheKey:         "services-auth-api",
+		StatusFile:       "/tmp/status-services-auth-api.json",
+		FlakyHistoryURL:  "https://ci.example.com/history/tests-services-auth-api.json",
+		FreezeHistoryDir: filepath.Join("/cache/freeze", "services-auth-api"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("projectConfig:\n got %+v\nwant %+v", got, want)
+	}
+	if cfg.ImageName != "shared-name" {
+		t.Fatal("projectConfig must not modify the shared config")
+	}
+	fmt.Println("✅ Project configuration derived from the shared one")
+}
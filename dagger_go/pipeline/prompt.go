@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPromptTimeout is how long a confirmation prompt waits before
+// answering "no" on its own.
+const DefaultPromptTimeout = 30 * time.Second
+
+// ConfirmFunc asks the operator a yes/no question. Config.Confirm is nil in
+// non-interactive runs, where the configured behavior applies unasked.
+type ConfirmFunc func(question string) bool
+
+// IsInteractive reports whether prompts should be shown: stdin must be a
+// terminal, and neither NON_INTERACTIVE nor CI may be set to a true value.
+func IsInteractive(stdin *os.File, getenv func(string) string) bool {
+	for _, key := range []string{"NON_INTERACTIVE", "CI"} {
+		switch strings.ToLower(getenv(key)) {
+		case "true", "1", "yes":
+			return false
+		}
+	}
+	return IsTerminal(stdin)
+}
+
+// Prompter asks yes/no questions on a terminal. Unanswered prompts default
+// to "no" after Timeout, so an unattended terminal never hangs the run.
+type Prompter struct {
+	In      io.Reader
+	Out     io.Writer
+	Timeout time.Duration // 0 means DefaultPromptTimeout
+
+	once  sync.Once
+	lines chan string
+}
+
+// Confirm prints the question and returns true only for an explicit "y" or "yes".
+func (p *Prompter) Confirm(question string) bool {
+	// A single reader goroutine feeds every prompt, so a line typed after a
+	// timed-out prompt is not mistaken for the answer to the next one.
+	p.once.Do(func() {
+		p.lines = make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(p.In)
+			for scanner.Scan() {
+				p.lines <- scanner.Text()
+			}
+			close(p.lines)
+		}()
+	})
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultPromptTimeout
+	}
+	fmt.Fprintf(p.Out, "❓ %s [y/N] (no answer in %v = no): ", question, timeout)
+
+	// Drop anything typed before the question was asked
+	for {
+		select {
+		case _, ok := <-p.lines:
+			if ok {
+				continue
+			}
+		default:
+		}
+		break
+	}
+
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			fmt.Fprintln(p.Out)
+			return false
+		}
+		answer := strings.ToLower(strings.TrimSpace(line))
+		return answer == "y" || answer == "yes"
+	case <-time.After(timeout):
+		fmt.Fprintln(p.Out, "\n   ⏱️  No answer — assuming no")
+		return false
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPrompterConfirm tests explicit answers and the default-no timeout
+func TestPrompterConfirm(t *testing.T) {
+	var out bytes.Buffer
+	p := &Prompter{In: strings.NewReader("yes\nn\n"), Out: &out, Timeout: time.Second}
+	if !p.Confirm("Publish latest?") {
+		t.Fatal("expected yes to confirm")
+	}
+	if p.Confirm("Publish latest?") {
+		t.Fatal("expected n to decline")
+	}
+	if p.Confirm("Publish latest?") {
+		t.Fatal("expected closed input to decline")
+	}
+	if !strings.Contains(out.String(), "Publish latest? [y/N]") {
+		t.Fatalf("question not printed: %q", out.String())
+	}
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	out.Reset()
+	silent := &Prompter{In: reader, Out: &out, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	if silent.Confirm("Delete tags?") {
+		t.Fatal("expected timeout to decline")
+	}
+	if time.Since(start) > 5*time.Second || !strings.Contains(out.String(), "assuming no") {
+		t.Fatalf("timeout not applied: %q", out.String())
+	}
+	fmt.Println("✅ Prompts accept explicit yes only and default to no on timeout")
+}
+
+// TestIsInteractive tests that CI and NON_INTERACTIVE disable prompts
+func TestIsInteractive(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+	for _, values := range []map[string]string{
+		{"NON_INTERACTIVE": "true"},
+		{"CI": "true"},
+		{"CI": "1"},
+	} {
+		if IsInteractive(os.Stdin, env(values)) {
+			t.Fatalf("expected prompts disabled for %v", values)
+		}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if IsInteractive(r, env(nil)) {
+		t.Fatal("a pipe is not a terminal")
+	}
+	if IsInteractive(nil, env(nil)) {
+		t.Fatal("no stdin is not interactive")
+	}
+	fmt.Println("✅ Prompts disabled without a TTY or in CI")
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultPublishRequires is the publish policy without PublishRequires: the
+// unit tests must have run and passed in this run.
+var DefaultPublishRequires = []string{"unit"}
+
+// publishStageNames maps the short PUBLISH_REQUIRES names to stage names;
+// the stage names themselves are accepted as well.
+var publishStageNames = map[string]string{
+	"unit":        StageUnitTests,
+	"slow":        StageSlowTests,
+	"benchmarks":  StageBenchmarks,
+	"integration": StageIntegrationTests,
+	"acceptance":  StageAcceptanceTests,
+	"lint":        StageLint,
+	"typecheck":   StageTypeCheck,
+	"audit":       StageDependencyAudit,
+	"hardening":   StageImageHardening,
+	"vuln-scan":   StageVulnScan,
+}
+
+// PublishStageName resolves a PUBLISH_REQUIRES entry such as "unit" or
+// "unit-tests" to its stage name.
+func PublishStageName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if stage, ok := publishStageNames[name]; ok {
+		return stage, nil
+	}
+	for _, stage := range publishStageNames {
+		if stage == name {
+			return stage, nil
+		}
+	}
+	short := make([]string, 0, len(publishStageNames))
+	for s := range publishStageNames {
+		short = append(short, s)
+	}
+	sort.Strings(short)
+	return "", fmt.Errorf("unknown stage %q in PublishRequires (use %s)", name, strings.Join(short, ", "))
+}
+
+// ParsePublishRequires splits a comma-separated PUBLISH_REQUIRES value. An
+// empty value returns nil, which keeps DefaultPublishRequires.
+func ParsePublishRequires(value string) []string {
+	return ParseCommaList(value)
+}
+
+// PublishPolicy is the publish policy of a run and how it was evaluated,
+// recorded in the run report.
+type PublishPolicy struct {
+	Enabled   bool     `json:"enabled"`
+	Requires  []string `json:"requires"`          // stage names
+	Satisfied bool     `json:"satisfied"`         // all required stages passed, or the policy is off
+	Missing   []string `json:"missing,omitempty"` // "lint (skipped: ...)"
+}
+
+// EvaluatePublishPolicy checks that every required stage passed in stages.
+// A stage listed in empty passed without running anything, e.g. a test stage
+// whose marker selected no tests, and does not count.
+func EvaluatePublishPolicy(requires []string, stages []StageRecord, empty map[string]bool) PublishPolicy {
+	policy := PublishPolicy{Enabled: true, Requires: requires}
+	for _, name := range requires {
+		status := "not run"
+		for _, s := range stages {
+			if s.Name != name {
+				continue
+			}
+			switch {
+			case s.Status == StagePassed && empty[name]:
+				status = "passed without running anything"
+			case s.Status == StagePassed:
+				status = ""
+			case s.Message != "":
+				status = fmt.Sprintf("%s: %s", s.Status, s.Message)
+			default:
+				status = string(s.Status)
+			}
+		}
+		if status != "" {
+			policy.Missing = append(policy.Missing, fmt.Sprintf("%s (%s)", name, status))
+		}
+	}
+	policy.Satisfied = len(policy.Missing) == 0
+	return policy
+}
+
+// publishRequired reports whether the publish policy needs stage to pass:
+// in a run that publishes, the versioned image or a pull request image.
+func (p *Pipeline) publishRequired(stage string) bool {
+	if !(p.cfg.RunPublish || p.cfg.PublishPRImage) || p.cfg.PublishPolicyOff {
+		return false
+	}
+	for _, name := range p.cfg.PublishRequires {
+		if name == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// freshFor keeps Dagger from answering a stage the publish policy requires
+// with a result cached by an earlier run, so the stage really runs in this
+// one. The steps after it are then not cached either.
+func (p *Pipeline) freshFor(stage string, container *dagger.Container) *dagger.Container {
+	if !p.bustsCache(stage) {
+		return container
+	}
+	return container.WithEnvVariable(cacheBusterEnv, p.cacheBuster)
+}
+
+// bustsCache reports whether freshFor busts the cache of stage; with
+// NoCache nothing is cached anyway.
+func (p *Pipeline) bustsCache(stage string) bool {
+	return !p.cfg.NoCache && p.publishRequired(stage)
+}
+
+// markEmpty records that stage passed without running anything.
+func (p *Pipeline) markEmpty(stage string) {
+	if p.emptyStages == nil {
+		p.emptyStages = map[string]bool{}
+	}
+	p.emptyStages[stage] = true
+}
+
+// checkPublishPolicy evaluates the publish policy before the publish stage
+// and records the result in the report.
+func (p *Pipeline) checkPublishPolicy() error {
+	if p.cfg.PublishPolicyOff {
+		p.report.PublishPolicy = &PublishPolicy{Requires: p.cfg.PublishRequires, Satisfied: true}
+		p.warnf("publish-policy-off", "\n🚨🚨🚨 PUBLISH_POLICY=off — publishing WITHOUT checking that tests passed in this run 🚨🚨🚨\n")
+		return nil
+	}
+	policy := EvaluatePublishPolicy(p.cfg.PublishRequires, p.tracker.Snapshot().Stages, p.emptyStages)
+	p.report.PublishPolicy = &policy
+	if !policy.Satisfied {
+		return Errorf(CategoryPublish, "publish policy not met: %s must pass in this run before publishing (PUBLISH_REQUIRES=%s)",
+			strings.Join(policy.Missing, ", "), strings.Join(p.cfg.PublishRequires, ","))
+	}
+	p.printf("🛡️  Publish policy met: %s passed in this run\n", strings.Join(policy.Requires, ", "))
+	return nil
+}
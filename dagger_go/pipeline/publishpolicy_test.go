@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestPublishStageName tests resolving short and full stage names and rejecting unknown ones
+func TestPublishStageName(t *testing.T) {
+	for name, want := range map[string]string{"unit": "unit-tests", " Lint ": "lint", "integration-tests": "integration-tests", "audit": "dependency-audit"} {
+		if got, err := PublishStageName(name); err != nil || got != want {
+			t.Fatalf("PublishStageName(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := PublishStageName("tests"); err == nil || !strings.Contains(err.Error(), "unit, ") {
+		t.Fatalf("unknown stage should list the valid names, got %v", err)
+	}
+	if got := ParsePublishRequires(" unit, lint ,,"); strings.Join(got, "|") != "unit|lint" {
+		t.Fatalf("ParsePublishRequires = %q", got)
+	}
+	if ParsePublishRequires("") != nil {
+		t.Fatal("an empty value should keep the default")
+	}
+	fmt.Println("✅ Publish policy stage names resolved")
+}
+
+// TestNewPublishRequires tests the default policy and that names are resolved and validated
+func TestNewPublishRequires(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Config().PublishRequires; strings.Join(got, ",") != "unit-tests" {
+		t.Fatalf("default PublishRequires = %q", got)
+	}
+	p, err = New(Config{RepoName: "cert-parser", GitUser: "org", PublishRequires: []string{"unit", "lint", "unit-tests"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Config().PublishRequires; strings.Join(got, ",") != "unit-tests,lint" {
+		t.Fatalf("PublishRequires = %q", got)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", PublishRequires: []string{"e2e"}}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("unknown stage should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Publish policy configured")
+}
+
+// TestEvaluatePublishPolicy tests that only stages that ran and passed satisfy the policy
+func TestEvaluatePublishPolicy(t *testing.T) {
+	stages := []StageRecord{
+		{Name: "unit-tests", Status: StagePassed},
+		{Name: "integration-tests", Status: StageSkipped, Message: "Docker not available"},
+		{Name: "lint", Status: StagePassed},
+	}
+	if policy := EvaluatePublishPolicy([]string{"unit-tests", "lint"}, stages, nil); !policy.Satisfied || len(policy.Missing) != 0 {
+		t.Fatalf("policy should be met: %+v", policy)
+	}
+
+	policy := EvaluatePublishPolicy([]string{"unit-tests", "integration-tests", "typecheck", "lint"}, stages, map[string]bool{"lint": true})
+	if policy.Satisfied || !policy.Enabled {
+		t.Fatalf("policy should not be met: %+v", policy)
+	}
+	want := []string{
+		"integration-tests (skipped: Docker not available)",
+		"typecheck (not run)",
+		"lint (passed without running anything)",
+	}
+	if strings.Join(policy.Missing, "|") != strings.Join(want, "|") {
+		t.Fatalf("Missing = %q, want %q", policy.Missing, want)
+	}
+	fmt.Println("✅ Publish policy evaluated against the run's stages")
+}
+
+// TestPublishPolicyPRImage tests that a pull request image run enforces the policy and runs the required stages fresh
+func TestPublishPolicyPRImage(t *testing.T) {
+	cfg := Config{RepoName: "cert-parser", GitUser: "org", RunBuild: true, RegistryToken: "t", PublishPRImage: true,
+		PullRequest: &PullRequest{Number: 7, HeadRepo: "org/cert-parser", BaseRepo: "org/cert-parser"}}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.publishRequired(StageUnitTests) || !p.bustsCache(StageUnitTests) {
+		t.Fatal("a pull request image run should require unit-tests to run in this run")
+	}
+	if p.publishRequired(StageLint) || p.freshFor(StageLint, nil) != nil {
+		t.Fatal("lint is not required and keeps its cache")
+	}
+
+	cfg.PublishPolicyOff = true
+	if p, _ := New(cfg); p.publishRequired(StageUnitTests) || p.bustsCache(StageUnitTests) {
+		t.Fatal("PUBLISH_POLICY=off should not require any stage")
+	}
+	cfg.PublishPolicyOff, cfg.NoCache = false, true
+	if p, _ := New(cfg); !p.publishRequired(StageUnitTests) || p.bustsCache(StageUnitTests) {
+		t.Fatal("with NoCache the stage is required but nothing is cached to bust")
+	}
+	if p, _ := New(Config{RepoName: "cert-parser", GitUser: "org"}); p.publishRequired(StageUnitTests) {
+		t.Fatal("a run that publishes nothing has no policy")
+	}
+	fmt.Println("✅ Publish policy enforced for pull request images")
+}
@@ -0,0 +1,311 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Byte order marks pyproject.toml may start with. TOML is UTF-8 without
+// one, but Windows editors write them.
+const (
+	utf8BOM    = "\xef\xbb\xbf"
+	utf16LEBOM = "\xff\xfe"
+	utf16BEBOM = "\xfe\xff"
+)
+
+// projectNameTables are the tables a project name is read from, the first
+// one that has it winning: [project] (PEP 621, what pip builds the wheel
+// with) before [tool.poetry].
+var projectNameTables = []string{"project", "tool.poetry"}
+
+// PyprojectError is pyproject.toml content that cannot be decoded, with
+// where it is.
+type PyprojectError struct {
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+	Offset int // bytes from the start of the file
+	Msg    string
+}
+
+func (e *PyprojectError) Error() string {
+	return fmt.Sprintf("pyproject.toml:%d:%d (byte %d): %s", e.Line, e.Column, e.Offset, e.Msg)
+}
+
+// ProjectNameDef is one definition of the project name.
+type ProjectNameDef struct {
+	Table string `json:"table"` // project or tool.poetry
+	Name  string `json:"name"`
+	Line  int    `json:"line"`
+}
+
+// PyprojectInfo is what reading pyproject.toml found, recorded in the
+// report.
+type PyprojectInfo struct {
+	Name        string           `json:"name,omitempty"`
+	Table       string           `json:"table,omitempty"` // the table Name comes from
+	Names       []ProjectNameDef `json:"names,omitempty"` // every definition, when there are several
+	BOM         bool             `json:"bom,omitempty"`
+	LineEndings string           `json:"line_endings,omitempty"` // crlf, cr or mixed; empty for lf
+	// Version is the static version, from the same tables as Name;
+	// DynamicVersion is set when the build backend computes it instead
+	// (dynamic = ["version"], poetry-dynamic-versioning).
+	Version        string `json:"version,omitempty"`
+	DynamicVersion bool   `json:"dynamic_version,omitempty"`
+}
+
+// NameConflict reports whether the project name is defined more than once
+// with names that are different projects (not just cert_parser against
+// cert-parser).
+func (info PyprojectInfo) NameConflict() bool {
+	for _, def := range info.Names {
+		if normalizeProjectName(def.Name) != normalizeProjectName(info.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pyprojectLine is one line of pyproject.toml without its line ending.
+type pyprojectLine struct {
+	text   string
+	number int
+	offset int
+}
+
+// splitPyprojectLines splits content at \n, \r\n and lone \r, keeping where
+// each line starts. base is added to the offsets.
+func splitPyprojectLines(content string, base int) (lines []pyprojectLine, endings map[string]bool) {
+	endings = map[string]bool{}
+	start := 0
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\n':
+			endings["lf"] = true
+		case '\r':
+			if i+1 < len(content) && content[i+1] == '\n' {
+				endings["crlf"] = true
+				lines = append(lines, pyprojectLine{content[start:i], len(lines) + 1, base + start})
+				i++
+				start = i + 1
+				continue
+			}
+			endings["cr"] = true
+		default:
+			continue
+		}
+		lines = append(lines, pyprojectLine{content[start:i], len(lines) + 1, base + start})
+		start = i + 1
+	}
+	if start < len(content) {
+		lines = append(lines, pyprojectLine{content[start:], len(lines) + 1, base + start})
+	}
+	return lines, endings
+}
+
+// ParsePyproject decodes pyproject.toml: a UTF-8 byte order mark is
+// stripped and line endings become \n in the returned content, and the
+// project name is read from [project], or [tool.poetry] without one. Content
+// that is not UTF-8, or a name that is not a single string, is a
+// *PyprojectError with its byte offset. A file without a name is not an
+// error; the name is then empty.
+func ParsePyproject(raw string) (string, PyprojectInfo, error) {
+	var info PyprojectInfo
+	if strings.HasPrefix(raw, utf16LEBOM) || strings.HasPrefix(raw, utf16BEBOM) {
+		return "", info, &PyprojectError{Line: 1, Column: 1, Msg: "the file is UTF-16 encoded; TOML must be UTF-8 — save it as UTF-8 without BOM"}
+	}
+	base := 0
+	if rest, ok := strings.CutPrefix(raw, utf8BOM); ok {
+		raw, base, info.BOM = rest, len(utf8BOM), true
+	}
+	lines, endings := splitPyprojectLines(raw, base)
+	switch {
+	case len(endings) > 1:
+		info.LineEndings = "mixed"
+	case endings["crlf"]:
+		info.LineEndings = "crlf"
+	case endings["cr"]:
+		info.LineEndings = "cr"
+	}
+
+	texts := make([]string, len(lines))
+	table, multiline, dynamicArray := "", "", false
+	defined := map[string]int{}
+	versions := map[string]string{}
+	for i, line := range lines {
+		texts[i] = line.text
+		if !utf8.ValidString(line.text) {
+			bad := 0
+			for bad < len(line.text) {
+				r, size := utf8.DecodeRuneInString(line.text[bad:])
+				if r == utf8.RuneError && size == 1 {
+					break
+				}
+				bad += size
+			}
+			return "", info, &PyprojectError{Line: line.number, Column: bad + 1, Offset: line.offset + bad,
+				Msg: fmt.Sprintf("invalid UTF-8 byte 0x%02x; TOML must be UTF-8 — re-save the file as UTF-8", line.text[bad])}
+		}
+		text := strings.TrimSpace(line.text)
+		if multiline != "" {
+			// Inside a multi-line string, such as a long description
+			if strings.Contains(text, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		if dynamicArray {
+			// dynamic = [ spread over several lines ]
+			info.DynamicVersion = info.DynamicVersion || dynamicListsVersion(text)
+			dynamicArray = !strings.Contains(text, "]")
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			table = tableName(text)
+			continue
+		}
+		key, value, ok := strings.Cut(line.text, "=")
+		if !ok {
+			continue
+		}
+		for _, quotes := range []string{`"""`, "'''"} {
+			if strings.Count(value, quotes)%2 == 1 {
+				multiline = quotes
+			}
+		}
+		path := tomlKeyPath(table, key)
+		switch path {
+		case "project.dynamic":
+			info.DynamicVersion = info.DynamicVersion || dynamicListsVersion(value)
+			dynamicArray = !strings.Contains(value, "]")
+		case "tool.poetry-dynamic-versioning.enable":
+			// The plugin replaces the placeholder version at build time
+			info.DynamicVersion = info.DynamicVersion || strings.HasPrefix(strings.TrimSpace(value), "true")
+		}
+		if tableOf, found := strings.CutSuffix(path, ".version"); found && slices.Contains(projectNameTables, tableOf) {
+			// Not a string is no version: the image tag falls back with a warning
+			if version, err := tomlSingleLineString(value); err == nil {
+				versions[tableOf] = version
+			}
+			continue
+		}
+		tableOf, found := strings.CutSuffix(path, ".name")
+		if !found || !slices.Contains(projectNameTables, tableOf) {
+			continue
+		}
+		column := len(key) + 1 + len(value) - len(strings.TrimLeft(value, " \t"))
+		name, err := tomlSingleLineString(value)
+		if err != nil {
+			return "", info, &PyprojectError{Line: line.number, Column: column + 1, Offset: line.offset + column,
+				Msg: fmt.Sprintf("[%s] name %v", tableOf, err)}
+		}
+		if first, twice := defined[tableOf]; twice {
+			return "", info, &PyprojectError{Line: line.number, Column: 1, Offset: line.offset,
+				Msg: fmt.Sprintf("[%s] name is defined twice (lines %d and %d)", tableOf, first, line.number)}
+		}
+		defined[tableOf] = line.number
+		info.Names = append(info.Names, ProjectNameDef{Table: tableOf, Name: name, Line: line.number})
+	}
+	for _, table := range projectNameTables {
+		for _, def := range info.Names {
+			if def.Table == table && info.Name == "" {
+				info.Name, info.Table = def.Name, def.Table
+			}
+		}
+		if info.Version == "" {
+			info.Version = versions[table]
+		}
+	}
+	if len(info.Names) < 2 {
+		info.Names = nil
+	}
+	content := strings.Join(texts, "\n")
+	if strings.HasSuffix(raw, "\n") || strings.HasSuffix(raw, "\r") {
+		content += "\n"
+	}
+	return content, info, nil
+}
+
+// dynamicListsVersion reports whether a line of a dynamic = [...] array
+// names "version".
+func dynamicListsVersion(text string) bool {
+	return strings.Contains(text, `"version"`) || strings.Contains(text, "'version'")
+}
+
+// tableName is the table a [table] or [[array]] header line opens, with
+// the quotes and spaces of its keys removed.
+func tableName(header string) string {
+	header = strings.TrimLeft(header, "[")
+	if i := strings.Index(header, "]"); i >= 0 {
+		header = header[:i]
+	}
+	return tomlKeyPath("", header)
+}
+
+// tomlKeyPath is the dotted path of key inside table: tool."poetry" . name
+// in the root table is tool.poetry.name.
+func tomlKeyPath(table, key string) string {
+	parts := strings.Split(strings.TrimSpace(key), ".")
+	for i, part := range parts {
+		parts[i] = unquoteTOML(strings.TrimSpace(part))
+	}
+	if table != "" {
+		parts = append([]string{table}, parts...)
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlSingleLineString parses the basic or literal string a value starts
+// with, followed by nothing but a comment.
+func tomlSingleLineString(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value[0] != '"' && value[0] != '\'' {
+		return "", fmt.Errorf("is not a quoted string: %s", value)
+	}
+	if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+		return "", fmt.Errorf("is a multi-line string")
+	}
+	end := strings.IndexByte(value[1:], value[0])
+	if end < 0 {
+		return "", fmt.Errorf("has no closing quote: %s", value)
+	}
+	rest := strings.TrimSpace(value[end+2:])
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("has text after the string: %s", rest)
+	}
+	name := value[1 : end+1]
+	if name == "" {
+		return "", fmt.Errorf("is empty")
+	}
+	return name, nil
+}
+
+// readPyproject decodes the pyproject.toml of the checkout and records what
+// it found in the report. A byte order mark is noted and a name defined in
+// both [project] and [tool.poetry] warns with both; a file that cannot be
+// decoded fails the run instead of the image silently being named after
+// the repository.
+func (p *Pipeline) readPyproject(raw string) (string, PyprojectInfo, error) {
+	content, info, err := ParsePyproject(raw)
+	if err != nil {
+		return "", info, Errorf(CategoryConfig, "%w — without a readable name the image would be named after the repository", err)
+	}
+	p.report.Pyproject = &info
+	if info.BOM {
+		p.println("   ℹ️  pyproject.toml starts with a UTF-8 byte order mark (BOM) — ignored; save it as UTF-8 without BOM")
+	}
+	if info.NameConflict() {
+		defs := make([]string, len(info.Names))
+		for i, def := range info.Names {
+			defs[i] = fmt.Sprintf("[%s] name = %q (line %d)", def.Table, def.Name, def.Line)
+		}
+		p.warnf("project-name-conflict", "\n⚠️  pyproject.toml names the project more than once:\n   %s\n"+
+			"   Using %q from [%s], the name pip builds the package with; make them match\n",
+			strings.Join(defs, "\n   "), info.Name, info.Table)
+	}
+	return content, info, nil
+}
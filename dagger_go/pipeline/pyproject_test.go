@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParsePyprojectBOM tests a pyproject.toml saved by a Windows editor
+func TestParsePyprojectBOM(t *testing.T) {
+	raw := "\xef\xbb\xbfname = \"top-level\"\r\n[project]\r\nname = \"cert-parser\"\r\n[project.scripts]\r\ncert-parser = \"cert_parser.main:run\"\r\n"
+	content, info, err := ParsePyproject(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "cert-parser" || info.Table != "project" || !info.BOM || info.LineEndings != "crlf" {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if strings.Contains(content, "\r") || strings.HasPrefix(content, "\xef") || !strings.HasSuffix(content, "\n") {
+		t.Fatalf("content not normalized: %q", content)
+	}
+	if ProjectScripts(content)["cert-parser"] != "cert_parser.main:run" {
+		t.Fatalf("scripts not parsed from %q", content)
+	}
+	if ExtractProjectName(raw) != "cert-parser" {
+		t.Fatalf("ExtractProjectName = %q", ExtractProjectName(raw))
+	}
+	fmt.Println("✅ BOM and CRLF stripped before parsing")
+}
+
+// TestParsePyprojectNames tests where the name is read from
+func TestParsePyprojectNames(t *testing.T) {
+	cases := []struct {
+		name, content, want, table string
+		conflict                   bool
+	}{
+		{"poetry only", "[tool.poetry]\nname = 'legacy-app' # poetry 1\n", "legacy-app", "tool.poetry", false},
+		{"project wins", "[tool.poetry]\nname = \"old-name\"\n\n[ project ]\nname = \"new-name\"\n", "new-name", "project", true},
+		{"same project", "[project]\nname = \"cert_parser\"\n[tool.poetry]\nname = \"cert-parser\"\n", "cert_parser", "project", false},
+		{"dotted keys", "project.name = \"dotted\"\n[tool]\npoetry.name = \"other\"\n", "dotted", "project", true},
+		{"other tables", "[tool.setuptools]\nname = \"nope\"\n[project.urls]\nname = \"nope\"\n", "", "", false},
+		{"multi-line description", "[project]\ndescription = \"\"\"\nname = \"nope\"\n[tool.poetry]\n\"\"\"\nname = \"real\"\n", "real", "project", false},
+	}
+	for _, c := range cases {
+		_, info, err := ParsePyproject(c.content)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if info.Name != c.want || info.Table != c.table || info.NameConflict() != c.conflict {
+			t.Fatalf("%s: unexpected info %+v", c.name, info)
+		}
+	}
+	fmt.Println("✅ Project name read from [project] before [tool.poetry]")
+}
+
+// TestExtractProjectVersion tests where the version is read from and dynamic versions
+func TestExtractProjectVersion(t *testing.T) {
+	cases := []struct {
+		name, content, want string
+		dynamic             bool
+	}{
+		{"double quotes", "[project]\nname = \"cert-parser\"\nversion = \"1.4.0\"\n", "1.4.0", false},
+		{"single quotes", "[project]\nversion = '2.0.0rc1' # next release\n", "2.0.0rc1", false},
+		{"poetry only", "[tool.poetry]\nname = \"legacy\"\nversion = \"0.9.1\"\n", "0.9.1", false},
+		{"project wins", "[tool.poetry]\nversion = \"0.9.1\"\n[project]\nversion = \"1.0.0\"\n", "1.0.0", false},
+		{"other tables", "[tool.commitizen]\nversion = \"3.0.0\"\n[project]\nname = \"x\"\n", "", false},
+		{"not a string", "[project]\nversion = 1.0\n", "", false},
+		{"dynamic", "[project]\nname = \"x\"\ndynamic = [\"version\", \"readme\"]\n", "", true},
+		{"dynamic on lines", "[project]\ndynamic = [\n  \"readme\",\n  'version',\n]\n", "", true},
+		{"dynamic readme only", "[project]\nversion = \"1.0.0\"\ndynamic = [\"readme\"]\n", "1.0.0", false},
+		{"poetry plugin", "[tool.poetry]\nversion = \"0.0.0\"\n[tool.poetry-dynamic-versioning]\nenable = true\n", "0.0.0", true},
+	}
+	for _, c := range cases {
+		version, dynamic := ExtractProjectVersion(c.content)
+		if version != c.want || dynamic != c.dynamic {
+			t.Fatalf("%s: got %q, dynamic %v", c.name, version, dynamic)
+		}
+	}
+	fmt.Println("✅ Project version read from [project] before [tool.poetry]")
+}
+
+// TestVersionTag tests the image tag prefix and its fallback
+func TestVersionTag(t *testing.T) {
+	for version, want := range map[string]string{"1.4.0": "v1.4.0", "v2.0.0": "v2.0.0", "1.4.0+corp.2": "v1.4.0-corp.2", "2.0.0rc1": "v2.0.0rc1"} {
+		if got, err := VersionTag(version); err != nil || got != want {
+			t.Fatalf("VersionTag(%q) = %q, %v; want %q", version, got, err, want)
+		}
+	}
+	if _, err := VersionTag("1.0 beta"); err == nil {
+		t.Fatal("a version with a space cannot be a tag")
+	}
+
+	var out bytes.Buffer
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag := p.versionTag(PyprojectInfo{Version: "1.4.0"}); tag != "v1.4.0" || out.Len() != 0 {
+		t.Fatalf("unexpected tag %q, output %q", tag, out.String())
+	}
+	if tag := p.versionTag(PyprojectInfo{DynamicVersion: true}); tag != DefaultVersionTag || !strings.Contains(out.String(), "dynamic") {
+		t.Fatalf("unexpected tag %q, output %q", tag, out.String())
+	}
+	if tag := p.versionTag(PyprojectInfo{}); tag != DefaultVersionTag || !strings.Contains(out.String(), "no version") {
+		t.Fatalf("unexpected tag %q, output %q", tag, out.String())
+	}
+	fmt.Println("✅ Image tag prefixed with the project version")
+}
+
+// TestParsePyprojectErrors tests decode errors with their byte offsets
+func TestParsePyprojectErrors(t *testing.T) {
+	cases := []struct {
+		name, content string
+		line, offset  int
+		msg           string
+	}{
+		{"utf-16", "\xff\xfe[\x00p\x00", 1, 0, "UTF-16"},
+		{"latin-1", "\xef\xbb\xbf[project]\nname = \"caf\xe9\"\n", 2, 24, "invalid UTF-8 byte 0xe9"},
+		{"unquoted", "[project]\r\nname = cert-parser\r\n", 2, 18, "is not a quoted string"},
+		{"unterminated", "[project]\nname = \"cert-parser\n", 2, 17, "no closing quote"},
+		{"twice", "[project]\nname = \"a\"\nname = \"b\"\n", 3, 21, "defined twice (lines 2 and 3)"},
+	}
+	for _, c := range cases {
+		_, _, err := ParsePyproject(c.content)
+		var perr *PyprojectError
+		if !errors.As(err, &perr) {
+			t.Fatalf("%s: expected a PyprojectError, got %v", c.name, err)
+		}
+		if perr.Line != c.line || perr.Offset != c.offset || !strings.Contains(perr.Msg, c.msg) {
+			t.Fatalf("%s: unexpected error %+v", c.name, perr)
+		}
+	}
+	fmt.Println("✅ pyproject.toml decode errors located")
+}
+
+// TestReadPyprojectConflict tests the warning for two different names
+func TestReadPyprojectConflict(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, info, err := p.readPyproject("[project]\nname = \"cert-parser\"\n[tool.poetry]\nname = \"icao-parser\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`[project] name = "cert-parser" (line 2)`, `[tool.poetry] name = "icao-parser" (line 4)`, `Using "cert-parser" from [project]`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in %q", want, out.String())
+		}
+	}
+	if p.report.Pyproject == nil || len(p.report.Pyproject.Names) != 2 || info.Name != "cert-parser" {
+		t.Fatalf("unexpected report %+v", p.report.Pyproject)
+	}
+	if _, _, err := p.readPyproject("[project]\nname = 42\n"); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a bad name should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Conflicting project names warned about")
+}
@@ -0,0 +1,319 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FailureListLimit is how many failures a stage summary lists before
+// pointing at the failures file.
+const FailureListLimit = 20
+
+// maxReasonLength caps the one-line failure reason printed in stage summaries.
+const maxReasonLength = 160
+
+// TestFailure is one failed or errored test extracted from pytest output.
+type TestFailure struct {
+	Stage   string `json:"stage"`
+	ID      string `json:"id"`     // node id, e.g. tests/test_db.py::TestRepo::test_insert
+	Kind    string `json:"kind"`   // FAILED or ERROR
+	Reason  string `json:"reason"` // one line, e.g. "AssertionError: expected 3"
+	Details string `json:"details,omitempty"`
+}
+
+// PytestSummary is the result of parsing a pytest run's output.
+type PytestSummary struct {
+	HasSummary bool // false when no final "== ... in Ns ==" line was found
+	Passed     int
+	Failed     int
+	Errors     int
+	Failures   []TestFailure
+}
+
+var (
+	pytestPassedPattern  = regexp.MustCompile(`(\d+) passed`)
+	pytestFailedPattern  = regexp.MustCompile(`(\d+) failed`)
+	pytestErrorPattern   = regexp.MustCompile(`(\d+) errors?\b`)
+	pytestShortPattern   = regexp.MustCompile(`^(FAILED|ERROR) (\S+)(?: - (.*))?$`)
+	pytestSectionPattern = regexp.MustCompile(`^_{3,} (.+?) _{3,}$`)
+	pytestBlockPattern   = regexp.MustCompile(`^=+ (FAILURES|ERRORS) =+$`)
+	pytestErrorAtPattern = regexp.MustCompile(`^ERROR (?:at|collecting) (?:(?:setup|teardown|call) of )?`)
+	pytestCollectPattern = regexp.MustCompile(`(?m)^(\d+)(?:/\d+)? tests? collected`)
+	pytestNoTestsPattern = regexp.MustCompile(`(?m)\bno tests (?:ran|collected)\b`)
+)
+
+// pytest's documented exit codes.
+const (
+	PytestExitTestsFailed   = 1 // tests ran and some failed
+	PytestExitInterrupted   = 2 // the run was interrupted, e.g. Ctrl-C or a collection error with -x
+	PytestExitInternalError = 3 // pytest or a plugin crashed
+	PytestExitUsageError    = 4 // bad command line: unknown option, invalid -m expression, missing path
+	PytestExitNoTests       = 5 // no tests were collected or run
+)
+
+// PytestExitMeaning describes a pytest exit code.
+func PytestExitMeaning(code int) string {
+	switch code {
+	case 0:
+		return "all tests passed"
+	case PytestExitTestsFailed:
+		return "tests failed"
+	case PytestExitInterrupted:
+		return "test run interrupted"
+	case PytestExitInternalError:
+		return "pytest internal error"
+	case PytestExitUsageError:
+		return "pytest usage error"
+	case PytestExitNoTests:
+		return "no tests collected"
+	}
+	return "pytest terminated abnormally (killed or crashed)"
+}
+
+// PytestExit is a test stage whose pytest run exited non-zero, recorded
+// under "pytest_exits" in the report.
+type PytestExit struct {
+	Stage    string `json:"stage"`
+	ExitCode int    `json:"exit_code"`
+	Meaning  string `json:"meaning"`
+	Command  string `json:"command"`
+}
+
+// TestRunError is a pytest run that exited non-zero. Usage errors mean the
+// command line is wrong, not the tests.
+type TestRunError struct {
+	PytestExit
+}
+
+func (e *TestRunError) Error() string {
+	switch e.ExitCode {
+	case PytestExitTestsFailed:
+		return fmt.Sprintf("%s tests failed (pytest exit code 1)", e.Stage)
+	case PytestExitUsageError:
+		return fmt.Sprintf("pytest usage error in the %s stage (exit code 4) — check the command line, not the tests: %s", e.Stage, e.Command)
+	}
+	return fmt.Sprintf("%s stage: %s (pytest exit code %d)", e.Stage, e.Meaning, e.ExitCode)
+}
+
+// testStageError categorizes a failed test stage: a pytest usage error is
+// a configuration problem, anything else a test failure.
+func testStageError(label string, err error) error {
+	var runErr *TestRunError
+	if !errors.As(err, &runErr) {
+		return Errorf(CategoryTest, "%s failed: %w", label, err)
+	}
+	if runErr.ExitCode == PytestExitUsageError {
+		return Errorf(CategoryConfig, "%w", err)
+	}
+	return Errorf(CategoryTest, "%w", err)
+}
+
+// pytestFailed records a non-zero pytest exit in the report and returns it
+// as a TestRunError. Usage and internal errors print the command line
+// prominently, since the tests themselves never ran.
+func (p *Pipeline) pytestFailed(stage string, exitCode int, cmd []string) error {
+	exit := PytestExit{Stage: stage, ExitCode: exitCode, Meaning: PytestExitMeaning(exitCode), Command: shellJoin(cmd)}
+	p.report.PytestExits = append(p.report.PytestExits, exit)
+	switch exitCode {
+	case PytestExitTestsFailed:
+	case PytestExitUsageError, PytestExitInternalError:
+		p.printf("\n   ❌ %s (exit code %d) — the tests did not run; check the command line:\n", exit.Meaning, exitCode)
+		p.printf("   $ %s\n", exit.Command)
+	default:
+		p.printf("\n   ❌ %s (exit code %d)\n", exit.Meaning, exitCode)
+	}
+	return &TestRunError{exit}
+}
+
+// NoTestsRan reports whether a pytest run selected no tests at all. Usage
+// and internal errors also print "no tests ran" but keep their exit code.
+func NoTestsRan(output string, exitCode int) bool {
+	return exitCode == PytestExitNoTests || (exitCode == 0 && pytestNoTestsPattern.MatchString(output))
+}
+
+// CollectedCount parses the output of `pytest --collect-only -q`. Exit code
+// 5 (nothing selected) counts as zero; any other failure is a collection
+// error, such as an import error in a test module.
+func CollectedCount(output string, exitCode int) (int, error) {
+	if exitCode != 0 && exitCode != PytestExitNoTests {
+		return 0, fmt.Errorf("pytest --collect-only exited with code %d (%s)", exitCode, PytestExitMeaning(exitCode))
+	}
+	if m := pytestCollectPattern.FindStringSubmatch(output); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n, nil
+	}
+	if pytestNoTestsPattern.MatchString(output) {
+		return 0, nil
+	}
+	// No summary line (e.g. -p no:terminal): count the node ids.
+	n := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "::") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ParsePytestOutput extracts the pass/fail counts, the "FAILED <id> - <reason>"
+// short-summary lines, and the --tb=short traceback section of each failure.
+func ParsePytestOutput(output string) PytestSummary {
+	var s PytestSummary
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+
+	sections := map[string]string{}
+	inBlock := false
+	current := ""
+	var body []string
+	closeSection := func() {
+		if current != "" {
+			sections[current] = strings.TrimRight(strings.Join(body, "\n"), "\n")
+		}
+		current, body = "", nil
+	}
+
+	for _, line := range lines {
+		switch {
+		case pytestBlockPattern.MatchString(line):
+			closeSection()
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "===="):
+			closeSection()
+			inBlock = false
+			if strings.Contains(line, "passed") || strings.Contains(line, "failed") || strings.Contains(line, "error") {
+				s.HasSummary = true
+				s.Passed = firstInt(pytestPassedPattern, line)
+				s.Failed = firstInt(pytestFailedPattern, line)
+				s.Errors = firstInt(pytestErrorPattern, line)
+			}
+			continue
+		}
+		if inBlock {
+			if m := pytestSectionPattern.FindStringSubmatch(line); m != nil {
+				closeSection()
+				current = pytestErrorAtPattern.ReplaceAllString(m[1], "")
+				continue
+			}
+			if current != "" {
+				body = append(body, line)
+			}
+			continue
+		}
+		if m := pytestShortPattern.FindStringSubmatch(line); m != nil {
+			s.Failures = append(s.Failures, TestFailure{ID: m[2], Kind: m[1], Reason: m[3]})
+		}
+	}
+	closeSection()
+
+	for i := range s.Failures {
+		f := &s.Failures[i]
+		f.Details = sections[sectionKey(f.ID)]
+		if f.Details == "" {
+			f.Details = sections[f.ID]
+		}
+		if f.Reason == "" {
+			f.Reason = lastErrorLine(f.Details)
+		}
+		f.Reason = oneLine(f.Reason)
+	}
+	return s
+}
+
+// WithStage returns a copy of the failures tagged with the stage name.
+func (s PytestSummary) WithStage(stage string) []TestFailure {
+	failures := make([]TestFailure, len(s.Failures))
+	for i, f := range s.Failures {
+		f.Stage = stage
+		failures[i] = f
+	}
+	return failures
+}
+
+// WriteFailuresFile writes the full details of each failure to
+// dir/<stage>-failures.txt and returns the path.
+func WriteFailuresFile(dir, stage string, failures []TestFailure) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s test failure(s)\n", len(failures), stage)
+	for _, f := range failures {
+		fmt.Fprintf(&b, "\n%s\n%s %s\n", strings.Repeat("─", 80), f.Kind, f.ID)
+		if f.Reason != "" {
+			fmt.Fprintf(&b, "Reason: %s\n", f.Reason)
+		}
+		if f.Details != "" {
+			fmt.Fprintf(&b, "\n%s\n", f.Details)
+		}
+	}
+	path := filepath.Join(dir, stage+"-failures.txt")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return path, WriteFileAtomic(path, []byte(b.String()))
+}
+
+// sectionKey converts a node id to the title pytest uses for its traceback
+// section: tests/test_x.py::TestClass::test_y[p] → TestClass.test_y[p].
+func sectionKey(id string) string {
+	parts := strings.Split(id, "::")
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
+
+// lastErrorLine returns the last "E   ..." line of a traceback, without the prefix.
+func lastErrorLine(details string) string {
+	lines := strings.Split(details, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); strings.HasPrefix(line, "E ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "E"))
+		}
+	}
+	return ""
+}
+
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxReasonLength {
+		s = string(r[:maxReasonLength-1]) + "…"
+	}
+	return s
+}
+
+func firstInt(re *regexp.Regexp, line string) int {
+	if m := re.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// PrintFailureList prints one "test id — reason" line per failure, up to
+// limit entries, for the stage summary.
+func PrintFailureList(w io.Writer, failures []TestFailure, limit int) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "   Failing tests (%d):\n", len(failures))
+	for i, f := range failures {
+		if i == limit {
+			fmt.Fprintf(w, "   … and %d more (see the failures file)\n", len(failures)-limit)
+			break
+		}
+		marker := "✗"
+		if f.Kind == "ERROR" {
+			marker = "⚠"
+		}
+		if f.Reason == "" {
+			fmt.Fprintf(w, "   %s %s\n", marker, f.ID)
+		} else {
+			fmt.Fprintf(w, "   %s %s — %s\n", marker, f.ID, f.Reason)
+		}
+	}
+}
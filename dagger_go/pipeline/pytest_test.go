@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+const samplePytestOutput = `============================= test session starts ==============================
+collected 5 items / 1 deselected / 4 selected
+
+tests/integration/test_repo.py::TestRepository::test_insert FAILED       [ 25%]
+tests/integration/test_repo.py::test_query[limit-10] FAILED              [ 50%]
+tests/integration/test_repo.py::test_connect ERROR                       [ 75%]
+tests/integration/test_repo.py::test_ok PASSED                           [100%]
+
+==================================== ERRORS ====================================
+________________________ ERROR at setup of test_connect ________________________
+tests/conftest.py:12: in pg
+    return start_container()
+E   docker.errors.DockerException: Error while fetching server API version
+=================================== FAILURES ===================================
+_________________________ TestRepository.test_insert __________________________
+tests/integration/test_repo.py:20: in test_insert
+    assert repo.count() == 3
+E   AssertionError: assert 2 == 3
+E    +  where 2 = count()
+____________________________ test_query[limit-10] _____________________________
+tests/integration/test_repo.py:31: in test_query
+    rows = repo.query(limit=10)
+E   psycopg.errors.UndefinedTable: relation "certs" does not exist
+=========================== short test summary info ============================
+FAILED tests/integration/test_repo.py::TestRepository::test_insert - AssertionError: assert 2 == 3
+FAILED tests/integration/test_repo.py::test_query[limit-10] - psycopg.errors.UndefinedTable: relation "certs" does not exist
+ERROR tests/integration/test_repo.py::test_connect - docker.errors.DockerException: Error while fetching server API version
+============ 2 failed, 1 passed, 1 deselected, 1 error in 3.21s ================
+`
+
+// TestParsePytestOutput tests count, failure id, reason and traceback extraction
+func TestParsePytestOutput(t *testing.T) {
+	s := ParsePytestOutput(samplePytestOutput)
+	if !s.HasSummary || s.Passed != 1 || s.Failed != 2 || s.Errors != 1 {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if len(s.Failures) != 3 {
+		t.Fatalf("expected 3 failures, got %d: %+v", len(s.Failures), s.Failures)
+	}
+
+	insert := s.Failures[0]
+	if insert.ID != "tests/integration/test_repo.py::TestRepository::test_insert" || insert.Kind != "FAILED" {
+		t.Fatalf("unexpected first failure: %+v", insert)
+	}
+	if insert.Reason != "AssertionError: assert 2 == 3" {
+		t.Fatalf("unexpected reason: %q", insert.Reason)
+	}
+	if !strings.Contains(insert.Details, "assert repo.count() == 3") {
+		t.Fatalf("traceback not attached to class-based test: %q", insert.Details)
+	}
+	if !strings.Contains(s.Failures[1].Details, "UndefinedTable") {
+		t.Fatalf("traceback not attached to parametrized test: %q", s.Failures[1].Details)
+	}
+	connect := s.Failures[2]
+	if connect.Kind != "ERROR" || !strings.Contains(connect.Details, "start_container()") {
+		t.Fatalf("setup error not extracted: %+v", connect)
+	}
+	fmt.Println("✅ Per-test failures extracted from pytest output")
+}
+
+// TestParsePytestOutputReasonFallback tests reasons taken from the traceback
+func TestParsePytestOutputReasonFallback(t *testing.T) {
+	output := `=================================== FAILURES ===================================
+___________________________________ test_x ____________________________________
+E   ValueError: ` + strings.Repeat("x", 300) + `
+=========================== short test summary info ============================
+FAILED tests/test_a.py::test_x
+========================= 1 failed in 0.10s =========================
+`
+	s := ParsePytestOutput(output)
+	if len(s.Failures) != 1 {
+		t.Fatalf("expected 1 failure, got %+v", s.Failures)
+	}
+	reason := s.Failures[0].Reason
+	if !strings.HasPrefix(reason, "ValueError: xxx") || len([]rune(reason)) != maxReasonLength {
+		t.Fatalf("expected truncated traceback reason, got %q", reason)
+	}
+	fmt.Println("✅ Failure reason falls back to the traceback's last E line")
+}
+
+// TestWriteFailuresFile tests the per-stage failures artifact
+func TestWriteFailuresFile(t *testing.T) {
+	s := ParsePytestOutput(samplePytestOutput)
+	path, err := WriteFailuresFile(t.TempDir(), "integration", s.WithStage("integration"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"3 integration test failure(s)", "FAILED tests/integration/test_repo.py::test_query[limit-10]", "relation \"certs\" does not exist"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in failures file:\n%s", want, content)
+		}
+	}
+	fmt.Println("✅ Failures file written with full details")
+}
+
+// TestPytestExitCodes tests that pytest exit codes get distinct errors and categories
+func TestPytestExitCodes(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage := p.pytestFailed("integration", PytestExitUsageError, []string{"pytest", "-m", "integration and"})
+	if !strings.Contains(usage.Error(), "usage error") || !strings.Contains(usage.Error(), "'integration and'") {
+		t.Fatalf("usage error should name the command line: %v", usage)
+	}
+	if !strings.Contains(out.String(), "$ pytest -m 'integration and'") {
+		t.Fatalf("usage error should print the command line:\n%s", out.String())
+	}
+	if got := CategoryOf(testStageError("integration tests", usage)); got != CategoryConfig {
+		t.Fatalf("usage error category = %v, want config", got)
+	}
+
+	failed := p.pytestFailed("unit", PytestExitTestsFailed, []string{"pytest"})
+	if got := CategoryOf(testStageError("unit tests", failed)); got != CategoryTest {
+		t.Fatalf("test failure category = %v, want test", got)
+	}
+	if !strings.Contains(p.pytestFailed("unit", PytestExitInternalError, []string{"pytest"}).Error(), "internal error") {
+		t.Fatal("internal error not described")
+	}
+	if got := CategoryOf(testStageError("unit tests", errors.New("engine lost"))); got != CategoryTest {
+		t.Fatalf("other errors category = %v, want test", got)
+	}
+	if len(p.report.PytestExits) != 3 || p.report.PytestExits[0].ExitCode != PytestExitUsageError || p.report.PytestExits[0].Meaning != "pytest usage error" {
+		t.Fatalf("unexpected pytest_exits: %+v", p.report.PytestExits)
+	}
+
+	if NoTestsRan("ERROR: Wrong expression passed to '-m'\n=== no tests ran in 0.01s ===", PytestExitUsageError) {
+		t.Fatal("a usage error is not an empty stage")
+	}
+	fmt.Println("✅ pytest exit codes reported distinctly")
+}
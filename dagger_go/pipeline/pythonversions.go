@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	// pythonVersionPattern matches a minor Python version such as 3.12.
+	pythonVersionPattern = regexp.MustCompile(`^3\.[0-9]{1,2}$`)
+	// imageTagPythonVersion splits an image reference whose tag starts with
+	// the Python version: python:3.14-slim, python:3.14.2-slim-bookworm.
+	imageTagPythonVersion = regexp.MustCompile(`^(.*:)(3\.[0-9]{1,2})(\.[0-9]+)?([^/:]*)$`)
+)
+
+// PythonVersionRun is what the unit-test, lint and type-check stages
+// returned on one of PythonVersions besides the base image's: passed,
+// failed or no tests; empty when the stage did not run.
+type PythonVersionRun struct {
+	Version   string `json:"version"`
+	Image     string `json:"image"`
+	UnitTests string `json:"unit_tests,omitempty"`
+	Lint      string `json:"lint,omitempty"`
+	TypeCheck string `json:"type_check,omitempty"`
+}
+
+// ParsePythonVersions parses PYTHON_VERSIONS: comma-separated minor
+// versions, e.g. 3.12,3.13,3.14. Duplicates are dropped.
+func ParsePythonVersions(value string) ([]string, error) {
+	var versions []string
+	for _, version := range ParseCommaList(value) {
+		if !pythonVersionPattern.MatchString(version) {
+			return nil, fmt.Errorf("%q is not a minor Python version such as 3.13", version)
+		}
+		if !slices.Contains(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// PythonVersionImage returns base with the Python version of its tag
+// replaced, e.g. python:3.14-slim for 3.12 → python:3.12-slim. base must be
+// tagged with its version, and not pinned by digest.
+func PythonVersionImage(base, version string) (string, error) {
+	m := imageTagPythonVersion.FindStringSubmatch(base)
+	if m == nil || strings.Contains(base, "@") {
+		return "", fmt.Errorf("base image %s has no Python version tag to swap, e.g. python:3.14-slim", base)
+	}
+	return m[1] + version + m[4], nil
+}
+
+// imagePythonVersion is the minor Python version in the tag of image, or
+// empty when the tag has none.
+func imagePythonVersion(image string) string {
+	if m := imageTagPythonVersion.FindStringSubmatch(image); m != nil {
+		return m[2]
+	}
+	return ""
+}
+
+// matrixVersions are the PythonVersions the matrix runs, with their images:
+// every version but the base image's, which the stages themselves run on.
+func matrixVersions(cfg Config) ([]PythonVersionRun, error) {
+	base := baseImageRef(cfg)
+	var runs []PythonVersionRun
+	for _, version := range cfg.PythonVersions {
+		if version == imagePythonVersion(base) {
+			continue
+		}
+		image, err := PythonVersionImage(base, version)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, PythonVersionRun{Version: version, Image: image})
+	}
+	return runs, nil
+}
+
+// pythonEnvs builds the environment of each matrix version once: the same
+// system environment and install as the build environment, from the
+// version's image and with a pip cache volume of its own.
+func (p *Pipeline) pythonEnvs(client *dagger.Client, source *dagger.Directory) []*dagger.Container {
+	if p.pythonVersionEnvs != nil {
+		return p.pythonVersionEnvs
+	}
+	for _, run := range p.report.PythonVersions {
+		p.printf("\n🐍 Python %s environment (PYTHON_VERSIONS)\n", run.Version)
+		system, _ := p.systemEnvFrom(client, MirrorImage(run.Image, p.cfg.BaseImageMirror), PipCacheVolume+"-py"+run.Version)
+		env, _ := p.installProject(client, system, source)
+		p.pythonVersionEnvs = append(p.pythonVersionEnvs, env)
+	}
+	return p.pythonVersionEnvs
+}
+
+// pythonMatrix is a stage running on the matrix versions while the stage
+// itself runs on the base image's version.
+type pythonMatrix struct {
+	stage   string
+	group   *errgroup.Group
+	cancel  context.CancelFunc
+	results []string // per matrix version: passed, failed or no tests
+	outputs []string
+}
+
+// startPythonMatrix runs cmd for stage on every matrix version at once, in
+// the background. Nothing is printed until finishPythonMatrix. It returns
+// nil without PythonVersions.
+func (p *Pipeline) startPythonMatrix(ctx context.Context, client *dagger.Client, source *dagger.Directory, stage string, cmd []string) *pythonMatrix {
+	if len(p.report.PythonVersions) == 0 || cmd == nil {
+		return nil
+	}
+	envs := p.pythonEnvs(client, source)
+	ctx, cancel := context.WithCancel(ctx)
+	group, ctx := errgroup.WithContext(ctx)
+	m := &pythonMatrix{stage: stage, group: group, cancel: cancel, results: make([]string, len(envs)), outputs: make([]string, len(envs))}
+	for i, env := range envs {
+		container := p.asTestUser(p.withStageEnv(client, stage, p.freshFor(stage, env))).
+			WithExec(p.memoryLimited(p.withUmask(cmd)), dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+		version := p.report.PythonVersions[i].Version
+		group.Go(func() error {
+			stdout, err := container.Stdout(ctx)
+			if err != nil {
+				return fmt.Errorf("Python %s: %w", version, err)
+			}
+			code, err := container.ExitCode(ctx)
+			if err != nil {
+				return fmt.Errorf("Python %s: %w", version, err)
+			}
+			stderr, _ := container.Stderr(ctx)
+			m.outputs[i] = strings.TrimSpace(stdout + "\n" + stderr)
+			switch {
+			case stage == StageUnitTests && NoTestsRan(stdout, code):
+				m.results[i] = "no tests"
+			case code == 0:
+				m.results[i] = "passed"
+			default:
+				m.results[i] = "failed"
+			}
+			return nil
+		})
+	}
+	return m
+}
+
+// stop cancels a matrix whose stage failed on the base image's version;
+// its results are not reported.
+func (m *pythonMatrix) stop() {
+	if m != nil {
+		m.cancel()
+		_ = m.group.Wait()
+	}
+}
+
+// finishPythonMatrix waits for the matrix, prints the result of every
+// version with the output of those that failed, records them in the report
+// and fails the stage naming the versions it failed on.
+func (p *Pipeline) finishPythonMatrix(m *pythonMatrix) error {
+	if m == nil {
+		return nil
+	}
+	defer m.cancel()
+	category := CategoryLint
+	if m.stage == StageUnitTests {
+		category = CategoryTest
+	}
+	if err := m.group.Wait(); err != nil {
+		return Errorf(category, "%s could not run: %w", m.stage, err)
+	}
+	var failed []string
+	p.printf("\n🐍 %s on the other Python versions (PYTHON_VERSIONS):\n", m.stage)
+	for i, result := range m.results {
+		run := &p.report.PythonVersions[i]
+		switch m.stage {
+		case StageUnitTests:
+			run.UnitTests = result
+		case StageLint:
+			run.Lint = result
+		case StageTypeCheck:
+			run.TypeCheck = result
+		}
+		icon := map[string]string{"passed": "✅", "failed": "❌", "no tests": "⏭️ "}[result]
+		p.printf("   %s Python %-5s %s (%s)\n", icon, run.Version, result, run.Image)
+		if result == "failed" {
+			failed = append(failed, run.Version)
+			p.printf("\n── Python %s ──\n%s\n\n", run.Version, m.outputs[i])
+		}
+	}
+	if len(failed) > 0 {
+		return Errorf(category, "%s failed on Python %s (passed on %s)", m.stage, strings.Join(failed, ", "), p.primaryPythonVersion())
+	}
+	return nil
+}
+
+// primaryPythonVersion names the base image's Python version, which builds
+// the image.
+func (p *Pipeline) primaryPythonVersion() string {
+	if version := imagePythonVersion(baseImageRef(p.cfg)); version != "" {
+		return version
+	}
+	return baseImageRef(p.cfg)
+}
+
+// primaryPythonRun is the row of the base image's version, from the stages
+// of the finished run.
+func (p *Pipeline) primaryPythonRun() PythonVersionRun {
+	run := PythonVersionRun{Version: p.primaryPythonVersion(), Image: p.baseImage()}
+	for _, stage := range p.report.Status.Stages {
+		result := map[StageStatus]string{StagePassed: "passed", StageFailed: "failed"}[stage.Status]
+		if result == "passed" && p.emptyStages[stage.Name] {
+			result = "no tests"
+		}
+		switch stage.Name {
+		case StageUnitTests:
+			run.UnitTests = result
+		case StageLint:
+			run.Lint = result
+		case StageTypeCheck:
+			run.TypeCheck = result
+		}
+	}
+	return run
+}
+
+// printPythonVersions writes the matrix results to the CI job summary.
+func (p *Pipeline) printPythonVersions() {
+	if len(p.report.PythonVersions) == 0 || p.cfg.StepSummary == "" {
+		return
+	}
+	runs := append([]PythonVersionRun{p.primaryPythonRun()}, p.report.PythonVersions...)
+	if err := AppendStepSummary(p.cfg.StepSummary, PythonVersionsMarkdown(runs)); err != nil {
+		p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", err)
+	}
+}
+
+// PythonVersionsMarkdown renders the unit-test, lint and type-check results
+// per Python version for a CI job summary; the first run is the base
+// image's version.
+func PythonVersionsMarkdown(runs []PythonVersionRun) string {
+	var b strings.Builder
+	b.WriteString("### Python versions\n\n| Python | Unit tests | Lint | Type check |\n|---|---|---|---|\n")
+	cell := func(result string) string {
+		if result == "" {
+			return "not run"
+		}
+		return result
+	}
+	for i, run := range runs {
+		version := run.Version
+		if i == 0 {
+			version += " (image)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", version, cell(run.UnitTests), cell(run.Lint), cell(run.TypeCheck))
+	}
+	return b.String()
+}
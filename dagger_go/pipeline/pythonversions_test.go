@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParsePythonVersions tests the PYTHON_VERSIONS list
+func TestParsePythonVersions(t *testing.T) {
+	versions, err := ParsePythonVersions(" 3.12,3.13, 3.14,3.12,")
+	if err != nil || strings.Join(versions, ",") != "3.12,3.13,3.14" {
+		t.Fatalf("got %v, %v", versions, err)
+	}
+	for _, value := range []string{"3", "3.12.1", "python3.12", "2.7"} {
+		if _, err := ParsePythonVersions(value); err == nil {
+			t.Fatalf("ParsePythonVersions(%q) should fail", value)
+		}
+	}
+	fmt.Println("✅ PYTHON_VERSIONS parsed")
+}
+
+// TestPythonVersionImage tests deriving each version's image from the base image
+func TestPythonVersionImage(t *testing.T) {
+	cases := map[string]string{
+		"python:3.14-slim":                                   "python:3.12-slim",
+		"python:3.14.2-slim-bookworm":                        "python:3.12-slim-bookworm",
+		"registry.corp.local:5000/python/python:3.14-alpine": "registry.corp.local:5000/python/python:3.12-alpine",
+		"python:3.14":                                        "python:3.12",
+	}
+	for base, want := range cases {
+		if got, err := PythonVersionImage(base, "3.12"); err != nil || got != want {
+			t.Fatalf("PythonVersionImage(%q) = %q, %v; want %q", base, got, err, want)
+		}
+	}
+	for _, base := range []string{"python:slim", "registry.corp.local:5000/python", "python:3.14-slim@sha256:" + strings.Repeat("a", 64)} {
+		if _, err := PythonVersionImage(base, "3.12"); err == nil {
+			t.Fatalf("PythonVersionImage(%q) should fail", base)
+		}
+	}
+	fmt.Println("✅ Python version swapped into the base image tag")
+}
+
+// TestMatrixVersions tests that the base image's version runs in the stages themselves
+func TestMatrixVersions(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", RunUnitTests: true, PythonVersions: []string{"3.12", "3.13", "3.14"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, run := range p.report.PythonVersions {
+		got = append(got, run.Version+"="+run.Image)
+	}
+	if strings.Join(got, " ") != "3.12=python:3.12-slim 3.13=python:3.13-slim" {
+		t.Fatalf("unexpected matrix %v", got)
+	}
+	if p.primaryPythonVersion() != "3.14" {
+		t.Fatalf("unexpected primary version %q", p.primaryPythonVersion())
+	}
+
+	for _, cfg := range []Config{
+		{RepoName: "cert-parser", GitUser: "org", RunUnitTests: true, BaseImage: "corp/python-base:latest", PythonVersions: []string{"3.12"}},
+		{RepoName: "cert-parser", GitUser: "org", PythonVersions: []string{"3.12"}},
+		{RepoName: "cert-parser", GitUser: "org", RunLint: true, PythonVersions: []string{"3.12.1"}},
+	} {
+		if _, err := New(cfg); CategoryOf(err) != CategoryConfig {
+			t.Fatalf("expected a config error for %+v, got %v", cfg, err)
+		}
+	}
+	fmt.Println("✅ Python version matrix derived from the base image")
+}
+
+// TestPythonVersionsMarkdown tests the job summary table of the matrix
+func TestPythonVersionsMarkdown(t *testing.T) {
+	md := PythonVersionsMarkdown([]PythonVersionRun{
+		{Version: "3.14", UnitTests: "passed", Lint: "passed", TypeCheck: "passed"},
+		{Version: "3.12", UnitTests: "failed"},
+	})
+	for _, want := range []string{"| 3.14 (image) | passed | passed | passed |", "| 3.12 | failed | not run | not run |"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("missing %q in:\n%s", want, md)
+		}
+	}
+	fmt.Println("✅ Python version results rendered for the job summary")
+}
@@ -0,0 +1,301 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TopPythonWarnings is how many distinct warnings the report and the job
+// summary list.
+const TopPythonWarnings = 10
+
+var (
+	// pythonWarningsHeader opens pytest's warnings summary section.
+	pythonWarningsHeader = regexp.MustCompile(`^=+ warnings summary( \(final\))? =+$`)
+	// pythonWarningLine is a warning as Python formats it:
+	// path:line: Category: message. Category is a *Warning class, possibly
+	// qualified, e.g. pytest.PytestUnraisableExceptionWarning.
+	pythonWarningLine = regexp.MustCompile(`^(\s*)(\S.*?):(\d+): ((?:[A-Za-z_][A-Za-z0-9_]*\.)*[A-Za-z_][A-Za-z0-9_]*Warning): (.*)$`)
+	// pythonWarningsCount is a summary header grouping the warnings of a
+	// whole file: "tests/test_x.py: 120 warnings".
+	pythonWarningsCount = regexp.MustCompile(`^(\S.*): (\d+) warnings?$`)
+)
+
+// PythonWarning is one distinct warning of the test stages, by category and
+// message, with how often it was emitted.
+type PythonWarning struct {
+	Category string   `json:"category"`
+	Message  string   `json:"message"`
+	Count    int      `json:"count"`
+	Location string   `json:"location,omitempty"` // path:line of the first occurrence
+	Stages   []string `json:"stages,omitempty"`
+}
+
+// PythonWarningsReport is what the test stages warned about: the total,
+// the count per category and the most frequent warnings, checked against
+// WarningsBudget and the categories of the branch's last accepted run.
+type PythonWarningsReport struct {
+	Total         int             `json:"total"`
+	Categories    map[string]int  `json:"categories"`
+	Top           []PythonWarning `json:"top,omitempty"` // the TopPythonWarnings most frequent
+	NewCategories []string        `json:"new_categories,omitempty"`
+	Budget        int             `json:"budget,omitempty"`
+	Problems      []string        `json:"problems,omitempty"`
+}
+
+// ParsePythonWarnings extracts the warnings of a pytest run: the
+// "warnings summary" section, where a warning follows the tests (or the
+// "file.py: N warnings" line) that emitted it, and Python's own
+// "path:line: Category: message" lines outside it, as printed with -W or
+// when pytest's warnings plugin is disabled. Warnings with the same
+// category and message are merged, most frequent first.
+func ParsePythonWarnings(output string) []PythonWarning {
+	var warnings []PythonWarning
+	add := func(m []string, count int) {
+		warnings = mergePythonWarnings(warnings, []PythonWarning{{
+			Category: m[4], Message: strings.TrimSpace(m[5]), Count: max(count, 1), Location: m[2] + ":" + m[3],
+		}})
+	}
+
+	inSummary, emitters, grouped := false, 0, false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if pythonWarningsHeader.MatchString(line) {
+			inSummary, emitters, grouped = true, 0, false
+			continue
+		}
+		if !inSummary {
+			// Unindented only: indented lines are source or traceback context
+			if m := pythonWarningLine.FindStringSubmatch(line); m != nil && m[1] == "" {
+				add(m, 1)
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "-- Docs:") || strings.HasPrefix(line, "==="):
+			inSummary = false
+		case strings.TrimSpace(line) == "":
+		case line[0] != ' ' && line[0] != '\t':
+			// A test id or location emitting the warnings that follow
+			if grouped {
+				emitters, grouped = 0, false
+			}
+			if m := pythonWarningsCount.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[2])
+				emitters += n
+			} else {
+				emitters++
+			}
+		default:
+			if m := pythonWarningLine.FindStringSubmatch(line); m != nil {
+				add(m, emitters)
+				grouped = true
+			}
+		}
+	}
+	return warnings
+}
+
+// mergePythonWarnings adds more to warnings, summing the counts of those
+// with the same category and message, and sorts them most frequent first.
+func mergePythonWarnings(warnings, more []PythonWarning) []PythonWarning {
+	for _, w := range more {
+		i := slices.IndexFunc(warnings, func(existing PythonWarning) bool {
+			return existing.Category == w.Category && existing.Message == w.Message
+		})
+		if i < 0 {
+			warnings = append(warnings, w)
+			continue
+		}
+		warnings[i].Count += w.Count
+		for _, stage := range w.Stages {
+			if !slices.Contains(warnings[i].Stages, stage) {
+				warnings[i].Stages = append(warnings[i].Stages, stage)
+			}
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Count > warnings[j].Count })
+	return warnings
+}
+
+// SummarizePythonWarnings totals warnings per category and keeps the
+// TopPythonWarnings most frequent.
+func SummarizePythonWarnings(warnings []PythonWarning) *PythonWarningsReport {
+	report := &PythonWarningsReport{Categories: map[string]int{}}
+	for _, w := range warnings {
+		report.Total += w.Count
+		report.Categories[w.Category] += w.Count
+	}
+	report.Top = warnings[:min(len(warnings), TopPythonWarnings)]
+	return report
+}
+
+// CheckPythonWarnings compares the warnings with the budget (0 for none)
+// and with the categories of the last accepted run (nil when there is
+// none), returning the categories new since then and the problems found.
+func CheckPythonWarnings(report *PythonWarningsReport, budget int, baseline []string) (newCategories, problems []string) {
+	if baseline != nil {
+		for category := range report.Categories {
+			if !slices.Contains(baseline, category) {
+				newCategories = append(newCategories, category)
+			}
+		}
+		sort.Strings(newCategories)
+	}
+	if budget > 0 && report.Total > budget {
+		problems = append(problems, fmt.Sprintf("%d Python warnings, over the budget of %d (WARNINGS_BUDGET)", report.Total, budget))
+	}
+	if budget > 0 && len(newCategories) > 0 {
+		problems = append(problems, fmt.Sprintf("new warning categories since the last accepted run: %s", strings.Join(newCategories, ", ")))
+	}
+	return newCategories, problems
+}
+
+// collectPythonWarnings adds the warnings of a unit, integration or
+// acceptance test run to those of the pipeline run.
+func (p *Pipeline) collectPythonWarnings(stage, output string) {
+	if stage != "unit" && stage != integrationMarker && stage != acceptanceMarker {
+		return
+	}
+	warnings := ParsePythonWarnings(output)
+	for i := range warnings {
+		warnings[i].Stages = []string{stage}
+	}
+	p.pythonWarnings = mergePythonWarnings(p.pythonWarnings, warnings)
+	p.warningsCollected = true
+}
+
+// pythonWarningsHistoryFile is where the warning categories of a branch's
+// last accepted run are kept between runs.
+func pythonWarningsHistoryFile(dir, branch string) string {
+	return filepath.Join(dir, "python-warnings-"+strings.NewReplacer("/", "_", "\\", "_").Replace(branch)+".json")
+}
+
+// pythonWarningsHistory is the stored warnings of a branch's last accepted
+// run.
+type pythonWarningsHistory struct {
+	Total      int      `json:"total"`
+	Categories []string `json:"categories"`
+	Commit     string   `json:"commit,omitempty"`
+}
+
+// checkPythonWarnings summarizes the warnings of the test stages once they
+// have run and checks them against WarningsBudget and the branch's history
+// in FreezeHistoryDir. Over the budget, or with categories the last
+// accepted run did not have, the run fails when WarningsBudget is set;
+// otherwise new categories are only a notice.
+func (p *Pipeline) checkPythonWarnings() error {
+	if !p.warningsCollected {
+		return nil
+	}
+	cfg := p.cfg
+	report := SummarizePythonWarnings(p.pythonWarnings)
+	report.Budget = cfg.WarningsBudget
+	p.report.PythonWarnings = report
+
+	var categories []string
+	for category := range report.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	p.printf("\n⚠️  Python warnings: %d", report.Total)
+	if report.Budget > 0 {
+		p.printf(" (budget %d)", report.Budget)
+	}
+	if len(categories) > 0 {
+		p.printf(" — %s", strings.Join(categories, ", "))
+	}
+	p.println()
+
+	history := ""
+	var baseline []string
+	if cfg.FreezeHistoryDir != "" {
+		history = pythonWarningsHistoryFile(cfg.FreezeHistoryDir, gitRefName(cfg))
+		var previous pythonWarningsHistory
+		data, err := os.ReadFile(history)
+		if err == nil {
+			err = json.Unmarshal(data, &previous)
+		}
+		switch {
+		case err == nil:
+			baseline = append([]string{}, previous.Categories...)
+		case errors.Is(err, os.ErrNotExist):
+			p.println("   ℹ️  No previous warnings for this branch — nothing to compare yet")
+		default:
+			p.warnf("python-warnings", "   ⚠️  Could not read warnings history: %v\n", err)
+		}
+	}
+
+	report.NewCategories, report.Problems = CheckPythonWarnings(report, report.Budget, baseline)
+	if len(report.NewCategories) > 0 && report.Budget == 0 {
+		p.printf("   ℹ️  New warning categories since the last accepted run: %s\n", strings.Join(report.NewCategories, ", "))
+	}
+	for _, problem := range report.Problems {
+		p.printf("   ❌ %s\n", problem)
+	}
+	if len(report.Problems) > 0 {
+		// Not recorded, so the next run still compares with the last accepted categories
+		return Errorf(CategoryTest, "Python warnings check failed: %s", report.Problems[0])
+	}
+
+	// A CHANGED_ONLY_TESTS run sees only part of the warnings
+	if history != "" && !p.changedOnly {
+		data, _ := json.Marshal(pythonWarningsHistory{Total: report.Total, Categories: categories, Commit: p.commit})
+		err := os.MkdirAll(filepath.Dir(history), 0o755)
+		if err == nil {
+			err = WriteFileAtomic(history, data)
+		}
+		if err != nil {
+			p.warnf("python-warnings", "   ⚠️  Could not record warnings history: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// printPythonWarnings writes the warnings of the test stages to the CI job
+// summary, including a run that stopped before they were checked.
+func (p *Pipeline) printPythonWarnings() {
+	if p.report.PythonWarnings == nil && p.warningsCollected {
+		p.report.PythonWarnings = SummarizePythonWarnings(p.pythonWarnings)
+	}
+	if p.report.PythonWarnings == nil || p.cfg.StepSummary == "" {
+		return
+	}
+	if err := AppendStepSummary(p.cfg.StepSummary, PythonWarningsMarkdown(p.report.PythonWarnings)); err != nil {
+		p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", err)
+	}
+}
+
+// PythonWarningsMarkdown renders the warnings report for a CI job summary.
+func PythonWarningsMarkdown(report *PythonWarningsReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Python warnings: %d\n\n", report.Total)
+	if report.Total == 0 {
+		b.WriteString("No warnings.\n")
+		return b.String()
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(&b, "❌ %s\n\n", problem)
+	}
+	b.WriteString("| Count | Category | Message | Stages |\n|---|---|---|---|\n")
+	for _, w := range report.Top {
+		message := strings.ReplaceAll(w.Message, "|", `\|`)
+		if len(message) > 120 {
+			message = message[:117] + "..."
+		}
+		category := w.Category
+		if slices.Contains(report.NewCategories, category) {
+			category += " (new)"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", w.Count, category, message, strings.Join(w.Stages, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+const samplePytestWarnings = `tests/unit/test_parser.py ....                                           [100%]
+
+=============================== warnings summary ===============================
+tests/unit/test_parser.py::test_parse_master_list
+tests/unit/test_parser.py::test_parse_empty
+  /app/src/cert_parser/parser.py:41: DeprecationWarning: datetime.datetime.utcnow() is deprecated
+    now = datetime.utcnow()
+
+tests/unit/test_cms.py: 12 warnings
+  /app/.venv/lib/python3.14/site-packages/asn1crypto/core.py:5: DeprecationWarning: datetime.datetime.utcnow() is deprecated
+    now = datetime.utcnow()
+
+tests/unit/test_cms.py::test_unsigned
+  /app/.venv/lib/python3.14/site-packages/_pytest/unraisableexception.py:85: pytest.PytestUnraisableExceptionWarning: Exception ignored in: <socket>
+    warnings.warn(pytest.PytestUnraisableExceptionWarning(msg))
+
+-- Docs: https://docs.pytest.org/en/stable/how-to/capture-warnings.html
+======================== 4 passed, 15 warnings in 0.42s ========================
+`
+
+// TestParsePythonWarnings tests the pytest warnings summary and Python's own warning lines
+func TestParsePythonWarnings(t *testing.T) {
+	warnings := ParsePythonWarnings(samplePytestWarnings)
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 distinct warnings, got %+v", warnings)
+	}
+	if w := warnings[0]; w.Category != "DeprecationWarning" || w.Count != 14 || w.Location != "/app/src/cert_parser/parser.py:41" {
+		t.Fatalf("unexpected first warning %+v", w)
+	}
+	if w := warnings[1]; w.Category != "pytest.PytestUnraisableExceptionWarning" || w.Count != 1 {
+		t.Fatalf("unexpected second warning %+v", w)
+	}
+
+	// -W default, or pytest with -p no:warnings: Python prints each warning itself
+	verbose := "/app/src/cert_parser/parser.py:41: DeprecationWarning: datetime.datetime.utcnow() is deprecated\n" +
+		"  now = datetime.utcnow()\n" +
+		"/app/src/cert_parser/store.py:9: ResourceWarning: unclosed file <_io.BufferedReader name='ml.bin'>\r\n" +
+		"  File \"/app/tests/x.py\", line 3, in <module>: UserWarning: not a warning line\n" +
+		"4 passed in 0.40s\n"
+	warnings = ParsePythonWarnings(verbose)
+	if len(warnings) != 2 || warnings[1].Category != "ResourceWarning" || warnings[1].Message != "unclosed file <_io.BufferedReader name='ml.bin'>" {
+		t.Fatalf("unexpected verbose warnings %+v", warnings)
+	}
+	if ParsePythonWarnings("4 passed in 0.40s\n") != nil {
+		t.Fatal("expected no warnings")
+	}
+	fmt.Println("✅ Python warnings parsed from both formats")
+}
+
+// TestSummarizePythonWarnings tests the totals per category and the budget check
+func TestSummarizePythonWarnings(t *testing.T) {
+	report := SummarizePythonWarnings(ParsePythonWarnings(samplePytestWarnings))
+	if report.Total != 15 || report.Categories["DeprecationWarning"] != 14 || len(report.Top) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	newCategories, problems := CheckPythonWarnings(report, 0, []string{"DeprecationWarning"})
+	if strings.Join(newCategories, ",") != "pytest.PytestUnraisableExceptionWarning" || problems != nil {
+		t.Fatalf("without a budget new categories are not problems: %v, %v", newCategories, problems)
+	}
+	if _, problems := CheckPythonWarnings(report, 20, nil); problems != nil {
+		t.Fatalf("no history and within budget: %v", problems)
+	}
+	if _, problems := CheckPythonWarnings(report, 10, []string{"DeprecationWarning"}); len(problems) != 2 || !strings.Contains(problems[0], "over the budget of 10") {
+		t.Fatalf("expected the budget and the new category to fail, got %v", problems)
+	}
+
+	report.NewCategories = newCategories
+	md := PythonWarningsMarkdown(report)
+	for _, want := range []string{"### Python warnings: 15", "| 14 | DeprecationWarning | datetime.datetime.utcnow() is deprecated |", "PytestUnraisableExceptionWarning (new)"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("missing %q in:\n%s", want, md)
+		}
+	}
+	fmt.Println("✅ Python warnings totalled and checked against the budget")
+}
+
+// TestCheckPythonWarningsHistory tests that new categories fail against the branch's last accepted run
+func TestCheckPythonWarningsHistory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{RepoName: "cert-parser", GitUser: "org", GitBranch: "feature/x", FreezeHistoryDir: dir, WarningsBudget: 100, Output: &strings.Builder{}}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.collectPythonWarnings("unit", "/app/a.py:1: DeprecationWarning: old\n")
+	if err := p.checkPythonWarnings(); err != nil {
+		t.Fatalf("first run has nothing to compare with: %v", err)
+	}
+	if _, err := os.Stat(pythonWarningsHistoryFile(dir, "feature/x")); err != nil {
+		t.Fatalf("expected the warnings history to be recorded: %v", err)
+	}
+
+	p, _ = New(cfg)
+	p.collectPythonWarnings(integrationMarker, "/app/a.py:1: DeprecationWarning: old\n/app/b.py:2: RuntimeWarning: coroutine never awaited\n")
+	if err := p.checkPythonWarnings(); CategoryOf(err) != CategoryTest || !strings.Contains(err.Error(), "RuntimeWarning") {
+		t.Fatalf("expected the new category to fail the run, got %v", err)
+	}
+	if p.report.PythonWarnings.Total != 2 || p.report.PythonWarnings.Top[0].Stages[0] != integrationMarker {
+		t.Fatalf("unexpected report %+v", p.report.PythonWarnings)
+	}
+
+	// The failed run was not recorded: the same warnings fail again
+	p, _ = New(cfg)
+	p.collectPythonWarnings("unit", "/app/b.py:2: RuntimeWarning: coroutine never awaited\n")
+	if err := p.checkPythonWarnings(); err == nil {
+		t.Fatal("expected the new category to fail again")
+	}
+	fmt.Println("✅ New warning categories checked against the branch history")
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CommitCheck modes (COMMIT_CHECK).
+const (
+	CommitCheckWarn = "warn"
+	CommitCheckFail = "fail"
+	CommitCheckOff  = "off"
+)
+
+// Outcomes of the commit reachability check.
+const (
+	CommitReachable   = "reachable"
+	CommitUnreachable = "unreachable"
+	CommitUnchecked   = "unchecked"
+)
+
+// ParseCommitCheck parses COMMIT_CHECK; empty is CommitCheckWarn.
+func ParseCommitCheck(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "":
+		return CommitCheckWarn, nil
+	case CommitCheckWarn, CommitCheckFail, CommitCheckOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown commit check %q (use %s, %s or %s)", value, CommitCheckWarn, CommitCheckFail, CommitCheckOff)
+}
+
+// CommitReachability records whether the built commit is on the branch it
+// was requested from, after the clone and again before publishing.
+type CommitReachability struct {
+	Mode        string `json:"mode"`
+	Branch      string `json:"branch"`
+	Commit      string `json:"commit"`
+	BranchHead  string `json:"branch_head,omitempty"`  // the branch's head after the clone
+	PublishHead string `json:"publish_head,omitempty"` // the branch's head before publishing
+	Outcome     string `json:"outcome"`                // CommitReachable, CommitUnreachable or CommitUnchecked
+	Detail      string `json:"detail,omitempty"`
+}
+
+// BranchContains asks the GitHub compare API whether commit is the head of
+// branch or one of its ancestors, and returns the branch's head.
+func BranchContains(ctx context.Context, client *http.Client, apiBase, owner, repo, token, branch, commit string) (head string, contains bool, err error) {
+	compareURL := fmt.Sprintf("%s/repos/%s/%s/compare/%s...%s", strings.TrimSuffix(apiBase, "/"),
+		url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(branch), url.PathEscape(commit))
+	var compare struct {
+		Status     string `json:"status"` // identical, behind, ahead or diverged
+		BaseCommit struct {
+			SHA string `json:"sha"`
+		} `json:"base_commit"`
+	}
+	if err := getGitHubJSON(ctx, client, compareURL, token, &compare); err != nil {
+		return "", false, err
+	}
+	if compare.BaseCommit.SHA == "" {
+		return "", false, fmt.Errorf("GET %s: no base_commit in the response", compareURL)
+	}
+	return compare.BaseCommit.SHA, compare.Status == "identical" || compare.Status == "behind", nil
+}
+
+// checkCommitReachable checks that commit is on GitBranch, so an image
+// never claims a commit the branch does not have, e.g. from a stale cached
+// ref or a force push. It runs after the clone and, with beforePublish,
+// again before publishing, for branches moved during a long run. A commit
+// off the branch warns, or with CommitCheckFail stops the run; API errors
+// and hosts without the GitHub API only print a note. Commit and tag builds
+// have no branch to check, and local sources are not on the Git host.
+func (p *Pipeline) checkCommitReachable(ctx context.Context, commit string, beforePublish bool) error {
+	cfg := p.cfg
+	if cfg.CommitCheck == CommitCheckOff || IsCommitSHA(cfg.GitBranch) || cfg.GitTag != "" || cfg.LocalSource != "" || commit == "" {
+		return nil
+	}
+	check := p.report.CommitReachability
+	if check == nil {
+		check = &CommitReachability{Mode: cfg.CommitCheck, Branch: cfg.GitBranch, Commit: commit}
+		p.report.CommitReachability = check
+	}
+	if strings.Contains(cfg.GitHost, "gitlab") {
+		check.Outcome, check.Detail = CommitUnchecked, "needs the GitHub compare API"
+		if !beforePublish {
+			p.println("   ℹ️  Commit not checked against the branch (needs the GitHub compare API)")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, branchLagTimeout)
+	defer cancel()
+	head, contains, err := BranchContains(ctx, cfg.HTTPClient, GitHubAPIBase(cfg.GitHost), cfg.GitUser, cfg.RepoName, cfg.GitToken, cfg.GitBranch, commit)
+	if err != nil {
+		check.Outcome, check.Detail = CommitUnchecked, err.Error()
+		p.warnf("commit-check", "   ⚠️  Could not check that %s is on %s: %v\n", shortCommit(commit), cfg.GitBranch, err)
+		return nil
+	}
+	when := "after the clone"
+	if beforePublish {
+		check.PublishHead = head
+		when = "before publishing"
+	} else {
+		check.BranchHead = head
+	}
+	if contains {
+		check.Outcome = CommitReachable
+		if beforePublish && head != check.BranchHead {
+			p.printf("   ℹ️  %s moved to %s during the run; %s is still on it\n", cfg.GitBranch, shortCommit(head), shortCommit(commit))
+		}
+		return nil
+	}
+
+	check.Outcome = CommitUnreachable
+	check.Detail = fmt.Sprintf("%s is not on %s (head %s) %s", commit, cfg.GitBranch, head, when)
+	p.warnf("commit-unreachable", "\n⚠️  The built commit %s is not on %s, whose head is %s %s (COMMIT_CHECK=%s)\n"+
+		"   The image would claim a commit the branch does not have: a stale cached ref or a force push. Re-run to build the current head.\n",
+		shortCommit(commit), cfg.GitBranch, shortCommit(head), when, cfg.CommitCheck)
+	if cfg.CommitCheck != CommitCheckFail {
+		return nil
+	}
+	if beforePublish {
+		return Errorf(CategoryPublish, "commit %s is no longer on branch %s (head %s) (COMMIT_CHECK=fail)", shortCommit(commit), cfg.GitBranch, shortCommit(head))
+	}
+	return Errorf(CategoryClone, "commit %s is not on branch %s (head %s) (COMMIT_CHECK=fail)", shortCommit(commit), cfg.GitBranch, shortCommit(head))
+}
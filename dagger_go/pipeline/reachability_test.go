@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// githubBranchServer serves the compare endpoint of a GitHub Enterprise API
+// for org/cert-parser: compare/<branch>...<commit> answers from status, a
+// map of "<branch>...<commit>" to the compare status, with head as the
+// branch's head.
+func githubBranchServer(t *testing.T, head *string, status map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/org/cert-parser/compare/", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := status[strings.TrimPrefix(r.URL.Path, "/api/v3/repos/org/cert-parser/compare/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"status": %q, "base_commit": {"sha": %q}}`, s, *head)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestBranchContains tests reading whether a commit is on a branch from the compare status
+func TestBranchContains(t *testing.T) {
+	head := "ffff"
+	server := githubBranchServer(t, &head, map[string]string{
+		"main...abc": "behind", "main...ffff": "identical", "main...def": "diverged", "main...123": "ahead",
+	})
+	for commit, want := range map[string]bool{"abc": true, "ffff": true, "def": false, "123": false} {
+		got, contains, err := BranchContains(context.Background(), server.Client(), server.URL+"/api/v3", "org", "cert-parser", "", "main", commit)
+		if err != nil || got != "ffff" || contains != want {
+			t.Fatalf("%s: got %q, %v, %v", commit, got, contains, err)
+		}
+	}
+	if _, _, err := BranchContains(context.Background(), server.Client(), server.URL+"/api/v3", "org", "cert-parser", "", "main", "missing"); err == nil {
+		t.Fatal("expected the API error")
+	}
+	fmt.Println("✅ Commit ancestry read from the compare API")
+}
+
+// TestCheckCommitReachable tests the check after the clone and before publishing
+func TestCheckCommitReachable(t *testing.T) {
+	head := "1111111111111111111111111111111111111111"
+	server := githubBranchServer(t, &head, map[string]string{"main...abc123": "behind", "main...bad456": "diverged"})
+	host := strings.TrimPrefix(server.URL, "https://")
+	newPipeline := func(mode, branch string) (*Pipeline, *bytes.Buffer) {
+		var out bytes.Buffer
+		p, err := New(Config{RepoName: "cert-parser", GitUser: "org", GitHost: host, GitBranch: branch, CommitCheck: mode,
+			HTTPClient: server.Client(), Output: &out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p, &out
+	}
+
+	p, out := newPipeline("", "main")
+	if err := p.checkCommitReachable(context.Background(), "abc123", false); err != nil {
+		t.Fatal(err)
+	}
+	head = "2222222222222222222222222222222222222222"
+	if err := p.checkCommitReachable(context.Background(), "abc123", true); err != nil {
+		t.Fatal(err)
+	}
+	check := p.report.CommitReachability
+	if check.Mode != CommitCheckWarn || check.Outcome != CommitReachable || check.BranchHead[:1] != "1" || check.PublishHead[:1] != "2" {
+		t.Fatalf("unexpected record %+v", check)
+	}
+	if !strings.Contains(out.String(), "main moved to 222222222222 during the run") {
+		t.Fatalf("the moved branch should be noted: %q", out.String())
+	}
+
+	p, out = newPipeline(CommitCheckWarn, "main")
+	if err := p.checkCommitReachable(context.Background(), "bad456", false); err != nil {
+		t.Fatalf("warn should not fail: %v", err)
+	}
+	if p.report.CommitReachability.Outcome != CommitUnreachable || !strings.Contains(out.String(), "not on main") {
+		t.Fatalf("unexpected record %+v, output %q", p.report.CommitReachability, out.String())
+	}
+	p, _ = newPipeline(CommitCheckFail, "main")
+	if err := p.checkCommitReachable(context.Background(), "bad456", false); CategoryOf(err) != CategoryClone {
+		t.Fatalf("expected a clone error, got %v", err)
+	}
+	if err := p.checkCommitReachable(context.Background(), "bad456", true); CategoryOf(err) != CategoryPublish {
+		t.Fatalf("expected a publish error before publishing, got %v", err)
+	}
+
+	p, _ = newPipeline(CommitCheckFail, "feature/x")
+	if err := p.checkCommitReachable(context.Background(), "abc123", false); err != nil || p.report.CommitReachability.Outcome != CommitUnchecked {
+		t.Fatalf("API errors should only warn: %v, %+v", err, p.report.CommitReachability)
+	}
+	p, _ = newPipeline(CommitCheckFail, head)
+	if err := p.checkCommitReachable(context.Background(), "bad456", false); err != nil || p.report.CommitReachability != nil {
+		t.Fatalf("commit builds have no branch to check: %v", err)
+	}
+	p, _ = newPipeline(CommitCheckOff, "main")
+	if err := p.checkCommitReachable(context.Background(), "bad456", false); err != nil || p.report.CommitReachability != nil {
+		t.Fatalf("off should not check: %v", err)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", CommitCheck: "strict"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	fmt.Println("✅ Built commit checked against the branch after the clone and before publishing")
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ServiceReadyTCP is the SERVICE_READY_URL that waits for the image's port
+// to accept connections, the default.
+const ServiceReadyTCP = "tcp"
+
+// DefaultServiceReadyInterval is the pause between readiness probes.
+const DefaultServiceReadyInterval = 500 * time.Millisecond
+
+// serviceReadyLogLines is how much of the image's output a failed readiness
+// gate prints.
+const serviceReadyLogLines = 40
+
+// ServiceReadiness is how long the image the acceptance tests call took to
+// become ready, so a slow startup is told apart from slow tests.
+type ServiceReadiness struct {
+	Probe           string  `json:"probe"` // tcp <address> or the URL polled
+	Ready           bool    `json:"ready"`
+	Attempts        int     `json:"attempts"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	LastError       string  `json:"last_error,omitempty"`
+}
+
+// ValidateServiceReadyURL checks SERVICE_READY_URL: ServiceReadyTCP, a path
+// on the started image such as /healthz, or an http(s) URL.
+func ValidateServiceReadyURL(value string) error {
+	if value == "" || value == ServiceReadyTCP || strings.HasPrefix(value, "/") {
+		return nil
+	}
+	if u, err := url.Parse(value); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return nil
+	}
+	return fmt.Errorf("%q is not %s, a path such as /healthz or an http(s) URL", value, ServiceReadyTCP)
+}
+
+// PollReady runs probe every interval until it succeeds, returning how many
+// probes ran. After timeout, the last probe's error is returned.
+func PollReady(ctx context.Context, probe func(context.Context) error, interval, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for attempts := 1; ; attempts++ {
+		err := probe(ctx)
+		if err == nil {
+			return attempts, nil
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return attempts, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// readinessProbe is the probe of ServiceReadyURL for the image serving on
+// baseURL, and how it is described. A path or URL is ready on a 2xx or 3xx
+// answer; the started image is on this machine, so it is called without
+// the proxy.
+func (p *Pipeline) readinessProbe(baseURL string) (func(context.Context) error, string) {
+	ready := p.cfg.ServiceReadyURL
+	if ready == ServiceReadyTCP {
+		address := baseURL
+		if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+			address = u.Host
+		}
+		return func(ctx context.Context) error {
+			conn, err := (&net.Dialer{Timeout: time.Second}).DialContext(ctx, "tcp", address)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}, "tcp " + address
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	if strings.HasPrefix(ready, "/") {
+		ready = strings.TrimSuffix(baseURL, "/") + ready
+	} else {
+		client = p.cfg.HTTPClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ready, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("GET %s: %s", ready, resp.Status)
+		}
+		return nil
+	}, ready
+}
+
+// waitUntilReady holds the acceptance tests back until the image serving on
+// baseURL passes the readiness probe, records the wait in the report and,
+// when the image never gets ready, prints its latest output.
+func (p *Pipeline) waitUntilReady(ctx context.Context, baseURL string) error {
+	cfg := p.cfg
+	probe, describe := p.readinessProbe(baseURL)
+	p.printf("   ⏳ Waiting for the image: %s (every %s, up to %s)\n", describe, cfg.ServiceReadyPoll, cfg.ServiceReadyTimeout)
+	start := time.Now()
+	attempts, err := PollReady(ctx, probe, cfg.ServiceReadyPoll, cfg.ServiceReadyTimeout)
+	readiness := &ServiceReadiness{Probe: describe, Ready: err == nil, Attempts: attempts, DurationSeconds: time.Since(start).Seconds()}
+	p.report.ServiceReady = readiness
+	if err == nil {
+		p.printf("   ⏱️  Ready after %.1fs (%d probe(s)) — startup, not test time\n", readiness.DurationSeconds, attempts)
+		return nil
+	}
+	readiness.LastError = err.Error()
+	p.printf("   ❌ Not ready after %.1fs (%d probe(s)): %v\n", readiness.DurationSeconds, attempts, err)
+	p.printImageLogs(ctx)
+	return Errorf(CategoryBuild, "the image was not ready within %s (SERVICE_READY_TIMEOUT): %s: %w", cfg.ServiceReadyTimeout, describe, err)
+}
+
+// printImageLogs prints the last lines the acceptance image wrote. A Dagger
+// service has none to read; stopAcceptanceImage points at the trace.
+func (p *Pipeline) printImageLogs(ctx context.Context) {
+	target := p.acceptance
+	if target == nil || target.container == "" {
+		return
+	}
+	logs, err := exec.CommandContext(context.WithoutCancel(ctx), "docker", "logs", "--tail", fmt.Sprint(serviceReadyLogLines), target.container).CombinedOutput()
+	if err != nil {
+		p.warnf("image-logs", "   ⚠️  Could not read the image logs: %v\n", err)
+		return
+	}
+	p.printf("   Last %d lines of the image's output:\n%s\n", serviceReadyLogLines, indentLines(strings.TrimRight(string(logs), "\n"), "   │ "))
+}
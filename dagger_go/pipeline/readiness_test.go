@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestValidateServiceReadyURL tests the accepted SERVICE_READY_URL forms
+func TestValidateServiceReadyURL(t *testing.T) {
+	for _, value := range []string{"", "tcp", "/healthz", "http://localhost:8000/ready", "https://svc.corp.local/health"} {
+		if err := ValidateServiceReadyURL(value); err != nil {
+			t.Fatalf("ValidateServiceReadyURL(%q): %v", value, err)
+		}
+	}
+	for _, value := range []string{"healthz", "udp", "ftp://host/x", "http://"} {
+		if err := ValidateServiceReadyURL(value); err == nil {
+			t.Fatalf("ValidateServiceReadyURL(%q) should fail", value)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", ServiceReadyURL: "healthz"}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	fmt.Println("✅ SERVICE_READY_URL validated")
+}
+
+// TestPollReady tests polling until the probe succeeds or the timeout passes
+func TestPollReady(t *testing.T) {
+	calls := 0
+	attempts, err := PollReady(context.Background(), func(context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, time.Millisecond, time.Second)
+	if err != nil || attempts != 3 {
+		t.Fatalf("got %d attempts, %v", attempts, err)
+	}
+
+	attempts, err = PollReady(context.Background(), func(context.Context) error {
+		return errors.New("connection refused")
+	}, 10*time.Millisecond, 50*time.Millisecond)
+	if err == nil || err.Error() != "connection refused" || attempts < 2 || attempts > 6 {
+		t.Fatalf("expected the last probe error after a few attempts, got %d, %v", attempts, err)
+	}
+	fmt.Println("✅ Readiness polled until ready or timed out")
+}
+
+// TestWaitUntilReady tests the HTTP and TCP probes against a started service
+func TestWaitUntilReady(t *testing.T) {
+	var ready atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	out := &strings.Builder{}
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", ServiceReadyURL: "/healthz", ServiceReadyPoll: 5 * time.Millisecond, ServiceReadyTimeout: 50 * time.Millisecond, Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.waitUntilReady(context.Background(), server.URL)
+	if CategoryOf(err) != CategoryBuild || !strings.Contains(err.Error(), "503") || p.report.ServiceReady.Ready {
+		t.Fatalf("expected the unready service to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Not ready after") {
+		t.Fatalf("expected the failed wait to be printed, got:\n%s", out)
+	}
+
+	ready.Store(true)
+	if err := p.waitUntilReady(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if r := p.report.ServiceReady; !r.Ready || r.Attempts != 1 || r.Probe != server.URL+"/healthz" {
+		t.Fatalf("unexpected readiness %+v", r)
+	}
+
+	p.cfg.ServiceReadyURL = ServiceReadyTCP
+	if err := p.waitUntilReady(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if probe := p.report.ServiceReady.Probe; probe != "tcp "+server.Listener.Addr().String() {
+		t.Fatalf("unexpected probe %q", probe)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + listener.Addr().String()
+	listener.Close()
+	if err := p.waitUntilReady(context.Background(), closed); err == nil {
+		t.Fatal("expected a closed port to fail")
+	}
+	fmt.Println("✅ Acceptance image readiness gate checked")
+}
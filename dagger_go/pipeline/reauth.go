@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// TokenRefreshFunc returns current registry credentials. The publish stage
+// calls it once when the registry rejects the token obtained at the start
+// of a long run.
+type TokenRefreshFunc func(ctx context.Context) (string, error)
+
+// registryAuthErrorPattern matches the registry responses for missing,
+// expired or revoked credentials. 403 "denied" is a permission problem that
+// new credentials of the same identity would not fix, so it is not retried.
+var registryAuthErrorPattern = regexp.MustCompile(`(?i)\b401\b|unauthorized|authentication required`)
+
+// isRegistryAuthError reports whether a publish failed on authentication.
+func isRegistryAuthError(err error) bool {
+	return err != nil && registryAuthErrorPattern.MatchString(err.Error())
+}
+
+// RegistryTokenFromEnv re-reads the registry token the way the binaries
+// first read it: CR_PAT_COMMAND (e.g. a script minting a GitHub App
+// installation token, or aws ecr get-login-password) prints a fresh token,
+// REGISTRY_PASSWORD_FILE or CR_PAT_FILE is read again (for tokens rotated
+// by an external agent), and otherwise REGISTRY_PASSWORD or CR_PAT is used.
+func RegistryTokenFromEnv(getenv func(string) string) TokenRefreshFunc {
+	return func(ctx context.Context) (string, error) {
+		if command := getenv("CR_PAT_COMMAND"); command != "" {
+			out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+			if err != nil {
+				return "", fmt.Errorf("CR_PAT_COMMAND failed: %w", err)
+			}
+			return strings.TrimSpace(string(out)), nil
+		}
+		if password, err := RegistryPasswordFromEnv(getenv); password != "" || err != nil {
+			return password, err
+		}
+		if path := getenv("CR_PAT_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("read CR_PAT_FILE: %w", err)
+			}
+			return strings.TrimSpace(string(data)), nil
+		}
+		return getenv("CR_PAT"), nil
+	}
+}
+
+// publishWithReauth publishes image, and when the registry rejects the
+// credentials, refreshes them with RenewRegistryToken and retries once.
+// Retrying with an unchanged token is pointless, so that fails straight away.
+func (p *Pipeline) publishWithReauth(ctx context.Context, client *dagger.Client, image *dagger.Container, address string, variants ...*dagger.Container) (string, error) {
+	published, err := p.PublishImage(ctx, client, image, address, variants...)
+	if !isRegistryAuthError(err) || p.cfg.RenewRegistryToken == nil {
+		return published, err
+	}
+
+	p.println("   🔑 Registry rejected the credentials (401) — re-authenticating")
+	token, rerr := p.cfg.RenewRegistryToken(ctx)
+	switch {
+	case rerr != nil:
+		return "", fmt.Errorf("%w (re-authentication failed: %v)", err, rerr)
+	case token == "" || token == p.registryToken:
+		return "", fmt.Errorf("%w (re-authentication returned the same token; not retrying)", err)
+	}
+	p.registryToken = token
+	p.report.RegistryReauths++
+	p.printf("   🔁 Retrying publish of %s with refreshed credentials\n", address)
+	return p.PublishImage(ctx, client, image, address, variants...)
+}
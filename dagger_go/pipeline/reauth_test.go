@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestIsRegistryAuthError tests which publish failures trigger re-authentication
+func TestIsRegistryAuthError(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"failed to push: 401 Unauthorized":                                           true,
+		"unexpected status from HEAD request: UNAUTHORIZED: authentication required": true,
+		"denied: permission_denied: write_package":                                   false,
+		"dial tcp: i/o timeout":                                                      false,
+	} {
+		if got := isRegistryAuthError(errors.New(msg)); got != want {
+			t.Fatalf("isRegistryAuthError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	if isRegistryAuthError(nil) {
+		t.Fatal("nil is not an auth error")
+	}
+	fmt.Println("✅ Registry authentication failures detected")
+}
+
+// TestRegistryTokenFromEnv tests the token sources used to re-authenticate
+func TestRegistryTokenFromEnv(t *testing.T) {
+	ctx := context.Background()
+	env := map[string]string{"CR_PAT": "ghp_start"}
+	refresh := RegistryTokenFromEnv(func(k string) string { return env[k] })
+
+	if token, err := refresh(ctx); err != nil || token != "ghp_start" {
+		t.Fatalf("CR_PAT fallback: %q %v", token, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("ghs_rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env["CR_PAT_FILE"] = file
+	if token, err := refresh(ctx); err != nil || token != "ghs_rotated" {
+		t.Fatalf("CR_PAT_FILE: %q %v", token, err)
+	}
+
+	env["CR_PAT_COMMAND"] = "echo ghs_minted"
+	if token, err := refresh(ctx); err != nil || token != "ghs_minted" {
+		t.Fatalf("CR_PAT_COMMAND: %q %v", token, err)
+	}
+	env["CR_PAT_COMMAND"] = "exit 3"
+	if _, err := refresh(ctx); err == nil {
+		t.Fatal("failing CR_PAT_COMMAND should be an error")
+	}
+	fmt.Println("✅ Registry token re-read from command, file or CR_PAT")
+}
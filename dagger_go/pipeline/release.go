@@ -0,0 +1,302 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// DefaultReleaseAuthor is the author and committer of the release commit
+// and tag without ReleaseAuthor.
+const DefaultReleaseAuthor = "Release Pipeline <release-pipeline@users.noreply.github.com>"
+
+// releaseVersionPattern is a release version: MAJOR.MINOR.PATCH without
+// leading zeros.
+var releaseVersionPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// ReleaseReport records a release run: the version written to
+// pyproject.toml and the tag the image was built from.
+type ReleaseReport struct {
+	Version         string `json:"version"`
+	Tag             string `json:"tag"`
+	Branch          string `json:"branch"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	Commit          string `json:"commit,omitempty"` // the tagged commit; empty in a dry run
+	DryRun          bool   `json:"dry_run,omitempty"`
+}
+
+// ParseReleaseVersion validates RELEASE_VERSION, x.y.z; a leading v, as in
+// the tag, is dropped.
+func ParseReleaseVersion(value string) (string, error) {
+	version := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if !releaseVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("%q is not a release version (use MAJOR.MINOR.PATCH, e.g. 1.4.0)", value)
+	}
+	return version, nil
+}
+
+// ReleaseTag is the git tag of a release version: v1.4.0.
+func ReleaseTag(version string) string {
+	return "v" + version
+}
+
+// DefaultVersionTag prefixes the image tag when pyproject.toml has no
+// static version.
+const DefaultVersionTag = "v0.0.0"
+
+// VersionTag is the image tag prefix of a project version: v1.4.0. The +
+// of a PEP 440 local version, which tags cannot hold, becomes -.
+func VersionTag(version string) (string, error) {
+	tag := "v" + strings.ReplaceAll(strings.TrimPrefix(version, "v"), "+", "-")
+	if !imageTagPattern.MatchString(tag) {
+		return "", fmt.Errorf("version %q cannot be part of an image tag", version)
+	}
+	return tag, nil
+}
+
+// versionTag is the image tag prefix of the checkout's project version,
+// DefaultVersionTag with a warning when pyproject.toml has no static
+// version to use.
+func (p *Pipeline) versionTag(info PyprojectInfo) string {
+	var reason string
+	switch {
+	case info.DynamicVersion:
+		reason = "the version is dynamic (set by the build backend)"
+	case info.Version == "":
+		reason = "pyproject.toml has no version in [project] or [tool.poetry]"
+	default:
+		tag, err := VersionTag(info.Version)
+		if err == nil {
+			return tag
+		}
+		reason = err.Error()
+	}
+	p.warnf("project-version", "   ⚠️  Image tag starts with %s: %s\n", DefaultVersionTag, reason)
+	return DefaultVersionTag
+}
+
+// SetPyprojectVersion sets the version in [project] and, when it has one,
+// [tool.poetry] of pyproject.toml content, changing nothing but the text
+// between the quotes: comments, quote style, spacing, line endings and a
+// byte order mark are kept. It returns the new content and the version it
+// replaced. A file without a static version, such as one with
+// dynamic = ["version"], is an error: the build backend would ignore it.
+func SetPyprojectVersion(raw, version string) (string, string, error) {
+	base := 0
+	if strings.HasPrefix(raw, utf8BOM) {
+		base = len(utf8BOM)
+	}
+	lines, _ := splitPyprojectLines(raw[base:], base)
+
+	type edit struct{ start, end int }
+	var edits []edit
+	previous, table, multiline, dynamic := "", "", "", false
+	for _, line := range lines {
+		text := strings.TrimSpace(line.text)
+		if multiline != "" {
+			if strings.Contains(text, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			table = tableName(text)
+			continue
+		}
+		key, value, ok := strings.Cut(line.text, "=")
+		if !ok {
+			continue
+		}
+		for _, quotes := range []string{`"""`, "'''"} {
+			if strings.Count(value, quotes)%2 == 1 {
+				multiline = quotes
+			}
+		}
+		keyPath := tomlKeyPath(table, key)
+		if keyPath == "project.dynamic" && strings.Contains(value, "version") {
+			dynamic = true
+		}
+		tableOf, found := strings.CutSuffix(keyPath, ".version")
+		if !found || !slices.Contains(projectNameTables, tableOf) {
+			continue
+		}
+		old, err := tomlSingleLineString(value)
+		if err != nil {
+			return "", "", &PyprojectError{Line: line.number, Column: len(key) + 2, Offset: line.offset + len(key) + 1,
+				Msg: fmt.Sprintf("[%s] version %v", tableOf, err)}
+		}
+		if previous == "" {
+			previous = old
+		}
+		// The opening quote is the first one after the =
+		start := line.offset + len(key) + 1 + strings.IndexAny(value, `"'`) + 1
+		edits = append(edits, edit{start, start + len(old)})
+	}
+	if len(edits) == 0 {
+		if dynamic {
+			return "", "", fmt.Errorf("pyproject.toml declares the version dynamic (dynamic = [\"version\"]), so the build backend sets it — remove it from dynamic and add version = \"...\" to [project] to release with RELEASE_VERSION")
+		}
+		return "", "", fmt.Errorf("pyproject.toml has no version in [project] or [tool.poetry] — add version = \"...\" to release with RELEASE_VERSION")
+	}
+	var b strings.Builder
+	last := 0
+	for _, e := range edits {
+		b.WriteString(raw[last:e.start])
+		b.WriteString(version)
+		last = e.end
+	}
+	b.WriteString(raw[last:])
+	return b.String(), previous, nil
+}
+
+// validateRelease checks the release settings of cfg, after the GitBranch
+// and GitRepo defaults were applied.
+func validateRelease(cfg Config) error {
+	if cfg.ReleaseVersion == "" {
+		if cfg.ReleaseDryRun {
+			return fmt.Errorf("ReleaseDryRun requires ReleaseVersion")
+		}
+		return nil
+	}
+	if cfg.GitTag != "" {
+		return fmt.Errorf("ReleaseVersion releases a branch and creates its tag, not tag %s: set GitBranch to the default branch", cfg.GitTag)
+	}
+	if IsCommitSHA(cfg.GitBranch) {
+		return fmt.Errorf("ReleaseVersion releases a branch, not commit %s: set GitBranch to the default branch", cfg.GitBranch)
+	}
+	if cfg.PullRequest != nil {
+		return fmt.Errorf("ReleaseVersion cannot run in a pull request build (#%d)", cfg.PullRequest.Number)
+	}
+	if u, err := url.Parse(cfg.GitRepo); err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("ReleaseVersion pushes over HTTPS with GitToken, but GitRepo %s is not an https:// URL", cfg.GitRepo)
+	}
+	if cfg.GitToken == "" && !cfg.ReleaseDryRun {
+		return fmt.Errorf("ReleaseVersion requires GitToken to push the release commit and tag")
+	}
+	if err := validGitCredentialUser(cfg.GitAuthUser); err != nil {
+		return fmt.Errorf("ReleaseVersion pushes as GitAuthUser: %w", err)
+	}
+	if _, err := mail.ParseAddress(cfg.ReleaseAuthor); err != nil {
+		return fmt.Errorf("invalid ReleaseAuthor %q (use \"Name <email>\"): %v", cfg.ReleaseAuthor, err)
+	}
+	return nil
+}
+
+// release bumps the version in pyproject.toml to ReleaseVersion, commits it
+// to the branch and pushes it with the tag vX.Y.Z, and returns the tag to
+// build from, so the image, the package and the tag all carry the version.
+// It only releases from the default branch and never moves an existing tag.
+// A dry run checks everything and shows the change, then returns nil.
+func (p *Pipeline) release(ctx context.Context, client *dagger.Client, repo *dagger.GitRepository, ref *dagger.GitRef) (*dagger.GitRef, error) {
+	cfg := p.cfg
+	tag := ReleaseTag(cfg.ReleaseVersion)
+	record := &ReleaseReport{Version: cfg.ReleaseVersion, Tag: tag, Branch: cfg.GitBranch, DryRun: cfg.ReleaseDryRun}
+	p.report.Release = record
+	p.printf("\n🏷️  Release %s from %s\n", tag, cfg.GitBranch)
+
+	head, err := repo.Head().Ref(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryClone, "failed to look up the default branch: %w%s", err, p.cloneCredentialHint())
+	}
+	if defaultBranch := strings.TrimPrefix(head, "refs/heads/"); defaultBranch != cfg.GitBranch {
+		return nil, Errorf(CategoryConfig, "refusing to release %s from branch %s: releases are cut from the default branch %s", tag, cfg.GitBranch, defaultBranch)
+	}
+	tags, err := repo.Tags(ctx, dagger.GitRepositoryTagsOpts{Patterns: []string{"refs/tags/" + tag}})
+	if err != nil {
+		return nil, Errorf(CategoryClone, "failed to list the tags of %s: %w", cfg.GitRepo, err)
+	}
+	if len(tags) > 0 {
+		return nil, Errorf(CategoryConfig, "tag %s already exists in %s — pick the next version; a released tag is never moved", tag, cfg.GitRepo)
+	}
+
+	pyprojectPath := path.Join(cfg.ProjectDir, "pyproject.toml")
+	raw, err := ref.Tree().File(pyprojectPath).Contents(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "failed to read %s: %w", pyprojectPath, err)
+	}
+	updated, previous, err := SetPyprojectVersion(raw, cfg.ReleaseVersion)
+	if err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	record.PreviousVersion = previous
+	changed := updated != raw
+	if changed {
+		p.printf("   %s: version %s → %s\n", pyprojectPath, previous, cfg.ReleaseVersion)
+	} else {
+		p.printf("   %s already has version %s — only tagging\n", pyprojectPath, previous)
+	}
+
+	message := "Release " + tag
+	push := []string{"git", "push", "--atomic", cfg.GitRepo, "HEAD:refs/heads/" + cfg.GitBranch, "refs/tags/" + tag}
+	if cfg.ReleaseDryRun {
+		p.println("   🔎 RELEASE_DRY_RUN=true — nothing is committed, pushed or built. The release would run:")
+		if changed {
+			p.printf("      git commit -m %s -- %s\n", shellQuote(message), shellQuote(pyprojectPath))
+		}
+		p.printf("      git tag -a %s -m %s\n", tag, shellQuote(message))
+		p.printf("      %s\n", shellJoin(push))
+		return nil, nil
+	}
+
+	git, err := p.ToolContainer(ctx, client, "git")
+	if err != nil {
+		return nil, Errorf(CategoryClone, "%w", err)
+	}
+	author, _ := mail.ParseAddress(cfg.ReleaseAuthor)
+	gitURL, _ := url.Parse(cfg.GitRepo)
+	credentials := []GitCredentialHost{{Host: gitURL.Host, User: cfg.GitAuthUser}}
+	git = git.
+		WithMountedDirectory("/src", ref.Tree()).
+		WithWorkdir("/src").
+		// Never replay a cached push
+		WithEnvVariable(cacheBusterEnv, p.cacheBuster).
+		WithEnvVariable("GIT_AUTHOR_NAME", author.Name).
+		WithEnvVariable("GIT_AUTHOR_EMAIL", author.Address).
+		WithEnvVariable("GIT_COMMITTER_NAME", author.Name).
+		WithEnvVariable("GIT_COMMITTER_EMAIL", author.Address).
+		WithEnvVariable("GIT_TERMINAL_PROMPT", "0")
+	for _, kv := range GitCredentialEnv(credentials) {
+		git = git.WithEnvVariable(kv[0], kv[1])
+	}
+	git = git.WithSecretVariable(gitDependencyTokenEnv, client.SetSecret("github-pat", cfg.GitToken))
+	if changed {
+		git = git.
+			WithNewFile(pyprojectPath, updated).
+			WithExec([]string{"git", "commit", "-m", message, "--", pyprojectPath})
+	}
+	commit, err := git.
+		WithExec([]string{"git", "tag", "-a", tag, "-m", message}).
+		WithExec(push).
+		WithExec([]string{"git", "rev-parse", "HEAD"}).
+		Stdout(ctx)
+	if err != nil {
+		hint := ""
+		if cfg.WorkflowToken {
+			hint = "\nThe workflow's GITHUB_TOKEN needs permission to push: add `permissions: contents: write` to the job."
+		}
+		return nil, Errorf(CategoryClone, "failed to push release %s (a branch that moved since the clone is rejected; run again): %w%s", tag, err, hint)
+	}
+	record.Commit = strings.TrimSpace(commit)
+	p.printf("   ✅ Pushed %s and tag %s (%s)\n", cfg.GitBranch, tag, record.Commit[:min(12, len(record.Commit))])
+
+	// Build from the tag itself, so what is built is what was released
+	tagged := repo.Tag(tag)
+	taggedCommit, err := tagged.Commit(ctx)
+	if err != nil {
+		return nil, Errorf(CategoryClone, "failed to fetch the pushed tag %s: %w", tag, err)
+	}
+	if taggedCommit != record.Commit {
+		return nil, Errorf(CategoryClone, "tag %s points at %s, not the pushed release commit %s", tag, taggedCommit, record.Commit)
+	}
+	return tagged, nil
+}
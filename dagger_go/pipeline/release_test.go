@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseReleaseVersion tests the accepted RELEASE_VERSION values
+func TestParseReleaseVersion(t *testing.T) {
+	for value, want := range map[string]string{"1.4.0": "1.4.0", "v2.0.10": "2.0.10", " 0.1.0 ": "0.1.0"} {
+		if got, err := ParseReleaseVersion(value); err != nil || got != want {
+			t.Fatalf("ParseReleaseVersion(%q) = %q, %v", value, got, err)
+		}
+	}
+	for _, value := range []string{"1.4", "01.4.0", "1.4.0-rc1", "latest", ""} {
+		if _, err := ParseReleaseVersion(value); err == nil {
+			t.Fatalf("ParseReleaseVersion(%q) should fail", value)
+		}
+	}
+	fmt.Println("✅ Release versions validated")
+}
+
+// TestSetPyprojectVersion tests that only the version text changes
+func TestSetPyprojectVersion(t *testing.T) {
+	raw := "\xef\xbb\xbf[project]\r\nname = \"cert-parser\"\r\nversion   =  '0.3.1'  # bumped by the pipeline\r\ndescription = \"\"\"\r\nversion = \"9.9.9\"\r\n\"\"\"\r\n\r\n[tool.poetry]\r\nversion = \"0.3.1\"\r\n\r\n[tool.other]\r\nversion = \"7.0.0\"\r\n"
+	got, previous, err := SetPyprojectVersion(raw, "0.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer("'0.3.1'", "'0.4.0'", "version = \"0.3.1\"", "version = \"0.4.0\"").Replace(raw)
+	if got != want || previous != "0.3.1" {
+		t.Fatalf("unexpected result (previous %q):\n%q\nwant\n%q", previous, got, want)
+	}
+	if again, _, _ := SetPyprojectVersion(got, "0.4.0"); again != got {
+		t.Fatalf("setting the same version changed the file: %q", again)
+	}
+	fmt.Println("✅ pyproject.toml version replaced in place")
+}
+
+// TestSetPyprojectVersionErrors tests files the version cannot be set in
+func TestSetPyprojectVersionErrors(t *testing.T) {
+	for content, want := range map[string]string{
+		"[project]\nname = \"app\"\ndynamic = [\"version\"]\n": "dynamic",
+		"[project]\nname = \"app\"\n":                          "has no version",
+		"[project]\nversion = 1\n":                             "is not a quoted string",
+	} {
+		if _, _, err := SetPyprojectVersion(content, "1.0.0"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected an error with %q, got %v", content, want, err)
+		}
+	}
+	fmt.Println("✅ Files without a static version rejected")
+}
+
+// TestReleaseConfig tests the settings a release needs
+func TestReleaseConfig(t *testing.T) {
+	base := Config{RepoName: "cert-parser", GitUser: "org", GitToken: "token", ReleaseVersion: "v1.4.0"}
+	p, err := New(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.ReleaseVersion != "1.4.0" || p.cfg.ReleaseAuthor != DefaultReleaseAuthor {
+		t.Fatalf("unexpected defaults %q %q", p.cfg.ReleaseVersion, p.cfg.ReleaseAuthor)
+	}
+	cases := map[string]func(*Config){
+		"requires GitToken":       func(c *Config) { c.GitToken = "" },
+		"not commit":              func(c *Config) { c.GitBranch = strings.Repeat("a", 40) },
+		"is not an https:// URL":  func(c *Config) { c.GitRepo = "git@github.com:org/cert-parser.git" },
+		"invalid ReleaseAuthor":   func(c *Config) { c.ReleaseAuthor = "nobody" },
+		"is not a release":        func(c *Config) { c.ReleaseVersion = "1.4" },
+		"requires ReleaseVersion": func(c *Config) { c.ReleaseVersion, c.ReleaseDryRun = "", true },
+		"pull request build":      func(c *Config) { c.PullRequest = &PullRequest{Number: 7} },
+		"pushes as GitAuthUser":   func(c *Config) { c.GitAuthUser = "x;id" },
+	}
+	for want, change := range cases {
+		cfg := base
+		change(&cfg)
+		if _, err := New(cfg); CategoryOf(err) != CategoryConfig || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected a config error with %q, got %v", want, err)
+		}
+	}
+	dryRun := base
+	dryRun.GitToken, dryRun.ReleaseDryRun = "", true
+	if _, err := New(dryRun); err != nil {
+		t.Fatalf("a dry run needs no token: %v", err)
+	}
+	fmt.Println("✅ Release settings validated")
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// DefaultArtifactsDir is where exported files and the run report go when
+// ARTIFACTS_DIR is not set. Relative paths resolve against the working directory.
+const DefaultArtifactsDir = "pipeline-artifacts"
+
+// ReportFile is the name of the run report inside the artifacts directory.
+const ReportFile = "pipeline-report.json"
+
+// Report is the end-of-run summary written to the artifacts directory.
+type Report struct {
+	Status             Status                `json:"status"`
+	Config             map[string]string     `json:"config,omitempty"` // EffectiveConfig, secrets redacted
+	Profile            *ProfileRecord        `json:"profile,omitempty"`
+	OptionSources      OptionSources         `json:"option_sources,omitempty"` // effective value and source of every option
+	Environment        *Environment          `json:"environment,omitempty"`
+	Dagger             *DaggerVersions       `json:"dagger,omitempty"`
+	DaggerTimings      []DaggerTiming        `json:"dagger_timings,omitempty"` // key Dagger operations (TimingDetail)
+	Tests              []TestCount           `json:"tests,omitempty"`          // outcome counts per test stage, from JUnit
+	TestFailures       []TestFailure         `json:"test_failures,omitempty"`
+	FlakyTests         []FlakyTest           `json:"flaky_tests,omitempty"`
+	Lint               []LintFindings        `json:"lint,omitempty"` // ruff and mypy finding counts, passing or not
+	TestSkips          []TestSkip            `json:"test_skips,omitempty"`
+	Outcome            RunOutcome            `json:"outcome,omitempty"` // passed, passed-with-skips, failed or cancelled
+	PytestExits        []PytestExit          `json:"pytest_exits,omitempty"`
+	Resolutions        []ResolutionRun       `json:"dep_resolutions,omitempty"` // unit tests per dependency resolution (DepResolutions)
+	PythonVersions     []PythonVersionRun    `json:"python_versions,omitempty"` // the stages on the other PythonVersions
+	PythonWarnings     *PythonWarningsReport `json:"python_warnings,omitempty"` // warnings of the unit, integration and acceptance tests
+	ExternalDatabase   string                `json:"external_database,omitempty"`
+	HashCheckedInstall *HashCheckedInstall   `json:"hash_checked_install,omitempty"` // the lockfile install (HashCheckedInstall)
+	DBSeeds            []DBSeed              `json:"db_seeds,omitempty"`             // DB_SEED_COMMAND runs, timed apart from the tests
+	ServiceReady       *ServiceReadiness     `json:"service_ready,omitempty"`        // the wait for the acceptance image (SERVICE_READY_URL)
+	AcceptanceTarget   string                `json:"acceptance_target,omitempty"`    // what the acceptance tests ran against
+	Artifacts          []Artifact            `json:"artifacts,omitempty"`
+	StageArtifacts     []StageArtifacts      `json:"stage_artifacts,omitempty"` // files container stages left in ContainerArtifactsDir
+	DirtiedPaths       []string              `json:"dirtied_paths,omitempty"`   // checkout paths the run changed (Paranoid)
+	BranchLag          *BranchLag            `json:"branch_lag,omitempty"`
+	CommitReachability *CommitReachability   `json:"commit_reachability,omitempty"`
+	ClockSkew          *ClockSkew            `json:"clock_skew,omitempty"`
+	RegistryMirrors    []MirrorStatus        `json:"registry_mirrors,omitempty"`
+	Unchanged          *UnchangedSkip        `json:"unchanged,omitempty"` // the run was skipped: its commit was already built
+	Release            *ReleaseReport        `json:"release,omitempty"`
+	Pyproject          *PyprojectInfo        `json:"pyproject,omitempty"`
+	NameCheck          *NameCheckResult      `json:"name_check,omitempty"`
+	BuildTimestamp     *BuildTimestamp       `json:"build_timestamp,omitempty"`
+	ImageLabels        map[string]string     `json:"image_labels,omitempty"`
+	PublishPolicy      *PublishPolicy        `json:"publish_policy,omitempty"`
+	PublishedImages    []string              `json:"published_images,omitempty"`
+	Platforms          []PlatformDigest      `json:"platforms,omitempty"` // the manifest of each platform in the published manifest list
+	Publish            *PublishReport        `json:"publish,omitempty"`   // large images only
+	Warnings           []Warning             `json:"warnings,omitempty"`  // deduplicated, in the order first raised
+	RegistryReauths    int                   `json:"registry_reauths,omitempty"`
+	TestImage          string                `json:"test_image,omitempty"` // test-runner image (PublishTestImage), never tagged latest
+	PRImage            *PRImageReport        `json:"pr_image,omitempty"`
+	PRImagesDeleted    []string              `json:"pr_images_deleted,omitempty"` // tags of closed pull requests (PRImageCleanup)
+	ToolImages         []ToolRecord          `json:"tool_images,omitempty"`
+	Hardening          *HardeningReport      `json:"hardening,omitempty"`
+	Dependencies       *DependencySnapshot   `json:"dependencies,omitempty"`
+	ImageSize          *ImageSizeReport      `json:"image_size,omitempty"`
+	Disk               *DiskReport           `json:"disk,omitempty"`
+}
+
+// WriteReport atomically writes the report to dir/pipeline-report.json and
+// returns the path written.
+func WriteReport(dir string, r *Report) (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, ReportFile)
+	return path, WriteFileAtomic(path, data)
+}
@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reproduction is the local equivalent of a stage: recorded for each stage
+// that ran under "reproduce" in pipeline-report.json, and printed when the
+// stage fails.
+type Reproduction struct {
+	Command string            `json:"command"`           // the stage command, with the arguments the pipeline added
+	Where   string            `json:"where"`             // "host" or "container"
+	Workdir string            `json:"workdir,omitempty"` // directory the command runs in
+	Image   string            `json:"image,omitempty"`   // base image of container stages
+	Env     map[string]string `json:"env,omitempty"`     // variables the pipeline sets; credentials redacted
+	Docker  string            `json:"docker,omitempty"`  // docker run line recreating the build container
+	User    string            `json:"user,omitempty"`    // UID:GID the command runs as (TestUser)
+}
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_./:=,@%+-]+$`)
+
+// shellQuote quotes s for a POSIX shell when it needs it.
+func shellQuote(s string) string {
+	if s != "" && shellSafePattern.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// hostPytestArgs are the pytest arguments of a host-run test stage.
+func hostPytestArgs(marker, junit string, paths []string) []string {
+	return append([]string{"-v", "--tb=short", "-m", marker, "--junitxml=" + junit, "-o", "cache_dir=" + hostPytestCache(junit)}, paths...)
+}
+
+// reproduction returns the local equivalent of stage, or nil for stages
+// without a single command (publishing, hardening).
+func (p *Pipeline) reproduction(stage string) *Reproduction {
+	switch stage {
+	case StageUnitTests:
+		return p.containerReproduction(p.containerTestCmd("unit", p.unitStageMarker()))
+	case StageSlowTests:
+		return p.containerReproduction(p.containerTestCmd("slow", slowMarker))
+	case StageBenchmarks:
+		return p.containerReproduction(p.containerTestCmd("benchmark", benchmarkMarker))
+	case StageLint, StageTypeCheck:
+		tool := "ruff"
+		if stage == StageTypeCheck {
+			tool = "mypy"
+		}
+		if cmd := p.lintCommand(tool); cmd != nil {
+			return p.containerReproduction(cmd)
+		}
+	case StageIntegrationTests, StageAcceptanceTests:
+		marker := strings.TrimSuffix(stage, "-tests")
+		junit, _ := p.junitPath(marker)
+		root, _ := filepath.Abs(p.cfg.ProjectRoot)
+		r := &Reproduction{
+			Command: shellJoin(append(p.hostTestCommand(root), hostPytestArgs(marker, junit, p.changedTests)...)),
+			Where:   "host",
+			Workdir: root,
+		}
+		if p.cfg.DatabaseURL != "" {
+			r.Env = map[string]string{
+				ExternalDatabaseEnv: "1",
+				TestDatabaseURLEnv:  RedactDatabaseURL(p.cfg.DatabaseURL),
+			}
+		}
+		return r
+	case StageBuild:
+		root, _ := filepath.Abs(p.cfg.ProjectRoot)
+		return &Reproduction{Command: "docker build .", Where: "host", Workdir: root}
+	}
+	return nil
+}
+
+// containerReproduction describes cmd run in the build container, with a
+// docker run line that installs the same packages and certificates.
+func (p *Pipeline) containerReproduction(cmd []string) *Reproduction {
+	cfg := p.cfg
+	env := map[string]string{}
+	if cfg.ProxyURL != "" {
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env[name] = cfg.ProxyURL
+		}
+		env["NO_PROXY"] = "localhost,127.0.0.1,.local"
+		env["no_proxy"] = env["NO_PROXY"]
+	}
+	if len(cfg.CACertPaths) > 0 {
+		for _, name := range []string{"REQUESTS_CA_BUNDLE", "SSL_CERT_FILE", "CURL_CA_BUNDLE"} {
+			env[name] = caBundlePath
+		}
+	}
+
+	var setup []string
+	if packages := p.aptPackages(); len(packages) > 0 {
+		setup = append(setup, "apt-get update", "apt-get install -y --no-install-recommends "+shellJoin(packages))
+	}
+	if len(cfg.CACertPaths) > 0 {
+		setup = append(setup, "update-ca-certificates")
+	}
+	setup = append(setup, "pip install --upgrade pip setuptools wheel")
+	editable := "pip install -e "
+	if p.hashLock != nil {
+		setup = append(setup, "pip install --require-hashes --no-deps -r "+shellJoin([]string{p.hashLock.Path}))
+		editable = "pip install --no-deps -e "
+	}
+	for _, dir := range p.localPackageDirs {
+		setup = append(setup, editable+shellJoin([]string{"./" + dir}))
+	}
+	setup = append(setup, editable+"'.[dev,server]'")
+	if p.hashLock != nil {
+		setup = append(setup, "pip check")
+	}
+	run := p.withUmask(cmd)
+	if p.cfg.TestUser != "" {
+		uid, gid, _ := ParseTestUser(p.cfg.TestUser)
+		run = append([]string{"setpriv", fmt.Sprintf("--reuid=%d", uid), fmt.Sprintf("--regid=%d", gid), "--clear-groups"}, run...)
+	}
+	setup = append(setup, shellJoin(run))
+
+	var docker []string
+	for _, path := range cfg.CACertPaths {
+		docker = append(docker, "-v", path+":/usr/local/share/ca-certificates/"+filepath.Base(path)+":ro")
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		docker = append(docker, "-e", name+"="+env[name])
+	}
+	docker = append(docker, p.baseImage(), "sh", "-c", strings.Join(setup, " && "))
+
+	r := &Reproduction{
+		Command: shellJoin(cmd),
+		Where:   "container",
+		Workdir: AppWorkdir,
+		Image:   p.baseImage(),
+		Docker:  `docker run --rm -v "$PWD":` + AppWorkdir + " -w " + AppWorkdir + " " + shellJoin(docker),
+		User:    p.cfg.TestUser,
+	}
+	if len(env) > 0 {
+		r.Env = env
+	}
+	return r
+}
+
+// printReproduction prints how to run a failed stage locally.
+func (p *Pipeline) printReproduction(stage string) {
+	r := p.reproduction(stage)
+	if r == nil {
+		return
+	}
+	p.printf("\n🔁 Reproduce %s locally:\n", stage)
+	if r.Where == "container" {
+		p.printf("   In the build container (%s, workdir %s):\n", r.Image, r.Workdir)
+		if r.User != "" {
+			p.printf("   As user %s (TEST_AS_USER), not root\n", r.User)
+		}
+	} else {
+		p.printf("   On the host, from %s:\n", r.Workdir)
+	}
+	p.printf("   $ %s\n", r.Command)
+	if len(r.Env) > 0 {
+		names := make([]string, 0, len(r.Env))
+		for name := range r.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		p.println("   Environment set by the pipeline:")
+		for _, name := range names {
+			p.printf("     %s=%s\n", name, r.Env[name])
+		}
+	}
+	if r.Docker != "" {
+		p.println("   Same environment with Docker, from the project root:")
+		p.printf("   $ %s\n", r.Docker)
+	}
+}
@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// Dependency resolutions the unit-test stage can run against.
+const (
+	// ResolutionHighest is the normal install: the newest versions the
+	// constraints in pyproject.toml allow.
+	ResolutionHighest = "highest"
+	// ResolutionLowest installs the lowest versions the direct dependencies'
+	// lower bounds allow, with uv's --resolution lowest-direct.
+	ResolutionLowest = "lowest"
+)
+
+// uvCacheVolume holds uv's downloads for the lowest resolution, apart from
+// the pip cache of the normal install.
+const uvCacheVolume = "uv-cache"
+
+// LowestFreezeFile is the artifact listing the versions the lowest
+// resolution installed.
+const LowestFreezeFile = "requirements-lowest.txt"
+
+// ResolutionRun is the unit-test result of one dependency resolution.
+type ResolutionRun struct {
+	Resolution string `json:"resolution"`
+	Result     string `json:"result"` // passed, failed, no tests or not run
+	Freeze     string `json:"freeze,omitempty"`
+}
+
+// ParseDepResolutions parses a comma-separated DEP_RESOLUTION_MATRIX such as
+// "highest,lowest". ResolutionHighest always runs, since the later stages
+// use its environment, so it is added when missing.
+func ParseDepResolutions(value string) ([]string, error) {
+	resolutions := []string{ResolutionHighest}
+	for _, item := range ParseCommaList(value) {
+		item = strings.ToLower(item)
+		if item != ResolutionHighest && item != ResolutionLowest {
+			return nil, fmt.Errorf("unknown resolution %q (use %s or %s)", item, ResolutionHighest, ResolutionLowest)
+		}
+		if !slices.Contains(resolutions, item) {
+			resolutions = append(resolutions, item)
+		}
+	}
+	return resolutions, nil
+}
+
+// lowestInstallCmd installs the project, and the local packages in
+// frameworks, at the lowest versions allowed for their direct dependencies.
+func lowestInstallCmd(frameworks []string) []string {
+	cmd := []string{"uv", "pip", "install", "--system", "--resolution", "lowest-direct"}
+	for _, dir := range frameworks {
+		cmd = append(cmd, "-e", "./"+dir)
+	}
+	return append(cmd, "-e", ".[dev,server]")
+}
+
+// lowestEnv is buildEnv with the lowest resolution: a separate container
+// from the same system environment, installing with uv and its own cache.
+func (p *Pipeline) lowestEnv(client *dagger.Client, source *dagger.Directory) *dagger.Container {
+	container := p.systemBase.
+		WithMountedDirectory(AppWorkdir, source).
+		WithWorkdir(AppWorkdir).
+		WithExec([]string{"pip", "install", "--upgrade", "pip", "uv"}).
+		WithMountedCache("/root/.cache/uv", p.cacheVolume(client, uvCacheVolume))
+	// uv reads neither PIP_INDEX_URL nor, by default, the system trust store
+	if p.cfg.PipIndexURL != "" {
+		container = container.WithEnvVariable("UV_DEFAULT_INDEX", p.cfg.PipIndexURL)
+	}
+	if len(p.cfg.CACertPaths) > 0 {
+		container = container.WithEnvVariable("UV_NATIVE_TLS", "true")
+	}
+	container = p.withGitCredentials(client, container)
+	container = container.WithExec(lowestInstallCmd(p.localPackageDirs))
+	return p.withoutGitCredentials(container)
+}
+
+// runLowestResolution runs the unit tests again against the lowest
+// resolution, after they passed against the highest. A failure here means
+// a lower bound in pyproject.toml is too low, and the error says so.
+func (p *Pipeline) runLowestResolution(ctx context.Context, client *dagger.Client, source *dagger.Directory) error {
+	run := ResolutionRun{Resolution: ResolutionLowest, Result: "failed"}
+	defer func() { p.report.Resolutions = append(p.report.Resolutions, run) }()
+
+	p.printf("\n🔻 Unit tests against the lowest dependency versions (uv --resolution lowest-direct)\n")
+	p.println(separatorLine)
+	builder := p.withStageEnv(client, StageUnitTests, p.freshFor(StageUnitTests, p.lowestEnv(client, source)))
+	freeze, err := builder.WithExec([]string{"pip", "freeze", "--all"}).Stdout(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "install with the lowest dependency versions failed — the lower bounds in pyproject.toml cannot be installed together: %w", err)
+	}
+	if path, werr := p.writeLowestFreeze(freeze); werr == nil {
+		run.Freeze = path
+		p.printf("   📄 Lowest versions: %s (%d packages)\n", path, len(ParseFreeze(freeze)))
+	} else {
+		p.warnf("lowest-freeze", "   ⚠️  Could not write %s: %v\n", LowestFreezeFile, werr)
+	}
+
+	_, ran, err := p.runContainerTests(ctx, builder, p.containerTests(builder, "unit-lowest", p.unitStageMarker()), "unit-lowest", p.unitStageMarker())
+	if err != nil {
+		err = testStageError("unit tests (lowest dependency versions)", err)
+		return Errorf(CategoryOf(err), "lowest dependency versions: %w — the highest versions passed, so a lower bound in pyproject.toml is too low (see %s)", err, LowestFreezeFile)
+	}
+	run.Result = "passed"
+	if !ran {
+		run.Result = "no tests"
+	}
+	return nil
+}
+
+// recordResolution records the unit-test result of a resolution.
+func (p *Pipeline) recordResolution(resolution, result string) {
+	p.report.Resolutions = append(p.report.Resolutions, ResolutionRun{Resolution: resolution, Result: result})
+}
+
+// writeLowestFreeze writes the lowest resolution's pip freeze to the
+// artifacts directory.
+func (p *Pipeline) writeLowestFreeze(freeze string) (string, error) {
+	if err := os.MkdirAll(p.cfg.ArtifactsDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(p.cfg.ArtifactsDir, LowestFreezeFile)
+	if err := WriteFileAtomic(path, []byte(freeze)); err != nil {
+		return "", err
+	}
+	p.report.Artifacts = append(p.report.Artifacts, Artifact{Name: LowestFreezeFile, Path: path, Size: int64(len(freeze)), SHA256: sha256Hex(freeze)})
+	return path, nil
+}
+
+// ResolutionsMarkdown renders the unit-test result per dependency
+// resolution for a CI job summary.
+func ResolutionsMarkdown(runs []ResolutionRun) string {
+	var b strings.Builder
+	b.WriteString("### Unit tests per dependency resolution\n\n| Resolution | Result |\n|---|---|\n")
+	for _, r := range runs {
+		result := r.Result
+		if r.Resolution == ResolutionLowest && r.Result == "failed" {
+			result += " (lower bounds too low, not a code regression)"
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", r.Resolution, result)
+	}
+	return b.String()
+}
+
+// printResolutions prints the unit-test result per resolution and adds it
+// to the job summary, when one is configured.
+func (p *Pipeline) printResolutions() {
+	if len(p.report.Resolutions) < 2 {
+		return
+	}
+	p.println("   📊 Unit tests per dependency resolution:")
+	for _, r := range p.report.Resolutions {
+		p.printf("      %-8s %s\n", r.Resolution, r.Result)
+	}
+	if p.cfg.StepSummary == "" {
+		return
+	}
+	if err := AppendStepSummary(p.cfg.StepSummary, ResolutionsMarkdown(p.report.Resolutions)); err != nil {
+		p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", err)
+	}
+}
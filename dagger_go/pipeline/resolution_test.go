@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseDepResolutions tests parsing DEP_RESOLUTION_MATRIX and validating DepResolutions
+func TestParseDepResolutions(t *testing.T) {
+	cases := map[string][]string{
+		"":                {ResolutionHighest},
+		"highest":         {ResolutionHighest},
+		"highest,lowest":  {ResolutionHighest, ResolutionLowest},
+		" Lowest ":        {ResolutionHighest, ResolutionLowest},
+		"lowest,highest,": {ResolutionHighest, ResolutionLowest},
+	}
+	for value, want := range cases {
+		got, err := ParseDepResolutions(value)
+		if err != nil || !slices.Equal(got, want) {
+			t.Fatalf("ParseDepResolutions(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseDepResolutions("highest,oldest"); err == nil || !strings.Contains(err.Error(), "oldest") {
+		t.Fatalf("an unknown resolution should be rejected, got %v", err)
+	}
+
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", DepResolutions: []string{ResolutionLowest}}); err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("lowest without unit tests should be a config error, got %v", err)
+	}
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", RunUnitTests: true, DepResolutions: []string{ResolutionLowest}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if !slices.Equal(p.cfg.DepResolutions, []string{ResolutionHighest, ResolutionLowest}) {
+		t.Fatalf("DepResolutions = %v, highest should be added", p.cfg.DepResolutions)
+	}
+	fmt.Println("✅ Dependency resolutions parsed")
+}
+
+// TestLowestResolution tests the lowest-resolution install and its job summary
+func TestLowestResolution(t *testing.T) {
+	cmd := strings.Join(lowestInstallCmd(DefaultLocalFrameworkDirs), " ")
+	if cmd != "uv pip install --system --resolution lowest-direct -e ./python_framework -e .[dev,server]" {
+		t.Fatalf("lowestInstallCmd(python_framework) = %s", cmd)
+	}
+	if cmd := strings.Join(lowestInstallCmd(nil), " "); strings.Contains(cmd, "python_framework") {
+		t.Fatalf("lowestInstallCmd(nil) should install the project only: %s", cmd)
+	}
+
+	md := ResolutionsMarkdown([]ResolutionRun{
+		{Resolution: ResolutionHighest, Result: "passed"},
+		{Resolution: ResolutionLowest, Result: "failed"},
+	})
+	for _, want := range []string{"| highest | passed |", "| lowest | failed (lower bounds too low, not a code regression) |"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("summary should contain %q:\n%s", want, md)
+		}
+	}
+	fmt.Println("✅ Lowest resolution planned")
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxManifestSize bounds the manifest read back from the registry; image
+// indexes for a handful of platforms are a few KiB.
+const maxManifestSize = 4 << 20
+
+// RetagImage points newTag at a manifest that is already in the registry
+// (image is the pushed repository:tag, digest its manifest digest) with one
+// GET and one PUT of the manifest, instead of a second full Publish. It
+// returns the digest the registry reports for the new tag.
+func RetagImage(ctx context.Context, client *http.Client, image, digest, newTag, username, password string) (string, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("no manifest digest for %s", image)
+	}
+	host, repo, _ := splitImage(image)
+	base := fmt.Sprintf("https://%s/v2/%s/manifests/", host, repo)
+
+	auth, err := registryAuth(ctx, client, base+digest, repo, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+digest, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch manifest %s: registry returned %s", digest, resp.Status)
+	}
+	mediaType := resp.Header.Get("Content-Type")
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, base+newTag, bytes.NewReader(manifest))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("tag %s: registry returned %s", newTag, resp.Status)
+	}
+	if d := resp.Header.Get("Docker-Content-Digest"); d != "" {
+		return d, nil
+	}
+	return digest, nil
+}
+
+// registryAuth probes url and returns the Authorization header the registry
+// asks for: a push-scoped bearer token, basic credentials, or "" when the
+// registry is open.
+func registryAuth(ctx context.Context, client *http.Client, url, repo, username, password string) (string, error) {
+	resp, err := headManifest(ctx, client, url, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if strings.HasPrefix(challenge, "Basic ") {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	}
+	token, err := bearerToken(ctx, client, challenge, "repository:"+repo+":pull,push", username, password)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// imageDigest returns the digest part of a published reference
+// (registry/repo:tag@sha256:…), or "" when there is none.
+func imageDigest(ref string) string {
+	if _, digest, ok := strings.Cut(ref, "@"); ok {
+		return digest
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRetagImage tests re-tagging a pushed manifest through the registry API
+func TestRetagImage(t *testing.T) {
+	const (
+		digest    = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		manifest  = `{"schemaVersion":2}`
+		mediaType = "application/vnd.oci.image.manifest.v1+json"
+	)
+	var server *httptest.Server
+	var tagged string
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, _ := r.BasicAuth()
+			if user != "ci" || pass != "pat" || r.URL.Query().Get("scope") != "repository:org/app:pull,push" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token":"push"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer push" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/org/app/manifests/"+digest:
+			w.Header().Set("Content-Type", mediaType)
+			fmt.Fprint(w, manifest)
+		case r.Method == http.MethodPut && r.URL.Path == "/v2/org/app/manifests/latest":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != manifest || r.Header.Get("Content-Type") != mediaType {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tagged = "latest"
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	got, err := RetagImage(context.Background(), server.Client(), host+"/org/app:v0.1.0-abc", digest, "latest", "ci", "pat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != digest || tagged != "latest" {
+		t.Fatalf("got digest %q, tagged %q", got, tagged)
+	}
+
+	if _, err := RetagImage(context.Background(), server.Client(), host+"/org/app:v0.1.0-abc", digest, "latest", "ci", "wrong"); err == nil {
+		t.Fatal("expected bad credentials to fail so the caller falls back to Publish")
+	}
+	if _, err := RetagImage(context.Background(), server.Client(), host+"/org/app:v0.1.0-abc", "", "latest", "ci", "pat"); err == nil {
+		t.Fatal("expected a missing digest to fail")
+	}
+	fmt.Println("✅ latest tag created from the pushed manifest")
+}
+
+// TestImageDigest tests extracting the digest from a published reference
+func TestImageDigest(t *testing.T) {
+	if got := imageDigest("ghcr.io/org/app:v1@sha256:abc"); got != "sha256:abc" {
+		t.Fatalf("got %q", got)
+	}
+	if got := imageDigest("ghcr.io/org/app:v1"); got != "" {
+		t.Fatalf("got %q", got)
+	}
+	fmt.Println("✅ Digest extracted from published reference")
+}
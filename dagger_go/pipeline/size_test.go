@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestParseByteSize tests human-readable size parsing
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1024", 1024},
+		{"5MiB", 5 << 20},
+		{"5 mib", 5 << 20},
+		{"300MB", 300 * 1000 * 1000},
+		{"1.5GB", 1500 * 1000 * 1000},
+		{"5GiB", 5 << 30},
+		{"10k", 10000},
+		{"512B", 512},
+	}
+	for _, tc := range tests {
+		got, err := ParseByteSize(tc.input)
+		if err != nil {
+			t.Fatalf("ParseByteSize(%q) returned error: %v", tc.input, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("ParseByteSize(%q) = %d, want %d", tc.input, got, tc.expected)
+		}
+	}
+
+	for _, bad := range []string{"", "MB", "5 parsecs", "-1MB"} {
+		if _, err := ParseByteSize(bad); err == nil {
+			t.Fatalf("ParseByteSize(%q) should fail", bad)
+		}
+	}
+	fmt.Println("✅ Byte sizes parse correctly")
+}
+
+// TestFormatBytes tests binary-unit rendering
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		input    int64
+		expected string
+	}{
+		{512, "512 B"},
+		{5 << 20, "5.0 MiB"},
+		{1536, "1.5 KiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tc := range tests {
+		if got := FormatBytes(tc.input); got != tc.expected {
+			t.Fatalf("FormatBytes(%d) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+	fmt.Println("✅ Byte counts render correctly")
+}
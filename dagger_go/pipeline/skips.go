@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TestSkip records a stage that was enabled but could not run, such as a
+// test stage without Docker, so a green run never hides untested code.
+type TestSkip struct {
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+// dockerSkipReason explains why the testcontainers stages cannot run,
+// including how Docker was looked for when the caller reported it.
+func dockerSkipReason(detection string) string {
+	reason := "Docker not available — testcontainers cannot start PostgreSQL"
+	if detection != "" {
+		reason += " (" + detection + ")"
+	}
+	return reason
+}
+
+// RunOutcome is the verdict of a finished run. A run that succeeded with
+// enabled stages skipped is told apart from a fully green one, so branch
+// protection and reviewers do not read the skips as passes.
+type RunOutcome string
+
+const (
+	RunOutcomePassed          RunOutcome = "passed"
+	RunOutcomePassedWithSkips RunOutcome = "passed-with-skips"
+	RunOutcomeFailed          RunOutcome = "failed"
+	RunOutcomeCancelled       RunOutcome = "cancelled"
+)
+
+// Outcome is the verdict of a run with status, where skips are the enabled
+// stages that could not run. A run still running has none.
+func Outcome(status Status, skips []TestSkip) RunOutcome {
+	switch {
+	case status.State == RunFailed:
+		return RunOutcomeFailed
+	case status.State == RunCancelled:
+		return RunOutcomeCancelled
+	case status.State != RunSucceeded:
+		return ""
+	case len(skips) > 0:
+		return RunOutcomePassedWithSkips
+	}
+	return RunOutcomePassed
+}
+
+// skipEnabledStage records an enabled stage that cannot run, e.g. offline,
+// as a skipped stage and a TestSkip.
+func (p *Pipeline) skipEnabledStage(stage, reason string) {
+	p.stageSkippedBanner(stage)
+	p.printf("   ⏭️  %s\n", reason)
+	p.tracker.SkipStage(stage, reason)
+	p.report.TestSkips = append(p.report.TestSkips, TestSkip{Stage: stage, Reason: reason})
+}
+
+// TestSkipsMarkdown renders skipped stages for a CI job summary.
+func TestSkipsMarkdown(skips []TestSkip) string {
+	var b strings.Builder
+	b.WriteString("### ⚠️ Stages skipped\n\n")
+	for _, s := range skips {
+		fmt.Fprintf(&b, "- **%s**: %s\n", s.Stage, s.Reason)
+	}
+	return b.String()
+}
+
+// skipDockerTests records the enabled integration and acceptance stages as
+// skipped for want of Docker. With RequireDockerTests the first of them
+// fails instead, with a CategoryDocker error, so CI can tell a runner
+// without Docker from a bad configuration or a failing test.
+func (p *Pipeline) skipDockerTests() error {
+	cfg := p.cfg
+	p.warnf("docker-missing", "   ⚠️  Docker socket NOT available on the host\n")
+	reason := dockerSkipReason(cfg.DockerDetection)
+	for _, stage := range []struct {
+		enabled bool
+		name    string
+		label   string
+	}{
+		{cfg.RunIntegrationTests, StageIntegrationTests, "Integration"},
+		{cfg.RunAcceptanceTests, StageAcceptanceTests, "Acceptance"},
+	} {
+		if !stage.enabled {
+			continue
+		}
+		p.report.TestSkips = append(p.report.TestSkips, TestSkip{Stage: stage.name, Reason: reason})
+		if cfg.RequireDockerTests {
+			p.printf("   %s tests REQUIRE Docker (REQUIRE_DOCKER_TESTS=true)\n", stage.label)
+		} else {
+			p.printf("   %s tests will be SKIPPED (require Docker)\n", stage.label)
+		}
+	}
+	p.appendTestSkipSummary()
+	if !cfg.RequireDockerTests || len(p.report.TestSkips) == 0 {
+		return nil
+	}
+	stage := p.report.TestSkips[0].Stage
+	err := Errorf(CategoryDocker, "Docker-dependent tests required but Docker is unavailable: %s", reason)
+	p.tracker.StartStage(stage)
+	p.stageFailed(stage)
+	p.printf("   %s\n", reason)
+	p.println("   Set REQUIRE_DOCKER_TESTS=false to skip these stages on purpose")
+	p.tracker.FailStage(err)
+	return err
+}
+
+// appendTestSkipSummary adds the recorded skips to the job summary, when one
+// is configured.
+func (p *Pipeline) appendTestSkipSummary() {
+	if p.cfg.StepSummary == "" || len(p.report.TestSkips) == 0 {
+		return
+	}
+	if err := AppendStepSummary(p.cfg.StepSummary, TestSkipsMarkdown(p.report.TestSkips)); err != nil {
+		p.warnf("job-summary", "   ⚠️  Could not write job summary: %v\n", err)
+	}
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDockerSkipReason tests that the Docker detection outcome is part of the skip reason
+func TestDockerSkipReason(t *testing.T) {
+	if got := dockerSkipReason(""); got != "Docker not available — testcontainers cannot start PostgreSQL" {
+		t.Fatalf("unexpected reason %q", got)
+	}
+	if got := dockerSkipReason("no socket at /var/run/docker.sock"); !strings.HasSuffix(got, "(no socket at /var/run/docker.sock)") {
+		t.Fatalf("detection missing from reason %q", got)
+	}
+	fmt.Println("✅ Skip reason explains Docker detection")
+}
+
+// TestTestSkipSummary tests that skipped test stages are appended to the job summary
+func TestTestSkipSummary(t *testing.T) {
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", StepSummary: summary, Output: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.appendTestSkipSummary()
+	if _, err := os.Stat(summary); !os.IsNotExist(err) {
+		t.Fatal("summary should not be written when nothing was skipped")
+	}
+
+	p.report.TestSkips = []TestSkip{{Stage: "integration-tests", Reason: dockerSkipReason("")}}
+	p.appendTestSkipSummary()
+	data, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "**integration-tests**: Docker not available") {
+		t.Fatalf("unexpected summary:\n%s", data)
+	}
+	fmt.Println("✅ Skipped test stages surfaced in the job summary")
+}
+
+// TestSkipDockerTests tests skipping the testcontainers stages without Docker, and failing the first with REQUIRE_DOCKER_TESTS
+func TestSkipDockerTests(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", RunIntegrationTests: true, RunAcceptanceTests: true, DockerDetection: "no socket", Output: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.skipDockerTests(); err != nil || len(p.report.TestSkips) != 2 || len(p.tracker.Snapshot().Stages) != 0 {
+		t.Fatalf("expected both stages skipped without a failure, got %v, %+v", err, p.report.TestSkips)
+	}
+
+	out := &strings.Builder{}
+	p, err = New(Config{RepoName: "cert-parser", GitUser: "org", RunIntegrationTests: true, RunAcceptanceTests: true, RequireDockerTests: true, Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.skipDockerTests()
+	if CategoryOf(err) != CategoryDocker || ExitCode(err) != ExitDocker || ExitDocker == ExitConfig {
+		t.Fatalf("expected the Docker exit code %d, got %d (%v)", ExitDocker, ExitCode(err), err)
+	}
+	stages := p.tracker.Snapshot().Stages
+	if len(stages) != 1 || stages[0].Name != StageIntegrationTests || stages[0].Status != StageFailed {
+		t.Fatalf("expected %s to be marked failed, got %+v", StageIntegrationTests, stages)
+	}
+	if !strings.Contains(out.String(), "PIPELINE FAILED AT STAGE "+StageIntegrationTests) {
+		t.Fatalf("expected the failed stage to be printed, got:\n%s", out)
+	}
+	fmt.Println("✅ Missing Docker fails required test stages with its own exit code")
+}
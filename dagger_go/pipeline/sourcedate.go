@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp sources recorded in the report.
+const (
+	TimestampSourceDateEpoch = "SOURCE_DATE_EPOCH"
+	TimestampClock           = "clock"
+)
+
+// OCICreatedLabel is the image label carrying the build timestamp.
+const OCICreatedLabel = "org.opencontainers.image.created"
+
+// BuildTimestamp is the time embedded in the image tag and labels, and where
+// it came from, so reproducibility audits can tell a pinned build from a
+// clock-stamped one.
+type BuildTimestamp struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"` // TimestampSourceDateEpoch or TimestampClock
+}
+
+// ParseSourceDateEpoch parses SOURCE_DATE_EPOCH: whole seconds since
+// 1970-01-01 UTC, as set by reproducible-builds tooling.
+func ParseSourceDateEpoch(value string) (int64, error) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q (seconds since 1970-01-01 UTC, e.g. the commit time from git log -1 --format=%%ct)", value)
+	}
+	return seconds, nil
+}
+
+// buildTimestamp is SourceDateEpoch when set, and the current time otherwise.
+func (p *Pipeline) buildTimestamp() BuildTimestamp {
+	if p.cfg.SourceDateEpoch > 0 {
+		return BuildTimestamp{Time: time.Unix(p.cfg.SourceDateEpoch, 0).UTC(), Source: TimestampSourceDateEpoch}
+	}
+	return BuildTimestamp{Time: time.Now(), Source: TimestampClock}
+}
+
+// TagTimestamp is the timestamp part of the image tag. A SOURCE_DATE_EPOCH
+// timestamp is formatted in UTC so the tag is the same on every runner.
+func (t BuildTimestamp) TagTimestamp() string {
+	if t.Source == TimestampSourceDateEpoch {
+		return t.Time.UTC().Format("20060102-1504")
+	}
+	return t.Time.Format("20060102-1504")
+}
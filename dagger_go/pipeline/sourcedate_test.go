@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestParseSourceDateEpoch tests accepting whole seconds and rejecting anything else
+func TestParseSourceDateEpoch(t *testing.T) {
+	if n, err := ParseSourceDateEpoch(" 1760693400 "); err != nil || n != 1760693400 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	for _, bad := range []string{"", "0", "-5", "2026-10-17", "1760693400.5"} {
+		if _, err := ParseSourceDateEpoch(bad); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+	fmt.Println("✅ SOURCE_DATE_EPOCH parsed")
+}
+
+// TestBuildTimestamp tests that SourceDateEpoch pins the tag timestamp and is recorded as the source
+func TestBuildTimestamp(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", SourceDateEpoch: 1760693400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := p.buildTimestamp()
+	if ts.Source != TimestampSourceDateEpoch || ts.TagTimestamp() != "20251017-0930" {
+		t.Fatalf("got %+v, tag %s", ts, ts.TagTimestamp())
+	}
+	if again := p.buildTimestamp(); !again.Time.Equal(ts.Time) {
+		t.Fatal("a pinned timestamp must not depend on the clock")
+	}
+
+	p, err = New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := p.buildTimestamp(); ts.Source != TimestampClock || time.Since(ts.Time) > time.Minute {
+		t.Fatalf("without SourceDateEpoch the clock is used, got %+v", ts)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", SourceDateEpoch: -1}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("negative SourceDateEpoch should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Build timestamp pinned by SOURCE_DATE_EPOCH")
+}
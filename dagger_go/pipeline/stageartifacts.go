@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// ContainerArtifactsDir is where tests and tools in a container stage leave
+// files worth keeping, such as profiles, hypothesis examples or generated
+// schemas. After the stage, passing or failing, they are exported to
+// <ArtifactsDir>/<stage>/.
+const ContainerArtifactsDir = AppWorkdir + "/.pipeline-artifacts"
+
+// DefaultStageArtifactsLimit caps what one stage exports from
+// ContainerArtifactsDir.
+const DefaultStageArtifactsLimit = 100 << 20 // 100 MiB
+
+// ParseStageArtifactsLimit parses STAGE_ARTIFACTS_LIMIT: a size such as
+// 20MiB, or "off" (-1) to export nothing.
+func ParseStageArtifactsLimit(value string) (int64, error) {
+	return parseSizeOrOff(value)
+}
+
+// StageArtifactFile is one file a stage left in ContainerArtifactsDir.
+type StageArtifactFile struct {
+	Path string `json:"path"` // relative to the stage's directory
+	Size int64  `json:"size"`
+}
+
+// StageArtifacts records what a container stage left in
+// ContainerArtifactsDir and where it was exported.
+type StageArtifacts struct {
+	Stage   string              `json:"stage"`
+	Dir     string              `json:"dir"`
+	Files   []StageArtifactFile `json:"files"`
+	Bytes   int64               `json:"bytes"`
+	Skipped []StageArtifactFile `json:"skipped,omitempty"` // over StageArtifactsLimit, not exported
+}
+
+// LimitStageArtifacts keeps the files, in path order, that fit in limit
+// bytes together; the others are skipped.
+func LimitStageArtifacts(files []StageArtifactFile, limit int64) (kept, skipped []StageArtifactFile) {
+	files = append([]StageArtifactFile(nil), files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	var total int64
+	for _, f := range files {
+		if total+f.Size > limit {
+			skipped = append(skipped, f)
+			continue
+		}
+		total += f.Size
+		kept = append(kept, f)
+	}
+	return kept, skipped
+}
+
+// exportStageArtifacts exports ContainerArtifactsDir of container to
+// <ArtifactsDir>/<stage>/, replacing what an earlier run left there, and
+// lists the files. Nothing happens when the stage left nothing; an export
+// that fails only warns.
+func (p *Pipeline) exportStageArtifacts(ctx context.Context, stage string, container *dagger.Container) {
+	if p.cfg.StageArtifactsLimit < 0 || container == nil {
+		return
+	}
+	rel := strings.TrimPrefix(ContainerArtifactsDir, AppWorkdir+"/")
+	if found, err := container.Directory(AppWorkdir).Glob(ctx, rel); err != nil || len(found) == 0 {
+		return
+	}
+	dir := container.Directory(ContainerArtifactsDir)
+	paths, err := dir.Glob(ctx, "**/*")
+	if err != nil {
+		p.warnf("stage-artifacts", "   ⚠️  Could not list %s: %v\n", ContainerArtifactsDir, err)
+		return
+	}
+	var files []StageArtifactFile
+	for _, path := range paths {
+		if strings.HasSuffix(path, "/") {
+			continue
+		}
+		size, err := dir.File(path).Size(ctx)
+		if err != nil {
+			continue // a directory
+		}
+		files = append(files, StageArtifactFile{Path: path, Size: int64(size)})
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	kept, skipped := LimitStageArtifacts(files, p.cfg.StageArtifactsLimit)
+	record := StageArtifacts{Stage: stage, Dir: filepath.Join(p.cfg.ArtifactsDir, stage), Files: kept, Skipped: skipped}
+	for _, f := range kept {
+		record.Bytes += f.Size
+	}
+	if len(skipped) > 0 {
+		var without []string
+		for _, f := range skipped {
+			without = append(without, f.Path)
+		}
+		dir = dir.WithoutFiles(without)
+	}
+	err = os.RemoveAll(record.Dir)
+	if err == nil {
+		_, err = dir.Export(ctx, record.Dir)
+	}
+	if err != nil {
+		p.warnf("stage-artifacts", "   ⚠️  Could not export %s to %s: %v\n", ContainerArtifactsDir, record.Dir, err)
+		return
+	}
+	p.report.StageArtifacts = append(p.report.StageArtifacts, record)
+	p.printStageArtifacts(record)
+}
+
+// printStageArtifacts lists the files a stage exported.
+func (p *Pipeline) printStageArtifacts(record StageArtifacts) {
+	p.printf("📎 Stage artifacts: %d file(s), %s → %s\n", len(record.Files), FormatBytes(record.Bytes), record.Dir)
+	for _, f := range record.Files {
+		p.printf("   %s (%s)\n", f.Path, FormatBytes(f.Size))
+	}
+	if len(record.Skipped) > 0 {
+		var names []string
+		for _, f := range record.Skipped {
+			names = append(names, f.Path)
+		}
+		p.warnf("stage-artifacts", "   ⚠️  Not exported, over STAGE_ARTIFACTS_LIMIT=%s: %s\n", FormatBytes(p.cfg.StageArtifactsLimit), strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestLimitStageArtifacts tests that the files are kept in path order up to the limit
+func TestLimitStageArtifacts(t *testing.T) {
+	files := []StageArtifactFile{
+		{Path: "schemas/openapi.json", Size: 300},
+		{Path: "hypothesis/examples.txt", Size: 200},
+		{Path: "profile.prof", Size: 900},
+		{Path: "coverage.xml", Size: 100},
+	}
+	kept, skipped := LimitStageArtifacts(files, 700)
+	var got []string
+	for _, f := range kept {
+		got = append(got, f.Path)
+	}
+	if strings.Join(got, ",") != "coverage.xml,hypothesis/examples.txt,schemas/openapi.json" {
+		t.Fatalf("unexpected kept files %v", got)
+	}
+	if len(skipped) != 1 || skipped[0].Path != "profile.prof" {
+		t.Fatalf("unexpected skipped files %+v", skipped)
+	}
+	if files[0].Path != "schemas/openapi.json" {
+		t.Fatal("the caller's slice should not be reordered")
+	}
+
+	for value, want := range map[string]int64{"20MiB": 20 << 20, "off": -1, "500kb": 500000} {
+		if got, err := ParseStageArtifactsLimit(value); err != nil || got != want {
+			t.Fatalf("ParseStageArtifactsLimit(%q) = %d, %v", value, got, err)
+		}
+	}
+	if _, err := ParseStageArtifactsLimit("lots"); err == nil {
+		t.Fatal("expected an error")
+	}
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org"})
+	if err != nil || p.cfg.StageArtifactsLimit != DefaultStageArtifactsLimit {
+		t.Fatalf("expected the default limit: %v", err)
+	}
+	fmt.Println("✅ Stage artifacts capped at the limit")
+}
+
+// TestPrintStageArtifacts tests the file list in the stage summary
+func TestPrintStageArtifacts(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", StageArtifactsLimit: 1 << 20, Output: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.printStageArtifacts(StageArtifacts{
+		Stage: StageUnitTests, Dir: "pipeline-artifacts/unit-tests", Bytes: 2048,
+		Files:   []StageArtifactFile{{Path: "coverage.xml", Size: 1024}, {Path: "schemas/openapi.json", Size: 1024}},
+		Skipped: []StageArtifactFile{{Path: "profile.prof", Size: 5 << 20}},
+	})
+	s := out.String()
+	for _, want := range []string{
+		"📎 Stage artifacts: 2 file(s), 2.0 KiB → pipeline-artifacts/unit-tests",
+		"   schemas/openapi.json (1.0 KiB)",
+		"Not exported, over STAGE_ARTIFACTS_LIMIT=1.0 MiB: profile.prof",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("output should contain %q:\n%s", want, s)
+		}
+	}
+	fmt.Println("✅ Stage artifacts listed in the stage summary")
+}
@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// StageEnvPrefix is the prefix of the per-stage environment variables,
+// e.g. STAGE_ENV_UNIT_TESTS="TZ=UTC,FEATURE_X=1".
+const StageEnvPrefix = "STAGE_ENV_"
+
+// stageEnvSecretPrefix marks a value that names a secret instead, e.g.
+// TOKEN=@secret:CR_PAT.
+const stageEnvSecretPrefix = "@secret:"
+
+// envNamePattern is a name a shell can export.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// StageEnvStages are the stages that run commands in the build container or
+// on the host, which is where StageEnv applies.
+var StageEnvStages = []string{
+	StageUnitTests, StageSlowTests, StageBenchmarks, StageIntegrationTests, StageAcceptanceTests,
+	StageLint, StageTypeCheck, StageDependencyAudit, StageExportWheels, StagePackagePublish,
+}
+
+// StageEnvVar is one variable added to a stage's environment.
+type StageEnvVar struct {
+	Name   string
+	Value  string
+	Secret string // the variable Value was read from (@secret:NAME); passed as a Dagger secret
+}
+
+// String is the variable as configured, without a secret's value.
+func (v StageEnvVar) String() string {
+	if v.Secret != "" {
+		return v.Name + "=" + stageEnvSecretPrefix + v.Secret
+	}
+	return v.Name + "=" + v.Value
+}
+
+// ParseStageEnv parses a STAGE_ENV_<STAGE> value: comma-separated
+// KEY=VALUE pairs. A value @secret:NAME is the value of the variable NAME,
+// looked up with lookup, and is kept secret.
+func ParseStageEnv(value string, lookup func(string) (string, bool)) ([]StageEnvVar, error) {
+	var vars []StageEnvVar
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(entry, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%q is not KEY=VALUE with a valid variable name", entry)
+		}
+		v := StageEnvVar{Name: name, Value: val}
+		if secret, ok := strings.CutPrefix(val, stageEnvSecretPrefix); ok {
+			if v.Value, ok = lookup(secret); !ok {
+				return nil, fmt.Errorf("%s refers to secret %s, which is not set", name, secret)
+			}
+			v.Secret = secret
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
+// StageEnvOverrides extracts the STAGE_ENV_<STAGE> entries from an
+// environment list (os.Environ format), keyed by stage name:
+// STAGE_ENV_UNIT_TESTS sets the environment of unit-tests.
+func StageEnvOverrides(environ []string, lookup func(string) (string, bool)) (map[string][]StageEnvVar, error) {
+	overrides := map[string][]StageEnvVar{}
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, StageEnvPrefix) {
+			continue
+		}
+		stage := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, StageEnvPrefix), "_", "-"))
+		vars, err := ParseStageEnv(value, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if len(vars) > 0 {
+			overrides[stage] = vars
+		}
+	}
+	return overrides, nil
+}
+
+// validateStageEnv rejects stage environments for unknown stages and for
+// stages that run no commands of their own.
+func validateStageEnv(env map[string][]StageEnvVar) error {
+	for stage, vars := range env {
+		if !slices.Contains(StageEnvStages, stage) {
+			return fmt.Errorf("StageEnv for %q: only stages that run commands in the build container or on the host take one (%s)", stage, strings.Join(StageEnvStages, ", "))
+		}
+		for _, v := range vars {
+			if !envNamePattern.MatchString(v.Name) || v.Name == cacheBusterEnv || v.Name == gitDependencyTokenEnv {
+				return fmt.Errorf("StageEnv for %s: %q cannot be set", stage, v.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// stageEnvKeys lists the variables of a stage's environment for its banner,
+// names only.
+func (p *Pipeline) stageEnvKeys(stage string) string {
+	keys := make([]string, 0, len(p.cfg.StageEnv[stage]))
+	for _, v := range p.cfg.StageEnv[stage] {
+		if v.Secret != "" {
+			keys = append(keys, v.Name+" (secret)")
+		} else {
+			keys = append(keys, v.Name)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// withStageEnv adds the StageEnv of stage to the container the stage runs
+// in; variables from a secret are Dagger secrets.
+func (p *Pipeline) withStageEnv(client *dagger.Client, stage string, container *dagger.Container) *dagger.Container {
+	for _, v := range p.cfg.StageEnv[stage] {
+		if v.Secret != "" {
+			container = container.WithSecretVariable(v.Name, client.SetSecret("stage-env-"+strings.ToLower(v.Secret), v.Value))
+		} else {
+			container = container.WithEnvVariable(v.Name, v.Value)
+		}
+	}
+	return container
+}
+
+// withoutStageEnv undoes withStageEnv when the stage's container becomes
+// the base of the stages after it: variables base had get its values back,
+// the others are removed.
+func (p *Pipeline) withoutStageEnv(ctx context.Context, stage string, base, container *dagger.Container) *dagger.Container {
+	for _, v := range p.cfg.StageEnv[stage] {
+		if v.Secret != "" {
+			container = container.WithoutSecretVariable(v.Name)
+			continue
+		}
+		if value, err := base.EnvVariable(ctx, v.Name); err == nil && value != "" {
+			container = container.WithEnvVariable(v.Name, value)
+		} else {
+			container = container.WithoutEnvVariable(v.Name)
+		}
+	}
+	return container
+}
+
+// stageHostEnv is the StageEnv of a host-run stage, appended to the
+// process environment so it wins over the host's values.
+func (p *Pipeline) stageHostEnv(stage string) []string {
+	var env []string
+	for _, v := range p.cfg.StageEnv[stage] {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestStageEnvOverrides tests parsing STAGE_ENV_<STAGE> from the environment
+func TestStageEnvOverrides(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "CR_PAT" {
+			return "s3cr3t", true
+		}
+		return "", false
+	}
+	env, err := StageEnvOverrides([]string{
+		"STAGE_ENV_UNIT_TESTS=TZ=UTC, FEATURE_X=1,API_TOKEN=@secret:CR_PAT",
+		"STAGE_ENV_TYPECHECK=MYPYPATH=src:stubs",
+		"STAGE_ENV_LINT=",
+		"TZ=Europe/Madrid",
+	}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := env[StageUnitTests]
+	if len(env) != 2 || len(unit) != 3 || unit[0] != (StageEnvVar{Name: "TZ", Value: "UTC"}) ||
+		unit[2] != (StageEnvVar{Name: "API_TOKEN", Value: "s3cr3t", Secret: "CR_PAT"}) || env[StageTypeCheck][0].Value != "src:stubs" {
+		t.Fatalf("unexpected stage env %+v", env)
+	}
+	for value, want := range map[string]string{
+		"TZ":                    "is not KEY=VALUE",
+		"1TZ=UTC":               "is not KEY=VALUE",
+		"TOKEN=@secret:MISSING": "secret MISSING, which is not set",
+	} {
+		if _, err := StageEnvOverrides([]string{"STAGE_ENV_UNIT_TESTS=" + value}, lookup); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected an error with %q, got %v", value, want, err)
+		}
+	}
+	fmt.Println("✅ Stage environments parsed")
+}
+
+// TestStageEnvConfig tests validation, the banner and the redacted config
+func TestStageEnvConfig(t *testing.T) {
+	var out bytes.Buffer
+	env := map[string][]StageEnvVar{
+		StageUnitTests:        {{Name: "TZ", Value: "UTC"}, {Name: "API_TOKEN", Value: "s3cr3t", Secret: "CR_PAT"}},
+		StageIntegrationTests: {{Name: "FEATURE_X", Value: "1"}},
+	}
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", Output: &out, StageEnv: env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.stageBanner(StageUnitTests)
+	if !strings.Contains(out.String(), "🌱 Stage env: API_TOKEN (secret), TZ\n") || strings.Contains(out.String(), "UTC") {
+		t.Fatalf("banner should list the names only: %q", out.String())
+	}
+	if got := p.stageHostEnv(StageIntegrationTests); len(got) != 1 || got[0] != "FEATURE_X=1" {
+		t.Fatalf("unexpected host env %v", got)
+	}
+	if cfg := EffectiveConfig(p.cfg)["StageEnv"]; strings.Contains(cfg, "s3cr3t") || !strings.Contains(cfg, "API_TOKEN=@secret:CR_PAT") {
+		t.Fatalf("secret value in the effective config: %s", cfg)
+	}
+
+	for stage, vars := range map[string][]StageEnvVar{
+		StageBuild:           {{Name: "TZ", Value: "UTC"}},
+		"unit":               {{Name: "TZ", Value: "UTC"}},
+		StageUnitTests:       {{Name: cacheBusterEnv, Value: "x"}},
+		StageDependencyAudit: {{Name: "BAD NAME", Value: "x"}},
+	} {
+		_, err := New(Config{RepoName: "cert-parser", GitUser: "org", StageEnv: map[string][]StageEnvVar{stage: vars}})
+		if CategoryOf(err) != CategoryConfig {
+			t.Fatalf("%s %v should be a config error, got %v", stage, vars, err)
+		}
+	}
+	fmt.Println("✅ Stage environments validated and shown by name")
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stage names. They identify a stage in banners, failure messages, the
+// status file, the run report and PUBLISH_REQUIRES, and do not change when
+// other stages are enabled or disabled; the step number in the banner is
+// cosmetic.
+const (
+	StageUnitTests        = "unit-tests"
+	StageSlowTests        = "slow-tests"
+	StageBenchmarks       = "benchmarks"
+	StageIntegrationTests = "integration-tests"
+	StageAcceptanceTests  = "acceptance-tests"
+	StageLint             = "lint"
+	StageTypeCheck        = "typecheck"
+	StageDependencyAudit  = "dependency-audit"
+	StageExportWheels     = "export-wheels"
+	StagePackagePublish   = "package-publish"
+	StageBuild            = "build"
+	StageImageHardening   = "image-hardening"
+	StageVulnScan         = "vuln-scan"
+	StagePublish          = "publish"
+	StageDeployVerify     = "deploy-verify"
+)
+
+// StageNames lists every stage name in run order.
+var StageNames = []string{
+	StageUnitTests, StageSlowTests, StageBenchmarks, StageIntegrationTests, StageAcceptanceTests,
+	StageLint, StageTypeCheck, StageDependencyAudit, StageExportWheels, StagePackagePublish,
+	StageBuild, StageImageHardening, StageVulnScan, StagePublish, StageDeployVerify,
+}
+
+// stageTitles are the banner titles of the stages.
+var stageTitles = map[string]string{
+	StageUnitTests:        "UNIT TESTS",
+	StageSlowTests:        "SLOW TESTS",
+	StageBenchmarks:       "BENCHMARKS",
+	StageIntegrationTests: "INTEGRATION TESTS",
+	StageAcceptanceTests:  "ACCEPTANCE TESTS",
+	StageLint:             "LINT (ruff)",
+	StageTypeCheck:        "TYPE CHECK (mypy)",
+	StageDependencyAudit:  "DEPENDENCY AUDIT (pip-audit)",
+	StageExportWheels:     "EXPORT WHEELS",
+	StagePackagePublish:   "PYTHON PACKAGE (build + twine)",
+	StageBuild:            "BUILD DOCKER IMAGE",
+	StageImageHardening:   "IMAGE HARDENING CHECK",
+	StageVulnScan:         "VULNERABILITY SCAN (trivy)",
+	StagePublish:          "PUBLISH TO REGISTRY",
+	StageDeployVerify:     "DEPLOYMENT VERIFICATION",
+}
+
+// PlannedStages returns the names of the stages that print a banner in a
+// run with cfg, in run order — including the ones printed as skipped.
+// Integration and acceptance tests skipped for lack of Docker, and the
+// build and publish stages when disabled, still have a banner.
+func PlannedStages(cfg Config) []string {
+	var stages []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			stages = append(stages, name)
+		}
+	}
+	add(cfg.RunUnitTests, StageUnitTests)
+	add(cfg.RunSlowTests, StageSlowTests)
+	add(cfg.RunBenchmarks, StageBenchmarks)
+	add(cfg.RunIntegrationTests, StageIntegrationTests)
+	add(cfg.RunAcceptanceTests, StageAcceptanceTests)
+	add(cfg.RunLint, StageLint)
+	add(cfg.RunTypeCheck, StageTypeCheck)
+	add(cfg.RunDependencyAudit, StageDependencyAudit)
+	add(cfg.ExportWheels && !cfg.RunPackagePublish, StageExportWheels)
+	add(cfg.RunPackagePublish, StagePackagePublish)
+	add(true, StageBuild)
+	add(cfg.RunBuild, StageImageHardening)
+	add(cfg.RunBuild && cfg.RunVulnScan, StageVulnScan)
+	add(true, StagePublish)
+	add(cfg.RunPublish && cfg.DeployWebhook != "" && cfg.DeployVerifyURL != "", StageDeployVerify)
+	return stages
+}
+
+// stageStep is the "step X of Y" suffix of a stage banner, or "" for a
+// stage that is not planned.
+func (p *Pipeline) stageStep(name string) string {
+	planned := PlannedStages(p.cfg)
+	for i, stage := range planned {
+		if stage == name {
+			return fmt.Sprintf(" (step %d of %d)", i+1, len(planned))
+		}
+	}
+	return ""
+}
+
+// stageBanner prints the banner that opens a stage, with the user the
+// stage's commands run as where TestUser applies.
+func (p *Pipeline) stageBanner(name string) {
+	p.printf("\n%s\n", strings.Repeat("=", 80))
+	p.printf("PIPELINE STAGE %s: %s%s\n", name, stageTitles[name], p.stageStep(name))
+	p.println(strings.Repeat("=", 80))
+	if testUserStages[name] {
+		p.printf("👤 User: %s\n", p.testUserDescription())
+	}
+	if keys := p.stageEnvKeys(name); keys != "" {
+		p.printf("🌱 Stage env: %s\n", keys)
+	}
+}
+
+// stageSkippedBanner prints the banner of a stage that does not run.
+func (p *Pipeline) stageSkippedBanner(name string) {
+	p.printf("\n%s\n", strings.Repeat("=", 80))
+	p.printf("PIPELINE STAGE %s: %s — SKIPPED%s\n", name, stageTitles[name], p.stageStep(name))
+	p.println(strings.Repeat("=", 80))
+}
+
+// stageFailed prints the failure line of a stage.
+func (p *Pipeline) stageFailed(name string) {
+	p.printf("\n❌ PIPELINE FAILED AT STAGE %s: %s\n", name, stageTitles[name])
+}
+
+// stageComplete prints the success line of a stage.
+func (p *Pipeline) stageComplete(name, format string, args ...any) {
+	p.printf("✅ STAGE %s COMPLETE: %s\n", name, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestPlannedStages tests that the planned stages follow the configuration and keep their names
+func TestPlannedStages(t *testing.T) {
+	got := PlannedStages(Config{RunUnitTests: true, RunLint: true, RunBuild: true, RunVulnScan: true})
+	if want := "unit-tests,lint,build,image-hardening,vuln-scan,publish"; strings.Join(got, ",") != want {
+		t.Fatalf("PlannedStages = %q, want %s", got, want)
+	}
+	got = PlannedStages(Config{RunIntegrationTests: true, RunVulnScan: true, ExportWheels: true})
+	if want := "integration-tests,export-wheels,build,publish"; strings.Join(got, ",") != want {
+		t.Fatalf("PlannedStages without a build = %q, want %s", got, want)
+	}
+	got = PlannedStages(Config{RunPackagePublish: true, ExportWheels: true, RunBuild: true, RunPublish: true, DeployWebhook: "https://deploy", DeployVerifyURL: "https://app/version"})
+	if want := "package-publish,build,image-hardening,publish,deploy-verify"; strings.Join(got, ",") != want {
+		t.Fatalf("PlannedStages with package publish = %q, want %s", got, want)
+	}
+	for _, name := range StageNames {
+		if stageTitles[name] == "" {
+			t.Fatalf("stage %s has no banner title", name)
+		}
+	}
+	fmt.Println("✅ Planned stages follow the configuration")
+}
+
+// TestStageBanner tests that banners and result lines carry the stage name and a cosmetic step
+func TestStageBanner(t *testing.T) {
+	var out bytes.Buffer
+	p := &Pipeline{cfg: Config{RunUnitTests: true, RunLint: true}, out: &out}
+	p.stageBanner(StageLint)
+	p.stageFailed(StageLint)
+	p.stageComplete(StageUnitTests, "All %s passed", "unit tests")
+	p.stageSkippedBanner(StageBuild)
+	for _, want := range []string{
+		"PIPELINE STAGE lint: LINT (ruff) (step 2 of 4)\n",
+		"❌ PIPELINE FAILED AT STAGE lint: LINT (ruff)\n",
+		"✅ STAGE unit-tests COMPLETE: All unit tests passed\n",
+		"PIPELINE STAGE build: BUILD DOCKER IMAGE — SKIPPED (step 3 of 4)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	fmt.Println("✅ Stage banners use stable names")
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// The container-level stage implementations. Run composes them with
+// progress output, status tracking and error categories; the Dagger module
+// in ../daggermodule calls the same functions, so both invocation styles
+// run the same commands.
+
+// Marker expressions selecting each test stage's tests.
+const (
+	unitMarker        = "not integration and not acceptance"
+	integrationMarker = "integration"
+	acceptanceMarker  = "acceptance"
+	slowMarker        = "slow and not integration and not acceptance"
+	benchmarkMarker   = "benchmark and not integration and not acceptance"
+)
+
+var auditCmd = []string{"pip-audit", "--progress-spinner", "off", "--skip-editable"}
+
+// BuildEnv returns the Python build container for source: the system
+// environment (apt packages, corporate CA, proxy, caches) with the local
+// framework and the project installed.
+func (p *Pipeline) BuildEnv(client *dagger.Client, source *dagger.Directory) *dagger.Container {
+	return p.buildEnv(client, source)
+}
+
+// UnitTests runs the unit tests in builder: the tests that need no
+// external services, less the slow tests and benchmarks when they run in a
+// stage of their own, with a JUnit report and, with CHANGED_ONLY_TESTS,
+// only the selected files. Failing tests do not fail the exec; Run's unit
+// stage and TestOutput check the outcome.
+func (p *Pipeline) UnitTests(builder *dagger.Container) *dagger.Container {
+	return p.containerTests(builder, "unit", p.unitStageMarker())
+}
+
+// TestOutput is the output of a UnitTests container. Like Run's unit
+// stage it fails when tests failed or the marker selected none.
+func TestOutput(ctx context.Context, tests *dagger.Container) (string, error) {
+	out, code, err := outputAndExitCode(ctx, tests)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case NoTestsRan(out, code):
+		return "", Errorf(CategoryTest, "no tests collected:\n%s", out)
+	case code != 0:
+		return "", Errorf(CategoryTest, "%s (exit code %d):\n%s", PytestExitMeaning(code), code, out)
+	}
+	return out, nil
+}
+
+// containerJUnitPath is where a container test stage writes its JUnit report.
+func containerJUnitPath(stage string) string {
+	return "/tmp/pytest-junit/" + stage + ".xml"
+}
+
+// containerTestCmd is the pytest command of a container test stage: the
+// marker, a JUnit report and, with CHANGED_ONLY_TESTS, the selected files.
+func (p *Pipeline) containerTestCmd(stage, marker string) []string {
+	cmd := []string{"pytest", "-v", "--tb=short", "-m", marker, "--junitxml=" + containerJUnitPath(stage)}
+	return append(cmd, p.changedTests...)
+}
+
+// unitStageMarker is unitMarker without the tests that run in a stage of
+// their own.
+func (p *Pipeline) unitStageMarker() string {
+	marker := unitMarker
+	if p.cfg.RunSlowTests {
+		marker += " and not slow"
+	}
+	if p.cfg.RunBenchmarks {
+		marker += " and not benchmark"
+	}
+	return marker
+}
+
+// containerTests runs the containerTestCmd of a test stage, to completion
+// even when tests fail so the JUnit report can be exported.
+func (p *Pipeline) containerTests(builder *dagger.Container, stage, marker string) *dagger.Container {
+	return p.runToCompletion(builder, p.containerTestCmd(stage, marker))
+}
+
+// runToCompletion runs cmd as the test user, with the stage memory limit and
+// umask, without failing the exec on a non-zero exit code; callers check
+// ExitCode.
+func (p *Pipeline) runToCompletion(builder *dagger.Container, cmd []string) *dagger.Container {
+	return p.asTestUser(builder).WithExec(p.memoryLimited(p.withUmask(cmd)), dagger.ContainerWithExecOpts{
+		Expect: dagger.ReturnTypeAny,
+	})
+}
+
+// outputAndExitCode is the stdout and exit code of a runToCompletion container.
+func outputAndExitCode(ctx context.Context, container *dagger.Container) (string, int, error) {
+	out, err := container.Stdout(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	code, err := container.ExitCode(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	return out, code, nil
+}
+
+// LintCommand is the ruff command over src/ and tests/, or over files, the
+// changed files of a LINT_CHANGED_ONLY run.
+func LintCommand(files []string) []string {
+	if len(files) == 0 {
+		return []string{"ruff", "check", "src/", "tests/"}
+	}
+	return append([]string{"ruff", "check"}, files...)
+}
+
+// TypeCheckCommand is the strict mypy command over src/, or over files.
+func TypeCheckCommand(files []string) []string {
+	if len(files) == 0 {
+		return []string{"mypy", "src/", "--strict"}
+	}
+	// Imported modules are still analyzed for their types, but errors in
+	// unchanged files are not reported
+	return append([]string{"mypy", "--strict", "--follow-imports=silent"}, files...)
+}
+
+// Lint runs LintCommand in builder. Findings do not fail the exec; Run's
+// lint stage and StageOutput check the exit code.
+func (p *Pipeline) Lint(builder *dagger.Container, files []string) *dagger.Container {
+	return p.runToCompletion(builder, LintCommand(files))
+}
+
+// TypeCheck runs TypeCheckCommand in builder. Errors do not fail the exec;
+// Run's type-check stage and StageOutput check the exit code.
+func (p *Pipeline) TypeCheck(builder *dagger.Container, files []string) *dagger.Container {
+	return p.runToCompletion(builder, TypeCheckCommand(files))
+}
+
+// StageOutput is the output of a Lint or TypeCheck container, or an error
+// with the output when the tool reported findings.
+func StageOutput(ctx context.Context, stage *dagger.Container) (string, error) {
+	out, code, err := outputAndExitCode(ctx, stage)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", Errorf(CategoryLint, "exit code %d:\n%s", code, out)
+	}
+	return out, nil
+}
+
+// DependencyAudit installs pip-audit and checks the installed dependencies
+// for known vulnerabilities; the editable project itself is skipped.
+func DependencyAudit(builder *dagger.Container) *dagger.Container {
+	return builder.
+		WithExec([]string{"pip", "install", "pip-audit"}).
+		WithExec(auditCmd)
+}
+
+// BuildImage builds the application image from the Dockerfile in source,
+// for platform, or the engine's platform when it is empty, with labels
+// (imageLabels in Run, ImageLabels otherwise). With SourceDateEpoch the
+// Dockerfile receives it as the SOURCE_DATE_EPOCH build arg, which BuildKit
+// uses for file and layer timestamps, and the image gets it as its
+// OCICreatedLabel, so rebuilding the same commit gives the same image.
+func (p *Pipeline) BuildImage(source *dagger.Directory, platform string, labels map[string]string) *dagger.Container {
+	opts := dagger.DirectoryDockerBuildOpts{Platform: dagger.Platform(platform)}
+	if p.cfg.SourceDateEpoch > 0 {
+		opts.BuildArgs = []dagger.BuildArg{{Name: "SOURCE_DATE_EPOCH", Value: strconv.FormatInt(p.cfg.SourceDateEpoch, 10)}}
+		if labels == nil {
+			labels = map[string]string{OCICreatedLabel: p.buildTimestamp().Time.Format(time.RFC3339)}
+		}
+	}
+	image := source.DockerBuild(opts)
+	for _, key := range sortedLabelKeys(labels) {
+		image = image.WithLabel(key, labels[key])
+	}
+	return image
+}
+
+// PublishImage pushes image to address with the configured registry
+// credentials and returns the published reference (with digest). With
+// variants, the images of other platforms, address is a manifest list of
+// image and the variants.
+func (p *Pipeline) PublishImage(ctx context.Context, client *dagger.Client, image *dagger.Container, address string, variants ...*dagger.Container) (string, error) {
+	secretName := "password"
+	if p.registryToken != p.cfg.RegistryToken {
+		secretName = "password-refreshed"
+	}
+	password := client.SetSecret(secretName, p.registryToken)
+	return image.
+		WithRegistryAuth(registryHost(p.cfg.Registry), p.cfg.RegistryUser, password).
+		Publish(ctx, address, dagger.ContainerPublishOpts{PlatformVariants: variants})
+}
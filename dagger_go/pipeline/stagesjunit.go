@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StagesJUnitFile is the JUnit report of the pipeline stages inside the
+// artifacts directory, for dashboards that only understand JUnit.
+const StagesJUnitFile = "pipeline-stages.junit.xml"
+
+// maxFailureExcerpt bounds the failure text of a stage test case.
+const maxFailureExcerpt = 8 << 10
+
+type stagesJUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type stagesJUnitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type stagesJUnitCase struct {
+	ClassName string             `xml:"classname,attr"`
+	Name      string             `xml:"name,attr"`
+	Time      string             `xml:"time,attr"`
+	Failure   *stagesJUnitResult `xml:"failure,omitempty"`
+	Skipped   *stagesJUnitResult `xml:"skipped,omitempty"`
+}
+
+type stagesJUnitSuite struct {
+	XMLName    xml.Name              `xml:"testsuite"`
+	Name       string                `xml:"name,attr"`
+	Tests      int                   `xml:"tests,attr"`
+	Failures   int                   `xml:"failures,attr"`
+	Skipped    int                   `xml:"skipped,attr"`
+	Time       string                `xml:"time,attr"`
+	Timestamp  string                `xml:"timestamp,attr,omitempty"`
+	Properties []stagesJUnitProperty `xml:"properties>property,omitempty"`
+	Cases      []stagesJUnitCase     `xml:"testcase"`
+}
+
+// StagesJUnit renders the stage records of a run as a JUnit report: one
+// test case per stage, failed with an excerpt of its error or skipped with
+// the reason. props are name/value pairs, such as the commit, that
+// dashboards link through with; empty values are left out.
+func StagesJUnit(status Status, props [][2]string) ([]byte, error) {
+	suite := stagesJUnitSuite{
+		Name:  "pipeline-stages",
+		Tests: len(status.Stages),
+		Time:  junitSeconds(status.ElapsedSeconds),
+	}
+	if !status.StartedAt.IsZero() {
+		suite.Timestamp = status.StartedAt.UTC().Format("2006-01-02T15:04:05")
+	}
+	for _, prop := range props {
+		if prop[1] != "" {
+			suite.Properties = append(suite.Properties, stagesJUnitProperty{Name: prop[0], Value: prop[1]})
+		}
+	}
+	for _, stage := range status.Stages {
+		c := stagesJUnitCase{ClassName: "pipeline", Name: stage.Name, Time: junitSeconds(stage.DurationSeconds)}
+		switch stage.Status {
+		case StageFailed:
+			suite.Failures++
+			c.Failure = &stagesJUnitResult{Message: firstLine(stage.Message), Text: failureExcerpt(stage.Message)}
+			if stage.Exit != nil {
+				c.Failure.Type = fmt.Sprintf("exit code %d", stage.Exit.ExitCode)
+			}
+		case StageSkipped:
+			suite.Skipped++
+			c.Skipped = &stagesJUnitResult{Message: stage.Message}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// junitSeconds formats a duration in seconds the way JUnit expects.
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// firstLine is the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// failureExcerpt is msg cut to maxFailureExcerpt bytes at a line boundary.
+func failureExcerpt(msg string) string {
+	if len(msg) <= maxFailureExcerpt {
+		return msg
+	}
+	cut := msg[:maxFailureExcerpt]
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "\n… (truncated)"
+}
+
+// writeStagesJUnit writes StagesJUnitFile from the final stage records, so
+// it matches the stages of the run report.
+func (p *Pipeline) writeStagesJUnit() {
+	data, err := StagesJUnit(p.report.Status, [][2]string{
+		{"commit", p.commit},
+		{"branch", gitRefName(p.cfg)},
+		{"image_tag", p.imageTag},
+		{"state", string(p.report.Status.State)},
+	})
+	if err == nil {
+		err = os.MkdirAll(p.cfg.ArtifactsDir, 0o755)
+	}
+	path := filepath.Join(p.cfg.ArtifactsDir, StagesJUnitFile)
+	if err == nil {
+		err = WriteFileAtomic(path, data)
+	}
+	if err != nil {
+		p.warnf("stages-junit", "⚠️  Could not write %s: %v\n", StagesJUnitFile, err)
+		return
+	}
+	p.report.Artifacts = append(p.report.Artifacts, Artifact{Name: StagesJUnitFile, Path: path, Size: int64(len(data)), SHA256: sha256Hex(string(data))})
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStagesJUnit tests rendering the stage records as one JUnit test case per stage
+func TestStagesJUnit(t *testing.T) {
+	status := Status{
+		State:          RunFailed,
+		StartedAt:      time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		ElapsedSeconds: 95.5,
+		Stages: []StageRecord{
+			{Name: StageUnitTests, Status: StagePassed, DurationSeconds: 42.25},
+			{Name: StageIntegrationTests, Status: StageSkipped, Message: "Docker not available"},
+			{Name: StageLint, Status: StageFailed, DurationSeconds: 3, Message: "ruff found 2 errors\nsrc/a.py:1:1: F401", Exit: &ExitClassification{ExitCode: 1}},
+		},
+	}
+	data, err := StagesJUnit(status, [][2]string{{"commit", "abc123"}, {"branch", "main"}, {"image_tag", ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes, err := ParseJUnit(data)
+	if err != nil {
+		t.Fatalf("the report should parse as JUnit: %v\n%s", err, data)
+	}
+	want := []string{OutcomePassed, OutcomeSkipped, OutcomeFailed}
+	for i, o := range outcomes {
+		if o.Outcome != want[i] {
+			t.Fatalf("outcomes = %+v, want %v", outcomes, want)
+		}
+	}
+	xml := string(data)
+	for _, s := range []string{
+		`<testsuite name="pipeline-stages" tests="3" failures="1" skipped="1" time="95.500" timestamp="2026-03-01T12:00:00">`,
+		`<property name="commit" value="abc123">`,
+		`<testcase classname="pipeline" name="unit-tests" time="42.250">`,
+		`<failure message="ruff found 2 errors" type="exit code 1">ruff found 2 errors&#xA;src/a.py:1:1: F401</failure>`,
+		`<skipped message="Docker not available">`,
+	} {
+		if !strings.Contains(xml, s) {
+			t.Fatalf("report should contain %s:\n%s", s, xml)
+		}
+	}
+	if strings.Contains(xml, "image_tag") {
+		t.Fatalf("empty properties should be left out:\n%s", xml)
+	}
+
+	long := strings.Repeat("line of output\n", 1000)
+	if excerpt := failureExcerpt(long); len(excerpt) > maxFailureExcerpt+20 || !strings.HasSuffix(excerpt, "(truncated)") {
+		t.Fatalf("a long failure should be truncated, got %d bytes", len(excerpt))
+	}
+	fmt.Println("✅ Stage JUnit report rendered")
+}
@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CommitStatusContext prefixes the contexts of the commit statuses the run
+// posts: "pipeline" for the run, "pipeline/<stage>" for each stage.
+const CommitStatusContext = "pipeline"
+
+// maxStatusDescription is the longest description GitHub accepts.
+const maxStatusDescription = 140
+
+// CommitStatus is one GitHub commit status.
+type CommitStatus struct {
+	State       string `json:"state"` // success, failure, error or pending
+	Context     string `json:"context"`
+	Description string `json:"description"`
+}
+
+// StageCommitStatuses maps a finished run to commit statuses: one for the
+// run and one per stage. The statuses API has no neutral state, so a
+// skipped stage is pending with the reason: it never reads as passed, and a
+// branch protection rule requiring it blocks until it runs. A run that
+// passed with skips is a success that says so.
+func StageCommitStatuses(status Status, outcome RunOutcome) []CommitStatus {
+	run := CommitStatus{State: "success", Context: CommitStatusContext, Description: "Passed"}
+	var skipped []string
+	for _, stage := range status.Stages {
+		if stage.Status == StageSkipped {
+			skipped = append(skipped, stage.Name)
+		}
+	}
+	switch outcome {
+	case RunOutcomePassedWithSkips:
+		run.Description = "Passed with skips: " + strings.Join(skipped, ", ")
+	case RunOutcomeFailed:
+		run.State, run.Description = "failure", "Failed: "+status.Error
+	case RunOutcomeCancelled:
+		run.State, run.Description = "error", "Cancelled"
+	}
+
+	statuses := []CommitStatus{run}
+	for _, stage := range status.Stages {
+		s := CommitStatus{Context: CommitStatusContext + "/" + stage.Name}
+		switch stage.Status {
+		case StagePassed:
+			s.State, s.Description = "success", "Passed in "+compactDuration(time.Duration(stage.DurationSeconds*float64(time.Second)))
+		case StageFailed:
+			s.State, s.Description = "failure", "Failed: "+stage.Message
+		case StageSkipped:
+			s.State, s.Description = "pending", "Skipped: "+stage.Message
+		default:
+			s.State, s.Description = "error", "Did not finish"
+		}
+		statuses = append(statuses, s)
+	}
+	for i := range statuses {
+		if d := []rune(statuses[i].Description); len(d) > maxStatusDescription {
+			statuses[i].Description = string(d[:maxStatusDescription-1]) + "…"
+		}
+	}
+	return statuses
+}
+
+// StagesMarkdown renders the stage table of the pull request comment. A
+// skipped stage gets a row of its own kind with the reason, so it is not
+// mistaken for a pass.
+func StagesMarkdown(status Status, outcome RunOutcome, commit string) string {
+	var b strings.Builder
+	title := map[RunOutcome]string{
+		RunOutcomePassed:          "✅ Pipeline passed",
+		RunOutcomePassedWithSkips: "⚠️ Pipeline passed with skipped stages",
+		RunOutcomeFailed:          "❌ Pipeline failed",
+		RunOutcomeCancelled:       "⏹️ Pipeline cancelled",
+	}[outcome]
+	if title == "" {
+		title = "Pipeline " + string(outcome)
+	}
+	fmt.Fprintf(&b, "### %s", title)
+	if commit != "" {
+		fmt.Fprintf(&b, " (`%s`)", shortCommit(commit))
+	}
+	b.WriteString("\n\n| Stage | Result | Duration | Details |\n|---|---|---|---|\n")
+	for _, stage := range status.Stages {
+		result := map[StageStatus]string{
+			StagePassed:  "✅ passed",
+			StageFailed:  "❌ failed",
+			StageSkipped: "⏭️ skipped",
+		}[stage.Status]
+		if result == "" {
+			result = string(stage.Status)
+		}
+		duration := ""
+		if stage.Status != StageSkipped {
+			duration = compactDuration(time.Duration(stage.DurationSeconds * float64(time.Second)))
+		}
+		details := strings.NewReplacer("|", "\\|", "\n", " ").Replace(stage.Message)
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", stage.Name, result, duration, details)
+	}
+	return b.String()
+}
+
+// postGitHubJSON POSTs v as JSON to a GitHub API URL.
+func postGitHubJSON(ctx context.Context, client *http.Client, url, token string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// reportToGitHub posts the finished run as commit statuses (CommitStatus)
+// and as a pull request comment (PRComment). Both need the GitHub API and a
+// GitToken allowed to write statuses and comments; failures only warn.
+func (p *Pipeline) reportToGitHub(ctx context.Context) {
+	cfg := p.cfg
+	comment := cfg.PRComment && cfg.PullRequest != nil
+	if !cfg.CommitStatus && !comment {
+		return
+	}
+	if strings.Contains(cfg.GitHost, "gitlab") {
+		p.println("   ℹ️  Commit statuses and pull request comments need the GitHub API — not posted")
+		return
+	}
+	repoURL := fmt.Sprintf("%s/repos/%s/%s", strings.TrimSuffix(GitHubAPIBase(cfg.GitHost), "/"), url.PathEscape(cfg.GitUser), url.PathEscape(cfg.RepoName))
+	if cfg.CommitStatus && IsCommitSHA(p.commit) {
+		if err := p.postCommitStatuses(ctx, repoURL); err != nil {
+			p.warnf("commit-status", "⚠️  Could not post the commit statuses: %v\n", err)
+		}
+	}
+	if comment {
+		body := map[string]string{"body": StagesMarkdown(p.report.Status, p.report.Outcome, p.commit)}
+		if err := postGitHubJSON(ctx, cfg.HTTPClient, fmt.Sprintf("%s/issues/%d/comments", repoURL, cfg.PullRequest.Number), cfg.GitToken, body); err != nil {
+			p.warnf("pr-comment", "⚠️  Could not comment on pull request #%d: %v\n", cfg.PullRequest.Number, err)
+			return
+		}
+		p.printf("💬 Stage results commented on pull request #%d\n", cfg.PullRequest.Number)
+	}
+}
+
+// postCommitStatuses posts the run and stage statuses for the built commit,
+// stopping at the first the API rejects.
+func (p *Pipeline) postCommitStatuses(ctx context.Context, repoURL string) error {
+	statuses := StageCommitStatuses(p.report.Status, p.report.Outcome)
+	for _, status := range statuses {
+		if err := postGitHubJSON(ctx, p.cfg.HTTPClient, repoURL+"/statuses/"+p.commit, p.cfg.GitToken, status); err != nil {
+			return fmt.Errorf("%s: %w", status.Context, err)
+		}
+	}
+	p.printf("📌 %d commit status(es) posted for %s\n", len(statuses), shortCommit(p.commit))
+	return nil
+}
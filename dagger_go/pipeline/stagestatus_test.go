@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRunOutcome tests telling a run that passed with skipped stages from a clean pass
+func TestRunOutcome(t *testing.T) {
+	skips := []TestSkip{{Stage: "integration-tests", Reason: "no Docker"}}
+	cases := []struct {
+		state RunState
+		skips []TestSkip
+		want  RunOutcome
+	}{
+		{RunSucceeded, nil, RunOutcomePassed},
+		{RunSucceeded, skips, RunOutcomePassedWithSkips},
+		{RunFailed, skips, RunOutcomeFailed},
+		{RunCancelled, nil, RunOutcomeCancelled},
+		{RunRunning, nil, ""},
+	}
+	for _, c := range cases {
+		if got := Outcome(Status{State: c.state}, c.skips); got != c.want {
+			t.Fatalf("Outcome(%s, %d skips) = %q, want %q", c.state, len(c.skips), got, c.want)
+		}
+	}
+	fmt.Println("✅ Run outcome tells passed from passed with skips")
+}
+
+// TestStageCommitStatuses tests that a skipped stage never posts as a pass
+func TestStageCommitStatuses(t *testing.T) {
+	status := Status{State: RunSucceeded, Stages: []StageRecord{
+		{Name: "unit-tests", Status: StagePassed, DurationSeconds: 42},
+		{Name: "integration-tests", Status: StageSkipped, Message: "no Docker socket: " + strings.Repeat("x", 200)},
+	}}
+	statuses := StageCommitStatuses(status, RunOutcomePassedWithSkips)
+	if len(statuses) != 3 {
+		t.Fatalf("expected the run and 2 stages, got %+v", statuses)
+	}
+	if s := statuses[0]; s.Context != "pipeline" || s.State != "success" || s.Description != "Passed with skips: integration-tests" {
+		t.Fatalf("unexpected run status %+v", s)
+	}
+	if s := statuses[1]; s.Context != "pipeline/unit-tests" || s.State != "success" {
+		t.Fatalf("unexpected passed stage %+v", s)
+	}
+	s := statuses[2]
+	if s.Context != "pipeline/integration-tests" || s.State != "pending" || !strings.HasPrefix(s.Description, "Skipped: no Docker socket") {
+		t.Fatalf("unexpected skipped stage %+v", s)
+	}
+	if n := len([]rune(s.Description)); n != maxStatusDescription {
+		t.Fatalf("description should be cut to %d characters, got %d", maxStatusDescription, n)
+	}
+
+	failed := StageCommitStatuses(Status{State: RunFailed, Error: "stage unit-tests: exit 1"}, RunOutcomeFailed)
+	if failed[0].State != "failure" || failed[0].Description != "Failed: stage unit-tests: exit 1" {
+		t.Fatalf("unexpected failed run %+v", failed[0])
+	}
+	fmt.Println("✅ Skipped stages post as pending with their reason")
+}
+
+// TestStagesMarkdown tests the stage table of the pull request comment
+func TestStagesMarkdown(t *testing.T) {
+	status := Status{State: RunSucceeded, Stages: []StageRecord{
+		{Name: "unit-tests", Status: StagePassed, DurationSeconds: 42},
+		{Name: "vuln-scan", Status: StageSkipped, Message: "offline | no advisory database"},
+	}}
+	md := StagesMarkdown(status, RunOutcomePassedWithSkips, "0123456789abcdef0123456789abcdef01234567")
+	for _, want := range []string{
+		"### ⚠️ Pipeline passed with skipped stages (`0123456789ab`)",
+		"| unit-tests | ✅ passed | 42s |  |",
+		"| vuln-scan | ⏭️ skipped |  | offline \\| no advisory database |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("missing %q in:\n%s", want, md)
+		}
+	}
+	fmt.Println("✅ Skipped stages get their own row in the pull request comment")
+}
+
+// TestPostGitHubJSON tests posting statuses and comments to the GitHub API
+func TestPostGitHubJSON(t *testing.T) {
+	var got CommitStatus
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer pat":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method != http.MethodPost || r.URL.Path != "/repos/org/cert-parser/statuses/abc":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	status := CommitStatus{State: "pending", Context: "pipeline/vuln-scan", Description: "Skipped: offline"}
+	if err := postGitHubJSON(context.Background(), server.Client(), server.URL+"/repos/org/cert-parser/statuses/abc", "pat", status); err != nil {
+		t.Fatal(err)
+	}
+	if got != status {
+		t.Fatalf("posted %+v, want %+v", got, status)
+	}
+	if err := postGitHubJSON(context.Background(), server.Client(), server.URL+"/repos/org/cert-parser/statuses/abc", "", status); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected a 401 error, got %v", err)
+	}
+	fmt.Println("✅ Commit statuses posted to the GitHub API")
+}
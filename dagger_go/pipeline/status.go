@@ -0,0 +1,269 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StageStatus is the outcome of a single pipeline stage.
+type StageStatus string
+
+const (
+	StageRunning StageStatus = "running"
+	StagePassed  StageStatus = "passed"
+	StageFailed  StageStatus = "failed"
+	StageSkipped StageStatus = "skipped"
+)
+
+// RunState is the overall state of a pipeline run.
+type RunState string
+
+const (
+	RunRunning   RunState = "running"
+	RunSucceeded RunState = "succeeded"
+	RunFailed    RunState = "failed"
+	RunCancelled RunState = "cancelled"
+)
+
+// StageRecord describes one stage transition history entry.
+type StageRecord struct {
+	Name            string      `json:"name"`
+	Status          StageStatus `json:"status"`
+	StartedAt       time.Time   `json:"started_at"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Message         string      `json:"message,omitempty"`
+	// Exit classifies the exit code behind a failure, e.g. SIGKILL from the OOM killer.
+	Exit *ExitClassification `json:"exit,omitempty"`
+	// Reproduce is the local equivalent of the stage; set in the run report only.
+	Reproduce *Reproduction `json:"reproduce,omitempty"`
+}
+
+// Status is the document written to STATUS_FILE after every stage transition.
+type Status struct {
+	State          RunState      `json:"state"`
+	CurrentStage   string        `json:"current_stage,omitempty"`
+	Stages         []StageRecord `json:"stages"`
+	StartedAt      time.Time     `json:"started_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+	Error          string        `json:"error,omitempty"`
+	Skipped        string        `json:"skipped,omitempty"` // why a successful run did nothing, e.g. no new commits
+}
+
+// Tracker records stage transitions for a pipeline run and, when a status
+// file path is configured, atomically rewrites it after every transition so
+// external pollers always see a complete JSON document. Listeners registered
+// with OnTransition are notified of every stage start and stage outcome.
+type Tracker struct {
+	mu         sync.Mutex
+	statusFile string
+	startedAt  time.Time
+	state      RunState
+	current    int // index into stages of the running stage, -1 if none
+	stages     []StageRecord
+	err        string
+	skipped    string
+	listeners  []func(StageRecord)
+	now        func() time.Time
+}
+
+// NewTracker creates a tracker. An empty statusFile disables the file output
+// while still recording stage history.
+func NewTracker(statusFile string) *Tracker {
+	t := &Tracker{
+		statusFile: statusFile,
+		state:      RunRunning,
+		current:    -1,
+		now:        time.Now,
+	}
+	t.startedAt = t.now()
+	t.transition(func() []StageRecord { return nil })
+	return t
+}
+
+// OnTransition registers a listener called (outside the tracker lock) with
+// the stage record each time a stage starts, passes, fails, or is skipped.
+func (t *Tracker) OnTransition(fn func(StageRecord)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+// StartStage marks a stage as running. Any stage still running is closed as passed.
+func (t *Tracker) StartStage(name string) {
+	t.transition(func() []StageRecord {
+		events := t.closeCurrentLocked(StagePassed, "")
+		t.stages = append(t.stages, StageRecord{Name: name, Status: StageRunning, StartedAt: t.now()})
+		t.current = len(t.stages) - 1
+		return append(events, t.stages[t.current])
+	})
+}
+
+// PassStage marks the running stage as passed.
+func (t *Tracker) PassStage() {
+	t.transition(func() []StageRecord {
+		return t.closeCurrentLocked(StagePassed, "")
+	})
+}
+
+// FailStage marks the running stage as failed with the given error.
+func (t *Tracker) FailStage(err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	t.transition(func() []StageRecord {
+		if t.current >= 0 {
+			t.stages[t.current].Exit = ClassifyExit(err)
+		}
+		return t.closeCurrentLocked(StageFailed, msg)
+	})
+}
+
+// SkipStage records a stage that was not executed and why.
+func (t *Tracker) SkipStage(name, reason string) {
+	t.transition(func() []StageRecord {
+		events := t.closeCurrentLocked(StagePassed, "")
+		rec := StageRecord{Name: name, Status: StageSkipped, StartedAt: t.now(), Message: reason}
+		t.stages = append(t.stages, rec)
+		return append(events, rec)
+	})
+}
+
+// SkipRun records that the run has nothing to do and why; it still
+// finishes with Finish.
+func (t *Tracker) SkipRun(reason string) {
+	t.transition(func() []StageRecord {
+		t.skipped = reason
+		return nil
+	})
+}
+
+// Finish records the terminal state of the run. A stage still running is
+// marked failed when err is non-nil and passed otherwise.
+func (t *Tracker) Finish(err error) {
+	t.transition(func() []StageRecord {
+		switch {
+		case err == nil:
+			t.state = RunSucceeded
+			return t.closeCurrentLocked(StagePassed, "")
+		case CategoryOf(err) == CategoryCancelled:
+			t.state = RunCancelled
+		default:
+			t.state = RunFailed
+		}
+		t.err = err.Error()
+		if t.current >= 0 {
+			t.stages[t.current].Exit = ClassifyExit(err)
+		}
+		return t.closeCurrentLocked(StageFailed, err.Error())
+	})
+}
+
+// Snapshot returns a copy of the current status.
+func (t *Tracker) Snapshot() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked()
+}
+
+// transition applies a state change under the lock, rewrites the status
+// file, and then notifies listeners of the resulting stage events.
+func (t *Tracker) transition(apply func() []StageRecord) {
+	t.mu.Lock()
+	events := apply()
+	t.flushLocked()
+	listeners := append([]func(StageRecord){}, t.listeners...)
+	t.mu.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+func (t *Tracker) snapshotLocked() Status {
+	now := t.now()
+	s := Status{
+		State:          t.state,
+		Stages:         append([]StageRecord(nil), t.stages...),
+		StartedAt:      t.startedAt,
+		UpdatedAt:      now,
+		ElapsedSeconds: now.Sub(t.startedAt).Seconds(),
+		Error:          t.err,
+		Skipped:        t.skipped,
+	}
+	if t.current >= 0 {
+		s.CurrentStage = t.stages[t.current].Name
+	}
+	return s
+}
+
+// closeCurrentLocked closes the running stage, if any, and returns it as an event.
+func (t *Tracker) closeCurrentLocked(status StageStatus, msg string) []StageRecord {
+	if t.current < 0 {
+		return nil
+	}
+	rec := &t.stages[t.current]
+	rec.Status = status
+	rec.Message = msg
+	rec.DurationSeconds = t.now().Sub(rec.StartedAt).Seconds()
+	t.current = -1
+	return []StageRecord{*rec}
+}
+
+func (t *Tracker) flushLocked() {
+	if t.statusFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(t.snapshotLocked(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not encode status file: %v\n", err)
+		return
+	}
+	if err := WriteFileAtomic(t.statusFile, data); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not write status file %s: %v\n", t.statusFile, err)
+	}
+}
+
+// WriteFileAtomic writes data to a temporary file in the target directory and
+// renames it over path, so concurrent readers never observe a partial file.
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
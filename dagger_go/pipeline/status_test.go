@@ -0,0 +1,147 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func readStatus(t *testing.T, path string) Status {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read status file: %v", err)
+	}
+	var s Status
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("status file is not valid JSON: %v\n%s", err, data)
+	}
+	return s
+}
+
+// TestTrackerStageTransitions tests that every transition is reflected in status.json
+func TestTrackerStageTransitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	tracker := NewTracker(path)
+
+	if s := readStatus(t, path); s.State != RunRunning || len(s.Stages) != 0 {
+		t.Fatalf("initial status unexpected: %+v", s)
+	}
+
+	tracker.StartStage("unit-tests")
+	if s := readStatus(t, path); s.CurrentStage != "unit-tests" || s.Stages[0].Status != StageRunning {
+		t.Fatalf("expected unit-tests running, got %+v", s)
+	}
+
+	tracker.PassStage()
+	tracker.SkipStage("integration-tests", "Docker not available")
+	tracker.StartStage("lint")
+	tracker.FailStage(errors.New("ruff lint failed"))
+	tracker.Finish(Errorf(CategoryLint, "ruff lint failed"))
+
+	s := readStatus(t, path)
+	if s.State != RunFailed {
+		t.Fatalf("State = %s, want %s", s.State, RunFailed)
+	}
+	if s.CurrentStage != "" {
+		t.Fatalf("CurrentStage = %q, want empty after finish", s.CurrentStage)
+	}
+	want := []StageStatus{StagePassed, StageSkipped, StageFailed}
+	if len(s.Stages) != len(want) {
+		t.Fatalf("got %d stages, want %d", len(s.Stages), len(want))
+	}
+	for i, status := range want {
+		if s.Stages[i].Status != status {
+			t.Fatalf("stage %d (%s) = %s, want %s", i, s.Stages[i].Name, s.Stages[i].Status, status)
+		}
+	}
+	if s.Stages[1].Message != "Docker not available" {
+		t.Fatalf("skip reason not recorded: %+v", s.Stages[1])
+	}
+	fmt.Println("✅ Tracker records stage transitions in status.json")
+}
+
+// TestTrackerFinishStates tests the terminal states
+func TestTrackerFinishStates(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected RunState
+	}{
+		{nil, RunSucceeded},
+		{Errorf(CategoryPublish, "publish failed"), RunFailed},
+		{Errorf(CategoryTest, "tests: %w", context.Canceled), RunCancelled},
+	}
+	for _, tc := range tests {
+		tracker := NewTracker("")
+		tracker.StartStage("publish")
+		tracker.Finish(tc.err)
+		s := tracker.Snapshot()
+		if s.State != tc.expected {
+			t.Fatalf("Finish(%v) state = %s, want %s", tc.err, s.State, tc.expected)
+		}
+		if s.Stages[0].Status == StageRunning {
+			t.Fatal("running stage should be closed by Finish")
+		}
+	}
+	fmt.Println("✅ Tracker terminal states are correct")
+}
+
+// TestWriteFileAtomicConcurrentReads tests that pollers never see a partial file
+func TestWriteFileAtomicConcurrentReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	tracker := NewTracker(path)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					errs <- fmt.Errorf("read failed: %w", err)
+					return
+				}
+				var s Status
+				if err := json.Unmarshal(data, &s); err != nil {
+					errs <- fmt.Errorf("partial or corrupt read: %w", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		tracker.StartStage(fmt.Sprintf("stage-%d", i))
+		tracker.PassStage()
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if s := readStatus(t, path); len(s.Stages) != 200 {
+		t.Fatalf("got %d stages, want 200", len(s.Stages))
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".status.json.*.tmp"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+	fmt.Println("✅ Atomic status writes survive concurrent readers")
+}
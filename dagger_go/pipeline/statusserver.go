@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statusShutdownTimeout bounds how long in-flight status requests may take
+// once the pipeline has finished.
+const statusShutdownTimeout = 2 * time.Second
+
+// StatusListenAddr validates STATUS_LISTEN and returns the address to bind.
+// A missing host (":8088") binds to 127.0.0.1; any address that is not
+// loopback needs allowRemote, since the endpoints have no authentication.
+func StatusListenAddr(addr string, allowRemote bool) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid status address %q (use host:port or :port): %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !isLoopbackHost(host) && !allowRemote {
+		return "", fmt.Errorf("status address %q is not a loopback address; the status endpoints have no authentication, set STATUS_ALLOW_REMOTE=true to expose them", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// StatusServer serves the live status of a run over HTTP:
+//
+//	/status   the same JSON as STATUS_FILE, current at every request
+//	/report   the run report as of the last stage transition
+//	/healthz  "ok" while the pipeline runs
+type StatusServer struct {
+	server   *http.Server
+	listener net.Listener
+	tracker  *Tracker
+
+	mu     sync.Mutex
+	report []byte
+}
+
+// ListenStatus binds addr (see StatusListenAddr) and serves the tracker's
+// status until Close.
+func ListenStatus(addr string, allowRemote bool, tracker *Tracker) (*StatusServer, error) {
+	bind, err := StatusListenAddr(addr, allowRemote)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		return nil, fmt.Errorf("status server: %w", err)
+	}
+	s := &StatusServer{listener: listener, tracker: tracker}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.MarshalIndent(s.tracker.Snapshot(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, data)
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		data := s.report
+		s.mu.Unlock()
+		if data == nil {
+			http.Error(w, "no report yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, data)
+	})
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+func writeJSON(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// Addr is the address the server listens on.
+func (s *StatusServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// SetReport replaces the document served at /report. The report is encoded
+// here, by the caller's goroutine, so handlers never read it while the
+// pipeline changes it.
+func (s *StatusServer) SetReport(report *Report) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.report = data
+	s.mu.Unlock()
+}
+
+// Close stops accepting requests and waits briefly for those in flight.
+func (s *StatusServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), statusShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveStatus starts the StatusListen server for this run and keeps its
+// report current at every stage transition.
+func (p *Pipeline) serveStatus() (*StatusServer, error) {
+	server, err := ListenStatus(p.cfg.StatusListen, p.cfg.StatusAllowRemote, p.tracker)
+	if err != nil {
+		return nil, err
+	}
+	// Listeners run on the pipeline's goroutine, between its report updates
+	p.tracker.OnTransition(func(StageRecord) { server.SetReport(p.liveReport()) })
+	server.SetReport(p.liveReport())
+	p.printf("📡 Status server: http://%s/status (/report, /healthz)\n", server.Addr())
+	return server, nil
+}
+
+// liveReport is the report with the current status, as served while running.
+func (p *Pipeline) liveReport() *Report {
+	report := *p.report
+	report.Status = p.tracker.Snapshot()
+	return &report
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestStatusListenAddr tests the default bind and the refusal of remote addresses
+func TestStatusListenAddr(t *testing.T) {
+	cases := []struct {
+		addr   string
+		remote bool
+		want   string
+	}{
+		{":8088", false, "127.0.0.1:8088"},
+		{"localhost:8088", false, "localhost:8088"},
+		{"[::1]:8088", false, "[::1]:8088"},
+		{"0.0.0.0:8088", true, "0.0.0.0:8088"},
+	}
+	for _, c := range cases {
+		if got, err := StatusListenAddr(c.addr, c.remote); err != nil || got != c.want {
+			t.Fatalf("StatusListenAddr(%q, %v) = %q, %v; want %q", c.addr, c.remote, got, err, c.want)
+		}
+	}
+	for _, bad := range []string{"0.0.0.0:8088", "10.1.2.3:8088", "runner.corp:8088", "8088"} {
+		if _, err := StatusListenAddr(bad, false); err == nil {
+			t.Fatalf("%q should be refused without STATUS_ALLOW_REMOTE", bad)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", StatusListen: "0.0.0.0:8088"}); err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a remote address should be a configuration error, got %v", err)
+	}
+	fmt.Println("✅ Status address bound to loopback unless allowed")
+}
+
+// TestStatusServer tests the endpoints during a simulated run
+func TestStatusServer(t *testing.T) {
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", StatusListen: "127.0.0.1:0", Output: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := p.serveStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + server.Addr() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get("/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("/healthz = %d %q", code, body)
+	}
+	p.tracker.StartStage("unit-tests")
+	p.tracker.PassStage()
+	p.tracker.StartStage("lint")
+	p.report.Lint = append(p.report.Lint, LintFindings{Tool: "ruff", Total: 3})
+
+	var status Status
+	if code, body := get("/status"); code != http.StatusOK || json.Unmarshal([]byte(body), &status) != nil {
+		t.Fatalf("/status = %d %q", code, body)
+	}
+	if status.CurrentStage != "lint" || len(status.Stages) != 2 || status.Stages[0].Status != StagePassed {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	// The report is taken at transitions: the lint findings appear once the stage ends
+	if _, body := get("/report"); strings.Contains(body, `"ruff"`) {
+		t.Fatalf("report changed between transitions: %s", body)
+	}
+	p.tracker.PassStage()
+	var report Report
+	if code, body := get("/report"); code != http.StatusOK || json.Unmarshal([]byte(body), &report) != nil {
+		t.Fatalf("/report = %d %q", code, body)
+	}
+	if len(report.Lint) != 1 || len(report.Status.Stages) != 2 || report.Status.Stages[1].Status != StagePassed {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + server.Addr() + "/healthz"); err == nil {
+		t.Fatal("the server should be shut down")
+	}
+	fmt.Println("✅ Status endpoints serve the live run")
+}
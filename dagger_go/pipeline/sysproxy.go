@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PROXY_AUTODETECT modes: what the corporate binary does with a proxy found
+// in the OS settings while HTTP_PROXY and HTTPS_PROXY are unset.
+const (
+	ProxyAutodetectAsk   = "ask"   // prompt on a terminal, warn otherwise (default)
+	ProxyAutodetectAdopt = "adopt" // use it, with a log line
+	ProxyAutodetectOff   = "off"   // do not look
+)
+
+// ValidProxyAutodetect reports whether mode is a PROXY_AUTODETECT value.
+func ValidProxyAutodetect(mode string) bool {
+	switch mode {
+	case ProxyAutodetectAsk, ProxyAutodetectAdopt, ProxyAutodetectOff:
+		return true
+	}
+	return false
+}
+
+// SystemProxy is a proxy configured at the OS level. PACURL is reported but
+// not evaluated, so a PAC-only setup has no URL to adopt.
+type SystemProxy struct {
+	Source string // where it was read, e.g. "scutil --proxy"
+	URL    string // http://host:port, empty when only a PAC file is configured
+	PACURL string
+}
+
+// Found reports whether any proxy setting was detected.
+func (s SystemProxy) Found() bool {
+	return s.URL != "" || s.PACURL != ""
+}
+
+// CommandRunner runs a command and returns its standard output.
+type CommandRunner func(name string, args ...string) (string, error)
+
+// RunCommand is the CommandRunner used outside tests.
+func RunCommand(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	return string(out), err
+}
+
+// winINetKey holds the per-user proxy settings on Windows.
+const winINetKey = `HKCU\Software\Microsoft\Windows\CurrentVersion\Internet Settings`
+
+// DetectSystemProxy reads the OS proxy settings on macOS (scutil) and
+// Windows (WinINET registry values, then the WinHTTP proxy). Other
+// systems, and any command that fails, detect nothing: this is best effort.
+func DetectSystemProxy(goos string, run CommandRunner) SystemProxy {
+	switch goos {
+	case "darwin":
+		if out, err := run("scutil", "--proxy"); err == nil {
+			return ParseScutilProxy(out)
+		}
+	case "windows":
+		if out, err := run("reg", "query", winINetKey); err == nil {
+			if proxy := ParseWinINetProxy(out); proxy.Found() {
+				return proxy
+			}
+		}
+		if out, err := run("netsh", "winhttp", "show", "proxy"); err == nil {
+			return ParseNetshProxy(out)
+		}
+	}
+	return SystemProxy{}
+}
+
+// scutilValues parses the "Key : Value" lines of scutil --proxy.
+func scutilValues(out string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, " : ")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// ParseScutilProxy reads the HTTPS (preferred) or HTTP proxy and the PAC
+// URL from `scutil --proxy` output.
+func ParseScutilProxy(out string) SystemProxy {
+	values := scutilValues(out)
+	proxy := SystemProxy{Source: "scutil --proxy"}
+	for _, scheme := range []string{"HTTPS", "HTTP"} {
+		if values[scheme+"Enable"] == "1" && values[scheme+"Proxy"] != "" {
+			proxy.URL = proxyURL(values[scheme+"Proxy"], values[scheme+"Port"])
+			break
+		}
+	}
+	if values["ProxyAutoConfigEnable"] == "1" {
+		proxy.PACURL = values["ProxyAutoConfigURLString"]
+	}
+	return proxy
+}
+
+// regValues parses the "Name    REG_TYPE    Data" lines of reg query.
+func regValues(out string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.HasPrefix(fields[1], "REG_") {
+			values[fields[0]] = strings.Join(fields[2:], " ")
+		}
+	}
+	return values
+}
+
+// ParseWinINetProxy reads ProxyServer (when ProxyEnable is set) and
+// AutoConfigURL from `reg query` output of the Internet Settings key.
+func ParseWinINetProxy(out string) SystemProxy {
+	values := regValues(out)
+	proxy := SystemProxy{Source: "Windows Internet Settings", PACURL: values["AutoConfigURL"]}
+	if values["ProxyEnable"] == "0x1" {
+		proxy.URL = winProxyServer(values["ProxyServer"])
+	}
+	return proxy
+}
+
+// ParseNetshProxy reads the WinHTTP proxy from `netsh winhttp show proxy`.
+func ParseNetshProxy(out string) SystemProxy {
+	proxy := SystemProxy{Source: "netsh winhttp"}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.HasPrefix(strings.TrimSpace(key), "Proxy Server") {
+			proxy.URL = winProxyServer(strings.TrimSpace(value))
+		}
+	}
+	return proxy
+}
+
+// winProxyServer turns a Windows proxy list — "host:port" or per-scheme
+// "http=host:port;https=host:port" — into one proxy URL, preferring https.
+func winProxyServer(server string) string {
+	if server == "" {
+		return ""
+	}
+	if !strings.Contains(server, "=") {
+		return proxyURL(server, "")
+	}
+	byScheme := map[string]string{}
+	for _, entry := range strings.Split(server, ";") {
+		scheme, addr, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		byScheme[strings.ToLower(scheme)] = addr
+	}
+	for _, scheme := range []string{"https", "http"} {
+		if byScheme[scheme] != "" {
+			return proxyURL(byScheme[scheme], "")
+		}
+	}
+	return ""
+}
+
+// proxyURL adds the http:// scheme, and the port when given separately.
+func proxyURL(host, port string) string {
+	if port != "" && port != "0" {
+		host = fmt.Sprintf("%s:%s", host, port)
+	}
+	if strings.Contains(host, "://") {
+		return host
+	}
+	return "http://" + host
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestParseScutilProxy tests reading the macOS proxy and PAC settings
+func TestParseScutilProxy(t *testing.T) {
+	out := `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+  }
+  HTTPEnable : 1
+  HTTPPort : 3128
+  HTTPProxy : proxy.corp.example
+  HTTPSEnable : 1
+  HTTPSPort : 8443
+  HTTPSProxy : secure-proxy.corp.example
+  ProxyAutoConfigEnable : 1
+  ProxyAutoConfigURLString : http://wpad.corp.example/proxy.pac
+}`
+	proxy := ParseScutilProxy(out)
+	if proxy.URL != "http://secure-proxy.corp.example:8443" {
+		t.Fatalf("HTTPS proxy not preferred: %+v", proxy)
+	}
+	if proxy.PACURL != "http://wpad.corp.example/proxy.pac" {
+		t.Fatalf("PAC URL not read: %+v", proxy)
+	}
+	if proxy := ParseScutilProxy("<dictionary> {\n  HTTPEnable : 0\n}"); proxy.Found() {
+		t.Fatalf("disabled proxy detected: %+v", proxy)
+	}
+	fmt.Println("✅ macOS proxy settings parsed")
+}
+
+// TestParseWindowsProxy tests the WinINET registry and WinHTTP outputs
+func TestParseWindowsProxy(t *testing.T) {
+	reg := `
+HKEY_CURRENT_USER\Software\Microsoft\Windows\CurrentVersion\Internet Settings
+    ProxyEnable    REG_DWORD    0x1
+    ProxyServer    REG_SZ    http=proxy.corp.example:8080;https=proxy.corp.example:8443
+    AutoConfigURL    REG_SZ    http://wpad.corp.example/proxy.pac
+`
+	proxy := ParseWinINetProxy(reg)
+	if proxy.URL != "http://proxy.corp.example:8443" || proxy.PACURL != "http://wpad.corp.example/proxy.pac" {
+		t.Fatalf("unexpected WinINET proxy: %+v", proxy)
+	}
+
+	netsh := `
+Current WinHTTP proxy settings:
+
+    Proxy Server(s) :  proxy.corp.example:8080
+    Bypass List     :  (none)
+`
+	if proxy := ParseNetshProxy(netsh); proxy.URL != "http://proxy.corp.example:8080" {
+		t.Fatalf("unexpected WinHTTP proxy: %+v", proxy)
+	}
+	if proxy := ParseNetshProxy("\n    Direct access (no proxy server).\n"); proxy.Found() {
+		t.Fatalf("direct access detected as a proxy: %+v", proxy)
+	}
+	fmt.Println("✅ Windows proxy settings parsed")
+}
+
+// TestDetectSystemProxy tests the per-OS commands and the WinHTTP fallback
+func TestDetectSystemProxy(t *testing.T) {
+	run := func(name string, args ...string) (string, error) {
+		switch name {
+		case "reg":
+			return "    ProxyEnable    REG_DWORD    0x0\n", nil
+		case "netsh":
+			return "    Proxy Server(s) :  winhttp.corp.example:3128\n", nil
+		}
+		return "", errors.New("not found")
+	}
+	if proxy := DetectSystemProxy("windows", run); proxy.URL != "http://winhttp.corp.example:3128" {
+		t.Fatalf("WinHTTP fallback not used: %+v", proxy)
+	}
+	if proxy := DetectSystemProxy("darwin", run); proxy.Found() {
+		t.Fatalf("failed scutil should detect nothing: %+v", proxy)
+	}
+	if proxy := DetectSystemProxy("linux", run); proxy.Found() {
+		t.Fatalf("linux should detect nothing: %+v", proxy)
+	}
+	fmt.Println("✅ System proxy detection is best effort per OS")
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// TestImageSuffix is appended to the image name for the test-runner image:
+// ghcr.io/org/cert-parser-tests:<tag> next to ghcr.io/org/cert-parser:<tag>.
+const TestImageSuffix = "-tests"
+
+// testImageCmd is the test-runner image's default command: the acceptance
+// tests against ACCEPTANCE_BASE_URL.
+var testImageCmd = []string{"pytest", "-v", "--tb=short", "-m", acceptanceMarker}
+
+// TestImageRef is the test-runner image reference for an image reference:
+// the same registry, repository name plus TestImageSuffix and the same tag.
+func TestImageRef(image string) string {
+	repo, tag := image, ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i:]
+	}
+	return repo + TestImageSuffix + tag
+}
+
+// testImage turns the build environment (source, dev dependencies and tests
+// installed) into the test-runner image. The source is copied in instead of
+// mounted so it is part of the image, the proxy and cache-buster variables
+// of the CI run are dropped, and the image gets the labels of app. By
+// default it runs the acceptance tests against ACCEPTANCE_BASE_URL:
+//
+//	docker run -e ACCEPTANCE_BASE_URL=https://staging.example.com ghcr.io/org/cert-parser-tests:<tag>
+func (p *Pipeline) testImage(ctx context.Context, builder, app *dagger.Container, source *dagger.Directory) (*dagger.Container, error) {
+	image := builder.
+		WithoutMount(AppWorkdir).
+		WithDirectory(AppWorkdir, source).
+		WithWorkdir(AppWorkdir)
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy", "NO_PROXY", "no_proxy", cacheBusterEnv} {
+		image = image.WithoutEnvVariable(name)
+	}
+	image = image.
+		WithEnvVariable(AcceptanceTargetEnv, "image").
+		WithoutEntrypoint().
+		WithDefaultArgs(testImageCmd)
+
+	labels, err := app.Labels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read image labels: %w", err)
+	}
+	for _, label := range labels {
+		name, err := label.Name(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value, err := label.Value(ctx)
+		if err != nil {
+			return nil, err
+		}
+		image = image.WithLabel(name, value)
+	}
+	return image, nil
+}
+
+// publishTestImage builds and publishes the test-runner image with the
+// versioned tag only; it never gets latest.
+func (p *Pipeline) publishTestImage(ctx context.Context, client *dagger.Client, builder, app *dagger.Container, source *dagger.Directory, versionedImage string) error {
+	address := TestImageRef(versionedImage)
+	p.printf("🧪 Publishing test image: %s\n", address)
+	image, err := p.testImage(ctx, builder, app, source)
+	if err != nil {
+		return Errorf(CategoryPublish, "failed to build test image: %w", err)
+	}
+	published, err := p.publishWithReauth(ctx, client, image, address)
+	if err != nil {
+		return Errorf(CategoryPublish, "failed to publish test image: %w%s", err, p.publishPermissionHint(err))
+	}
+	p.report.TestImage = published
+	p.events.Emit(Event{Type: EventPublished, Image: published})
+	return nil
+}
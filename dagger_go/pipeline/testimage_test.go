@@ -0,0 +1,24 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestTestImageRef tests naming the test-runner image after the application image
+func TestTestImageRef(t *testing.T) {
+	cases := map[string]string{
+		"ghcr.io/org/cert-parser:v0.1.0-abc1234-20261017-0930": "ghcr.io/org/cert-parser-tests:v0.1.0-abc1234-20261017-0930",
+		"registry.local:5000/org/app:v1":                       "registry.local:5000/org/app-tests:v1",
+		"registry.local:5000/org/app":                          "registry.local:5000/org/app-tests",
+	}
+	for image, want := range cases {
+		if got := TestImageRef(image); got != want {
+			t.Fatalf("TestImageRef(%q) = %q, want %q", image, got, want)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", RunBuild: true, PublishTestImage: true}); CategoryOf(err) != CategoryConfig {
+		t.Fatalf("PublishTestImage without RunPublish should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Test image named <image>-tests with the same tag")
+}
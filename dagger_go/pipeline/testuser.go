@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// testUserName and testUserHome are the account created for TestUser when
+// the image has none with that UID.
+const (
+	testUserName = "pipeline"
+	testUserHome = "/home/pipeline"
+)
+
+var umaskPattern = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// testUserStages are the stages whose commands run as TestUser.
+var testUserStages = map[string]bool{
+	StageUnitTests: true, StageSlowTests: true, StageBenchmarks: true,
+	StageLint: true, StageTypeCheck: true,
+}
+
+// ParseTestUser parses TEST_AS_USER: a numeric UID, optionally with a GID
+// ("1000" or "1000:1000"). The GID defaults to the UID.
+func ParseTestUser(value string) (uid, gid int, err error) {
+	u, g, hasGroup := strings.Cut(value, ":")
+	if !hasGroup {
+		g = u
+	}
+	uid, uerr := strconv.Atoi(u)
+	gid, gerr := strconv.Atoi(g)
+	if uerr != nil || gerr != nil || uid < 0 || gid < 0 {
+		return 0, 0, fmt.Errorf("invalid user %q (use UID or UID:GID, e.g. 1000:1000)", value)
+	}
+	return uid, gid, nil
+}
+
+// ValidUmask reports whether value is an octal umask such as 027 or 0022.
+func ValidUmask(value string) bool {
+	return umaskPattern.MatchString(value)
+}
+
+// testUserSetupCmd creates the group and user for uid:gid unless they
+// exist, and hands the workdir and a home directory to them.
+func testUserSetupCmd(uid, gid int) []string {
+	script := fmt.Sprintf(
+		"getent group %[2]d >/dev/null || groupadd -g %[2]d %[3]s; "+
+			"getent passwd %[1]d >/dev/null || useradd -u %[1]d -g %[2]d -M -d %[4]s -s /bin/sh %[3]s; "+
+			"mkdir -p %[4]s && chown -R %[1]d:%[2]d %[4]s %[5]s",
+		uid, gid, testUserName, testUserHome, AppWorkdir)
+	return []string{"sh", "-c", script}
+}
+
+// asTestUser switches container to TestUser for a test or lint command,
+// chowning the workdir first so files root created since are covered too.
+func (p *Pipeline) asTestUser(container *dagger.Container) *dagger.Container {
+	if p.cfg.TestUser == "" {
+		return container
+	}
+	uid, gid, _ := ParseTestUser(p.cfg.TestUser)
+	return container.
+		WithExec(testUserSetupCmd(uid, gid)).
+		WithUser(fmt.Sprintf("%d:%d", uid, gid)).
+		WithEnvVariable("HOME", testUserHome)
+}
+
+// asRoot switches a container asTestUser returned back to root for the
+// stages that install packages.
+func (p *Pipeline) asRoot(container *dagger.Container) *dagger.Container {
+	if p.cfg.TestUser == "" {
+		return container
+	}
+	return container.WithUser("root").WithoutEnvVariable("HOME")
+}
+
+// withUmask wraps a test or lint command so the files it creates get
+// TestUmask.
+func (p *Pipeline) withUmask(cmd []string) []string {
+	if p.cfg.TestUmask == "" {
+		return cmd
+	}
+	return append([]string{"sh", "-c", fmt.Sprintf(`umask %s && exec "$@"`, p.cfg.TestUmask), "sh"}, cmd...)
+}
+
+// testUserDescription is the effective user of the test and lint commands,
+// as the stage banner shows it.
+func (p *Pipeline) testUserDescription() string {
+	user := "root"
+	if p.cfg.TestUser != "" {
+		uid, gid, _ := ParseTestUser(p.cfg.TestUser)
+		user = fmt.Sprintf("UID %d, GID %d (TEST_AS_USER)", uid, gid)
+	}
+	if p.cfg.TestUmask != "" {
+		user += ", umask " + p.cfg.TestUmask
+	}
+	return user
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestParseTestUser tests parsing TEST_AS_USER and validating the umask
+func TestParseTestUser(t *testing.T) {
+	for value, want := range map[string][2]int{"1000": {1000, 1000}, "1000:2000": {1000, 2000}, "0:0": {0, 0}} {
+		uid, gid, err := ParseTestUser(value)
+		if err != nil || uid != want[0] || gid != want[1] {
+			t.Fatalf("ParseTestUser(%q) = %d, %d, %v", value, uid, gid, err)
+		}
+	}
+	for _, value := range []string{"app", "1000:app", "-1", ""} {
+		if _, _, err := ParseTestUser(value); err == nil {
+			t.Fatalf("ParseTestUser(%q) should fail", value)
+		}
+	}
+	for value, want := range map[string]bool{"027": true, "0022": true, "77": false, "089": false, "u=rwx": false} {
+		if ValidUmask(value) != want {
+			t.Fatalf("ValidUmask(%q) = %v", value, !want)
+		}
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", TestUser: "app"}); err == nil || CategoryOf(err) != CategoryConfig {
+		t.Fatalf("a named TestUser should be a config error, got %v", err)
+	}
+	fmt.Println("✅ Test user parsed")
+}
+
+// TestTestUserCommands tests the user setup, the umask wrapper and the banner line
+func TestTestUserCommands(t *testing.T) {
+	setup := strings.Join(testUserSetupCmd(1000, 1000), " ")
+	for _, want := range []string{"groupadd -g 1000", "useradd -u 1000 -g 1000", "chown -R 1000:1000 " + testUserHome + " " + AppWorkdir} {
+		if !strings.Contains(setup, want) {
+			t.Fatalf("setup should contain %q: %s", want, setup)
+		}
+	}
+
+	var out bytes.Buffer
+	p := &Pipeline{cfg: Config{RunUnitTests: true, TestUser: "1000:1000", TestUmask: "027"}, out: &out}
+	cmd := p.withUmask([]string{"pytest", "-m", "unit"})
+	if strings.Join(cmd, " ") != `sh -c umask 027 && exec "$@" sh pytest -m unit` {
+		t.Fatalf("withUmask = %q", cmd)
+	}
+	p.stageBanner(StageUnitTests)
+	p.stageBanner(StageBuild)
+	if got := strings.Count(out.String(), "👤 User: UID 1000, GID 1000 (TEST_AS_USER), umask 027\n"); got != 1 {
+		t.Fatalf("only the unit-test banner should state the user (%d):\n%s", got, out.String())
+	}
+
+	r := p.reproduction(StageUnitTests)
+	if r.User != "1000:1000" || !strings.Contains(r.Docker, "setpriv --reuid=1000 --regid=1000 --clear-groups sh -c") {
+		t.Fatalf("reproduction should run as the test user: %+v", r)
+	}
+
+	plain := &Pipeline{cfg: Config{}}
+	if got := plain.testUserDescription(); got != "root" {
+		t.Fatalf("testUserDescription without TestUser = %q", got)
+	}
+	fmt.Println("✅ Test user commands")
+}
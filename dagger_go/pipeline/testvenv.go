@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// TestVenvDir is where ExportTestVenv puts the exported environment, under
+// ArtifactsDir, unless VenvDir is set.
+const TestVenvDir = "test-venv"
+
+// containerTestVenv is where the environment is built in the container.
+const containerTestVenv = "/opt/test-venv"
+
+// pythonPlatformScript prints the machine and the Python major.minor.
+const pythonPlatformScript = `import platform, sys; print(platform.machine(), "%d.%d" % sys.version_info[:2])`
+
+// machineArch maps platform.machine() names to GOARCH names.
+func machineArch(machine string) string {
+	switch strings.ToLower(machine) {
+	case "x86_64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	}
+	return strings.ToLower(machine)
+}
+
+// VenvCompatible reports whether a virtual environment built in the Linux
+// build container can run on the host: compiled wheels need the same OS and
+// architecture, and site-packages the same Python minor version. The
+// platforms are "<machine> <major.minor>" as printed by pythonPlatformScript.
+func VenvCompatible(hostOS, hostPlatform, containerPlatform string) (bool, string) {
+	hostMachine, hostPython, _ := strings.Cut(strings.TrimSpace(hostPlatform), " ")
+	machine, python, _ := strings.Cut(strings.TrimSpace(containerPlatform), " ")
+	switch {
+	case hostOS != "linux":
+		return false, fmt.Sprintf("container is linux/%s, host is %s/%s", machineArch(machine), hostOS, machineArch(hostMachine))
+	case machineArch(hostMachine) != machineArch(machine):
+		return false, fmt.Sprintf("container is linux/%s, host is linux/%s", machineArch(machine), machineArch(hostMachine))
+	case hostPython != python:
+		return false, fmt.Sprintf("container has Python %s, host python3 is %s", python, hostPython)
+	}
+	return true, ""
+}
+
+// exportTestVenv builds a virtual environment with the exact packages of the
+// build container, exports it to the artifacts directory and points it at
+// the host's python3, so the host test stages run with the same dependency
+// versions as the container ones. The project and local framework are then
+// installed editable from ProjectRoot. On any problem the host stages fall
+// back to the project's .venv with a warning.
+func (p *Pipeline) exportTestVenv(ctx context.Context, builder *dagger.Container) {
+	p.println("📦 Exporting the build environment for host tests (EXPORT_TEST_VENV=true)...")
+	fallback := func(format string, args ...any) {
+		p.warnf("test-venv", "   ⚠️  "+format+" — host tests use the project's .venv\n", args...)
+	}
+
+	containerPlatform, err := builder.WithExec([]string{"python", "-c", pythonPlatformScript}).Stdout(ctx)
+	if err != nil {
+		fallback("could not read the container platform: %v", err)
+		return
+	}
+	hostPlatform, err := exec.CommandContext(ctx, "python3", "-c", pythonPlatformScript).Output()
+	if err != nil {
+		fallback("could not run python3 on the host: %v", err)
+		return
+	}
+	if ok, reason := VenvCompatible(runtime.GOOS, string(hostPlatform), containerPlatform); !ok {
+		fallback("%s", reason)
+		return
+	}
+
+	root, err := filepath.Abs(p.cfg.ProjectRoot)
+	if err != nil {
+		fallback("%v", err)
+		return
+	}
+	dir, err := filepath.Abs(p.cfg.VenvDir)
+	if err != nil {
+		fallback("%v", err)
+		return
+	}
+	// Editable installs point into /app, so they are installed again on the host
+	venv := builder.WithExec([]string{"sh", "-c", strings.Join([]string{
+		"pip freeze --all --exclude-editable > /tmp/test-venv-requirements.txt",
+		"python -m venv " + containerTestVenv,
+		containerTestVenv + "/bin/pip install --no-deps -r /tmp/test-venv-requirements.txt",
+	}, " && ")}).Directory(containerTestVenv)
+	if _, err := venv.Export(ctx, dir, dagger.DirectoryExportOpts{Wipe: true}); err != nil {
+		fallback("export failed: %v", err)
+		return
+	}
+
+	// The interpreter links still point into the container; venv --upgrade
+	// recreates them for the host's python3
+	links, _ := filepath.Glob(filepath.Join(dir, "bin", "python*"))
+	for _, link := range links {
+		os.Remove(link)
+	}
+	steps := [][]string{{"python3", "-m", "venv", "--upgrade", dir}}
+	python := filepath.Join(dir, "bin", "python")
+	install := []string{python, "-m", "pip", "install", "--quiet", "--no-deps", "--no-build-isolation"}
+	install = append(install, p.frameworkInstallArgs(root)...)
+	steps = append(steps, append(install, "-e", root))
+	for _, step := range steps {
+		cmd := exec.CommandContext(ctx, step[0], step[1:]...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			p.println(strings.TrimSpace(string(out)))
+			fallback("%s failed: %v", strings.Join(step[:3], " "), err)
+			return
+		}
+	}
+
+	p.testVenv = dir
+	p.printf("   ✅ Host tests will use %s (%s)\n", dir, strings.TrimSpace(containerPlatform))
+}
+
+// hostPytest is the pytest command for the host test stages: the exported
+// build environment, the project's .venv, or pytest from PATH.
+func (p *Pipeline) hostPytest(root string) []string {
+	if p.testVenv != "" {
+		return []string{filepath.Join(p.testVenv, "bin", "python"), "-m", "pytest"}
+	}
+	bin := filepath.Join(root, ".venv", "bin", "pytest")
+	if _, err := os.Stat(bin); err == nil {
+		return []string{bin}
+	}
+	return []string{"pytest"}
+}
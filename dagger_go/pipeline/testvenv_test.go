@@ -0,0 +1,31 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestVenvCompatible tests the platform and Python checks for exporting the build environment
+func TestVenvCompatible(t *testing.T) {
+	if ok, reason := VenvCompatible("linux", "x86_64 3.14\n", "x86_64 3.14\n"); !ok {
+		t.Fatalf("same platform rejected: %s", reason)
+	}
+	if ok, _ := VenvCompatible("linux", "aarch64 3.14", "arm64 3.14"); !ok {
+		t.Fatal("aarch64 and arm64 should be the same architecture")
+	}
+	cases := []struct {
+		hostOS, host, container, want string
+	}{
+		{"darwin", "arm64 3.14", "aarch64 3.14", "host is darwin/arm64"},
+		{"linux", "aarch64 3.14", "x86_64 3.14", "container is linux/amd64, host is linux/arm64"},
+		{"linux", "x86_64 3.12", "x86_64 3.14", "host python3 is 3.12"},
+	}
+	for _, c := range cases {
+		ok, reason := VenvCompatible(c.hostOS, c.host, c.container)
+		if ok || !strings.Contains(reason, c.want) {
+			t.Fatalf("VenvCompatible(%q, %q, %q) = %v, %q; want mismatch mentioning %q", c.hostOS, c.host, c.container, ok, reason, c.want)
+		}
+	}
+	fmt.Println("✅ Build environment export limited to matching platforms")
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// DaggerTiming is how long one Dagger operation took, recorded with
+// TimingDetail.
+type DaggerTiming struct {
+	Operation string  `json:"operation"`
+	Seconds   float64 `json:"seconds"`
+	Outcome   string  `json:"outcome"` // ok or error
+}
+
+// recordDaggerTiming adds a measured operation to the report and prints it.
+func (p *Pipeline) recordDaggerTiming(operation string, elapsed time.Duration, err error) {
+	timing := DaggerTiming{Operation: operation, Seconds: elapsed.Seconds(), Outcome: "ok"}
+	suffix := ""
+	if err != nil {
+		timing.Outcome, suffix = "error", " (failed)"
+	}
+	p.report.DaggerTimings = append(p.report.DaggerTimings, timing)
+	p.printf("   ⏱️  Dagger %s: %.2fs%s\n", operation, timing.Seconds, suffix)
+}
+
+// timeDagger runs op, a call that already evaluates the Dagger pipeline,
+// and records how long it took when TimingDetail is set.
+func (p *Pipeline) timeDagger(operation string, op func() error) error {
+	if !p.cfg.TimingDetail {
+		return op()
+	}
+	start := time.Now()
+	err := op()
+	p.recordDaggerTiming(operation, time.Since(start), err)
+	return err
+}
+
+// syncPoint forces evaluation of a lazy Dagger object with TimingDetail, so
+// its time is not blurred into whichever call happens to evaluate it first.
+// Without TimingDetail it does nothing. A failure is only recorded; the
+// call that normally evaluates the object reports it as usual.
+func (p *Pipeline) syncPoint(ctx context.Context, operation string, sync func(context.Context) error) {
+	if !p.cfg.TimingDetail {
+		return
+	}
+	p.timeDagger(operation, func() error { return sync(ctx) })
+}
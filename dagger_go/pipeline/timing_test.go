@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDaggerTiming tests that Dagger operations are timed and synced only with TimingDetail
+func TestDaggerTiming(t *testing.T) {
+	var out bytes.Buffer
+	p := &Pipeline{out: &out, report: &Report{}}
+	synced := false
+	p.syncPoint(context.Background(), "git tree", func(context.Context) error { synced = true; return nil })
+	if err := p.timeDagger("publish", func() error { return nil }); err != nil || synced {
+		t.Fatalf("without TimingDetail nothing is forced: synced=%v err=%v", synced, err)
+	}
+	if len(p.report.DaggerTimings) != 0 || out.Len() != 0 {
+		t.Fatalf("without TimingDetail nothing is recorded: %+v %q", p.report.DaggerTimings, out.String())
+	}
+
+	p.cfg.TimingDetail = true
+	p.syncPoint(context.Background(), "git tree", func(context.Context) error { synced = true; return errors.New("clone failed") })
+	err := p.timeDagger("docker build", func() error { time.Sleep(10 * time.Millisecond); return nil })
+	if err != nil || !synced {
+		t.Fatalf("TimingDetail should force the sync point: synced=%v err=%v", synced, err)
+	}
+	timings := p.report.DaggerTimings
+	if len(timings) != 2 || timings[0].Outcome != "error" || timings[1].Outcome != "ok" || timings[1].Seconds < 0.01 {
+		t.Fatalf("unexpected timings: %+v", timings)
+	}
+	if !strings.Contains(out.String(), "Dagger git tree:") || !strings.Contains(out.String(), "(failed)") {
+		t.Fatalf("timings should be printed: %s", out.String())
+	}
+
+	if _, ok := EffectiveConfig(Config{ConnectTime: time.Second})["ConnectTime"]; ok {
+		t.Fatal("the measured connect time must not be recorded as configuration")
+	}
+	fmt.Println("✅ Dagger operation timing recorded with TIMING_DETAIL only")
+}
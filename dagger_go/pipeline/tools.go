@@ -0,0 +1,278 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// ToolImageEnvPrefix is the prefix of the per-tool image override variables,
+// e.g. TOOL_IMAGE_TRIVY=mirror.corp.example/aquasec/trivy:0.58.1.
+const ToolImageEnvPrefix = "TOOL_IMAGE_"
+
+// ToolImage is an auxiliary container used by a stage (scanners, linters,
+// signing), pinned so runs are reproducible and mirrors can be verified.
+type ToolImage struct {
+	Name   string // lower-case key, matches TOOL_IMAGE_<NAME>
+	Image  string // repository:tag
+	Digest string // manifest digest (sha256:…); empty until refreshed with --update-tool-pins
+}
+
+// ToolImages is the single table of auxiliary tool images. Refresh the
+// digests with `--update-tool-pins` and commit the printed table.
+var ToolImages = []ToolImage{
+	{Name: "cosign", Image: "gcr.io/projectsigstore/cosign:v2.4.1"},
+	{Name: "curl", Image: "curlimages/curl:8.11.1"},
+	{Name: "dockle", Image: "goodwithtech/dockle:v0.4.15"},
+	{Name: "git", Image: "alpine/git:v2.47.1"},
+	{Name: "hadolint", Image: "hadolint/hadolint:v2.12.0"},
+	{Name: "syft", Image: "anchore/syft:v1.18.1"},
+	{Name: "trivy", Image: "aquasec/trivy:0.58.1"},
+}
+
+// ToolRecord is the tool image a run actually pulled, for the report.
+type ToolRecord struct {
+	Name      string `json:"name"`
+	Requested string `json:"requested"`
+	Resolved  string `json:"resolved"` // fully qualified reference with digest
+	Override  bool   `json:"override,omitempty"`
+}
+
+// Ref returns the pinned reference (image@digest), or the tag alone when no
+// digest has been recorded yet.
+func (t ToolImage) Ref() string {
+	if t.Digest == "" {
+		return t.Image
+	}
+	return t.Image + "@" + t.Digest
+}
+
+// LookupToolImage returns the table entry for name.
+func LookupToolImage(name string) (ToolImage, bool) {
+	for _, t := range ToolImages {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return ToolImage{}, false
+}
+
+// ToolImageOverrides extracts TOOL_IMAGE_<NAME> entries from an environment
+// list (os.Environ format). TOOL_IMAGE_FOO_BAR overrides the tool "foo-bar".
+func ToolImageOverrides(environ []string) map[string]string {
+	overrides := map[string]string{}
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, ToolImageEnvPrefix) || value == "" {
+			continue
+		}
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, ToolImageEnvPrefix), "_", "-"))
+		overrides[name] = value
+	}
+	return overrides
+}
+
+// validateToolOverrides rejects overrides for tools that are not in the table,
+// which are almost always typos in the variable name.
+func validateToolOverrides(overrides map[string]string) error {
+	var unknown []string
+	for name := range overrides {
+		if _, ok := LookupToolImage(name); !ok {
+			unknown = append(unknown, ToolImageEnvPrefix+strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	var known []string
+	for _, t := range ToolImages {
+		known = append(known, t.Name)
+	}
+	return fmt.Errorf("unknown tool image override %s (known tools: %s)", strings.Join(unknown, ", "), strings.Join(known, ", "))
+}
+
+// ToolContainer returns a container from the tool's image (the override when
+// one is configured) and records the digest that was actually pulled in the
+// run report. A pinned digest makes the engine verify the downloaded content.
+func (p *Pipeline) ToolContainer(ctx context.Context, client *dagger.Client, name string) (*dagger.Container, error) {
+	tool, ok := LookupToolImage(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool image %q", name)
+	}
+	ref, override := tool.Ref(), false
+	if v, ok := p.cfg.ToolImages[name]; ok {
+		ref, override = v, true
+	} else if tool.Digest == "" {
+		p.warnf("tool-unpinned", "   ⚠️  Tool image %s is not pinned to a digest (run --update-tool-pins)\n", tool.Image)
+	}
+
+	container := p.from(client, ref)
+	resolved, err := container.ImageRef(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pull tool image %s: %w", ref, err)
+	}
+	p.report.ToolImages = append(p.report.ToolImages, ToolRecord{Name: name, Requested: ref, Resolved: resolved, Override: override})
+	return container, nil
+}
+
+var (
+	bearerParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	manifestAccept     = strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+	}, ", ")
+)
+
+// splitImage splits repository:tag into registry host, repository path and tag,
+// applying Docker Hub's defaults for short names.
+func splitImage(image string) (host, repo, tag string) {
+	repo, tag = image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+	host = "registry-1.docker.io"
+	if first, rest, ok := strings.Cut(repo, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, repo = first, rest
+		if host == "docker.io" {
+			host = "registry-1.docker.io"
+		}
+	}
+	if host == "registry-1.docker.io" && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	return host, repo, tag
+}
+
+// ResolveDigest asks the registry for the current manifest digest of a tag,
+// using an anonymous bearer token when the registry requires one.
+func ResolveDigest(ctx context.Context, client *http.Client, image string) (string, error) {
+	return resolveDigest(ctx, client, image, "", "")
+}
+
+// resolveDigest is ResolveDigest with the registry credentials of a private
+// repository; an empty username asks for an anonymous token.
+func resolveDigest(ctx context.Context, client *http.Client, image, username, password string) (string, error) {
+	host, repo, tag := splitImage(image)
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, tag)
+
+	resp, err := headManifest(ctx, client, url, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := bearerToken(ctx, client, resp.Header.Get("WWW-Authenticate"), "repository:"+repo+":pull", username, password)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", image, err)
+		}
+		if resp, err = headManifest(ctx, client, url, token); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: registry returned %s", image, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("%s: registry did not return a digest", image)
+	}
+	return digest, nil
+}
+
+func headManifest(ctx context.Context, client *http.Client, url, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// bearerToken follows a `Bearer realm=…,service=…` challenge for the given
+// scope, anonymously or with basic credentials when username is set.
+func bearerToken(ctx context.Context, client *http.Client, challenge, scope, username, password string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+	params := map[string]string{}
+	for _, m := range bearerParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry auth challenge has no realm")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"], nil)
+	if err != nil {
+		return "", err
+	}
+	q := req.URL.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", scope)
+	req.URL.RawQuery = q.Encode()
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// UpdateToolPins resolves the current digest of every tool tag and prints
+// the ToolImages table ready to paste into tools.go.
+func UpdateToolPins(ctx context.Context, w io.Writer, client *http.Client) error {
+	var failed []string
+	fmt.Fprintln(w, "var ToolImages = []ToolImage{")
+	for _, t := range ToolImages {
+		digest, err := ResolveDigest(ctx, client, t.Image)
+		if err != nil {
+			failed = append(failed, err.Error())
+			digest = t.Digest
+		}
+		if digest == "" {
+			fmt.Fprintf(w, "\t{Name: %q, Image: %q},\n", t.Name, t.Image)
+		} else {
+			fmt.Fprintf(w, "\t{Name: %q, Image: %q, Digest: %q},\n", t.Name, t.Image, digest)
+		}
+	}
+	fmt.Fprintln(w, "}")
+	if len(failed) > 0 {
+		return fmt.Errorf("could not resolve %d tool image(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestToolImageOverrides tests TOOL_IMAGE_<NAME> parsing and validation
+func TestToolImageOverrides(t *testing.T) {
+	overrides := ToolImageOverrides([]string{
+		"PATH=/usr/bin",
+		"TOOL_IMAGE_TRIVY=mirror.corp.example/aquasec/trivy:0.58.1",
+		"TOOL_IMAGE_HADOLINT=",
+	})
+	if len(overrides) != 1 || overrides["trivy"] != "mirror.corp.example/aquasec/trivy:0.58.1" {
+		t.Fatalf("unexpected overrides: %v", overrides)
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", ToolImages: overrides}); err != nil {
+		t.Fatalf("known tool override rejected: %v", err)
+	}
+
+	_, err := New(Config{RepoName: "cert-parser", GitUser: "org", ToolImages: map[string]string{"trivvy": "x"}})
+	if CategoryOf(err) != CategoryConfig || !strings.Contains(err.Error(), "TOOL_IMAGE_TRIVVY") {
+		t.Fatalf("expected config error naming the variable, got %v", err)
+	}
+
+	for _, tool := range ToolImages {
+		if tool.Name != strings.ToLower(tool.Name) || !strings.Contains(tool.Image, ":") {
+			t.Fatalf("tool %q must have a lower-case name and a tagged image", tool.Name)
+		}
+	}
+	fmt.Println("✅ Tool image overrides parsed and validated")
+}
+
+// TestSplitImage tests registry/repository/tag splitting with Docker Hub defaults
+func TestSplitImage(t *testing.T) {
+	tests := []struct{ image, host, repo, tag string }{
+		{"curlimages/curl:8.11.1", "registry-1.docker.io", "curlimages/curl", "8.11.1"},
+		{"alpine", "registry-1.docker.io", "library/alpine", "latest"},
+		{"docker.io/aquasec/trivy:0.58.1", "registry-1.docker.io", "aquasec/trivy", "0.58.1"},
+		{"gcr.io/projectsigstore/cosign:v2.4.1", "gcr.io", "projectsigstore/cosign", "v2.4.1"},
+		{"localhost:5000/tools/syft", "localhost:5000", "tools/syft", "latest"},
+	}
+	for _, tc := range tests {
+		host, repo, tag := splitImage(tc.image)
+		if host != tc.host || repo != tc.repo || tag != tc.tag {
+			t.Fatalf("splitImage(%q) = %s %s %s", tc.image, host, repo, tag)
+		}
+	}
+	fmt.Println("✅ Image references split into registry, repository and tag")
+}
+
+// TestResolveDigest tests the anonymous token flow and digest lookup
+func TestResolveDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:tools/trivy:pull" {
+				t.Errorf("unexpected scope %q", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token":"anon"}`)
+		case "/v2/tools/trivy/manifests/0.58.1":
+			if r.Header.Get("Authorization") != "Bearer anon" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	got, err := ResolveDigest(context.Background(), server.Client(), host+"/tools/trivy:0.58.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != digest {
+		t.Fatalf("got digest %q", got)
+	}
+	if _, err := ResolveDigest(context.Background(), server.Client(), host+"/tools/missing:1"); err == nil {
+		t.Fatal("expected missing tag to fail")
+	}
+	fmt.Println("✅ Tool image digests resolved from the registry")
+}
+
+// TestToolImageRef tests pinned and unpinned references
+func TestToolImageRef(t *testing.T) {
+	pinned := ToolImage{Name: "trivy", Image: "aquasec/trivy:0.58.1", Digest: "sha256:abc"}
+	if pinned.Ref() != "aquasec/trivy:0.58.1@sha256:abc" {
+		t.Fatalf("unexpected pinned ref %q", pinned.Ref())
+	}
+	if (ToolImage{Image: "aquasec/trivy:0.58.1"}).Ref() != "aquasec/trivy:0.58.1" {
+		t.Fatal("unpinned ref should be the tag")
+	}
+	fmt.Println("✅ Tool image references include the pinned digest")
+}
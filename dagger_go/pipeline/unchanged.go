@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LastBuild is the last successful run of a repository, project and
+// branch or tag, kept in FreezeHistoryDir so the next run can tell whether there
+// is anything new to build.
+type LastBuild struct {
+	Commit      string    `json:"commit"`
+	BuiltAt     time.Time `json:"built_at"`
+	Published   bool      `json:"published,omitempty"`   // the run published an image
+	Images      []string  `json:"images,omitempty"`      // what it published
+	Fingerprint string    `json:"fingerprint,omitempty"` // runFingerprint of the run
+}
+
+// UnchangedSkip records a run skipped because its commit was already built.
+type UnchangedSkip struct {
+	Commit    string    `json:"commit"`
+	LastBuilt time.Time `json:"last_built"`
+	Images    []string  `json:"images,omitempty"`
+	Verified  bool      `json:"verified,omitempty"` // the images were found in the registry
+}
+
+// lastBuildFile is where the last successful run of cfg's repository,
+// project and branch or tag is kept between runs.
+func lastBuildFile(cfg Config) string {
+	key := strings.Join([]string{cfg.GitUser, cfg.RepoName, cfg.ProjectDir, gitRefName(cfg)}, "/")
+	key = strings.Trim(strings.NewReplacer("//", "/").Replace(key), "/")
+	return filepath.Join(cfg.FreezeHistoryDir, "last-build-"+strings.NewReplacer("/", "_", "\\", "_").Replace(key)+".json")
+}
+
+// LoadLastBuild reads a last-build file; a missing file is nil.
+func LoadLastBuild(path string) (*LastBuild, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var last LastBuild
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, fmt.Errorf("invalid last-build record %s: %w", path, err)
+	}
+	return &last, nil
+}
+
+// publishes reports whether a run with cfg publishes an image.
+func publishes(cfg Config) bool {
+	return cfg.RunPublish || cfg.PublishPRImage
+}
+
+// runFingerprint identifies what a run with cfg checks: its profile, its
+// stages and whether its tests and lint only cover the change. A last build
+// only stands in for a run with the same fingerprint.
+func runFingerprint(cfg Config) string {
+	profile := ProfileMain
+	if cfg.Profile != nil {
+		profile = cfg.Profile.Name
+	}
+	parts := []string{"profile=" + profile, "stages=" + strings.Join(PlannedStages(cfg), ",")}
+	if len(cfg.DepResolutions) > 0 {
+		parts = append(parts, "resolutions="+strings.Join(cfg.DepResolutions, ","))
+	}
+	if cfg.ChangedOnlyTests {
+		parts = append(parts, "changed-only-tests")
+	}
+	if cfg.LintChangedOnly {
+		parts = append(parts, "lint-changed-only")
+	}
+	return strings.Join(parts, " ")
+}
+
+// skipUnchanged reports whether the run can stop because commit is the one
+// the last successful run built with the same profile and stages. A last
+// run that did not publish only covers runs that do not publish either.
+// With VerifyUnchanged, the images it published must still be in the
+// registry. FORCE_RUN, NO_CACHE, releases and runs without FreezeHistoryDir
+// always run.
+func (p *Pipeline) skipUnchanged(ctx context.Context, commit string) bool {
+	cfg := p.cfg
+	if cfg.ForceRun || cfg.NoCache || cfg.FreezeHistoryDir == "" || cfg.ReleaseVersion != "" {
+		return false
+	}
+	last, err := LoadLastBuild(lastBuildFile(cfg))
+	if err != nil {
+		p.warnf("last-build", "   ⚠️  %v — running\n", err)
+		return false
+	}
+	if last == nil || last.Commit != commit || publishes(cfg) && !last.Published {
+		return false
+	}
+	if fingerprint := runFingerprint(cfg); last.Fingerprint != fingerprint {
+		p.printf("   ℹ️  %s was built before, but not with %s — running\n", shortCommit(commit), fingerprint)
+		return false
+	}
+	skip := &UnchangedSkip{Commit: commit, LastBuilt: last.BuiltAt, Images: last.Images}
+	if cfg.VerifyUnchanged && publishes(cfg) {
+		for _, image := range last.Images {
+			if _, err := resolveDigest(ctx, cfg.HTTPClient, image, cfg.RegistryUser, p.registryToken); err != nil {
+				p.printf("   ℹ️  %s was built before, but %s could not be found in the registry (%v) — running\n", shortCommit(commit), image, err)
+				return false
+			}
+		}
+		skip.Verified = true
+	}
+
+	reason := fmt.Sprintf("no new commits since %s, last built %s (%s ago)", shortCommit(commit),
+		last.BuiltAt.UTC().Format("2006-01-02 15:04 UTC"), compactDuration(time.Since(last.BuiltAt).Truncate(time.Minute)))
+	p.report.Unchanged = skip
+	p.tracker.SkipRun(reason)
+	p.printf("\n⏭️  Nothing to do: %s\n", reason)
+	if skip.Verified {
+		p.printf("   Published images still in the registry: %s\n", strings.Join(last.Images, ", "))
+	}
+	p.println("   Set FORCE_RUN=true to run anyway")
+	return true
+}
+
+// recordLastBuild keeps the commit of a successful run for skipUnchanged.
+// Local sources are not kept: their working tree may not match the commit.
+func (p *Pipeline) recordLastBuild() {
+	if p.cfg.FreezeHistoryDir == "" || p.commit == "" || p.report.Unchanged != nil || p.cfg.LocalSource != "" {
+		return
+	}
+	last := LastBuild{Commit: p.commit, BuiltAt: time.Now().UTC(), Published: publishes(p.cfg), Images: p.report.PublishedImages,
+		Fingerprint: runFingerprint(p.cfg)}
+	if p.report.PRImage != nil {
+		last.Images = append(last.Images, p.report.PRImage.Image)
+	}
+	data, err := json.MarshalIndent(last, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(p.cfg.FreezeHistoryDir, 0o755)
+	}
+	if err == nil {
+		err = WriteFileAtomic(lastBuildFile(p.cfg), data)
+	}
+	if err != nil {
+		p.warnf("last-build", "⚠️  Could not record the built commit: %v\n", err)
+	}
+}
+
+// shortCommit is the first 12 characters of a commit SHA.
+func shortCommit(commit string) string {
+	return commit[:min(12, len(commit))]
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLastBuildFile tests that repositories, projects and branches do not share a record
+func TestLastBuildFile(t *testing.T) {
+	cfg := Config{FreezeHistoryDir: "/cache", GitUser: "org", RepoName: "mono", GitBranch: "feature/x"}
+	if got := lastBuildFile(cfg); got != filepath.Join("/cache", "last-build-org_mono_feature_x.json") {
+		t.Fatalf("unexpected file %s", got)
+	}
+	cfg.ProjectDir = "services/api"
+	if got := lastBuildFile(cfg); got != filepath.Join("/cache", "last-build-org_mono_services_api_feature_x.json") {
+		t.Fatalf("unexpected file %s", got)
+	}
+	fmt.Println("✅ Last build kept per repository, project and branch")
+}
+
+// TestSkipUnchanged tests when a run stops because its commit was already built
+func TestSkipUnchanged(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	dir := t.TempDir()
+	newPipeline := func(change func(*Config)) (*Pipeline, *bytes.Buffer) {
+		var out bytes.Buffer
+		cfg := Config{RepoName: "cert-parser", GitUser: "org", FreezeHistoryDir: dir, ArtifactsDir: t.TempDir(), Output: &out}
+		if change != nil {
+			change(&cfg)
+		}
+		p, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p, &out
+	}
+
+	p, _ := newPipeline(nil)
+	if p.skipUnchanged(context.Background(), commit) {
+		t.Fatal("a first run should not be skipped")
+	}
+	p.commit = commit
+	p.recordLastBuild()
+
+	p, out := newPipeline(nil)
+	if !p.skipUnchanged(context.Background(), commit) {
+		t.Fatal("the same commit should be skipped")
+	}
+	p.tracker.Finish(nil)
+	status := p.tracker.Snapshot()
+	if status.State != RunSucceeded || !strings.Contains(status.Skipped, "no new commits since 0123456789ab") || p.report.Unchanged == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if !strings.Contains(out.String(), "FORCE_RUN=true") {
+		t.Fatalf("missing FORCE_RUN hint in %q", out.String())
+	}
+
+	for name, change := range map[string]func(*Config){
+		"FORCE_RUN":        func(c *Config) { c.ForceRun = true },
+		"a new branch":     func(c *Config) { c.GitBranch = "develop" },
+		"a publishing run": func(c *Config) { c.RunBuild, c.RunPublish, c.RegistryToken = true, true, "token" },
+		"no history":       func(c *Config) { c.FreezeHistoryDir = "" },
+		"a release":        func(c *Config) { c.ReleaseVersion, c.GitToken = "1.0.0", "token" },
+		"NO_CACHE":         func(c *Config) { c.NoCache = true },
+		"another profile":  func(c *Config) { c.Profile = &ProfileRecord{Name: ProfileNightly} },
+		"more stages":      func(c *Config) { c.RunIntegrationTests, c.DatabaseURL = true, "postgresql://db/test" },
+		"changed-only":     func(c *Config) { c.ChangedOnlyTests = true },
+	} {
+		if p, _ := newPipeline(change); p.skipUnchanged(context.Background(), commit) {
+			t.Fatalf("%s should run", name)
+		}
+	}
+	if p, _ := newPipeline(nil); p.skipUnchanged(context.Background(), strings.Repeat("f", 40)) {
+		t.Fatal("a new commit should run")
+	}
+	fmt.Println("✅ Unchanged commits skipped")
+}
+
+// TestSkipUnchangedVerify tests checking the published images before skipping
+func TestSkipUnchangedVerify(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	server, host := mirrorServer(t)
+	dir := t.TempDir()
+	publish := func(images ...string) *Pipeline {
+		p, err := New(Config{RepoName: "cert-parser", GitUser: "org", FreezeHistoryDir: dir, ArtifactsDir: t.TempDir(), Output: &bytes.Buffer{},
+			RunBuild: true, RunPublish: true, RegistryToken: "token", VerifyUnchanged: true, HTTPClient: server.Client()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.commit, p.report.PublishedImages = commit, images
+		return p
+	}
+
+	publish(host + "/python/python:3.14-slim").recordLastBuild()
+	p := publish()
+	if !p.skipUnchanged(context.Background(), commit) || !p.report.Unchanged.Verified {
+		t.Fatalf("an image still in the registry should be skipped: %+v", p.report.Unchanged)
+	}
+	last, err := LoadLastBuild(lastBuildFile(p.cfg))
+	if err != nil || !last.Published || last.BuiltAt.After(time.Now()) {
+		t.Fatalf("unexpected record %+v, %v", last, err)
+	}
+
+	publish(host + "/org/cert-parser:deleted").recordLastBuild()
+	if publish().skipUnchanged(context.Background(), commit) {
+		t.Fatal("a deleted image should be rebuilt")
+	}
+	fmt.Println("✅ Published images verified before skipping")
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+
+	"dagger.io/dagger"
+)
+
+// VulnScanSeverities are the trivy severities that fail the vulnerability
+// scan; vulnerabilities without a released fix are ignored.
+const VulnScanSeverities = "HIGH,CRITICAL"
+
+// trivyCacheVolume keeps trivy's vulnerability database between runs.
+const trivyCacheVolume = "trivy-cache"
+
+// scanVulnerabilities scans the built image with trivy and returns an error
+// when it has fixable HIGH or CRITICAL vulnerabilities. The database is
+// downloaded through ProxyURL when one is configured.
+func (p *Pipeline) scanVulnerabilities(ctx context.Context, client *dagger.Client, image *dagger.Container) error {
+	trivy, err := p.ToolContainer(ctx, client, "trivy")
+	if err != nil {
+		return Errorf(CategoryBuild, "trivy: %w", err)
+	}
+	if p.cfg.ProxyURL != "" {
+		trivy = trivy.
+			WithEnvVariable("HTTPS_PROXY", p.cfg.ProxyURL).
+			WithEnvVariable("HTTP_PROXY", p.cfg.ProxyURL)
+	}
+	if p.cfg.NoCache {
+		trivy = trivy.WithEnvVariable(cacheBusterEnv, p.cacheBuster)
+	}
+	scan := trivy.
+		WithMountedCache("/root/.cache/trivy", p.cacheVolume(client, trivyCacheVolume)).
+		WithMountedFile("/image.tar", image.AsTarball()).
+		WithExec([]string{
+			"trivy", "image", "--input", "/image.tar",
+			"--severity", VulnScanSeverities, "--ignore-unfixed",
+			"--exit-code", "1", "--no-progress",
+		}, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+
+	output, err := scan.Stdout(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "trivy: %w", err)
+	}
+	p.println(output)
+	code, err := scan.ExitCode(ctx)
+	if err != nil {
+		return Errorf(CategoryBuild, "trivy: %w", err)
+	}
+	if code != 0 {
+		if stderr, _ := scan.Stderr(ctx); stderr != "" {
+			p.println(stderr)
+		}
+		return Errorf(CategoryBuild, "image has fixable %s vulnerabilities (trivy exit code %d)", VulnScanSeverities, code)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// WarmupConfig selects what Warmup pre-populates on a fresh runner.
+type WarmupConfig struct {
+	Requirements     string            // requirements file pre-installed into the pip cache (optional)
+	CACertPaths      []string          // CA files or directories, as in Config
+	ProxyURL         string            // Proxy exported to the containers, as in Config
+	ToolImages       map[string]string // Tool name → image overriding the pinned ToolImages entry
+	BaseImage        string            // Python image of the build container, as in Config
+	AptPackages      []string          // Replaces DefaultAptPackages, as in Config
+	ExtraAptPackages []string          // Extra build-container packages, as in Config
+	SkipDefaultApt   bool              // Leave out DefaultAptPackages, as in Config
+	BaseImageMirror  string            // Mirror the base image is pulled through, as in Config
+	DockerHubUser    string            // Docker Hub account for authenticated pulls, as in Config
+	DockerHubToken   string            // Docker Hub access token, as in Config
+	Output           io.Writer         // Progress output (default: os.Stdout)
+}
+
+// WarmupItem is one thing Warmup pulled or populated.
+type WarmupItem struct {
+	Name     string        `json:"name"`
+	Detail   string        `json:"detail"`
+	Duration time.Duration `json:"duration"`
+}
+
+// WarmupReport lists what Warmup did and how long it took.
+type WarmupReport struct {
+	Items    []WarmupItem  `json:"items"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Warmup pre-populates the engine for later runs without cloning a project:
+// it pulls the base image and every tool image, builds the system
+// environment (filling the apt cache volume) and, when Requirements is set,
+// installs that requirements set so the pip cache volume is filled too.
+// Everything goes through the same proxy/CA setup as a real run, and the
+// engine's layer cache makes a repeated warmup a no-op.
+func Warmup(ctx context.Context, client *dagger.Client, cfg WarmupConfig) (*WarmupReport, error) {
+	if err := validateToolOverrides(cfg.ToolImages); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if err := validateBuildContainer(Config{BaseImage: cfg.BaseImage, AptPackages: cfg.AptPackages, ExtraAptPackages: cfg.ExtraAptPackages, SkipDefaultApt: cfg.SkipDefaultApt}); err != nil {
+		return nil, Errorf(CategoryConfig, "%w", err)
+	}
+	if cfg.Requirements != "" {
+		if _, err := os.Stat(cfg.Requirements); err != nil {
+			return nil, Errorf(CategoryConfig, "warmup requirements: %w", err)
+		}
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	p := &Pipeline{
+		cfg: Config{
+			CACertPaths:      cfg.CACertPaths,
+			ProxyURL:         cfg.ProxyURL,
+			ToolImages:       cfg.ToolImages,
+			BaseImage:        cfg.BaseImage,
+			AptPackages:      cfg.AptPackages,
+			ExtraAptPackages: cfg.ExtraAptPackages,
+			SkipDefaultApt:   cfg.SkipDefaultApt,
+			BaseImageMirror:  cfg.BaseImageMirror,
+			DockerHubUser:    cfg.DockerHubUser,
+			DockerHubToken:   cfg.DockerHubToken,
+		},
+		out:    cfg.Output,
+		report: &Report{},
+	}
+
+	report := &WarmupReport{}
+	start := time.Now()
+	step := func(name, detail string, fn func() error) error {
+		p.printf("🔥 Warming %s (%s)...\n", name, detail)
+		stepStart := time.Now()
+		if err := fn(); err != nil {
+			p.printf("   ❌ %s: %v\n", name, err)
+			return err
+		}
+		elapsed := time.Since(stepStart)
+		p.printf("   ✅ %s ready in %s\n", name, elapsed.Round(time.Millisecond))
+		report.Items = append(report.Items, WarmupItem{Name: name, Detail: detail, Duration: elapsed})
+		return nil
+	}
+
+	// Directory.Digest forces the layers to be fetched, not just the manifest
+	if err := step("base image", p.baseImage(), func() error {
+		_, err := p.from(client, p.baseImage()).Rootfs().Digest(ctx)
+		return err
+	}); err != nil {
+		p.explainRateLimit(err)
+		return report, Errorf(CategoryBuild, "pull base image: %w", err)
+	}
+
+	for _, tool := range ToolImages {
+		ref := tool.Ref()
+		if v, ok := cfg.ToolImages[tool.Name]; ok {
+			ref = v
+		}
+		if err := step("tool "+tool.Name, ref, func() error {
+			container, err := p.ToolContainer(ctx, client, tool.Name)
+			if err != nil {
+				return err
+			}
+			_, err = container.Rootfs().Digest(ctx)
+			return err
+		}); err != nil {
+			return report, Errorf(CategoryBuild, "pull tool image %s: %w", tool.Name, err)
+		}
+	}
+
+	system := p.systemEnv(client)
+	if err := step("system packages", "apt cache volume "+AptCacheVolume, func() error {
+		_, err := system.Sync(ctx)
+		return err
+	}); err != nil {
+		if aptErr := aptInstallError(err); aptErr != nil {
+			return report, aptErr
+		}
+		return report, Errorf(CategoryBuild, "install system packages: %w", err)
+	}
+
+	if cfg.Requirements != "" {
+		const mounted = "/tmp/warmup/requirements.txt"
+		if err := step("pip cache", filepath.Base(cfg.Requirements)+" → "+PipCacheVolume, func() error {
+			_, err := system.
+				WithMountedFile(mounted, client.Host().File(cfg.Requirements)).
+				WithExec([]string{"pip", "install", "--upgrade", "pip", "setuptools", "wheel"}).
+				WithExec([]string{"pip", "install", "-r", mounted}).
+				Sync(ctx)
+			return err
+		}); err != nil {
+			return report, Errorf(CategoryBuild, "pre-install requirements: %w", err)
+		}
+	}
+
+	report.Duration = time.Since(start)
+	p.printf("\n%s\n", strings.Repeat("=", 80))
+	p.printf("WARMUP COMPLETE in %s\n", report.Duration.Round(time.Millisecond))
+	p.println(strings.Repeat("=", 80))
+	for _, item := range report.Items {
+		p.printf("   %-20s %-10s %s\n", item.Name, item.Duration.Round(time.Millisecond), item.Detail)
+	}
+	return report, nil
+}
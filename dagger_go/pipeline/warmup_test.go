@@ -0,0 +1,21 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// TestWarmupValidatesConfig tests that bad warmup settings fail before the engine is used
+func TestWarmupValidatesConfig(t *testing.T) {
+	_, err := Warmup(context.Background(), nil, WarmupConfig{ToolImages: map[string]string{"trivvy": "x"}})
+	if CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected config error for unknown tool, got %v", err)
+	}
+	_, err = Warmup(context.Background(), nil, WarmupConfig{Requirements: filepath.Join(t.TempDir(), "missing.txt")})
+	if CategoryOf(err) != CategoryConfig {
+		t.Fatalf("expected config error for missing requirements, got %v", err)
+	}
+	fmt.Println("✅ Warmup configuration validated up front")
+}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Warning is one distinct warning of a run and how often it was raised.
+// ID names the kind of warning (e.g. venv-missing) for WarningsAllow; the
+// same kind can be raised with different messages.
+type Warning struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// Warnings collects the warnings of a run, deduplicated by ID and message,
+// in the order they were first raised. The binaries create it before
+// New so warnings from before the run (certificate discovery, flag
+// handling) end up in the same section and report. Safe for concurrent use.
+type Warnings struct {
+	mu    sync.Mutex
+	list  []Warning
+	index map[string]int // ID + message → position in list
+}
+
+// NewWarnings returns an empty collection.
+func NewWarnings() *Warnings {
+	return &Warnings{index: map[string]int{}}
+}
+
+// Add records a warning and reports whether it is the first with this ID
+// and message.
+func (w *Warnings) Add(id, message string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := id + "\x00" + message
+	if i, ok := w.index[key]; ok {
+		w.list[i].Count++
+		return false
+	}
+	w.index[key] = len(w.list)
+	w.list = append(w.list, Warning{ID: id, Message: message, Count: 1})
+	return true
+}
+
+// Fprintf prints a warning to out like fmt.Fprintf, e.g.
+// "   ⚠️  Could not ...\n", and records it under id without the indentation
+// and the warning sign. A nil collection only prints.
+func (w *Warnings) Fprintf(out io.Writer, id, format string, args ...any) {
+	text := fmt.Sprintf(format, args...)
+	fmt.Fprint(out, text)
+	if w != nil {
+		w.Add(id, warningMessage(text))
+	}
+}
+
+// warningMessage is a printed warning without the indentation and the
+// warning sign.
+func warningMessage(text string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "⚠️"))
+}
+
+// List returns the warnings in the order first raised.
+func (w *Warnings) List() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.list)
+}
+
+// Clone returns an independent copy, so each project of a monorepo run
+// starts from the warnings raised before it.
+func (w *Warnings) Clone() *Warnings {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c := NewWarnings()
+	for _, warning := range w.list {
+		c.index[warning.ID+"\x00"+warning.Message] = len(c.list)
+		c.list = append(c.list, warning)
+	}
+	return c
+}
+
+// ParseCommaList splits a comma-separated setting such as WARNINGS_ALLOW,
+// dropping empty entries.
+func ParseCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// FatalWarnings are the warnings whose ID is not in allow.
+func FatalWarnings(warnings []Warning, allow []string) []Warning {
+	var fatal []Warning
+	for _, w := range warnings {
+		if !slices.Contains(allow, w.ID) {
+			fatal = append(fatal, w)
+		}
+	}
+	return fatal
+}
+
+// PrintWarnings prints the "Warnings (n)" section: one line per distinct
+// warning, with its ID and how often it was raised.
+func PrintWarnings(out io.Writer, warnings []Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n⚠️  Warnings (%d)\n", len(warnings))
+	for _, w := range warnings {
+		count := ""
+		if w.Count > 1 {
+			count = fmt.Sprintf(" (×%d)", w.Count)
+		}
+		fmt.Fprintf(out, "   • [%s] %s%s\n", w.ID, w.Message, count)
+	}
+}
+
+// warnf prints a warning like printf and records it under id.
+func (p *Pipeline) warnf(id, format string, args ...any) {
+	p.cfg.Warnings.Fprintf(p.out, id, format, args...)
+	if p.events != nil {
+		p.events.Emit(Event{Type: EventWarning, WarningID: id, Message: warningMessage(fmt.Sprintf(format, args...))})
+	}
+}
+
+// finishWarnings prints the warnings section, records it in the report and,
+// with WarningsAsErrors, fails a run that passed otherwise.
+func (p *Pipeline) finishWarnings(err error) error {
+	warnings := p.cfg.Warnings.List()
+	p.report.Warnings = warnings
+	PrintWarnings(p.out, warnings)
+	if err != nil || !p.cfg.WarningsAsErrors {
+		return err
+	}
+	if fatal := FatalWarnings(warnings, p.cfg.WarningsAllow); len(fatal) > 0 {
+		var ids []string
+		for _, w := range fatal {
+			if !slices.Contains(ids, w.ID) {
+				ids = append(ids, w.ID)
+			}
+		}
+		return Errorf(CategoryUnknown, "%d warning(s) with WARNINGS_AS_ERRORS=true: %s (allow IDs with WARNINGS_ALLOW)", len(fatal), strings.Join(ids, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestWarningsDedupe tests that identical warnings are counted once and printed in a final section
+func TestWarningsDedupe(t *testing.T) {
+	w := NewWarnings()
+	var out bytes.Buffer
+	w.Fprintf(&out, "venv-missing", "   ⚠️  .venv not found, using system pytest\n")
+	w.Fprintf(&out, "tool-unpinned", "   ⚠️  Tool image %s is not pinned to a digest\n", "aquasec/trivy")
+	w.Fprintf(&out, "venv-missing", "   ⚠️  .venv not found, using system pytest\n")
+	if strings.Count(out.String(), ".venv not found") != 2 {
+		t.Fatalf("every warning should still be printed where it happens:\n%s", out.String())
+	}
+	list := w.List()
+	if len(list) != 2 || list[0] != (Warning{ID: "venv-missing", Message: ".venv not found, using system pytest", Count: 2}) {
+		t.Fatalf("got %+v", list)
+	}
+
+	clone := w.Clone()
+	clone.Add("project-name", "Could not parse name")
+	if len(w.List()) != 2 || len(clone.List()) != 3 {
+		t.Fatal("a clone should be independent of the original")
+	}
+
+	out.Reset()
+	PrintWarnings(&out, list)
+	for _, want := range []string{"Warnings (2)", "[venv-missing] .venv not found, using system pytest (×2)", "[tool-unpinned] Tool image aquasec/trivy"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("section should contain %q:\n%s", want, out.String())
+		}
+	}
+	var nilWarnings *Warnings
+	nilWarnings.Fprintf(&out, "x", "printed only\n")
+	fmt.Println("✅ Warnings deduplicated and listed")
+}
+
+// TestWarningsAsErrors tests failing a passing run on warnings outside the allowlist
+func TestWarningsAsErrors(t *testing.T) {
+	newPipeline := func(asErrors bool, allow ...string) *Pipeline {
+		p, err := New(Config{RepoName: "cert-parser", GitUser: "org", WarningsAsErrors: asErrors, WarningsAllow: allow, Output: &bytes.Buffer{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.warnf("tool-unpinned", "   ⚠️  Tool image is not pinned\n")
+		p.warnf("venv-missing", "   ⚠️  .venv not found\n")
+		return p
+	}
+
+	p := newPipeline(false)
+	if err := p.finishWarnings(nil); err != nil || len(p.report.Warnings) != 2 {
+		t.Fatalf("warnings alone should not fail the run: %v, %+v", err, p.report.Warnings)
+	}
+	if err := newPipeline(true).finishWarnings(nil); err == nil || !strings.Contains(err.Error(), "tool-unpinned, venv-missing") {
+		t.Fatalf("WarningsAsErrors should fail naming the IDs, got %v", err)
+	}
+	if err := newPipeline(true, "tool-unpinned", "venv-missing").finishWarnings(nil); err != nil {
+		t.Fatalf("allowlisted warnings should stay non-fatal, got %v", err)
+	}
+	failed := errors.New("unit tests failed")
+	if err := newPipeline(true).finishWarnings(failed); err != failed {
+		t.Fatalf("a failing run keeps its own error, got %v", err)
+	}
+	if got := ParseCommaList(" a, ,b,"); strings.Join(got, "|") != "a|b" {
+		t.Fatalf("ParseCommaList = %q", got)
+	}
+	fmt.Println("✅ WARNINGS_AS_ERRORS honours the allowlist")
+}
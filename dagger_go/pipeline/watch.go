@@ -0,0 +1,303 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+)
+
+// Watch mode timing defaults.
+const (
+	DefaultWatchInterval = 500 * time.Millisecond
+	DefaultWatchDebounce = time.Second
+)
+
+// watchDirs are the project directories whose changes re-run the fast
+// stages; pyproject.toml changes rebuild the environment first.
+var watchDirs = []string{"src", "tests"}
+
+// WatchOptions configures the watch loop itself; the build environment,
+// proxy, CA certificates, package index and credentials come from the
+// Config of a normal run.
+type WatchOptions struct {
+	Interval time.Duration     // how often the tree is polled (default: DefaultWatchInterval)
+	Debounce time.Duration     // quiet period after the last change before a run (default: DefaultWatchDebounce)
+	After    func(WatchResult) // called after every iteration (optional)
+}
+
+// WatchResult is the outcome of one watch iteration.
+type WatchResult struct {
+	Iteration int
+	Changed   []string
+	Tests     string // e.g. "✅ 12 passed", "❌ 1 failed, 11 passed", "⏭️ none selected"
+	Lint      string
+	Passed    bool
+	Duration  time.Duration
+}
+
+// fileStamp identifies a version of a file for change detection.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshotTree stamps the Python files under the watched directories and
+// pyproject.toml, keyed by slash-separated path relative to root.
+func snapshotTree(root string) map[string]fileStamp {
+	files := map[string]fileStamp{}
+	stamp := func(path string, info fs.FileInfo) {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			files[filepath.ToSlash(rel)] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+	if info, err := os.Stat(filepath.Join(root, "pyproject.toml")); err == nil {
+		stamp(filepath.Join(root, "pyproject.toml"), info)
+	}
+	for _, dir := range watchDirs {
+		filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if name := d.Name(); name == "__pycache__" || strings.HasPrefix(name, ".") && path != filepath.Join(root, dir) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".py" {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				stamp(path, info)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// changedSince lists the files added, modified or deleted between two
+// snapshots, sorted.
+func changedSince(before, after map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || old != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// FormatWatchResult renders the compact line printed after every iteration.
+func FormatWatchResult(r WatchResult, at time.Time) string {
+	what := "initial run"
+	if r.Iteration > 1 || len(r.Changed) > 0 {
+		what = fmt.Sprintf("%d file(s) changed", len(r.Changed))
+	}
+	return fmt.Sprintf("[%s] #%d %s — tests %s | ruff %s (%s)",
+		at.Format("15:04:05"), r.Iteration, what, r.Tests, r.Lint, r.Duration.Round(100*time.Millisecond))
+}
+
+// Watch is the local development loop: it runs the unit tests and ruff on
+// cfg.LocalSource, then polls src/ and tests/ and re-runs them for the
+// changed files once changes have settled for Debounce. The build
+// environment is built from cfg once and reused, with the current source
+// mounted for every iteration; a pyproject.toml change rebuilds it. Nothing
+// is built or published. Watch returns nil when ctx is cancelled (Ctrl-C).
+func Watch(ctx context.Context, client *dagger.Client, cfg Config, opts WatchOptions) error {
+	if cfg.LocalSource == "" {
+		return Errorf(CategoryConfig, "watch mode needs LOCAL_SOURCE, the project directory to watch")
+	}
+	root, err := filepath.Abs(cfg.LocalSource)
+	if err != nil {
+		return Errorf(CategoryConfig, "LOCAL_SOURCE: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pyproject.toml")); err != nil {
+		return Errorf(CategoryConfig, "LOCAL_SOURCE %s has no pyproject.toml", root)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+	cfg.LocalSource = root
+	p, err := newWatchPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	if err := p.resolveLocalFrameworks(hostExists(root)); err != nil {
+		return err
+	}
+	source := func() *dagger.Directory {
+		return client.Host().Directory(root, dagger.HostDirectoryOpts{Exclude: LocalSourceExcludes})
+	}
+
+	p.printf("👀 Watch mode: %s (src/, tests/, pyproject.toml) — Ctrl-C to stop\n", root)
+	p.println("   Unit tests and ruff only; nothing is built or published")
+	p.println("🔨 Setting up Python build environment...")
+	builder := p.buildEnv(client, source())
+	if _, err := builder.Sync(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if aptErr := aptInstallError(err); aptErr != nil {
+			return aptErr
+		}
+		return Errorf(CategoryBuild, "build environment: %w", err)
+	}
+
+	snapshot := snapshotTree(root)
+	iteration := 0
+	runOnce := func(changed []string) {
+		iteration++
+		result := p.watchIteration(ctx, builder.WithMountedDirectory(AppWorkdir, source()), iteration, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		p.println(FormatWatchResult(result, time.Now()))
+		if opts.After != nil {
+			opts.After(result)
+		}
+	}
+	runOnce(nil)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	var pending []string
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			p.println("\n👋 Watch stopped")
+			return nil
+		case <-ticker.C:
+		}
+		current := snapshotTree(root)
+		if changed := changedSince(snapshot, current); len(changed) > 0 {
+			snapshot = current
+			pending = mergeSorted(pending, changed)
+			lastChange = time.Now()
+			continue
+		}
+		if len(pending) == 0 || time.Since(lastChange) < opts.Debounce {
+			continue
+		}
+		changed := pending
+		pending = nil
+		for _, file := range changed {
+			if file == "pyproject.toml" {
+				p.println("🔨 pyproject.toml changed — rebuilding the environment...")
+				builder = p.buildEnv(client, source())
+				if _, err := builder.Sync(ctx); err != nil && ctx.Err() == nil {
+					p.printf("   ❌ Environment rebuild failed: %v\n", err)
+				}
+				changed = nil // everything runs after a rebuild
+				break
+			}
+		}
+		runOnce(changed)
+	}
+}
+
+// newWatchPipeline is the pipeline of Watch: cfg with only the stages watch
+// mode runs, so the publish, release and package settings of a normal run
+// neither apply nor fail validation.
+func newWatchPipeline(cfg Config) (*Pipeline, error) {
+	cfg.RunUnitTests, cfg.RunLint = true, true
+	cfg.RunIntegrationTests, cfg.RunAcceptanceTests, cfg.RunTypeCheck = false, false, false
+	cfg.RunSlowTests, cfg.RunBenchmarks, cfg.RunDependencyAudit = false, false, false
+	cfg.RunBuild, cfg.RunPublish, cfg.PublishTestImage, cfg.PublishPRImage = false, false, false, false
+	cfg.RunPackagePublish, cfg.ExportWheels, cfg.PackagePublish = false, false, PackagePublish{}
+	cfg.ReleaseVersion, cfg.PythonVersions, cfg.DepResolutions = "", nil, nil
+	cfg.StatusFile, cfg.EventsFile, cfg.StatusListen = "", "", ""
+	return newPipeline(cfg)
+}
+
+// mergeSorted merges two sorted file lists without duplicates.
+func mergeSorted(a, b []string) []string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, f := range append(append([]string(nil), a...), b...) {
+		if !seen[f] {
+			seen[f] = true
+			merged = append(merged, f)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// watchIteration runs the unit tests and ruff for one iteration. A nil
+// changed list runs everything; otherwise the CHANGED_ONLY_TESTS and
+// LINT_CHANGED_ONLY selections apply to the changed files.
+func (p *Pipeline) watchIteration(ctx context.Context, container *dagger.Container, iteration int, changed []string) WatchResult {
+	start := time.Now()
+	result := WatchResult{Iteration: iteration, Changed: changed, Passed: true}
+	p.changedOnly, p.changedTests, p.lintScope = false, nil, LintScope{}
+	if changed != nil {
+		var testFiles []string
+		for _, pattern := range testFilePatterns {
+			if matches, err := container.Directory(AppWorkdir).Glob(ctx, pattern); err == nil {
+				testFiles = append(testFiles, matches...)
+			}
+		}
+		if selected, reason := ChangedTests(changed, testFiles); reason == "" {
+			p.changedOnly, p.changedTests = true, selected
+		}
+		p.lintScope = ChangedLintScope(changed, p.cfg.LintChangedMax)
+	}
+
+	if p.changedOnly && len(p.changedTests) == 0 {
+		result.Tests = "⏭️ none selected"
+	} else {
+		cmd := append([]string{"pytest", "-q", "--tb=short", "-m", unitMarker}, p.changedTests...)
+		run := container.WithExec(cmd, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+		output, err := run.Stdout(ctx)
+		code, cerr := run.ExitCode(ctx)
+		summary := ParsePytestOutput(output)
+		switch {
+		case errors.Join(err, cerr) != nil:
+			result.Tests, result.Passed = "❌ could not run", false
+			p.printf("   ❌ pytest: %v\n", errors.Join(err, cerr))
+		case NoTestsRan(output, code):
+			result.Tests = "⏭️ no tests"
+		case code != 0:
+			result.Tests, result.Passed = fmt.Sprintf("❌ %d failed, %d passed", summary.Failed+summary.Errors, summary.Passed), false
+			PrintFailureList(p.out, summary.Failures, FailureListLimit)
+		default:
+			result.Tests = fmt.Sprintf("✅ %d passed", summary.Passed)
+		}
+	}
+
+	if cmd := p.lintCommand("ruff"); cmd == nil {
+		result.Lint = "⏭️ no files"
+	} else {
+		output, code, err := outputAndExitCode(ctx, p.runLint(container, "ruff"))
+		switch {
+		case err != nil:
+			result.Lint, result.Passed = "❌ could not run", false
+		case code != 0:
+			result.Lint, result.Passed = "❌", false
+			p.println(strings.TrimRight(output, "\n"))
+		default:
+			result.Lint = "✅"
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
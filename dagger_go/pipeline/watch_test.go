@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSnapshotTreeChanges tests that added, modified and deleted Python files are detected
+func TestSnapshotTreeChanges(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pyproject.toml", "[project]\n")
+	write("src/app/parser.py", "x = 1\n")
+	write("src/app/__pycache__/parser.cpython-314.pyc", "bytecode")
+	write("tests/unit/test_parser.py", "def test(): pass\n")
+	write("tests/unit/data.json", "{}")
+	write("docs/index.py", "ignored\n")
+
+	before := snapshotTree(root)
+	want := []string{"pyproject.toml", "src/app/parser.py", "tests/unit/test_parser.py"}
+	if got := changedSince(nil, before); !reflect.DeepEqual(got, want) {
+		t.Fatalf("watched files = %v, want %v", got, want)
+	}
+
+	write("src/app/parser.py", "x = 12\n")
+	write("src/app/model.py", "y = 2\n")
+	if err := os.Remove(filepath.Join(root, "tests/unit/test_parser.py")); err != nil {
+		t.Fatal(err)
+	}
+	changed := changedSince(before, snapshotTree(root))
+	want = []string{"src/app/model.py", "src/app/parser.py", "tests/unit/test_parser.py"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changedSince = %v, want %v", changed, want)
+	}
+	if changed := changedSince(before, before); len(changed) != 0 {
+		t.Fatalf("an unchanged tree reported %v", changed)
+	}
+	fmt.Println("✅ Watched file changes are detected")
+}
+
+// TestFormatWatchResult tests the compact per-iteration line
+func TestFormatWatchResult(t *testing.T) {
+	at := time.Date(2026, 1, 2, 14, 2, 31, 0, time.UTC)
+	line := FormatWatchResult(WatchResult{
+		Iteration: 3,
+		Changed:   []string{"src/a.py", "tests/test_a.py"},
+		Tests:     "✅ 14 passed",
+		Lint:      "✅",
+		Duration:  3240 * time.Millisecond,
+	}, at)
+	want := "[14:02:31] #3 2 file(s) changed — tests ✅ 14 passed | ruff ✅ (3.2s)"
+	if line != want {
+		t.Fatalf("got %q, want %q", line, want)
+	}
+	if first := FormatWatchResult(WatchResult{Iteration: 1, Tests: "✅ 5 passed", Lint: "✅"}, at); !strings.Contains(first, "#1 initial run") {
+		t.Fatalf("first iteration line = %q", first)
+	}
+	fmt.Println("✅ Watch result line is compact")
+}
+
+// TestNewWatchPipeline tests that watch mode keeps the build settings of a run and drops its other stages
+func TestNewWatchPipeline(t *testing.T) {
+	hosts := []GitCredentialHost{{Host: "gitlab.corp.local", User: "oauth2"}}
+	p, err := newWatchPipeline(Config{
+		LocalSource:        t.TempDir(),
+		RunBuild:           true,
+		RunPublish:         true,
+		ReleaseVersion:     "1.2.0",
+		PackagePublish:     PackagePublish{Upload: true},
+		GitDependencyToken: "dep-token",
+		GitDependencyHosts: hosts,
+		PipIndexURL:        "https://pypi.corp.local/simple",
+		CacheKey:           "api",
+		ArtifactsDir:       t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("a run configuration without a registry token should still watch: %v", err)
+	}
+	cfg := p.Config()
+	if cfg.GitDependencyToken != "dep-token" || !reflect.DeepEqual(cfg.GitDependencyHosts, hosts) || cfg.PipIndexURL != "https://pypi.corp.local/simple" || cfg.CacheKey != "api" {
+		t.Fatalf("build settings lost: %+v", cfg)
+	}
+	if !cfg.RunUnitTests || !cfg.RunLint || cfg.RunBuild || cfg.RunPublish || cfg.ReleaseVersion != "" || cfg.PackagePublish.Upload {
+		t.Fatalf("watch runs only the unit tests and ruff: %+v", cfg)
+	}
+	if cfg.LintChangedMax != DefaultLintChangedMax || cfg.BaseImage != BaseImage {
+		t.Fatalf("defaults not applied: LintChangedMax %d, BaseImage %s", cfg.LintChangedMax, cfg.BaseImage)
+	}
+	fmt.Println("✅ Watch mode built from the run configuration")
+}
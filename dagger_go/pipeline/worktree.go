@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The pipeline never writes into the checkout it tests: host-run tools are
+// pointed at these directories under ArtifactsDir instead.
+const (
+	HostPytestCacheDir = "pytest-cache" // pytest's cache_dir (lastfailed, nodeids)
+	HostPycacheDir     = "pycache"      // PYTHONPYCACHEPREFIX for bytecode
+)
+
+// hostPytestCache is pytest's cache directory for host runs whose JUnit
+// report is junit.
+func hostPytestCache(junit string) string {
+	return filepath.Join(filepath.Dir(junit), HostPytestCacheDir)
+}
+
+// hostWriteEnv is the environment that keeps host-run Python from writing
+// bytecode next to the sources.
+func (p *Pipeline) hostWriteEnv() []string {
+	dir, err := filepath.Abs(filepath.Join(p.cfg.ArtifactsDir, HostPycacheDir))
+	if err != nil {
+		return nil
+	}
+	return []string{"PYTHONPYCACHEPREFIX=" + dir}
+}
+
+// Within reports whether path is dir or inside it. Both are made absolute
+// first; symlinks are not resolved.
+func Within(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// WorktreeSnapshot maps each path `git status` reports — modified, untracked
+// or ignored — to its status and, for files, a hash of the contents, so an
+// already-modified file that changes again is noticed too. New untracked and
+// ignored directories appear as one entry; their contents are not listed,
+// which keeps .venv and node_modules cheap.
+type WorktreeSnapshot map[string]string
+
+// ParseGitStatus reads `git status --porcelain -z` output into a snapshot
+// without hashes.
+func ParseGitStatus(output string) WorktreeSnapshot {
+	snap := WorktreeSnapshot{}
+	fields := strings.Split(output, "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		snap[path] = status
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // the next field is the original path
+		}
+	}
+	return snap
+}
+
+// SnapshotWorktree records the state of the git checkout at root.
+func SnapshotWorktree(ctx context.Context, root string) (WorktreeSnapshot, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "status", "--porcelain", "-z", "--untracked-files=normal", "--ignored=traditional").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status in %s: %w", root, err)
+	}
+	snap := ParseGitStatus(string(out))
+	for path, status := range snap {
+		if sum, err := hashFile(filepath.Join(root, path)); err == nil {
+			snap[path] = status + " " + sum
+		}
+	}
+	return snap, nil
+}
+
+// hashFile hashes a regular file; directories (collapsed ignored entries)
+// are left to their status.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DirtiedPaths lists the paths that appeared or changed between two
+// snapshots, leaving out those the allowed function accepts.
+func DirtiedPaths(before, after WorktreeSnapshot, allowed func(path string) bool) []string {
+	var dirtied []string
+	for path, state := range after {
+		if before[path] == state || allowed != nil && allowed(path) {
+			continue
+		}
+		dirtied = append(dirtied, path)
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok && (allowed == nil || !allowed(path)) {
+			dirtied = append(dirtied, path) // e.g. a modified file restored or deleted
+		}
+	}
+	sort.Strings(dirtied)
+	return dirtied
+}
+
+// checkWorktree is the Paranoid check: it fails the run when the checkout
+// at ProjectRoot changed outside ArtifactsDir while the pipeline ran.
+func (p *Pipeline) checkWorktree(ctx context.Context, root string, before WorktreeSnapshot) error {
+	after, err := SnapshotWorktree(context.WithoutCancel(ctx), root)
+	if err != nil {
+		p.warnf("worktree-check", "⚠️  Paranoid mode: could not re-check the working tree: %v\n", err)
+		return nil
+	}
+	dirtied := DirtiedPaths(before, after, func(path string) bool {
+		return Within(p.cfg.ArtifactsDir, filepath.Join(root, path))
+	})
+	if len(dirtied) == 0 {
+		p.println("🛡️  Paranoid mode: the working tree is unchanged")
+		return nil
+	}
+	p.report.DirtiedPaths = dirtied
+	p.printf("\n❌ Paranoid mode: the pipeline changed %d path(s) in %s:\n", len(dirtied), root)
+	for _, path := range dirtied {
+		p.printf("   • %s\n", path)
+	}
+	return Errorf(CategoryUnknown, "the pipeline changed the working tree outside %s: %s", p.cfg.ArtifactsDir, strings.Join(dirtied, ", "))
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHostWritesStayOutOfCheckout tests that every path host-run tools write to is under ArtifactsDir
+func TestHostWritesStayOutOfCheckout(t *testing.T) {
+	root := t.TempDir()
+	artifacts := filepath.Join(root, "dagger_go", "pipeline-artifacts")
+	p, err := New(Config{RepoName: "cert-parser", GitUser: "org", ProjectRoot: root, ArtifactsDir: artifacts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []string
+	for _, marker := range []string{integrationMarker, acceptanceMarker} {
+		junit, err := p.junitPath(marker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, arg := range hostPytestArgs(marker, junit, nil) {
+			if strings.HasPrefix(arg, "--junitxml=") || strings.HasPrefix(arg, "cache_dir=") {
+				_, path, _ := strings.Cut(arg, "=")
+				written = append(written, path)
+			}
+		}
+	}
+	for _, kv := range p.hostWriteEnv() {
+		_, path, _ := strings.Cut(kv, "=")
+		written = append(written, path)
+	}
+	written = append(written, p.cfg.VenvDir)
+	if len(written) != 6 {
+		t.Fatalf("expected JUnit and cache paths for two stages, the bytecode prefix and the venv, got %v", written)
+	}
+	for _, path := range written {
+		if !Within(artifacts, path) {
+			t.Fatalf("%s is written outside %s", path, artifacts)
+		}
+	}
+
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", ProjectRoot: root, VenvDir: filepath.Join(root, ".venv-ci")}); err == nil {
+		t.Fatal("a VenvDir inside the checkout should be rejected")
+	}
+	if _, err := New(Config{RepoName: "cert-parser", GitUser: "org", ProjectRoot: root, VenvDir: filepath.Join(os.TempDir(), "cert-parser-venv")}); err != nil {
+		t.Fatalf("a VenvDir outside the checkout should be accepted: %v", err)
+	}
+	fmt.Println("✅ Host-side writes stay under the artifacts directory")
+}
+
+// TestWithin tests the directory containment check
+func TestWithin(t *testing.T) {
+	cases := []struct {
+		dir, path string
+		want      bool
+	}{
+		{"/repo", "/repo", true},
+		{"/repo", "/repo/dagger_go/pipeline-artifacts", true},
+		{"/repo/", "/repo/a/../b", true},
+		{"/repo", "/repository", false},
+		{"/repo", "/tmp/venv", false},
+		{"/repo/a", "/repo/a/../b", false},
+	}
+	for _, c := range cases {
+		if got := Within(c.dir, c.path); got != c.want {
+			t.Fatalf("Within(%q, %q) = %v, want %v", c.dir, c.path, got, c.want)
+		}
+	}
+	fmt.Println("✅ Directory containment checked")
+}
+
+// TestDirtiedPaths tests detecting working-tree changes between two git status snapshots
+func TestDirtiedPaths(t *testing.T) {
+	before := ParseGitStatus(" M src/app.py\x00?? notes.txt\x00!! .venv/\x00")
+	if before["src/app.py"] != " M" || before[".venv/"] != "!!" {
+		t.Fatalf("unexpected snapshot %v", before)
+	}
+	renamed := ParseGitStatus("R  new.py\x00old.py\x00?? x\x00")
+	if len(renamed) != 2 || renamed["new.py"] != "R " {
+		t.Fatalf("the original path of a rename is not an entry: %v", renamed)
+	}
+
+	after := WorktreeSnapshot{
+		"src/app.py":                         " M",
+		"notes.txt":                          "??",
+		".venv/":                             "!!",
+		"junit-integration.xml":              "??",
+		".pytest_cache/":                     "!!",
+		"dagger_go/pipeline-artifacts/":      "!!",
+		"dagger_go/pipeline-artifacts/x.xml": "??",
+	}
+	allowed := func(path string) bool { return strings.HasPrefix(path, "dagger_go/pipeline-artifacts/") }
+	got := DirtiedPaths(before, after, allowed)
+	if strings.Join(got, " ") != ".pytest_cache/ junit-integration.xml" {
+		t.Fatalf("got %v", got)
+	}
+	after["src/app.py"] = " M 0123"
+	delete(after, "notes.txt")
+	if got := DirtiedPaths(before, after, allowed); len(got) != 4 {
+		t.Fatalf("a changed and a removed entry should count, got %v", got)
+	}
+	fmt.Println("✅ Working tree changes detected")
+}
+
+// TestSnapshotWorktree tests that a file written into a git checkout is noticed, and one already modified that changes again
+func TestSnapshotWorktree(t *testing.T) {
+	root := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git unavailable: %v %s", err, out)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte(".pytest_cache/\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "app.py"), []byte("x = 1\n"), 0o644)
+	git("add", ".")
+	git("-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "-m", "init")
+	os.WriteFile(filepath.Join(root, "app.py"), []byte("x = 2\n"), 0o644)
+
+	ctx := context.Background()
+	before, err := SnapshotWorktree(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "app.py"), []byte("x = 3\n"), 0o644)
+	os.MkdirAll(filepath.Join(root, ".pytest_cache"), 0o755)
+	os.WriteFile(filepath.Join(root, ".pytest_cache", "lastfailed"), []byte("{}"), 0o644)
+	after, err := SnapshotWorktree(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DirtiedPaths(before, after, nil); strings.Join(got, " ") != ".pytest_cache/ app.py" {
+		t.Fatalf("got %v", got)
+	}
+	if _, err := SnapshotWorktree(ctx, t.TempDir()); err == nil {
+		t.Fatal("a directory that is not a git checkout should fail")
+	}
+	fmt.Println("✅ Working tree snapshots compared")
+}
@@ -13,6 +13,8 @@
 #   REPO_NAME          - Repository name (auto-detected from parent dir if unset)
 #   GIT_BRANCH         - Branch to build (default: main)
 #   IMAGE_NAME         - Docker image name (default: Docker-safe project name)
+#   RUN_BUILD          - Build the Docker image (default: true)
+#   RUN_PUBLISH        - Publish to the registry (default: true; CR_PAT only required when true)
 #
 # Corporate-specific:
 #   HTTP_PROXY / HTTPS_PROXY  - Corporate MITM proxy URL
@@ -3,7 +3,7 @@
 # Project name is auto-discovered from pyproject.toml.
 #
 # Required env vars (loaded from credentials/.env or exported):
-#   CR_PAT      - Personal access token for the container registry / git host
+#   CR_PAT      - Personal access token for the container registry / git host (publishing only)
 #   USERNAME    - Your username on the git host
 #
 # Repository & registry configuration (optional — sensible defaults):
@@ -13,6 +13,8 @@
 #   REPO_NAME          - Repository name (auto-detected from parent dir if unset)
 #   GIT_BRANCH         - Branch to build (default: main)
 #   IMAGE_NAME         - Docker image name (default: Docker-safe project name)
+#   RUN_BUILD          - Build the Docker image (default: true)
+#   RUN_PUBLISH        - Publish to the registry (default: true; CR_PAT only required when true)
 #
 # Examples:
 #   ./run.sh                                           # GitHub + GHCR (defaults)
@@ -34,8 +36,16 @@ if [ -z "$REPO_NAME" ]; then
     export REPO_NAME
 fi
 
-# Check required environment variables
-if [ -z "$CR_PAT" ]; then
+# Same truthiness rules as parseEnvBool in main.go
+is_true() {
+    case "$(echo "$1" | tr '[:upper:]' '[:lower:]')" in
+        true|1|yes) return 0 ;;
+        *) return 1 ;;
+    esac
+}
+
+# Check required environment variables (CR_PAT only matters when publishing)
+if is_true "${RUN_BUILD:-true}" && is_true "${RUN_PUBLISH:-true}" && [ -z "$CR_PAT" ]; then
     echo "❌ CR_PAT environment variable is not set"
     echo "   Set it to your Personal Access Token with registry write access"
     exit 1
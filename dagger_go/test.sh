@@ -29,6 +29,11 @@ echo ""
 echo "🧪 Running unit tests..."
 go test -v -run Test
 
+# Run corporate pipeline unit tests (corporate_main.go is behind a build tag)
+echo ""
+echo "🧪 Running corporate unit tests..."
+go test -v -tags corporate -run Test .
+
 # Build the binary
 echo ""
 echo "🔨 Building $PROJECT_NAME pipeline binary..."
@@ -1,34 +1,30 @@
 """
 Acceptance test fixtures — PostgreSQL testcontainer for end-to-end tests.
 
-Reuses the same DDL and pattern as integration tests but scoped for acceptance.
+Reuses the same DDL and pattern as integration tests but scoped for acceptance,
+including the external database used when EXTERNAL_TEST_DATABASE=1.
 """
 
 from __future__ import annotations
 
+from collections.abc import Iterator
+
 import psycopg
 import pytest
-from testcontainers.postgres import PostgresContainer
 
-from tests.integration.conftest import DDL, TRUNCATE_ALL
+from tests.integration.conftest import TRUNCATE_ALL, postgres_session_dsn
 
 
 @pytest.fixture(scope="session")
-def acceptance_pg() -> PostgresContainer:
-    """Start a PostgreSQL container for the acceptance test session."""
-    with PostgresContainer("postgres:16-alpine") as pg:
-        dsn = pg.get_connection_url().replace("postgresql+psycopg2", "postgresql")
-        with psycopg.connect(dsn) as conn:
-            conn.execute(DDL)
-            conn.commit()
-        yield pg
+def acceptance_pg() -> Iterator[str]:
+    """Provide PostgreSQL for the acceptance test session; yields its DSN."""
+    yield from postgres_session_dsn()
 
 
 @pytest.fixture()
-def acceptance_dsn(acceptance_pg: PostgresContainer) -> str:
+def acceptance_dsn(acceptance_pg: str) -> str:
     """Return a psycopg-compatible DSN and truncate all tables before each test."""
-    connection_url = acceptance_pg.get_connection_url().replace("postgresql+psycopg2", "postgresql")
-    with psycopg.connect(connection_url) as conn:
+    with psycopg.connect(acceptance_pg) as conn:
         conn.execute(TRUNCATE_ALL)
         conn.commit()
-    return connection_url
+    return acceptance_pg
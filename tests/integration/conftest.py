@@ -1,19 +1,29 @@
 """
 Integration test fixtures — PostgreSQL testcontainer and schema setup.
 
-Provides a real PostgreSQL instance for each test session via testcontainers.
-Creates all four tables matching the production schema.
+Provides a real PostgreSQL instance for each test session via testcontainers,
+or the external database in TEST_DATABASE_URL when EXTERNAL_TEST_DATABASE=1
+(set by the pipeline for DATABASE_URL_OVERRIDE on runners without Docker).
+Creates all four tables matching the production schema, then runs the
+pipeline's DB_SEED_COMMAND once when it hands one over in TEST_DB_SEED_COMMAND.
 Each test gets a fresh, clean database via truncation.
 """
 
 from __future__ import annotations
 
+import json
+import os
+import shlex
+import subprocess
+import sys
+import time
+from collections.abc import Iterator
+
 import psycopg
 import pytest
-from testcontainers.postgres import PostgresContainer
 
 DDL = """
-CREATE TABLE root_ca (
+CREATE TABLE IF NOT EXISTS root_ca (
     id                        UUID PRIMARY KEY,
     certificate               BYTEA NOT NULL,
     subject_key_identifier    TEXT,
@@ -26,7 +36,7 @@ CREATE TABLE root_ca (
     updated_at                TIMESTAMP WITHOUT TIME ZONE
 );
 
-CREATE TABLE dsc (
+CREATE TABLE IF NOT EXISTS dsc (
     id                        UUID PRIMARY KEY,
     certificate               BYTEA NOT NULL,
     subject_key_identifier    TEXT,
@@ -38,7 +48,7 @@ CREATE TABLE dsc (
     updated_at                TIMESTAMP WITHOUT TIME ZONE
 );
 
-CREATE TABLE crls (
+CREATE TABLE IF NOT EXISTS crls (
     id          UUID PRIMARY KEY,
     crl         BYTEA NOT NULL,
     source      TEXT,
@@ -47,7 +57,7 @@ CREATE TABLE crls (
     updated_at  TIMESTAMP WITHOUT TIME ZONE
 );
 
-CREATE TABLE revoked_certificate_list (
+CREATE TABLE IF NOT EXISTS revoked_certificate_list (
     id                UUID PRIMARY KEY,
     source            TEXT,
     country           TEXT,
@@ -64,24 +74,82 @@ TRUNCATE revoked_certificate_list, crls, dsc, root_ca CASCADE;
 """
 
 
-@pytest.fixture(scope="session")
-def postgres_container() -> PostgresContainer:
-    """Start a PostgreSQL container for the entire test session."""
+def external_database_url() -> str | None:
+    """Return the external test database DSN when the pipeline provided one."""
+    if os.environ.get("EXTERNAL_TEST_DATABASE") != "1":
+        return None
+    url = os.environ.get("TEST_DATABASE_URL")
+    if not url:
+        raise RuntimeError("EXTERNAL_TEST_DATABASE=1 requires TEST_DATABASE_URL")
+    return url
+
+
+def run_seed_command(dsn: str) -> None:
+    """Run TEST_DB_SEED_COMMAND once against dsn, after the schema exists.
+
+    The pipeline hands the command over only when the database lives in this
+    session (testcontainers); it seeds an external database itself. The
+    outcome goes to TEST_DB_SEED_RESULT so the pipeline can report the seed
+    duration apart from the tests, and a failure stops the session.
+    """
+    command = os.environ.get("TEST_DB_SEED_COMMAND")
+    if not command:
+        return
+    env = {
+        **os.environ,
+        "TEST_DATABASE_URL": dsn,
+        "DATABASE_DSN": dsn,
+        "PATH": os.path.dirname(sys.executable) + os.pathsep + os.environ.get("PATH", ""),
+    }
+    start = time.monotonic()
+    proc = subprocess.run(shlex.split(command), env=env, capture_output=True, text=True, check=False)
+    output = proc.stdout + proc.stderr
+    result = os.environ.get("TEST_DB_SEED_RESULT")
+    if result:
+        with open(result, "w", encoding="utf-8") as f:
+            json.dump(
+                {
+                    "duration_seconds": time.monotonic() - start,
+                    "exit_code": proc.returncode,
+                    "output": output,
+                },
+                f,
+            )
+    if proc.returncode != 0:
+        raise RuntimeError(f"DB_SEED_COMMAND failed with exit code {proc.returncode}:\n{output}")
+
+
+def postgres_session_dsn() -> Iterator[str]:
+    """Yield a DSN with the schema created, from the external database or a testcontainer."""
+    external = external_database_url()
+    if external is not None:
+        with psycopg.connect(external) as conn:
+            conn.execute(DDL)
+            conn.commit()
+        yield external
+        return
+
+    from testcontainers.postgres import PostgresContainer
+
     with PostgresContainer("postgres:16-alpine") as pg:
         dsn = pg.get_connection_url().replace("postgresql+psycopg2", "postgresql")
         with psycopg.connect(dsn) as conn:
             conn.execute(DDL)
             conn.commit()
-        yield pg
+        run_seed_command(dsn)
+        yield dsn
+
+
+@pytest.fixture(scope="session")
+def postgres_container() -> Iterator[str]:
+    """Provide PostgreSQL for the entire test session; yields its DSN."""
+    yield from postgres_session_dsn()
 
 
 @pytest.fixture()
-def dsn(postgres_container: PostgresContainer) -> str:
+def dsn(postgres_container: str) -> str:
     """Return a psycopg-compatible DSN and truncate all tables before each test."""
-    connection_url = postgres_container.get_connection_url().replace(
-        "postgresql+psycopg2", "postgresql"
-    )
-    with psycopg.connect(connection_url) as conn:
+    with psycopg.connect(postgres_container) as conn:
         conn.execute(TRUNCATE_ALL)
         conn.commit()
-    return connection_url
+    return postgres_container